@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/n-th/gymshark/internal/auth"
+	"github.com/n-th/gymshark/internal/config"
+)
+
+// buildAdminVerifier returns the verifier of admin tokens, or nil when admin
+// authentication is not enabled.
+func buildAdminVerifier(c config.AdminAuthConfig) (*auth.Verifier, error) {
+	if !c.Enabled() {
+		if c.Issuer != "" || c.Audience != "" {
+			return nil, errors.New("server.admin_auth.jwks_url is required")
+		}
+		return nil, nil
+	}
+	if c.Issuer == "" {
+		return nil, errors.New("server.admin_auth.issuer is required")
+	}
+	if c.RefreshInterval < 0 {
+		return nil, errors.New("server.admin_auth.refresh_interval must not be negative")
+	}
+	return auth.NewVerifier(auth.Config{
+		Issuer:          c.Issuer,
+		Audience:        c.Audience,
+		JWKSURL:         c.JWKSURL,
+		RolesClaim:      c.RolesClaim,
+		RefreshInterval: c.RefreshInterval,
+	})
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/n-th/gymshark/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildAdminVerifier(t *testing.T) {
+	verifier, err := buildAdminVerifier(config.AdminAuthConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, verifier)
+
+	verifier, err = buildAdminVerifier(config.AdminAuthConfig{
+		Issuer:          "https://idp.example.com",
+		Audience:        "gymshark",
+		JWKSURL:         "https://idp.example.com/.well-known/jwks.json",
+		RefreshInterval: 10 * time.Minute,
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, verifier)
+
+	for _, c := range []config.AdminAuthConfig{
+		{Issuer: "https://idp.example.com"},
+		{JWKSURL: "https://idp.example.com/.well-known/jwks.json"},
+		{Issuer: "https://idp.example.com", JWKSURL: "https://idp.example.com/.well-known/jwks.json", RefreshInterval: -time.Minute},
+	} {
+		_, err := buildAdminVerifier(c)
+		assert.Error(t, err, c)
+	}
+}
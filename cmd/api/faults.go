@@ -0,0 +1,25 @@
+//go:build faults
+
+package main
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/n-th/gymshark/internal/faults"
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+// installFaults wraps the storage and the allocation strategies with a fault
+// injector, and returns a function serving /debug/faults to control it.
+func installFaults(store storage.Storage) (storage.Storage, func(gin.IRoutes)) {
+	log.Printf("WARNING: built with fault injection; /debug/faults can slow down and fail requests")
+	injector := faults.New()
+	if err := faults.WrapStrategies(injector); err != nil {
+		log.Fatalf("Failed to inject allocation faults: %v", err)
+	}
+	return faults.Storage(store, injector), func(r gin.IRoutes) {
+		faults.Register(r, injector)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/n-th/gymshark/internal/config"
+)
+
+// listener is an address the server is served on. UNIX sockets are local,
+// for a sidecar proxy, so they are served without TLS.
+type listener struct {
+	net.Listener
+	tls bool
+}
+
+// listen opens every listener the server config asks for: host and port
+// unless port is zero, each listen address and the UNIX socket. On failure
+// it closes the listeners it already opened.
+func listen(c config.ServerConfig, withTLS bool) (listeners []listener, err error) {
+	defer func() {
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			listeners = nil
+		}
+	}()
+
+	addrs := c.Listen
+	if c.Port != 0 {
+		addrs = append([]string{net.JoinHostPort(c.Host, strconv.Itoa(c.Port))}, addrs...)
+	}
+	for _, addr := range addrs {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return listeners, err
+		}
+		listeners = append(listeners, listener{Listener: l, tls: withTLS})
+	}
+	if c.Socket != "" {
+		l, err := listenUnix(c.Socket, c.SocketMode)
+		if err != nil {
+			return listeners, err
+		}
+		listeners = append(listeners, listener{Listener: l})
+	}
+	return listeners, nil
+}
+
+// listenUnix listens on the UNIX socket at path, replacing a socket left
+// behind by a previous run, and sets its permissions to mode when not zero.
+// The socket file is removed when the listener is closed.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// serve serves server on every listener until it is shut down, which
+// closes them all.
+func serve(server *http.Server, listeners []listener) {
+	for _, l := range listeners {
+		go func(l listener) {
+			var err error
+			if l.tls {
+				log.Printf("Serving HTTPS on %s", l.Addr())
+				err = server.ServeTLS(l, "", "")
+			} else {
+				log.Printf("Serving HTTP on %s:%s", l.Addr().Network(), l.Addr())
+				err = server.Serve(l)
+			}
+			if err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Failed to serve on %s: %v", l.Addr(), err)
+			}
+		}(l)
+	}
+}
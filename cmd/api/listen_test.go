@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/n-th/gymshark/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListen(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "api.sock")
+	// A socket left behind by a previous run is replaced.
+	stale, err := net.Listen("unix", socket)
+	assert.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	listeners, err := listen(config.ServerConfig{
+		Listen:     []string{"127.0.0.1:0", "127.0.0.1:0"},
+		Socket:     socket,
+		SocketMode: 0600,
+	}, false)
+	assert.NoError(t, err)
+	if !assert.Len(t, listeners, 3) {
+		return
+	}
+	fi, err := os.Stat(socket)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})}
+	serve(server, listeners)
+
+	get := func(client *http.Client, url string) {
+		resp, err := client.Get(url)
+		if assert.NoError(t, err) {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			assert.Equal(t, "ok", string(body))
+		}
+	}
+	for _, l := range listeners[:2] {
+		get(http.DefaultClient, "http://"+l.Addr().String()+"/health")
+	}
+	unixClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	get(unixClient, "http://api/health")
+
+	// Shutting down closes every listener and removes the socket.
+	assert.NoError(t, server.Shutdown(context.Background()))
+	_, err = net.Dial("tcp", listeners[0].Addr().String())
+	assert.Error(t, err)
+	_, err = os.Stat(socket)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestListenFailure(t *testing.T) {
+	// A path that is not a socket is left alone, and the listeners already
+	// opened are closed.
+	path := filepath.Join(t.TempDir(), "api.sock")
+	assert.NoError(t, os.WriteFile(path, nil, 0600))
+	listeners, err := listen(config.ServerConfig{Listen: []string{"127.0.0.1:0"}, Socket: path}, false)
+	assert.ErrorContains(t, err, "not a socket")
+	assert.Nil(t, listeners)
+	_, err = os.Stat(path)
+	assert.NoError(t, err)
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,117 +15,515 @@ import (
 	"syscall"
 	"time"
 
-	"gopkg.in/yaml.v3"
-
 	"github.com/gin-gonic/gin"
-	_ "github.com/n-th/gymshark/docs" // generated swagger docs
+	"github.com/n-th/gymshark/docs" // generated swagger docs
 	"github.com/n-th/gymshark/internal/allocator"
 	"github.com/n-th/gymshark/internal/api"
+	"github.com/n-th/gymshark/internal/config"
+	"github.com/n-th/gymshark/internal/events"
+	"github.com/n-th/gymshark/internal/health"
+	"github.com/n-th/gymshark/internal/invalidation"
+	"github.com/n-th/gymshark/internal/metrics"
+	"github.com/n-th/gymshark/internal/notify"
 	"github.com/n-th/gymshark/internal/storage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 )
 
-type Config struct {
-	PackSizes []int `yaml:"pack_sizes"`
-	Server    struct {
-		Port int    `yaml:"port"`
-		Host string `yaml:"host"`
-	} `yaml:"server"`
-}
+// strictConfig refuses to start without a valid config. It is on by default
+// with GIN_MODE=release, as in the Docker image, so production never serves
+// the development fallback config.
+var strictConfig = flag.Bool("strict-config", gin.Mode() == gin.ReleaseMode,
+	"refuse to start without a valid config/config.yaml; false falls back to a development config")
 
-func loadConfig(path string) (*Config, error) {
+// loadConfig loads the config at path; see config.Load.
+func loadConfig(path string) (*config.Config, error) {
 	log.Printf("Loading config from %s", path)
-	f, err := os.Open(path)
+	cfg, err := config.Load(path)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
-	var cfg Config
-	dec := yaml.NewDecoder(f)
-	if err := dec.Decode(&cfg); err != nil {
-		return nil, err
-	}
+	log.Printf("Loaded config: pack_sizes=%v, strategy=%s, server.host=%s, server.port=%d", cfg.PackSizes, cfg.Strategy, cfg.Server.Host, cfg.Server.Port)
+	return cfg, nil
+}
 
-	// Validate pack sizes
-	if len(cfg.PackSizes) == 0 {
-		return nil, errors.New("no pack sizes configured")
+// eventPublisher connects to the configured broker, or returns nil if none is.
+func eventPublisher(c config.EventsConfig) (events.Publisher, error) {
+	switch {
+	case c.Kafka.RESTProxyURL != "":
+		return events.NewKafkaPublisher(c.Kafka.RESTProxyURL, c.Kafka.Topic)
+	case c.NATS.URL != "":
+		return events.NewNATSPublisher(c.NATS.URL, c.NATS.Subject)
 	}
+	return nil, nil
+}
 
-	// Validate that all pack sizes are positive
-	for i, size := range cfg.PackSizes {
-		if size <= 0 {
-			return nil, fmt.Errorf("invalid pack size at index %d: %d (must be positive)", i, size)
+// alertNotifier sends alerts over the configured channels, or returns nil if
+// none is.
+func alertNotifier(c config.NotificationsConfig) (*notify.Notifier, error) {
+	if !c.Enabled() {
+		return nil, nil
+	}
+	var senders []notify.Sender
+	if c.Slack.WebhookURL != "" {
+		slack, err := notify.NewSlackSender(c.Slack.WebhookURL)
+		if err != nil {
+			return nil, err
+		}
+		senders = append(senders, slack)
+	}
+	if e := c.Email; e.SMTPAddr != "" {
+		var password []byte
+		if e.Username != "" {
+			var err error
+			if password, err = os.ReadFile(e.PasswordFile); err != nil {
+				return nil, err
+			}
+		}
+		email, err := notify.NewEmailSender(e.SMTPAddr, e.From, e.To, e.Username, string(bytes.TrimSpace(password)))
+		if err != nil {
+			return nil, err
 		}
+		senders = append(senders, email)
+	}
+	return notify.NewNotifier(senders, c.Cooldown, c.Buffer), nil
+}
+
+// openStorage opens the configured storage backend for the runtime
+// environment. APP_ENV=test uses a temporary database so end-to-end runs are
+// hermetic; otherwise allocations are persisted under the data directory.
+func openStorage(env string, cfg config.StorageConfig) (storage.Storage, error) {
+	if env == "test" {
+		log.Printf("APP_ENV=test: using temporary storage")
+		return storage.OpenTemporary(cfg.Backend, cfg.WriteMode)
+	}
+
+	// Create data directory if it doesn't exist
+	dir := dataDir(env)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create data directory: %w", err)
 	}
 
-	log.Printf("Loaded config: pack_sizes=%v, server.host=%s, server.port=%d", cfg.PackSizes, cfg.Server.Host, cfg.Server.Port)
-	return &cfg, nil
+	return storage.Open(cfg.Backend, filepath.Join(dir, storage.DatabaseFile(cfg.Backend)), cfg.WriteMode)
 }
 
+// dataDir is the directory holding the database for the runtime environment.
+func dataDir(env string) string {
+	if env == "docker" {
+		return "/app/data"
+	}
+	return "data"
+}
+
+// healthChecker builds the readiness probes: the latency of reading the most
+// recent allocation from store, unless the database is in memory how full the
+// data volume is and, with a Redis cache, whether its circuit is open.
+func healthChecker(cfg config.HealthConfig, store storage.History, cache *storage.BreakerCache, env string) *health.Checker {
+	var probes []health.Probe
+	if cache != nil {
+		probes = append(probes, health.Degraded("cache", cache))
+	}
+	if cfg.Database.Enabled() {
+		probes = append(probes, health.Latency("database", func(context.Context) error {
+			_, err := store.GetRecentAllocations(1, false)
+			return err
+		}, cfg.Database.Warning, cfg.Database.Critical))
+	}
+	if cfg.Disk.Enabled() && env != "test" {
+		probes = append(probes, health.Disk("disk", dataDir(env), cfg.Disk.WarningPercent, cfg.Disk.CriticalPercent))
+	}
+	return health.NewChecker(cfg.Timeout, probes...)
+}
+
+// selfTest makes main check allocations before serving; see runSelfTest.
+var selfTest = flag.Bool("self-test", false, "check the self_test cases and edge quantities before serving; exit 1 on a wrong allocation")
+
 // @title Smart Pack Allocation API
 // @version 1.0
 // @description A Go-based API service that calculates optimal pack distribution for fulfilling orders with fixed pack sizes.
 // @host localhost:8080
 // @BasePath /
 func main() {
-	// Create data directory if it doesn't exist
-	dataDir := "data"
-	if os.Getenv("APP_ENV") == "docker" {
-		dataDir = "/app/data"
-	}
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		log.Fatalf("Failed to create data directory: %v", err)
+	flag.Parse()
+
+	cfg, err := loadConfig("config/config.yaml")
+	if err != nil {
+		if *strictConfig {
+			log.Fatalf("Failed to load config: %v (start with -strict-config=false to run with the development fallback)", err)
+		}
+		log.Printf("Failed to load config: %v", err)
+		cfg = config.Fallback()
+		log.Printf("WARNING: using the development fallback config with pack sizes %v; never run this in production", cfg.PackSizes)
 	}
 
 	// Initialize storage
-	store, err := storage.NewSQLiteStorage(filepath.Join(dataDir, "allocations.db"))
+	db, err := openStorage(os.Getenv("APP_ENV"), cfg.Storage)
 	if err != nil {
 		log.Fatalf("Failed to initialize storage: %v", err)
 	}
-	defer store.Close()
+	defer db.Close()
 
-	cfg, err := loadConfig("config/config.yaml")
-	if err != nil {
-		log.Printf("Failed to load config: %v", err)
-		log.Printf("Using default config")
-		cfg = &Config{
-			PackSizes: []int{1, 2, 3},
-			Server: struct {
-				Port int    `yaml:"port"`
-				Host string `yaml:"host"`
-			}{Port: 8080, Host: "0.0.0.0"},
+	// Fault injection, only compiled in with -tags faults
+	store, registerFaults := installFaults(db)
+
+	// Measure, trace and log the calls to the primary storage
+	var instrumented *storage.InstrumentedStorage
+	if in := cfg.Storage.Instrumentation; in.Enabled {
+		var tracer trace.Tracer
+		if in.Tracing {
+			tracer = otel.Tracer("github.com/n-th/gymshark/internal/storage")
 		}
+		instrumented = storage.NewInstrumentedStorage(store, storage.Instrumentation{
+			SlowThreshold: in.SlowThreshold,
+			Tracer:        tracer,
+		})
+		store = instrumented
+	}
+
+	// Buffer writes locally while the primary storage is unavailable
+	var fallback *storage.FallbackStorage
+	if cfg.Storage.FallbackPath != "" {
+		buffer, err := storage.NewSQLiteStorage(cfg.Storage.FallbackPath)
+		if err != nil {
+			log.Fatalf("Failed to open storage fallback buffer: %v", err)
+		}
+		fallback, err = storage.NewFallbackStorage(store, buffer)
+		if err != nil {
+			log.Fatalf("Failed to configure storage fallback: %v", err)
+		}
+		store = fallback
 	}
 
 	// Initialize allocator with storage
 	alloc := allocator.NewAllocator(cfg.PackSizes, store)
 	defer alloc.Close()
+	if err := alloc.SetStrategy(cfg.Strategy); err != nil {
+		log.Fatalf("Failed to set allocation strategy: %v", err)
+	}
+	alloc.SetTimeouts(cfg.Calculation.SoftTimeout, cfg.Calculation.HardTimeout)
+	alloc.SetCostModel(cfg.Calculation.Cost.Model())
+	if err := alloc.SetShadow(cfg.Calculation.Shadow.Shadow()); err != nil {
+		log.Fatalf("Failed to configure shadow strategy: %v", err)
+	}
+	if sh := cfg.Calculation.Shadow; sh.Rate > 0 {
+		log.Printf("Running the %s strategy in shadow for %.4g%% of calculations", sh.Strategy, sh.Rate*100)
+	}
+	alloc.SetNegativeCacheTTL(cfg.Calculation.NegativeCacheTTL)
+	alloc.SetResultCache(cfg.Calculation.ResultCache)
+	ad := cfg.Calculation.Admission
+	if err := alloc.SetAdmission(allocator.Admission{
+		MaxInFlight: ad.MaxInFlight,
+		MaxQueue:    ad.MaxQueue,
+		MaxWait:     ad.MaxWait,
+		RetryAfter:  ad.RetryAfter,
+	}); err != nil {
+		log.Fatalf("Failed to configure admission control: %v", err)
+	}
+	var cache *storage.BreakerCache
+	if cfg.Storage.Cache.RedisURL != "" {
+		redis, err := storage.NewRedisCache(context.Background(), cfg.Storage.Cache.RedisURL, cfg.Storage.Cache.Prefix, cfg.Storage.Cache.TTL)
+		if err != nil {
+			log.Fatalf("Failed to configure allocation cache: %v", err)
+		}
+		cache = storage.NewBreakerCache("redis", redis, cfg.Storage.Cache.Breaker.Policy())
+		alloc.SetCache(cache)
+		log.Printf("Serving cached allocations from Redis")
+	}
+	var replicas *storage.ReplicaCache
+	if r := cfg.Storage.Replicas; len(r.DSNs) > 0 {
+		primary, ok := db.(*storage.SQLiteStorage)
+		if !ok {
+			log.Fatalf("Storage replicas need the %s backend", storage.BackendSQLite)
+		}
+		replicas, err = storage.NewReplicaCache(primary, r.DSNs, r.MaxStaleness)
+		if err != nil {
+			log.Fatalf("Failed to open storage replicas: %v", err)
+		}
+		alloc.SetCache(replicas)
+		log.Printf("Serving cached allocations from %d storage replicas", len(r.DSNs))
+	}
+	alloc.SetLimits(allocator.Limits{
+		MaxQuantity:  cfg.Calculation.MaxQuantity,
+		MaxBatchSize: cfg.Calculation.MaxBatchSize,
+	})
+	if err := alloc.SetProfiles(cfg.Profiles, cfg.SKUProfiles); err != nil {
+		log.Fatalf("Failed to configure pack size profiles: %v", err)
+	}
+	if err := alloc.SetPackSizeRules(cfg.PackSizeRules.Rules()); err != nil {
+		log.Fatalf("Failed to configure pack size rules: %v", err)
+	}
+	if err := alloc.SetProfileGuardrail(cfg.ProfileGuardrail.Guardrail()); err != nil {
+		log.Fatalf("Failed to configure the profile guardrail: %v", err)
+	}
+	if err := alloc.SetAllocationRules(cfg.AllocationRules.Rules()); err != nil {
+		log.Fatalf("Failed to configure allocation rules: %v", err)
+	}
+	if err := checkPackSizes(alloc); err != nil {
+		log.Fatalf("Invalid pack sizes: %v", err)
+	}
+	units, err := config.QuantityUnits(cfg.Units)
+	if err != nil {
+		log.Fatalf("Invalid units: %v", err)
+	}
+	if err := alloc.SetUnits(units); err != nil {
+		log.Fatalf("Failed to configure units: %v", err)
+	}
+	if err := alloc.SetMetering(cfg.Usage.Metering()); err != nil {
+		log.Fatalf("Failed to configure usage metering: %v", err)
+	}
+	if err := alloc.SetPackLimits(cfg.PackLimits); err != nil {
+		log.Fatalf("Failed to configure pack limits: %v", err)
+	}
+	if err := alloc.SetDeprecatedSizes(cfg.DeprecatedSizes); err != nil {
+		log.Fatalf("Failed to configure deprecated pack sizes: %v", err)
+	}
+	dimensions := make(map[string]map[int]allocator.Dimensions, len(cfg.PackDimensions))
+	for name, bySize := range cfg.PackDimensions {
+		dimensions[name] = make(map[int]allocator.Dimensions, len(bySize))
+		for size, d := range bySize {
+			dimensions[name][size] = allocator.Dimensions{Length: d.Length, Width: d.Width, Height: d.Height, Weight: d.Weight}
+		}
+	}
+	if err := alloc.SetPackDimensions(dimensions); err != nil {
+		log.Fatalf("Failed to configure pack dimensions: %v", err)
+	}
+	cartons := make([]allocator.Carton, len(cfg.Cartons))
+	for i, c := range cfg.Cartons {
+		cartons[i] = allocator.Carton{Name: c.Name, Length: c.Length, Width: c.Width, Height: c.Height, MaxWeight: c.MaxWeight}
+	}
+	if err := alloc.SetCartons(cartons); err != nil {
+		log.Fatalf("Failed to configure cartons: %v", err)
+	}
+	if len(cfg.WeightPackSizes) > 0 {
+		sizes := make([]allocator.Weight, len(cfg.WeightPackSizes))
+		for i, kg := range cfg.WeightPackSizes {
+			sizes[i], _ = allocator.WeightFromKilograms(kg)
+		}
+		if err := alloc.SetWeightPackSizes(sizes); err != nil {
+			log.Fatalf("Failed to configure weight pack sizes: %v", err)
+		}
+	}
+	if err := alloc.SetReadOnly(cfg.ReadOnly.State()); err != nil {
+		log.Fatalf("Failed to configure read-only mode: %v", err)
+	}
+	if cfg.ReadOnly.Enabled {
+		log.Printf("Starting in read-only mode (%s)", alloc.ReadOnly().Mode)
+	}
+	if err := alloc.RecordProfileVersions(); err != nil {
+		log.Fatalf("Failed to record pack size profile versions: %v", err)
+	}
+	if err := alloc.LoadPins(); err != nil {
+		log.Fatalf("Failed to load pinned allocations: %v", err)
+	}
+	if err := alloc.LoadProfileDefaults(); err != nil {
+		log.Fatalf("Failed to load profile defaults: %v", err)
+	}
+	if *selfTest {
+		if err := runSelfTest(context.Background(), alloc, cfg.SelfTest); err != nil {
+			log.Fatalf("Self-test failed: %v", err)
+		}
+	}
+	alloc.SetOutbox(allocator.OutboxOptions{
+		Capacity:    cfg.Storage.Outbox.Capacity,
+		MaxAttempts: cfg.Storage.Outbox.MaxAttempts,
+	})
+	retention := storage.RetentionPolicy{MaxAge: cfg.Retention.MaxAge, MaxRows: cfg.Retention.MaxRows}
+	alloc.SetRetention(retention)
+
+	// Background jobs run until shutdown
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	if retention.Enabled() && cfg.Retention.Interval > 0 {
+		go alloc.RunPruner(backgroundCtx, cfg.Retention.Interval)
+	}
+	if fallback != nil {
+		go fallback.RunReplayer(backgroundCtx, cfg.Storage.ReplayInterval)
+	}
+	if replicas != nil {
+		go replicas.RunReplicaChecks(backgroundCtx, cfg.Storage.Replicas.CheckInterval)
+	}
+	if cfg.Storage.Outbox.Capacity > 0 {
+		go alloc.RunOutbox(backgroundCtx, cfg.Storage.Outbox.RetryInterval)
+	}
+	if w := cfg.Calculation.Warm; w.Top > 0 && cfg.Calculation.ResultCache && !cfg.ReadOnly.Enabled {
+		var since time.Time
+		if w.Lookback > 0 {
+			since = time.Now().Add(-w.Lookback)
+		}
+		go func() {
+			if _, err := alloc.WarmTop(backgroundCtx, w.Top, since); err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("Warming the result cache failed: %v", err)
+			}
+		}()
+	}
+
+	// Keep caches coherent with the other replicas
+	if cfg.Invalidation.RedisURL != "" {
+		bus, err := invalidation.NewRedisBus(backgroundCtx, cfg.Invalidation.RedisURL, cfg.Invalidation.Channel)
+		if err != nil {
+			log.Fatalf("Failed to configure cache invalidation: %v", err)
+		}
+		defer bus.Close()
+		alloc.SetInvalidationBus(bus)
+		go alloc.ListenForInvalidations(backgroundCtx)
+		log.Printf("Cache invalidation enabled over Redis")
+	}
+
+	publisher, err := eventPublisher(cfg.Events)
+	if err != nil {
+		log.Fatalf("Failed to configure event publishing: %v", err)
+	}
+	if publisher != nil {
+		async := events.NewAsyncPublisher(publisher, cfg.Events.Buffer)
+		defer async.Close()
+		alloc.SetEventPublisher(async)
+		log.Printf("Publishing allocation and profile events")
+	}
+
+	// Alert operators to anomalous allocations
+	notifier, err := alertNotifier(cfg.Notifications)
+	if err != nil {
+		log.Fatalf("Failed to configure notifications: %v", err)
+	}
+	if notifier != nil {
+		defer notifier.Close()
+		alloc.SetNotifier(notifier, cfg.Notifications.Policy())
+		log.Printf("Alerting on anomalous allocations")
+	}
+
+	// Keep the profiles in line with the declared ones
+	if sync := cfg.ProfileSync; sync.Path != "" {
+		if err := reconcileProfiles(backgroundCtx, alloc, sync.Path); err != nil {
+			log.Fatalf("Failed to sync pack size profiles: %v", err)
+		}
+		go syncProfiles(backgroundCtx, alloc, sync)
+		log.Printf("Syncing pack size profiles from %s every %s", sync.Path, sync.Interval)
+	}
 
 	// Create a new Gin router
+	if cfg.Server.Mode != "" {
+		gin.SetMode(cfg.Server.Mode)
+	}
 	router := gin.Default()
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("Failed to configure trusted proxies: %v", err)
+	}
+	if cfg.Server.BasePath != "" {
+		docs.SwaggerInfo.BasePath = cfg.Server.BasePath
+	}
+	router.Use(api.MaxBodySize(cfg.Server.MaxBodyBytes))
+	timeouts := cfg.Server.Timeouts.Timeouts()
+	timeouts.BasePath = cfg.Server.BasePath
+	router.Use(api.Timeout(timeouts))
+	if cfg.Server.Compression.Enabled {
+		router.Use(api.Compression(cfg.Server.Compression.MinSize))
+	}
 
 	// Create a new handler
 	handler := api.NewHandler(alloc)
+	handler.SetLiveOptions(api.LiveOptions{
+		Debounce: cfg.Server.WebSocket.Debounce,
+		Rate:     cfg.Server.WebSocket.RateLimit,
+		Burst:    cfg.Server.WebSocket.Burst,
+	})
+	handler.SetBasePath(cfg.Server.BasePath)
+	handler.SetLegacyRoutes(cfg.Server.LegacyRoutes.LegacyRoutes())
+	signer, err := buildSigner(cfg.Server.Signing)
+	if err != nil {
+		log.Fatalf("Failed to configure response signing: %v", err)
+	}
+	handler.SetSigner(signer)
+	verifier, err := buildAdminVerifier(cfg.Server.AdminAuth)
+	if err != nil {
+		log.Fatalf("Failed to configure admin authentication: %v", err)
+	}
+	if verifier != nil {
+		handler.SetAdminAuth(verifier)
+		log.Printf("Admin routes require tokens issued by %s", cfg.Server.AdminAuth.Issuer)
+	}
+	if err := handler.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("Failed to configure trusted proxies: %v", err)
+	}
+	if err := handler.SetAPIKeys(cfg.Usage.APIKeys); err != nil {
+		log.Fatalf("Failed to configure usage metering: %v", err)
+	}
+	handler.SetCacheMaxAge(cfg.Server.CacheMaxAge)
+	handler.SetRecentLimits(cfg.Server.Recent.Limits())
+	handler.SetSecurity(cfg.Server.Security.Security())
+	handler.SetDebugEndpoints(api.DebugEndpoints{
+		Enabled:     cfg.Server.Debug.Enabled,
+		AllowRemote: cfg.Server.Debug.AllowRemote,
+	})
+	if cfg.Server.Debug.Enabled {
+		log.Printf("Serving /debug/pprof and /debug/vars (allow_remote=%t)", cfg.Server.Debug.AllowRemote)
+	}
+	if cfg.Metrics.Enabled {
+		m := metrics.New(alloc)
+		handler.SetMetrics(m)
+		handler.SetLatencyObserver(m)
+		if instrumented != nil {
+			instrumented.SetObserver(m)
+		}
+	}
+	handler.SetHealthChecker(healthChecker(cfg.Health, store, cache, os.Getenv("APP_ENV")))
+	handler.SetPreStop(api.PreStop{
+		DrainDelay:   cfg.Server.PreStop.DrainDelay,
+		DrainTimeout: cfg.Server.PreStop.DrainTimeout,
+		Flush:        flushWrites(alloc, fallback),
+	})
 
 	// Register the routes
 	handler.RegisterRoutes(router)
+	registerFaults(router.Group(cfg.Server.BasePath))
 
 	// Create a new HTTP server
-	server := &http.Server{
-		Addr:    fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
-		Handler: router,
+	server := &http.Server{Handler: router}
+
+	tlsConfig, err := buildTLSConfig(cfg.Server.TLS)
+	if err != nil {
+		log.Fatalf("Failed to configure TLS: %v", err)
+	}
+	if tlsConfig != nil {
+		server.TLSConfig = tlsConfig
+		if !cfg.Server.TLS.HTTP2 {
+			// A non-nil, empty map disables the automatic HTTP/2 upgrade.
+			server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		}
+		log.Printf("TLS enabled (http2=%t)", cfg.Server.TLS.HTTP2)
 	}
 
-	// Start the server in a goroutine
-	go func() {
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
+	// Serve on every configured address and socket; shutting the server
+	// down closes them all
+	listeners, err := listen(cfg.Server, tlsConfig != nil)
+	if err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+	serve(server, listeners)
+
+	// Optionally redirect plain HTTP traffic to the HTTPS listener
+	var redirectServer *http.Server
+	if tlsConfig != nil && cfg.Server.TLS.RedirectPort > 0 {
+		redirectServer = &http.Server{
+			Addr:    fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.TLS.RedirectPort),
+			Handler: httpsRedirectHandler(cfg.Server.Port),
 		}
-	}()
+		go func() {
+			if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Failed to start redirect server: %v", err)
+			}
+		}()
+	}
 
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	// SIGTERM, as sent by Kubernetes and docker stop, drains the instance
+	// first unless POST /admin/prestop already did
+	if sig := <-quit; sig == syscall.SIGTERM {
+		if _, err := handler.PreStop(context.Background()); err != nil {
+			log.Printf("Pre-stop failed: %v", err)
+		}
+	}
 
 	// Create a deadline for server shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -132,6 +533,11 @@ func main() {
 	if err := server.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
+	if redirectServer != nil {
+		if err := redirectServer.Shutdown(ctx); err != nil {
+			log.Printf("Redirect server forced to shutdown: %v", err)
+		}
+	}
 
 	log.Println("Server exiting")
 }
@@ -10,6 +10,9 @@ import (
 )
 
 func TestMain(t *testing.T) {
+	// Run against in-memory storage so the test never touches data/
+	t.Setenv("APP_ENV", "test")
+
 	// Create a new HTTP server
 	server := &http.Server{
 		Addr: ":8080",
@@ -38,6 +41,11 @@ func TestMain(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
 
+	// Test the readiness probes against the in-memory database
+	resp, err = http.Get("http://localhost:8080/health/ready")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
 	// Shutdown the server
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -0,0 +1,14 @@
+//go:build !faults
+
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+// installFaults is a no-op unless built with the "faults" tag.
+func installFaults(store storage.Storage) (storage.Storage, func(gin.IRoutes)) {
+	return store, func(gin.IRoutes) {}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/n-th/gymshark/internal/allocator"
+)
+
+// checkPackSizes logs the issues of the configured profiles, returning an
+// error if any has error severity.
+func checkPackSizes(alloc *allocator.Allocator) error {
+	var errs int
+	for _, issue := range alloc.ValidateProfiles() {
+		log.Printf("Pack sizes of profile %q: %s %s: %s", issue.Profile, issue.Severity, issue.Rule, issue.Message)
+		if issue.Severity == allocator.SeverityError {
+			errs++
+		}
+	}
+	if errs > 0 {
+		return fmt.Errorf("%w: %d issue(s) of error severity", allocator.ErrPackSizeRule, errs)
+	}
+	return nil
+}
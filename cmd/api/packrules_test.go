@@ -0,0 +1,21 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPackSizes(t *testing.T) {
+	alloc := allocator.NewAllocator([]int{23, 31, 53}, nil)
+	assert.NoError(t, checkPackSizes(alloc))
+
+	// Warnings are only logged
+	assert.NoError(t, alloc.SetProfiles(map[string][]int{"bulk": {1, 50}}, nil))
+	assert.NoError(t, checkPackSizes(alloc))
+
+	assert.NoError(t, alloc.SetPackSizeRules(config.PackSizeRulesConfig{UnitPack: "error"}.Rules()))
+	assert.ErrorIs(t, checkPackSizes(alloc), allocator.ErrPackSizeRule)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+// flushWrites returns the pre-stop flush: it writes the allocations queued
+// in the outbox and replays the writes the storage fallback buffered, if one
+// is configured, so that an instance being replaced leaves none behind.
+// While read-only, queued writes stay queued.
+func flushWrites(alloc *allocator.Allocator, fallback *storage.FallbackStorage) func() error {
+	return func() error {
+		var errs []error
+		n, err := alloc.FlushOutbox()
+		switch {
+		case errors.Is(err, allocator.ErrReadOnly):
+			log.Printf("Pre-stop: read-only, leaving %d queued allocation writes", alloc.OutboxStats().Pending)
+		case err != nil:
+			errs = append(errs, fmt.Errorf("flush outbox: %w", err))
+		case n > 0:
+			log.Printf("Pre-stop: wrote %d queued allocations", n)
+		}
+		if fallback != nil {
+			n, err := fallback.Replay()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("replay storage fallback: %w", err))
+			} else if n > 0 {
+				log.Printf("Pre-stop: replayed %d buffered writes", n)
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlushWrites(t *testing.T) {
+	alloc := allocator.NewAllocator([]int{23, 31, 53}, nil)
+	assert.NoError(t, flushWrites(alloc, nil)())
+
+	// Read-only instances keep their queued writes for the next one.
+	assert.NoError(t, alloc.SetReadOnly(allocator.ReadOnly{Enabled: true}))
+	assert.NoError(t, flushWrites(alloc, nil)())
+}
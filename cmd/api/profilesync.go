@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/config"
+)
+
+// reconcileProfiles makes the profiles declared in the file at path current,
+// logging every profile it changes.
+func reconcileProfiles(ctx context.Context, alloc *allocator.Allocator, path string) error {
+	declared, err := config.LoadProfiles(path)
+	if err != nil {
+		return err
+	}
+	changes, err := alloc.SyncProfiles(ctx, declared)
+	for _, c := range changes {
+		log.Printf("Profile %q synced from %s: version %d, added %v, removed %v", c.Profile, path, c.Version, c.Added, c.Removed)
+	}
+	return err
+}
+
+// syncProfiles reconciles the profiles with the profile_sync file every
+// interval until ctx is done, so edits to the file, and to the profiles by
+// other means, are settled in the file's favour. Failures are logged and
+// retried at the next check.
+func syncProfiles(ctx context.Context, alloc *allocator.Allocator, c config.ProfileSyncConfig) {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := reconcileProfiles(ctx, alloc, c.Path); err != nil {
+				log.Printf("Failed to sync pack size profiles from %s: %v", c.Path, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncProfiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiles.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("profiles:\n  apparel: [10, 20]\n"), 0600))
+	alloc := allocator.NewAllocator([]int{23, 31, 53}, nil)
+	sizes := func() []int {
+		for _, p := range alloc.Profiles() {
+			if p.Name == "apparel" {
+				return p.PackSizes
+			}
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, reconcileProfiles(ctx, alloc, path))
+	assert.Equal(t, []int{20, 10}, sizes())
+	go syncProfiles(ctx, alloc, config.ProfileSyncConfig{Path: path, Interval: time.Millisecond})
+
+	// Edits to the file are picked up, and changes made otherwise undone
+	assert.NoError(t, os.WriteFile(path, []byte("profiles:\n  apparel: [10, 20, 40]\n"), 0600))
+	assert.Eventually(t, func() bool { return len(sizes()) == 3 }, time.Second, time.Millisecond)
+	_, err := alloc.UpdateProfile(ctx, "apparel", []int{5})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return len(sizes()) == 3 }, time.Second, time.Millisecond)
+
+	// A broken file leaves the profiles as they are
+	assert.NoError(t, os.WriteFile(path, []byte("profiles:\n  apparel: [0]\n"), 0600))
+	assert.Error(t, reconcileProfiles(ctx, alloc, path))
+	assert.Equal(t, []int{40, 20, 10}, sizes())
+}
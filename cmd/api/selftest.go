@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/config"
+)
+
+// runSelfTest checks the configured cases and the built-in edge quantities
+// against alloc, logging every failure, and returns an error if any failed.
+func runSelfTest(ctx context.Context, alloc *allocator.Allocator, cfg config.SelfTestConfig) error {
+	cases := make([]allocator.SelfTestCase, len(cfg.Cases))
+	for i, c := range cfg.Cases {
+		cases[i] = allocator.SelfTestCase{Profile: c.Profile, Quantity: c.Quantity, Packs: c.Packs}
+	}
+	failures, err := alloc.SelfTest(ctx, cases)
+	if err != nil {
+		return err
+	}
+	for _, f := range failures {
+		log.Printf("Self-test: %v", f)
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d of the allocations checked with strategy %s are wrong", len(failures), alloc.Strategy())
+	}
+	if len(cases) == 0 {
+		log.Printf("Self-test passed; no self_test cases are configured, so only edge quantities were checked")
+		return nil
+	}
+	log.Printf("Self-test passed: %d cases and the edge quantities of every profile", len(cases))
+	return nil
+}
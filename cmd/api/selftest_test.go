@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/config"
+)
+
+func TestRunSelfTest(t *testing.T) {
+	alloc := allocator.NewAllocator([]int{23, 31, 53}, nil)
+	defer alloc.Close()
+	assert.NoError(t, alloc.SetStrategy("combination"))
+
+	cfg := config.SelfTestConfig{Cases: []config.SelfTestCaseConfig{
+		{Quantity: 1, Packs: map[int]int{23: 1}},
+		{Quantity: 263, Packs: map[int]int{23: 2, 31: 7}},
+	}}
+	assert.NoError(t, runSelfTest(context.Background(), alloc, cfg))
+
+	cfg.Cases = append(cfg.Cases, config.SelfTestCaseConfig{Quantity: 24, Packs: map[int]int{23: 2}})
+	assert.ErrorContains(t, runSelfTest(context.Background(), alloc, cfg), "1 of the allocations")
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/n-th/gymshark/internal/api"
+	"github.com/n-th/gymshark/internal/config"
+)
+
+// buildSigner loads the signing key. It returns nil when signing is not
+// enabled.
+func buildSigner(c config.SigningConfig) (*api.Signer, error) {
+	if !c.Enabled() {
+		return nil, nil
+	}
+	if c.KeyFile == "" {
+		return nil, errors.New("server.signing.key_file is required")
+	}
+	key, err := os.ReadFile(c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.Algorithm {
+	case api.AlgorithmHMACSHA256:
+		return api.NewHMACSigner(c.KeyID, bytes.TrimSpace(key))
+	case api.AlgorithmEd25519:
+		block, _ := pem.Decode(key)
+		if block == nil || block.Type != "PRIVATE KEY" {
+			return nil, fmt.Errorf("%s: want a PEM-encoded PKCS #8 private key", c.KeyFile)
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", c.KeyFile, err)
+		}
+		private, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%s: not an ed25519 private key", c.KeyFile)
+		}
+		return api.NewEd25519Signer(c.KeyID, private)
+	default:
+		return nil, fmt.Errorf("invalid signing algorithm %q: want %s or %s", c.Algorithm, api.AlgorithmHMACSHA256, api.AlgorithmEd25519)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/n-th/gymshark/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildSigner(t *testing.T) {
+	signer, err := buildSigner(config.SigningConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, signer)
+
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "secret")
+	assert.NoError(t, os.WriteFile(secret, []byte("0123456789abcdef0123456789abcdef\n"), 0600))
+	signer, err = buildSigner(config.SigningConfig{Algorithm: "hmac-sha256", KeyID: "k1", KeyFile: secret})
+	assert.NoError(t, err)
+	assert.NotNil(t, signer)
+
+	_, private, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	assert.NoError(t, err)
+	key := filepath.Join(dir, "key.pem")
+	assert.NoError(t, os.WriteFile(key, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+	signer, err = buildSigner(config.SigningConfig{Algorithm: "ed25519", KeyFile: key})
+	assert.NoError(t, err)
+	assert.NotNil(t, signer)
+
+	short := filepath.Join(dir, "short")
+	assert.NoError(t, os.WriteFile(short, []byte("too short"), 0600))
+	for _, c := range []config.SigningConfig{
+		{Algorithm: "hmac-sha256"},
+		{Algorithm: "hmac-sha256", KeyFile: filepath.Join(dir, "missing")},
+		{Algorithm: "hmac-sha256", KeyFile: short},
+		{Algorithm: "ed25519", KeyFile: secret},
+		{Algorithm: "rsa", KeyFile: key},
+	} {
+		_, err := buildSigner(c)
+		assert.Error(t, err, c)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/n-th/gymshark/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildTLSConfig(t *testing.T) {
+	cfg, err := buildTLSConfig(config.TLSConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, cfg)
+
+	_, err = buildTLSConfig(config.TLSConfig{CertFile: "cert.pem"})
+	assert.Error(t, err)
+
+	cfg, err = buildTLSConfig(config.TLSConfig{SelfSigned: true, HTTP2: true})
+	assert.NoError(t, err)
+	assert.Len(t, cfg.Certificates, 1)
+	assert.Equal(t, []string{"h2", "http/1.1"}, cfg.NextProtos)
+
+	leaf, err := x509.ParseCertificate(cfg.Certificates[0].Certificate[0])
+	assert.NoError(t, err)
+	assert.NoError(t, leaf.VerifyHostname("localhost"))
+}
+
+func TestHTTPSRedirectHandler(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://example.com:8081/calculate?quantity=5", nil)
+	w := httptest.NewRecorder()
+	httpsRedirectHandler(8443).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "https://example.com:8443/calculate?quantity=5", w.Header().Get("Location"))
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/n-th/gymshark/internal/allocator"
+)
+
+// runAllocate allocates every quantity of a CSV file and writes the rows
+// back with their packs, total, waste and pack count appended. The
+// quantities of each profile are answered from one table by parallel
+// workers (see allocator.AllocateBatch), so files of hundreds of thousands
+// of rows are practical. Every row is checked before anything is allocated,
+// and nothing is stored; no database is read, so pins are not applied.
+func runAllocate(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("allocate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: gymshark allocate [flags] <quantities.csv>")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "The CSV file has a header row with a quantity column, and optionally a profile")
+		fmt.Fprintln(stderr, "column; other columns are copied to the output as they are.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("config", "config/config.yaml", "config file with the pack sizes and profiles")
+	profile := fs.String("profile", allocator.DefaultProfile, "profile of the rows without one")
+	workers := fs.Int("workers", 0, "goroutines allocating each profile's rows (default the number of CPUs)")
+	outPath := fs.String("o", "", "write the allocations to this CSV file instead of standard output")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitError
+	}
+
+	fail := func(format string, a ...interface{}) int {
+		fmt.Fprintf(stderr, "gymshark allocate: "+format+"\n", a...)
+		return exitError
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitError
+	}
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return fail("load config: %v", err)
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fail("%v", err)
+	}
+	rows, err := readQuantities(f, *profile)
+	f.Close()
+	if err != nil {
+		return fail("%s: %v", fs.Arg(0), err)
+	}
+
+	alloc := allocator.NewAllocator(cfg.PackSizes, nil)
+	defer alloc.Close()
+	if err := alloc.SetProfiles(cfg.Profiles, nil); err != nil {
+		return fail("configure pack size profiles: %v", err)
+	}
+	if err := alloc.SetPackLimits(cfg.PackLimits); err != nil {
+		return fail("configure pack limits: %v", err)
+	}
+	if err := alloc.SetDeprecatedSizes(cfg.DeprecatedSizes); err != nil {
+		return fail("configure deprecated sizes: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	results := make([]allocator.Result, len(rows.quantities))
+	for _, p := range rows.profiles {
+		indexes := rows.byProfile[p]
+		quantities := make([]int, len(indexes))
+		for i, row := range indexes {
+			quantities[i] = rows.quantities[row]
+		}
+		batch, err := alloc.AllocateBatch(ctx, allocator.BatchRequest{Quantities: quantities, Profile: p, Workers: *workers})
+		if err != nil {
+			return fail("profile %s: %v", p, err)
+		}
+		for i, row := range indexes {
+			results[row] = batch[i]
+		}
+	}
+
+	out := stdout
+	var file *os.File
+	if *outPath != "" {
+		if file, err = os.Create(*outPath); err != nil {
+			return fail("%v", err)
+		}
+		out = file
+	}
+	err = writeAllocations(out, rows, results)
+	if file != nil {
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		return fail("write allocations: %v", err)
+	}
+	if file != nil {
+		fmt.Fprintf(stdout, "allocated %d quantities into %s\n", len(results), *outPath)
+	}
+	return exitOK
+}
+
+// quantityRows are the rows of a quantities CSV file.
+type quantityRows struct {
+	header  []string
+	records [][]string
+	// quantities[i] is the quantity of records[i]; byProfile lists the
+	// indexes of each profile's records, and profiles the profiles in the
+	// order they first appear.
+	quantities []int
+	byProfile  map[string][]int
+	profiles   []string
+}
+
+// readQuantities parses a CSV file with a header row and a quantity column,
+// defaulting the profile of its rows to profile.
+func readQuantities(r io.Reader, profile string) (*quantityRows, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("empty file")
+	}
+	if err != nil {
+		return nil, err
+	}
+	quantityColumn, profileColumn := -1, -1
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "quantity":
+			quantityColumn = i
+		case "profile":
+			profileColumn = i
+		case "packs", "total", "waste", "pack_count":
+			return nil, fmt.Errorf("column %q clashes with the output", name)
+		}
+	}
+	if quantityColumn < 0 {
+		return nil, errors.New("no quantity column")
+	}
+
+	rows := &quantityRows{header: header, byProfile: make(map[string][]int)}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		value := strings.TrimSpace(record[quantityColumn])
+		quantity, err := strconv.Atoi(value)
+		if err != nil || quantity <= 0 {
+			return nil, fmt.Errorf("line %d: invalid quantity %q", line, value)
+		}
+		p := profile
+		if profileColumn >= 0 {
+			if v := strings.TrimSpace(record[profileColumn]); v != "" {
+				p = v
+			}
+		}
+		if _, ok := rows.byProfile[p]; !ok {
+			rows.profiles = append(rows.profiles, p)
+		}
+		rows.byProfile[p] = append(rows.byProfile[p], len(rows.records))
+		rows.records = append(rows.records, record)
+		rows.quantities = append(rows.quantities, quantity)
+	}
+}
+
+// writeAllocations writes the rows with their results appended, packs as
+// JSON objects keyed by pack size.
+func writeAllocations(out io.Writer, rows *quantityRows, results []allocator.Result) error {
+	w := csv.NewWriter(out)
+	w.Write(append(rows.header, "packs", "total", "waste", "pack_count"))
+	for i, record := range rows.records {
+		r := results[i]
+		packs, err := json.Marshal(r.Packs)
+		if err != nil {
+			return err
+		}
+		count := 0
+		for _, n := range r.Packs {
+			count += n
+		}
+		w.Write(append(record,
+			string(packs), strconv.Itoa(r.Total), strconv.Itoa(r.Total-rows.quantities[i]), strconv.Itoa(count)))
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+// importBatchSize is the number of orders stored per transaction.
+const importBatchSize = 1000
+
+// runImportOrders loads historical orders from a CSV file into the
+// database, as allocations with algorithm storage.AlgorithmImport and no
+// packs, so that the top quantities, the cache warmers and what-if
+// simulations see realistic demand before the API has served any. Every
+// row is checked before anything is stored.
+func runImportOrders(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("import-orders", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: gymshark import-orders [flags] <orders.csv>")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "The CSV file has a header row naming its columns: quantity, and optionally")
+		fmt.Fprintln(stderr, "order_id, customer_id, profile and created_at (RFC 3339 time or date).")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+	}
+	backend := fs.String("backend", storage.BackendSQLite, "storage backend of the database: sqlite or bolt")
+	dbPath := fs.String("db", "", "database of the API, created if missing (default data/allocations.db, or data/allocations.bolt with -backend bolt)")
+	profile := fs.String("profile", allocator.DefaultProfile, "profile of the rows without one")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitError
+	}
+
+	fail := func(format string, a ...interface{}) int {
+		fmt.Fprintf(stderr, "gymshark import-orders: "+format+"\n", a...)
+		return exitError
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitError
+	}
+	if err := storage.CheckBackend(*backend); err != nil {
+		return fail("%v", err)
+	}
+	if *dbPath == "" {
+		*dbPath = filepath.Join("data", storage.DatabaseFile(*backend))
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fail("%v", err)
+	}
+	orders, err := readOrders(f, *profile)
+	f.Close()
+	if err != nil {
+		return fail("%s: %v", fs.Arg(0), err)
+	}
+
+	store, err := storage.Open(*backend, *dbPath, storage.WriteAppend)
+	if err != nil {
+		return fail("open database: %v", err)
+	}
+	defer store.Close()
+	for start := 0; start < len(orders); start += importBatchSize {
+		batch := orders[start:min(start+importBatchSize, len(orders))]
+		if err := store.StoreAllocations(batch); err != nil {
+			return fail("store orders %d-%d: %v (the first %d were imported)", start+1, start+len(batch), err, start)
+		}
+	}
+	fmt.Fprintf(stdout, "imported %d orders into %s\n", len(orders), *dbPath)
+	return exitOK
+}
+
+// readOrders parses the orders of a CSV file with a header row, defaulting
+// their profile to profile and their creation time to now.
+func readOrders(r io.Reader, profile string) ([]storage.AllocationInput, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("empty file")
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "quantity", "order_id", "customer_id", "profile", "created_at":
+		default:
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["quantity"]; !ok {
+		return nil, errors.New("no quantity column")
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var orders []storage.AllocationInput
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return orders, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		quantity, err := strconv.Atoi(field(record, "quantity"))
+		if err != nil || quantity <= 0 {
+			return nil, fmt.Errorf("line %d: invalid quantity %q", line, field(record, "quantity"))
+		}
+		createdAt, err := parseTime(field(record, "created_at"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: created_at: %v", line, err)
+		}
+		in := storage.AllocationInput{
+			Quantity:   quantity,
+			Packs:      map[int]int{},
+			OrderID:    field(record, "order_id"),
+			CustomerID: field(record, "customer_id"),
+			Profile:    field(record, "profile"),
+			Algorithm:  storage.AlgorithmImport,
+			CreatedAt:  createdAt,
+		}
+		if in.Profile == "" {
+			in.Profile = profile
+		}
+		orders = append(orders, in)
+	}
+}
@@ -0,0 +1,64 @@
+// Command gymshark runs maintenance tasks against the API's database.
+//
+// Usage:
+//
+//	gymshark verify [flags]            recompute stored allocations and report those that were not optimal
+//	gymshark restore [flags] <file>    replace the database with a backup from POST /admin/backup
+//	gymshark import-orders [flags] <file>
+//	                                   load historical order quantities from a CSV file, without computing packs
+//	gymshark allocate [flags] <file>   allocate every quantity of a CSV file from one shared table
+//
+// Run "gymshark <command> -h" for the flags of a command.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Exit statuses, as with diff: 1 means the command found problems, 2 that
+// it could not run.
+const (
+	exitOK       = 0
+	exitProblems = 1
+	exitError    = 2
+)
+
+// commands maps subcommand names to their implementations. Each parses its
+// own flags from args and returns an exit status.
+var commands = map[string]func(args []string, stdout, stderr io.Writer) int{
+	"verify":        runVerify,
+	"restore":       runRestore,
+	"import-orders": runImportOrders,
+	"allocate":      runAllocate,
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: gymshark <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	fmt.Fprintln(w, "  verify          recompute stored allocations and report those that were not optimal")
+	fmt.Fprintln(w, "  restore         replace the database with a backup from POST /admin/backup")
+	fmt.Fprintln(w, "  import-orders   load historical order quantities from a CSV file, without computing packs")
+	fmt.Fprintln(w, "  allocate        allocate every quantity of a CSV file from one shared table")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage(os.Stderr)
+		os.Exit(exitError)
+	}
+	switch name := os.Args[1]; name {
+	case "-h", "-help", "--help", "help":
+		usage(os.Stdout)
+	default:
+		cmd, ok := commands[name]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "gymshark: unknown command %q\n\n", name)
+			usage(os.Stderr)
+			os.Exit(exitError)
+		}
+		os.Exit(cmd(os.Args[2:], os.Stdout, os.Stderr))
+	}
+}
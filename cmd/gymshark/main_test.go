@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/n-th/gymshark/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+// writeHistory creates a database holding allocations computed with
+// {23, 31, 53} as version 1 of the default profile.
+func writeHistory(t *testing.T, allocations ...storage.AllocationInput) string {
+	path := filepath.Join(t.TempDir(), "allocations.db")
+	store, err := storage.NewSQLiteStorage(path)
+	assert.NoError(t, err)
+	_, err = store.RecordProfileVersion("default", []int{23, 31, 53})
+	assert.NoError(t, err)
+	for _, in := range allocations {
+		assert.NoError(t, store.StoreAllocationInput(in))
+	}
+	assert.NoError(t, store.Close())
+	return path
+}
+
+func TestVerify(t *testing.T) {
+	config := writeConfig(t, "pack_sizes: [250, 500, 1000]\n")
+	db := writeHistory(t,
+		storage.AllocationInput{Quantity: 50, Packs: map[int]int{53: 1}, Total: 53, Profile: "default", ProfileVersion: 1},
+		storage.AllocationInput{Quantity: 100, Packs: map[int]int{53: 2}, Total: 106, Profile: "default", ProfileVersion: 1},
+		storage.AllocationInput{Quantity: 501, Packs: map[int]int{500: 1, 250: 1}, Total: 750},
+	)
+	report := filepath.Join(t.TempDir(), "report.csv")
+
+	var stdout, stderr bytes.Buffer
+	code := runVerify([]string{"-config", config, "-db", db, "-report", report}, &stdout, &stderr)
+	assert.Equal(t, exitProblems, code, stderr.String())
+	assert.Contains(t, stdout.String(), "quantity 100 (default v1, ")
+	assert.Contains(t, stdout.String(), "stored waste 6 in 2 packs, optimal waste 0 in 4 packs")
+	assert.Contains(t, stdout.String(), "3 allocations checked with dp, 0 skipped: 1 not optimal, 6 excess waste")
+
+	f, err := os.Open(report)
+	assert.NoError(t, err)
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 2) {
+		assert.Equal(t, "quantity", rows[0][2])
+		assert.Equal(t, []string{"100", "default", "1", `{"53":2}`, "106", "6", "2", `{"23":3,"31":1}`, "100", "0", "4"}, rows[1][2:])
+	}
+
+	// Nothing left to report before the wasteful allocation was stored
+	stdout.Reset()
+	code = runVerify([]string{"-config", config, "-db", db, "-to", "2000-01-01"}, &stdout, &stderr)
+	assert.Equal(t, exitOK, code, stderr.String())
+	assert.Contains(t, stdout.String(), "0 allocations checked")
+}
+
+func TestVerifyBolt(t *testing.T) {
+	config := writeConfig(t, "pack_sizes: [23, 31, 53]\n")
+	db := filepath.Join(t.TempDir(), "allocations.bolt")
+	store, err := storage.NewBoltStorage(db)
+	assert.NoError(t, err)
+	assert.NoError(t, store.StoreAllocation(100, map[int]int{53: 2}, 106))
+	assert.NoError(t, store.Close())
+
+	var stdout, stderr bytes.Buffer
+	code := runVerify([]string{"-config", config, "-backend", "bolt", "-db", db}, &stdout, &stderr)
+	assert.Equal(t, exitProblems, code, stderr.String())
+	assert.Contains(t, stdout.String(), "1 allocations checked with dp, 0 skipped: 1 not optimal, 6 excess waste")
+}
+
+func TestVerifyErrors(t *testing.T) {
+	config := writeConfig(t, "pack_sizes: [250, 500, 1000]\n")
+	db := writeHistory(t)
+	missing := filepath.Join(t.TempDir(), "missing.db")
+
+	for name, args := range map[string][]string{
+		"missing database": {"-config", config, "-db", missing},
+		"missing config":   {"-config", filepath.Join(t.TempDir(), "missing.yaml"), "-db", db},
+		"empty config":     {"-config", writeConfig(t, "profiles: {}\n"), "-db", db},
+		"invalid from":     {"-config", config, "-db", db, "-from", "yesterday"},
+		"unknown flag":     {"-strategy", "greedy"},
+		"unknown backend":  {"-config", config, "-backend", "postgres", "-db", db},
+	} {
+		var stdout, stderr bytes.Buffer
+		assert.Equal(t, exitError, runVerify(args, &stdout, &stderr), name)
+		assert.NotEmpty(t, stderr.String(), name)
+	}
+
+	// A missing database is not created
+	_, err := os.Stat(missing)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRestore(t *testing.T) {
+	dir := t.TempDir()
+	source := writeHistory(t, storage.AllocationInput{Quantity: 100, Packs: map[int]int{53: 2}, Total: 106})
+	store, err := storage.NewSQLiteStorage(source)
+	assert.NoError(t, err)
+	var backup bytes.Buffer
+	assert.NoError(t, store.Backup(&backup))
+	assert.NoError(t, store.Close())
+	file := filepath.Join(dir, "gymshark.backup")
+	assert.NoError(t, os.WriteFile(file, backup.Bytes(), 0o644))
+
+	// A new database is restored as is
+	db := filepath.Join(dir, "allocations.db")
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, exitOK, runRestore([]string{"-db", db, file}, &stdout, &stderr), stderr.String())
+	assert.Equal(t, "restored "+db+" from "+file+"\n", stdout.String())
+
+	// An existing one only with -force
+	stderr.Reset()
+	assert.Equal(t, exitError, runRestore([]string{"-db", db, file}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "pass -force to replace it")
+	assert.Equal(t, exitOK, runRestore([]string{"-db", db, "-force", file}, &stdout, &stderr), stderr.String())
+
+	store, err = storage.NewSQLiteStorage(db)
+	assert.NoError(t, err)
+	a, err := store.GetAllocationByQuantity(100)
+	assert.NoError(t, err)
+	assert.NotNil(t, a)
+	assert.NoError(t, store.Close())
+}
+
+func TestRestoreErrors(t *testing.T) {
+	dir := t.TempDir()
+	garbage := filepath.Join(dir, "garbage.backup")
+	assert.NoError(t, os.WriteFile(garbage, bytes.Repeat([]byte("not a database "), 512), 0o644))
+	db := filepath.Join(dir, "allocations.db")
+
+	for name, args := range map[string][]string{
+		"no backup":       {"-db", db},
+		"two backups":     {"-db", db, garbage, garbage},
+		"missing backup":  {"-db", db, filepath.Join(dir, "missing.backup")},
+		"invalid backup":  {"-db", db, garbage},
+		"unknown backend": {"-backend", "postgres", "-db", db, garbage},
+	} {
+		var stdout, stderr bytes.Buffer
+		assert.Equal(t, exitError, runRestore(args, &stdout, &stderr), name)
+		assert.NotEmpty(t, stderr.String(), name)
+	}
+
+	// A failed restore creates nothing
+	_, err := os.Stat(db)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestImportOrders(t *testing.T) {
+	dir := t.TempDir()
+	orders := filepath.Join(dir, "orders.csv")
+	assert.NoError(t, os.WriteFile(orders, []byte("order_id,quantity,profile,created_at\n"+
+		"ORD-1,250,,2024-01-02\n"+
+		"ORD-2, 250,,2024-01-03T10:00:00Z\n"+
+		"ORD-3,12001,bulk,2024-01-04\n"+
+		"ORD-4,501,,\n"), 0o644))
+	db := filepath.Join(dir, "allocations.db")
+
+	var stdout, stderr bytes.Buffer
+	code := runImportOrders([]string{"-db", db, orders}, &stdout, &stderr)
+	assert.Equal(t, exitOK, code, stderr.String())
+	assert.Contains(t, stdout.String(), "imported 4 orders")
+
+	store, err := storage.NewSQLiteStorage(db)
+	assert.NoError(t, err)
+	defer store.Close()
+	top, err := store.TopQuantities(time.Time{}, 10)
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, top) {
+		assert.Equal(t, storage.QuantityCount{Profile: "default", Quantity: 250, Requests: 2}, top[0])
+	}
+	found, err := store.GetAllocationsByOrderID("ORD-3")
+	assert.NoError(t, err)
+	if assert.Len(t, found, 1) {
+		assert.Equal(t, "bulk", found[0].Profile)
+		assert.Equal(t, storage.AlgorithmImport, found[0].Algorithm)
+		assert.Empty(t, found[0].Packs)
+		assert.Equal(t, time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC), found[0].CreatedAt.UTC())
+	}
+
+	// Imported orders are neither served from the cache nor counted as waste
+	cached, err := store.GetCachedAllocation("default", 250)
+	assert.NoError(t, err)
+	assert.Nil(t, cached)
+	waste, err := store.AggregateWaste(storage.GroupByProfile, time.Time{}, time.Time{})
+	assert.NoError(t, err)
+	assert.Empty(t, waste)
+}
+
+func TestImportOrdersErrors(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+	db := filepath.Join(dir, "allocations.db")
+
+	for name, args := range map[string][]string{
+		"no file":          {"-db", db},
+		"missing file":     {"-db", db, filepath.Join(dir, "missing.csv")},
+		"empty file":       {"-db", db, write("empty.csv", "")},
+		"no quantity":      {"-db", db, write("noquantity.csv", "order_id\nORD-1\n")},
+		"unknown column":   {"-db", db, write("unknown.csv", "quantity,packs\n250,1\n")},
+		"invalid quantity": {"-db", db, write("invalid.csv", "quantity\n250\n-1\n")},
+		"invalid time":     {"-db", db, write("time.csv", "quantity,created_at\n250,yesterday\n")},
+		"unknown backend":  {"-backend", "postgres", "-db", db, write("ok.csv", "quantity\n250\n")},
+	} {
+		var stdout, stderr bytes.Buffer
+		assert.Equal(t, exitError, runImportOrders(args, &stdout, &stderr), name)
+		assert.NotEmpty(t, stderr.String(), name)
+	}
+
+	var stdout, stderr bytes.Buffer
+	runImportOrders([]string{"-db", db, filepath.Join(dir, "invalid.csv")}, &stdout, &stderr)
+	assert.Contains(t, stderr.String(), `line 3: invalid quantity "-1"`)
+
+	// A failed import stores nothing
+	_, err := os.Stat(db)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestAllocate(t *testing.T) {
+	config := writeConfig(t, "pack_sizes: [250, 500, 1000]\nprofiles:\n  odd: [23, 31, 53]\n")
+	dir := t.TempDir()
+	quantities := filepath.Join(dir, "quantities.csv")
+	assert.NoError(t, os.WriteFile(quantities, []byte("order_id,quantity,profile\n"+
+		"ORD-1,251,\n"+
+		"ORD-2, 100,odd\n"+
+		"ORD-3,1000,\n"), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	code := runAllocate([]string{"-config", config, quantities}, &stdout, &stderr)
+	assert.Equal(t, exitOK, code, stderr.String())
+	rows, err := csv.NewReader(&stdout).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"order_id", "quantity", "profile", "packs", "total", "waste", "pack_count"},
+		{"ORD-1", "251", "", `{"500":1}`, "500", "249", "1"},
+		{"ORD-2", "100", "odd", `{"23":3,"31":1}`, "100", "0", "4"},
+		{"ORD-3", "1000", "", `{"1000":1}`, "1000", "0", "1"},
+	}, rows)
+
+	out := filepath.Join(dir, "allocations.csv")
+	stdout.Reset()
+	code = runAllocate([]string{"-config", config, "-profile", "odd", "-workers", "2", "-o", out, quantities}, &stdout, &stderr)
+	assert.Equal(t, exitOK, code, stderr.String())
+	assert.Contains(t, stdout.String(), "allocated 3 quantities")
+	f, err := os.Open(out)
+	assert.NoError(t, err)
+	defer f.Close()
+	rows, err = csv.NewReader(f).ReadAll()
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 4) {
+		// Rows without a profile take -profile.
+		assert.Equal(t, []string{"ORD-1", "251", "", `{"23":4,"53":3}`, "251", "0", "7"}, rows[1])
+	}
+}
+
+func TestAllocateErrors(t *testing.T) {
+	config := writeConfig(t, "pack_sizes: [250, 500, 1000]\npack_limits:\n  default:\n    1000: 1\n")
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+
+	for name, args := range map[string][]string{
+		"no file":          {"-config", config},
+		"missing config":   {"-config", filepath.Join(dir, "missing.yaml"), write("ok.csv", "quantity\n250\n")},
+		"missing file":     {"-config", config, filepath.Join(dir, "missing.csv")},
+		"empty file":       {"-config", config, write("empty.csv", "")},
+		"no quantity":      {"-config", config, write("noquantity.csv", "order_id\nORD-1\n")},
+		"output column":    {"-config", config, write("packs.csv", "quantity,packs\n250,1\n")},
+		"invalid quantity": {"-config", config, write("invalid.csv", "quantity\n250\n-1\n")},
+		"unknown profile":  {"-config", config, write("profile.csv", "quantity,profile\n250,apparel\n")},
+		"pack limits":      {"-config", config, write("limits.csv", "quantity\n2500\n")},
+	} {
+		var stdout, stderr bytes.Buffer
+		assert.Equal(t, exitError, runAllocate(args, &stdout, &stderr), name)
+		assert.NotEmpty(t, stderr.String(), name)
+		assert.Empty(t, stdout.String(), name)
+	}
+
+	var stdout, stderr bytes.Buffer
+	runAllocate([]string{"-config", config, filepath.Join(dir, "invalid.csv")}, &stdout, &stderr)
+	assert.Contains(t, stderr.String(), `line 3: invalid quantity "-1"`)
+}
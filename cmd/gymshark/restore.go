@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+// runRestore replaces the API's database with a backup taken with
+// POST /admin/backup. The API must be stopped first.
+func runRestore(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: gymshark restore [flags] <backup file>")
+		fs.PrintDefaults()
+	}
+	backend := fs.String("backend", storage.BackendSQLite, "storage backend of the backup: sqlite or bolt")
+	dbPath := fs.String("db", "", "database to replace (default data/allocations.db, or data/allocations.bolt with -backend bolt)")
+	force := fs.Bool("force", false, "replace an existing database")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitError
+	}
+
+	fail := func(format string, a ...interface{}) int {
+		fmt.Fprintf(stderr, "gymshark restore: "+format+"\n", a...)
+		return exitError
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitError
+	}
+	if err := storage.CheckBackend(*backend); err != nil {
+		return fail("%v", err)
+	}
+	if *dbPath == "" {
+		*dbPath = filepath.Join("data", storage.DatabaseFile(*backend))
+	}
+	if _, err := os.Stat(*dbPath); err == nil && !*force {
+		return fail("%s exists; stop the API and pass -force to replace it", *dbPath)
+	}
+	if err := storage.RestoreBackup(*backend, fs.Arg(0), *dbPath); err != nil {
+		return fail("%v", err)
+	}
+	fmt.Fprintf(stdout, "restored %s from %s\n", *dbPath, fs.Arg(0))
+	return exitOK
+}
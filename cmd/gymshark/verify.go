@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+// Config is the part of config/config.yaml the commands use: the current
+// pack sizes, against which verify checks allocations stored before profile
+// versions were recorded, and the limits and deprecated sizes allocate
+// honours.
+type Config struct {
+	PackSizes       []int                  `yaml:"pack_sizes"`
+	Profiles        map[string][]int       `yaml:"profiles"`
+	PackLimits      map[string]map[int]int `yaml:"pack_limits"`
+	DeprecatedSizes map[string][]int       `yaml:"deprecated_sizes"`
+}
+
+// loadConfig reads the pack sizes from path.
+func loadConfig(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var cfg Config
+	if err := yaml.NewDecoder(f).Decode(&cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.PackSizes) == 0 {
+		return nil, errors.New("no pack sizes configured")
+	}
+	return &cfg, nil
+}
+
+// parseTime accepts RFC 3339 timestamps or plain dates; empty means
+// unbounded.
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: expected RFC 3339 timestamp or YYYY-MM-DD date", value)
+	}
+	return t, nil
+}
+
+// runVerify recomputes the stored allocations with the exact solver and
+// lists those that were not optimal, optionally writing them to a CSV
+// report. It exits with exitProblems if there are any.
+func runVerify(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configPath := fs.String("config", "config/config.yaml", "config file with the current pack sizes")
+	backend := fs.String("backend", storage.BackendSQLite, "storage backend of the database: sqlite or bolt")
+	dbPath := fs.String("db", "", "database of the API (default data/allocations.db, or data/allocations.bolt with -backend bolt)")
+	fromFlag := fs.String("from", "", "only allocations created at or after this RFC 3339 time or date")
+	toFlag := fs.String("to", "", "only allocations created before this RFC 3339 time or date")
+	reportPath := fs.String("report", "", "write the mismatches to this CSV file")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitError
+	}
+
+	fail := func(format string, a ...interface{}) int {
+		fmt.Fprintf(stderr, "gymshark verify: "+format+"\n", a...)
+		return exitError
+	}
+	from, err := parseTime(*fromFlag)
+	if err != nil {
+		return fail("from: %v", err)
+	}
+	to, err := parseTime(*toFlag)
+	if err != nil {
+		return fail("to: %v", err)
+	}
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return fail("load config: %v", err)
+	}
+	if err := storage.CheckBackend(*backend); err != nil {
+		return fail("%v", err)
+	}
+	if *dbPath == "" {
+		*dbPath = filepath.Join("data", storage.DatabaseFile(*backend))
+	}
+	// Opening a missing path would create an empty database.
+	if _, err := os.Stat(*dbPath); err != nil {
+		return fail("open database: %v", err)
+	}
+	store, err := storage.Open(*backend, *dbPath, storage.WriteAppend)
+	if err != nil {
+		return fail("open database: %v", err)
+	}
+	defer store.Close()
+
+	alloc := allocator.NewAllocator(cfg.PackSizes, store)
+	defer alloc.Close()
+	if err := alloc.SetProfiles(cfg.Profiles, nil); err != nil {
+		return fail("configure pack size profiles: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	v, err := alloc.VerifyHistory(ctx, from, to)
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	for _, m := range v.Mismatches {
+		fmt.Fprintf(stdout, "allocation %d: quantity %d (%s v%d, %s): stored waste %d in %d packs, optimal waste %d in %d packs\n",
+			m.ID, m.Quantity, m.Profile, m.ProfileVersion, m.CreatedAt.UTC().Format(time.RFC3339),
+			m.Stored.Waste, m.Stored.PackCount, m.Optimal.Waste, m.Optimal.PackCount)
+	}
+	fmt.Fprintf(stdout, "%d allocations checked with %s, %d skipped: %d not optimal, %d excess waste\n",
+		v.Checked, allocator.ExactStrategy, v.Skipped, len(v.Mismatches), v.ExcessWaste)
+
+	if *reportPath != "" {
+		if err := writeReport(*reportPath, v.Mismatches); err != nil {
+			return fail("write report: %v", err)
+		}
+	}
+	if len(v.Mismatches) > 0 {
+		return exitProblems
+	}
+	return exitOK
+}
+
+// writeReport writes the mismatches to a CSV file at path, packs as JSON
+// objects keyed by pack size.
+func writeReport(path string, mismatches []allocator.Mismatch) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{
+		"id", "created_at", "quantity", "profile", "profile_version",
+		"stored_packs", "stored_total", "stored_waste", "stored_pack_count",
+		"optimal_packs", "optimal_total", "optimal_waste", "optimal_pack_count",
+	})
+	for _, m := range mismatches {
+		stored, err := json.Marshal(m.Stored.Packs)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		optimal, err := json.Marshal(m.Optimal.Packs)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		w.Write([]string{
+			strconv.FormatInt(m.ID, 10), m.CreatedAt.UTC().Format(time.RFC3339), strconv.Itoa(m.Quantity), m.Profile, strconv.Itoa(m.ProfileVersion),
+			string(stored), strconv.Itoa(m.Stored.Total), strconv.Itoa(m.Stored.Waste), strconv.Itoa(m.Stored.PackCount),
+			string(optimal), strconv.Itoa(m.Optimal.Total), strconv.Itoa(m.Optimal.Waste), strconv.Itoa(m.Optimal.PackCount),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
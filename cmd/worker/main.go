@@ -0,0 +1,217 @@
+// Command worker consumes order-created events and pre-computes their
+// allocations into the API's database, so that the API, with
+// calculation.result_cache enabled, serves them without computing them.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/events"
+	"github.com/n-th/gymshark/internal/storage"
+	"github.com/n-th/gymshark/internal/worker"
+)
+
+// outboxRetryInterval is how often allocation writes the database failed
+// are retried.
+const outboxRetryInterval = 5 * time.Second
+
+// Config is the part of config/config.yaml the worker uses. The pack sizes,
+// profiles and strategy must match the API's for its result cache to hit.
+type Config struct {
+	PackSizes   []int                  `yaml:"pack_sizes"`
+	Strategy    string                 `yaml:"strategy"`
+	Profiles    map[string][]int       `yaml:"profiles"`
+	SKUProfiles map[string]string      `yaml:"sku_profiles"`
+	PackLimits  map[string]map[int]int `yaml:"pack_limits"`
+	// DeprecatedSizes must match the API's for warmed allocations to avoid
+	// the same sizes.
+	DeprecatedSizes map[string][]int  `yaml:"deprecated_sizes"`
+	Calculation     CalculationConfig `yaml:"calculation"`
+	Storage         StorageConfig     `yaml:"storage"`
+	Worker          WorkerConfig      `yaml:"worker"`
+}
+
+// StorageConfig is the part of the API's storage settings the worker uses:
+// the backend whose database it writes to and, with a Redis cache
+// configured, the cache warmed allocations must be cached in too. A bbolt
+// database is locked by the API while it runs, so the worker needs the
+// SQLite backend to run alongside it.
+type StorageConfig struct {
+	Backend string      `yaml:"backend"`
+	Cache   CacheConfig `yaml:"cache"`
+}
+
+type CacheConfig struct {
+	RedisURL string        `yaml:"redis_url"`
+	Prefix   string        `yaml:"prefix"`
+	TTL      time.Duration `yaml:"ttl"`
+}
+
+type CalculationConfig struct {
+	SoftTimeout time.Duration `yaml:"soft_timeout"`
+	HardTimeout time.Duration `yaml:"hard_timeout"`
+	MaxQuantity int           `yaml:"max_quantity"`
+}
+
+// WorkerConfig names the order-created topic, on Kafka through a Kafka REST
+// Proxy or on NATS. Exactly one must be configured.
+type WorkerConfig struct {
+	Kafka KafkaConfig `yaml:"kafka"`
+	NATS  NATSConfig  `yaml:"nats"`
+}
+
+type KafkaConfig struct {
+	RESTProxyURL string `yaml:"rest_proxy_url"`
+	Topic        string `yaml:"topic"`
+	Group        string `yaml:"group"`
+}
+
+// NATSConfig subscribes to Subject; workers sharing a Queue split the
+// messages between them.
+type NATSConfig struct {
+	URL     string `yaml:"url"`
+	Subject string `yaml:"subject"`
+	Queue   string `yaml:"queue"`
+}
+
+// subscriber connects to the configured broker.
+func (c WorkerConfig) subscriber() (events.Subscriber, error) {
+	if c.Kafka.RESTProxyURL != "" {
+		return events.NewKafkaSubscriber(c.Kafka.RESTProxyURL, c.Kafka.Topic, c.Kafka.Group)
+	}
+	return events.NewNATSSubscriber(c.NATS.URL, c.NATS.Subject, c.NATS.Queue)
+}
+
+// loadConfig reads and validates the config. Unlike the API, the worker has
+// no default config: allocations stored with the wrong pack sizes would be
+// served by the API.
+func loadConfig(path string) (*Config, error) {
+	log.Printf("Loading config from %s", path)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var cfg Config
+	if err := yaml.NewDecoder(f).Decode(&cfg); err != nil {
+		return nil, err
+	}
+
+	if len(cfg.PackSizes) == 0 {
+		return nil, errors.New("no pack sizes configured")
+	}
+	for i, size := range cfg.PackSizes {
+		if size <= 0 {
+			return nil, fmt.Errorf("invalid pack size at index %d: %d (must be positive)", i, size)
+		}
+	}
+	if cfg.Strategy == "" {
+		cfg.Strategy = allocator.DefaultStrategy
+	}
+	if _, err := allocator.LookupStrategy(cfg.Strategy); err != nil {
+		return nil, err
+	}
+	if cfg.Calculation.SoftTimeout < 0 || cfg.Calculation.HardTimeout < 0 || cfg.Calculation.MaxQuantity < 0 {
+		return nil, errors.New("calculation settings must not be negative")
+	}
+	if cfg.Storage.Cache.TTL < 0 {
+		return nil, errors.New("storage cache ttl must not be negative")
+	}
+	if err := storage.CheckBackend(cfg.Storage.Backend); err != nil {
+		return nil, err
+	}
+
+	w := cfg.Worker
+	switch {
+	case w.Kafka.RESTProxyURL != "" && w.NATS.URL != "":
+		return nil, errors.New("worker: configure either kafka or nats, not both")
+	case w.Kafka.RESTProxyURL == "" && w.NATS.URL == "":
+		return nil, errors.New("worker: no kafka rest_proxy_url or nats url configured")
+	case w.Kafka.RESTProxyURL != "" && (w.Kafka.Topic == "" || w.Kafka.Group == ""):
+		return nil, errors.New("worker: kafka topic and group are required")
+	case w.NATS.URL != "" && w.NATS.Subject == "":
+		return nil, errors.New("worker: nats subject is required")
+	}
+	return &cfg, nil
+}
+
+// openStorage opens the API's database for the runtime environment.
+func openStorage(env, backend string) (storage.Storage, error) {
+	dataDir := "data"
+	if env == "docker" {
+		dataDir = "/app/data"
+	}
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("create data directory: %w", err)
+	}
+	return storage.Open(backend, filepath.Join(dataDir, storage.DatabaseFile(backend)), storage.WriteAppend)
+}
+
+func main() {
+	cfg, err := loadConfig("config/config.yaml")
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	store, err := openStorage(os.Getenv("APP_ENV"), cfg.Storage.Backend)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	alloc := allocator.NewAllocator(cfg.PackSizes, store)
+	defer alloc.Close()
+	if err := alloc.SetStrategy(cfg.Strategy); err != nil {
+		log.Fatalf("Failed to set allocation strategy: %v", err)
+	}
+	alloc.SetTimeouts(cfg.Calculation.SoftTimeout, cfg.Calculation.HardTimeout)
+	alloc.SetLimits(allocator.Limits{MaxQuantity: cfg.Calculation.MaxQuantity})
+	if cfg.Storage.Cache.RedisURL != "" {
+		cache, err := storage.NewRedisCache(context.Background(), cfg.Storage.Cache.RedisURL, cfg.Storage.Cache.Prefix, cfg.Storage.Cache.TTL)
+		if err != nil {
+			log.Fatalf("Failed to configure allocation cache: %v", err)
+		}
+		alloc.SetCache(cache)
+	}
+	if err := alloc.SetProfiles(cfg.Profiles, cfg.SKUProfiles); err != nil {
+		log.Fatalf("Failed to configure pack size profiles: %v", err)
+	}
+	if err := alloc.SetPackLimits(cfg.PackLimits); err != nil {
+		log.Fatalf("Failed to configure pack limits: %v", err)
+	}
+	if err := alloc.SetDeprecatedSizes(cfg.DeprecatedSizes); err != nil {
+		log.Fatalf("Failed to configure deprecated pack sizes: %v", err)
+	}
+	// Cached allocations are matched on profile version, so record the
+	// same versions the API does.
+	if err := alloc.RecordProfileVersions(); err != nil {
+		log.Fatalf("Failed to record pack size profile versions: %v", err)
+	}
+
+	sub, err := cfg.Worker.subscriber()
+	if err != nil {
+		log.Fatalf("Failed to subscribe to order events: %v", err)
+	}
+	defer sub.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go alloc.RunOutbox(ctx, outboxRetryInterval)
+
+	w := worker.New(alloc)
+	log.Printf("Warming allocations from order events")
+	w.Run(ctx, sub)
+
+	stats := w.Stats()
+	log.Printf("Worker stopped: %d allocations warmed, %d already cached, %d failed", stats.Warmed, stats.Skipped, stats.Failed)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	cfg, err := loadConfig(writeConfig(t, `
+pack_sizes: [250, 500, 1000]
+profiles:
+  apparel: [10, 20]
+calculation:
+  soft_timeout: 5s
+worker:
+  nats:
+    url: nats://localhost:4222
+    subject: orders.created
+    queue: gymshark-worker
+server:
+  port: 8080
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, []int{250, 500, 1000}, cfg.PackSizes)
+	assert.Equal(t, "dp", cfg.Strategy)
+	assert.Equal(t, "orders.created", cfg.Worker.NATS.Subject)
+
+	for name, content := range map[string]string{
+		"no pack sizes":    "worker: {nats: {url: nats://localhost:4222, subject: orders}}",
+		"bad pack size":    "pack_sizes: [0]\nworker: {nats: {url: nats://localhost:4222, subject: orders}}",
+		"unknown strategy": "pack_sizes: [1]\nstrategy: fastest\nworker: {nats: {url: nats://localhost:4222, subject: orders}}",
+		"no broker":        "pack_sizes: [1]",
+		"both brokers":     "pack_sizes: [1]\nworker: {nats: {url: nats://localhost:4222, subject: orders}, kafka: {rest_proxy_url: http://localhost:8082, topic: orders, group: g}}",
+		"no kafka group":   "pack_sizes: [1]\nworker: {kafka: {rest_proxy_url: http://localhost:8082, topic: orders}}",
+		"no nats subject":  "pack_sizes: [1]\nworker: {nats: {url: nats://localhost:4222}}",
+	} {
+		_, err := loadConfig(writeConfig(t, content))
+		assert.Error(t, err, name)
+	}
+
+	_, err = loadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
@@ -15,9 +15,9 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/calculate": {
+        "/health": {
             "get": {
-                "description": "Calculate the optimal pack distribution for a given quantity",
+                "description": "Check if the service is alive. It does not check dependencies; see /health/ready.",
                 "consumes": [
                     "application/json"
                 ],
@@ -25,67 +25,374 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "packs"
+                    "health"
                 ],
-                "summary": "Calculate pack distribution",
+                "summary": "Health check",
+                "responses": {
+                    "200": {
+                        "description": "Health status",
+                        "schema": {
+                            "$ref": "#/definitions/api.HealthResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/health/ready": {
+            "get": {
+                "description": "Run the readiness probes, such as database query latency and data volume usage, and report each against its warning and critical thresholds. The service is not ready while any probe is critical; warnings are reported but keep it ready. Once the instance prepares for termination (see /admin/prestop) it is not ready either, with status stopping, and the probes are not run.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness check",
+                "responses": {
+                    "200": {
+                        "description": "Ready, possibly with warnings",
+                        "schema": {
+                            "$ref": "#/definitions/api.ReadinessResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "A probe is critical or the instance is stopping",
+                        "schema": {
+                            "$ref": "#/definitions/api.ReadinessResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/metrics": {
+            "get": {
+                "description": "Prometheus metrics, in the OpenMetrics format when requested through the Accept header. Includes histograms of waste and packs per allocation by profile a gauge of distinct quantities in the negative cache, the calculations in flight, queued and shed by admission control, and a histogram of calculation request durations by route, status and quantity bucket (1-100, 100-10k, 10k-1M, 1M+).",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Get service metrics",
+                "responses": {
+                    "200": {
+                        "description": "Metrics exposition",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/openapi.json": {
+            "get": {
+                "description": "Serve the generated API specification (Swagger 2.0 / OpenAPI 2) as JSON for client code generation",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "docs"
+                ],
+                "summary": "OpenAPI specification",
+                "responses": {
+                    "200": {
+                        "description": "API specification",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    "500": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/admin/audit": {
+            "get": {
+                "description": "List audited requests (every mutating or calculating request) with the caller, parameters and outcome, most recent first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Query the audit log",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only entries by this actor, e.g. user:alice or key:3f2a9c1b0d4e",
+                        "name": "actor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only entries for this request path, e.g. /calculate",
+                        "name": "path",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Earliest time (RFC 3339 or YYYY-MM-DD), inclusive",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Latest time (RFC 3339 or YYYY-MM-DD), exclusive",
+                        "name": "to",
+                        "in": "query"
+                    },
                     {
                         "type": "integer",
-                        "description": "Order quantity",
-                        "name": "quantity",
-                        "in": "query",
-                        "required": true
+                        "description": "Maximum entries to return (default 100, max 1000)",
+                        "name": "limit",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Pack distribution",
+                        "description": "Audit entries",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/api.AuditResponse"
                         }
                     },
                     "400": {
                         "description": "Error message",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/health": {
+        "/v1/admin/backup": {
+            "post": {
+                "description": "Stream a consistent snapshot of the allocation database: a SQLite database file, or a bbolt file with storage.backend bolt. Writes continue while it is taken. Restore it with \"gymshark restore\".",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Back up the database",
+                "responses": {
+                    "200": {
+                        "description": "Database snapshot",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "500": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/admin/cache": {
             "get": {
-                "description": "Check if the service is healthy",
-                "consumes": [
+                "description": "Report whether the result cache is enabled, the negative cache entries and infeasible quantities held on this instance, and the number of pinned allocations",
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Get cache statistics",
+                "responses": {
+                    "200": {
+                        "description": "Cache statistics",
+                        "schema": {
+                            "$ref": "#/definitions/api.CacheStatsResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/admin/cache/purge": {
+            "post": {
+                "description": "Drop every cached calculation outcome on this instance and, when cache invalidation is configured, on every replica",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "health"
+                    "admin"
                 ],
-                "summary": "Health check",
+                "summary": "Purge cached outcomes",
                 "responses": {
                     "200": {
-                        "description": "Health status",
+                        "description": "Cache purged",
+                        "schema": {
+                            "$ref": "#/definitions/api.PurgeResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Purged locally but not propagated",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/admin/config": {
+            "get": {
+                "description": "Report the strategy, the pack sizes, limits, dimensions, SKUs and defaults of every profile, the request limits, the read-only state and the cartons, including changes made at runtime",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Get the running configuration",
+                "responses": {
+                    "200": {
+                        "description": "Running configuration",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.ConfigResponse"
                         }
                     }
                 }
             }
         },
-        "/recent": {
+        "/v1/admin/outbox": {
             "get": {
-                "description": "Get the most recent pack allocations",
+                "description": "Report allocation writes the storage failed: pending ones queued for retry, ones persisted on a retry and ones dropped from the history",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Get the allocation outbox state",
+                "responses": {
+                    "200": {
+                        "description": "Outbox counters",
+                        "schema": {
+                            "$ref": "#/definitions/api.OutboxResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/admin/precompute": {
+            "post": {
+                "description": "Compute and store the allocation of every quantity in [from, to] with one dynamic-programming pass, so calculation.result_cache serves them. Quantities already stored for the current profile version, or pinned, are skipped. Ranges end at 1000000 at most.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Precompute allocations",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "First quantity",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Last quantity",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Profile name (default when empty)",
+                        "name": "profile",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Allocations stored and skipped",
+                        "schema": {
+                            "$ref": "#/definitions/allocator.PrecomputeResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Range exceeds the quantity limit",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Read-only mode",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Request timeout; allocations stored so far are kept",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/admin/prestop": {
+            "post": {
+                "description": "Report the instance not ready on /health/ready, keep serving for server.prestop.drain_delay so that the ingress stops routing to it, wait up to drain_timeout for the requests in flight to finish, then flush the allocation outbox and the storage fallback spool. Call it from a Kubernetes preStop hook; SIGTERM runs the same sequence. It runs once: later calls wait for it.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Prepare for termination",
+                "responses": {
+                    "200": {
+                        "description": "Drained and flushed",
+                        "schema": {
+                            "$ref": "#/definitions/api.PreStopResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Flushing failed",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Request timed out before draining ended",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/admin/profiles/{name}": {
+            "put": {
+                "description": "Replace the pack sizes of a profile at runtime (\"default\" for the configured pack sizes), record a new profile version and purge cached outcomes. The update is propagated to every replica when cache invalidation is configured. When profile_guardrail is configured, the profile's latest allocations are replayed with the new sizes first, and an update raising their waste rate by more than max_waste_increase percentage points is rejected unless forced.",
                 "consumes": [
                     "application/json"
                 ],
@@ -93,28 +400,3674 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "packs"
+                    "admin"
+                ],
+                "summary": "Update a pack-size profile",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Profile name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Update even if waste rises beyond the guardrail",
+                        "name": "force",
+                        "in": "query"
+                    },
+                    {
+                        "description": "New pack sizes",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.profileUpdateRequest"
+                        }
+                    }
                 ],
-                "summary": "Get recent allocations",
                 "responses": {
                     "200": {
-                        "description": "Recent allocations",
+                        "description": "Updated profile",
+                        "schema": {
+                            "$ref": "#/definitions/api.ProfileUpdateResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid fields",
+                        "schema": {
+                            "$ref": "#/definitions/api.ValidationErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Waste rises beyond the guardrail",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/api.ProfileGuardrailResponse"
                         }
                     },
                     "500": {
                         "description": "Error message",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Updated locally but not propagated",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Read-only mode",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
                         }
                     }
                 }
             }
+        },
+        "/v1/admin/profiles/{name}/defaults": {
+            "put": {
+                "description": "Store the strategy, objective weights and soft timeout applied to the profile's calculations that do not set them (\"default\" for the configured pack sizes), replacing its previous defaults; an empty body removes them. Weights apply to requests naming no strategy and are solved with branchbound, so they cannot be combined with a strategy. The change is propagated to every replica when cache invalidation is configured.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Set the defaults of a pack-size profile",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Profile name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Profile defaults",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.profileDefaultsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Stored defaults",
+                        "schema": {
+                            "$ref": "#/definitions/api.ProfileDefaultsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid defaults or unknown profile",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Set locally but not propagated",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Read-only mode",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/admin/prune": {
+            "post": {
+                "description": "Remove stored allocations outside the retention policy. max_age and max_rows override the configured policy for this run.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Prune allocation history",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Remove allocations older than this duration, e.g. 720h",
+                        "name": "max_age",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Keep only this many of the most recent allocations",
+                        "name": "max_rows",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Number of allocations removed",
+                        "schema": {
+                            "$ref": "#/definitions/storage.PruneResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Read-only mode",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/admin/read-only": {
+            "get": {
+                "description": "Report whether the service is in read-only (maintenance) mode and how writes are handled",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Get read-only state",
+                "responses": {
+                    "200": {
+                        "description": "Read-only state",
+                        "schema": {
+                            "$ref": "#/definitions/api.ReadOnlyResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Switch read-only (maintenance) mode. In \"skip\" mode calculations are served but not stored; in \"reject\" mode they fail with 503.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Set read-only state",
+                "parameters": [
+                    {
+                        "description": "Read-only state; mode is skip (default) or reject",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.readOnlyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Read-only state",
+                        "schema": {
+                            "$ref": "#/definitions/api.ReadOnlyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid fields",
+                        "schema": {
+                            "$ref": "#/definitions/api.ValidationErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/admin/shadow": {
+            "get": {
+                "description": "Report how often the strategy run in shadow, on a sample of calculations, disagreed with the results answered since this instance started, and the latest disagreements. All counts are zero when shadowing is disabled.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Get shadow comparisons",
+                "responses": {
+                    "200": {
+                        "description": "Shadow comparisons",
+                        "schema": {
+                            "$ref": "#/definitions/api.ShadowResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/admin/usage": {
+            "get": {
+                "description": "Report the requests and compute time each client used in a calendar month (UTC), with its quota, for billing by consumption. Clients are configured API keys (key:\u003cfingerprint\u003e) and verified token subjects (user:\u003csub\u003e); everyone else is anonymous.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Get usage by client",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Month as YYYY-MM (default the current month)",
+                        "name": "month",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only this client, e.g. user:alice or key:3f2a9c1b0d4e",
+                        "name": "client",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Usage by client",
+                        "schema": {
+                            "$ref": "#/definitions/api.UsageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/allocations": {
+            "get": {
+                "description": "Get all allocations recorded against an order ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "packs"
+                ],
+                "summary": "Get allocations for an order",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Order ID",
+                        "name": "order_id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Allocations for the order",
+                        "schema": {
+                            "$ref": "#/definitions/api.AllocationsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/allocations/export": {
+            "get": {
+                "description": "Stream every stored allocation in the requested format, optionally limited to a date range",
+                "produces": [
+                    "text/csv",
+                    "application/json",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "packs"
+                ],
+                "summary": "Export allocation history",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Export format: csv, json or ndjson (default csv)",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Inclusive start (RFC 3339 or YYYY-MM-DD)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Exclusive end (RFC 3339 or YYYY-MM-DD)",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Allocation history",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/allocations/pin": {
+            "put": {
+                "description": "Store a manual pack breakdown that /calculate returns for the quantity and profile, flagged with \"source\": \"manual\", until it is unpinned. Pinning a pinned quantity replaces its pin. Constrained calculations are not affected.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "allocations"
+                ],
+                "summary": "Pin an allocation",
+                "parameters": [
+                    {
+                        "description": "Quantity, profile (default when empty) and packs",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.pinRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The stored pin",
+                        "schema": {
+                            "$ref": "#/definitions/api.PinResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid fields",
+                        "schema": {
+                            "$ref": "#/definitions/api.ValidationErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Pinned locally but not propagated to other replicas",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Read-only mode",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Remove the pin of a quantity, so /calculate computes it again",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "allocations"
+                ],
+                "summary": "Unpin an allocation",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Pinned quantity",
+                        "name": "quantity",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Profile name (default when empty)",
+                        "name": "profile",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Pin removed"
+                    },
+                    "400": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Quantity not pinned",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Unpinned locally but not propagated to other replicas",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Read-only mode",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/allocations/pins": {
+            "get": {
+                "description": "List the manual pack breakdowns served by /calculate, ordered by profile and quantity",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "allocations"
+                ],
+                "summary": "List pinned allocations",
+                "responses": {
+                    "200": {
+                        "description": "Pinned allocations",
+                        "schema": {
+                            "$ref": "#/definitions/api.PinsResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/allocations/search": {
+            "get": {
+                "description": "Find stored allocations by quantity, waste, creation time, pack size used and profile, e.g. every allocation that used the 5000 pack last week. Ranges are inclusive except to. Soft-deleted allocations are left out.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "allocations"
+                ],
+                "summary": "Search allocations",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Smallest order quantity",
+                        "name": "min_quantity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Largest order quantity",
+                        "name": "max_quantity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Least waste (total minus quantity)",
+                        "name": "min_waste",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Most waste; 0 finds exact allocations",
+                        "name": "max_waste",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Earliest creation time (RFC 3339 or YYYY-MM-DD), inclusive",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Latest creation time (RFC 3339 or YYYY-MM-DD), exclusive",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only allocations using at least one pack of this size",
+                        "name": "pack_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only allocations of this profile",
+                        "name": "profile",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "created_at, quantity, waste or total, prefixed with - for descending (default -created_at)",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Matches to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum allocations to return (default 100, max 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Matching allocations",
+                        "schema": {
+                            "$ref": "#/definitions/api.SearchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/allocations/{id}": {
+            "delete": {
+                "description": "Soft-delete a stored allocation: it is kept, marked with its deletion time, but left out of /recent, order lookups, exports and /stats, and no longer reused by the result cache, until it is restored. /recent?include_deleted=true still lists it.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "allocations"
+                ],
+                "summary": "Delete an allocation",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Allocation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Allocation deleted"
+                    },
+                    "400": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No such allocation, or already deleted",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Read-only mode",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/allocations/{id}/restore": {
+            "post": {
+                "description": "Undo the soft deletion of an allocation",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "allocations"
+                ],
+                "summary": "Restore an allocation",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Allocation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Allocation restored"
+                    },
+                    "400": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No such deleted allocation",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Read-only mode",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/allocations/{quantity}/history": {
+            "get": {
+                "description": "Show how the stored allocation of a quantity changed over time, for example after a profile update: one entry per run of allocations that packed it the same way with the same profile version, oldest first, each with the change in packs from the one before. Built from the latest 1000 allocations of the quantity; soft-deleted allocations are left out.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "allocations"
+                ],
+                "summary": "Allocation history of a quantity",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Order quantity in items",
+                        "name": "quantity",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Profile name (default \\",
+                        "name": "profile",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Allocation changes, oldest first",
+                        "schema": {
+                            "$ref": "#/definitions/api.AllocationHistoryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No allocations stored for the quantity",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/calculate": {
+            "get": {
+                "description": "Calculate the optimal pack distribution for a given quantity. Responses for a quantity in units carry a weak ETag derived from the quantity and the profile version; a request whose If-None-Match matches it is answered with 304 without calculating or storing the allocation.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "packs"
+                ],
+                "summary": "Calculate pack distribution",
+                "parameters": [
+                    {
+                        "type": "number",
+                        "description": "Order quantity; decimal kilograms when unit=weight",
+                        "name": "quantity",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "units (default), weight or a unit configured under units, e.g. case",
+                        "name": "unit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Allocation strategy (defaults to the configured strategy)",
+                        "name": "strategy",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include algorithm telemetry in the response",
+                        "name": "debug",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "map",
+                            "list",
+                            "flat"
+                        ],
+                        "type": "string",
+                        "description": "Packs format: map (default), list or flat",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include how the packs ship in the configured cartons",
+                        "name": "cartons",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include labels describing the packs in the language negotiated from Accept-Language",
+                        "name": "labels",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Calculate with the pack sizes active at this RFC 3339 time or date (midnight UTC); the result is not stored",
+                        "name": "as_of",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of a previous response",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Pack distribution (WeightCalculateResponse when unit=weight)",
+                        "schema": {
+                            "$ref": "#/definitions/api.CalculateResponse"
+                        }
+                    },
+                    "304": {
+                        "description": "Not modified"
+                    },
+                    "400": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "No feasible combination, packs that fit no carton, or no profile version at as_of",
+                        "schema": {
+                            "$ref": "#/definitions/api.InfeasibleResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Usage quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Read-only mode or overloaded",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Calculate the optimal pack distribution and record it against an order reference. Optional constraints (stock per size, max count per size, max packs, cost per size) are solved with the branchbound strategy. Stock given as available, or constraints with allow_shortfall, turns a quantity the stock cannot cover into a best-effort allocation with a shortfall instead of a 422. Weights trade waste against pack count and cost.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "packs"
+                ],
+                "summary": "Calculate pack distribution for an order",
+                "parameters": [
+                    {
+                        "description": "Quantity, order reference and metadata",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.calculateRequest"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include algorithm telemetry in the response",
+                        "name": "debug",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "map",
+                            "list",
+                            "flat"
+                        ],
+                        "type": "string",
+                        "description": "Packs format: map (default), list or flat",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include how the packs ship in the configured cartons",
+                        "name": "cartons",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include labels describing the packs in the language negotiated from Accept-Language",
+                        "name": "labels",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Calculate with the pack sizes active at this RFC 3339 time or date (midnight UTC); the result is not stored",
+                        "name": "as_of",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Pack distribution (WeightCalculateResponse when unit is weight)",
+                        "schema": {
+                            "$ref": "#/definitions/api.CalculateResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid fields",
+                        "schema": {
+                            "$ref": "#/definitions/api.ValidationErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "No feasible combination, packs that fit no carton, or no profile version at as_of",
+                        "schema": {
+                            "$ref": "#/definitions/api.InfeasibleResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Usage quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Read-only mode or overloaded",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/calculate/batch": {
+            "post": {
+                "description": "Allocate every quantity with one profile, answering them all from one dynamic-programming table built up to the largest quantity, so a batch costs about as much as its largest quantity. Results come in the order of the quantities, with the exact strategy's packs avoiding deprecated sizes, unless the quantity is pinned. Nothing is stored and no cache or allocation rule applies. The number of quantities counts towards the batch limit, quantities end at 1000000 at most, and profiles with pack limits are rejected.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "packs"
+                ],
+                "summary": "Calculate pack distributions for many quantities",
+                "parameters": [
+                    {
+                        "description": "Quantities and profile",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.batchRequest"
+                        }
+                    },
+                    {
+                        "enum": [
+                            "map",
+                            "list",
+                            "flat"
+                        ],
+                        "type": "string",
+                        "description": "Packs format: map (default), list or flat",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "One result per quantity",
+                        "schema": {
+                            "$ref": "#/definitions/api.BatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid fields",
+                        "schema": {
+                            "$ref": "#/definitions/api.ValidationErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Too many or too large quantities, or a profile with pack limits",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Usage quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Overloaded",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/calculate/compare": {
+            "post": {
+                "description": "Allocate one quantity under several pack-size sets and report which set is best by waste, pack count and cost. Costs per pack size are optional and default to 1, so cost is the pack count. Nothing is stored.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "packs"
+                ],
+                "summary": "Compare pack-size sets for a quantity",
+                "parameters": [
+                    {
+                        "description": "Quantity and at least two pack-size sets",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.compareRequest"
+                        }
+                    },
+                    {
+                        "enum": [
+                            "map",
+                            "list",
+                            "flat"
+                        ],
+                        "type": "string",
+                        "description": "Packs format: map (default), list or flat",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Allocation per set and the best sets",
+                        "schema": {
+                            "$ref": "#/definitions/api.CompareResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid fields",
+                        "schema": {
+                            "$ref": "#/definitions/api.ValidationErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Too many sets or too large a quantity",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Usage quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Overloaded",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/calculate/order": {
+            "post": {
+                "description": "Allocate packs for every SKU line of an order using each SKU's pack-size profile, and summarise the order",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "packs"
+                ],
+                "summary": "Calculate pack distribution for a multi-item order",
+                "parameters": [
+                    {
+                        "description": "Order lines",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.orderRequest"
+                        }
+                    },
+                    {
+                        "enum": [
+                            "map",
+                            "list",
+                            "flat"
+                        ],
+                        "type": "string",
+                        "description": "Packs format: map (default), list or flat",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include labels describing each line's packs in the language negotiated from Accept-Language",
+                        "name": "labels",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Per-item allocations and order summary",
+                        "schema": {
+                            "$ref": "#/definitions/api.OrderResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid fields",
+                        "schema": {
+                            "$ref": "#/definitions/api.ValidationErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "No feasible combination",
+                        "schema": {
+                            "$ref": "#/definitions/api.InfeasibleResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Usage quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Read-only mode or overloaded",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/profiles/validate": {
+            "get": {
+                "description": "Check the default and named profiles against the configured pack-size rules: duplicate sizes, a size of 1 next to other sizes, sizes that are multiples of each other and too large a ratio between the largest and smallest size. Valid is false when any issue has error severity; profile updates with such issues are rejected.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "profiles"
+                ],
+                "summary": "Validate pack-size profiles",
+                "responses": {
+                    "200": {
+                        "description": "Rule issues by profile",
+                        "schema": {
+                            "$ref": "#/definitions/api.ProfileValidationResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/profiles/{name}/versions": {
+            "get": {
+                "description": "Get every recorded version of a pack-size profile with its effective date range",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "profiles"
+                ],
+                "summary": "Get profile versions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Profile name (\\",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Profile versions, oldest first",
+                        "schema": {
+                            "$ref": "#/definitions/api.ProfileVersionsResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/recent": {
+            "get": {
+                "description": "Get the most recent pack allocations, and how many are stored in all. Soft-deleted allocations are left out unless include_deleted is set, which takes the reader role when admin authentication is configured.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "packs"
+                ],
+                "summary": "Get recent allocations",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Maximum allocations to return (server.recent.default_limit by default, at most server.recent.max_limit)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft-deleted allocations, marked with DeletedAt",
+                        "name": "include_deleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Recent allocations",
+                        "schema": {
+                            "$ref": "#/definitions/api.RecentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/reports/allocations.xlsx": {
+            "get": {
+                "description": "Build an Excel workbook of the allocations created in a date range: a Summary sheet with totals, waste, packs by size and allocations by profile, and an Allocations sheet with one row per allocation.",
+                "produces": [
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                ],
+                "tags": [
+                    "packs"
+                ],
+                "summary": "Allocation report as Excel",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Inclusive start (RFC 3339 or YYYY-MM-DD)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Exclusive end (RFC 3339 or YYYY-MM-DD)",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Excel workbook",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/simulate": {
+            "post": {
+                "description": "Allocate a quantity, or every quantity ordered in a date range, under a baseline and a candidate pack-size set and compare waste and pack counts. Nothing is stored.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "packs"
+                ],
+                "summary": "Compare two pack-size sets",
+                "parameters": [
+                    {
+                        "description": "Quantity or date range, and the pack-size sets to compare",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.simulateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Side-by-side comparison",
+                        "schema": {
+                            "$ref": "#/definitions/api.SimulationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid fields",
+                        "schema": {
+                            "$ref": "#/definitions/api.ValidationErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "No feasible combination",
+                        "schema": {
+                            "$ref": "#/definitions/api.InfeasibleResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Usage quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Overloaded",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/stats": {
+            "get": {
+                "description": "Count the stored allocations by the algorithm that produced them (a strategy, greedy after a soft timeout fallback, cache or manual), and how many were approximate, sum the items they shipped beyond the quantities ordered by algorithm, profile or day, and count the packs of deprecated sizes shipped, optionally limited to a date range. The sums are computed by the storage, without loading the allocations. Allocations deduplicated as hits count once per hit.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "packs"
+                ],
+                "summary": "Get algorithm statistics",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Inclusive start (RFC 3339 or YYYY-MM-DD)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Exclusive end (RFC 3339 or YYYY-MM-DD)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "algorithm",
+                            "profile",
+                            "day"
+                        ],
+                        "type": "string",
+                        "default": "algorithm",
+                        "description": "What waste_by groups waste by",
+                        "name": "group_by",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Allocations by algorithm, most used first, waste by group and deprecated packs by profile and size",
+                        "schema": {
+                            "$ref": "#/definitions/api.StatsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/suggest": {
+            "get": {
+                "description": "Find the quantities nearest to an order quantity, below and above, that the profile's pack sizes allocate with zero waste, so sales can nudge customers towards them. Quantities with pack limits are not supported, deprecated sizes are avoided where possible, and nothing is stored. Quantities end at 1000000 at most.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "packs"
+                ],
+                "summary": "Suggest zero-waste quantities",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Order quantity",
+                        "name": "quantity",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Profile name (default when empty)",
+                        "name": "profile",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "map",
+                            "list",
+                            "flat"
+                        ],
+                        "type": "string",
+                        "description": "Packs format: map (default), list or flat",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Nearest zero-waste quantities; below is null when there is none",
+                        "schema": {
+                            "$ref": "#/definitions/api.SuggestResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Too large a quantity or a profile with pack limits",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Usage quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Overloaded",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Error message",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/ws/calculate": {
+            "get": {
+                "description": "Upgrade to a WebSocket. Send quantities (a number or {\"quantity\", \"strategy\", \"profile\"}) and receive {\"quantity\", \"packs\", \"total\", \"approximate\"} or {\"quantity\", \"error\"}. Rapid messages are debounced so only the latest quantity is calculated. Results are not stored.",
+                "tags": [
+                    "packs"
+                ],
+                "summary": "Live pack calculator",
+                "responses": {
+                    "101": {
+                        "description": "Switching Protocols",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Not a WebSocket handshake",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "allocator.PrecomputeResult": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "type": "integer"
+                },
+                "profile": {
+                    "type": "string"
+                },
+                "skipped": {
+                    "description": "Skipped counts quantities that already had a current allocation\nstored, or are pinned.",
+                    "type": "integer"
+                },
+                "stored": {
+                    "description": "Stored counts allocations computed and stored.",
+                    "type": "integer"
+                },
+                "to": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.AlgorithmStatsEntry": {
+            "type": "object",
+            "properties": {
+                "algorithm": {
+                    "type": "string",
+                    "example": "dp"
+                },
+                "allocations": {
+                    "type": "integer",
+                    "example": 900
+                },
+                "approximate": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "share": {
+                    "type": "number",
+                    "example": 0.75
+                }
+            }
+        },
+        "api.AllocationChangeResponse": {
+            "type": "object",
+            "properties": {
+                "algorithm": {
+                    "type": "string",
+                    "example": "dp"
+                },
+                "allocations": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "delta": {
+                    "description": "Delta is the change in packs of each size from the previous entry,\nnegative for packs removed; it is left out of the first entry.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "first_seen": {
+                    "type": "string"
+                },
+                "last_seen": {
+                    "type": "string"
+                },
+                "packs": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "profile_version": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 500
+                },
+                "waste": {
+                    "type": "integer",
+                    "example": 0
+                }
+            }
+        },
+        "api.AllocationHistoryResponse": {
+            "type": "object",
+            "properties": {
+                "changes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.AllocationChangeResponse"
+                    }
+                },
+                "profile": {
+                    "type": "string",
+                    "example": "default"
+                },
+                "quantity": {
+                    "type": "integer",
+                    "example": 500
+                }
+            }
+        },
+        "api.AllocationsResponse": {
+            "type": "object",
+            "properties": {
+                "allocations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/storage.Allocation"
+                    }
+                }
+            }
+        },
+        "api.AuditResponse": {
+            "type": "object",
+            "properties": {
+                "entries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/storage.AuditEntry"
+                    }
+                }
+            }
+        },
+        "api.BatchItemResponse": {
+            "type": "object",
+            "properties": {
+                "pack_count": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "packs": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "quantity": {
+                    "type": "integer",
+                    "example": 251
+                },
+                "source": {
+                    "type": "string",
+                    "enum": [
+                        "manual"
+                    ]
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 500
+                },
+                "waste": {
+                    "type": "integer",
+                    "example": 249
+                }
+            }
+        },
+        "api.BatchResponse": {
+            "type": "object",
+            "properties": {
+                "profile": {
+                    "type": "string",
+                    "example": "default"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.BatchItemResponse"
+                    }
+                }
+            }
+        },
+        "api.CacheStatsResponse": {
+            "type": "object",
+            "properties": {
+                "infeasible_quantities": {
+                    "type": "integer"
+                },
+                "negative_entries": {
+                    "type": "integer"
+                },
+                "negative_ttl": {
+                    "description": "NegativeTTL is how long failed outcomes are cached, e.g. \"5m0s\";\nempty when the negative cache is disabled.",
+                    "type": "string",
+                    "example": "5m0s"
+                },
+                "pins": {
+                    "type": "integer"
+                },
+                "result_cache": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "api.CalculateResponse": {
+            "type": "object",
+            "properties": {
+                "approximate": {
+                    "description": "Approximate is set when the soft deadline passed before the search\nproved the result optimal.",
+                    "type": "boolean"
+                },
+                "cached": {
+                    "description": "Cached is set when the result is a stored allocation reused by the\nresult cache rather than computed for this request.",
+                    "type": "boolean"
+                },
+                "cartons": {
+                    "description": "Cartons is how the packs ship in the configured cartons, included\nwith ?cartons=true.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.CartonPlanResponse"
+                        }
+                    ]
+                },
+                "computed_at": {
+                    "description": "ComputedAt is when the packs were computed: for a cached result when\nthe stored allocation was, for a pinned one when it was pinned.\nResults computed before the current pack sizes are never cached.",
+                    "type": "string",
+                    "example": "2025-05-31T20:18:17Z"
+                },
+                "customer_id": {
+                    "type": "string"
+                },
+                "debug": {
+                    "$ref": "#/definitions/api.DebugResponse"
+                },
+                "deprecated_packs": {
+                    "description": "DeprecatedPacks are the packs of sizes being phased out, which are\nonly used when needed for the least waste. Omitted when there are\nnone.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "flags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "requires_approval"
+                    ]
+                },
+                "items": {
+                    "type": "integer",
+                    "example": 144
+                },
+                "labels": {
+                    "description": "Labels describes the packs for display in the request's language,\nincluded with ?labels=true.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.LabelsResponse"
+                        }
+                    ]
+                },
+                "order_id": {
+                    "type": "string"
+                },
+                "packs": {
+                    "description": "Packs maps pack size to the number of packs of that size. With\n?format=list it is a []PackCount sorted largest first; with\n?format=flat a string such as \"2x500,1x250\".",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "rules": {
+                    "description": "Rules are the configured allocation rules that held for the result,\nand Flags every flag they set. Both are omitted when none held.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.RuleMatchResponse"
+                    }
+                },
+                "shortfall": {
+                    "description": "Shortfall is how much of the quantity the available stock could not\ncover; the packs are everything the constraints allowed.",
+                    "type": "integer",
+                    "example": 0
+                },
+                "source": {
+                    "description": "Source is manual when the quantity is pinned with PUT\n/allocations/pin, and omitted when the result was computed.",
+                    "type": "string",
+                    "enum": [
+                        "manual"
+                    ]
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 750
+                },
+                "unit": {
+                    "description": "Unit is the configured unit the quantity was requested in, and\nItems the number of items it was converted to and allocated. Both\nare omitted for quantities in items.",
+                    "type": "string",
+                    "example": "case"
+                }
+            }
+        },
+        "api.CartonLoadResponse": {
+            "type": "object",
+            "properties": {
+                "carton": {
+                    "type": "string",
+                    "example": "large"
+                },
+                "count": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "fill": {
+                    "description": "Fill is the share of each carton's volume the packs take up.",
+                    "type": "number",
+                    "example": 0.82
+                },
+                "packs": {
+                    "description": "Packs are the packs in each carton, in the requested format.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "weight": {
+                    "description": "Weight is the weight of the packs in each carton in grams.",
+                    "type": "integer",
+                    "example": 5000
+                }
+            }
+        },
+        "api.CartonPlanResponse": {
+            "type": "object",
+            "properties": {
+                "cartons": {
+                    "description": "Cartons is the number of cartons, Weight the weight of all the packs in\ngrams.",
+                    "type": "integer",
+                    "example": 3
+                },
+                "loads": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.CartonLoadResponse"
+                    }
+                },
+                "weight": {
+                    "type": "integer",
+                    "example": 12500
+                }
+            }
+        },
+        "api.CartonResponse": {
+            "type": "object",
+            "properties": {
+                "height": {
+                    "type": "integer",
+                    "example": 400
+                },
+                "length": {
+                    "type": "integer",
+                    "example": 600
+                },
+                "max_weight": {
+                    "type": "integer",
+                    "example": 25000
+                },
+                "name": {
+                    "type": "string",
+                    "example": "large"
+                },
+                "width": {
+                    "type": "integer",
+                    "example": 400
+                }
+            }
+        },
+        "api.CheckResponse": {
+            "type": "object",
+            "properties": {
+                "critical": {
+                    "type": "number",
+                    "example": 1000
+                },
+                "error": {
+                    "description": "Error says why the probe could not measure, which is critical.",
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "ok",
+                        "warning",
+                        "critical"
+                    ],
+                    "example": "ok"
+                },
+                "unit": {
+                    "type": "string",
+                    "example": "ms"
+                },
+                "value": {
+                    "type": "number",
+                    "example": 1.8
+                },
+                "warning": {
+                    "type": "number",
+                    "example": 100
+                }
+            }
+        },
+        "api.ClientUsageResponse": {
+            "type": "object",
+            "properties": {
+                "client": {
+                    "type": "string",
+                    "example": "key:3f2a9c1b0d4e"
+                },
+                "compute_seconds": {
+                    "type": "number",
+                    "example": 12.5
+                },
+                "quota": {
+                    "description": "Quota is omitted for clients without one.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.QuotaResponse"
+                        }
+                    ]
+                },
+                "requests": {
+                    "type": "integer",
+                    "example": 1520
+                }
+            }
+        },
+        "api.CompareOutcomeResponse": {
+            "type": "object",
+            "properties": {
+                "approximate": {
+                    "type": "boolean"
+                },
+                "cost": {
+                    "description": "Cost is the sum of the pack costs, or the pack count without costs.",
+                    "type": "number"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "current"
+                },
+                "pack_count": {
+                    "type": "integer"
+                },
+                "pack_sizes": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "packs": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "waste": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.CompareResponse": {
+            "type": "object",
+            "properties": {
+                "quantity": {
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.CompareOutcomeResponse"
+                    }
+                },
+                "summary": {
+                    "$ref": "#/definitions/api.CompareSummaryResponse"
+                }
+            }
+        },
+        "api.CompareSummaryResponse": {
+            "type": "object",
+            "properties": {
+                "best": {
+                    "type": "string"
+                },
+                "best_by_cost": {
+                    "type": "string"
+                },
+                "best_by_packs": {
+                    "type": "string"
+                },
+                "best_by_waste": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.ConfigResponse": {
+            "type": "object",
+            "properties": {
+                "cartons": {
+                    "description": "Cartons are the cartons ?cartons=true fits allocations into, smallest\nfirst.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.CartonResponse"
+                    }
+                },
+                "max_batch_size": {
+                    "type": "integer"
+                },
+                "max_quantity": {
+                    "type": "integer"
+                },
+                "profiles": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ProfileResponse"
+                    }
+                },
+                "read_only": {
+                    "$ref": "#/definitions/api.ReadOnlyResponse"
+                },
+                "strategy": {
+                    "type": "string",
+                    "example": "dp"
+                }
+            }
+        },
+        "api.DebugResponse": {
+            "type": "object",
+            "properties": {
+                "algorithm": {
+                    "description": "Algorithm is the strategy that produced the result; greedy after a\nsoft timeout fallback.",
+                    "type": "string",
+                    "example": "backtracking"
+                },
+                "cache": {
+                    "description": "Cache is miss when the result was computed, hit when it was a stored\nallocation (see calculation.result_cache), shared when it was computed\nfor an identical concurrent request, or bypass for constrained\nrequests, which are never cached.",
+                    "type": "string",
+                    "enum": [
+                        "miss",
+                        "hit",
+                        "shared",
+                        "bypass"
+                    ]
+                },
+                "compute_ms": {
+                    "type": "number",
+                    "example": 0.42
+                },
+                "deadline_ms": {
+                    "type": "number",
+                    "example": 50
+                },
+                "estimated_ms": {
+                    "description": "EstimatedMS is how long the cost model expected the calculation to\ntake and DeadlineMS when it would have fallen back to greedy; see\ncalculation.cost. Both are omitted for results not computed.",
+                    "type": "number",
+                    "example": 0.12
+                },
+                "iterations": {
+                    "description": "Iterations counts search nodes, DP cells or candidate combinations,\ndepending on the algorithm.",
+                    "type": "integer",
+                    "example": 1204
+                }
+            }
+        },
+        "api.DeprecatedUsageEntry": {
+            "type": "object",
+            "properties": {
+                "allocations": {
+                    "type": "integer",
+                    "example": 310
+                },
+                "packs": {
+                    "type": "integer",
+                    "example": 340
+                },
+                "profile": {
+                    "type": "string",
+                    "example": "default"
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 250
+                }
+            }
+        },
+        "api.DimensionsResponse": {
+            "type": "object",
+            "properties": {
+                "height": {
+                    "type": "integer",
+                    "example": 150
+                },
+                "length": {
+                    "type": "integer",
+                    "example": 300
+                },
+                "weight": {
+                    "type": "integer",
+                    "example": 2500
+                },
+                "width": {
+                    "type": "integer",
+                    "example": 200
+                }
+            }
+        },
+        "api.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Code identifies the error independently of the language of Error. It\nis empty for errors without a translation.",
+                    "type": "string",
+                    "example": "invalid_quantity"
+                },
+                "error": {
+                    "type": "string",
+                    "example": "invalid quantity"
+                }
+            }
+        },
+        "api.FieldError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string",
+                    "example": "quantity"
+                },
+                "rule": {
+                    "type": "string",
+                    "example": "gt=0"
+                }
+            }
+        },
+        "api.HealthResponse": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "api.InfeasibleResponse": {
+            "type": "object",
+            "properties": {
+                "cached": {
+                    "description": "Cached is set when the failure was served from the infeasible cache.",
+                    "type": "boolean"
+                },
+                "code": {
+                    "type": "string",
+                    "example": "no_combination"
+                },
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.LabelsResponse": {
+            "type": "object",
+            "properties": {
+                "packs": {
+                    "description": "Packs labels the packs of each size, largest first.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "9 × 53-unit boxes"
+                    ]
+                },
+                "summary": {
+                    "description": "Summary labels the number of packs and the units they hold.",
+                    "type": "string",
+                    "example": "9 boxes, 477 units"
+                }
+            }
+        },
+        "api.OrderItemResponse": {
+            "type": "object",
+            "properties": {
+                "approximate": {
+                    "type": "boolean"
+                },
+                "cached": {
+                    "type": "boolean"
+                },
+                "computed_at": {
+                    "type": "string",
+                    "example": "2025-05-31T20:18:17Z"
+                },
+                "deprecated_packs": {
+                    "description": "DeprecatedPacks are the packs of sizes being phased out.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "flags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "requires_approval"
+                    ]
+                },
+                "labels": {
+                    "description": "Labels describes the packs for display, included with ?labels=true.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.LabelsResponse"
+                        }
+                    ]
+                },
+                "pack_count": {
+                    "type": "integer"
+                },
+                "packs": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "profile": {
+                    "type": "string"
+                },
+                "quantity": {
+                    "type": "integer"
+                },
+                "rules": {
+                    "description": "Rules and Flags are the allocation rules that held for the line.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.RuleMatchResponse"
+                    }
+                },
+                "sku": {
+                    "type": "string"
+                },
+                "source": {
+                    "type": "string",
+                    "enum": [
+                        "manual"
+                    ]
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "waste": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.OrderResponse": {
+            "type": "object",
+            "properties": {
+                "customer_id": {
+                    "type": "string"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.OrderItemResponse"
+                    }
+                },
+                "order_id": {
+                    "type": "string"
+                },
+                "summary": {
+                    "$ref": "#/definitions/api.OrderSummaryResponse"
+                }
+            }
+        },
+        "api.OrderSummaryResponse": {
+            "type": "object",
+            "properties": {
+                "approximate": {
+                    "type": "boolean"
+                },
+                "total_items": {
+                    "type": "integer"
+                },
+                "total_packs": {
+                    "type": "integer"
+                },
+                "total_quantity": {
+                    "type": "integer"
+                },
+                "total_waste": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.OutboxResponse": {
+            "type": "object",
+            "properties": {
+                "failed": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "pending": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "persisted": {
+                    "type": "integer",
+                    "example": 120
+                }
+            }
+        },
+        "api.PackSizeIssueResponse": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string",
+                    "example": "pack size 250 is listed more than once"
+                },
+                "profile": {
+                    "type": "string",
+                    "example": "default"
+                },
+                "rule": {
+                    "type": "string",
+                    "example": "duplicate"
+                },
+                "severity": {
+                    "type": "string",
+                    "example": "error"
+                }
+            }
+        },
+        "api.PinResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "packs": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "profile": {
+                    "type": "string",
+                    "example": "default"
+                },
+                "quantity": {
+                    "type": "integer",
+                    "example": 600
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "summer bundle"
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 750
+                }
+            }
+        },
+        "api.PinsResponse": {
+            "type": "object",
+            "properties": {
+                "pins": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.PinResponse"
+                    }
+                }
+            }
+        },
+        "api.PreStopResponse": {
+            "type": "object",
+            "properties": {
+                "in_flight": {
+                    "type": "integer"
+                },
+                "stopping": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "api.ProfileDefaultsResponse": {
+            "type": "object",
+            "properties": {
+                "strategy": {
+                    "type": "string",
+                    "example": "dp"
+                },
+                "timeout": {
+                    "description": "Timeout is the soft timeout of the profile's calculations.",
+                    "type": "string",
+                    "example": "2s"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "weights": {
+                    "$ref": "#/definitions/api.WeightsResponse"
+                }
+            }
+        },
+        "api.ProfileGuardrailResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "impact": {
+                    "$ref": "#/definitions/api.ProfileImpactResponse"
+                }
+            }
+        },
+        "api.ProfileImpactResponse": {
+            "type": "object",
+            "properties": {
+                "allocations": {
+                    "type": "integer"
+                },
+                "current": {
+                    "$ref": "#/definitions/api.SimulationTotalsResponse"
+                },
+                "current_sizes": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "current_waste_rate": {
+                    "type": "number"
+                },
+                "exceeded": {
+                    "type": "boolean"
+                },
+                "max_waste_increase": {
+                    "type": "number"
+                },
+                "updated": {
+                    "$ref": "#/definitions/api.SimulationTotalsResponse"
+                },
+                "waste_increase": {
+                    "type": "number"
+                },
+                "waste_rate": {
+                    "type": "number"
+                }
+            }
+        },
+        "api.ProfileResponse": {
+            "type": "object",
+            "properties": {
+                "defaults": {
+                    "description": "Defaults are omitted when the profile has none.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.ProfileDefaultsResponse"
+                        }
+                    ]
+                },
+                "deprecated": {
+                    "description": "Deprecated lists the sizes being phased out, which allocations avoid\nunless they are needed for the least waste.",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "dimensions": {
+                    "description": "Dimensions maps pack size to its dimensions, for carton fitting.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/api.DimensionsResponse"
+                    }
+                },
+                "limits": {
+                    "description": "Limits caps the packs of each size per allocation.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "name": {
+                    "type": "string",
+                    "example": "default"
+                },
+                "pack_sizes": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "skus": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "version": {
+                    "description": "Version is omitted when no profile version is recorded.",
+                    "type": "integer"
+                }
+            }
+        },
+        "api.ProfileUpdateResponse": {
+            "type": "object",
+            "properties": {
+                "impact": {
+                    "$ref": "#/definitions/api.ProfileImpactResponse"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "pack_sizes": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.ProfileValidationResponse": {
+            "type": "object",
+            "properties": {
+                "issues": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.PackSizeIssueResponse"
+                    }
+                },
+                "valid": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "api.ProfileVersionResponse": {
+            "type": "object",
+            "properties": {
+                "effective_from": {
+                    "type": "string"
+                },
+                "effective_to": {
+                    "type": "string"
+                },
+                "pack_sizes": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.ProfileVersionsResponse": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "versions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ProfileVersionResponse"
+                    }
+                }
+            }
+        },
+        "api.PurgeResponse": {
+            "type": "object",
+            "properties": {
+                "purged": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "api.QuotaResponse": {
+            "type": "object",
+            "properties": {
+                "compute_seconds": {
+                    "type": "number",
+                    "example": 3600
+                },
+                "requests": {
+                    "type": "integer",
+                    "example": 100000
+                }
+            }
+        },
+        "api.ReadOnlyResponse": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean"
+                },
+                "mode": {
+                    "type": "string",
+                    "enum": [
+                        "skip",
+                        "reject"
+                    ]
+                }
+            }
+        },
+        "api.ReadinessResponse": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/api.CheckResponse"
+                    }
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "ok",
+                        "warning",
+                        "critical",
+                        "stopping"
+                    ],
+                    "example": "ok"
+                }
+            }
+        },
+        "api.RecentResponse": {
+            "type": "object",
+            "properties": {
+                "allocations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/storage.Allocation"
+                    }
+                },
+                "limit": {
+                    "type": "integer",
+                    "example": 10
+                },
+                "total": {
+                    "description": "Total counts every stored allocation, not just those listed.",
+                    "type": "integer",
+                    "example": 1200
+                }
+            }
+        },
+        "api.RuleMatchResponse": {
+            "type": "object",
+            "properties": {
+                "flags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "requires_approval"
+                    ]
+                },
+                "note": {
+                    "type": "string",
+                    "example": "Waste above 10%: check the pack sizes"
+                },
+                "rule": {
+                    "type": "string",
+                    "example": "high_waste"
+                }
+            }
+        },
+        "api.SearchResponse": {
+            "type": "object",
+            "properties": {
+                "allocations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/storage.Allocation"
+                    }
+                },
+                "limit": {
+                    "type": "integer",
+                    "example": 100
+                },
+                "offset": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "total": {
+                    "description": "Total counts every matching allocation, not just this page.",
+                    "type": "integer",
+                    "example": 240
+                }
+            }
+        },
+        "api.ShadowMismatchResponse": {
+            "type": "object",
+            "properties": {
+                "approximate": {
+                    "type": "boolean"
+                },
+                "packs": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "profile": {
+                    "type": "string"
+                },
+                "quantity": {
+                    "type": "integer"
+                },
+                "shadow_approximate": {
+                    "type": "boolean"
+                },
+                "shadow_packs": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "shadow_strategy": {
+                    "type": "string"
+                },
+                "shadow_total": {
+                    "type": "integer"
+                },
+                "strategy": {
+                    "type": "string"
+                },
+                "time": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "verdict": {
+                    "type": "string",
+                    "enum": [
+                        "better",
+                        "worse",
+                        "tie"
+                    ]
+                }
+            }
+        },
+        "api.ShadowResponse": {
+            "type": "object",
+            "properties": {
+                "comparisons": {
+                    "description": "Comparisons counts shadow results compared with the results\nanswered, Mismatches those that differed and MismatchRate their\nshare. Errors counts shadow calculations that failed or timed out,\nSkipped those not run because too many were running.",
+                    "type": "integer",
+                    "example": 1200
+                },
+                "errors": {
+                    "type": "integer"
+                },
+                "mismatch_rate": {
+                    "type": "number",
+                    "example": 0.0025
+                },
+                "mismatches": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "rate": {
+                    "type": "number",
+                    "example": 0.05
+                },
+                "recent": {
+                    "description": "Recent holds the latest mismatches, newest first.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ShadowMismatchResponse"
+                    }
+                },
+                "skipped": {
+                    "type": "integer"
+                },
+                "strategy": {
+                    "description": "Strategy is the strategy run in shadow, empty when shadowing is\ndisabled, and Rate the fraction of calculations it runs for.",
+                    "type": "string",
+                    "example": "dp"
+                }
+            }
+        },
+        "api.SimulationOutcomeResponse": {
+            "type": "object",
+            "properties": {
+                "pack_count": {
+                    "type": "integer"
+                },
+                "packs": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "waste": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.SimulationResponse": {
+            "type": "object",
+            "properties": {
+                "baseline_sizes": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "candidate_sizes": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.SimulationRowResponse"
+                    }
+                },
+                "summary": {
+                    "$ref": "#/definitions/api.SimulationSummaryResponse"
+                }
+            }
+        },
+        "api.SimulationRowResponse": {
+            "type": "object",
+            "properties": {
+                "baseline": {
+                    "$ref": "#/definitions/api.SimulationOutcomeResponse"
+                },
+                "candidate": {
+                    "$ref": "#/definitions/api.SimulationOutcomeResponse"
+                },
+                "pack_delta": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "integer"
+                },
+                "waste_delta": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.SimulationSummaryResponse": {
+            "type": "object",
+            "properties": {
+                "baseline": {
+                    "$ref": "#/definitions/api.SimulationTotalsResponse"
+                },
+                "candidate": {
+                    "$ref": "#/definitions/api.SimulationTotalsResponse"
+                },
+                "pack_delta": {
+                    "type": "integer"
+                },
+                "waste_delta": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.SimulationTotalsResponse": {
+            "type": "object",
+            "properties": {
+                "avg_packs": {
+                    "type": "number"
+                },
+                "avg_waste": {
+                    "type": "number"
+                },
+                "total_packs": {
+                    "type": "integer"
+                },
+                "total_waste": {
+                    "type": "integer"
+                },
+                "zero_waste_count": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.StatsResponse": {
+            "type": "object",
+            "properties": {
+                "algorithms": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.AlgorithmStatsEntry"
+                    }
+                },
+                "allocations": {
+                    "type": "integer",
+                    "example": 1200
+                },
+                "approximate": {
+                    "description": "Approximate counts allocations returned before being proven optimal,\nFallbacks those of them computed by the greedy fallback after the\nsoft timeout. The rates are their share of Allocations.",
+                    "type": "integer",
+                    "example": 12
+                },
+                "approximate_rate": {
+                    "type": "number",
+                    "example": 0.01
+                },
+                "deprecated": {
+                    "description": "Deprecated counts the packs of deprecated sizes shipped, to follow\nthe drain of their stock.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.DeprecatedUsageEntry"
+                    }
+                },
+                "fallback_rate": {
+                    "type": "number",
+                    "example": 0.0075
+                },
+                "fallbacks": {
+                    "type": "integer",
+                    "example": 9
+                },
+                "waste": {
+                    "description": "Waste sums the items shipped beyond the quantities ordered, and\nWasteRate is its share of the items ordered. WasteBy breaks it down\nby the group_by parameter.",
+                    "type": "integer",
+                    "example": 4800
+                },
+                "waste_by": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.WasteStatsEntry"
+                    }
+                },
+                "waste_rate": {
+                    "type": "number",
+                    "example": 0.012
+                }
+            }
+        },
+        "api.SuggestResponse": {
+            "type": "object",
+            "properties": {
+                "above": {
+                    "$ref": "#/definitions/api.SuggestedQuantityResponse"
+                },
+                "below": {
+                    "$ref": "#/definitions/api.SuggestedQuantityResponse"
+                },
+                "exact": {
+                    "type": "boolean"
+                },
+                "profile": {
+                    "type": "string",
+                    "example": "default"
+                },
+                "quantity": {
+                    "type": "integer",
+                    "example": 600
+                }
+            }
+        },
+        "api.SuggestedQuantityResponse": {
+            "type": "object",
+            "properties": {
+                "packs": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "quantity": {
+                    "type": "integer",
+                    "example": 750
+                }
+            }
+        },
+        "api.UsageResponse": {
+            "type": "object",
+            "properties": {
+                "clients": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ClientUsageResponse"
+                    }
+                },
+                "metering": {
+                    "description": "Metering is false when usage is not being recorded.",
+                    "type": "boolean"
+                },
+                "month": {
+                    "type": "string",
+                    "example": "2025-06"
+                }
+            }
+        },
+        "api.ValidationErrorResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "invalid_body"
+                },
+                "error": {
+                    "type": "string",
+                    "example": "invalid request body"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.FieldError"
+                    }
+                }
+            }
+        },
+        "api.WasteStatsEntry": {
+            "type": "object",
+            "properties": {
+                "allocations": {
+                    "type": "integer",
+                    "example": 900
+                },
+                "group": {
+                    "type": "string",
+                    "example": "dp"
+                },
+                "max_waste": {
+                    "description": "MaxWaste is the largest waste of a single allocation.",
+                    "type": "integer",
+                    "example": 22
+                },
+                "quantity": {
+                    "type": "integer",
+                    "example": 300000
+                },
+                "waste": {
+                    "type": "integer",
+                    "example": 3600
+                },
+                "waste_rate": {
+                    "type": "number",
+                    "example": 0.012
+                }
+            }
+        },
+        "api.WeightsResponse": {
+            "type": "object",
+            "properties": {
+                "w_cost": {
+                    "type": "number",
+                    "example": 0
+                },
+                "w_packs": {
+                    "type": "number",
+                    "example": 100
+                },
+                "w_waste": {
+                    "type": "number",
+                    "example": 1
+                }
+            }
+        },
+        "api.batchRequest": {
+            "type": "object",
+            "required": [
+                "quantities"
+            ],
+            "properties": {
+                "profile": {
+                    "type": "string"
+                },
+                "quantities": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "api.calculateRequest": {
+            "type": "object",
+            "required": [
+                "quantity"
+            ],
+            "properties": {
+                "available": {
+                    "description": "Available is the stock per pack size for this request. Unlike\nconstraints.available, a quantity the stock cannot cover is not an\nerror: the stock is allocated and the rest reported as shortfall.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "constraints": {
+                    "$ref": "#/definitions/api.constraintsRequest"
+                },
+                "customer_id": {
+                    "type": "string"
+                },
+                "metadata": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "order_id": {
+                    "type": "string"
+                },
+                "quantity": {
+                    "description": "Quantity is a whole number of items, or decimal kilograms when Unit\nis weight, or a whole number of a unit configured under units, e.g.\ncases.",
+                    "type": "number"
+                },
+                "strategy": {
+                    "type": "string"
+                },
+                "unit": {
+                    "type": "string",
+                    "example": "units"
+                },
+                "weights": {
+                    "$ref": "#/definitions/api.weightsRequest"
+                }
+            }
+        },
+        "api.compareRequest": {
+            "type": "object",
+            "required": [
+                "sets"
+            ],
+            "properties": {
+                "quantity": {
+                    "type": "integer"
+                },
+                "sets": {
+                    "type": "array",
+                    "minItems": 2,
+                    "items": {
+                        "$ref": "#/definitions/api.packSizeSetRequest"
+                    }
+                },
+                "strategy": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.constraintsRequest": {
+            "type": "object",
+            "properties": {
+                "allow_shortfall": {
+                    "description": "AllowShortfall allocates what the constraints allow, reporting the\nrest as shortfall, instead of failing with 422.",
+                    "type": "boolean"
+                },
+                "available": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "costs": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "number"
+                    }
+                },
+                "max_counts": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "max_packs": {
+                    "type": "integer",
+                    "minimum": 0
+                }
+            }
+        },
+        "api.orderItem": {
+            "type": "object",
+            "properties": {
+                "profile": {
+                    "type": "string"
+                },
+                "quantity": {
+                    "type": "integer"
+                },
+                "sku": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.orderRequest": {
+            "type": "object",
+            "required": [
+                "items"
+            ],
+            "properties": {
+                "customer_id": {
+                    "type": "string"
+                },
+                "items": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/api.orderItem"
+                    }
+                },
+                "metadata": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "order_id": {
+                    "type": "string"
+                },
+                "strategy": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.packSizeSetRequest": {
+            "type": "object",
+            "required": [
+                "pack_sizes"
+            ],
+            "properties": {
+                "costs": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "number"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "pack_sizes": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "api.pinRequest": {
+            "type": "object",
+            "required": [
+                "packs"
+            ],
+            "properties": {
+                "packs": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "profile": {
+                    "type": "string",
+                    "example": "default"
+                },
+                "quantity": {
+                    "type": "integer",
+                    "example": 600
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "summer bundle"
+                }
+            }
+        },
+        "api.profileDefaultsRequest": {
+            "type": "object",
+            "properties": {
+                "strategy": {
+                    "type": "string",
+                    "example": "dp"
+                },
+                "timeout": {
+                    "description": "Timeout is a duration such as \"2s\" replacing the soft timeout.",
+                    "type": "string",
+                    "example": "2s"
+                },
+                "weights": {
+                    "$ref": "#/definitions/api.weightsRequest"
+                }
+            }
+        },
+        "api.profileUpdateRequest": {
+            "type": "object",
+            "required": [
+                "pack_sizes"
+            ],
+            "properties": {
+                "pack_sizes": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "api.readOnlyRequest": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean"
+                },
+                "mode": {
+                    "type": "string",
+                    "enum": [
+                        "skip",
+                        "reject"
+                    ]
+                }
+            }
+        },
+        "api.simulateRequest": {
+            "type": "object",
+            "required": [
+                "candidate"
+            ],
+            "properties": {
+                "baseline": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "candidate": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "from": {
+                    "type": "string"
+                },
+                "quantity": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "strategy": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.weightsRequest": {
+            "type": "object",
+            "properties": {
+                "w_cost": {
+                    "type": "number",
+                    "minimum": 0,
+                    "example": 0
+                },
+                "w_packs": {
+                    "type": "number",
+                    "minimum": 0,
+                    "example": 100
+                },
+                "w_waste": {
+                    "type": "number",
+                    "minimum": 0,
+                    "example": 1
+                }
+            }
+        },
+        "storage.Allocation": {
+            "type": "object",
+            "properties": {
+                "Algorithm": {
+                    "description": "Algorithm names what produced the allocation: a strategy such as\n\"dp\", \"greedy\" after a timeout fallback, \"cache\", \"manual\" or\nAlgorithmImport. It is empty for allocations stored before it was\nrecorded.",
+                    "type": "string"
+                },
+                "Approximate": {
+                    "type": "boolean"
+                },
+                "CreatedAt": {
+                    "type": "string"
+                },
+                "CustomerID": {
+                    "type": "string"
+                },
+                "DeletedAt": {
+                    "description": "DeletedAt is set on allocations soft-deleted with DeleteAllocation.",
+                    "type": "string"
+                },
+                "Hits": {
+                    "description": "Hits counts how often the allocation was stored: above one only with\nWriteDedup, where LastAccessedAt is when it was last stored.",
+                    "type": "integer"
+                },
+                "ID": {
+                    "type": "integer"
+                },
+                "LastAccessedAt": {
+                    "type": "string"
+                },
+                "Metadata": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "OrderID": {
+                    "type": "string"
+                },
+                "OrderQuantity": {
+                    "type": "integer"
+                },
+                "Packs": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "Profile": {
+                    "type": "string"
+                },
+                "ProfileVersion": {
+                    "type": "integer"
+                },
+                "RequestedQuantity": {
+                    "type": "integer"
+                },
+                "Rules": {
+                    "description": "Rules are the allocation rules that held for the allocation when it\nwas calculated.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/storage.RuleMatch"
+                    }
+                },
+                "Source": {
+                    "description": "Source is \"manual\" for allocations served from a pin and empty for\ncomputed ones.",
+                    "type": "string"
+                },
+                "Total": {
+                    "type": "integer"
+                },
+                "Unit": {
+                    "description": "Unit is the unit the quantity was requested in, and\nRequestedQuantity the quantity in that unit, before OrderQuantity\nitems were derived from it. Both are empty for quantities requested\nin items.",
+                    "type": "string"
+                }
+            }
+        },
+        "storage.AuditEntry": {
+            "type": "object",
+            "properties": {
+                "actor": {
+                    "description": "Actor identifies the caller, e.g. \"user:alice\", \"key:3f2a9c1b0d4e\" or \"anonymous\".",
+                    "type": "string"
+                },
+                "client_ip": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "duration_ms": {
+                    "type": "integer"
+                },
+                "error": {
+                    "description": "Error is the error message of failed requests.",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "method": {
+                    "type": "string"
+                },
+                "params": {
+                    "description": "Params holds the query string and request body, truncated.",
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "integer"
+                }
+            }
+        },
+        "storage.PruneResult": {
+            "type": "object",
+            "properties": {
+                "excess": {
+                    "description": "Excess counts allocations removed for exceeding MaxRows.",
+                    "type": "integer"
+                },
+                "expired": {
+                    "description": "Expired counts allocations removed for exceeding MaxAge.",
+                    "type": "integer"
+                }
+            }
+        },
+        "storage.RuleMatch": {
+            "type": "object",
+            "properties": {
+                "Flags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "Note": {
+                    "type": "string"
+                },
+                "Rule": {
+                    "type": "string"
+                }
+            }
         }
     }
 }`
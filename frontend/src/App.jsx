@@ -31,7 +31,7 @@ function App() {
     setError(null);
 
     try {
-      const response = await axios.get(`/calculate?quantity=${quantity}`);
+      const response = await axios.get(`/v1/calculate?quantity=${quantity}`);
       setResult(response.data);
       setQuantity('');
     } catch (err) {
@@ -6,11 +6,7 @@ export default defineConfig({
   server: {
     port: 3000,
     proxy: {
-      "/calculate": {
-        target: "http://localhost:8080",
-        changeOrigin: true,
-      },
-      "/recent": {
+      "/v1": {
         target: "http://localhost:8080",
         changeOrigin: true,
       },
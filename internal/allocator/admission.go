@@ -0,0 +1,126 @@
+package allocator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+var ErrOverloaded = errors.New("too many calculations in progress")
+
+// Admission sheds calculations under load. Only calculations that run a
+// strategy count: results served from the result cache, pins and the
+// infeasible cache are never shed, so cheap requests keep their latency
+// while expensive ones are turned away.
+type Admission struct {
+	// MaxInFlight is how many calculations may run at once. Zero disables
+	// admission control.
+	MaxInFlight int
+	// MaxQueue is how many calculations may wait for one to finish; any
+	// more are shed at once.
+	MaxQueue int
+	// MaxWait is how long a calculation waits in the queue before it is
+	// shed. Zero waits until the request is cancelled or times out.
+	MaxWait time.Duration
+	// RetryAfter is how long shed clients are told to wait before retrying.
+	RetryAfter time.Duration
+}
+
+// OverloadedError reports a shed calculation.
+// It matches ErrOverloaded with errors.Is.
+type OverloadedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *OverloadedError) Error() string {
+	return ErrOverloaded.Error() + ", retry later"
+}
+
+// Is makes errors.Is(err, ErrOverloaded) true for shed calculations.
+func (e *OverloadedError) Is(target error) bool {
+	return target == ErrOverloaded
+}
+
+// AdmissionStats reports the load seen by admission control.
+type AdmissionStats struct {
+	InFlight int
+	Queued   int
+	// Shed counts the calculations rejected since startup.
+	Shed int64
+}
+
+// admission tracks the running and queued calculations.
+type admission struct {
+	Admission
+	slots  chan struct{}
+	queued atomic.Int64
+	shed   atomic.Int64
+}
+
+// SetAdmission enables admission control. It must be called before the
+// allocator is used concurrently.
+func (a *Allocator) SetAdmission(ad Admission) error {
+	if ad.MaxInFlight < 0 || ad.MaxQueue < 0 || ad.MaxWait < 0 || ad.RetryAfter < 0 {
+		return fmt.Errorf("admission settings must not be negative")
+	}
+	if ad.MaxInFlight == 0 {
+		a.admission = nil
+		return nil
+	}
+	a.admission = &admission{Admission: ad, slots: make(chan struct{}, ad.MaxInFlight)}
+	return nil
+}
+
+// AdmissionStats returns the current load, all zero when admission control
+// is disabled.
+func (a *Allocator) AdmissionStats() AdmissionStats {
+	g := a.admission
+	if g == nil {
+		return AdmissionStats{}
+	}
+	return AdmissionStats{InFlight: len(g.slots), Queued: int(g.queued.Load()), Shed: g.shed.Load()}
+}
+
+// admit waits for a calculation slot, queueing if all are taken. It returns
+// an *OverloadedError when the queue is full or the wait too long, and the
+// context's error if it is done first. The caller must call release once the
+// calculation finishes.
+func (a *Allocator) admit(ctx context.Context) (release func(), err error) {
+	g := a.admission
+	if g == nil {
+		return func() {}, nil
+	}
+	release = func() { <-g.slots }
+	select {
+	case g.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	if g.queued.Add(1) > int64(g.MaxQueue) {
+		g.queued.Add(-1)
+		return nil, g.reject()
+	}
+	defer g.queued.Add(-1)
+	var timeout <-chan time.Time
+	if g.MaxWait > 0 {
+		timer := time.NewTimer(g.MaxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case g.slots <- struct{}{}:
+		return release, nil
+	case <-timeout:
+		return nil, g.reject()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (g *admission) reject() error {
+	g.shed.Add(1)
+	return &OverloadedError{RetryAfter: g.RetryAfter}
+}
@@ -0,0 +1,94 @@
+package allocator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/n-th/gymshark/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdmission(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	RegisterStrategy("admission-blocking", StrategyFunc(func(ctx context.Context, quantity int, sizes []int) (Result, error) {
+		started <- struct{}{}
+		<-release
+		return backtrackingStrategy(ctx, quantity, sizes)
+	}))
+
+	ctx := context.Background()
+	a := NewAllocator([]int{250, 500, 1000}, newMockStorage())
+	assert.NoError(t, a.RecordProfileVersions())
+	assert.Error(t, a.SetAdmission(Admission{MaxInFlight: 1, MaxQueue: -1}))
+	assert.NoError(t, a.SetAdmission(Admission{MaxInFlight: 1, MaxQueue: 1, RetryAfter: 2 * time.Second}))
+	_, err := a.Pin(ctx, storage.Pin{Quantity: 600, Packs: map[int]int{250: 3}})
+	assert.NoError(t, err)
+
+	errs := make(chan error, 2)
+	// Distinct quantities, since identical calculations would be coalesced
+	allocate := func(quantity int) {
+		_, err := a.Preview(ctx, Request{Quantity: quantity, Strategy: "admission-blocking"})
+		errs <- err
+	}
+	go allocate(501)
+	<-started
+	go allocate(503)
+	assert.Eventually(t, func() bool { return a.AdmissionStats().Queued == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, AdmissionStats{InFlight: 1, Queued: 1}, a.AdmissionStats())
+
+	// With the slot taken and the queue full, calculations are shed
+	_, err = a.Allocate(ctx, Request{Quantity: 502, Strategy: "admission-blocking"})
+	var overloaded *OverloadedError
+	assert.True(t, errors.As(err, &overloaded))
+	assert.True(t, errors.Is(err, ErrOverloaded))
+	assert.Equal(t, 2*time.Second, overloaded.RetryAfter)
+	_, err = a.Simulate(ctx, []int{502}, []int{250, 500, 1000}, []int{23, 31, 53}, "")
+	assert.ErrorIs(t, err, ErrOverloaded)
+
+	// Pinned allocations need no calculation
+	result, err := a.Allocate(ctx, Request{Quantity: 600})
+	assert.NoError(t, err)
+	assert.Equal(t, SourceManual, result.Source)
+
+	// The queued calculation runs once the slot is released
+	release <- struct{}{}
+	<-started
+	release <- struct{}{}
+	assert.NoError(t, <-errs)
+	assert.NoError(t, <-errs)
+	assert.Equal(t, AdmissionStats{Shed: 2}, a.AdmissionStats())
+}
+
+func TestAdmissionMaxWait(t *testing.T) {
+	a := NewAllocator([]int{250, 500, 1000}, nil)
+	assert.NoError(t, a.SetAdmission(Admission{MaxInFlight: 1, MaxQueue: 1, MaxWait: 10 * time.Millisecond}))
+	ctx := context.Background()
+
+	release, err := a.admit(ctx)
+	assert.NoError(t, err)
+	_, err = a.admit(ctx)
+	assert.ErrorIs(t, err, ErrOverloaded)
+
+	// A cancelled request leaves the queue without being counted as shed
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = a.admit(cancelled)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, AdmissionStats{InFlight: 1, Shed: 1}, a.AdmissionStats())
+
+	release()
+	release, err = a.admit(ctx)
+	assert.NoError(t, err)
+	release()
+
+	// Disabled admission control admits everything
+	assert.NoError(t, a.SetAdmission(Admission{}))
+	for i := 0; i < 3; i++ {
+		_, err := a.admit(ctx)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, AdmissionStats{}, a.AdmissionStats())
+}
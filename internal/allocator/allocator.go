@@ -3,299 +3,885 @@
 package allocator
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"log"
 	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/n-th/gymshark/internal/events"
+	"github.com/n-th/gymshark/internal/invalidation"
 	"github.com/n-th/gymshark/internal/storage"
 )
 
 var (
-	ErrInvalidQuantity      = errors.New("quantity must be greater than 0")
+	ErrInvalidQuantity      = invalid("quantity must be greater than 0")
 	ErrStorageNotConfigured = errors.New("storage not configured")
+	ErrNoPackSizes          = invalid("no pack sizes configured")
+	ErrUnknownProfile       = invalid("unknown pack size profile")
 )
 
+// DefaultProfile names the allocator's own pack sizes.
+const DefaultProfile = "default"
+
 type Pack struct {
 	Size     int
 	Quantity int
 }
 
 type Allocator struct {
-	packSizes []int
-	storage   storage.Storage
+	// current holds the pack sizes, profiles, versions and pack limits,
+	// which can change at runtime through UpdateProfile or invalidation
+	// events; configMu serializes the changes. See snapshot.
+	configMu sync.Mutex
+	current  atomic.Pointer[snapshot]
+	storage  storage.History
+	// cache serves the result cache. It is the storage unless SetCache
+	// configured a separate backend, in which case ownCache is set.
+	cache       storage.Cache
+	ownCache    bool
+	strategy    string
+	softTimeout time.Duration
+	hardTimeout time.Duration
+	negative    *outcomeCache
+	limits      Limits
+	units       map[string]Unit
+	metering    Metering
+	admission   *admission
+	retention   storage.RetentionPolicy
+	readOnlyMu  sync.RWMutex
+	readOnly    ReadOnly
+	bus         invalidation.Bus
+	origin      string
+	outbox      *outbox
+	observer    Observer
+	events      events.Publisher
+	notifier    Notifier
+	anomalies   AnomalyPolicy
+	resultCache bool
+	// pinsMu guards pins, which is replaced rather than modified.
+	pinsMu sync.RWMutex
+	pins   map[pinKey]storage.Pin
+	// inFlight coalesces identical concurrent calculations.
+	inFlight flights
+	// costModel, when set, chooses strategies and deadlines by estimated
+	// cost; see SetCostModel.
+	costModel CostModel
+	// shadow, when set, runs a second strategy on a sample of
+	// calculations; see SetShadow.
+	shadow *shadowRunner
+	// guardrail limits the rise in waste of profile updates; see
+	// SetProfileGuardrail.
+	guardrail ProfileGuardrail
+	// rules post-process results; see SetAllocationRules.
+	rules []AllocationRule
 }
 
 func NewAllocator(packSizes []int, s storage.Storage) *Allocator {
-	if packSizes == nil {
-		packSizes = []int{}
+	a := &Allocator{
+		strategy: DefaultStrategy,
+		readOnly: ReadOnly{Mode: ReadOnlySkip},
+		outbox: newOutbox(OutboxOptions{
+			Capacity:    DefaultOutboxCapacity,
+			MaxAttempts: DefaultOutboxMaxAttempts,
+		}),
+	}
+	a.current.Store(&snapshot{packSizes: sortedSizes(packSizes)})
+	// A nil Storage must leave both interfaces nil, not holding a nil value.
+	if s != nil {
+		a.storage = s
+		a.cache = s
 	}
+	return a
+}
+
+// sortedSizes returns a copy of sizes sorted in descending order.
+func sortedSizes(packSizes []int) []int {
 	sizes := make([]int, len(packSizes))
 	copy(sizes, packSizes)
 	sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
-	return &Allocator{packSizes: sizes, storage: s}
+	return sizes
 }
 
-// CalculatePacksOptimized calculates the optimal pack distribution for a given quantity
-// using the stored pack sizes.
-// It returns the pack distribution, the total quantity, and an error if the quantity is invalid.
-func (a *Allocator) CalculatePacksOptimized(quantity int) (map[int]int, int, error) {
+// SetProfiles registers named pack-size profiles and the SKUs that use them.
+// SKUs without a profile, and requests without one, use the default sizes.
+func (a *Allocator) SetProfiles(profiles map[string][]int, skuProfiles map[string]string) error {
+	sorted := make(map[string][]int, len(profiles)+1)
+	for name, sizes := range profiles {
+		if name == WeightProfile {
+			return fmt.Errorf("profile %q is reserved for weight pack sizes", name)
+		}
+		if len(sizes) == 0 {
+			return fmt.Errorf("profile %q: %w", name, ErrNoPackSizes)
+		}
+		sorted[name] = sortedSizes(sizes)
+	}
+	for sku, name := range skuProfiles {
+		if _, ok := sorted[name]; !ok && name != DefaultProfile {
+			return fmt.Errorf("sku %q: %w: %q", sku, ErrUnknownProfile, name)
+		}
+	}
 
-	if quantity <= 0 {
-		return nil, 0, ErrInvalidQuantity
+	return a.updateConfig(func(next *snapshot) error {
+		if weight, ok := next.profiles[WeightProfile]; ok {
+			sorted[WeightProfile] = weight
+		}
+		next.profiles = sorted
+		next.skuProfiles = skuProfiles
+		return nil
+	})
+}
+
+// RecordProfileVersions stores the current pack sizes of the default and all
+// named profiles as versioned snapshots, so every allocation made afterwards
+// can be traced back to the profile version that produced it.
+// While read-only, nothing is written and the latest stored versions are used.
+func (a *Allocator) RecordProfileVersions() error {
+	if a.storage == nil {
+		return ErrStorageNotConfigured
 	}
 
-	if a.storage != nil {
-		if cached, err := a.storage.GetAllocationByQuantity(quantity); err == nil && cached != nil {
-			log.Printf("Using cached result for quantity %d", quantity)
-			return cached.Packs, cached.Total, nil
+	return a.updateConfig(func(next *snapshot) error {
+		versions := make(map[string]int, len(next.profiles)+1)
+		record := func(name string, sizes []int) error {
+			if len(sizes) == 0 {
+				return nil
+			}
+			v, err := a.recordProfileVersion(name, sizes)
+			if err != nil {
+				return err
+			}
+			if v > 0 {
+				versions[name] = v
+			}
+			return nil
+		}
+
+		if err := record(DefaultProfile, next.packSizes); err != nil {
+			return err
+		}
+		for name, sizes := range next.profiles {
+			if err := record(name, sizes); err != nil {
+				return err
+			}
 		}
+
+		next.versions = versions
+		return nil
+	})
+}
+
+// recordProfileVersion stores sizes as the latest version of a profile and
+// returns its version number. While read-only, nothing is written and the
+// latest stored version, or zero if there is none, is returned.
+func (a *Allocator) recordProfileVersion(name string, sizes []int) (int, error) {
+	if a.ReadOnly().Enabled {
+		history, err := a.storage.GetProfileVersions(name)
+		if err != nil {
+			return 0, fmt.Errorf("read profile %q: %w", name, err)
+		}
+		if n := len(history); n > 0 {
+			return history[n-1].Version, nil
+		}
+		return 0, nil
+	}
+	v, err := a.storage.RecordProfileVersion(name, sizes)
+	if err != nil {
+		return 0, fmt.Errorf("record profile %q: %w", name, err)
 	}
+	return v.Version, nil
+}
 
-	maxPackSize := a.packSizes[0]
-	maxPossibleWaste := maxPackSize * 1000000 // arbitrarily large upper bound
+// ProfileVersions returns the version history of a profile.
+func (a *Allocator) ProfileVersions(name string) ([]storage.ProfileVersion, error) {
+	if a.storage == nil {
+		return nil, ErrStorageNotConfigured
+	}
+	return a.storage.GetProfileVersions(name)
+}
+
+// Profile describes the current state of a pack-size profile.
+type Profile struct {
+	Name      string
+	PackSizes []int
+	// Version is the recorded profile version, zero if none is recorded.
+	Version int
+	// Limits caps the packs of each size per allocation; see SetPackLimits.
+	Limits map[int]int
+	// Deprecated lists the sizes being phased out; see SetDeprecatedSizes.
+	Deprecated []int
+	// Dimensions are the pack dimensions; see SetPackDimensions.
+	Dimensions map[int]Dimensions
+	// SKUs lists the SKUs mapped to the profile, sorted.
+	SKUs []string
+	// Defaults are the calculation defaults; see SetProfileDefaults.
+	Defaults ProfileDefaults
+}
 
-	best := struct {
-		packs     map[int]int
-		total     int
-		waste     int
-		packCount int
-		found     bool
-	}{
-		waste: maxPossibleWaste,
+// Profiles returns the default profile followed by the named profiles in
+// name order. The weight profile is not included.
+func (a *Allocator) Profiles() []Profile {
+	cfg := a.config()
+	skus := make(map[string][]string)
+	for sku, name := range cfg.skuProfiles {
+		skus[name] = append(skus[name], sku)
+	}
+	profile := func(name string, sizes []int) Profile {
+		sort.Strings(skus[name])
+		return Profile{
+			Name:       name,
+			PackSizes:  sizes,
+			Version:    cfg.versions[name],
+			Limits:     cfg.packLimits[name],
+			Deprecated: cfg.deprecatedSizes[name],
+			Dimensions: cfg.packDimensions[name],
+			SKUs:       skus[name],
+			Defaults:   cfg.profileDefaults(name),
+		}
 	}
 
-	a.findOptimal(quantity, 0, map[int]int{}, 0, 0, &best)
+	profiles := []Profile{profile(DefaultProfile, cfg.packSizes)}
+	names := make([]string, 0, len(cfg.profiles))
+	for name := range cfg.profiles {
+		if name != WeightProfile {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		profiles = append(profiles, profile(name, cfg.profiles[name]))
+	}
+	return profiles
+}
 
-	if !best.found {
-		return nil, 0, errors.New("no valid pack combination found")
+// ProfileForSKU returns the profile name configured for a SKU.
+func (a *Allocator) ProfileForSKU(sku string) string {
+	if name, ok := a.config().skuProfiles[sku]; ok {
+		return name
 	}
+	return DefaultProfile
+}
 
-	if a.storage != nil {
-		if err := a.storage.StoreAllocation(quantity, best.packs, best.total); err != nil {
-			log.Printf("Failed to store allocation: %v", err)
+// SetStrategy changes the strategy used when a calculation does not name one.
+// It returns ErrUnknownStrategy if no strategy is registered under name.
+func (a *Allocator) SetStrategy(name string) error {
+	if _, err := LookupStrategy(name); err != nil {
+		return err
+	}
+	a.strategy = name
+	return nil
+}
+
+// Strategy returns the name of the default strategy.
+func (a *Allocator) Strategy() string {
+	return a.strategy
+}
 
+// SetTimeouts configures calculation deadlines.
+// When a strategy runs longer than soft, the allocator abandons it and returns
+// an approximate greedy result instead. Calculations still running after hard
+// are cancelled through their context. A zero duration disables that deadline.
+func (a *Allocator) SetTimeouts(soft, hard time.Duration) {
+	a.softTimeout = soft
+	a.hardTimeout = hard
+}
+
+// SetNegativeCacheTTL enables caching of unfulfillable and invalid requests
+// for ttl, so repeated requests fail fast instead of rerunning the search.
+// A zero ttl disables the cache.
+func (a *Allocator) SetNegativeCacheTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		a.negative = nil
+		return
+	}
+	a.negative = newOutcomeCache(ttl)
+}
+
+// Request describes a single calculation.
+type Request struct {
+	Quantity int
+	// Strategy names the allocation strategy; empty selects the default.
+	Strategy string
+	// Profile names the pack-size profile; empty selects the default sizes.
+	Profile string
+	// Constraints, when set, restrict the combinations considered and
+	// require the branch-and-bound strategy.
+	Constraints *Constraints
+	// OrderID, CustomerID and Metadata are persisted with the result so
+	// allocations can be reconciled with the originating order.
+	OrderID    string
+	CustomerID string
+	Metadata   map[string]interface{}
+	// AsOf, when set, calculates with the pack sizes the profile had at that
+	// time, from its recorded versions, e.g. to reprocess old orders. Pins
+	// and pack limits, which are not versioned, do not apply.
+	AsOf time.Time
+	// Unit names the unit configured with SetUnits that Quantity is in;
+	// empty means items. The quantity is converted to items before it is
+	// allocated, and both are stored with the result.
+	Unit string
+	// requested is Quantity in Unit, once converted by normalize.
+	requested int
+}
+
+// Allocate computes the pack distribution for a request.
+// Successful results are persisted to storage when it is configured.
+// Calculations as of a past time are previews: they are not persisted.
+func (a *Allocator) Allocate(ctx context.Context, req Request) (Result, error) {
+	if !req.AsOf.IsZero() {
+		return a.Preview(ctx, req)
+	}
+	if err := a.checkWritable(); err != nil {
+		return Result{}, err
+	}
+	req, err := a.normalize(req)
+	if err != nil {
+		return Result{}, err
+	}
+	cfg := a.config()
+	result, err := a.preview(ctx, cfg, req)
+	if err != nil {
+		return Result{}, err
+	}
+	a.store(computed{req, result, cfg.version(req.Profile)})
+	a.observe(req, result)
+	a.notifyAnomaly(req, result)
+	a.emitAllocation(ctx, req, result, cfg.version(req.Profile))
+	a.runShadow(cfg, req, result)
+	return result, nil
+}
+
+// Preview computes the pack distribution for a request exactly as Allocate
+// does, but without persisting the result.
+func (a *Allocator) Preview(ctx context.Context, req Request) (Result, error) {
+	req, err := a.normalize(req)
+	if err != nil {
+		return Result{}, err
+	}
+	cfg := a.config()
+	if !req.AsOf.IsZero() {
+		if cfg, err = a.asOf(cfg, req.Profile, req.AsOf); err != nil {
+			return Result{}, err
 		}
 	}
+	return a.preview(ctx, cfg, req)
+}
+
+// preview computes the pack distribution for a request with the sizes,
+// limits and version of cfg, reporting the packs of sizes deprecated now.
+func (a *Allocator) preview(ctx context.Context, cfg *snapshot, req Request) (Result, error) {
+	result, err := a.previewPacks(ctx, cfg, req)
+	if err != nil {
+		return Result{}, err
+	}
+	result.DeprecatedPacks = deprecatedPacks(result.Packs, a.config().deprecated(req.Profile))
+	result.Rules = a.applyRules(cfg, req, result)
+	return result, nil
+}
+
+// previewPacks computes the pack distribution for preview.
+func (a *Allocator) previewPacks(ctx context.Context, cfg *snapshot, req Request) (Result, error) {
+	defaults := cfg.profileDefaults(req.Profile)
+	name := req.Strategy
+	if name == "" {
+		name = defaults.Strategy
+	}
+	if name == "" {
+		name = a.strategy
+	}
+	log.Printf("Calculating packs for order quantity %d using %s strategy", req.Quantity, name)
+	if req.Quantity <= 0 {
+		return Result{}, ErrInvalidQuantity
+	}
+	if err := a.checkQuantity(req.Quantity); err != nil {
+		return Result{}, err
+	}
+
+	sizes, err := cfg.sizes(req.Profile)
+	if err != nil {
+		return Result{}, err
+	}
+	if len(sizes) == 0 {
+		return Result{}, ErrNoPackSizes
+	}
 
-	return best.packs, best.total, nil
-}
-
-// findOptimal is a helper function that finds the optimal pack distribution
-// for a given quantity using a recursive backtracking approach.
-func (a *Allocator) findOptimal(target, index int, current map[int]int, total, packCount int, best *struct {
-	packs     map[int]int
-	total     int
-	waste     int
-	packCount int
-	found     bool
-}) {
-	if total >= target {
-		waste := total - target
-		if !best.found || waste < best.waste || (waste == best.waste && packCount < best.packCount) {
-			best.found = true
-			best.total = total
-			best.waste = waste
-			best.packCount = packCount
-			best.packs = cloneMap(current)
+	start := time.Now()
+	if req.Constraints.empty() && req.AsOf.IsZero() {
+		if result, ok := a.pinned(req.Quantity, req.Profile); ok {
+			result.Stats.Duration = time.Since(start)
+			return result, nil
 		}
-		return
 	}
 
-	if index >= len(a.packSizes) {
-		return
+	// The profile's weights only apply to requests leaving the strategy to
+	// the allocator, since other strategies cannot weigh combinations.
+	constraints := req.Constraints
+	if req.Strategy == "" {
+		constraints = defaults.withWeights(constraints)
+	}
+	if constraints = constraints.withMaxCounts(cfg.limits(req.Profile)); !constraints.empty() {
+		result, err := a.allocateConstrained(ctx, req, constraints, sizes, defaults.softTimeout(a.softTimeout))
+		result.Stats.Strategy = ConstrainedStrategy
+		result.Stats.Cache = CacheBypass
+		result.Stats.Duration = time.Since(start)
+		result.ComputedAt = time.Now()
+		return result, err
+	}
+
+	strategy, err := LookupStrategy(name)
+	if err != nil {
+		return Result{}, err
+	}
 
+	key := infeasibleKey(name, sizes, req.Quantity)
+	if cached, ok := a.negative.get(key); ok {
+		infeasible := *cached.(*InfeasibleError)
+		infeasible.Cached = true
+		return Result{}, &infeasible
 	}
 
-	size := a.packSizes[index]
-	maxQty := (target - total + size - 1) / size // minimal fill
+	deprecated := cfg.deprecated(req.Profile)
+	if a.resultCache && name == a.strategy {
+		// Results stored before sizes they use were deprecated are
+		// recomputed, to avoid those sizes.
+		if result, ok := a.storedResult(cfg, req.Quantity, req.Profile); ok && deprecatedPacks(result.Packs, deprecated) == nil {
+			result.Stats.Strategy = name
+			result.Stats.Duration = time.Since(start)
+			return result, nil
+		}
+	}
 
-	for q := maxQty; q >= 0; q-- {
-		if q > 0 {
-			current[size] = q
-		} else {
-			delete(current, size)
+	p := a.plan(req.Strategy, req.Quantity, sizes, defaults)
+	if p.strategy != name {
+		name = p.strategy
+		if strategy, err = LookupStrategy(name); err != nil {
+			return Result{}, err
 		}
-		a.findOptimal(target, index+1, current, total+q*size, packCount+q, best)
 	}
+	// The greedy strategy only approximates, so it is not rebalanced.
+	if len(deprecated) > 0 && name != FallbackStrategy {
+		strategy = avoidingDeprecated{strategy, deprecated}
+	}
+
+	// Identical calculations already in progress are joined rather than
+	// repeated; see CacheShared.
+	flight := flightKey(name, sizes, req.Quantity, deprecated, p.deadline)
+	result, shared, err := a.inFlight.do(ctx, flight, func(ctx context.Context) (Result, error) {
+		return a.run(ctx, strategy, req.Quantity, sizes, p.deadline)
+	})
+	if errors.Is(err, ErrNoCombination) {
+		infeasible := &InfeasibleError{Quantity: req.Quantity, Profile: req.Profile, Strategy: name}
+		a.negative.put(key, infeasible)
+		return Result{}, infeasible
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	if result.Stats.Strategy == "" {
+		result.Stats.Strategy = name
+	}
+	if p.degraded && name == FallbackStrategy {
+		result.Approximate = true
+	}
+	result.Stats.Estimate = p.estimate
+	result.Stats.Deadline = p.deadline
+	result.Stats.Cache = CacheMiss
+	if shared {
+		result.Stats.Cache = CacheShared
+	}
+	result.Stats.Duration = time.Since(start)
+	result.ComputedAt = time.Now()
+	return result, nil
+}
+
+// allocateConstrained solves a request under the request's constraints and
+// the profile's pack limits with branch-and-bound.
+// Past the soft timeout soft the best combination found so far is returned as
+// approximate; the greedy fallback is never used because it ignores constraints.
+// When no combination covers the quantity and the constraints allow a
+// shortfall, the largest packs they allow are returned instead.
+// Constrained outcomes depend on the constraints, so they are not cached.
+func (a *Allocator) allocateConstrained(ctx context.Context, req Request, constraints *Constraints, sizes []int, soft time.Duration) (Result, error) {
+	if req.Strategy != "" && req.Strategy != ConstrainedStrategy {
+		return Result{}, fmt.Errorf("%w: %q", ErrConstraintsUnsupported, req.Strategy)
+	}
+	if err := constraints.validate(); err != nil {
+		return Result{}, err
+	}
+	if err := checkOverflow(req.Quantity, sizes); err != nil {
+		return Result{}, err
+	}
+	release, err := a.admit(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	defer release()
+
+	if a.hardTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.hardTimeout)
+		defer cancel()
+	}
+	expired := make(chan struct{})
+	if soft > 0 {
+		timer := time.AfterFunc(soft, func() { close(expired) })
+		defer timer.Stop()
+	}
+
+	result, err := branchAndBound(ctx, expired, req.Quantity, sizes, *constraints)
+	if errors.Is(err, ErrNoCombination) && constraints.AllowShortfall {
+		return shortfallResult(req.Quantity, sizes, *constraints), nil
+	}
+	if errors.Is(err, ErrNoCombination) {
+		return Result{}, &InfeasibleError{Quantity: req.Quantity, Profile: req.Profile, Strategy: ConstrainedStrategy, Constrained: true}
+	}
+	return result, err
+}
+
+// run executes a strategy under the soft deadline and the configured hard
+// deadline, once admission control lets it. A zero soft deadline disables
+// the greedy fallback.
+func (a *Allocator) run(ctx context.Context, strategy AllocationStrategy, quantity int, sizes []int, soft time.Duration) (Result, error) {
+	if err := checkOverflow(quantity, sizes); err != nil {
+		return Result{}, err
+	}
+	release, err := a.admit(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	defer release()
+
+	if a.hardTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.hardTimeout)
+		defer cancel()
+	}
+
+	if soft <= 0 {
+		return strategy.Allocate(ctx, quantity, sizes)
+	}
+
+	type outcome struct {
+		result Result
+		err    error
+	}
+
+	exactCtx, cancelExact := context.WithCancel(ctx)
+	defer cancelExact()
+
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := strategy.Allocate(exactCtx, quantity, sizes)
+		done <- outcome{result, err}
+	}()
+
+	timer := time.NewTimer(soft)
+	defer timer.Stop()
+
+	select {
+	case o := <-done:
+		return o.result, o.err
+	case <-timer.C:
+		cancelExact()
+		log.Printf("Calculation for quantity %d exceeded soft timeout %s, falling back to greedy", quantity, soft)
+		packs, total := greedyWithCorrection(quantity, sizes)
+		return Result{Packs: packs, Total: total, Approximate: true, Stats: Stats{Strategy: FallbackStrategy}}, nil
+	case <-ctx.Done():
+		return Result{}, ctx.Err()
+	}
+}
+
+// Calculate computes the pack distribution for quantity using the named
+// strategy, or the allocator's default strategy when name is empty.
+func (a *Allocator) Calculate(ctx context.Context, quantity int, name string) (map[int]int, int, error) {
+	result, err := a.Allocate(ctx, Request{Quantity: quantity, Strategy: name})
+	if err != nil {
+		return nil, 0, err
+	}
+	return result.Packs, result.Total, nil
+}
+
+// CalculatePacks calculates the optimal pack distribution for a given order quantity
+// using the allocator's default strategy.
+func (a *Allocator) CalculatePacks(orderQuantity int) (map[int]int, int, error) {
+	return a.Calculate(context.Background(), orderQuantity, "")
+}
+
+// CalculatePacksOptimized calculates the optimal pack distribution for a given quantity
+// using the backtracking strategy, serving previously stored results when available.
+// It returns the pack distribution, the total quantity, and an error if the quantity is invalid.
+func (a *Allocator) CalculatePacksOptimized(quantity int) (map[int]int, int, error) {
+	if quantity <= 0 {
+		return nil, 0, ErrInvalidQuantity
+	}
+
+	if a.cache != nil {
+		// Results cached under an older version of the default profile were
+		// computed with different pack sizes and are not reused, nor are
+		// pinned, approximate or constrained ones.
+		cached, err := a.cache.GetCachedAllocation(DefaultProfile, quantity)
+		if err == nil && cached != nil &&
+			cached.ProfileVersion == a.config().version(DefaultProfile) && cached.Cacheable && !cached.Approximate {
+			log.Printf("Using cached result for quantity %d", quantity)
+			return cached.Packs, cached.Total, nil
+		}
+	}
+
+	return a.Calculate(context.Background(), quantity, "backtracking")
 }
 
 // GreedyWithCorrectionPacks computes an approximate pack distribution
 // using a greedy approach followed by local correction to reduce waste.
 func (a *Allocator) GreedyWithCorrectionPacks(quantity int) (map[int]int, int) {
-	packSizes := a.packSizes
-	packs := make(map[int]int)
-	total := 0
-	remaining := quantity
-
-	// Greedy phase: use as many large packs as possible
-	for _, size := range packSizes {
-		count := remaining / size
-		if count > 0 {
-			packs[size] = count
-			total += size * count
-			remaining -= size * count
+	return greedyWithCorrection(quantity, a.config().packSizes)
+}
+
+// computed is a result computed for a request with version of its profile.
+type computed struct {
+	req     Request
+	result  Result
+	version int
+}
+
+// store persists computed results, several in a single transaction. Failed
+// writes are queued in the outbox for retry rather than failing the request.
+// Nothing is stored while the allocator is read-only.
+func (a *Allocator) store(results ...computed) {
+	if a.storage == nil || a.ReadOnly().Enabled || len(results) == 0 {
+		return
+	}
+	ins := make([]storage.AllocationInput, len(results))
+	now := time.Now()
+	for i, c := range results {
+		profile := c.req.Profile
+		if profile == "" {
+			profile = DefaultProfile
+		}
+		algorithm := c.result.Stats.Strategy
+		if c.result.Stats.Cache == CacheHit {
+			algorithm = AlgorithmCache
+		}
+		ins[i] = storage.AllocationInput{
+			Quantity:       c.req.Quantity,
+			Packs:          c.result.Packs,
+			Total:          c.result.Total,
+			OrderID:        c.req.OrderID,
+			CustomerID:     c.req.CustomerID,
+			Metadata:       c.req.Metadata,
+			Profile:        profile,
+			ProfileVersion: c.version,
+			Source:         c.result.Source,
+			Algorithm:      algorithm,
+			Approximate:    c.result.Approximate,
+			Cacheable:      a.cacheable(c),
+			CreatedAt:      now,
+		}
+		if c.req.Unit != "" {
+			ins[i].Unit, ins[i].RequestedQuantity = c.req.Unit, c.req.requested
+		}
+		for _, m := range c.result.Rules {
+			ins[i].Rules = append(ins[i].Rules, storage.RuleMatch(m))
 		}
 	}
 
-	// Add smallest pack if needed
-	if remaining > 0 {
-		smallest := packSizes[len(packSizes)-1]
-		packs[smallest]++
-		total += smallest
+	var err error
+	if len(ins) == 1 {
+		err = a.storage.StoreAllocationInput(ins[0])
+	} else {
+		err = a.storage.StoreAllocations(ins)
 	}
-
-	// Local correction phase
-	// Try replacing a small pack with a larger one that reduces waste
-	for i := len(packSizes) - 1; i > 0; i-- {
-		small := packSizes[i]
-		if packs[small] == 0 {
-			continue
+	for i, c := range results {
+		if err != nil {
+			a.outbox.enqueue(ins[i], err)
 		}
-		for j := i - 1; j >= 0; j-- {
-			large := packSizes[j]
-			newTotal := total - small + large
-			if newTotal >= quantity && newTotal < total {
-				packs[small]--
-				if packs[small] == 0 {
-					delete(packs, small)
-				}
-				packs[large]++
-				total = newTotal
-				break
-			}
+		// A hit is already cached, with the time it was computed.
+		if c.result.Source == "" && c.result.Stats.Cache != CacheHit {
+			a.cacheAllocation(ins[i])
 		}
 	}
+}
 
-	return packs, total
+// AlgorithmStats counts the allocations stored in [from, to) by the
+// algorithm that produced them; a zero from or to leaves the range open.
+func (a *Allocator) AlgorithmStats(from, to time.Time) ([]storage.AlgorithmStats, error) {
+	if a.storage == nil {
+		return nil, ErrStorageNotConfigured
+	}
+	return a.storage.GetAlgorithmStats(from, to)
 }
 
-// cloneMap creates a deep copy of a map[int]int.
-func cloneMap(src map[int]int) map[int]int {
-	dst := make(map[int]int, len(src))
-	for k, v := range src {
-		dst[k] = v
+// WasteStats sums the items and waste of the allocations stored in
+// [from, to) by group, one of the storage GroupBy fields; a zero from or to
+// leaves the range open.
+func (a *Allocator) WasteStats(groupBy string, from, to time.Time) ([]storage.WasteStats, error) {
+	if a.storage == nil {
+		return nil, ErrStorageNotConfigured
 	}
-	return dst
+	return a.storage.AggregateWaste(groupBy, from, to)
 }
 
-// GetRecentAllocations retrieves the most recent allocations from the storage.
+// GetRecentAllocations retrieves the most recent allocations from the
+// storage, including soft-deleted ones if includeDeleted is set.
+func (a *Allocator) GetRecentAllocations(limit int, includeDeleted bool) ([]storage.Allocation, error) {
+	if a.storage == nil {
+		return nil, ErrStorageNotConfigured
+	}
+	return a.storage.GetRecentAllocations(limit, includeDeleted)
+}
+
+// CountAllocations counts the stored allocations matching f.
+func (a *Allocator) CountAllocations(f storage.AllocationFilter) (int, error) {
+	if a.storage == nil {
+		return 0, ErrStorageNotConfigured
+	}
+	return a.storage.CountAllocations(f)
+}
+
+// SearchAllocations retrieves the page of stored allocations matching f,
+// and how many match in all.
+func (a *Allocator) SearchAllocations(f storage.AllocationFilter) ([]storage.Allocation, int, error) {
+	if a.storage == nil {
+		return nil, 0, ErrStorageNotConfigured
+	}
+	return a.storage.SearchAllocations(f)
+}
 
-func (a *Allocator) GetRecentAllocations(limit int) ([]storage.Allocation, error) {
+// DeleteAllocation soft-deletes the stored allocation with the given ID, so
+// it is hidden from the history, and from the result cache unless SetCache
+// serves that elsewhere, until restored. It reports whether there was such
+// an allocation that was not already deleted.
+// It fails with ErrReadOnly while the allocator is read-only.
+func (a *Allocator) DeleteAllocation(id int64) (bool, error) {
+	if a.storage == nil {
+		return false, ErrStorageNotConfigured
+	}
+	if a.ReadOnly().Enabled {
+		return false, ErrReadOnly
+	}
+	return a.storage.DeleteAllocation(id)
+}
+
+// RestoreAllocation undoes DeleteAllocation. It reports whether there was
+// a deleted allocation with the ID.
+// It fails with ErrReadOnly while the allocator is read-only.
+func (a *Allocator) RestoreAllocation(id int64) (bool, error) {
+	if a.storage == nil {
+		return false, ErrStorageNotConfigured
+	}
+	if a.ReadOnly().Enabled {
+		return false, ErrReadOnly
+	}
+	return a.storage.RestoreAllocation(id)
+}
+
+// GetAllocationsByOrderID retrieves the allocations recorded for an order.
+func (a *Allocator) GetAllocationsByOrderID(orderID string) ([]storage.Allocation, error) {
 	if a.storage == nil {
 		return nil, ErrStorageNotConfigured
 	}
-	return a.storage.GetRecentAllocations(limit)
+	return a.storage.GetAllocationsByOrderID(orderID)
 }
 
-// Close closes the storage.
+// ExportAllocations streams stored allocations created in [from, to) to fn.
+func (a *Allocator) ExportAllocations(from, to time.Time, fn func(storage.Allocation) error) error {
+	if a.storage == nil {
+		return ErrStorageNotConfigured
+	}
+	return a.storage.ExportAllocations(from, to, fn)
+}
 
-func (a *Allocator) Close() error {
-	if a.storage != nil {
-		return a.storage.Close()
+// Backup writes a consistent snapshot of the storage to w. It is allowed
+// while read-only.
+func (a *Allocator) Backup(w io.Writer) error {
+	if a.storage == nil {
+		return ErrStorageNotConfigured
 	}
-	return nil
+	return a.storage.Backup(w)
 }
 
-// CalculatePacks calculates the optimal pack distribution for a given order quantity
-func (a *Allocator) CalculatePacks(orderQuantity int) (map[int]int, int, error) {
-	log.Printf("Calculating optimal packs for order quantity: %d", orderQuantity)
-	if orderQuantity <= 0 {
-		log.Printf("Order quantity <= 0, returning error")
-		return nil, 0, errors.New("quantity must be greater than 0")
+// RecordAudit appends an entry to the audit log. While read-only the entry
+// is written to the process log instead, so no audit trail is lost.
+func (a *Allocator) RecordAudit(e storage.AuditEntry) error {
+	if a.storage == nil {
+		return ErrStorageNotConfigured
+	}
+	if a.ReadOnly().Enabled {
+		log.Printf("audit: actor=%s ip=%s %s %s status=%d params=%q error=%q duration=%dms",
+			e.Actor, e.ClientIP, e.Method, e.Path, e.Status, e.Params, e.Error, e.DurationMS)
+		return nil
 	}
+	return a.storage.RecordAudit(e)
+}
+
+// AuditEntries retrieves audit entries matching f, most recent first.
+func (a *Allocator) AuditEntries(f storage.AuditFilter) ([]storage.AuditEntry, error) {
+	if a.storage == nil {
+		return nil, ErrStorageNotConfigured
+	}
+	return a.storage.GetAuditEntries(f)
+}
+
+// SetRetention configures the policy applied by Prune.
+func (a *Allocator) SetRetention(p storage.RetentionPolicy) {
+	a.retention = p
+}
+
+// Retention returns the configured retention policy.
+func (a *Allocator) Retention() storage.RetentionPolicy {
+	return a.retention
+}
 
-	if len(a.packSizes) == 0 {
-		log.Printf("No pack sizes configured")
-		return nil, 0, errors.New("no pack sizes configured")
+// Prune removes stored allocations that fall outside p.
+// It always fails with ErrReadOnly while the allocator is read-only.
+func (a *Allocator) Prune(p storage.RetentionPolicy) (storage.PruneResult, error) {
+	if a.storage == nil {
+		return storage.PruneResult{}, ErrStorageNotConfigured
 	}
+	if a.ReadOnly().Enabled {
+		return storage.PruneResult{}, ErrReadOnly
+	}
+	return storage.Prune(a.storage, p, time.Now())
+}
 
-	// Special case: order is smaller than all pack sizes
-	smallest := a.packSizes[len(a.packSizes)-1]
-	if orderQuantity < smallest {
-		result := map[int]int{smallest: 1}
-		if a.storage != nil {
-			if err := a.storage.StoreAllocation(orderQuantity, result, smallest); err != nil {
-				log.Printf("Failed to store allocation: %v", err)
+// RunPruner applies the retention policy every interval until ctx is done.
+// Runs are skipped while the allocator is read-only; failures are logged
+// and retried on the next tick.
+func (a *Allocator) RunPruner(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			result, err := a.Prune(a.retention)
+			if errors.Is(err, ErrReadOnly) {
+				continue
 			}
-		}
-		return result, smallest, nil
-	}
-
-	// Initialize result map
-	result := make(map[int]int)
-
-	// Calculate the minimum number of packs needed for each pack size
-	minPacks := make(map[int]int)
-	for _, size := range a.packSizes {
-		minPacks[size] = (orderQuantity + size - 1) / size
-	}
-
-	// Find the best combination by trying different combinations
-	bestResult := make(map[int]int)
-	bestTotal := 0
-	bestOverage := orderQuantity
-
-	// Try combinations starting from the largest pack size
-	for _, size := range a.packSizes {
-		currentResult := make(map[int]int)
-		currentTotal := 0
-		remaining := orderQuantity
-
-		// Start with the maximum possible number of current pack size
-		maxPacks := minPacks[size]
-		for packs := maxPacks; packs >= 0; packs-- {
-			currentResult[size] = packs
-			currentTotal = packs * size
-			remaining = orderQuantity - currentTotal
-
-			// If we've met or exceeded the order quantity, check if this is better
-			if remaining <= 0 {
-				overage := -remaining
-				if overage < bestOverage || (overage == bestOverage && currentTotal < bestTotal) {
-					bestResult = make(map[int]int)
-					for k, v := range currentResult {
-						bestResult[k] = v
-					}
-					bestTotal = currentTotal
-					bestOverage = overage
-				}
+			if err != nil {
+				log.Printf("Pruning allocations failed: %v", err)
 				continue
 			}
-
-			// Try to fill the remaining quantity with smaller packs
-			for _, smallerSize := range a.packSizes {
-				if smallerSize >= size {
-					continue
-				}
-				smallerPacks := (remaining + smallerSize - 1) / smallerSize
-				currentResult[smallerSize] = smallerPacks
-				currentTotal += smallerPacks * smallerSize
-				overage := currentTotal - orderQuantity
-
-				if overage < bestOverage || (overage == bestOverage && currentTotal < bestTotal) {
-					bestResult = make(map[int]int)
-					for k, v := range currentResult {
-						bestResult[k] = v
-					}
-					bestTotal = currentTotal
-					bestOverage = overage
-				}
+			if result.Expired > 0 || result.Excess > 0 {
+				log.Printf("Pruned %d expired and %d excess allocations", result.Expired, result.Excess)
 			}
 		}
 	}
+}
 
-	// Copy the best result to the final result
-	for k, v := range bestResult {
-		if v > 0 {
-			result[k] = v
-		}
+// Close closes the storage and, if it is a separate backend, the cache.
+func (a *Allocator) Close() error {
+	var errs []error
+	if a.ownCache {
+		errs = append(errs, a.cache.Close())
 	}
-
 	if a.storage != nil {
-		if err := a.storage.StoreAllocation(orderQuantity, result, bestTotal); err != nil {
-			log.Printf("Failed to store allocation: %v", err)
-		}
+		errs = append(errs, a.storage.Close())
 	}
-
-	return result, bestTotal, nil
+	return errors.Join(errs...)
 }
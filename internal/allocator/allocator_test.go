@@ -1,7 +1,14 @@
 package allocator
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/n-th/gymshark/internal/storage"
 	"github.com/stretchr/testify/assert"
@@ -9,12 +16,25 @@ import (
 
 // mockStorage implements storage.Storage for testing
 type mockStorage struct {
+	// mu guards allocations for tests that allocate concurrently.
+	mu          sync.Mutex
 	allocations map[int]*storage.Allocation
+	profiles    map[string][]storage.ProfileVersion
+	audit       []storage.AuditEntry
+	pins        map[string]storage.Pin
+	defaults    map[string]storage.ProfileDefaults
+	usage       map[string]storage.Usage
+	// batches counts StoreAllocations calls.
+	batches int
 }
 
 func newMockStorage() *mockStorage {
 	return &mockStorage{
 		allocations: make(map[int]*storage.Allocation),
+		profiles:    make(map[string][]storage.ProfileVersion),
+		pins:        make(map[string]storage.Pin),
+		defaults:    make(map[string]storage.ProfileDefaults),
+		usage:       make(map[string]storage.Usage),
 	}
 }
 
@@ -27,15 +47,289 @@ func (m *mockStorage) StoreAllocation(quantity int, packs map[int]int, total int
 	return nil
 }
 
-func (m *mockStorage) GetRecentAllocations(limit int) ([]storage.Allocation, error) {
+func (m *mockStorage) GetRecentAllocations(limit int, includeDeleted bool) ([]storage.Allocation, error) {
 	// Not used in tests
 	return nil, nil
 }
 
+func (m *mockStorage) CountAllocations(f storage.AllocationFilter) (int, error) {
+	return len(m.allocations), nil
+}
+
+func (m *mockStorage) StoreAllocationInput(in storage.AllocationInput) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.allocations[in.Quantity] = &storage.Allocation{
+		OrderQuantity:     in.Quantity,
+		Packs:             in.Packs,
+		Total:             in.Total,
+		OrderID:           in.OrderID,
+		CustomerID:        in.CustomerID,
+		Metadata:          in.Metadata,
+		Profile:           in.Profile,
+		ProfileVersion:    in.ProfileVersion,
+		Source:            in.Source,
+		Algorithm:         in.Algorithm,
+		Approximate:       in.Approximate,
+		Cacheable:         in.Cacheable,
+		Unit:              in.Unit,
+		RequestedQuantity: in.RequestedQuantity,
+		Rules:             in.Rules,
+		CreatedAt:         time.Now(),
+	}
+	return nil
+}
+
+func (m *mockStorage) StoreAllocations(ins []storage.AllocationInput) error {
+	m.batches++
+	for _, in := range ins {
+		m.StoreAllocationInput(in)
+	}
+	return nil
+}
+
 func (m *mockStorage) GetAllocationByQuantity(quantity int) (*storage.Allocation, error) {
 	return m.allocations[quantity], nil
 }
 
+func (m *mockStorage) GetCachedAllocation(profile string, quantity int) (*storage.Allocation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if a := m.allocations[quantity]; a != nil && a.Profile == profile {
+		return a, nil
+	}
+	return nil, nil
+}
+
+func (m *mockStorage) CacheAllocation(storage.Allocation) error {
+	return nil
+}
+
+func (m *mockStorage) GetAllocationsByOrderID(orderID string) ([]storage.Allocation, error) {
+	var allocations []storage.Allocation
+	for _, a := range m.allocations {
+		if a.OrderID == orderID {
+			allocations = append(allocations, *a)
+		}
+	}
+	return allocations, nil
+}
+
+func (m *mockStorage) SearchAllocations(f storage.AllocationFilter) ([]storage.Allocation, int, error) {
+	// Only the profile filter and the limit are supported; matches are
+	// ordered by quantity.
+	var matches []storage.Allocation
+	for _, a := range m.allocations {
+		if f.Profile == "" || a.Profile == f.Profile {
+			matches = append(matches, *a)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].OrderQuantity < matches[j].OrderQuantity })
+	total := len(matches)
+	if f.Limit > 0 && len(matches) > f.Limit {
+		matches = matches[:f.Limit]
+	}
+	return matches, total, nil
+}
+
+func (m *mockStorage) ExportAllocations(from, to time.Time, fn func(storage.Allocation) error) error {
+	for _, a := range m.allocations {
+		if err := fn(*a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *mockStorage) RecordProfileVersion(name string, packSizes []int) (storage.ProfileVersion, error) {
+	versions := m.profiles[name]
+	if n := len(versions); n > 0 && reflect.DeepEqual(versions[n-1].PackSizes, packSizes) {
+		return versions[n-1], nil
+	}
+	v := storage.ProfileVersion{Name: name, Version: len(versions) + 1, PackSizes: packSizes, EffectiveFrom: time.Now()}
+	m.profiles[name] = append(versions, v)
+	return v, nil
+}
+
+func (m *mockStorage) GetProfileVersions(name string) ([]storage.ProfileVersion, error) {
+	return append([]storage.ProfileVersion{}, m.profiles[name]...), nil
+}
+
+func (m *mockStorage) DeleteAllocation(id int64) (bool, error) {
+	// Allocations have no IDs
+	return false, nil
+}
+
+func (m *mockStorage) RestoreAllocation(id int64) (bool, error) {
+	return false, nil
+}
+
+func (m *mockStorage) DeleteOlderThan(t time.Time) (int64, error) {
+	var n int64
+	for q, a := range m.allocations {
+		if a.CreatedAt.Before(t) {
+			delete(m.allocations, q)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *mockStorage) DeleteAllButNewest(keep int) (int64, error) {
+	all := make([]*storage.Allocation, 0, len(m.allocations))
+	for _, a := range m.allocations {
+		all = append(all, a)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].CreatedAt.After(all[j].CreatedAt) })
+	var n int64
+	for i := keep; i < len(all); i++ {
+		delete(m.allocations, all[i].OrderQuantity)
+		n++
+	}
+	return n, nil
+}
+
+func (m *mockStorage) RecordAudit(e storage.AuditEntry) error {
+	e.ID = int64(len(m.audit) + 1)
+	m.audit = append(m.audit, e)
+	return nil
+}
+
+func (m *mockStorage) GetAuditEntries(f storage.AuditFilter) ([]storage.AuditEntry, error) {
+	entries := []storage.AuditEntry{}
+	for i := len(m.audit) - 1; i >= 0; i-- {
+		e := m.audit[i]
+		if (f.Actor == "" || e.Actor == f.Actor) && (f.Path == "" || e.Path == f.Path) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func (m *mockStorage) PinAllocation(p storage.Pin) error {
+	m.pins[fmt.Sprintf("%s/%d", p.Profile, p.Quantity)] = p
+	return nil
+}
+
+func (m *mockStorage) UnpinAllocation(profile string, quantity int) (bool, error) {
+	key := fmt.Sprintf("%s/%d", profile, quantity)
+	_, ok := m.pins[key]
+	delete(m.pins, key)
+	return ok, nil
+}
+
+func (m *mockStorage) GetPins() ([]storage.Pin, error) {
+	pins := []storage.Pin{}
+	for _, p := range m.pins {
+		pins = append(pins, p)
+	}
+	return pins, nil
+}
+
+func (m *mockStorage) SetProfileDefaults(d storage.ProfileDefaults) error {
+	m.defaults[d.Profile] = d
+	return nil
+}
+
+func (m *mockStorage) DeleteProfileDefaults(profile string) (bool, error) {
+	_, ok := m.defaults[profile]
+	delete(m.defaults, profile)
+	return ok, nil
+}
+
+func (m *mockStorage) GetProfileDefaults() ([]storage.ProfileDefaults, error) {
+	defaults := []storage.ProfileDefaults{}
+	for _, d := range m.defaults {
+		defaults = append(defaults, d)
+	}
+	return defaults, nil
+}
+
+func (m *mockStorage) GetAlgorithmStats(from, to time.Time) ([]storage.AlgorithmStats, error) {
+	counts := map[string]*storage.AlgorithmStats{}
+	for _, a := range m.allocations {
+		if a.CreatedAt.Before(from) || !to.IsZero() && !a.CreatedAt.Before(to) {
+			continue
+		}
+		c := counts[a.Algorithm]
+		if c == nil {
+			c = &storage.AlgorithmStats{Algorithm: a.Algorithm}
+			counts[a.Algorithm] = c
+		}
+		c.Allocations++
+		if a.Approximate {
+			c.Approximate++
+		}
+	}
+	stats := []storage.AlgorithmStats{}
+	for _, c := range counts {
+		stats = append(stats, *c)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Algorithm < stats[j].Algorithm })
+	return stats, nil
+}
+
+func (m *mockStorage) AggregateWaste(groupBy string, from, to time.Time) ([]storage.WasteStats, error) {
+	// Not used in tests
+	return []storage.WasteStats{}, nil
+}
+
+func (m *mockStorage) PackUsage(from, to time.Time) ([]storage.PackUsage, error) {
+	// Not used in tests
+	return []storage.PackUsage{}, nil
+}
+
+func (m *mockStorage) TopQuantities(since time.Time, n int) ([]storage.QuantityCount, error) {
+	counts := map[storage.QuantityCount]int64{}
+	for _, a := range m.allocations {
+		if a.CreatedAt.Before(since) {
+			continue
+		}
+		counts[storage.QuantityCount{Profile: a.Profile, Quantity: a.OrderQuantity}]++
+	}
+	top := []storage.QuantityCount{}
+	for c, requests := range counts {
+		c.Requests = requests
+		top = append(top, c)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Requests != top[j].Requests {
+			return top[i].Requests > top[j].Requests
+		}
+		return top[i].Quantity < top[j].Quantity
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top, nil
+}
+
+func (m *mockStorage) RecordUsage(client string, at time.Time, compute time.Duration) error {
+	key := storage.UsageMonth(at) + "/" + client
+	u := m.usage[key]
+	u.Client, u.Month = client, storage.UsageMonth(at)
+	u.Requests++
+	u.Compute += compute
+	m.usage[key] = u
+	return nil
+}
+
+func (m *mockStorage) GetUsage(at time.Time, client string) ([]storage.Usage, error) {
+	usage := []storage.Usage{}
+	for _, u := range m.usage {
+		if u.Month == storage.UsageMonth(at) && (client == "" || u.Client == client) {
+			usage = append(usage, u)
+		}
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Client < usage[j].Client })
+	return usage, nil
+}
+
+func (m *mockStorage) Backup(w io.Writer) error {
+	_, err := io.WriteString(w, "backup")
+	return err
+}
+
 func (m *mockStorage) Close() error {
 	return nil
 }
@@ -86,7 +380,7 @@ func TestCalculatePacks(t *testing.T) {
 		{
 			name:          "large quantity",
 			quantity:      500,
-			expectedPacks: map[int]int{53: 3, 23: 13, 31: 11},
+			expectedPacks: map[int]int{53: 9, 23: 1},
 			expectedTotal: 500,
 			expectedError: false,
 		},
@@ -137,7 +431,7 @@ func TestCalculatePacksWithDifferentPackSizes(t *testing.T) {
 		{
 			name:          "medium quantity",
 			quantity:      25,
-			expectedPacks: map[int]int{10: 2, 5: 1},
+			expectedPacks: map[int]int{20: 1, 5: 1},
 			expectedTotal: 25,
 		},
 	}
@@ -192,7 +486,7 @@ func TestNewAllocator(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			storage := newMockStorage()
 			allocator := NewAllocator(tt.packSizes, storage)
-			assert.Equal(t, tt.expectedSorted, allocator.packSizes)
+			assert.Equal(t, tt.expectedSorted, allocator.config().packSizes)
 		})
 	}
 }
@@ -203,14 +497,71 @@ func TestGetRecentAllocations(t *testing.T) {
 
 	// Test when storage is not configured
 	allocator.storage = nil
-	allocations, err := allocator.GetRecentAllocations(10)
+	allocations, err := allocator.GetRecentAllocations(10, false)
 	assert.Error(t, err)
 	assert.Equal(t, "storage not configured", err.Error())
 	assert.Nil(t, allocations)
 
 	// Test with mock storage
 	allocator.storage = storage
-	allocations, err = allocator.GetRecentAllocations(10)
+	allocations, err = allocator.GetRecentAllocations(10, false)
 	assert.NoError(t, err)
 	assert.Empty(t, allocations)
 }
+
+func TestPreviewDoesNotStore(t *testing.T) {
+	storage := newMockStorage()
+	allocator := NewAllocator([]int{23, 31, 53}, storage)
+
+	result, err := allocator.Preview(context.Background(), Request{Quantity: 50})
+	assert.NoError(t, err)
+	assert.Equal(t, 53, result.Total)
+	assert.Empty(t, storage.allocations)
+
+	_, err = allocator.Preview(context.Background(), Request{Quantity: 0})
+	assert.ErrorIs(t, err, ErrInvalidQuantity)
+}
+
+func TestStoreRecordsAlgorithm(t *testing.T) {
+	RegisterStrategy("algorithm-blocking", StrategyFunc(func(ctx context.Context, quantity int, sizes []int) (Result, error) {
+		<-ctx.Done()
+		return Result{}, ctx.Err()
+	}))
+	ctx := context.Background()
+	store := newMockStorage()
+	a := NewAllocator([]int{250, 500, 1000}, store)
+	assert.NoError(t, a.RecordProfileVersions())
+
+	_, err := a.Allocate(ctx, Request{Quantity: 501, Strategy: "dp"})
+	assert.NoError(t, err)
+	assert.Equal(t, "dp", store.allocations[501].Algorithm)
+	assert.False(t, store.allocations[501].Approximate)
+
+	a.SetResultCache(true)
+	_, err = a.Allocate(ctx, Request{Quantity: 501})
+	assert.NoError(t, err)
+	assert.Equal(t, AlgorithmCache, store.allocations[501].Algorithm)
+
+	_, err = a.Allocate(ctx, Request{Quantity: 750, Constraints: &Constraints{MaxPacks: 2}})
+	assert.NoError(t, err)
+	assert.Equal(t, ConstrainedStrategy, store.allocations[750].Algorithm)
+
+	_, err = a.Pin(ctx, storage.Pin{Quantity: 600, Packs: map[int]int{250: 3}})
+	assert.NoError(t, err)
+	_, err = a.Allocate(ctx, Request{Quantity: 600})
+	assert.NoError(t, err)
+	assert.Equal(t, SourceManual, store.allocations[600].Algorithm)
+
+	a.SetTimeouts(10*time.Millisecond, time.Second)
+	_, err = a.Allocate(ctx, Request{Quantity: 900, Strategy: "algorithm-blocking"})
+	assert.NoError(t, err)
+	assert.Equal(t, FallbackStrategy, store.allocations[900].Algorithm)
+	assert.True(t, store.allocations[900].Approximate)
+
+	stats, err := a.AlgorithmStats(time.Time{}, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 4)
+
+	_, err = NewAllocator([]int{250}, nil).AlgorithmStats(time.Time{}, time.Time{})
+	assert.ErrorIs(t, err, ErrStorageNotConfigured)
+}
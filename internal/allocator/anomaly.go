@@ -0,0 +1,63 @@
+package allocator
+
+import "github.com/n-th/gymshark/internal/notify"
+
+// AnomalyPolicy decides which allocations operators are alerted to.
+type AnomalyPolicy struct {
+	// WasteRate is the fraction of its quantity an allocation may waste,
+	// e.g. 0.2 for 20%, before it is alerted on. Zero disables the check.
+	WasteRate float64
+	// Approximate alerts on approximate results, such as greedy fallbacks
+	// after the soft timeout.
+	Approximate bool
+}
+
+// reasons returns why result is anomalous for quantity, or nil.
+func (p AnomalyPolicy) reasons(quantity int, result Result) []notify.Reason {
+	var reasons []notify.Reason
+	if p.WasteRate > 0 && float64(result.Total-quantity) > p.WasteRate*float64(quantity) {
+		reasons = append(reasons, notify.ReasonWaste)
+	}
+	if p.Approximate && result.Approximate {
+		reasons = append(reasons, notify.ReasonApproximate)
+	}
+	return reasons
+}
+
+// Notifier is alerted to anomalous allocations, e.g. a *notify.Notifier.
+type Notifier interface {
+	// Notify must not block.
+	Notify(a notify.Alert)
+}
+
+// SetNotifier alerts n to every allocation computed by Allocate or
+// AllocateOrder that p finds anomalous. It must be called before the
+// allocator is used concurrently.
+func (a *Allocator) SetNotifier(n Notifier, p AnomalyPolicy) {
+	a.notifier = n
+	a.anomalies = p
+}
+
+// notifyAnomaly alerts the notifier, if any, when result is anomalous.
+func (a *Allocator) notifyAnomaly(req Request, result Result) {
+	if a.notifier == nil {
+		return
+	}
+	reasons := a.anomalies.reasons(req.Quantity, result)
+	if len(reasons) == 0 {
+		return
+	}
+	profile := req.Profile
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	a.notifier.Notify(notify.Alert{
+		Reasons:  reasons,
+		Profile:  profile,
+		Quantity: req.Quantity,
+		Packs:    result.Packs,
+		Total:    result.Total,
+		Strategy: result.Stats.Strategy,
+		OrderID:  req.OrderID,
+	})
+}
@@ -0,0 +1,56 @@
+package allocator
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/n-th/gymshark/internal/notify"
+	"github.com/stretchr/testify/assert"
+)
+
+// alertRecorder is a Notifier that records alerts.
+type alertRecorder struct {
+	mu     sync.Mutex
+	alerts []notify.Alert
+}
+
+func (r *alertRecorder) Notify(a notify.Alert) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.alerts = append(r.alerts, a)
+}
+
+func TestAnomalyPolicy(t *testing.T) {
+	p := AnomalyPolicy{WasteRate: 0.1, Approximate: true}
+	assert.Nil(t, p.reasons(100, Result{Total: 110}))
+	assert.Equal(t, []notify.Reason{notify.ReasonWaste}, p.reasons(100, Result{Total: 111}))
+	assert.Equal(t, []notify.Reason{notify.ReasonWaste, notify.ReasonApproximate}, p.reasons(100, Result{Total: 120, Approximate: true}))
+	// Shortfalls waste nothing.
+	assert.Nil(t, p.reasons(100, Result{Total: 50, Shortfall: 50}))
+	assert.Nil(t, AnomalyPolicy{}.reasons(100, Result{Total: 200, Approximate: true}))
+}
+
+func TestNotifyAnomaly(t *testing.T) {
+	a := NewAllocator([]int{23, 31, 53}, nil)
+	recorder := &alertRecorder{}
+	a.SetNotifier(recorder, AnomalyPolicy{WasteRate: 0.5})
+
+	_, err := a.Allocate(context.Background(), Request{Quantity: 12, OrderID: "ORD-1"})
+	assert.NoError(t, err)
+	_, err = a.Allocate(context.Background(), Request{Quantity: 23})
+	assert.NoError(t, err)
+	_, err = a.AllocateOrder(context.Background(), OrderRequest{Lines: []OrderLine{{SKU: "SKU-1", Quantity: 10}}})
+	assert.NoError(t, err)
+
+	if assert.Len(t, recorder.alerts, 2) {
+		alert := recorder.alerts[0]
+		assert.Equal(t, []notify.Reason{notify.ReasonWaste}, alert.Reasons)
+		assert.Equal(t, DefaultProfile, alert.Profile)
+		assert.Equal(t, 12, alert.Quantity)
+		assert.Equal(t, map[int]int{23: 1}, alert.Packs)
+		assert.Equal(t, "ORD-1", alert.OrderID)
+		assert.NotEmpty(t, alert.Strategy)
+		assert.Equal(t, 10, recorder.alerts[1].Quantity)
+	}
+}
@@ -0,0 +1,69 @@
+package allocator
+
+import (
+	"fmt"
+	"time"
+)
+
+// ErrNoProfileVersion reports a calculation as of a time before the first
+// recorded version of its profile.
+var ErrNoProfileVersion = infeasible("no profile version active")
+
+// asOf returns a copy of cfg in which profile has the pack sizes and version
+// that were active at t, from the recorded profile versions. Pack limits are
+// not versioned, so the copy has none for the profile.
+func (a *Allocator) asOf(cfg *snapshot, profile string, t time.Time) (*snapshot, error) {
+	if a.storage == nil {
+		return nil, ErrStorageNotConfigured
+	}
+	if _, err := cfg.sizes(profile); err != nil {
+		return nil, err
+	}
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	versions, err := a.storage.GetProfileVersions(profile)
+	if err != nil {
+		return nil, err
+	}
+	found := -1
+	for i, v := range versions {
+		if !v.EffectiveFrom.After(t) {
+			found = i
+		}
+	}
+	if found < 0 {
+		return nil, fmt.Errorf("%w: profile %q at %s", ErrNoProfileVersion, profile, t.UTC().Format(time.RFC3339))
+	}
+	v := versions[found]
+
+	next := *cfg
+	sizes := sortedSizes(v.PackSizes)
+	if profile == DefaultProfile {
+		next.packSizes = sizes
+	} else {
+		next.profiles = make(map[string][]int, len(cfg.profiles))
+		for name, s := range cfg.profiles {
+			next.profiles[name] = s
+		}
+		next.profiles[profile] = sizes
+	}
+	next.versions = make(map[string]int, len(cfg.versions)+1)
+	for name, version := range cfg.versions {
+		next.versions[name] = version
+	}
+	next.versions[profile] = v.Version
+	next.packLimits = make(map[string]map[int]int, len(cfg.packLimits))
+	for name, limits := range cfg.packLimits {
+		if name != profile {
+			next.packLimits[name] = limits
+		}
+	}
+	next.deprecatedSizes = make(map[string][]int, len(cfg.deprecatedSizes))
+	for name, sizes := range cfg.deprecatedSizes {
+		if name != profile {
+			next.deprecatedSizes[name] = sizes
+		}
+	}
+	return &next, nil
+}
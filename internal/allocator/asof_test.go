@@ -0,0 +1,50 @@
+package allocator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/n-th/gymshark/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllocateAsOf(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStorage()
+	a := NewAllocator([]int{250, 500, 1000}, store)
+	assert.NoError(t, a.SetProfiles(map[string][]int{"apparel": {10, 20}}, nil))
+	assert.NoError(t, a.RecordProfileVersions())
+	store.profiles[DefaultProfile][0].EffectiveFrom = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err := a.UpdateProfile(ctx, DefaultProfile, []int{23, 31, 53})
+	assert.NoError(t, err)
+	_, err = a.Pin(ctx, storage.Pin{Quantity: 501, Packs: map[int]int{53: 10}})
+	assert.NoError(t, err)
+	assert.NoError(t, a.SetPackLimits(map[string]map[int]int{DefaultProfile: {53: 0}}))
+
+	// The first version applies, without the current pin and limits, and
+	// the result is not stored
+	result, err := a.Allocate(ctx, Request{Quantity: 501, AsOf: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{500: 1, 250: 1}, result.Packs)
+	assert.Equal(t, "", result.Source)
+	assert.Nil(t, store.allocations[501])
+
+	// Now the current sizes apply again
+	result, err = a.Preview(ctx, Request{Quantity: 100, AsOf: time.Now().Add(time.Hour)})
+	assert.NoError(t, err)
+	assert.Equal(t, 100, result.Total)
+	assert.Equal(t, []int{53, 31, 23}, a.config().packSizes)
+
+	_, err = a.Allocate(ctx, Request{Quantity: 501, AsOf: time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC)})
+	assert.ErrorIs(t, err, ErrNoProfileVersion)
+	assert.EqualError(t, err, `no profile version active: profile "default" at 2023-12-31T00:00:00Z`)
+	_, err = a.Allocate(ctx, Request{Quantity: 501, Profile: "shoes", AsOf: time.Now()})
+	assert.ErrorIs(t, err, ErrUnknownProfile)
+	result, err = a.Allocate(ctx, Request{Quantity: 15, Profile: "apparel", AsOf: time.Now()})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{20: 1}, result.Packs)
+
+	_, err = NewAllocator([]int{250}, nil).Allocate(ctx, Request{Quantity: 1, AsOf: time.Now()})
+	assert.ErrorIs(t, err, ErrStorageNotConfigured)
+}
@@ -0,0 +1,138 @@
+package allocator
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/n-th/gymshark/pkg/packer"
+)
+
+// MaxBatchQuantity bounds the quantities AllocateBatch takes, since its
+// table holds two integers for every total up to the largest quantity.
+const MaxBatchQuantity = 1_000_000
+
+// batchChunkSize is how many quantities a batch worker takes at a time.
+const batchChunkSize = 1024
+
+// BatchRequest describes the allocation of many quantities of one profile.
+type BatchRequest struct {
+	Quantities []int
+	// Profile defaults to the default profile.
+	Profile string
+	// Workers is how many goroutines read results from the table; zero
+	// uses GOMAXPROCS.
+	Workers int
+}
+
+// AllocateBatch allocates every quantity of the request, answering them all
+// from one dynamic-programming table built up to the largest quantity
+// instead of solving each independently, so batches of hundreds of
+// thousands of quantities take about as long as the largest one. Results
+// come in the order of the quantities, with the exact strategy's packs
+// unless the quantity is pinned. Nothing is stored, no cache is consulted
+// and no allocation rules are evaluated. Profiles with pack limits are not
+// supported, since the table ignores them. It takes one calculation slot
+// for the whole batch.
+func (a *Allocator) AllocateBatch(ctx context.Context, req BatchRequest) ([]Result, error) {
+	profile := req.Profile
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	if err := a.checkBatchSize(len(req.Quantities)); err != nil {
+		return nil, err
+	}
+	largest := 0
+	for i, q := range req.Quantities {
+		if q <= 0 {
+			return nil, fmt.Errorf("%w: quantity %d at %d", ErrInvalidQuantity, q, i)
+		}
+		largest = max(largest, q)
+	}
+	if err := a.checkQuantity(largest); err != nil {
+		return nil, err
+	}
+	if largest > MaxBatchQuantity {
+		return nil, &LimitError{Field: "quantity", Value: largest, Limit: MaxBatchQuantity}
+	}
+	if profile == WeightProfile {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownProfile, profile)
+	}
+	cfg := a.config()
+	if len(cfg.limits(profile)) > 0 {
+		return nil, fmt.Errorf("%w: profile %q has pack limits", ErrConstraintsUnsupported, profile)
+	}
+	sizes, err := cfg.sizes(profile)
+	if err != nil {
+		return nil, err
+	}
+	if len(sizes) == 0 {
+		return nil, ErrNoPackSizes
+	}
+	if err := checkOverflow(largest, sizes); err != nil {
+		return nil, err
+	}
+	if len(req.Quantities) == 0 {
+		return []Result{}, nil
+	}
+
+	release, err := a.admit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	start := time.Now()
+	deprecated := cfg.deprecated(profile)
+	table, err := packer.NewTable(ctx, largest+sizes[len(sizes)-1]-1, sizes, deprecated)
+	if err != nil {
+		return nil, err
+	}
+
+	// The table is only read from here on, so the workers share it.
+	results := make([]Result, len(req.Quantities))
+	workers := req.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	chunks := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, (len(results)+batchChunkSize-1)/batchChunkSize) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for from := range chunks {
+				for i := from; i < min(from+batchChunkSize, len(results)); i++ {
+					results[i] = a.batchResult(table, req.Quantities[i], profile, deprecated, start)
+				}
+			}
+		}()
+	}
+	for from := 0; from < len(results) && ctx.Err() == nil; from += batchChunkSize {
+		select {
+		case chunks <- from:
+		case <-ctx.Done():
+		}
+	}
+	close(chunks)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// batchResult answers one quantity of a batch from its table.
+func (a *Allocator) batchResult(table *packer.Table, quantity int, profile string, deprecated []int, start time.Time) Result {
+	if r, ok := a.pinned(quantity, profile); ok {
+		return r
+	}
+	// A multiple of the smallest size is always within the table.
+	r, _ := solve(table, quantity)
+	r.DeprecatedPacks = deprecatedPacks(r.Packs, deprecated)
+	r.ComputedAt = start
+	r.Stats = Stats{Strategy: ExactStrategy, Cache: CacheBypass}
+	return r
+}
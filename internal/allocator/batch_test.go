@@ -0,0 +1,68 @@
+package allocator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+func TestAllocateBatch(t *testing.T) {
+	ctx := context.Background()
+	a := NewAllocator([]int{23, 31, 53}, newMockStorage())
+	_, err := a.Pin(ctx, storage.Pin{Quantity: 500, Packs: map[int]int{53: 10}})
+	assert.NoError(t, err)
+
+	// Enough quantities for several chunks, answered as Allocate would.
+	quantities := make([]int, 5000)
+	for i := range quantities {
+		quantities[i] = 5000 - i
+	}
+	results, err := a.AllocateBatch(ctx, BatchRequest{Quantities: quantities, Workers: 3})
+	assert.NoError(t, err)
+	if assert.Len(t, results, len(quantities)) {
+		for i, q := range quantities {
+			want, err := a.Allocate(ctx, Request{Quantity: q, Strategy: ExactStrategy})
+			assert.NoError(t, err)
+			if !assert.Equal(t, want.Packs, results[i].Packs, "quantity %d", q) {
+				break
+			}
+			assert.Equal(t, want.Total, results[i].Total)
+		}
+		pinned := results[len(quantities)-500]
+		assert.Equal(t, SourceManual, pinned.Source)
+		assert.Equal(t, ExactStrategy, results[0].Stats.Strategy)
+	}
+
+	results, err = a.AllocateBatch(ctx, BatchRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestAllocateBatchErrors(t *testing.T) {
+	ctx := context.Background()
+	a := NewAllocator([]int{250, 500, 1000}, nil)
+
+	_, err := a.AllocateBatch(ctx, BatchRequest{Quantities: []int{250, 0}})
+	assert.ErrorIs(t, err, ErrInvalidQuantity)
+	_, err = a.AllocateBatch(ctx, BatchRequest{Quantities: []int{250}, Profile: "apparel"})
+	assert.ErrorIs(t, err, ErrUnknownProfile)
+	_, err = a.AllocateBatch(ctx, BatchRequest{Quantities: []int{MaxBatchQuantity + 1}})
+	assert.ErrorIs(t, err, ErrTooLarge)
+
+	a.SetLimits(Limits{MaxBatchSize: 2})
+	_, err = a.AllocateBatch(ctx, BatchRequest{Quantities: []int{1, 2, 3}})
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+	a.SetLimits(Limits{})
+
+	assert.NoError(t, a.SetPackLimits(map[string]map[int]int{DefaultProfile: {1000: 1}}))
+	_, err = a.AllocateBatch(ctx, BatchRequest{Quantities: []int{600}})
+	assert.ErrorIs(t, err, ErrConstraintsUnsupported)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = NewAllocator([]int{250, 500}, nil).AllocateBatch(canceled, BatchRequest{Quantities: []int{250, 750}})
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,103 @@
+package allocator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// benchCases covers the shapes of input that stress the strategies differently:
+// small coprime sizes, the business sizes from the challenge, and many sizes.
+var benchCases = []struct {
+	name     string
+	sizes    []int
+	quantity int
+}{
+	{"coprime/1e3", []int{53, 31, 23}, 1_000},
+	{"coprime/1e4", []int{53, 31, 23}, 10_000},
+	{"coprime/1e6", []int{53, 31, 23}, 1_000_000},
+	{"coprime/1e9", []int{53, 31, 23}, 1_000_000_000},
+	{"business/12001", []int{5000, 2000, 1000, 500, 250}, 12_001},
+	{"business/1e6", []int{5000, 2000, 1000, 500, 250}, 1_000_001},
+	{"business/1e9", []int{5000, 2000, 1000, 500, 250}, 1_000_000_001},
+	{"many/1e3", manySizes(20), 1_000},
+	{"many/1e4", manySizes(20), 10_000},
+	{"many/1e6", manySizes(20), 1_000_000},
+}
+
+// benchLimits caps the quantity each strategy is benchmarked with, so that
+// strategies with super-linear time or linear memory do not stall the suite.
+var benchLimits = map[string]int{
+	"backtracking": 1_000,
+	"dp":           10_000_000,
+}
+
+// manySizes returns n distinct pack sizes in descending order.
+func manySizes(n int) []int {
+	sizes := make([]int, n)
+	for i := range sizes {
+		sizes[i] = 7 + (n-i)*13
+	}
+	return sizes
+}
+
+func BenchmarkStrategies(b *testing.B) {
+	ctx := context.Background()
+	for _, name := range []string{"combination", "backtracking", "greedy", "dp"} {
+		strategy, err := LookupStrategy(name)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, bc := range benchCases {
+			b.Run(fmt.Sprintf("%s/%s", name, bc.name), func(b *testing.B) {
+				if limit, ok := benchLimits[name]; ok && bc.quantity > limit {
+					b.Skipf("quantity %d exceeds %s benchmark limit %d", bc.quantity, name, limit)
+				}
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := strategy.Allocate(ctx, bc.quantity, bc.sizes); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkCalculatePacks(b *testing.B) {
+	allocator := NewAllocator([]int{23, 31, 53}, nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := allocator.CalculatePacks(500_000); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkBranchAndBound is kept apart from BenchmarkStrategies because
+// many-size inputs with no exact fewest-pack answer make the search exponential.
+func BenchmarkBranchAndBound(b *testing.B) {
+	ctx := context.Background()
+	cases := []struct {
+		name        string
+		sizes       []int
+		quantity    int
+		constraints Constraints
+	}{
+		{"coprime/1e6", []int{53, 31, 23}, 1_000_000, Constraints{}},
+		{"coprime/1e6/costs", []int{53, 31, 23}, 1_000_000, Constraints{Costs: map[int]float64{53: 5, 31: 1, 23: 1}}},
+		{"coprime/1e6/stock", []int{53, 31, 23}, 1_000_000, Constraints{Available: map[int]int{53: 10_000, 31: 10_000}}},
+		{"business/1e6/maxpacks", []int{5000, 2000, 1000, 500, 250}, 1_000_001, Constraints{MaxPacks: 205}},
+		{"many/1e3", manySizes(20), 1_000, Constraints{}},
+	}
+	for _, bc := range cases {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := branchAndBound(ctx, nil, bc.quantity, bc.sizes, bc.constraints); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
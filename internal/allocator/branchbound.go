@@ -0,0 +1,310 @@
+package allocator
+
+import (
+	"context"
+	"math"
+	"sort"
+)
+
+// costEpsilon absorbs floating-point noise when comparing costs.
+const costEpsilon = 1e-9
+
+// bbItem is one pack size as seen by the branch-and-bound search.
+type bbItem struct {
+	size  int
+	limit int // -1 for unlimited
+	cost  float64
+}
+
+// bbSearch finds the combination with the least waste, then the lowest cost,
+// subject to Constraints, or the lowest score when weights are set. Branches
+// are pruned with a lower bound on the total (the best total reachable
+// without constraints) and a lower bound on the cost (the LP relaxation of
+// covering the remaining quantity with the cheapest-per-item sizes still
+// available); weighted searches combine both with a bound on the pack count.
+type bbSearch struct {
+	ctx context.Context
+	// soft is closed when the search should settle for the best answer so far.
+	soft     <-chan struct{}
+	quantity int
+	items    []bbItem
+	maxPacks int
+	weights  *Weights
+	minTotal int
+	capacity []int // capacity[i] is the most items[i:] can hold, -1 if unbounded
+	largest  []int // largest[i] is the largest size in items[i:]
+	bySize   []int // indexes of items, largest size first
+	// integral is set when every cost is a whole number, so cost bounds
+	// can be rounded up.
+	integral bool
+	counts   []int
+
+	found     bool
+	total     int
+	cost      float64
+	packCount int
+	score     float64
+	best      []int
+
+	nodes   int
+	err     error
+	stopped bool
+}
+
+// branchAndBound solves a constrained allocation. When soft is closed before
+// the search completes, the best combination found so far is returned with
+// Approximate set; if none has been found yet the search continues until
+// ctx is done.
+func branchAndBound(ctx context.Context, soft <-chan struct{}, quantity int, sizes []int, c Constraints) (Result, error) {
+	minTotal, err := minReachableTotal(ctx, quantity, sizes)
+	if err != nil {
+		return Result{}, err
+	}
+
+	s := &bbSearch{
+		ctx:      ctx,
+		soft:     soft,
+		quantity: quantity,
+		maxPacks: c.MaxPacks,
+		weights:  c.Weights,
+		minTotal: minTotal,
+	}
+	for _, size := range sizes {
+		if limit := c.limit(size); limit != 0 {
+			s.items = append(s.items, bbItem{size: size, limit: limit, cost: c.cost(size)})
+		}
+	}
+	// Branch on the cheapest sizes per item first so good incumbents are
+	// found early; without costs this is largest-first.
+	sort.SliceStable(s.items, func(a, b int) bool {
+		return s.items[a].cost/float64(s.items[a].size) < s.items[b].cost/float64(s.items[b].size)
+	})
+	s.counts = make([]int, len(s.items))
+	s.prepare()
+
+	s.search(0, 0, 0, 0)
+	if s.err != nil {
+		return Result{}, s.err
+	}
+	if !s.found {
+		return Result{}, ErrNoCombination
+	}
+
+	packs := make(map[int]int)
+	for i, n := range s.best {
+		if n > 0 {
+			packs[s.items[i].size] = n
+		}
+	}
+	return Result{Packs: packs, Total: s.total, Approximate: s.stopped, Stats: Stats{Iterations: s.nodes}}, nil
+}
+
+// prepare precomputes the per-suffix capacity and largest size used for
+// bounds, and the order of items by size used to break ties.
+func (s *bbSearch) prepare() {
+	n := len(s.items)
+	s.capacity = make([]int, n+1)
+	s.largest = make([]int, n+1)
+	s.integral = true
+	s.bySize = make([]int, n)
+	for i := range s.bySize {
+		s.bySize[i] = i
+	}
+	sort.Slice(s.bySize, func(a, b int) bool { return s.items[s.bySize[a]].size > s.items[s.bySize[b]].size })
+	for i := n - 1; i >= 0; i-- {
+		item := s.items[i]
+		// Capacities too large for an int hold any quantity, so they
+		// count as unbounded.
+		s.capacity[i] = -1
+		if item.limit >= 0 && s.capacity[i+1] >= 0 {
+			if held, ok := mulInt(item.limit, item.size); ok {
+				if c, ok := addInt(s.capacity[i+1], held); ok {
+					s.capacity[i] = c
+				}
+			}
+		}
+		s.largest[i] = max(item.size, s.largest[i+1])
+		if item.cost != math.Trunc(item.cost) {
+			s.integral = false
+		}
+	}
+}
+
+func (s *bbSearch) search(i, total, packCount int, cost float64) {
+	if s.err != nil || s.stopped {
+		return
+	}
+	s.nodes++
+	if s.nodes%ctxCheckInterval == 0 {
+		if err := s.ctx.Err(); err != nil {
+			s.err = err
+			return
+		}
+		if s.found && s.softDone() {
+			s.stopped = true
+			return
+		}
+	}
+
+	if total >= s.quantity {
+		s.consider(total, packCount, cost)
+		return
+	}
+	if i == len(s.items) {
+		return
+	}
+
+	remaining := s.quantity - total
+	if s.capacity[i] >= 0 && s.capacity[i] < remaining {
+		return
+	}
+	packsLeft := -1
+	if s.maxPacks > 0 {
+		packsLeft = s.maxPacks - packCount
+		if most, ok := mulInt(packsLeft, s.largest[i]); ok && most < remaining {
+			return
+		}
+	}
+	if s.found && s.weights != nil {
+		if s.scoreBound(i, remaining, packCount, cost) >= s.score-costEpsilon {
+			return
+		}
+	} else if s.found && s.total == s.minTotal {
+		bound := cost + s.coverCost(i, remaining)
+		if s.integral {
+			bound = math.Ceil(bound - costEpsilon)
+		}
+		if bound >= s.cost-costEpsilon {
+			return
+		}
+	}
+
+	item := s.items[i]
+	hi := (remaining + item.size - 1) / item.size
+	if item.limit >= 0 && item.limit < hi {
+		hi = item.limit
+	}
+	if packsLeft >= 0 && packsLeft < hi {
+		hi = packsLeft
+	}
+	for x := hi; x >= 0; x-- {
+		s.counts[i] = x
+		s.search(i+1, total+x*item.size, packCount+x, cost+float64(x)*item.cost)
+	}
+	s.counts[i] = 0
+}
+
+// consider records a complete combination if it beats the incumbent.
+func (s *bbSearch) consider(total, packCount int, cost float64) {
+	score := 0.0
+	if s.weights != nil {
+		score = s.weights.score(total-s.quantity, packCount, cost)
+	}
+	better := !s.found || score < s.score-costEpsilon
+	if !better && score <= s.score+costEpsilon {
+		better = total < s.total
+	}
+	if !better && score <= s.score+costEpsilon && total == s.total {
+		switch {
+		case cost < s.cost-costEpsilon:
+			better = true
+		case cost <= s.cost+costEpsilon:
+			better = packCount < s.packCount || packCount == s.packCount && s.largerPacks()
+		}
+	}
+	if !better {
+		return
+	}
+	s.found = true
+	s.total = total
+	s.cost = cost
+	s.packCount = packCount
+	s.score = score
+	s.best = append(s.best[:0], s.counts...)
+}
+
+// largerPacks reports whether the current counts come before the best ones
+// in the canonical order: more packs of the largest size where they differ.
+// Without costs items are visited largest first, so the first of tied
+// combinations found is already the canonical one. With costs the bounds
+// prune ties, so the canonical choice is among the ties visited.
+func (s *bbSearch) largerPacks() bool {
+	for _, i := range s.bySize {
+		if s.counts[i] != s.best[i] {
+			return s.counts[i] > s.best[i]
+		}
+	}
+	return false
+}
+
+// coverCost is the cheapest fractional way to cover remaining items using
+// items[i:], a lower bound on the cost of any integer completion. Items are
+// sorted by cost per item, so filling them in order is optimal.
+func (s *bbSearch) coverCost(i, remaining int) float64 {
+	cost := 0.0
+	left := float64(remaining)
+	for _, item := range s.items[i:] {
+		take := left
+		if item.limit >= 0 {
+			take = math.Min(take, float64(item.limit)*float64(item.size))
+		}
+		cost += take * item.cost / float64(item.size)
+		left -= take
+		if left <= 0 {
+			return cost
+		}
+	}
+	return math.Inf(1)
+}
+
+// scoreBound is a lower bound on the score of any completion: the waste of
+// the best reachable total, the fewest packs of the largest remaining size
+// that cover remaining, and coverCost.
+func (s *bbSearch) scoreBound(i, remaining, packCount int, cost float64) float64 {
+	w := s.weights
+	bound := w.score(s.minTotal-s.quantity, packCount+(remaining+s.largest[i]-1)/s.largest[i], cost)
+	if w.Cost > 0 {
+		bound += w.Cost * s.coverCost(i, remaining)
+	}
+	return bound
+}
+
+func (s *bbSearch) softDone() bool {
+	select {
+	case <-s.soft:
+		return true
+	default:
+		return false
+	}
+}
+
+// minReachableTotal returns the smallest total >= quantity that any
+// combination of sizes can make, ignoring constraints.
+func minReachableTotal(ctx context.Context, quantity int, sizes []int) (int, error) {
+	limit := quantity + sizes[len(sizes)-1] - 1
+	reach := make([]bool, limit+1)
+	reach[0] = true
+	for t := 1; t <= limit; t++ {
+		if t%ctxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return 0, err
+			}
+		}
+		for _, size := range sizes {
+			if size <= t && reach[t-size] {
+				reach[t] = true
+				break
+			}
+		}
+		if reach[t] && t >= quantity {
+			return t, nil
+		}
+	}
+	return 0, ErrNoCombination
+}
+
+// branchAndBoundStrategy runs branch-and-bound without constraints, so the
+// solver can also be selected by name for ordinary requests.
+func branchAndBoundStrategy(ctx context.Context, quantity int, sizes []int) (Result, error) {
+	return branchAndBound(ctx, nil, quantity, sizes, Constraints{})
+}
@@ -0,0 +1,426 @@
+package allocator
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// bruteForceConstrained enumerates every combination within the constraints.
+func bruteForceConstrained(quantity int, sizes []int, c Constraints) (int, float64, bool) {
+	bestTotal, bestCost, found := 0, 0.0, false
+	var walk func(i, total, packs int, cost float64)
+	walk = func(i, total, packs int, cost float64) {
+		if total >= quantity {
+			if !found || total < bestTotal || (total == bestTotal && cost < bestCost-costEpsilon) {
+				bestTotal, bestCost, found = total, cost, true
+			}
+			return
+		}
+		if i == len(sizes) {
+			return
+		}
+		size := sizes[i]
+		hi := (quantity - total + size - 1) / size
+		if limit := c.limit(size); limit >= 0 && limit < hi {
+			hi = limit
+		}
+		for x := 0; x <= hi; x++ {
+			if c.MaxPacks > 0 && packs+x > c.MaxPacks {
+				break
+			}
+			walk(i+1, total+x*size, packs+x, cost+float64(x)*c.cost(size))
+		}
+	}
+	walk(0, 0, 0, 0)
+	return bestTotal, bestCost, found
+}
+
+// bruteForceWeighted returns the lowest score of any combination within the
+// constraints.
+func bruteForceWeighted(quantity int, sizes []int, c Constraints) (float64, bool) {
+	best, found := 0.0, false
+	var walk func(i, total, packs int, cost float64)
+	walk = func(i, total, packs int, cost float64) {
+		if total >= quantity {
+			if score := c.Weights.score(total-quantity, packs, cost); !found || score < best {
+				best, found = score, true
+			}
+			return
+		}
+		if i == len(sizes) {
+			return
+		}
+		size := sizes[i]
+		hi := (quantity - total + size - 1) / size
+		if limit := c.limit(size); limit >= 0 && limit < hi {
+			hi = limit
+		}
+		for x := 0; x <= hi; x++ {
+			if c.MaxPacks > 0 && packs+x > c.MaxPacks {
+				break
+			}
+			walk(i+1, total+x*size, packs+x, cost+float64(x)*c.cost(size))
+		}
+	}
+	walk(0, 0, 0, 0)
+	return best, found
+}
+
+func packTotals(packs map[int]int, c Constraints) (int, float64, int) {
+	total, cost, count := 0, 0.0, 0
+	for size, n := range packs {
+		total += size * n
+		cost += float64(n) * c.cost(size)
+		count += n
+	}
+	return total, cost, count
+}
+
+func TestBranchAndBoundMatchesDP(t *testing.T) {
+	for _, sizes := range [][]int{{53, 31, 23}, {5000, 2000, 1000, 500, 250}} {
+		for q := 1; q <= 600; q++ {
+			want, err := dpStrategy(context.Background(), q, sizes)
+			assert.NoError(t, err)
+			got, err := branchAndBoundStrategy(context.Background(), q, sizes)
+			assert.NoError(t, err)
+			assert.Equal(t, want.Total, got.Total, "quantity %d", q)
+
+			_, _, wantCount := packTotals(want.Packs, Constraints{})
+			_, _, gotCount := packTotals(got.Packs, Constraints{})
+			assert.Equal(t, wantCount, gotCount, "quantity %d", q)
+			assert.Equal(t, want.Packs, got.Packs, "quantity %d", q)
+		}
+	}
+}
+
+func TestBranchAndBoundConstraints(t *testing.T) {
+	sizes := []int{1000, 500, 250}
+	tests := []struct {
+		name     string
+		quantity int
+		c        Constraints
+		expected map[int]int
+	}{
+		{
+			name:     "out of stock size is skipped",
+			quantity: 1000,
+			c:        Constraints{Available: map[int]int{1000: 0}},
+			expected: map[int]int{500: 2},
+		},
+		{
+			name:     "limited stock",
+			quantity: 1500,
+			c:        Constraints{Available: map[int]int{1000: 0, 500: 1}},
+			expected: map[int]int{500: 1, 250: 4},
+		},
+		{
+			name:     "costs prefer cheaper packs",
+			quantity: 500,
+			c:        Constraints{Costs: map[int]float64{500: 3, 250: 1}},
+			expected: map[int]int{250: 2},
+		},
+		{
+			name:     "max packs too low",
+			quantity: 1250,
+			c:        Constraints{MaxPacks: 1},
+			expected: nil,
+		},
+		{
+			name:     "max packs trades waste for fewer packs",
+			quantity: 750,
+			c:        Constraints{MaxPacks: 1},
+			expected: map[int]int{1000: 1},
+		},
+		{
+			name:     "pack weight trades waste for fewer packs",
+			quantity: 750,
+			c:        Constraints{Weights: &Weights{Waste: 1, Packs: 500}},
+			expected: map[int]int{1000: 1},
+		},
+		{
+			name:     "waste weight keeps the least waste",
+			quantity: 750,
+			c:        Constraints{Weights: &Weights{Waste: 1, Packs: 100}},
+			expected: map[int]int{500: 1, 250: 1},
+		},
+		{
+			name:     "cost weight prefers cheaper packs despite waste",
+			quantity: 500,
+			c:        Constraints{Costs: map[int]float64{1000: 1, 500: 10, 250: 10}, Weights: &Weights{Waste: 0.01, Cost: 1}},
+			expected: map[int]int{1000: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := branchAndBound(context.Background(), nil, tt.quantity, sizes, tt.c)
+			if tt.expected == nil {
+				assert.ErrorIs(t, err, ErrNoCombination)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Packs)
+			assert.False(t, result.Approximate)
+		})
+	}
+}
+
+func TestBranchAndBoundRandomConstraints(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	sizes := []int{53, 31, 23, 7}
+
+	for i := 0; i < 300; i++ {
+		c := Constraints{Available: map[int]int{}, Costs: map[int]float64{}}
+		for _, size := range sizes {
+			if rng.Intn(2) == 0 {
+				c.Available[size] = rng.Intn(6)
+			}
+			if rng.Intn(2) == 0 {
+				c.Costs[size] = float64(rng.Intn(10)) / 2
+			}
+		}
+		if rng.Intn(2) == 0 {
+			c.MaxPacks = 1 + rng.Intn(8)
+		}
+		q := 1 + rng.Intn(250)
+
+		wantTotal, wantCost, feasible := bruteForceConstrained(q, sizes, c)
+		result, err := branchAndBound(context.Background(), nil, q, sizes, c)
+		if !feasible {
+			assert.ErrorIs(t, err, ErrNoCombination, "quantity %d, constraints %+v", q, c)
+			continue
+		}
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		total, cost, count := packTotals(result.Packs, c)
+		assert.Equal(t, wantTotal, total, "quantity %d, constraints %+v", q, c)
+		assert.Equal(t, total, result.Total)
+		assert.InDelta(t, wantCost, cost, costEpsilon, "quantity %d, constraints %+v", q, c)
+		for size, n := range result.Packs {
+			if limit := c.limit(size); limit >= 0 {
+				assert.LessOrEqual(t, n, limit)
+			}
+		}
+		if c.MaxPacks > 0 {
+			assert.LessOrEqual(t, count, c.MaxPacks)
+		}
+	}
+}
+
+func TestBranchAndBoundRandomWeights(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	sizes := []int{53, 31, 23, 7}
+
+	for i := 0; i < 300; i++ {
+		c := Constraints{
+			Available: map[int]int{},
+			Costs:     map[int]float64{},
+			Weights:   &Weights{Waste: float64(rng.Intn(4)), Packs: float64(rng.Intn(40)), Cost: float64(rng.Intn(20)) / 2},
+		}
+		for _, size := range sizes {
+			if rng.Intn(3) == 0 {
+				c.Available[size] = rng.Intn(6)
+			}
+			c.Costs[size] = float64(rng.Intn(10)) / 2
+		}
+		if rng.Intn(3) == 0 {
+			c.MaxPacks = 1 + rng.Intn(8)
+		}
+		q := 1 + rng.Intn(250)
+
+		want, feasible := bruteForceWeighted(q, sizes, c)
+		result, err := branchAndBound(context.Background(), nil, q, sizes, c)
+		if !feasible {
+			assert.ErrorIs(t, err, ErrNoCombination, "quantity %d, constraints %+v", q, c)
+			continue
+		}
+		if !assert.NoError(t, err) {
+			continue
+		}
+		total, cost, count := packTotals(result.Packs, c)
+		assert.InDelta(t, want, c.Weights.score(total-q, count, cost), costEpsilon, "quantity %d, constraints %+v, weights %+v", q, c, *c.Weights)
+	}
+}
+
+func TestAllocateWithConstraints(t *testing.T) {
+	storage := newMockStorage()
+	allocator := NewAllocator([]int{250, 500, 1000}, storage)
+
+	result, err := allocator.Allocate(context.Background(), Request{
+		Quantity:    1000,
+		Constraints: &Constraints{Available: map[int]int{1000: 0}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{500: 2}, result.Packs)
+	assert.Equal(t, map[int]int{500: 2}, storage.allocations[1000].Packs)
+
+	_, err = allocator.Allocate(context.Background(), Request{
+		Quantity:    1000,
+		Constraints: &Constraints{Available: map[int]int{1000: 0, 500: 0, 250: 3}},
+	})
+	var infeasible *InfeasibleError
+	assert.ErrorAs(t, err, &infeasible)
+	assert.Equal(t, ConstrainedStrategy, infeasible.Strategy)
+
+	_, err = allocator.Allocate(context.Background(), Request{
+		Quantity:    1000,
+		Strategy:    "dp",
+		Constraints: &Constraints{MaxPacks: 2},
+	})
+	assert.ErrorIs(t, err, ErrConstraintsUnsupported)
+
+	_, err = allocator.Allocate(context.Background(), Request{
+		Quantity:    1000,
+		Constraints: &Constraints{Costs: map[int]float64{500: -1}},
+	})
+	assert.ErrorIs(t, err, ErrInvalidConstraints)
+	_, err = allocator.Allocate(context.Background(), Request{
+		Quantity:    1000,
+		Constraints: &Constraints{Weights: &Weights{Waste: -1, Packs: 1}},
+	})
+	assert.ErrorIs(t, err, ErrInvalidConstraints)
+	_, err = allocator.Allocate(context.Background(), Request{
+		Quantity:    1000,
+		Constraints: &Constraints{Weights: &Weights{}},
+	})
+	assert.ErrorIs(t, err, ErrInvalidConstraints)
+
+	// Weights alone are solved with branchbound
+	result, err = allocator.Allocate(context.Background(), Request{
+		Quantity:    750,
+		Constraints: &Constraints{Weights: &Weights{Waste: 1, Packs: 500}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{1000: 1}, result.Packs)
+	assert.Equal(t, ConstrainedStrategy, result.Stats.Strategy)
+
+	// Empty constraints behave like an ordinary request
+	_, err = allocator.Allocate(context.Background(), Request{
+		Quantity:    1000,
+		Strategy:    "dp",
+		Constraints: &Constraints{},
+	})
+	assert.NoError(t, err)
+}
+
+func TestAllocateWithMaxCounts(t *testing.T) {
+	ctx := context.Background()
+	allocator := NewAllocator([]int{250, 500, 1000, 2000, 5000}, newMockStorage())
+
+	result, err := allocator.Allocate(ctx, Request{
+		Quantity:    12000,
+		Constraints: &Constraints{MaxCounts: map[int]int{5000: 1}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{5000: 1, 2000: 3, 1000: 1}, result.Packs)
+
+	// The lower of stock and max count applies
+	result, err = allocator.Allocate(ctx, Request{
+		Quantity:    12000,
+		Constraints: &Constraints{Available: map[int]int{5000: 1}, MaxCounts: map[int]int{5000: 2}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Packs[5000])
+
+	_, err = allocator.Allocate(ctx, Request{
+		Quantity:    1000,
+		Constraints: &Constraints{MaxCounts: map[int]int{500: -1}},
+	})
+	assert.ErrorIs(t, err, ErrInvalidConstraints)
+}
+
+func TestAllocateWithShortfall(t *testing.T) {
+	ctx := context.Background()
+	storage := newMockStorage()
+	allocator := NewAllocator([]int{250, 500, 1000}, storage)
+	assert.NoError(t, allocator.RecordProfileVersions())
+	allocator.SetResultCache(true)
+
+	// The stock is allocated and the rest reported
+	result, err := allocator.Allocate(ctx, Request{
+		Quantity:    1200,
+		Constraints: &Constraints{Available: map[int]int{1000: 0, 500: 1, 250: 2}, AllowShortfall: true},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{500: 1, 250: 2}, result.Packs)
+	assert.Equal(t, 1000, result.Total)
+	assert.Equal(t, 200, result.Shortfall)
+	assert.Equal(t, 1000, storage.allocations[1200].Total)
+
+	// Stock that covers the quantity is allocated as usual
+	result, err = allocator.Allocate(ctx, Request{
+		Quantity:    1200,
+		Constraints: &Constraints{Available: map[int]int{1000: 0, 500: 3}, AllowShortfall: true},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{500: 2, 250: 1}, result.Packs)
+	assert.Zero(t, result.Shortfall)
+
+	// Max packs keeps the largest packs
+	result, err = allocator.Allocate(ctx, Request{
+		Quantity:    5000,
+		Constraints: &Constraints{Available: map[int]int{1000: 2}, MaxPacks: 3, AllowShortfall: true},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{1000: 2, 500: 1}, result.Packs)
+	assert.Equal(t, 2500, result.Shortfall)
+
+	// No stock at all
+	result, err = allocator.Allocate(ctx, Request{
+		Quantity:    100,
+		Constraints: &Constraints{Available: map[int]int{1000: 0, 500: 0, 250: 0}, AllowShortfall: true},
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, result.Packs)
+	assert.Equal(t, 100, result.Shortfall)
+
+	// A stored shortfall is not served from the result cache
+	_, err = allocator.Allocate(ctx, Request{
+		Quantity:    1200,
+		Constraints: &Constraints{Available: map[int]int{1000: 0, 500: 1, 250: 2}, AllowShortfall: true},
+	})
+	assert.NoError(t, err)
+	result, err = allocator.Allocate(ctx, Request{Quantity: 1200})
+	assert.NoError(t, err)
+	assert.Equal(t, CacheMiss, result.Stats.Cache)
+	assert.Equal(t, 1250, result.Total)
+}
+
+func TestPackLimits(t *testing.T) {
+	ctx := context.Background()
+	allocator := NewAllocator([]int{250, 500, 1000, 2000, 5000}, newMockStorage())
+	assert.NoError(t, allocator.SetProfiles(map[string][]int{"bulk": {5000}}, nil))
+
+	assert.ErrorIs(t, allocator.SetPackLimits(map[string]map[int]int{"missing": {5000: 1}}), ErrUnknownProfile)
+	assert.ErrorIs(t, allocator.SetPackLimits(map[string]map[int]int{"default": {300: 1}}), ErrInvalidConstraints)
+	assert.ErrorIs(t, allocator.SetPackLimits(map[string]map[int]int{"default": {5000: -1}}), ErrInvalidConstraints)
+	assert.NoError(t, allocator.SetPackLimits(map[string]map[int]int{"default": {5000: 2}, "bulk": {5000: 2}}))
+
+	result, err := allocator.Allocate(ctx, Request{Quantity: 20000})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{5000: 2, 2000: 5}, result.Packs)
+	assert.Equal(t, ConstrainedStrategy, result.Stats.Strategy)
+
+	// A request's own max count can only tighten the profile's
+	result, err = allocator.Allocate(ctx, Request{
+		Quantity:    20000,
+		Constraints: &Constraints{MaxCounts: map[int]int{5000: 3}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Packs[5000])
+
+	// Strategies other than branchbound cannot honour the limits
+	_, err = allocator.Allocate(ctx, Request{Quantity: 20000, Strategy: "dp"})
+	assert.ErrorIs(t, err, ErrConstraintsUnsupported)
+
+	_, err = allocator.Allocate(ctx, Request{Quantity: 12000, Profile: "bulk"})
+	var infeasible *InfeasibleError
+	assert.ErrorAs(t, err, &infeasible)
+	assert.True(t, infeasible.Constrained)
+	assert.Contains(t, err.Error(), "within the pack constraints and limits")
+}
@@ -0,0 +1,28 @@
+package allocator
+
+// Allocations that tie on waste and pack count are broken canonically, so
+// every run, replica and exact strategy returns the same packs for the same
+// quantity and sizes, and caches, diffs and audits stay stable: the one with
+// more packs of the largest size where the two differ wins. For sizes
+// {5, 4, 3} and quantity 8 that is 5+3 rather than 4+4.
+
+// largerPacksFirst reports whether packs a come before packs b in the
+// canonical order. sizes must be sorted in descending order and hold every
+// size of a and b.
+func largerPacksFirst(a, b map[int]int, sizes []int) bool {
+	for _, size := range sizes {
+		if a[size] != b[size] {
+			return a[size] > b[size]
+		}
+	}
+	return false
+}
+
+// packCount returns the number of packs in packs.
+func packCount(packs map[int]int) int {
+	n := 0
+	for _, count := range packs {
+		n += count
+	}
+	return n
+}
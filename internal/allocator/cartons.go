@@ -0,0 +1,314 @@
+package allocator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrCartons reports an allocation whose packs cannot be fitted into the
+// configured cartons: none are configured, a pack size has no dimensions, or
+// a pack is too large or heavy for every carton.
+var ErrCartons = infeasible("cannot fit packs into cartons")
+
+// Dimensions are the outer size of a pack in millimetres and its weight in
+// grams. A zero weight is not counted against carton weight limits.
+type Dimensions struct {
+	Length int
+	Width  int
+	Height int
+	Weight int
+}
+
+func (d Dimensions) volume() int64 {
+	return int64(d.Length) * int64(d.Width) * int64(d.Height)
+}
+
+// sorted returns the three sides smallest first, so two boxes can be compared
+// regardless of orientation.
+func (d Dimensions) sorted() [3]int {
+	sides := [3]int{d.Length, d.Width, d.Height}
+	sort.Ints(sides[:])
+	return sides
+}
+
+func (d Dimensions) validate() error {
+	if d.Length <= 0 || d.Width <= 0 || d.Height <= 0 || d.Weight < 0 {
+		return errors.New("sides must be positive and weight non-negative")
+	}
+	return nil
+}
+
+// Carton is a shipping container packs are fitted into, such as a carton or
+// a pallet: its inner size in millimetres and the weight in grams it may
+// hold, zero for no limit.
+type Carton struct {
+	Name      string
+	Length    int
+	Width     int
+	Height    int
+	MaxWeight int
+}
+
+func (c Carton) inner() Dimensions {
+	return Dimensions{Length: c.Length, Width: c.Width, Height: c.Height}
+}
+
+// holds reports whether a single pack fits the carton in some orientation.
+func (c Carton) holds(d Dimensions) bool {
+	if c.MaxWeight > 0 && d.Weight > c.MaxWeight {
+		return false
+	}
+	inner, outer := c.inner().sorted(), d.sorted()
+	for i := range inner {
+		if outer[i] > inner[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// CartonLoad is Count cartons of one type holding the same packs.
+type CartonLoad struct {
+	Carton string
+	Count  int
+	// Packs maps pack size to the number of packs in each carton.
+	Packs map[int]int
+	// Weight is the weight of the packs in each carton in grams.
+	Weight int
+	// Fill is the share of each carton's volume the packs take up.
+	Fill float64
+}
+
+// CartonPlan is how the packs of an allocation ship in cartons.
+type CartonPlan struct {
+	Loads []CartonLoad
+	// Cartons is the number of cartons, Weight the weight of all the packs
+	// in grams.
+	Cartons int
+	Weight  int
+}
+
+// SetPackDimensions sets the dimensions of the packs of each profile, e.g.
+// {"default": {250: {Length: 300, Width: 200, Height: 150, Weight: 2500}}}.
+// Sizes must be pack sizes of the profile; sizes without dimensions cannot be
+// fitted into cartons.
+func (a *Allocator) SetPackDimensions(dimensions map[string]map[int]Dimensions) error {
+	return a.updateConfig(func(next *snapshot) error {
+		copied := make(map[string]map[int]Dimensions, len(dimensions))
+		for name, bySize := range dimensions {
+			sizes, err := next.sizes(name)
+			if err != nil {
+				return fmt.Errorf("pack dimensions: %w", err)
+			}
+			for size, d := range bySize {
+				if !containsSize(sizes, size) {
+					return fmt.Errorf("pack dimensions: %d is not a pack size of profile %q", size, name)
+				}
+				if err := d.validate(); err != nil {
+					return fmt.Errorf("pack dimensions of size %d of profile %q: %w", size, name, err)
+				}
+			}
+			if name == "" {
+				name = DefaultProfile
+			}
+			if len(bySize) > 0 {
+				copied[name] = bySize
+			}
+		}
+		next.packDimensions = copied
+		return nil
+	})
+}
+
+// SetCartons sets the cartons FitCartons may use. Names must be unique, sides
+// positive and weight limits non-negative.
+func (a *Allocator) SetCartons(cartons []Carton) error {
+	seen := make(map[string]bool, len(cartons))
+	for _, c := range cartons {
+		if c.Name == "" || seen[c.Name] {
+			return fmt.Errorf("cartons: missing or duplicate name %q", c.Name)
+		}
+		seen[c.Name] = true
+		if err := c.inner().validate(); err != nil || c.MaxWeight < 0 {
+			return fmt.Errorf("carton %q: sides must be positive and max weight non-negative", c.Name)
+		}
+	}
+	// Smallest first, so the first carton that holds a load is the best fit.
+	sorted := append([]Carton(nil), cartons...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].inner().volume() < sorted[j].inner().volume()
+	})
+	return a.updateConfig(func(next *snapshot) error {
+		next.cartons = sorted
+		return nil
+	})
+}
+
+// Cartons returns the configured cartons, smallest first.
+func (a *Allocator) Cartons() []Carton {
+	return a.config().cartons
+}
+
+// FitCartons estimates how the packs of an allocation of a profile ship in
+// the configured cartons. Packs are fitted by volume and weight, largest
+// first, into the largest carton, each pack fitting the carton's sides in
+// some orientation; every filled carton is then swapped for the smallest one
+// that holds its contents. Fitting by volume ignores gaps between packs, so
+// the plan is an estimate for freight quotes, not a loading instruction.
+func (a *Allocator) FitCartons(profile string, packs map[int]int) (CartonPlan, error) {
+	cfg := a.config()
+	if len(cfg.cartons) == 0 {
+		return CartonPlan{}, fmt.Errorf("%w: no cartons configured", ErrCartons)
+	}
+	if profile == "" {
+		profile = DefaultProfile
+	}
+
+	type item struct {
+		size int
+		dims Dimensions
+		left int
+	}
+	var items []item
+	for size, n := range packs {
+		if n <= 0 {
+			continue
+		}
+		d, ok := cfg.packDimensions[profile][size]
+		if !ok {
+			return CartonPlan{}, fmt.Errorf("%w: no dimensions for pack size %d of profile %q", ErrCartons, size, profile)
+		}
+		items = append(items, item{size: size, dims: d, left: n})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		vi, vj := items[i].dims.volume(), items[j].dims.volume()
+		if vi != vj {
+			return vi > vj
+		}
+		return items[i].size > items[j].size
+	})
+
+	// Fill the largest carton that holds the largest pack left, then whatever
+	// smaller packs still fit in it, until no packs are left. Packs the
+	// carton cannot hold wait for a later one.
+	var plan CartonPlan
+	for {
+		first := -1
+		for i, it := range items {
+			if it.left > 0 {
+				first = i
+				break
+			}
+		}
+		if first < 0 {
+			break
+		}
+		carton, ok := largestHolding(cfg.cartons, items[first].dims)
+		if !ok {
+			return CartonPlan{}, fmt.Errorf("%w: pack size %d of profile %q fits no carton", ErrCartons, items[first].size, profile)
+		}
+
+		load := CartonLoad{Packs: make(map[int]int)}
+		space, capacity := carton.inner().volume(), carton.MaxWeight
+		var used int64
+		for i := range items {
+			it := &items[i]
+			if it.left == 0 || !carton.holds(it.dims) {
+				continue
+			}
+			n := int64(it.left)
+			if fit := (space - used) / it.dims.volume(); fit < n {
+				n = fit
+			}
+			if capacity > 0 && it.dims.Weight > 0 {
+				if fit := int64(capacity-load.Weight) / int64(it.dims.Weight); fit < n {
+					n = fit
+				}
+			}
+			if n <= 0 {
+				continue
+			}
+			load.Packs[it.size] = int(n)
+			used += n * it.dims.volume()
+			load.Weight += int(n) * it.dims.Weight
+		}
+
+		// The same carton repeats while every size it holds has as many
+		// packs left as it took.
+		load.Count = -1
+		for i := range items {
+			it := &items[i]
+			if n := load.Packs[it.size]; n > 0 {
+				if repeats := it.left / n; load.Count < 0 || repeats < load.Count {
+					load.Count = repeats
+				}
+			}
+		}
+		for i := range items {
+			items[i].left -= load.Count * load.Packs[items[i].size]
+		}
+
+		load.Carton = carton.Name
+		if smallest, ok := smallestHolding(cfg.cartons, cfg.packDimensions[profile], load.Packs, used, load.Weight); ok {
+			carton = smallest
+			load.Carton = smallest.Name
+		}
+		load.Fill = float64(used) / float64(carton.inner().volume())
+		plan.add(load)
+	}
+	return plan, nil
+}
+
+// largestHolding returns the largest carton that holds a pack of d.
+func largestHolding(cartons []Carton, d Dimensions) (Carton, bool) {
+	for i := len(cartons) - 1; i >= 0; i-- {
+		if cartons[i].holds(d) {
+			return cartons[i], true
+		}
+	}
+	return Carton{}, false
+}
+
+// smallestHolding returns the smallest carton with room for the volume and
+// weight of packs and sides that hold each of them.
+func smallestHolding(cartons []Carton, dims map[int]Dimensions, packs map[int]int, volume int64, weight int) (Carton, bool) {
+next:
+	for _, c := range cartons {
+		if c.inner().volume() < volume || c.MaxWeight > 0 && weight > c.MaxWeight {
+			continue
+		}
+		for size := range packs {
+			if !c.holds(dims[size]) {
+				continue next
+			}
+		}
+		return c, true
+	}
+	return Carton{}, false
+}
+
+// add merges load into the plan, combining it with an identical load.
+func (p *CartonPlan) add(load CartonLoad) {
+	p.Cartons += load.Count
+	p.Weight += load.Count * load.Weight
+	for i, l := range p.Loads {
+		if l.Carton == load.Carton && equalPacks(l.Packs, load.Packs) {
+			p.Loads[i].Count += load.Count
+			return
+		}
+	}
+	p.Loads = append(p.Loads, load)
+}
+
+func equalPacks(a, b map[int]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for size, n := range a {
+		if b[size] != n {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+package allocator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newCartonAllocator(t *testing.T) *Allocator {
+	a := NewAllocator([]int{23, 31, 53}, nil)
+	assert.NoError(t, a.SetPackDimensions(map[string]map[int]Dimensions{
+		DefaultProfile: {
+			23: {Length: 200, Width: 150, Height: 100, Weight: 1200},
+			31: {Length: 250, Width: 200, Height: 100, Weight: 1600},
+			53: {Length: 300, Width: 250, Height: 150, Weight: 2700},
+		},
+	}))
+	assert.NoError(t, a.SetCartons([]Carton{
+		{Name: "large", Length: 600, Width: 400, Height: 400, MaxWeight: 30000},
+		{Name: "small", Length: 400, Width: 300, Height: 300, MaxWeight: 15000},
+	}))
+	return a
+}
+
+func TestFitCartons(t *testing.T) {
+	a := newCartonAllocator(t)
+	assert.Equal(t, []string{"small", "large"}, []string{a.Cartons()[0].Name, a.Cartons()[1].Name})
+
+	tests := []struct {
+		name  string
+		packs map[int]int
+		want  CartonPlan
+	}{
+		{
+			// Eight 53s fill the large carton by volume, a 31 fits the gap,
+			// and the last two 53s move to a small carton
+			name:  "mixed",
+			packs: map[int]int{53: 10, 31: 1},
+			want: CartonPlan{
+				Loads: []CartonLoad{
+					{Carton: "large", Count: 1, Packs: map[int]int{53: 8, 31: 1}, Weight: 23200, Fill: 95.0 / 96},
+					{Carton: "small", Count: 1, Packs: map[int]int{53: 2}, Weight: 5400, Fill: 0.625},
+				},
+				Cartons: 2,
+				Weight:  28600,
+			},
+		},
+		{
+			// Weight, not volume, limits the large carton to 25 packs
+			name:  "repeated",
+			packs: map[int]int{23: 100},
+			want: CartonPlan{
+				Loads:   []CartonLoad{{Carton: "large", Count: 4, Packs: map[int]int{23: 25}, Weight: 30000, Fill: 0.78125}},
+				Cartons: 4,
+				Weight:  120000,
+			},
+		},
+		{
+			name:  "empty",
+			packs: map[int]int{},
+			want:  CartonPlan{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := a.FitCartons("", tt.packs)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want.Cartons, plan.Cartons)
+			assert.Equal(t, tt.want.Weight, plan.Weight)
+			assert.Equal(t, len(tt.want.Loads), len(plan.Loads))
+			for i := range plan.Loads {
+				if i < len(tt.want.Loads) {
+					want, got := tt.want.Loads[i], plan.Loads[i]
+					assert.InDelta(t, want.Fill, got.Fill, 1e-9)
+					want.Fill, got.Fill = 0, 0
+					assert.Equal(t, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestFitCartonsErrors(t *testing.T) {
+	a := NewAllocator([]int{23, 31, 53}, nil)
+	_, err := a.FitCartons("", map[int]int{23: 1})
+	assert.ErrorIs(t, err, ErrCartons)
+
+	a = newCartonAllocator(t)
+	assert.NoError(t, a.SetPackDimensions(map[string]map[int]Dimensions{
+		DefaultProfile: {23: {Length: 200, Width: 150, Height: 100}, 53: {Length: 700, Width: 100, Height: 100}},
+	}))
+	_, err = a.FitCartons("", map[int]int{31: 1})
+	assert.ErrorIs(t, err, ErrCartons)
+	assert.Contains(t, err.Error(), "no dimensions for pack size 31")
+	_, err = a.FitCartons("", map[int]int{53: 1})
+	assert.ErrorIs(t, err, ErrCartons)
+	assert.Contains(t, err.Error(), "pack size 53 of profile \"default\" fits no carton")
+
+	// Packs without a weight only take up volume
+	plan, err := a.FitCartons("", map[int]int{23: 40})
+	assert.NoError(t, err)
+	assert.Equal(t, []CartonLoad{{Carton: "large", Count: 1, Packs: map[int]int{23: 32}, Fill: 1}, {Carton: "small", Count: 1, Packs: map[int]int{23: 8}, Fill: 24.0 / 36}}, plan.Loads)
+
+	assert.Error(t, a.SetPackDimensions(map[string]map[int]Dimensions{DefaultProfile: {24: {Length: 1, Width: 1, Height: 1}}}))
+	assert.Error(t, a.SetPackDimensions(map[string]map[int]Dimensions{DefaultProfile: {23: {Length: 1, Width: 1}}}))
+	assert.Error(t, a.SetPackDimensions(map[string]map[int]Dimensions{"unknown": {23: {Length: 1, Width: 1, Height: 1}}}))
+	assert.Error(t, a.SetCartons([]Carton{{Name: "a", Length: 1, Width: 1, Height: 1}, {Name: "a", Length: 2, Width: 2, Height: 2}}))
+	assert.Error(t, a.SetCartons([]Carton{{Name: "a", Length: 1, Width: 1, Height: 1, MaxWeight: -1}}))
+}
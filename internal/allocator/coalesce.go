@@ -0,0 +1,102 @@
+package allocator
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+)
+
+// CacheShared is the cache status of a result computed once for several
+// identical concurrent requests, reported to every request but the one that
+// started the calculation.
+const CacheShared = "shared"
+
+// flights coalesces identical concurrent calculations, in the manner of
+// singleflight: the first request for a key starts the calculation and
+// later ones wait for its result instead of repeating it. The zero value is
+// ready to use.
+type flights struct {
+	mu sync.Mutex
+	m  map[string]*flight
+}
+
+// flightKey identifies a calculation for coalescing: besides the strategy,
+// sizes and quantity, the deprecated sizes it avoids and its soft deadline
+// change its result, so profiles sharing sizes share flights only when they
+// agree on both.
+func flightKey(strategy string, sizes []int, quantity int, deprecated []int, deadline time.Duration) string {
+	return fmt.Sprintf("%s|%v|%s", infeasibleKey(strategy, sizes, quantity), deprecated, deadline)
+}
+
+// flight is one calculation in progress and the requests waiting for it.
+type flight struct {
+	done    chan struct{}
+	result  Result
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// do returns the outcome of fn for key, calling it unless a calculation for
+// key is already in progress, in which case it waits for that one and
+// reports shared. fn runs with a context detached from every caller's, so
+// that one caller going away does not fail the others; it is cancelled once
+// all of them have, and the last one returns when fn does.
+func (g *flights) do(ctx context.Context, key string, fn func(context.Context) (Result, error)) (result Result, shared bool, err error) {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*flight)
+	}
+	f, shared := g.m[key]
+	if !shared {
+		var fctx context.Context
+		f = &flight{done: make(chan struct{})}
+		fctx, f.cancel = context.WithCancel(context.WithoutCancel(ctx))
+		g.m[key] = f
+		go func() {
+			f.result, f.err = fn(fctx)
+			g.forget(key, f)
+			close(f.done)
+		}()
+	}
+	f.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		result = f.result
+		if shared {
+			// Callers may modify their packs, so each gets its own.
+			result.Packs = maps.Clone(result.Packs)
+		}
+		return result, shared, f.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		f.waiters--
+		abandoned := f.waiters == 0 && g.m[key] == f
+		if abandoned {
+			delete(g.m, key)
+		}
+		g.mu.Unlock()
+		if abandoned {
+			// As without coalescing, the last caller returns once the
+			// calculation has stopped and released its admission slot.
+			f.cancel()
+			<-f.done
+		}
+		return Result{}, false, ctx.Err()
+	}
+}
+
+// forget stops later requests for key from joining f, which has finished,
+// and releases its context.
+func (g *flights) forget(key string, f *flight) {
+	g.mu.Lock()
+	if g.m[key] == f {
+		delete(g.m, key)
+	}
+	g.mu.Unlock()
+	f.cancel()
+}
@@ -0,0 +1,171 @@
+package allocator
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// waiting returns the number of callers waiting for key.
+func (g *flights) waiting(key string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if f, ok := g.m[key]; ok {
+		return f.waiters
+	}
+	return 0
+}
+
+func TestCoalescing(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	RegisterStrategy("coalesce-blocking", StrategyFunc(func(ctx context.Context, quantity int, sizes []int) (Result, error) {
+		calls.Add(1)
+		<-release
+		return greedyStrategy(ctx, quantity, sizes)
+	}))
+
+	a := NewAllocator([]int{23, 31, 53}, nil)
+	key := flightKey("coalesce-blocking", []int{53, 31, 23}, 500, nil, 0)
+	const callers = 10
+	results := make(chan Result, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := a.Allocate(context.Background(), Request{Quantity: 500, Strategy: "coalesce-blocking"})
+			assert.NoError(t, err)
+			results <- result
+		}()
+	}
+	assert.Eventually(t, func() bool { return a.inFlight.waiting(key) == callers }, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	assert.Equal(t, int32(1), calls.Load())
+	shared := 0
+	var packs []map[int]int
+	for result := range results {
+		assert.GreaterOrEqual(t, result.Total, 500)
+		if result.Stats.Cache == CacheShared {
+			shared++
+		}
+		packs = append(packs, result.Packs)
+	}
+	assert.Equal(t, callers-1, shared)
+	// Every caller gets its own copy of the packs.
+	packs[0][53]++
+	assert.NotEqual(t, packs[0], packs[1])
+
+	// Once finished, the same quantity is calculated again.
+	_, err := a.Allocate(context.Background(), Request{Quantity: 500, Strategy: "coalesce-blocking"})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestCoalescingCancellation(t *testing.T) {
+	var calls atomic.Int32
+	stopped := make(chan struct{}, 1)
+	release := make(chan struct{})
+	RegisterStrategy("coalesce-cancel", StrategyFunc(func(ctx context.Context, quantity int, sizes []int) (Result, error) {
+		calls.Add(1)
+		select {
+		case <-release:
+			return greedyStrategy(ctx, quantity, sizes)
+		case <-ctx.Done():
+			stopped <- struct{}{}
+			return Result{}, ctx.Err()
+		}
+	}))
+
+	a := NewAllocator([]int{23, 31, 53}, nil)
+	key := flightKey("coalesce-cancel", []int{53, 31, 23}, 500, nil, 0)
+	req := Request{Quantity: 500, Strategy: "coalesce-cancel"}
+
+	// The caller that started the calculation going away does not fail the
+	// others.
+	first, cancelFirst := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := a.Allocate(first, req)
+		errs <- err
+	}()
+	assert.Eventually(t, func() bool { return a.inFlight.waiting(key) == 1 }, time.Second, time.Millisecond)
+	done := make(chan Result, 1)
+	go func() {
+		result, err := a.Allocate(context.Background(), req)
+		assert.NoError(t, err)
+		done <- result
+	}()
+	assert.Eventually(t, func() bool { return a.inFlight.waiting(key) == 2 }, time.Second, time.Millisecond)
+	cancelFirst()
+	assert.ErrorIs(t, <-errs, context.Canceled)
+	close(release)
+	assert.Equal(t, CacheShared, (<-done).Stats.Cache)
+	assert.Equal(t, int32(1), calls.Load())
+
+	// Once every caller has gone, the calculation is cancelled.
+	release = make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		_, err := a.Allocate(ctx, req)
+		errs <- err
+	}()
+	assert.Eventually(t, func() bool { return a.inFlight.waiting(key) == 1 }, time.Second, time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-errs, context.Canceled)
+	select {
+	case <-stopped:
+	default:
+		assert.Fail(t, "calculation not cancelled before the last caller returned")
+	}
+	assert.Zero(t, a.inFlight.waiting(key))
+}
+
+func TestCoalescingPerProfileSettings(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	RegisterStrategy("coalesce-profiles", StrategyFunc(func(ctx context.Context, quantity int, sizes []int) (Result, error) {
+		calls.Add(1)
+		<-release
+		return dpStrategy(ctx, quantity, sizes)
+	}))
+
+	a := NewAllocator([]int{23, 31, 53}, newMockStorage())
+	assert.NoError(t, a.SetProfiles(map[string][]int{"legacy": {23, 31, 53}, "slow": {23, 31, 53}}, nil))
+	assert.NoError(t, a.SetDeprecatedSizes(map[string][]int{"legacy": {53}}))
+	_, err := a.SetProfileDefaults(context.Background(), "slow", ProfileDefaults{Timeout: time.Hour})
+	assert.NoError(t, err)
+
+	// Profiles with the same sizes but other deprecations or deadlines do
+	// not share a calculation, so none gets another's packs.
+	sizes := []int{53, 31, 23}
+	results := make(map[string]chan Result)
+	for _, profile := range []string{"", "legacy", "slow"} {
+		done := make(chan Result, 1)
+		results[profile] = done
+		go func() {
+			result, err := a.Allocate(context.Background(), Request{Quantity: 115, Strategy: "coalesce-profiles", Profile: profile})
+			assert.NoError(t, err)
+			done <- result
+		}()
+	}
+	assert.Eventually(t, func() bool {
+		return a.inFlight.waiting(flightKey("coalesce-profiles", sizes, 115, nil, 0)) == 1 &&
+			a.inFlight.waiting(flightKey("coalesce-profiles", sizes, 115, []int{53}, 0)) == 1 &&
+			a.inFlight.waiting(flightKey("coalesce-profiles", sizes, 115, nil, time.Hour)) == 1
+	}, time.Second, time.Millisecond)
+	close(release)
+	assert.Equal(t, map[int]int{53: 1, 31: 2}, (<-results[""]).Packs)
+	legacy := <-results["legacy"]
+	assert.Equal(t, CacheMiss, legacy.Stats.Cache)
+	assert.Equal(t, map[int]int{23: 5}, legacy.Packs)
+	assert.Equal(t, CacheMiss, (<-results["slow"]).Stats.Cache)
+	assert.Equal(t, int32(3), calls.Load())
+}
@@ -0,0 +1,181 @@
+package allocator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/n-th/gymshark/internal/invalidation"
+)
+
+// ErrNotPropagated is returned when a change was applied locally but could
+// not be published to the other replicas.
+var ErrNotPropagated = errors.New("change applied locally but not propagated to other replicas")
+
+// resubscribeDelay is how long ListenForInvalidations waits before
+// resubscribing after the subscription fails.
+const resubscribeDelay = time.Second
+
+// SetInvalidationBus connects the allocator to the replicas sharing bus.
+// Cache purges and profile updates made through this allocator are published
+// on it, and ListenForInvalidations applies those made by other replicas.
+func (a *Allocator) SetInvalidationBus(bus invalidation.Bus) {
+	a.bus = bus
+	a.origin = invalidation.NewOrigin()
+}
+
+// PurgeCache drops every cached outcome on this and, when an invalidation
+// bus is configured, every other replica.
+func (a *Allocator) PurgeCache(ctx context.Context) error {
+	a.negative.purge()
+	return a.publish(ctx, invalidation.Event{Type: invalidation.EventPurge})
+}
+
+// UpdateProfile replaces the pack sizes of a profile at runtime, records the
+// new profile version and purges cached outcomes. The update is published to
+// the other replicas when an invalidation bus is configured, and announced as
+// a profile.changed event when an event publisher is. Updating the default
+// profile changes the allocator's own pack sizes.
+// It fails with ErrReadOnly while the allocator is read-only.
+func (a *Allocator) UpdateProfile(ctx context.Context, name string, sizes []int) (int, error) {
+	if a.ReadOnly().Enabled {
+		return 0, ErrReadOnly
+	}
+	version, previous, err := a.applyProfile(name, sizes)
+	if err != nil {
+		return 0, err
+	}
+	a.emitProfile(ctx, name, version, previous, sizes)
+	err = a.publish(ctx, invalidation.Event{Type: invalidation.EventProfile, Profile: name, PackSizes: sizes})
+	return version, err
+}
+
+// checkProfile returns why sizes cannot become the pack sizes of a profile,
+// or nil.
+func (a *Allocator) checkProfile(name string, sizes []int) error {
+	if name == "" {
+		return fmt.Errorf("%w: name is required", ErrUnknownProfile)
+	}
+	if err := validateSizes(sizes); err != nil {
+		return fmt.Errorf("profile %q: %w", name, err)
+	}
+	if err := checkRules(a.config().packRules.Check(name, sizes)); err != nil {
+		return fmt.Errorf("profile %q: %w", name, err)
+	}
+	return nil
+}
+
+// applyProfile installs sizes for a profile and purges cached outcomes. It
+// returns the new version and the sizes the profile had, nil if it is new.
+func (a *Allocator) applyProfile(name string, sizes []int) (int, []int, error) {
+	if err := a.checkProfile(name, sizes); err != nil {
+		return 0, nil, err
+	}
+	sorted := sortedSizes(sizes)
+
+	version := 0
+	var previous []int
+	err := a.updateConfig(func(next *snapshot) error {
+		previous, _ = next.sizes(name)
+		if a.storage != nil {
+			v, err := a.recordProfileVersion(name, sorted)
+			if err != nil {
+				return err
+			}
+			version = v
+		}
+
+		if name == DefaultProfile {
+			next.packSizes = sorted
+		} else {
+			profiles := make(map[string][]int, len(next.profiles)+1)
+			for n, s := range next.profiles {
+				profiles[n] = s
+			}
+			profiles[name] = sorted
+			next.profiles = profiles
+		}
+		versions := make(map[string]int, len(next.versions)+1)
+		for n, v := range next.versions {
+			versions[n] = v
+		}
+		if version > 0 {
+			versions[name] = version
+		}
+		next.versions = versions
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	a.negative.purge()
+	return version, previous, nil
+}
+
+func (a *Allocator) publish(ctx context.Context, e invalidation.Event) error {
+	if a.bus == nil {
+		return nil
+	}
+	e.Origin = a.origin
+	if err := a.bus.Publish(ctx, e); err != nil {
+		return fmt.Errorf("%w: %v", ErrNotPropagated, err)
+	}
+	return nil
+}
+
+// ListenForInvalidations applies events published by other replicas until
+// ctx is done, resubscribing if the subscription fails. Because events sent
+// while unsubscribed are lost, the local cache is purged, and pins and
+// profile defaults reloaded, on every (re)subscription. It returns immediately if no bus is configured.
+func (a *Allocator) ListenForInvalidations(ctx context.Context) {
+	if a.bus == nil {
+		return
+	}
+	for {
+		a.negative.purge()
+		if a.storage != nil {
+			if err := a.LoadPins(); err != nil {
+				log.Printf("Failed to reload pins: %v", err)
+			}
+			if err := a.LoadProfileDefaults(); err != nil {
+				log.Printf("Failed to reload profile defaults: %v", err)
+			}
+		}
+		err := a.bus.Subscribe(ctx, a.applyEvent)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Invalidation subscription failed, retrying in %s: %v", resubscribeDelay, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(resubscribeDelay):
+		}
+	}
+}
+
+// applyEvent applies an event received from the bus. The allocator's own
+// events are ignored since they were applied before being published.
+func (a *Allocator) applyEvent(e invalidation.Event) {
+	if e.Origin == a.origin {
+		return
+	}
+	switch e.Type {
+	case invalidation.EventPurge:
+		a.negative.purge()
+		log.Printf("Cache purged by replica %s", e.Origin)
+	case invalidation.EventProfile:
+		if _, _, err := a.applyProfile(e.Profile, e.PackSizes); err != nil {
+			log.Printf("Failed to apply profile %q from replica %s: %v", e.Profile, e.Origin, err)
+			return
+		}
+		log.Printf("Profile %q updated to %v by replica %s", e.Profile, e.PackSizes, e.Origin)
+	case invalidation.EventPins:
+		a.reloadPins(e.Origin)
+	case invalidation.EventProfileDefaults:
+		a.reloadProfileDefaults(e.Origin)
+	}
+}
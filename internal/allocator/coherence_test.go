@@ -0,0 +1,178 @@
+package allocator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/n-th/gymshark/internal/invalidation"
+	"github.com/stretchr/testify/assert"
+)
+
+// memBus is an in-process invalidation.Bus that delivers every event to
+// every subscriber synchronously, like Redis delivering to all replicas.
+type memBus struct {
+	mu          sync.Mutex
+	subscribers []func(invalidation.Event)
+	published   []invalidation.Event
+	failPublish bool
+}
+
+func (b *memBus) Publish(ctx context.Context, e invalidation.Event) error {
+	b.mu.Lock()
+	if b.failPublish {
+		b.mu.Unlock()
+		return errors.New("connection refused")
+	}
+	b.published = append(b.published, e)
+	subscribers := append([]func(invalidation.Event){}, b.subscribers...)
+	b.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(e)
+	}
+	return nil
+}
+
+func (b *memBus) Subscribe(ctx context.Context, fn func(invalidation.Event)) error {
+	b.mu.Lock()
+	b.subscribers = append(b.subscribers, fn)
+	b.mu.Unlock()
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (b *memBus) Close() error {
+	return nil
+}
+
+func (b *memBus) subscriberCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+// newReplicas returns n allocators with their own storage, listening on one bus.
+func newReplicas(t *testing.T, bus *memBus, n int) []*Allocator {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	replicas := make([]*Allocator, n)
+	for i := range replicas {
+		a := NewAllocator([]int{250, 500, 1000}, newMockStorage())
+		a.SetNegativeCacheTTL(time.Minute)
+		assert.NoError(t, a.SetProfiles(map[string][]int{"apparel": {10, 20}}, nil))
+		assert.NoError(t, a.RecordProfileVersions())
+		a.SetInvalidationBus(bus)
+		go a.ListenForInvalidations(ctx)
+		replicas[i] = a
+	}
+	for bus.subscriberCount() < n {
+		time.Sleep(time.Millisecond)
+	}
+	return replicas
+}
+
+func TestUpdateProfilePropagates(t *testing.T) {
+	bus := &memBus{}
+	replicas := newReplicas(t, bus, 2)
+	ctx := context.Background()
+
+	version, err := replicas[0].UpdateProfile(ctx, DefaultProfile, []int{23, 31, 53})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, version)
+
+	for _, a := range replicas {
+		result, err := a.Preview(ctx, Request{Quantity: 500})
+		assert.NoError(t, err)
+		assert.Equal(t, 500, result.Total)
+		assert.Equal(t, []int{53, 31, 23}, a.config().packSizes)
+		assert.Equal(t, 2, a.profileVersion(DefaultProfile))
+	}
+
+	_, err = replicas[1].UpdateProfile(ctx, "apparel", []int{7})
+	assert.NoError(t, err)
+	for _, a := range replicas {
+		sizes, err := a.profileSizes("apparel")
+		assert.NoError(t, err)
+		assert.Equal(t, []int{7}, sizes)
+	}
+}
+
+func TestUpdateProfilePurgesCache(t *testing.T) {
+	bus := &memBus{}
+	replicas := newReplicas(t, bus, 2)
+	for _, a := range replicas {
+		a.negative.put(infeasibleKey("dp", []int{20, 10}, 7), &InfeasibleError{Quantity: 7})
+	}
+
+	_, err := replicas[0].UpdateProfile(context.Background(), "apparel", []int{1, 10, 20})
+	assert.NoError(t, err)
+	for _, a := range replicas {
+		assert.Equal(t, 0, a.negative.len())
+		result, err := a.Preview(context.Background(), Request{Quantity: 7, Profile: "apparel", Strategy: "dp"})
+		assert.NoError(t, err)
+		assert.Equal(t, 7, result.Total)
+	}
+}
+
+func TestPurgeCachePropagates(t *testing.T) {
+	bus := &memBus{}
+	replicas := newReplicas(t, bus, 3)
+	for _, a := range replicas {
+		a.negative.put("key", ErrNoCombination)
+	}
+
+	assert.NoError(t, replicas[2].PurgeCache(context.Background()))
+	for _, a := range replicas {
+		assert.Equal(t, 0, a.negative.len())
+	}
+	if assert.Len(t, bus.published, 1) {
+		assert.Equal(t, invalidation.EventPurge, bus.published[0].Type)
+		assert.Equal(t, replicas[2].origin, bus.published[0].Origin)
+	}
+}
+
+func TestUpdateProfileErrors(t *testing.T) {
+	bus := &memBus{}
+	a := newReplicas(t, bus, 1)[0]
+	ctx := context.Background()
+
+	_, err := a.UpdateProfile(ctx, "apparel", nil)
+	assert.ErrorIs(t, err, ErrNoPackSizes)
+	_, err = a.UpdateProfile(ctx, "apparel", []int{10, -1})
+	assert.ErrorIs(t, err, ErrInvalidPackSize)
+	assert.Empty(t, bus.published)
+
+	assert.NoError(t, a.SetReadOnly(ReadOnly{Enabled: true}))
+	_, err = a.UpdateProfile(ctx, "apparel", []int{5})
+	assert.ErrorIs(t, err, ErrReadOnly)
+	assert.NoError(t, a.SetReadOnly(ReadOnly{}))
+
+	// A failed publish still applies the update locally
+	bus.failPublish = true
+	_, err = a.UpdateProfile(ctx, "apparel", []int{5})
+	assert.ErrorIs(t, err, ErrNotPropagated)
+	sizes, _ := a.profileSizes("apparel")
+	assert.Equal(t, []int{5}, sizes)
+}
+
+func TestApplyEventFromReplicaWhileReadOnly(t *testing.T) {
+	a := NewAllocator([]int{250, 500}, newMockStorage())
+	a.SetInvalidationBus(&memBus{})
+	assert.NoError(t, a.SetReadOnly(ReadOnly{Enabled: true}))
+
+	// Updates from other replicas are applied but not recorded locally
+	a.applyEvent(invalidation.Event{Type: invalidation.EventProfile, Profile: DefaultProfile, PackSizes: []int{100}, Origin: "other"})
+	assert.Equal(t, []int{100}, a.config().packSizes)
+	versions, err := a.ProfileVersions(DefaultProfile)
+	assert.NoError(t, err)
+	assert.Empty(t, versions)
+
+	// The allocator's own events are ignored
+	a.applyEvent(invalidation.Event{Type: invalidation.EventProfile, Profile: DefaultProfile, PackSizes: []int{1}, Origin: a.origin})
+	assert.Equal(t, []int{100}, a.config().packSizes)
+}
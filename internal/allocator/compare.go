@@ -0,0 +1,101 @@
+package allocator
+
+import (
+	"context"
+	"fmt"
+)
+
+var ErrNoPackSizeSets = invalid("at least two pack-size sets are needed to compare")
+
+// PackSizeSet is a named pack-size set for comparison.
+type PackSizeSet struct {
+	Name  string
+	Sizes []int
+	// Costs weighs each pack size; sizes not listed cost 1, so without costs
+	// the cost is the pack count.
+	Costs map[int]float64
+}
+
+// ComparisonOutcome is one set's allocation for the compared quantity.
+type ComparisonOutcome struct {
+	Name  string
+	Sizes []int
+	SimulationOutcome
+	Cost        float64
+	Approximate bool
+}
+
+// Comparison is the allocation of one quantity under several pack-size
+// sets. The Best fields index Outcomes; ties go to the earlier set.
+type Comparison struct {
+	Quantity int
+	Outcomes []ComparisonOutcome
+	// Best has the least waste, then the fewest packs, then the lowest cost,
+	// the same order the allocator itself optimises in.
+	Best        int
+	BestByWaste int
+	BestByPacks int
+	BestByCost  int
+}
+
+// Compare allocates quantity under every set and ranks them by waste, pack
+// count and cost. Sets without a name are called set-1, set-2 and so on.
+// Nothing is written to storage.
+func (a *Allocator) Compare(ctx context.Context, quantity int, sets []PackSizeSet, strategyName string) (Comparison, error) {
+	if len(sets) < 2 {
+		return Comparison{}, ErrNoPackSizeSets
+	}
+	if err := a.checkBatchSize(len(sets)); err != nil {
+		return Comparison{}, err
+	}
+
+	cmp := Comparison{Quantity: quantity, Outcomes: make([]ComparisonOutcome, 0, len(sets))}
+	names := make(map[string]bool, len(sets))
+	for i, set := range sets {
+		name := set.Name
+		if name == "" {
+			name = fmt.Sprintf("set-%d", i+1)
+		}
+		if names[name] {
+			return Comparison{}, invalidf("duplicate pack-size set name %q", name)
+		}
+		names[name] = true
+
+		costs := Constraints{Costs: set.Costs}
+		if err := costs.validate(); err != nil {
+			return Comparison{}, fmt.Errorf("set %q: %w", name, err)
+		}
+		result, err := a.Evaluate(ctx, quantity, set.Sizes, strategyName)
+		if err != nil {
+			return Comparison{}, fmt.Errorf("set %q: %w", name, err)
+		}
+
+		o := ComparisonOutcome{
+			Name:              name,
+			Sizes:             sortedSizes(set.Sizes),
+			SimulationOutcome: outcome(quantity, result),
+			Approximate:       result.Approximate,
+		}
+		for size, n := range result.Packs {
+			o.Cost += float64(n) * costs.cost(size)
+		}
+		cmp.Outcomes = append(cmp.Outcomes, o)
+	}
+
+	for i, o := range cmp.Outcomes {
+		best, byWaste, byPacks, byCost := cmp.Outcomes[cmp.Best], cmp.Outcomes[cmp.BestByWaste], cmp.Outcomes[cmp.BestByPacks], cmp.Outcomes[cmp.BestByCost]
+		if o.Waste < best.Waste || o.Waste == best.Waste && (o.PackCount < best.PackCount || o.PackCount == best.PackCount && o.Cost < best.Cost) {
+			cmp.Best = i
+		}
+		if o.Waste < byWaste.Waste {
+			cmp.BestByWaste = i
+		}
+		if o.PackCount < byPacks.PackCount {
+			cmp.BestByPacks = i
+		}
+		if o.Cost < byCost.Cost {
+			cmp.BestByCost = i
+		}
+	}
+	return cmp, nil
+}
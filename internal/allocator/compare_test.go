@@ -0,0 +1,67 @@
+package allocator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare(t *testing.T) {
+	store := newMockStorage()
+	allocator := NewAllocator([]int{23, 31, 53}, store)
+
+	cmp, err := allocator.Compare(context.Background(), 501, []PackSizeSet{
+		{Name: "coarse", Sizes: []int{1000, 500}},
+		{Name: "fine", Sizes: []int{250, 500, 1000}, Costs: map[int]float64{250: 5}},
+		{Sizes: []int{501}},
+	}, "dp")
+	assert.NoError(t, err)
+	assert.Equal(t, 501, cmp.Quantity)
+	if !assert.Len(t, cmp.Outcomes, 3) {
+		return
+	}
+
+	coarse, fine, exact := cmp.Outcomes[0], cmp.Outcomes[1], cmp.Outcomes[2]
+	assert.Equal(t, map[int]int{1000: 1}, coarse.Packs)
+	assert.Equal(t, 499, coarse.Waste)
+	assert.Equal(t, 1.0, coarse.Cost)
+
+	assert.Equal(t, []int{1000, 500, 250}, fine.Sizes)
+	assert.Equal(t, map[int]int{500: 1, 250: 1}, fine.Packs)
+	assert.Equal(t, 249, fine.Waste)
+	assert.Equal(t, 6.0, fine.Cost)
+
+	assert.Equal(t, "set-3", exact.Name)
+	assert.Equal(t, 0, exact.Waste)
+
+	assert.Equal(t, 2, cmp.Best)
+	assert.Equal(t, 2, cmp.BestByWaste)
+	assert.Equal(t, 0, cmp.BestByPacks, "ties go to the earlier set")
+	assert.Equal(t, 0, cmp.BestByCost)
+	assert.Empty(t, store.allocations, "comparisons are not stored")
+}
+
+func TestCompareErrors(t *testing.T) {
+	allocator := NewAllocator([]int{23, 31, 53}, newMockStorage())
+	ctx := context.Background()
+
+	_, err := allocator.Compare(ctx, 10, []PackSizeSet{{Sizes: []int{5}}}, "")
+	assert.ErrorIs(t, err, ErrNoPackSizeSets)
+
+	_, err = allocator.Compare(ctx, 10, []PackSizeSet{{Name: "a", Sizes: []int{5}}, {Name: "a", Sizes: []int{3}}}, "")
+	assert.ErrorContains(t, err, "duplicate")
+
+	_, err = allocator.Compare(ctx, 10, []PackSizeSet{{Sizes: []int{5}}, {Sizes: []int{0}}}, "")
+	assert.ErrorIs(t, err, ErrInvalidPackSize)
+
+	_, err = allocator.Compare(ctx, 10, []PackSizeSet{{Sizes: []int{5}}, {Sizes: []int{3}, Costs: map[int]float64{3: -1}}}, "")
+	assert.ErrorIs(t, err, ErrInvalidConstraints)
+
+	_, err = allocator.Compare(ctx, 0, []PackSizeSet{{Sizes: []int{5}}, {Sizes: []int{3}}}, "")
+	assert.ErrorIs(t, err, ErrInvalidQuantity)
+
+	allocator.SetLimits(Limits{MaxBatchSize: 2})
+	_, err = allocator.Compare(ctx, 10, []PackSizeSet{{Sizes: []int{5}}, {Sizes: []int{3}}, {Sizes: []int{2}}}, "")
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+}
@@ -0,0 +1,207 @@
+package allocator
+
+import (
+	"fmt"
+	"math"
+)
+
+// ConstrainedStrategy is the name of the branch-and-bound strategy, the only
+// strategy that honours Constraints.
+const ConstrainedStrategy = "branchbound"
+
+var (
+	ErrInvalidConstraints     = invalid("invalid allocation constraints")
+	ErrConstraintsUnsupported = invalid("strategy does not support constraints")
+)
+
+// Constraints restrict the pack combinations an allocation may use.
+// Unless Weights are given, the least waste always wins; among equally
+// wasteful combinations the cheapest one does.
+type Constraints struct {
+	// Available caps how many packs of each size may be used, e.g. from
+	// inventory. Sizes not listed are unlimited.
+	Available map[int]int
+	// MaxCounts caps how many packs of each size one allocation may use by
+	// policy, e.g. never more than 2 of the 5000 pack. Where a size is in
+	// both, the lower of MaxCounts and Available applies. Profile pack
+	// limits (see SetPackLimits) are merged into it.
+	MaxCounts map[int]int
+	// MaxPacks caps the total number of packs. Zero means unlimited.
+	MaxPacks int
+	// Costs weighs each pack size. Sizes not listed cost 1, so without
+	// costs the combination with the fewest packs wins.
+	Costs map[int]float64
+	// AllowShortfall makes an allocation no combination can cover within
+	// the constraints ship the largest packs they allow instead of failing,
+	// e.g. all the stock left, with the rest reported as Result.Shortfall.
+	AllowShortfall bool
+	// Weights, when set, replace the least-waste-first objective with a
+	// weighted sum of waste, pack count and cost.
+	Weights *Weights
+}
+
+// Weights score a combination as Waste*waste + Packs*packs + Cost*cost,
+// where cost sums Constraints.Costs over the packs; the lowest score wins.
+// Ties go to the least waste, then the lowest cost, then the fewest packs.
+// For example {Waste: 1, Packs: 100} accepts up to 100 more items of waste
+// to ship one pack fewer.
+type Weights struct {
+	Waste float64
+	Packs float64
+	Cost  float64
+}
+
+// score is the weighted objective of a combination.
+func (w *Weights) score(waste, packCount int, cost float64) float64 {
+	return w.Waste*float64(waste) + w.Packs*float64(packCount) + w.Cost*cost
+}
+
+// empty reports whether c constrains nothing.
+func (c *Constraints) empty() bool {
+	return c == nil || (len(c.Available) == 0 && len(c.MaxCounts) == 0 && c.MaxPacks == 0 && len(c.Costs) == 0 && c.Weights == nil)
+}
+
+func (c *Constraints) validate() error {
+	for size, n := range c.Available {
+		if n < 0 {
+			return fmt.Errorf("%w: available count for size %d is negative", ErrInvalidConstraints, size)
+		}
+	}
+	for size, n := range c.MaxCounts {
+		if n < 0 {
+			return fmt.Errorf("%w: max count for size %d is negative", ErrInvalidConstraints, size)
+		}
+	}
+	if c.MaxPacks < 0 {
+		return fmt.Errorf("%w: max packs is negative", ErrInvalidConstraints)
+	}
+	for size, cost := range c.Costs {
+		if cost < 0 || math.IsNaN(cost) || math.IsInf(cost, 0) {
+			return fmt.Errorf("%w: cost for size %d must be a non-negative number", ErrInvalidConstraints, size)
+		}
+	}
+	if w := c.Weights; w != nil {
+		for name, weight := range map[string]float64{"waste": w.Waste, "packs": w.Packs, "cost": w.Cost} {
+			if weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+				return fmt.Errorf("%w: %s weight must be a non-negative number", ErrInvalidConstraints, name)
+			}
+		}
+		if w.Waste == 0 && w.Packs == 0 && w.Cost == 0 {
+			return fmt.Errorf("%w: at least one weight must be positive", ErrInvalidConstraints)
+		}
+	}
+	return nil
+}
+
+// shortfallResult ships the largest packs c allows, at most MaxPacks of
+// them, when no combination covers quantity. That only happens when every
+// size is limited or MaxPacks is set, so the packs are finite and fall short
+// of quantity. sizes must be sorted largest first.
+func shortfallResult(quantity int, sizes []int, c Constraints) Result {
+	packs := make(map[int]int)
+	total, count := 0, 0
+	for _, size := range sizes {
+		n := c.limit(size)
+		if c.MaxPacks > 0 && (n < 0 || n > c.MaxPacks-count) {
+			n = c.MaxPacks - count
+		}
+		if n <= 0 {
+			continue
+		}
+		packs[size] = n
+		total += n * size
+		count += n
+	}
+	return Result{Packs: packs, Total: total, Shortfall: quantity - total}
+}
+
+// cost returns the weight of one pack of size.
+func (c *Constraints) cost(size int) float64 {
+	if cost, ok := c.Costs[size]; ok {
+		return cost
+	}
+	return 1
+}
+
+// limit returns how many packs of size may be used, or -1 for unlimited.
+func (c *Constraints) limit(size int) int {
+	limit := -1
+	if n, ok := c.Available[size]; ok {
+		limit = n
+	}
+	if n, ok := c.MaxCounts[size]; ok && (limit < 0 || n < limit) {
+		limit = n
+	}
+	return limit
+}
+
+// withMaxCounts returns a copy of c whose MaxCounts also include limits,
+// keeping the lower count where both cap a size, or c itself when there are
+// no limits. c may be nil.
+func (c *Constraints) withMaxCounts(limits map[int]int) *Constraints {
+	if len(limits) == 0 {
+		return c
+	}
+	var merged Constraints
+	if c != nil {
+		merged = *c
+	}
+	counts := make(map[int]int, len(merged.MaxCounts)+len(limits))
+	for size, n := range merged.MaxCounts {
+		counts[size] = n
+	}
+	for size, n := range limits {
+		if current, ok := counts[size]; !ok || n < current {
+			counts[size] = n
+		}
+	}
+	merged.MaxCounts = counts
+	return &merged
+}
+
+// SetPackLimits caps how many packs of each size a single allocation of a
+// profile may use, e.g. {"default": {5000: 2}}. Calculations for a limited
+// profile are solved with the branch-and-bound strategy, like constrained
+// requests, and combine the profile's limits with the request's constraints.
+// Limits must be non-negative and name sizes of the profile.
+func (a *Allocator) SetPackLimits(limits map[string]map[int]int) error {
+	return a.updateConfig(func(next *snapshot) error {
+		copied := make(map[string]map[int]int, len(limits))
+		for name, counts := range limits {
+			sizes, err := next.sizes(name)
+			if err != nil {
+				return fmt.Errorf("pack limits: %w", err)
+			}
+			for size, n := range counts {
+				if n < 0 {
+					return fmt.Errorf("pack limits: %w: max count for size %d of profile %q is negative", ErrInvalidConstraints, size, name)
+				}
+				if !containsSize(sizes, size) {
+					return fmt.Errorf("pack limits: %w: %d is not a pack size of profile %q", ErrInvalidConstraints, size, name)
+				}
+			}
+			if name == "" {
+				name = DefaultProfile
+			}
+			if len(counts) > 0 {
+				copied[name] = counts
+			}
+		}
+		next.packLimits = copied
+		return nil
+	})
+}
+
+// PackLimits returns the pack limits of a profile, or nil if it has none.
+func (a *Allocator) PackLimits(profile string) map[int]int {
+	return a.config().limits(profile)
+}
+
+func containsSize(sizes []int, size int) bool {
+	for _, s := range sizes {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package allocator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/n-th/gymshark/pkg/packer"
+)
+
+// SetDeprecatedSizes marks pack sizes of each profile as being phased out,
+// e.g. {"default": [250]}. Unconstrained calculations for the profile avoid
+// deprecated sizes unless no combination without them reaches the least
+// waste: among the combinations with the least waste, the one with the
+// fewest deprecated packs wins, then the one with the fewest packs.
+// Deprecated sizes must be sizes of the profile.
+func (a *Allocator) SetDeprecatedSizes(deprecated map[string][]int) error {
+	return a.updateConfig(func(next *snapshot) error {
+		copied := make(map[string][]int, len(deprecated))
+		for name, list := range deprecated {
+			sizes, err := next.sizes(name)
+			if err != nil {
+				return fmt.Errorf("deprecated sizes: %w", err)
+			}
+			for _, size := range list {
+				if !containsSize(sizes, size) {
+					return fmt.Errorf("deprecated sizes: %d is not a pack size of profile %q", size, name)
+				}
+			}
+			if name == "" {
+				name = DefaultProfile
+			}
+			if len(list) > 0 {
+				copied[name] = sortedSizes(list)
+			}
+		}
+		next.deprecatedSizes = copied
+		return nil
+	})
+}
+
+// DeprecatedSizes returns the deprecated sizes of a profile, largest first,
+// or nil if it has none.
+func (a *Allocator) DeprecatedSizes(profile string) []int {
+	return a.config().deprecated(profile)
+}
+
+// deprecatedPacks returns the packs of deprecated sizes in packs, or nil if
+// there are none.
+func deprecatedPacks(packs map[int]int, deprecated []int) map[int]int {
+	var used map[int]int
+	for _, size := range deprecated {
+		if n := packs[size]; n > 0 {
+			if used == nil {
+				used = make(map[int]int)
+			}
+			used[size] = n
+		}
+	}
+	return used
+}
+
+// avoidingDeprecated wraps a strategy so that its results use as few packs
+// of deprecated sizes as the same total allows.
+type avoidingDeprecated struct {
+	AllocationStrategy
+	deprecated []int
+}
+
+// Allocate allocates with the wrapped strategy and, when the result uses
+// deprecated sizes, replaces it with the combination of the same total using
+// the fewest deprecated packs, then the fewest packs. Approximate results
+// are returned unchanged.
+func (s avoidingDeprecated) Allocate(ctx context.Context, quantity int, sizes []int) (Result, error) {
+	result, err := s.AllocationStrategy.Allocate(ctx, quantity, sizes)
+	if err != nil || result.Approximate || deprecatedPacks(result.Packs, s.deprecated) == nil {
+		return result, err
+	}
+	packs, err := fewestDeprecated(ctx, result.Total, sizes, s.deprecated)
+	if err != nil {
+		return Result{}, err
+	}
+	if packs != nil {
+		result.Packs = packs
+	}
+	result.Stats.Iterations += result.Total * len(sizes)
+	return result, nil
+}
+
+// fewestDeprecated returns the combination of sizes summing exactly to total
+// with the fewest packs of deprecated sizes, then the fewest packs, ties
+// going to larger packs, or nil if none sums to total.
+func fewestDeprecated(ctx context.Context, total int, sizes, deprecated []int) (map[int]int, error) {
+	table, err := packer.NewTable(ctx, total, sizes, deprecated)
+	if err != nil {
+		return nil, err
+	}
+	return table.Packs(total), nil
+}
+
+// DeprecatedUsage counts the packs of a deprecated size in the stored
+// allocations of a profile.
+type DeprecatedUsage struct {
+	Profile string
+	Size    int
+	// Packs sums the packs of the size and Allocations counts the
+	// allocations using it.
+	Packs       int64
+	Allocations int64
+}
+
+// DeprecatedUsage counts the packs of currently deprecated sizes in the
+// allocations stored in [from, to), by profile and then size, largest first.
+func (a *Allocator) DeprecatedUsage(from, to time.Time) ([]DeprecatedUsage, error) {
+	if a.storage == nil {
+		return nil, ErrStorageNotConfigured
+	}
+	cfg := a.config()
+	usage := []DeprecatedUsage{}
+	if len(cfg.deprecatedSizes) == 0 {
+		return usage, nil
+	}
+	packs, err := a.storage.PackUsage(from, to)
+	if err != nil {
+		return nil, err
+	}
+	// Allocations stored before profiles were recorded have an empty one.
+	type key struct {
+		profile string
+		size    int
+	}
+	index := make(map[key]int)
+	for _, p := range packs {
+		if !containsSize(cfg.deprecated(p.Profile), p.Size) {
+			continue
+		}
+		k := key{p.Profile, p.Size}
+		if k.profile == "" {
+			k.profile = DefaultProfile
+		}
+		i, ok := index[k]
+		if !ok {
+			i = len(usage)
+			index[k] = i
+			usage = append(usage, DeprecatedUsage{Profile: k.profile, Size: k.size})
+		}
+		usage[i].Packs += p.Packs
+		usage[i].Allocations += p.Allocations
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Profile != usage[j].Profile {
+			return usage[i].Profile < usage[j].Profile
+		}
+		return usage[i].Size > usage[j].Size
+	})
+	return usage, nil
+}
@@ -0,0 +1,59 @@
+package allocator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeprecatedSizes(t *testing.T) {
+	a := NewAllocator([]int{23, 31, 53}, nil)
+	assert.NoError(t, a.SetProfiles(map[string][]int{"bulk": {100, 250}}, nil))
+	assert.ErrorIs(t, a.SetDeprecatedSizes(map[string][]int{"apparel": {10}}), ErrUnknownProfile)
+	assert.ErrorContains(t, a.SetDeprecatedSizes(map[string][]int{"bulk": {53}}), `53 is not a pack size of profile "bulk"`)
+
+	// 53+31+31 and 5x23 both make 115.
+	result, err := a.Allocate(context.Background(), Request{Quantity: 115, Strategy: "dp"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{53: 1, 31: 2}, result.Packs)
+	assert.Nil(t, result.DeprecatedPacks)
+
+	assert.NoError(t, a.SetDeprecatedSizes(map[string][]int{"default": {53}}))
+	assert.Equal(t, []int{53}, a.DeprecatedSizes(""))
+	assert.Equal(t, []int{53}, a.Profiles()[0].Deprecated)
+	for _, strategy := range []string{"combination", "dp", "backtracking"} {
+		result, err := a.Allocate(context.Background(), Request{Quantity: 115, Strategy: strategy})
+		assert.NoError(t, err, strategy)
+		assert.Equal(t, map[int]int{23: 5}, result.Packs, strategy)
+		assert.Equal(t, 115, result.Total, strategy)
+		assert.Nil(t, result.DeprecatedPacks, strategy)
+	}
+
+	// Deprecated sizes are still used for the least waste, and reported.
+	result, err = a.Allocate(context.Background(), Request{Quantity: 53})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{53: 1}, result.Packs)
+	assert.Equal(t, map[int]int{53: 1}, result.DeprecatedPacks)
+
+	// Other profiles are not affected.
+	result, err = a.Allocate(context.Background(), Request{Quantity: 250, Profile: "bulk"})
+	assert.NoError(t, err)
+	assert.Nil(t, result.DeprecatedPacks)
+}
+
+func TestFewestDeprecated(t *testing.T) {
+	sizes := []int{53, 31, 23}
+	packs, err := fewestDeprecated(context.Background(), 168, sizes, []int{53})
+	assert.NoError(t, err)
+	// 53+53+31+31 uses two deprecated packs, 53+23+23+23+23+23 one.
+	assert.Equal(t, map[int]int{53: 1, 23: 5}, packs)
+
+	packs, err = fewestDeprecated(context.Background(), 168, sizes, []int{23})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{53: 2, 31: 2}, packs)
+
+	packs, err = fewestDeprecated(context.Background(), 24, sizes, []int{53})
+	assert.NoError(t, err)
+	assert.Nil(t, packs)
+}
@@ -0,0 +1,58 @@
+package allocator
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error classes group the errors the allocator returns by what the caller
+// can do about them, so that callers such as the API map classes rather
+// than individual errors: errors.Is(err, class) is true for every error of
+// the class.
+var (
+	// ErrInvalidRequest classifies requests the caller must correct, such as
+	// a quantity that is not positive or an unknown profile.
+	ErrInvalidRequest = errors.New("invalid request")
+	// ErrInfeasible classifies valid requests no allocation can satisfy,
+	// such as a quantity no combination of the pack sizes reaches within
+	// the constraints.
+	ErrInfeasible = errors.New("request cannot be satisfied")
+	// ErrTooLarge classifies requests exceeding a configured limit or what
+	// can be calculated exactly.
+	ErrTooLarge = errors.New("request too large")
+)
+
+// classError is an error of a class.
+type classError struct {
+	msg   string
+	class error
+}
+
+func (e *classError) Error() string {
+	return e.msg
+}
+
+// Is makes errors.Is(e, class) true for the class of e.
+func (e *classError) Is(target error) bool {
+	return target == e.class
+}
+
+// invalid returns a sentinel error of class ErrInvalidRequest.
+func invalid(msg string) error {
+	return &classError{msg: msg, class: ErrInvalidRequest}
+}
+
+// invalidf formats an error of class ErrInvalidRequest.
+func invalidf(format string, args ...interface{}) error {
+	return &classError{msg: fmt.Sprintf(format, args...), class: ErrInvalidRequest}
+}
+
+// infeasible returns a sentinel error of class ErrInfeasible.
+func infeasible(msg string) error {
+	return &classError{msg: msg, class: ErrInfeasible}
+}
+
+// tooLarge returns a sentinel error of class ErrTooLarge.
+func tooLarge(msg string) error {
+	return &classError{msg: msg, class: ErrTooLarge}
+}
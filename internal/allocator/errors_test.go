@@ -0,0 +1,44 @@
+package allocator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorClasses(t *testing.T) {
+	tests := []struct {
+		err   error
+		class error
+	}{
+		{ErrInvalidQuantity, ErrInvalidRequest},
+		{fmt.Errorf("item 0: %w", ErrUnknownProfile), ErrInvalidRequest},
+		{ErrNoPackSizes, ErrInvalidRequest},
+		{fmt.Errorf("%w: no packs", ErrInvalidPin), ErrInvalidRequest},
+		{&InfeasibleError{Quantity: 1}, ErrInfeasible},
+		{ErrCartons, ErrInfeasible},
+		{&LimitError{Field: "quantity", Value: 2, Limit: 1}, ErrTooLarge},
+		{&OverflowError{Quantity: 1}, ErrTooLarge},
+	}
+	classes := []error{ErrInvalidRequest, ErrInfeasible, ErrTooLarge}
+	for _, tt := range tests {
+		for _, class := range classes {
+			assert.Equal(t, class == tt.class, errors.Is(tt.err, class), "%v is %v", tt.err, class)
+		}
+	}
+	// Typed errors still match their own sentinels.
+	assert.ErrorIs(t, &InfeasibleError{Quantity: 1}, ErrNoCombination)
+	assert.ErrorIs(t, &LimitError{}, ErrLimitExceeded)
+	assert.ErrorIs(t, &OverflowError{}, ErrOverflow)
+	assert.NotErrorIs(t, ErrReadOnly, ErrInvalidRequest)
+}
+
+func TestOrderMissingSKU(t *testing.T) {
+	a := NewAllocator([]int{23, 31, 53}, nil)
+	_, err := a.AllocateOrder(context.Background(), OrderRequest{Lines: []OrderLine{{Quantity: 5}}})
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+	assert.EqualError(t, err, "item 0: sku is required")
+}
@@ -0,0 +1,135 @@
+package allocator
+
+import (
+	"log"
+	"math"
+	"time"
+)
+
+// CostModel predicts how long the built-in strategies take from the quantity
+// and pack sizes, so each calculation gets a strategy and deadline fitting
+// its own cost rather than the same soft timeout as every other.
+type CostModel struct {
+	// Rate is how many operations, as counted in Stats.Iterations, a
+	// strategy gets through per second.
+	Rate float64
+	// Slack is how many times its estimate a calculation may run before it
+	// falls back to greedy.
+	Slack float64
+	// MinDeadline is the shortest deadline given, so that cheap
+	// calculations are not abandoned over scheduling noise.
+	MinDeadline time.Duration
+}
+
+// DefaultCostModel is calibrated conservatively against the built-in
+// strategies on commodity hardware.
+var DefaultCostModel = CostModel{Rate: 50_000_000, Slack: 4, MinDeadline: 50 * time.Millisecond}
+
+// EstimateCost returns the operations strategy is expected to take for
+// quantity with sizes, sorted in descending order: table cells for dp,
+// candidate combinations for combination and an upper bound on search nodes
+// for backtracking. It returns false for strategies it cannot estimate, such
+// as ones registered by other packages.
+func EstimateCost(strategy string, quantity int, sizes []int) (float64, bool) {
+	if len(sizes) == 0 || quantity <= 0 {
+		return 0, false
+	}
+	n := float64(len(sizes))
+	switch strategy {
+	case "greedy":
+		return n * n, true
+	case "dp":
+		return (float64(quantity) + float64(sizes[0])) * n, true
+	case "combination":
+		cost := 0.0
+		for i, size := range sizes {
+			cost += (math.Ceil(float64(quantity)/float64(size)) + 1) * float64(len(sizes)-i)
+		}
+		return cost, true
+	case "backtracking":
+		cost := 1.0
+		for _, size := range sizes {
+			cost *= math.Ceil(float64(quantity)/float64(size)) + 1
+		}
+		return cost, true
+	}
+	return 0, false
+}
+
+// plan is the strategy and deadline a calculation runs with.
+type plan struct {
+	strategy string
+	// estimate is the expected duration, zero when not estimated.
+	estimate time.Duration
+	// deadline is the soft deadline, zero for none.
+	deadline time.Duration
+	// degraded is set when a cheaper strategy than requested was chosen.
+	degraded bool
+}
+
+// SetCostModel makes calculations without a requested strategy run with the
+// first of the default strategy, dp and greedy expected to finish within the
+// soft timeout, or the hard timeout without one, and gives each a soft
+// deadline of Slack times its estimate, at least MinDeadline and at most the
+// soft timeout. Results of a greedy strategy chosen this way are approximate.
+// A zero Rate disables the model. It must be called before the allocator
+// serves requests.
+func (a *Allocator) SetCostModel(m CostModel) {
+	a.costModel = m
+}
+
+// plan chooses the strategy and soft deadline of a calculation of quantity
+// with sizes. requested is the strategy the request named, empty for the
+// default of the profile's defaults or else the allocator's.
+func (a *Allocator) plan(requested string, quantity int, sizes []int, defaults ProfileDefaults) plan {
+	name := requested
+	if name == "" {
+		name = defaults.Strategy
+	}
+	if name == "" {
+		name = a.strategy
+	}
+	softTimeout := defaults.softTimeout(a.softTimeout)
+	m := a.costModel
+	if m.Rate <= 0 {
+		return plan{strategy: name, deadline: softTimeout}
+	}
+
+	budget := softTimeout
+	if budget <= 0 {
+		budget = a.hardTimeout
+	}
+	candidates := []string{name}
+	if requested == "" && budget > 0 {
+		candidates = append(candidates, ExactStrategy, FallbackStrategy)
+	}
+	p := plan{strategy: name}
+	for _, candidate := range candidates {
+		cost, ok := EstimateCost(candidate, quantity, sizes)
+		if !ok {
+			// Strategies that cannot be estimated run as without the model.
+			return plan{strategy: candidate, deadline: softTimeout}
+		}
+		p = plan{strategy: candidate, estimate: seconds(cost / m.Rate), degraded: candidate != name}
+		if budget <= 0 || p.estimate <= budget {
+			break
+		}
+	}
+	if p.degraded {
+		log.Printf("Calculation for quantity %d is estimated too costly for %s, using %s", quantity, name, p.strategy)
+	}
+
+	p.deadline = max(seconds(p.estimate.Seconds()*m.Slack), m.MinDeadline)
+	if softTimeout > 0 {
+		p.deadline = min(p.deadline, softTimeout)
+	}
+	return p
+}
+
+// seconds converts s seconds to a duration, saturating at the largest one.
+func seconds(s float64) time.Duration {
+	if s >= float64(math.MaxInt64)/float64(time.Second) {
+		return math.MaxInt64
+	}
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,71 @@
+package allocator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateCost(t *testing.T) {
+	sizes := []int{53, 31, 23}
+	for _, name := range []string{"dp", "combination"} {
+		strategy, err := LookupStrategy(name)
+		assert.NoError(t, err)
+		result, err := strategy.Allocate(context.Background(), 5000, sizes)
+		assert.NoError(t, err)
+		cost, ok := EstimateCost(name, 5000, sizes)
+		assert.True(t, ok, name)
+		assert.GreaterOrEqual(t, cost, float64(result.Stats.Iterations), name)
+		assert.LessOrEqual(t, cost, 1.1*float64(result.Stats.Iterations), name)
+	}
+
+	cost, ok := EstimateCost("backtracking", 100, sizes)
+	assert.True(t, ok)
+	assert.Equal(t, float64(3*5*6), cost)
+
+	_, ok = EstimateCost("blocking", 100, sizes)
+	assert.False(t, ok)
+	_, ok = EstimateCost("dp", 0, sizes)
+	assert.False(t, ok)
+}
+
+func TestCostModel(t *testing.T) {
+	a := NewAllocator([]int{23, 31, 53}, nil)
+	assert.NoError(t, a.SetStrategy("backtracking"))
+	a.SetTimeouts(10*time.Millisecond, time.Second)
+	a.SetCostModel(CostModel{Rate: 1_000_000, Slack: 4, MinDeadline: time.Millisecond})
+
+	tests := []struct {
+		name        string
+		req         Request
+		strategy    string
+		approximate bool
+		estimate    time.Duration
+		deadline    time.Duration
+	}{
+		{"cheap", Request{Quantity: 10}, "backtracking", false, 8 * time.Microsecond, time.Millisecond},
+		{"too costly to search", Request{Quantity: 2000}, "dp", false, 6159 * time.Microsecond, 10 * time.Millisecond},
+		{"too costly for dp", Request{Quantity: 100000}, "greedy", true, 9 * time.Microsecond, time.Millisecond},
+		{"requested", Request{Quantity: 300, Strategy: "backtracking"}, "backtracking", false, 7 * 11 * 15 * time.Microsecond, 4 * 7 * 11 * 15 * time.Microsecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := a.Allocate(context.Background(), tt.req)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.strategy, result.Stats.Strategy)
+			assert.Equal(t, tt.approximate, result.Approximate)
+			assert.Equal(t, tt.estimate, result.Stats.Estimate)
+			assert.Equal(t, tt.deadline, result.Stats.Deadline)
+			assert.GreaterOrEqual(t, result.Total, tt.req.Quantity)
+		})
+	}
+
+	// Strategies that cannot be estimated run under the soft timeout.
+	RegisterStrategy("unestimated", StrategyFunc(greedyStrategy))
+	result, err := a.Allocate(context.Background(), Request{Quantity: 2000, Strategy: "unestimated"})
+	assert.NoError(t, err)
+	assert.Zero(t, result.Stats.Estimate)
+	assert.Equal(t, 10*time.Millisecond, result.Stats.Deadline)
+}
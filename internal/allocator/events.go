@@ -0,0 +1,61 @@
+package allocator
+
+import (
+	"context"
+	"log"
+
+	"github.com/n-th/gymshark/internal/events"
+)
+
+// SetEventPublisher publishes an event for every allocation computed by
+// Allocate and every profile changed with UpdateProfile. Publishing is
+// best effort: failures are logged and never fail the request, so p should
+// not block, e.g. an events.AsyncPublisher. It must be called before the
+// allocator is used concurrently.
+func (a *Allocator) SetEventPublisher(p events.Publisher) {
+	a.events = p
+}
+
+// emit publishes e if a publisher is configured.
+func (a *Allocator) emit(ctx context.Context, e events.Event) {
+	if a.events == nil {
+		return
+	}
+	if err := a.events.Publish(ctx, e); err != nil {
+		log.Printf("Failed to publish %s event: %v", e.Type, err)
+	}
+}
+
+// emitAllocation publishes an allocation computed with version of the
+// request's profile.
+func (a *Allocator) emitAllocation(ctx context.Context, req Request, result Result, version int) {
+	if a.events == nil {
+		return
+	}
+	profile := req.Profile
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	e := events.New(events.TypeAllocationCompleted)
+	e.Allocation = &events.Allocation{
+		Quantity:       req.Quantity,
+		Packs:          result.Packs,
+		Total:          result.Total,
+		Approximate:    result.Approximate,
+		Profile:        profile,
+		ProfileVersion: version,
+		OrderID:        req.OrderID,
+		CustomerID:     req.CustomerID,
+		Metadata:       req.Metadata,
+		Source:         result.Source,
+	}
+	a.emit(ctx, e)
+}
+
+// emitProfile publishes new pack sizes for a profile that had previous.
+func (a *Allocator) emitProfile(ctx context.Context, name string, version int, previous, sizes []int) {
+	e := events.New(events.TypeProfileChanged)
+	added, removed := sizeChanges(previous, sizes)
+	e.Profile = &events.Profile{Name: name, Version: version, PackSizes: sortedSizes(sizes), Added: added, Removed: removed}
+	a.emit(ctx, e)
+}
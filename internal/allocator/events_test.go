@@ -0,0 +1,78 @@
+package allocator
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/n-th/gymshark/internal/events"
+	"github.com/stretchr/testify/assert"
+)
+
+// eventRecorder is an events.Publisher that records what it is given.
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []events.Event
+}
+
+func (r *eventRecorder) Publish(ctx context.Context, e events.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	return nil
+}
+
+func (r *eventRecorder) Close() error {
+	return nil
+}
+
+func TestAllocateEmitsEvent(t *testing.T) {
+	a := NewAllocator([]int{250, 500, 1000}, newMockStorage())
+	assert.NoError(t, a.RecordProfileVersions())
+	published := &eventRecorder{}
+	a.SetEventPublisher(published)
+	ctx := context.Background()
+
+	_, err := a.Allocate(ctx, Request{Quantity: 501, OrderID: "ORD-1", CustomerID: "CUST-1", Metadata: map[string]interface{}{"channel": "web"}})
+	assert.NoError(t, err)
+
+	// Previews and failed allocations are not announced
+	_, err = a.Preview(ctx, Request{Quantity: 501})
+	assert.NoError(t, err)
+	_, err = a.Allocate(ctx, Request{Quantity: 0})
+	assert.Error(t, err)
+
+	if assert.Len(t, published.events, 1) {
+		e := published.events[0]
+		assert.Equal(t, events.TypeAllocationCompleted, e.Type)
+		assert.NotEmpty(t, e.ID)
+		assert.Equal(t, &events.Allocation{
+			Quantity:       501,
+			Packs:          map[int]int{500: 1, 250: 1},
+			Total:          750,
+			Profile:        DefaultProfile,
+			ProfileVersion: 1,
+			OrderID:        "ORD-1",
+			CustomerID:     "CUST-1",
+			Metadata:       map[string]interface{}{"channel": "web"},
+		}, e.Allocation)
+	}
+}
+
+func TestUpdateProfileEmitsEvent(t *testing.T) {
+	a := NewAllocator([]int{250, 500, 1000}, newMockStorage())
+	assert.NoError(t, a.RecordProfileVersions())
+	published := &eventRecorder{}
+	a.SetEventPublisher(published)
+
+	_, err := a.UpdateProfile(context.Background(), "apparel", []int{10, 30, 20})
+	assert.NoError(t, err)
+	_, err = a.UpdateProfile(context.Background(), "apparel", nil)
+	assert.Error(t, err)
+
+	if assert.Len(t, published.events, 1) {
+		e := published.events[0]
+		assert.Equal(t, events.TypeProfileChanged, e.Type)
+		assert.Equal(t, &events.Profile{Name: "apparel", Version: 1, PackSizes: []int{30, 20, 10}, Added: []int{30, 20, 10}}, e.Profile)
+	}
+}
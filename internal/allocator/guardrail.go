@@ -0,0 +1,127 @@
+package allocator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+// DefaultGuardrailSample is how many allocations ProfileImpact replays when
+// no sample size is configured.
+const DefaultGuardrailSample = 500
+
+// ProfileGuardrail limits how much a profile update may raise the waste of
+// the profile's recent allocations before it must be forced.
+type ProfileGuardrail struct {
+	// MaxWasteIncrease is the largest rise in the waste rate, the waste as
+	// a percentage of the quantities ordered, in percentage points. Zero
+	// disables the guardrail.
+	MaxWasteIncrease float64
+	// Sample is how many of the profile's latest allocations are replayed,
+	// at most storage.MaxSearchLimit; zero uses DefaultGuardrailSample.
+	Sample int
+}
+
+// Validate returns an error for negative settings or a sample larger than
+// storage.MaxSearchLimit.
+func (g ProfileGuardrail) Validate() error {
+	if g.MaxWasteIncrease < 0 {
+		return errors.New("profile guardrail max waste increase must not be negative")
+	}
+	if g.Sample < 0 || g.Sample > storage.MaxSearchLimit {
+		return fmt.Errorf("profile guardrail sample %d must be between 0 and %d", g.Sample, storage.MaxSearchLimit)
+	}
+	return nil
+}
+
+// SetProfileGuardrail configures the guardrail ProfileImpact checks profile
+// updates against. It must be called before the allocator is used
+// concurrently.
+func (a *Allocator) SetProfileGuardrail(g ProfileGuardrail) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	if g.Sample == 0 {
+		g.Sample = DefaultGuardrailSample
+	}
+	a.guardrail = g
+	return nil
+}
+
+// ProfileImpact is the effect on waste of replacing the pack sizes of a
+// profile, over the quantities of its latest allocations.
+type ProfileImpact struct {
+	Profile      string
+	CurrentSizes []int
+	Sizes        []int
+	// Allocations counts the allocations replayed.
+	Allocations int
+	// Current and Updated are the waste of the allocations with the current
+	// and the new sizes.
+	Current SimulationTotals
+	Updated SimulationTotals
+	// CurrentWasteRate and WasteRate are the waste as a percentage of the
+	// quantities, and WasteIncrease the difference in percentage points,
+	// negative when the new sizes waste less.
+	CurrentWasteRate float64
+	WasteRate        float64
+	WasteIncrease    float64
+	MaxWasteIncrease float64
+	// Exceeded is set when WasteIncrease is above MaxWasteIncrease, so that
+	// the update must be forced.
+	Exceeded bool
+}
+
+// ProfileImpact replays the quantities of the latest allocations of a
+// profile, up to the guardrail's sample, with its current pack sizes and
+// with sizes, and checks the rise in waste against the guardrail. Sizes are
+// validated as for UpdateProfile. It returns nil without analysing anything
+// when the guardrail is disabled, no storage is configured, the profile
+// does not exist yet or has no allocations.
+func (a *Allocator) ProfileImpact(ctx context.Context, name string, sizes []int) (*ProfileImpact, error) {
+	if err := a.checkProfile(name, sizes); err != nil {
+		return nil, err
+	}
+	g := a.guardrail
+	if g.MaxWasteIncrease == 0 || a.storage == nil {
+		return nil, nil
+	}
+	current, err := a.config().sizes(name)
+	if err != nil {
+		return nil, nil
+	}
+	allocations, _, err := a.storage.SearchAllocations(storage.AllocationFilter{Profile: name, Limit: g.Sample})
+	if err != nil {
+		return nil, err
+	}
+	if len(allocations) == 0 {
+		return nil, nil
+	}
+
+	quantities := make([]int, len(allocations))
+	ordered := 0
+	for i, al := range allocations {
+		quantities[i] = al.OrderQuantity
+		ordered += al.OrderQuantity
+	}
+	sim, err := a.simulate(ctx, quantities, current, sizes, "")
+	if err != nil {
+		return nil, err
+	}
+	impact := &ProfileImpact{
+		Profile:          name,
+		CurrentSizes:     sim.BaselineSizes,
+		Sizes:            sim.CandidateSizes,
+		Allocations:      len(allocations),
+		Current:          sim.Baseline,
+		Updated:          sim.Candidate,
+		CurrentWasteRate: 100 * float64(sim.Baseline.Waste) / float64(ordered),
+		WasteRate:        100 * float64(sim.Candidate.Waste) / float64(ordered),
+		MaxWasteIncrease: g.MaxWasteIncrease,
+	}
+	impact.WasteIncrease = impact.WasteRate - impact.CurrentWasteRate
+	impact.Exceeded = impact.WasteIncrease > g.MaxWasteIncrease
+	return impact, nil
+}
@@ -0,0 +1,63 @@
+package allocator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/n-th/gymshark/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileImpact(t *testing.T) {
+	ctx := context.Background()
+	s := newMockStorage()
+	for _, q := range []int{23, 31, 46, 53} {
+		assert.NoError(t, s.StoreAllocationInput(storage.AllocationInput{Quantity: q, Profile: DefaultProfile}))
+	}
+	a := NewAllocator([]int{23, 31, 53}, s)
+
+	// Disabled by default.
+	impact, err := a.ProfileImpact(ctx, DefaultProfile, []int{50})
+	assert.NoError(t, err)
+	assert.Nil(t, impact)
+
+	assert.NoError(t, a.SetProfileGuardrail(ProfileGuardrail{MaxWasteIncrease: 5}))
+
+	// 23, 31, 46 and 53 need no waste now; with 50 they waste 27, 19, 4 and
+	// 47 of 153 items.
+	impact, err = a.ProfileImpact(ctx, DefaultProfile, []int{50})
+	assert.NoError(t, err)
+	if assert.NotNil(t, impact) {
+		assert.Equal(t, 4, impact.Allocations)
+		assert.Equal(t, []int{53, 31, 23}, impact.CurrentSizes)
+		assert.Equal(t, 0, impact.Current.Waste)
+		assert.Equal(t, 97, impact.Updated.Waste)
+		assert.Zero(t, impact.CurrentWasteRate)
+		assert.InDelta(t, 63.40, impact.WasteRate, 0.01)
+		assert.InDelta(t, 63.40, impact.WasteIncrease, 0.01)
+		assert.True(t, impact.Exceeded)
+	}
+
+	impact, err = a.ProfileImpact(ctx, DefaultProfile, []int{23, 31, 46, 53})
+	assert.NoError(t, err)
+	if assert.NotNil(t, impact) {
+		assert.Zero(t, impact.WasteIncrease)
+		assert.False(t, impact.Exceeded)
+	}
+
+	// New profiles have nothing to compare with.
+	impact, err = a.ProfileImpact(ctx, "boxes", []int{50})
+	assert.NoError(t, err)
+	assert.Nil(t, impact)
+
+	_, err = a.ProfileImpact(ctx, DefaultProfile, []int{})
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+}
+
+func TestProfileGuardrailValidate(t *testing.T) {
+	assert.NoError(t, ProfileGuardrail{}.Validate())
+	assert.NoError(t, ProfileGuardrail{MaxWasteIncrease: 2.5, Sample: storage.MaxSearchLimit}.Validate())
+	assert.Error(t, ProfileGuardrail{MaxWasteIncrease: -1}.Validate())
+	assert.Error(t, ProfileGuardrail{Sample: -1}.Validate())
+	assert.Error(t, ProfileGuardrail{Sample: storage.MaxSearchLimit + 1}.Validate())
+}
@@ -0,0 +1,100 @@
+package allocator
+
+import (
+	"maps"
+	"time"
+
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+// AllocationChange is a run of stored allocations of a quantity that packed
+// it the same way with the same profile version.
+type AllocationChange struct {
+	Packs          map[int]int
+	Total          int
+	ProfileVersion int
+	// Algorithm is what produced the first allocation of the run.
+	Algorithm string
+	// First and Last are when the first and last allocation of the run were
+	// stored, and Allocations how many there were.
+	First       time.Time
+	Last        time.Time
+	Allocations int
+	// Delta is the change in packs of each size from the previous run:
+	// positive for packs added, negative for packs removed. It is nil for
+	// the first run and empty when only the profile version changed.
+	Delta map[int]int
+}
+
+// AllocationHistory returns how the stored allocations of a quantity of a
+// profile changed over time, oldest first, built from the latest
+// storage.MaxSearchLimit allocations stored for it. Soft-deleted
+// allocations are left out.
+func (a *Allocator) AllocationHistory(profile string, quantity int) ([]AllocationChange, error) {
+	if a.storage == nil {
+		return nil, ErrStorageNotConfigured
+	}
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	allocations, _, err := a.storage.SearchAllocations(storage.AllocationFilter{
+		MinQuantity: quantity,
+		MaxQuantity: quantity,
+		Profile:     profile,
+		Limit:       storage.MaxSearchLimit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []AllocationChange
+	// Searches return the most recent allocations first.
+	for i := len(allocations) - 1; i >= 0; i-- {
+		al := allocations[i]
+		last := al.CreatedAt
+		if al.LastAccessedAt != nil {
+			last = *al.LastAccessedAt
+		}
+		hits := max(al.Hits, 1)
+
+		if n := len(changes); n > 0 {
+			prev := &changes[n-1]
+			if prev.ProfileVersion == al.ProfileVersion && maps.Equal(prev.Packs, al.Packs) {
+				prev.Last = last
+				prev.Allocations += hits
+				continue
+			}
+		}
+		change := AllocationChange{
+			Packs:          al.Packs,
+			Total:          al.Total,
+			ProfileVersion: al.ProfileVersion,
+			Algorithm:      al.Algorithm,
+			First:          al.CreatedAt,
+			Last:           last,
+			Allocations:    hits,
+		}
+		if n := len(changes); n > 0 {
+			change.Delta = packDelta(changes[n-1].Packs, al.Packs)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// packDelta returns the change in the count of each pack size from before to
+// after, leaving out sizes whose count did not change.
+func packDelta(before, after map[int]int) map[int]int {
+	delta := map[int]int{}
+	for size, count := range after {
+		if d := count - before[size]; d != 0 {
+			delta[size] = d
+		}
+	}
+	for size, count := range before {
+		if _, ok := after[size]; !ok && count != 0 {
+			delta[size] = -count
+		}
+	}
+	return delta
+}
@@ -0,0 +1,18 @@
+package allocator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackDelta(t *testing.T) {
+	assert.Equal(t, map[int]int{23: -1, 50: 2, 53: 1},
+		packDelta(map[int]int{23: 3, 31: 1, 53: 1}, map[int]int{23: 2, 31: 1, 50: 2, 53: 2}))
+	assert.Empty(t, packDelta(map[int]int{250: 1}, map[int]int{250: 1}))
+}
+
+func TestAllocationHistoryWithoutStorage(t *testing.T) {
+	_, err := NewAllocator([]int{23}, nil).AllocationHistory("", 23)
+	assert.ErrorIs(t, err, ErrStorageNotConfigured)
+}
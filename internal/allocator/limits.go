@@ -0,0 +1,60 @@
+package allocator
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrLimitExceeded = tooLarge("request exceeds configured limit")
+
+// Limits bounds the size of the work a single request may ask for.
+// A zero field means unlimited.
+type Limits struct {
+	// MaxQuantity is the largest order quantity accepted for calculation.
+	MaxQuantity int
+	// MaxBatchSize is the largest number of quantities or order lines
+	// accepted by a single batch-style request.
+	MaxBatchSize int
+}
+
+// LimitError describes which limit a request exceeded.
+// It matches ErrLimitExceeded with errors.Is.
+type LimitError struct {
+	Field string
+	Value int
+	Limit int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s %d exceeds the maximum of %d", e.Field, e.Value, e.Limit)
+}
+
+// Is makes errors.Is(err, ErrLimitExceeded) and errors.Is(err, ErrTooLarge)
+// true for limit violations.
+func (e *LimitError) Is(target error) bool {
+	return errors.Is(ErrLimitExceeded, target)
+}
+
+// SetLimits configures request limits.
+func (a *Allocator) SetLimits(l Limits) {
+	a.limits = l
+}
+
+// Limits returns the configured request limits.
+func (a *Allocator) Limits() Limits {
+	return a.limits
+}
+
+func (a *Allocator) checkQuantity(quantity int) error {
+	if a.limits.MaxQuantity > 0 && quantity > a.limits.MaxQuantity {
+		return &LimitError{Field: "quantity", Value: quantity, Limit: a.limits.MaxQuantity}
+	}
+	return nil
+}
+
+func (a *Allocator) checkBatchSize(size int) error {
+	if a.limits.MaxBatchSize > 0 && size > a.limits.MaxBatchSize {
+		return &LimitError{Field: "batch size", Value: size, Limit: a.limits.MaxBatchSize}
+	}
+	return nil
+}
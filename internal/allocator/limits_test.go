@@ -0,0 +1,38 @@
+package allocator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimits(t *testing.T) {
+	allocator := NewAllocator([]int{23, 31, 53}, newMockStorage())
+	allocator.SetLimits(Limits{MaxQuantity: 1000, MaxBatchSize: 2})
+
+	_, err := allocator.Allocate(context.Background(), Request{Quantity: 1000})
+	assert.NoError(t, err)
+
+	_, err = allocator.Allocate(context.Background(), Request{Quantity: 1001})
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+	var limitErr *LimitError
+	assert.True(t, errors.As(err, &limitErr))
+	assert.Equal(t, "quantity 1001 exceeds the maximum of 1000", err.Error())
+
+	lines := []OrderLine{{SKU: "A", Quantity: 1}, {SKU: "B", Quantity: 1}, {SKU: "C", Quantity: 1}}
+	_, err = allocator.AllocateOrder(context.Background(), OrderRequest{Lines: lines})
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+
+	// Repeated quantities count once towards the batch size
+	_, err = allocator.Simulate(context.Background(), []int{10, 10, 20}, nil, []int{10}, "")
+	assert.NoError(t, err)
+	_, err = allocator.Simulate(context.Background(), []int{10, 20, 30}, nil, []int{10}, "")
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+
+	// Zero limits are unlimited
+	allocator.SetLimits(Limits{})
+	_, err = allocator.Allocate(context.Background(), Request{Quantity: 5000})
+	assert.NoError(t, err)
+}
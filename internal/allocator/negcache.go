@@ -0,0 +1,139 @@
+package allocator
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// InfeasibleError reports that no pack combination satisfies a request.
+// It matches ErrNoCombination and ErrInfeasible with errors.Is.
+type InfeasibleError struct {
+	Quantity int
+	Profile  string
+	Strategy string
+	// Constrained is set when the quantity could not be met within the
+	// request's constraints or the profile's pack limits.
+	Constrained bool
+	// Cached is set when the outcome was served from the negative cache
+	// rather than recomputed.
+	Cached bool
+}
+
+func (e *InfeasibleError) Error() string {
+	if e.Constrained {
+		return fmt.Sprintf("no valid pack combination found for quantity %d within the pack constraints and limits", e.Quantity)
+	}
+	return fmt.Sprintf("no valid pack combination found for quantity %d", e.Quantity)
+}
+
+// Is makes errors.Is(err, ErrNoCombination) and errors.Is(err,
+// ErrInfeasible) true for infeasible results.
+func (e *InfeasibleError) Is(target error) bool {
+	return errors.Is(ErrNoCombination, target)
+}
+
+// outcomeCache remembers failed outcomes for a limited time so that requests
+// known to be unfulfillable, or to carry invalid input, are not recomputed.
+type outcomeCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]outcomeEntry
+	now     func() time.Time
+}
+
+type outcomeEntry struct {
+	err     error
+	expires time.Time
+}
+
+func newOutcomeCache(ttl time.Duration) *outcomeCache {
+	return &outcomeCache{ttl: ttl, entries: make(map[string]outcomeEntry), now: time.Now}
+}
+
+// get returns the cached error for key, if any and not expired.
+func (c *outcomeCache) get(key string) (error, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if c.now().After(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.err, true
+}
+
+// put caches err under key until the TTL elapses.
+func (c *outcomeCache) put(key string, err error) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	// Opportunistically drop expired entries so the map cannot grow forever.
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = outcomeEntry{err: err, expires: now.Add(c.ttl)}
+}
+
+// purge drops every cached entry.
+func (c *outcomeCache) purge() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]outcomeEntry)
+}
+
+// len returns the number of cached entries, including expired ones not yet purged.
+func (c *outcomeCache) len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// quantities returns the number of distinct quantities with an unexpired
+// infeasible outcome.
+func (c *outcomeCache) quantities() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	seen := make(map[int]bool)
+	for _, e := range c.entries {
+		if infeasible, ok := e.err.(*InfeasibleError); ok && !now.After(e.expires) {
+			seen[infeasible.Quantity] = true
+		}
+	}
+	return len(seen)
+}
+
+// infeasibleKey identifies a calculation for the negative cache.
+func infeasibleKey(strategy string, sizes []int, quantity int) string {
+	return fmt.Sprintf("calc|%s|%v|%d", strategy, sizes, quantity)
+}
+
+// sizesKey identifies an ad-hoc pack-size set for validation caching.
+func sizesKey(sizes []int) string {
+	return fmt.Sprintf("sizes|%v", sizes)
+}
@@ -0,0 +1,74 @@
+package allocator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegativeCache(t *testing.T) {
+	calls := 0
+	RegisterStrategy("never", StrategyFunc(func(context.Context, int, []int) (Result, error) {
+		calls++
+		return Result{}, ErrNoCombination
+	}))
+
+	allocator := NewAllocator([]int{2, 4}, newMockStorage())
+	allocator.SetNegativeCacheTTL(time.Minute)
+
+	_, err := allocator.Allocate(context.Background(), Request{Quantity: 7, Strategy: "never"})
+	var infeasible *InfeasibleError
+	assert.True(t, errors.As(err, &infeasible))
+	assert.ErrorIs(t, err, ErrNoCombination)
+	assert.False(t, infeasible.Cached)
+	assert.Equal(t, 7, infeasible.Quantity)
+	assert.Equal(t, 1, calls)
+
+	// The second request is served from the cache
+	_, err = allocator.Allocate(context.Background(), Request{Quantity: 7, Strategy: "never"})
+	assert.True(t, errors.As(err, &infeasible))
+	assert.True(t, infeasible.Cached)
+	assert.Equal(t, 1, calls)
+
+	// Other quantities are computed independently
+	_, err = allocator.Allocate(context.Background(), Request{Quantity: 9, Strategy: "never"})
+	assert.ErrorIs(t, err, ErrNoCombination)
+	assert.Equal(t, 2, calls)
+}
+
+func TestNegativeCacheExpiry(t *testing.T) {
+	now := time.Now()
+	cache := newOutcomeCache(time.Minute)
+	cache.now = func() time.Time { return now }
+
+	cache.put("key", ErrNoCombination)
+	err, ok := cache.get("key")
+	assert.True(t, ok)
+	assert.Equal(t, ErrNoCombination, err)
+
+	now = now.Add(2 * time.Minute)
+	_, ok = cache.get("key")
+	assert.False(t, ok)
+	assert.Equal(t, 0, cache.len())
+
+	// A nil cache is disabled
+	var disabled *outcomeCache
+	disabled.put("key", ErrNoCombination)
+	_, ok = disabled.get("key")
+	assert.False(t, ok)
+}
+
+func TestValidationCache(t *testing.T) {
+	allocator := NewAllocator([]int{23, 31, 53}, newMockStorage())
+	allocator.SetNegativeCacheTTL(time.Minute)
+
+	_, err := allocator.Evaluate(context.Background(), 10, []int{10, -1}, "")
+	assert.ErrorIs(t, err, ErrInvalidPackSize)
+	assert.Equal(t, 1, allocator.negative.len())
+
+	_, err = allocator.Evaluate(context.Background(), 10, []int{10, -1}, "")
+	assert.ErrorIs(t, err, ErrInvalidPackSize)
+}
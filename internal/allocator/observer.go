@@ -0,0 +1,66 @@
+package allocator
+
+import "time"
+
+// Observer is notified of every allocation computed by Allocate, whether or
+// not it is stored. Implementations must be safe for concurrent use.
+type Observer interface {
+	ObserveAllocation(profile string, outcome SimulationOutcome)
+}
+
+// SetObserver registers o to be notified of allocations. It must be called
+// before the allocator is used concurrently.
+func (a *Allocator) SetObserver(o Observer) {
+	a.observer = o
+}
+
+// CachedQuantities returns the number of distinct quantities held in the
+// negative cache, across strategies and profiles.
+func (a *Allocator) CachedQuantities() int {
+	return a.negative.quantities()
+}
+
+// CacheStats describes the allocator's caches.
+type CacheStats struct {
+	// ResultCache reports whether stored allocations are reused; see
+	// SetResultCache.
+	ResultCache bool
+	// NegativeTTL is how long failed outcomes are cached, zero when the
+	// negative cache is disabled.
+	NegativeTTL time.Duration
+	// NegativeEntries counts the cached failed outcomes, including expired
+	// ones not yet evicted.
+	NegativeEntries int
+	// InfeasibleQuantities is CachedQuantities.
+	InfeasibleQuantities int
+	// Pins counts the pinned allocations.
+	Pins int
+}
+
+// CacheStats returns the current state of the allocator's caches.
+func (a *Allocator) CacheStats() CacheStats {
+	stats := CacheStats{
+		ResultCache:          a.resultCache,
+		NegativeEntries:      a.negative.len(),
+		InfeasibleQuantities: a.negative.quantities(),
+	}
+	a.pinsMu.RLock()
+	stats.Pins = len(a.pins)
+	a.pinsMu.RUnlock()
+	if a.negative != nil {
+		stats.NegativeTTL = a.negative.ttl
+	}
+	return stats
+}
+
+// observe reports a successful allocation to the observer, if any.
+func (a *Allocator) observe(req Request, result Result) {
+	if a.observer == nil {
+		return
+	}
+	profile := req.Profile
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	a.observer.ObserveAllocation(profile, outcome(req.Quantity, result))
+}
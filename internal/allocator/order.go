@@ -0,0 +1,130 @@
+package allocator
+
+import (
+	"context"
+	"fmt"
+)
+
+var ErrEmptyOrder = invalid("order must contain at least one item")
+
+// OrderLine is a single SKU and quantity within an order.
+type OrderLine struct {
+	SKU      string
+	Quantity int
+	// Profile overrides the profile configured for the SKU.
+	Profile string
+}
+
+// OrderRequest describes a calculation for every line of an order.
+type OrderRequest struct {
+	OrderID    string
+	CustomerID string
+	Strategy   string
+	Metadata   map[string]interface{}
+	Lines      []OrderLine
+}
+
+// LineResult is the allocation for one order line.
+type LineResult struct {
+	SKU      string
+	Quantity int
+	Profile  string
+	Result
+}
+
+// Waste returns the number of items shipped beyond the requested quantity.
+func (r LineResult) Waste() int {
+	return r.Total - r.Quantity
+}
+
+// PackCount returns the number of packs in the allocation.
+func (r LineResult) PackCount() int {
+	count := 0
+	for _, n := range r.Packs {
+		count += n
+	}
+	return count
+}
+
+// OrderResult holds the per-line allocations and order-level totals.
+type OrderResult struct {
+	Lines         []LineResult
+	TotalQuantity int
+	TotalItems    int
+	TotalPacks    int
+	TotalWaste    int
+	Approximate   bool
+}
+
+// AllocateOrder allocates every line of an order independently, using the
+// pack-size profile of each line's SKU, and summarises the whole order.
+// Each line is persisted as its own allocation tagged with the order ID and
+// SKU, all of them in a single transaction once every line is computed, so
+// an order that fails part way stores nothing.
+func (a *Allocator) AllocateOrder(ctx context.Context, req OrderRequest) (OrderResult, error) {
+	if len(req.Lines) == 0 {
+		return OrderResult{}, ErrEmptyOrder
+	}
+	if err := a.checkBatchSize(len(req.Lines)); err != nil {
+		return OrderResult{}, err
+	}
+	if err := a.checkWritable(); err != nil {
+		return OrderResult{}, err
+	}
+
+	cfg := a.config()
+	var order OrderResult
+	computedLines := make([]computed, 0, len(req.Lines))
+	for i, line := range req.Lines {
+		if line.SKU == "" {
+			return OrderResult{}, invalidf("item %d: sku is required", i)
+		}
+
+		profile := line.Profile
+		if profile == "" {
+			profile = a.ProfileForSKU(line.SKU)
+		}
+
+		metadata := make(map[string]interface{}, len(req.Metadata)+1)
+		for k, v := range req.Metadata {
+			metadata[k] = v
+		}
+		metadata["sku"] = line.SKU
+
+		lineReq := Request{
+			Quantity:   line.Quantity,
+			Strategy:   req.Strategy,
+			Profile:    profile,
+			OrderID:    req.OrderID,
+			CustomerID: req.CustomerID,
+			Metadata:   metadata,
+		}
+		result, err := a.preview(ctx, cfg, lineReq)
+		if err != nil {
+			return OrderResult{}, fmt.Errorf("item %d (%s): %w", i, line.SKU, err)
+		}
+		computedLines = append(computedLines, computed{lineReq, result, cfg.version(profile)})
+
+		lr := LineResult{SKU: line.SKU, Quantity: line.Quantity, Profile: profile, Result: result}
+		totalQuantity, ok := addInt(order.TotalQuantity, lr.Quantity)
+		totalItems, itemsOK := addInt(order.TotalItems, lr.Total)
+		if !ok || !itemsOK {
+			return OrderResult{}, fmt.Errorf("item %d (%s): order total: %w", i, line.SKU, &OverflowError{Quantity: line.Quantity})
+		}
+		order.Lines = append(order.Lines, lr)
+		order.TotalQuantity = totalQuantity
+		order.TotalItems = totalItems
+		order.TotalPacks += lr.PackCount()
+		order.TotalWaste += lr.Waste()
+		order.Approximate = order.Approximate || lr.Approximate
+	}
+
+	a.store(computedLines...)
+	for _, c := range computedLines {
+		a.observe(c.req, c.result)
+		a.notifyAnomaly(c.req, c.result)
+		a.emitAllocation(ctx, c.req, c.result, c.version)
+		a.runShadow(cfg, c.req, c.result)
+	}
+	return order, nil
+}
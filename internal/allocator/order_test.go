@@ -0,0 +1,121 @@
+package allocator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetProfiles(t *testing.T) {
+	allocator := NewAllocator([]int{23, 31, 53}, newMockStorage())
+
+	err := allocator.SetProfiles(map[string][]int{"empty": {}}, nil)
+	assert.ErrorIs(t, err, ErrNoPackSizes)
+
+	err = allocator.SetProfiles(map[string][]int{"small": {5, 10}}, map[string]string{"SKU-1": "missing"})
+	assert.ErrorIs(t, err, ErrUnknownProfile)
+
+	err = allocator.SetProfiles(map[string][]int{"small": {5, 10}}, map[string]string{"SKU-1": "small"})
+	assert.NoError(t, err)
+	assert.Equal(t, "small", allocator.ProfileForSKU("SKU-1"))
+	assert.Equal(t, DefaultProfile, allocator.ProfileForSKU("SKU-2"))
+
+	_, err = allocator.Allocate(context.Background(), Request{Quantity: 10, Profile: "missing"})
+	assert.ErrorIs(t, err, ErrUnknownProfile)
+}
+
+func TestAllocateOrder(t *testing.T) {
+	storage := newMockStorage()
+	allocator := NewAllocator([]int{23, 31, 53}, storage)
+	assert.NoError(t, allocator.SetStrategy("dp"))
+	assert.NoError(t, allocator.SetProfiles(
+		map[string][]int{"small": {5, 10, 20}},
+		map[string]string{"SKU-SMALL": "small"},
+	))
+
+	order, err := allocator.AllocateOrder(context.Background(), OrderRequest{
+		OrderID: "ORD-1",
+		Lines: []OrderLine{
+			{SKU: "SKU-SMALL", Quantity: 7},
+			{SKU: "SKU-BIG", Quantity: 50},
+			{SKU: "SKU-OVERRIDE", Quantity: 25, Profile: "small"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, order.Lines, 3)
+
+	assert.Equal(t, "small", order.Lines[0].Profile)
+	assert.Equal(t, map[int]int{10: 1}, order.Lines[0].Packs)
+	assert.Equal(t, 3, order.Lines[0].Waste())
+
+	assert.Equal(t, DefaultProfile, order.Lines[1].Profile)
+	assert.Equal(t, map[int]int{53: 1}, order.Lines[1].Packs)
+
+	assert.Equal(t, map[int]int{20: 1, 5: 1}, order.Lines[2].Packs)
+	assert.Equal(t, 2, order.Lines[2].PackCount())
+
+	assert.Equal(t, 82, order.TotalQuantity)
+	assert.Equal(t, 88, order.TotalItems)
+	assert.Equal(t, 4, order.TotalPacks)
+	assert.Equal(t, 6, order.TotalWaste)
+
+	// Lines are persisted with the order reference and SKU
+	stored, err := storage.GetAllocationByQuantity(50)
+	assert.NoError(t, err)
+	assert.Equal(t, "ORD-1", stored.OrderID)
+	assert.Equal(t, "SKU-BIG", stored.Metadata["sku"])
+	// in a single batch
+	assert.Equal(t, 1, storage.batches)
+}
+
+func TestAllocateOrderValidation(t *testing.T) {
+	allocator := NewAllocator([]int{23, 31, 53}, newMockStorage())
+
+	_, err := allocator.AllocateOrder(context.Background(), OrderRequest{})
+	assert.ErrorIs(t, err, ErrEmptyOrder)
+
+	_, err = allocator.AllocateOrder(context.Background(), OrderRequest{Lines: []OrderLine{{Quantity: 5}}})
+	assert.Error(t, err)
+
+	_, err = allocator.AllocateOrder(context.Background(), OrderRequest{Lines: []OrderLine{{SKU: "A", Quantity: 0}}})
+	assert.ErrorIs(t, err, ErrInvalidQuantity)
+}
+
+func TestAllocateOrderStoresNothingOnFailure(t *testing.T) {
+	storage := newMockStorage()
+	allocator := NewAllocator([]int{23, 31, 53}, storage)
+
+	_, err := allocator.AllocateOrder(context.Background(), OrderRequest{
+		OrderID: "ORD-1",
+		Lines:   []OrderLine{{SKU: "A", Quantity: 50}, {SKU: "B", Quantity: -1}},
+	})
+	assert.ErrorIs(t, err, ErrInvalidQuantity)
+	assert.Empty(t, storage.allocations)
+}
+
+func TestRecordProfileVersions(t *testing.T) {
+	storage := newMockStorage()
+	allocator := NewAllocator([]int{23, 31, 53}, storage)
+	assert.NoError(t, allocator.SetProfiles(map[string][]int{"small": {5, 10}}, nil))
+	assert.NoError(t, allocator.RecordProfileVersions())
+
+	versions, err := allocator.ProfileVersions("small")
+	assert.NoError(t, err)
+	assert.Len(t, versions, 1)
+
+	_, err = allocator.Allocate(context.Background(), Request{Quantity: 7, Profile: "small"})
+	assert.NoError(t, err)
+	stored, _ := storage.GetAllocationByQuantity(7)
+	assert.Equal(t, "small", stored.Profile)
+	assert.Equal(t, 1, stored.ProfileVersion)
+
+	_, err = allocator.Allocate(context.Background(), Request{Quantity: 50})
+	assert.NoError(t, err)
+	stored, _ = storage.GetAllocationByQuantity(50)
+	assert.Equal(t, DefaultProfile, stored.Profile)
+	assert.Equal(t, 1, stored.ProfileVersion)
+
+	allocator.storage = nil
+	assert.ErrorIs(t, allocator.RecordProfileVersions(), ErrStorageNotConfigured)
+}
@@ -0,0 +1,177 @@
+package allocator
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+// Outbox defaults used by NewAllocator.
+const (
+	DefaultOutboxCapacity    = 10_000
+	DefaultOutboxMaxAttempts = 10
+)
+
+// OutboxOptions bounds the queue of allocation writes awaiting retry.
+type OutboxOptions struct {
+	// Capacity is the most writes held for retry; further failures are
+	// dropped. Zero disables the outbox, so failed writes are dropped at once.
+	Capacity int
+	// MaxAttempts is how many times a write is retried before it is dropped.
+	// Zero retries until it succeeds.
+	MaxAttempts int
+}
+
+// OutboxStats reports on allocation writes that failed the first time.
+type OutboxStats struct {
+	// Pending writes are queued for retry.
+	Pending int
+	// Persisted writes succeeded on a retry.
+	Persisted int64
+	// Failed writes were dropped, because the queue was full or they ran
+	// out of attempts, and are missing from the history.
+	Failed int64
+	// LastError is the most recent storage error, if any.
+	LastError string
+}
+
+// outbox queues allocation writes that failed so they can be retried in the
+// background instead of being lost from the history.
+type outbox struct {
+	mu       sync.Mutex
+	opts     OutboxOptions
+	queue    []outboxItem
+	stats    OutboxStats
+	flushing sync.Mutex
+}
+
+type outboxItem struct {
+	in       storage.AllocationInput
+	attempts int
+}
+
+func newOutbox(opts OutboxOptions) *outbox {
+	return &outbox{opts: opts}
+}
+
+// enqueue queues a write that failed with err.
+func (o *outbox) enqueue(in storage.AllocationInput, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.stats.LastError = err.Error()
+	if len(o.queue) >= o.opts.Capacity {
+		o.stats.Failed++
+		log.Printf("Failed to store allocation for quantity %d, outbox full, dropping it: %v", in.Quantity, err)
+		return
+	}
+	o.queue = append(o.queue, outboxItem{in: in, attempts: 1})
+	log.Printf("Failed to store allocation for quantity %d, queued for retry: %v", in.Quantity, err)
+}
+
+// flush retries queued writes in order and returns how many were persisted.
+// It stops at the first failure, since the storage is most likely still down.
+func (o *outbox) flush(s storage.History) (int, error) {
+	o.flushing.Lock()
+	defer o.flushing.Unlock()
+
+	persisted := 0
+	for {
+		o.mu.Lock()
+		if len(o.queue) == 0 {
+			o.mu.Unlock()
+			return persisted, nil
+		}
+		item := o.queue[0]
+		o.mu.Unlock()
+
+		err := s.StoreAllocationInput(item.in)
+
+		o.mu.Lock()
+		if len(o.queue) == 0 {
+			// SetOutbox dropped the queue meanwhile.
+			o.mu.Unlock()
+			return persisted, err
+		}
+		if err == nil {
+			o.queue = o.queue[1:]
+			o.stats.Persisted++
+			o.mu.Unlock()
+			persisted++
+			continue
+		}
+		o.stats.LastError = err.Error()
+		o.queue[0].attempts++
+		if o.opts.MaxAttempts > 0 && o.queue[0].attempts >= o.opts.MaxAttempts {
+			o.queue = o.queue[1:]
+			o.stats.Failed++
+			log.Printf("Dropping allocation for quantity %d after %d attempts: %v", item.in.Quantity, o.opts.MaxAttempts, err)
+		}
+		o.mu.Unlock()
+		return persisted, err
+	}
+}
+
+func (o *outbox) snapshot() OutboxStats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	stats := o.stats
+	stats.Pending = len(o.queue)
+	return stats
+}
+
+// SetOutbox configures the retry queue for failed allocation writes.
+// Writes already queued are kept, up to the new capacity.
+func (a *Allocator) SetOutbox(opts OutboxOptions) {
+	a.outbox.mu.Lock()
+	defer a.outbox.mu.Unlock()
+	a.outbox.opts = opts
+	if excess := len(a.outbox.queue) - opts.Capacity; excess > 0 {
+		a.outbox.queue = a.outbox.queue[:opts.Capacity]
+		a.outbox.stats.Failed += int64(excess)
+	}
+}
+
+// OutboxStats reports on failed allocation writes.
+func (a *Allocator) OutboxStats() OutboxStats {
+	return a.outbox.snapshot()
+}
+
+// FlushOutbox retries queued allocation writes now and returns how many
+// were persisted. Nothing is written while the allocator is read-only.
+func (a *Allocator) FlushOutbox() (int, error) {
+	if a.storage == nil {
+		return 0, nil
+	}
+	if a.ReadOnly().Enabled {
+		return 0, ErrReadOnly
+	}
+	return a.outbox.flush(a.storage)
+}
+
+// RunOutbox retries queued allocation writes every interval until ctx is done.
+func (a *Allocator) RunOutbox(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if a.OutboxStats().Pending == 0 || a.ReadOnly().Enabled {
+				continue
+			}
+			n, err := a.FlushOutbox()
+			if n > 0 {
+				log.Printf("Stored %d queued allocations", n)
+			}
+			if err != nil {
+				log.Printf("Retrying queued allocations failed, %d pending: %v", a.OutboxStats().Pending, err)
+			}
+		}
+	}
+}
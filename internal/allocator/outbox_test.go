@@ -0,0 +1,129 @@
+package allocator
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/n-th/gymshark/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyStorage fails allocation writes while down.
+type flakyStorage struct {
+	*mockStorage
+	down atomic.Bool
+}
+
+var errStorageDown = errors.New("database is locked")
+
+func (s *flakyStorage) StoreAllocationInput(in storage.AllocationInput) error {
+	if s.down.Load() {
+		return errStorageDown
+	}
+	return s.mockStorage.StoreAllocationInput(in)
+}
+
+func TestOutboxRetriesFailedWrites(t *testing.T) {
+	store := &flakyStorage{mockStorage: newMockStorage()}
+	allocator := NewAllocator([]int{23, 31, 53}, store)
+
+	store.down.Store(true)
+	_, err := allocator.Allocate(context.Background(), Request{Quantity: 50, OrderID: "ORD-1"})
+	assert.NoError(t, err, "storage failures do not fail the calculation")
+	assert.Nil(t, store.allocations[50])
+
+	stats := allocator.OutboxStats()
+	assert.Equal(t, 1, stats.Pending)
+	assert.Equal(t, errStorageDown.Error(), stats.LastError)
+
+	n, err := allocator.FlushOutbox()
+	assert.ErrorIs(t, err, errStorageDown)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, 1, allocator.OutboxStats().Pending)
+
+	store.down.Store(false)
+	n, err = allocator.FlushOutbox()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	stats = allocator.OutboxStats()
+	assert.Equal(t, 0, stats.Pending)
+	assert.Equal(t, int64(1), stats.Persisted)
+	assert.Equal(t, int64(0), stats.Failed)
+	if assert.NotNil(t, store.allocations[50]) {
+		assert.Equal(t, "ORD-1", store.allocations[50].OrderID)
+	}
+}
+
+func TestOutboxDropsAfterMaxAttempts(t *testing.T) {
+	store := &flakyStorage{mockStorage: newMockStorage()}
+	allocator := NewAllocator([]int{23, 31, 53}, store)
+	allocator.SetOutbox(OutboxOptions{Capacity: 10, MaxAttempts: 2})
+
+	store.down.Store(true)
+	_, err := allocator.Allocate(context.Background(), Request{Quantity: 50})
+	assert.NoError(t, err)
+
+	_, err = allocator.FlushOutbox()
+	assert.Error(t, err)
+
+	stats := allocator.OutboxStats()
+	assert.Equal(t, 0, stats.Pending)
+	assert.Equal(t, int64(1), stats.Failed)
+}
+
+func TestOutboxCapacity(t *testing.T) {
+	store := &flakyStorage{mockStorage: newMockStorage()}
+	allocator := NewAllocator([]int{23, 31, 53}, store)
+	allocator.SetOutbox(OutboxOptions{Capacity: 1})
+
+	store.down.Store(true)
+	for _, q := range []int{50, 100} {
+		_, err := allocator.Allocate(context.Background(), Request{Quantity: q})
+		assert.NoError(t, err)
+	}
+
+	stats := allocator.OutboxStats()
+	assert.Equal(t, 1, stats.Pending)
+	assert.Equal(t, int64(1), stats.Failed)
+}
+
+func TestFlushOutboxReadOnly(t *testing.T) {
+	store := &flakyStorage{mockStorage: newMockStorage()}
+	allocator := NewAllocator([]int{23, 31, 53}, store)
+
+	store.down.Store(true)
+	_, err := allocator.Allocate(context.Background(), Request{Quantity: 50})
+	assert.NoError(t, err)
+	store.down.Store(false)
+
+	assert.NoError(t, allocator.SetReadOnly(ReadOnly{Enabled: true}))
+	_, err = allocator.FlushOutbox()
+	assert.ErrorIs(t, err, ErrReadOnly)
+	assert.Equal(t, 1, allocator.OutboxStats().Pending)
+}
+
+func TestRunOutbox(t *testing.T) {
+	store := &flakyStorage{mockStorage: newMockStorage()}
+	allocator := NewAllocator([]int{23, 31, 53}, store)
+
+	store.down.Store(true)
+	_, err := allocator.Allocate(context.Background(), Request{Quantity: 50})
+	assert.NoError(t, err)
+	store.down.Store(false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		allocator.RunOutbox(ctx, 5*time.Millisecond)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool { return allocator.OutboxStats().Pending == 0 }, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+
+	assert.Equal(t, int64(1), allocator.OutboxStats().Persisted)
+}
@@ -0,0 +1,60 @@
+package allocator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/n-th/gymshark/pkg/packer"
+)
+
+// ErrOverflow reports a quantity too large for the allocator to calculate
+// exactly: some total it would consider does not fit in an int.
+var ErrOverflow = tooLarge("quantity too large for exact calculation")
+
+// OverflowError reports a calculation whose totals would not fit in an int.
+// It matches ErrOverflow with errors.Is.
+type OverflowError struct {
+	Quantity int
+}
+
+func (e *OverflowError) Error() string {
+	return fmt.Sprintf("quantity %d is too large for exact calculation", e.Quantity)
+}
+
+// Is makes errors.Is(err, ErrOverflow) and errors.Is(err, ErrTooLarge) true
+// for overflowing calculations.
+func (e *OverflowError) Is(target error) bool {
+	return errors.Is(ErrOverflow, target)
+}
+
+// addInt returns a + b, or false if the sum overflows. Both must be
+// non-negative.
+func addInt(a, b int) (int, bool) {
+	if a > math.MaxInt-b {
+		return 0, false
+	}
+	return a + b, true
+}
+
+// mulInt returns a * b, or false if the product overflows. Both must be
+// non-negative.
+func mulInt(a, b int) (int, bool) {
+	if a != 0 && b > math.MaxInt/a {
+		return 0, false
+	}
+	return a * b, true
+}
+
+// checkOverflow returns an OverflowError unless quantity + 2*largest fits
+// in an int. That bounds the totals the dp, backtracking and greedy
+// strategies consider: none goes past the quantity plus the smallest size
+// before correcting with one pack of another. The combination strategy's
+// totals go further, to about len(sizes)-1 times the quantity, so it checks
+// its sums itself. sizes must be sorted largest first.
+func checkOverflow(quantity int, sizes []int) error {
+	if packer.CheckOverflow(quantity, sizes) != nil {
+		return &OverflowError{Quantity: quantity}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package allocator
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckedArithmetic(t *testing.T) {
+	sum, ok := addInt(math.MaxInt-1, 1)
+	assert.True(t, ok)
+	assert.Equal(t, math.MaxInt, sum)
+	_, ok = addInt(math.MaxInt, 1)
+	assert.False(t, ok)
+
+	product, ok := mulInt(math.MaxInt/2, 2)
+	assert.True(t, ok)
+	assert.Equal(t, math.MaxInt-1, product)
+	_, ok = mulInt(math.MaxInt/2+1, 2)
+	assert.False(t, ok)
+	product, ok = mulInt(0, math.MaxInt)
+	assert.True(t, ok)
+	assert.Zero(t, product)
+}
+
+func TestAllocateOverflow(t *testing.T) {
+	ctx := context.Background()
+	a := NewAllocator([]int{23, 31, 53}, nil)
+
+	// The largest quantity whose totals fit is still calculated.
+	largest := math.MaxInt - 2*53
+	result, err := a.Allocate(ctx, Request{Quantity: largest, Strategy: "greedy"})
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, result.Total, largest)
+
+	_, err = a.Allocate(ctx, Request{Quantity: largest + 1, Strategy: "greedy"})
+	assert.ErrorIs(t, err, ErrOverflow)
+	var overflow *OverflowError
+	if assert.True(t, errors.As(err, &overflow)) {
+		assert.Equal(t, largest+1, overflow.Quantity)
+	}
+	assert.EqualError(t, err, "quantity 9223372036854775702 is too large for exact calculation")
+
+	_, err = a.Allocate(ctx, Request{Quantity: math.MaxInt, Constraints: &Constraints{MaxPacks: 1}})
+	assert.ErrorIs(t, err, ErrOverflow)
+
+	// Pack sizes too large to double overflow for every quantity.
+	_, err = a.Evaluate(ctx, 10, []int{math.MaxInt/2 + 1}, "greedy")
+	assert.ErrorIs(t, err, ErrOverflow)
+}
+
+func TestCombinationOverflow(t *testing.T) {
+	// Topping up 2^62 with all three smaller sizes totals about 3*2^62,
+	// whose overage must not wrap around into a "better" negative one.
+	quantity := 1 << 62
+	result, err := NewAllocator(nil, nil).Evaluate(context.Background(), quantity,
+		[]int{1 << 60, 1<<59 + 1, 1<<58 + 3, 1<<57 + 5}, "combination")
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{1 << 60: 4}, result.Packs)
+	assert.Equal(t, quantity, result.Total)
+}
+
+func TestAllocateConstrainedHugeLimits(t *testing.T) {
+	ctx := context.Background()
+	a := NewAllocator([]int{23, 31, 53}, nil)
+
+	// Limits whose capacity overflows hold any quantity, and do not prune
+	// every branch.
+	for _, c := range []*Constraints{
+		{MaxPacks: math.MaxInt},
+		{Available: map[int]int{53: math.MaxInt, 31: math.MaxInt, 23: math.MaxInt}},
+		{Available: map[int]int{53: math.MaxInt}, Costs: map[int]float64{53: 1, 31: 2}},
+	} {
+		result, err := a.Allocate(ctx, Request{Quantity: 100, Constraints: c})
+		assert.NoError(t, err, "%+v", c)
+		assert.Equal(t, 100, result.Total, "%+v", c)
+	}
+}
+
+func TestAllocateOrderOverflow(t *testing.T) {
+	a := NewAllocator([]int{23, 31, 53}, nil)
+	_, err := a.AllocateOrder(context.Background(), OrderRequest{
+		Strategy: "greedy",
+		Lines: []OrderLine{
+			{SKU: "SKU-1", Quantity: math.MaxInt / 2},
+			{SKU: "SKU-2", Quantity: math.MaxInt / 2},
+		},
+	})
+	assert.ErrorIs(t, err, ErrOverflow)
+	assert.ErrorContains(t, err, "item 1 (SKU-2): order total")
+}
@@ -0,0 +1,193 @@
+package allocator
+
+import (
+	"fmt"
+	"sort"
+)
+
+var ErrPackSizeRule = invalid("pack sizes break a rule")
+
+// Severity is what breaking a pack-size rule does.
+type Severity string
+
+const (
+	// SeverityOff disables a rule.
+	SeverityOff Severity = "off"
+	// SeverityWarning reports the issue but accepts the sizes.
+	SeverityWarning Severity = "warning"
+	// SeverityError rejects the sizes.
+	SeverityError Severity = "error"
+)
+
+// Pack-size rules checked by PackSizeRules.
+const (
+	// RuleDuplicate flags a size listed more than once.
+	RuleDuplicate = "duplicate"
+	// RuleUnitPack flags a size of 1 next to other sizes: every quantity is
+	// then met exactly, so waste no longer steers the choice of packs.
+	RuleUnitPack = "unit_pack"
+	// RuleMultiple flags a size that is an exact multiple of a smaller one.
+	RuleMultiple = "multiple"
+	// RuleRatio flags a largest size more than MaxRatio times the smallest,
+	// where small orders degenerate into piles of the smallest pack.
+	RuleRatio = "ratio"
+)
+
+// DefaultMaxPackRatio is the MaxRatio used when PackSizeRules leaves it zero.
+const DefaultMaxPackRatio = 1000
+
+// PackSizeRules sets the severity of each rule. An empty severity uses the
+// rule's default: error for duplicates, warning for a unit pack and for the
+// ratio, and off for multiples, which common size ladders such as 250, 500,
+// 1000 are made of.
+type PackSizeRules struct {
+	Duplicate Severity
+	UnitPack  Severity
+	Multiple  Severity
+	Ratio     Severity
+	// MaxRatio is the largest size allowed as a multiple of the smallest;
+	// zero means DefaultMaxPackRatio.
+	MaxRatio int
+}
+
+// PackSizeIssue is a rule a profile's pack sizes break.
+type PackSizeIssue struct {
+	Profile  string
+	Rule     string
+	Severity Severity
+	Message  string
+}
+
+// withDefaults fills in the default of every rule left empty.
+func (r PackSizeRules) withDefaults() PackSizeRules {
+	for _, s := range []struct {
+		severity *Severity
+		def      Severity
+	}{
+		{&r.Duplicate, SeverityError},
+		{&r.UnitPack, SeverityWarning},
+		{&r.Multiple, SeverityOff},
+		{&r.Ratio, SeverityWarning},
+	} {
+		if *s.severity == "" {
+			*s.severity = s.def
+		}
+	}
+	if r.MaxRatio == 0 {
+		r.MaxRatio = DefaultMaxPackRatio
+	}
+	return r
+}
+
+// Validate returns an error for unknown severities or a negative MaxRatio.
+func (r PackSizeRules) Validate() error {
+	for rule, severity := range map[string]Severity{
+		RuleDuplicate: r.Duplicate,
+		RuleUnitPack:  r.UnitPack,
+		RuleMultiple:  r.Multiple,
+		RuleRatio:     r.Ratio,
+	} {
+		switch severity {
+		case "", SeverityOff, SeverityWarning, SeverityError:
+		default:
+			return fmt.Errorf("pack size rule %s: unknown severity %q (want off, warning or error)", rule, severity)
+		}
+	}
+	if r.MaxRatio < 0 {
+		return fmt.Errorf("pack size rule %s: max ratio must not be negative", RuleRatio)
+	}
+	return nil
+}
+
+// Check returns the issues of a profile's pack sizes, in rule order.
+func (r PackSizeRules) Check(profile string, sizes []int) []PackSizeIssue {
+	r = r.withDefaults()
+	sorted := sortedSizes(sizes)
+	var issues []PackSizeIssue
+	report := func(rule string, severity Severity, format string, args ...interface{}) {
+		if severity != SeverityOff {
+			issues = append(issues, PackSizeIssue{Profile: profile, Rule: rule, Severity: severity, Message: fmt.Sprintf(format, args...)})
+		}
+	}
+
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i] == sorted[i-1] && (i == 1 || sorted[i-2] != sorted[i]) {
+			report(RuleDuplicate, r.Duplicate, "pack size %d is listed more than once", sorted[i])
+		}
+	}
+	if n := len(sorted); n > 1 && sorted[n-1] == 1 && sorted[0] > 1 {
+		report(RuleUnitPack, r.UnitPack, "pack size 1 meets every quantity exactly, so the other sizes only reduce the pack count")
+	}
+	distinct := distinctSizes(sorted)
+	for i, large := range distinct {
+		for _, small := range distinct[i+1:] {
+			if small > 1 && large%small == 0 {
+				report(RuleMultiple, r.Multiple, "pack size %d is a multiple of %d", large, small)
+				break
+			}
+		}
+	}
+	if n := len(sorted); n > 1 && sorted[0]/sorted[n-1] > r.MaxRatio {
+		report(RuleRatio, r.Ratio, "largest pack size %d is more than %d times the smallest, %d", sorted[0], r.MaxRatio, sorted[n-1])
+	}
+	return issues
+}
+
+// distinctSizes returns sizes sorted in descending order without duplicates.
+func distinctSizes(sorted []int) []int {
+	distinct := make([]int, 0, len(sorted))
+	for i, size := range sorted {
+		if i == 0 || size != sorted[i-1] {
+			distinct = append(distinct, size)
+		}
+	}
+	return distinct
+}
+
+// checkRules returns an error wrapping ErrPackSizeRule for the first issue of
+// error severity among issues.
+func checkRules(issues []PackSizeIssue) error {
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			return fmt.Errorf("%w: %s: %s", ErrPackSizeRule, issue.Rule, issue.Message)
+		}
+	}
+	return nil
+}
+
+// SetPackSizeRules configures the rules pack sizes are checked against.
+// Profile updates breaking a rule of error severity are rejected from then
+// on; profiles already configured are not, see ValidateProfiles.
+func (a *Allocator) SetPackSizeRules(r PackSizeRules) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	return a.updateConfig(func(next *snapshot) error {
+		next.packRules = r
+		return nil
+	})
+}
+
+// PackSizeRules returns the configured pack-size rules.
+func (a *Allocator) PackSizeRules() PackSizeRules {
+	return a.config().packRules
+}
+
+// ValidateProfiles checks the default and named profiles against the
+// pack-size rules, returning their issues by profile name. The weight
+// profile is not checked.
+func (a *Allocator) ValidateProfiles() []PackSizeIssue {
+	cfg := a.config()
+	issues := cfg.packRules.Check(DefaultProfile, cfg.packSizes)
+	names := make([]string, 0, len(cfg.profiles))
+	for name := range cfg.profiles {
+		if name != WeightProfile {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		issues = append(issues, cfg.packRules.Check(name, cfg.profiles[name])...)
+	}
+	return issues
+}
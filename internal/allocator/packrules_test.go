@@ -0,0 +1,78 @@
+package allocator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackSizeRulesCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    PackSizeRules
+		sizes    []int
+		expected []string
+	}{
+		{"clean", PackSizeRules{}, []int{23, 31, 53}, nil},
+		{"size ladder", PackSizeRules{}, []int{250, 500, 1000, 2000, 5000}, nil},
+		{"duplicate", PackSizeRules{}, []int{250, 500, 250, 250}, []string{"error duplicate: pack size 250 is listed more than once"}},
+		{"unit pack", PackSizeRules{}, []int{1, 50}, []string{"warning unit_pack: pack size 1 meets every quantity exactly, so the other sizes only reduce the pack count"}},
+		{"only a unit pack", PackSizeRules{}, []int{1}, nil},
+		{"ratio", PackSizeRules{}, []int{2, 5000}, []string{"warning ratio: largest pack size 5000 is more than 1000 times the smallest, 2"}},
+		{"custom ratio", PackSizeRules{Ratio: SeverityError, MaxRatio: 10}, []int{250, 5000}, []string{"error ratio: largest pack size 5000 is more than 10 times the smallest, 250"}},
+		{"multiple", PackSizeRules{Multiple: SeverityWarning}, []int{250, 500, 1000, 333}, []string{
+			"warning multiple: pack size 1000 is a multiple of 500",
+			"warning multiple: pack size 500 is a multiple of 250",
+		}},
+		{"rules off", PackSizeRules{Duplicate: SeverityOff, UnitPack: SeverityOff}, []int{1, 1, 50}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, issue := range tt.rules.Check("default", tt.sizes) {
+				assert.Equal(t, "default", issue.Profile)
+				got = append(got, string(issue.Severity)+" "+issue.Rule+": "+issue.Message)
+			}
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestPackSizeRulesValidate(t *testing.T) {
+	assert.NoError(t, PackSizeRules{}.Validate())
+	assert.NoError(t, PackSizeRules{Duplicate: SeverityWarning, Multiple: SeverityError, MaxRatio: 50}.Validate())
+	assert.Error(t, PackSizeRules{UnitPack: "fatal"}.Validate())
+	assert.Error(t, PackSizeRules{MaxRatio: -1}.Validate())
+
+	a := NewAllocator([]int{23, 31, 53}, newMockStorage())
+	assert.Error(t, a.SetPackSizeRules(PackSizeRules{Ratio: "maybe"}))
+	assert.Equal(t, PackSizeRules{}, a.PackSizeRules())
+}
+
+func TestValidateProfiles(t *testing.T) {
+	a := NewAllocator([]int{1, 250, 500}, newMockStorage())
+	assert.NoError(t, a.SetProfiles(map[string][]int{"bulk": {1000, 1000}, "small": {5, 7}}, nil))
+	assert.NoError(t, a.SetWeightPackSizes([]Weight{1, 5000}))
+
+	issues := a.ValidateProfiles()
+	if assert.Len(t, issues, 2) {
+		assert.Equal(t, PackSizeIssue{Profile: DefaultProfile, Rule: RuleUnitPack, Severity: SeverityWarning,
+			Message: "pack size 1 meets every quantity exactly, so the other sizes only reduce the pack count"}, issues[0])
+		assert.Equal(t, "bulk", issues[1].Profile)
+		assert.Equal(t, SeverityError, issues[1].Severity)
+	}
+
+	// Profile updates breaking a rule of error severity are rejected
+	_, err := a.UpdateProfile(context.Background(), "small", []int{5, 5})
+	assert.ErrorIs(t, err, ErrPackSizeRule)
+	_, err = a.UpdateProfile(context.Background(), "small", []int{1, 5})
+	assert.NoError(t, err)
+
+	assert.NoError(t, a.SetPackSizeRules(PackSizeRules{UnitPack: SeverityError}))
+	_, err = a.UpdateProfile(context.Background(), "small", []int{1, 7})
+	assert.ErrorIs(t, err, ErrPackSizeRule)
+	sizes, err := a.profileSizes("small")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{5, 1}, sizes)
+}
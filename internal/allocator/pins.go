@@ -0,0 +1,198 @@
+package allocator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/n-th/gymshark/internal/invalidation"
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+// SourceManual marks results served from a pin rather than computed.
+const SourceManual = "manual"
+
+var ErrInvalidPin = invalid("invalid pin")
+
+// pinKey identifies the pin of a quantity of a profile.
+type pinKey struct {
+	profile  string
+	quantity int
+}
+
+// LoadPins reads the pinned allocations from storage, replacing those held
+// in memory. It is called at startup and whenever another replica changes
+// the pins.
+func (a *Allocator) LoadPins() error {
+	if a.storage == nil {
+		return ErrStorageNotConfigured
+	}
+	stored, err := a.storage.GetPins()
+	if err != nil {
+		return fmt.Errorf("load pins: %w", err)
+	}
+	pins := make(map[pinKey]storage.Pin, len(stored))
+	for _, p := range stored {
+		pins[pinKey{p.Profile, p.Quantity}] = p
+	}
+	a.pinsMu.Lock()
+	a.pins = pins
+	a.pinsMu.Unlock()
+	return nil
+}
+
+// Pins returns the pinned allocations, ordered by profile and quantity.
+func (a *Allocator) Pins() []storage.Pin {
+	a.pinsMu.RLock()
+	pins := make([]storage.Pin, 0, len(a.pins))
+	for _, p := range a.pins {
+		pins = append(pins, p)
+	}
+	a.pinsMu.RUnlock()
+	sort.Slice(pins, func(i, j int) bool {
+		if pins[i].Profile != pins[j].Profile {
+			return pins[i].Profile < pins[j].Profile
+		}
+		return pins[i].Quantity < pins[j].Quantity
+	})
+	return pins
+}
+
+// Pin stores a manual pack breakdown that calculations for p.Quantity with
+// p.Profile return, flagged with SourceManual, instead of computing one,
+// until it is removed with Unpin. Constrained calculations ignore pins.
+// The packs must use the profile's sizes and cover the quantity; the total
+// is computed from them. The change is published to the other replicas when
+// an invalidation bus is configured.
+// It fails with ErrReadOnly while the allocator is read-only.
+func (a *Allocator) Pin(ctx context.Context, p storage.Pin) (storage.Pin, error) {
+	if a.storage == nil {
+		return storage.Pin{}, ErrStorageNotConfigured
+	}
+	if a.ReadOnly().Enabled {
+		return storage.Pin{}, ErrReadOnly
+	}
+	if p.Profile == "" {
+		p.Profile = DefaultProfile
+	}
+	if err := a.validatePin(&p); err != nil {
+		return storage.Pin{}, err
+	}
+	p.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	if err := a.storage.PinAllocation(p); err != nil {
+		return storage.Pin{}, fmt.Errorf("store pin: %w", err)
+	}
+
+	a.pinsMu.Lock()
+	pins := make(map[pinKey]storage.Pin, len(a.pins)+1)
+	for k, v := range a.pins {
+		pins[k] = v
+	}
+	pins[pinKey{p.Profile, p.Quantity}] = p
+	a.pins = pins
+	a.pinsMu.Unlock()
+
+	return p, a.publish(ctx, invalidation.Event{Type: invalidation.EventPins})
+}
+
+// validatePin checks the pack breakdown against the profile's sizes and
+// sets its total.
+func (a *Allocator) validatePin(p *storage.Pin) error {
+	if p.Quantity <= 0 {
+		return ErrInvalidQuantity
+	}
+	if p.Profile == WeightProfile {
+		return fmt.Errorf("%w: weight allocations cannot be pinned", ErrInvalidPin)
+	}
+	sizes, err := a.profileSizes(p.Profile)
+	if err != nil {
+		return err
+	}
+	if len(p.Packs) == 0 {
+		return fmt.Errorf("%w: no packs", ErrInvalidPin)
+	}
+	allowed := make(map[int]bool, len(sizes))
+	for _, size := range sizes {
+		allowed[size] = true
+	}
+	total := 0
+	for size, count := range p.Packs {
+		if !allowed[size] {
+			return fmt.Errorf("%w: %d is not a pack size of profile %q", ErrInvalidPin, size, p.Profile)
+		}
+		if count <= 0 {
+			return fmt.Errorf("%w: pack count for size %d must be positive", ErrInvalidPin, size)
+		}
+		total += size * count
+	}
+	if total < p.Quantity {
+		return fmt.Errorf("%w: packs total %d is less than quantity %d", ErrInvalidPin, total, p.Quantity)
+	}
+	p.Total = total
+	return nil
+}
+
+// Unpin removes the pin for quantity of profile, so calculations compute it
+// again. It reports whether there was a pin.
+// It fails with ErrReadOnly while the allocator is read-only.
+func (a *Allocator) Unpin(ctx context.Context, quantity int, profile string) (bool, error) {
+	if a.storage == nil {
+		return false, ErrStorageNotConfigured
+	}
+	if a.ReadOnly().Enabled {
+		return false, ErrReadOnly
+	}
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	removed, err := a.storage.UnpinAllocation(profile, quantity)
+	if err != nil {
+		return false, fmt.Errorf("remove pin: %w", err)
+	}
+
+	a.pinsMu.Lock()
+	pins := make(map[pinKey]storage.Pin, len(a.pins))
+	for k, v := range a.pins {
+		pins[k] = v
+	}
+	delete(pins, pinKey{profile, quantity})
+	a.pins = pins
+	a.pinsMu.Unlock()
+
+	return removed, a.publish(ctx, invalidation.Event{Type: invalidation.EventPins})
+}
+
+// pinned returns the pinned result for quantity of profile, if any.
+func (a *Allocator) pinned(quantity int, profile string) (Result, bool) {
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	a.pinsMu.RLock()
+	p, ok := a.pins[pinKey{profile, quantity}]
+	a.pinsMu.RUnlock()
+	if !ok {
+		return Result{}, false
+	}
+	packs := make(map[int]int, len(p.Packs))
+	for size, count := range p.Packs {
+		packs[size] = count
+	}
+	return Result{
+		Packs:      packs,
+		Total:      p.Total,
+		Source:     SourceManual,
+		ComputedAt: p.CreatedAt,
+		Stats:      Stats{Strategy: SourceManual, Cache: CacheBypass},
+	}, true
+}
+
+// reloadPins reloads the pins after a change by another replica.
+func (a *Allocator) reloadPins(origin string) {
+	if err := a.LoadPins(); err != nil {
+		log.Printf("Failed to reload pins changed by replica %s: %v", origin, err)
+		return
+	}
+	log.Printf("Pins reloaded after a change by replica %s", origin)
+}
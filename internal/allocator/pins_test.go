@@ -0,0 +1,118 @@
+package allocator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/n-th/gymshark/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPin(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStorage()
+	a := NewAllocator([]int{250, 500, 1000}, store)
+	assert.NoError(t, a.SetProfiles(map[string][]int{"apparel": {10, 20}}, nil))
+	assert.NoError(t, a.RecordProfileVersions())
+
+	pin, err := a.Pin(ctx, storage.Pin{Quantity: 600, Packs: map[int]int{250: 3}, Reason: "bundle"})
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultProfile, pin.Profile)
+	assert.Equal(t, 750, pin.Total)
+
+	result, err := a.Allocate(ctx, Request{Quantity: 600})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{250: 3}, result.Packs)
+	assert.Equal(t, 750, result.Total)
+	assert.Equal(t, SourceManual, result.Source)
+	assert.Equal(t, SourceManual, store.allocations[600].Source)
+
+	// Other profiles, quantities and constrained requests are computed
+	result, err = a.Allocate(ctx, Request{Quantity: 600, Profile: "apparel"})
+	assert.NoError(t, err)
+	assert.Equal(t, "", result.Source)
+	result, err = a.Allocate(ctx, Request{Quantity: 600, Constraints: &Constraints{MaxPacks: 2}})
+	assert.NoError(t, err)
+	assert.Equal(t, "", result.Source)
+
+	removed, err := a.Unpin(ctx, 600, "")
+	assert.NoError(t, err)
+	assert.True(t, removed)
+	assert.Empty(t, a.Pins())
+	result, err = a.Allocate(ctx, Request{Quantity: 600})
+	assert.NoError(t, err)
+	assert.Equal(t, "", result.Source)
+	assert.Equal(t, 750, result.Total)
+	assert.NotEqual(t, map[int]int{250: 3}, result.Packs)
+
+	removed, err = a.Unpin(ctx, 600, "")
+	assert.NoError(t, err)
+	assert.False(t, removed)
+}
+
+func TestPinValidation(t *testing.T) {
+	ctx := context.Background()
+	a := NewAllocator([]int{250, 500, 1000}, newMockStorage())
+
+	tests := []struct {
+		name string
+		pin  storage.Pin
+		err  error
+	}{
+		{"no quantity", storage.Pin{Packs: map[int]int{250: 1}}, ErrInvalidQuantity},
+		{"unknown profile", storage.Pin{Quantity: 10, Profile: "missing", Packs: map[int]int{250: 1}}, ErrUnknownProfile},
+		{"no packs", storage.Pin{Quantity: 10}, ErrInvalidPin},
+		{"unknown size", storage.Pin{Quantity: 10, Packs: map[int]int{300: 1}}, ErrInvalidPin},
+		{"zero count", storage.Pin{Quantity: 10, Packs: map[int]int{250: 0}}, ErrInvalidPin},
+		{"short", storage.Pin{Quantity: 600, Packs: map[int]int{250: 2}}, ErrInvalidPin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.Pin(ctx, tt.pin)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+	assert.Empty(t, a.Pins())
+
+	_, err := NewAllocator([]int{250}, nil).Pin(ctx, storage.Pin{Quantity: 10, Packs: map[int]int{250: 1}})
+	assert.ErrorIs(t, err, ErrStorageNotConfigured)
+
+	a.SetReadOnly(ReadOnly{Enabled: true})
+	_, err = a.Pin(ctx, storage.Pin{Quantity: 10, Packs: map[int]int{250: 1}})
+	assert.ErrorIs(t, err, ErrReadOnly)
+	_, err = a.Unpin(ctx, 10, "")
+	assert.ErrorIs(t, err, ErrReadOnly)
+}
+
+func TestPinPropagates(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := &memBus{}
+	store := newMockStorage()
+	assert.NoError(t, store.PinAllocation(storage.Pin{Profile: DefaultProfile, Quantity: 100, Packs: map[int]int{500: 1}, Total: 500}))
+
+	// Replicas sharing a database load its pins when they subscribe
+	replicas := make([]*Allocator, 2)
+	for i := range replicas {
+		replicas[i] = NewAllocator([]int{250, 500, 1000}, store)
+		replicas[i].SetInvalidationBus(bus)
+		go replicas[i].ListenForInvalidations(ctx)
+	}
+	for bus.subscriberCount() < len(replicas) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Len(t, replicas[1].Pins(), 1)
+
+	_, err := replicas[0].Pin(ctx, storage.Pin{Quantity: 600, Packs: map[int]int{250: 3}})
+	assert.NoError(t, err)
+	result, err := replicas[1].Preview(ctx, Request{Quantity: 600})
+	assert.NoError(t, err)
+	assert.Equal(t, SourceManual, result.Source)
+
+	_, err = replicas[0].Unpin(ctx, 100, "")
+	assert.NoError(t, err)
+	result, err = replicas[1].Preview(ctx, Request{Quantity: 100})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{250: 1}, result.Packs)
+}
@@ -0,0 +1,139 @@
+package allocator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/n-th/gymshark/internal/storage"
+	"github.com/n-th/gymshark/pkg/packer"
+)
+
+// MaxPrecomputeQuantity bounds the range Precompute covers, since its table
+// holds two integers for every total up to the end of the range.
+const MaxPrecomputeQuantity = 1_000_000
+
+// precomputeBatchSize bounds the allocations Precompute stores per
+// transaction.
+const precomputeBatchSize = 500
+
+// PrecomputeResult reports what Precompute stored.
+type PrecomputeResult struct {
+	Profile string `json:"profile"`
+	From    int    `json:"from"`
+	To      int    `json:"to"`
+	// Stored counts allocations computed and stored.
+	Stored int `json:"stored"`
+	// Skipped counts quantities that already had a current allocation
+	// stored, or are pinned.
+	Skipped int `json:"skipped"`
+}
+
+// Precompute stores the allocation of every quantity in [from, to] for a
+// profile, so requests for them are served from the result cache (see
+// SetResultCache). A single dynamic-programming table is built for the whole
+// range, so this is far cheaper than computing each quantity separately.
+// Quantities with a current stored allocation or a pin are skipped.
+// Profiles with pack limits are not supported, since the table ignores them.
+// Allocations are stored in transactions of up to precomputeBatchSize. It
+// fails with ErrReadOnly while the allocator is read-only. If ctx is done
+// part way, the batches stored so far are kept.
+func (a *Allocator) Precompute(ctx context.Context, from, to int, profile string) (PrecomputeResult, error) {
+	start := time.Now()
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	result := PrecomputeResult{Profile: profile, From: from, To: to}
+	if a.storage == nil {
+		return result, ErrStorageNotConfigured
+	}
+	if a.ReadOnly().Enabled {
+		return result, ErrReadOnly
+	}
+	if from <= 0 || to < from {
+		return result, fmt.Errorf("%w: range must satisfy 0 < from <= to", ErrInvalidQuantity)
+	}
+	if err := a.checkQuantity(to); err != nil {
+		return result, err
+	}
+	if to > MaxPrecomputeQuantity {
+		return result, &LimitError{Field: "quantity", Value: to, Limit: MaxPrecomputeQuantity}
+	}
+	cfg := a.config()
+	if len(cfg.limits(profile)) > 0 {
+		return result, fmt.Errorf("%w: profile %q has pack limits", ErrConstraintsUnsupported, profile)
+	}
+	sizes, err := cfg.sizes(profile)
+	if err != nil {
+		return result, err
+	}
+	if len(sizes) == 0 {
+		return result, ErrNoPackSizes
+	}
+
+	if err := checkOverflow(to, sizes); err != nil {
+		return result, err
+	}
+	deprecated := cfg.deprecated(profile)
+	table, err := packer.NewTable(ctx, to+sizes[len(sizes)-1]-1, sizes, deprecated)
+	if err != nil {
+		return result, err
+	}
+	version := cfg.version(profile)
+	batch := make([]storage.AllocationInput, 0, precomputeBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := a.storage.StoreAllocations(batch); err != nil {
+			return fmt.Errorf("store allocations for quantities %d-%d: %w",
+				batch[0].Quantity, batch[len(batch)-1].Quantity, err)
+		}
+		for _, in := range batch {
+			a.cacheAllocation(in)
+		}
+		result.Stored += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+	for q := from; q <= to; q++ {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if r, ok := a.storedResult(cfg, q, profile); ok && deprecatedPacks(r.Packs, deprecated) == nil {
+			result.Skipped++
+			continue
+		}
+		if _, ok := a.pinned(q, profile); ok {
+			result.Skipped++
+			continue
+		}
+		r, ok := solve(table, q)
+		if !ok {
+			// Unreachable: a multiple of the smallest size is always in range.
+			continue
+		}
+		in := storage.AllocationInput{
+			Quantity:       q,
+			Packs:          r.Packs,
+			Total:          r.Total,
+			Profile:        profile,
+			ProfileVersion: version,
+			Algorithm:      ExactStrategy,
+			Cacheable:      true,
+			CreatedAt:      time.Now(),
+		}
+		if batch = append(batch, in); len(batch) == precomputeBatchSize {
+			if err := flush(); err != nil {
+				return result, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return result, err
+	}
+	log.Printf("Precomputed %d allocations for quantities %d-%d of profile %q in %s (%d skipped)",
+		result.Stored, from, to, profile, time.Since(start), result.Skipped)
+	return result, nil
+}
@@ -0,0 +1,73 @@
+package allocator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/n-th/gymshark/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrecompute(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStorage()
+	a := NewAllocator([]int{23, 31, 53}, store)
+	assert.NoError(t, a.RecordProfileVersions())
+	a.SetResultCache(true)
+
+	_, err := a.Pin(ctx, storage.Pin{Quantity: 10, Packs: map[int]int{53: 1}})
+	assert.NoError(t, err)
+
+	result, err := a.Precompute(ctx, 1, 2000, "")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultProfile, result.Profile)
+	assert.Equal(t, 1999, result.Stored)
+	assert.Equal(t, 1, result.Skipped)
+	assert.Equal(t, (1999+precomputeBatchSize-1)/precomputeBatchSize, store.batches)
+
+	// Stored allocations match the strategies' and are served from cache
+	for q := 1; q <= 2000; q += 37 {
+		want, err := dpStrategy(ctx, q, []int{53, 31, 23})
+		assert.NoError(t, err)
+		got, err := a.Preview(ctx, Request{Quantity: q})
+		assert.NoError(t, err)
+		assert.Equal(t, want.Total, got.Total, "quantity %d", q)
+		assert.Equal(t, packCount(want.Packs), packCount(got.Packs), "quantity %d", q)
+		if q != 10 {
+			assert.Equal(t, CacheHit, got.Stats.Cache, "quantity %d", q)
+		}
+	}
+
+	// A second run finds everything stored
+	result, err = a.Precompute(ctx, 1, 2000, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.Stored)
+	assert.Equal(t, 2000, result.Skipped)
+}
+
+func TestPrecomputeErrors(t *testing.T) {
+	ctx := context.Background()
+	a := NewAllocator([]int{250, 500}, newMockStorage())
+	a.SetLimits(Limits{MaxQuantity: 10_000})
+
+	_, err := a.Precompute(ctx, 0, 10, "")
+	assert.ErrorIs(t, err, ErrInvalidQuantity)
+	_, err = a.Precompute(ctx, 10, 5, "")
+	assert.ErrorIs(t, err, ErrInvalidQuantity)
+	_, err = a.Precompute(ctx, 1, 20_000, "")
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+	_, err = a.Precompute(ctx, 1, 10, "missing")
+	assert.ErrorIs(t, err, ErrUnknownProfile)
+
+	assert.NoError(t, a.SetPackLimits(map[string]map[int]int{DefaultProfile: {500: 1}}))
+	_, err = a.Precompute(ctx, 1, 10, "")
+	assert.ErrorIs(t, err, ErrConstraintsUnsupported)
+
+	_, err = NewAllocator([]int{250}, nil).Precompute(ctx, 1, 10, "")
+	assert.ErrorIs(t, err, ErrStorageNotConfigured)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = NewAllocator([]int{250}, newMockStorage()).Precompute(canceled, 1, 10, "")
+	assert.ErrorIs(t, err, context.Canceled)
+}
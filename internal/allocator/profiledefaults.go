@@ -0,0 +1,188 @@
+package allocator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/n-th/gymshark/internal/invalidation"
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+var ErrInvalidProfileDefaults = invalid("invalid profile defaults")
+
+// ProfileDefaults are the calculation settings of a profile's requests that
+// do not set them, so that product lines get their own optimization without
+// callers passing parameters. They are kept in storage; see
+// SetProfileDefaults.
+type ProfileDefaults struct {
+	// Strategy names the strategy of requests that name none, instead of
+	// the allocator's.
+	Strategy string
+	// Weights apply to requests that name no strategy and set no weights.
+	// Like weighted requests, they are solved with the branch-and-bound
+	// strategy, so Strategy must be empty.
+	Weights *Weights
+	// Timeout replaces the soft timeout of the profile's calculations; see
+	// SetTimeouts. The hard timeout still applies.
+	Timeout time.Duration
+	// UpdatedAt is when the defaults were set.
+	UpdatedAt time.Time
+}
+
+// IsZero reports whether d changes nothing.
+func (d ProfileDefaults) IsZero() bool {
+	return d.Strategy == "" && d.Weights == nil && d.Timeout == 0
+}
+
+// Validate requires a registered strategy, valid weights without a strategy
+// and a non-negative timeout.
+func (d ProfileDefaults) Validate() error {
+	if d.Strategy != "" {
+		if _, err := LookupStrategy(d.Strategy); err != nil {
+			return err
+		}
+		if d.Weights != nil {
+			return fmt.Errorf("%w: weights are solved with %s, so no strategy can be set with them", ErrInvalidProfileDefaults, ConstrainedStrategy)
+		}
+	}
+	if d.Weights != nil {
+		if err := (&Constraints{Weights: d.Weights}).validate(); err != nil {
+			return err
+		}
+	}
+	if d.Timeout < 0 {
+		return fmt.Errorf("%w: timeout must not be negative", ErrInvalidProfileDefaults)
+	}
+	return nil
+}
+
+// softTimeout returns the soft timeout of calculations with d, given the
+// allocator's.
+func (d ProfileDefaults) softTimeout(soft time.Duration) time.Duration {
+	if d.Timeout > 0 {
+		return d.Timeout
+	}
+	return soft
+}
+
+// withWeights returns a copy of c weighted with the defaults' weights, or c
+// itself when the defaults have none or c has its own. c may be nil.
+func (d ProfileDefaults) withWeights(c *Constraints) *Constraints {
+	if d.Weights == nil || c != nil && c.Weights != nil {
+		return c
+	}
+	var weighted Constraints
+	if c != nil {
+		weighted = *c
+	}
+	weighted.Weights = d.Weights
+	return &weighted
+}
+
+// ProfileDefaults returns the calculation defaults of the named profile
+// ("default" for the allocator's own sizes), zero if it has none.
+func (a *Allocator) ProfileDefaults(name string) ProfileDefaults {
+	return a.config().profileDefaults(name)
+}
+
+// LoadProfileDefaults reads the profiles' defaults from storage, replacing
+// those held in memory. It is called at startup and whenever another
+// replica changes them. Defaults of profiles that no longer exist are kept
+// but unused.
+func (a *Allocator) LoadProfileDefaults() error {
+	if a.storage == nil {
+		return ErrStorageNotConfigured
+	}
+	stored, err := a.storage.GetProfileDefaults()
+	if err != nil {
+		return fmt.Errorf("load profile defaults: %w", err)
+	}
+	defaults := make(map[string]ProfileDefaults, len(stored))
+	for _, d := range stored {
+		defaults[d.Profile] = ProfileDefaults{
+			Strategy:  d.Strategy,
+			Weights:   (*Weights)(d.Weights),
+			Timeout:   d.Timeout,
+			UpdatedAt: d.UpdatedAt,
+		}
+	}
+	return a.updateConfig(func(next *snapshot) error {
+		next.defaults = defaults
+		return nil
+	})
+}
+
+// SetProfileDefaults stores the calculation defaults of the named profile
+// ("default" for the allocator's own sizes) and applies them to the
+// calculations that start afterwards; zero defaults remove them. The change
+// is published to the other replicas when an invalidation bus is configured.
+// It fails with ErrReadOnly while the allocator is read-only.
+func (a *Allocator) SetProfileDefaults(ctx context.Context, name string, d ProfileDefaults) (ProfileDefaults, error) {
+	if a.storage == nil {
+		return ProfileDefaults{}, ErrStorageNotConfigured
+	}
+	if a.ReadOnly().Enabled {
+		return ProfileDefaults{}, ErrReadOnly
+	}
+	if name == "" {
+		name = DefaultProfile
+	}
+	if name == WeightProfile {
+		return ProfileDefaults{}, fmt.Errorf("%w: weight calculations have no defaults", ErrInvalidProfileDefaults)
+	}
+	if _, err := a.profileSizes(name); err != nil {
+		return ProfileDefaults{}, err
+	}
+	if err := d.Validate(); err != nil {
+		return ProfileDefaults{}, err
+	}
+
+	d.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	if d.IsZero() {
+		d = ProfileDefaults{}
+		if _, err := a.storage.DeleteProfileDefaults(name); err != nil {
+			return ProfileDefaults{}, fmt.Errorf("remove profile defaults: %w", err)
+		}
+	} else {
+		err := a.storage.SetProfileDefaults(storage.ProfileDefaults{
+			Profile:   name,
+			Strategy:  d.Strategy,
+			Weights:   (*storage.Weights)(d.Weights),
+			Timeout:   d.Timeout,
+			UpdatedAt: d.UpdatedAt,
+		})
+		if err != nil {
+			return ProfileDefaults{}, fmt.Errorf("store profile defaults: %w", err)
+		}
+	}
+
+	err := a.updateConfig(func(next *snapshot) error {
+		defaults := make(map[string]ProfileDefaults, len(next.defaults)+1)
+		for n, v := range next.defaults {
+			defaults[n] = v
+		}
+		if d.IsZero() {
+			delete(defaults, name)
+		} else {
+			defaults[name] = d
+		}
+		next.defaults = defaults
+		return nil
+	})
+	if err != nil {
+		return ProfileDefaults{}, err
+	}
+	return d, a.publish(ctx, invalidation.Event{Type: invalidation.EventProfileDefaults})
+}
+
+// reloadProfileDefaults reloads the defaults after a change by another
+// replica.
+func (a *Allocator) reloadProfileDefaults(origin string) {
+	if err := a.LoadProfileDefaults(); err != nil {
+		log.Printf("Failed to reload profile defaults changed by replica %s: %v", origin, err)
+		return
+	}
+	log.Printf("Profile defaults reloaded after a change by replica %s", origin)
+}
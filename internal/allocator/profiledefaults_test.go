@@ -0,0 +1,95 @@
+package allocator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileDefaults(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStorage()
+	a := NewAllocator([]int{250, 500, 1000}, store)
+	assert.NoError(t, a.SetProfiles(map[string][]int{"apparel": {250, 500, 1000}}, nil))
+
+	// A pack weighs as much as 300 items of waste, so 750 ships as one pack.
+	weighted, err := a.SetProfileDefaults(ctx, "apparel", ProfileDefaults{Weights: &Weights{Waste: 1, Packs: 300}})
+	assert.NoError(t, err)
+	assert.False(t, weighted.UpdatedAt.IsZero())
+	_, err = a.SetProfileDefaults(ctx, "", ProfileDefaults{Strategy: "greedy", Timeout: time.Second})
+	assert.NoError(t, err)
+
+	result, err := a.Allocate(ctx, Request{Quantity: 750, Profile: "apparel"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{1000: 1}, result.Packs)
+	assert.Equal(t, ConstrainedStrategy, result.Stats.Strategy)
+	// Naming a strategy opts out of the profile's weights.
+	result, err = a.Allocate(ctx, Request{Quantity: 750, Profile: "apparel", Strategy: "dp"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{500: 1, 250: 1}, result.Packs)
+
+	result, err = a.Allocate(ctx, Request{Quantity: 750})
+	assert.NoError(t, err)
+	assert.Equal(t, "greedy", result.Stats.Strategy)
+	result, err = a.Allocate(ctx, Request{Quantity: 750, Strategy: "dp"})
+	assert.NoError(t, err)
+	assert.Equal(t, "dp", result.Stats.Strategy)
+
+	// The timeout replaces the soft timeout of the profile only.
+	a.SetTimeouts(5*time.Second, 0)
+	cfg := a.config()
+	assert.Equal(t, time.Second, a.plan("", 750, []int{1000, 500, 250}, cfg.profileDefaults(DefaultProfile)).deadline)
+	assert.Equal(t, 5*time.Second, a.plan("", 750, []int{1000, 500, 250}, cfg.profileDefaults("apparel")).deadline)
+
+	profiles := a.Profiles()
+	if assert.Len(t, profiles, 2) {
+		assert.Equal(t, "greedy", profiles[0].Defaults.Strategy)
+		assert.Equal(t, &Weights{Waste: 1, Packs: 300}, profiles[1].Defaults.Weights)
+	}
+
+	// Another allocator on the same storage loads them.
+	b := NewAllocator([]int{250, 500, 1000}, store)
+	assert.NoError(t, b.LoadProfileDefaults())
+	assert.Equal(t, "greedy", b.Profiles()[0].Defaults.Strategy)
+
+	// Zero defaults remove them.
+	_, err = a.SetProfileDefaults(ctx, DefaultProfile, ProfileDefaults{})
+	assert.NoError(t, err)
+	assert.Len(t, store.defaults, 1)
+	result, err = a.Allocate(ctx, Request{Quantity: 750})
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultStrategy, result.Stats.Strategy)
+}
+
+func TestProfileDefaultsValidation(t *testing.T) {
+	ctx := context.Background()
+	a := NewAllocator([]int{250, 500, 1000}, newMockStorage())
+
+	tests := []struct {
+		name     string
+		profile  string
+		defaults ProfileDefaults
+		err      error
+	}{
+		{"unknown strategy", "", ProfileDefaults{Strategy: "magic"}, ErrUnknownStrategy},
+		{"strategy and weights", "", ProfileDefaults{Strategy: "dp", Weights: &Weights{Packs: 1}}, ErrInvalidProfileDefaults},
+		{"zero weights", "", ProfileDefaults{Weights: &Weights{}}, ErrInvalidConstraints},
+		{"negative timeout", "", ProfileDefaults{Timeout: -time.Second}, ErrInvalidProfileDefaults},
+		{"unknown profile", "apparel", ProfileDefaults{Strategy: "dp"}, ErrUnknownProfile},
+		{"weight profile", WeightProfile, ProfileDefaults{Strategy: "dp"}, ErrInvalidProfileDefaults},
+	}
+	for _, tt := range tests {
+		_, err := a.SetProfileDefaults(ctx, tt.profile, tt.defaults)
+		assert.ErrorIs(t, err, tt.err, tt.name)
+		assert.ErrorIs(t, err, ErrInvalidRequest, tt.name)
+	}
+
+	assert.NoError(t, a.SetReadOnly(ReadOnly{Enabled: true}))
+	_, err := a.SetProfileDefaults(ctx, "", ProfileDefaults{Strategy: "dp"})
+	assert.ErrorIs(t, err, ErrReadOnly)
+
+	_, err = NewAllocator([]int{250}, nil).SetProfileDefaults(ctx, "", ProfileDefaults{Strategy: "dp"})
+	assert.ErrorIs(t, err, ErrStorageNotConfigured)
+}
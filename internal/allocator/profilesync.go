@@ -0,0 +1,84 @@
+package allocator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// ProfileChange describes a profile changed by SyncProfiles.
+type ProfileChange struct {
+	Profile string
+	// Version is the recorded version of the new sizes, zero without
+	// storage.
+	Version int
+	// Added and Removed are the sizes the profile gained and lost, largest
+	// first. A new profile only gains sizes.
+	Added   []int
+	Removed []int
+}
+
+// SyncProfiles makes declared, pack sizes by profile name, the current pack
+// sizes of those profiles, as if each whose sizes differ were updated with
+// UpdateProfile, in name order. Profiles left out of declared are not
+// changed. Every declared profile is checked before any is changed, so that
+// an invalid declaration changes nothing; the error then lists all problems.
+// It returns the changes made, which are also returned, along with the
+// error, when publishing them to other replicas fails.
+// It fails with ErrReadOnly while the allocator is read-only, unless nothing
+// needs to change.
+func (a *Allocator) SyncProfiles(ctx context.Context, declared map[string][]int) ([]ProfileChange, error) {
+	names := make([]string, 0, len(declared))
+	for name := range declared {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if name == WeightProfile {
+			errs = append(errs, fmt.Errorf("profile %q is reserved for weight pack sizes", name))
+			continue
+		}
+		errs = append(errs, a.checkProfile(name, declared[name]))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	errs = nil
+	changes := []ProfileChange{}
+	for _, name := range names {
+		sizes := sortedSizes(declared[name])
+		current, err := a.config().sizes(name)
+		if err == nil && slices.Equal(current, sizes) {
+			continue
+		}
+		version, err := a.UpdateProfile(ctx, name, sizes)
+		if err != nil && !errors.Is(err, ErrNotPropagated) {
+			return changes, err
+		}
+		added, removed := sizeChanges(current, sizes)
+		changes = append(changes, ProfileChange{Profile: name, Version: version, Added: added, Removed: removed})
+		errs = append(errs, err)
+	}
+	return changes, errors.Join(errs...)
+}
+
+// sizeChanges returns the sizes in after but not before and those in before
+// but not after, largest first.
+func sizeChanges(before, after []int) (added, removed []int) {
+	for _, size := range sortedSizes(after) {
+		if !slices.Contains(before, size) {
+			added = append(added, size)
+		}
+	}
+	for _, size := range sortedSizes(before) {
+		if !slices.Contains(after, size) {
+			removed = append(removed, size)
+		}
+	}
+	return added, removed
+}
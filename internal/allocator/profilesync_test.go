@@ -0,0 +1,64 @@
+package allocator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/n-th/gymshark/internal/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncProfiles(t *testing.T) {
+	ctx := context.Background()
+	a := NewAllocator([]int{250, 500, 1000}, newMockStorage())
+	assert.NoError(t, a.RecordProfileVersions())
+	published := &eventRecorder{}
+	a.SetEventPublisher(published)
+
+	changes, err := a.SyncProfiles(ctx, map[string][]int{
+		DefaultProfile: {1000, 250, 500},
+		"apparel":      {10, 20, 30},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []ProfileChange{{Profile: "apparel", Version: 1, Added: []int{30, 20, 10}}}, changes)
+
+	// Unchanged profiles are left alone
+	changes, err = a.SyncProfiles(ctx, map[string][]int{"apparel": {30, 20, 10}})
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+
+	changes, err = a.SyncProfiles(ctx, map[string][]int{
+		DefaultProfile: {250, 500, 2000},
+		"apparel":      {10, 20, 40},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []ProfileChange{
+		{Profile: "apparel", Version: 2, Added: []int{40}, Removed: []int{30}},
+		{Profile: DefaultProfile, Version: 2, Added: []int{2000}, Removed: []int{1000}},
+	}, changes)
+	sizes, err := a.config().sizes("apparel")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{40, 20, 10}, sizes)
+
+	if assert.Len(t, published.events, 3) {
+		assert.Equal(t, &events.Profile{Name: "apparel", Version: 2, PackSizes: []int{40, 20, 10}, Added: []int{40}, Removed: []int{30}}, published.events[1].Profile)
+	}
+
+	// An invalid declaration changes nothing
+	_, err = a.SyncProfiles(ctx, map[string][]int{
+		"apparel":     {10, 20},
+		"bulk":        {0, 100},
+		WeightProfile: {1},
+	})
+	assert.ErrorContains(t, err, `profile "bulk"`)
+	assert.ErrorContains(t, err, `profile "weight" is reserved`)
+	sizes, _ = a.config().sizes("apparel")
+	assert.Equal(t, []int{40, 20, 10}, sizes)
+
+	// Read-only allocators only accept declarations they already match
+	assert.NoError(t, a.SetReadOnly(ReadOnly{Enabled: true, Mode: ReadOnlySkip}))
+	_, err = a.SyncProfiles(ctx, map[string][]int{"apparel": {10, 20, 40}})
+	assert.NoError(t, err)
+	_, err = a.SyncProfiles(ctx, map[string][]int{"apparel": {10, 20}})
+	assert.ErrorIs(t, err, ErrReadOnly)
+}
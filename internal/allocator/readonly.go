@@ -0,0 +1,63 @@
+package allocator
+
+import "errors"
+
+// ErrReadOnly is returned for requests that would write to storage while the
+// allocator is in read-only mode with rejection enabled.
+var ErrReadOnly = errors.New("service is in read-only mode")
+
+// ReadOnlyMode controls what happens to writes while read-only.
+type ReadOnlyMode string
+
+const (
+	// ReadOnlySkip serves calculations but does not store them.
+	ReadOnlySkip ReadOnlyMode = "skip"
+	// ReadOnlyReject fails calculations that would be stored with ErrReadOnly.
+	ReadOnlyReject ReadOnlyMode = "reject"
+)
+
+// ReadOnly is the allocator's maintenance state.
+type ReadOnly struct {
+	Enabled bool
+	Mode    ReadOnlyMode
+}
+
+// Validate checks that the mode is known. An empty mode means ReadOnlySkip.
+func (r ReadOnly) Validate() error {
+	switch r.Mode {
+	case "", ReadOnlySkip, ReadOnlyReject:
+		return nil
+	}
+	return invalidf("unknown read-only mode %q (want %q or %q)", r.Mode, ReadOnlySkip, ReadOnlyReject)
+}
+
+// SetReadOnly switches read-only mode on or off. It is safe to call while
+// requests are being served, e.g. from an admin endpoint during database
+// maintenance.
+func (a *Allocator) SetReadOnly(r ReadOnly) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	if r.Mode == "" {
+		r.Mode = ReadOnlySkip
+	}
+	a.readOnlyMu.Lock()
+	defer a.readOnlyMu.Unlock()
+	a.readOnly = r
+	return nil
+}
+
+// ReadOnly returns the current read-only state.
+func (a *Allocator) ReadOnly() ReadOnly {
+	a.readOnlyMu.RLock()
+	defer a.readOnlyMu.RUnlock()
+	return a.readOnly
+}
+
+// checkWritable returns ErrReadOnly if writes are currently rejected.
+func (a *Allocator) checkWritable() error {
+	if r := a.ReadOnly(); r.Enabled && r.Mode == ReadOnlyReject {
+		return ErrReadOnly
+	}
+	return nil
+}
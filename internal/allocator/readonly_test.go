@@ -0,0 +1,90 @@
+package allocator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/n-th/gymshark/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadOnly(t *testing.T) {
+	store := newMockStorage()
+	allocator := NewAllocator([]int{23, 31, 53}, store)
+	assert.Equal(t, ReadOnly{Mode: ReadOnlySkip}, allocator.ReadOnly())
+
+	// Skip: calculations succeed but are not stored
+	assert.NoError(t, allocator.SetReadOnly(ReadOnly{Enabled: true}))
+	assert.Equal(t, ReadOnlySkip, allocator.ReadOnly().Mode)
+	result, err := allocator.Allocate(context.Background(), Request{Quantity: 50})
+	assert.NoError(t, err)
+	assert.Equal(t, 53, result.Total)
+	assert.Empty(t, store.allocations)
+
+	_, err = allocator.Prune(storage.RetentionPolicy{MaxRows: 1})
+	assert.ErrorIs(t, err, ErrReadOnly)
+
+	// Reject: calculations fail, previews still work
+	assert.NoError(t, allocator.SetReadOnly(ReadOnly{Enabled: true, Mode: ReadOnlyReject}))
+	_, err = allocator.Allocate(context.Background(), Request{Quantity: 50})
+	assert.ErrorIs(t, err, ErrReadOnly)
+	_, err = allocator.AllocateOrder(context.Background(), OrderRequest{Lines: []OrderLine{{SKU: "A", Quantity: 50}}})
+	assert.ErrorIs(t, err, ErrReadOnly)
+	_, err = allocator.Preview(context.Background(), Request{Quantity: 50})
+	assert.NoError(t, err)
+
+	// Back to normal
+	assert.NoError(t, allocator.SetReadOnly(ReadOnly{}))
+	_, err = allocator.Allocate(context.Background(), Request{Quantity: 50})
+	assert.NoError(t, err)
+	assert.Len(t, store.allocations, 1)
+
+	assert.Error(t, allocator.SetReadOnly(ReadOnly{Enabled: true, Mode: "sometimes"}))
+}
+
+func TestRecordProfileVersionsReadOnly(t *testing.T) {
+	store := newMockStorage()
+	allocator := NewAllocator([]int{23, 31, 53}, store)
+	assert.NoError(t, allocator.RecordProfileVersions())
+
+	// A changed profile is not recorded while read-only; the latest stored version is used
+	allocator = NewAllocator([]int{23, 31, 53, 100}, store)
+	assert.NoError(t, allocator.SetReadOnly(ReadOnly{Enabled: true}))
+	assert.NoError(t, allocator.RecordProfileVersions())
+	versions, err := allocator.ProfileVersions(DefaultProfile)
+	assert.NoError(t, err)
+	assert.Len(t, versions, 1)
+	assert.Equal(t, 1, allocator.config().versions[DefaultProfile])
+}
+
+func TestRunPruner(t *testing.T) {
+	store := newMockStorage()
+	allocator := NewAllocator([]int{23, 31, 53}, store)
+	allocator.SetRetention(storage.RetentionPolicy{MaxRows: 1})
+	for _, q := range []int{10, 50} {
+		_, err := allocator.Allocate(context.Background(), Request{Quantity: q})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, allocator.SetReadOnly(ReadOnly{Enabled: true}))
+
+	runPruner := func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			allocator.RunPruner(ctx, 5*time.Millisecond)
+			close(done)
+		}()
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+		<-done
+	}
+
+	// Nothing is pruned while read-only
+	runPruner()
+	assert.Len(t, store.allocations, 2)
+
+	assert.NoError(t, allocator.SetReadOnly(ReadOnly{}))
+	runPruner()
+	assert.Len(t, store.allocations, 1)
+}
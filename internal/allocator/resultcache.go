@@ -0,0 +1,217 @@
+package allocator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+// SetResultCache makes requests for the default strategy without constraints
+// reuse the stored allocation for the same quantity, profile and profile
+// version instead of recomputing it. Only results stored under a recorded
+// profile version are reused, so RecordProfileVersions must have been called.
+// It must be called before the allocator is used concurrently.
+func (a *Allocator) SetResultCache(enabled bool) {
+	a.resultCache = enabled
+}
+
+// SetCache serves the result cache from c, e.g. a storage.RedisCache, instead
+// of the storage, so cache reads do not hit the allocation history. Computed
+// allocations are written to both. The allocator closes c on Close.
+// It must be called before the allocator is used concurrently.
+func (a *Allocator) SetCache(c storage.Cache) {
+	a.cache = c
+	a.ownCache = c != nil
+}
+
+// cacheAllocation caches a computed allocation, unless it is not cacheable.
+// Failures are logged, unless the cache's circuit is open: the allocation is
+// recomputed on the next miss.
+func (a *Allocator) cacheAllocation(in storage.AllocationInput) {
+	if a.cache == nil || !in.Cacheable {
+		return
+	}
+	err := a.cache.CacheAllocation(storage.Allocation{
+		OrderQuantity:  in.Quantity,
+		Packs:          in.Packs,
+		Total:          in.Total,
+		Profile:        in.Profile,
+		ProfileVersion: in.ProfileVersion,
+		Algorithm:      in.Algorithm,
+		Approximate:    in.Approximate,
+		Cacheable:      in.Cacheable,
+		CreatedAt:      in.CreatedAt,
+	})
+	if err != nil && !errors.Is(err, storage.ErrCircuitOpen) {
+		log.Printf("Failed to cache allocation for quantity %d: %v", in.Quantity, err)
+	}
+}
+
+// storedResult returns the stored allocation for quantity if it was computed
+// with the pack sizes of profile in cfg, without constraints, by the default
+// strategy or by Precompute. Pinned allocations are not reused, so they stop
+// being served once unpinned.
+func (a *Allocator) storedResult(cfg *snapshot, quantity int, profile string) (Result, bool) {
+	if a.cache == nil {
+		return Result{}, false
+	}
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	version := cfg.version(profile)
+	if version == 0 {
+		return Result{}, false
+	}
+	stored, err := a.cache.GetCachedAllocation(profile, quantity)
+	if err != nil {
+		// An open circuit was logged when it opened.
+		if !errors.Is(err, storage.ErrCircuitOpen) {
+			log.Printf("Failed to read cached allocation for quantity %d: %v", quantity, err)
+		}
+		return Result{}, false
+	}
+	// Allocations that fell short of their quantity were limited by stock,
+	// and approximate ones were not proven optimal. Those of another
+	// strategy are skipped too, e.g. after the default is changed, except
+	// the exact ones Precompute stores.
+	if stored == nil || !stored.Cacheable || stored.Algorithm != a.strategy && stored.Algorithm != ExactStrategy ||
+		stored.ProfileVersion != version || stored.Source != "" || stored.Total < quantity || stored.Approximate {
+		return Result{}, false
+	}
+	return Result{Packs: stored.Packs, Total: stored.Total, ComputedAt: stored.CreatedAt, Stats: Stats{Cache: CacheHit}}, true
+}
+
+// cacheable reports whether the result cache may serve c later: an exact
+// result computed, not served from the cache or a pin, by the default
+// strategy without constraints, profile weights or pack limits, which
+// bypass the cache.
+func (a *Allocator) cacheable(c computed) bool {
+	r := c.result
+	if r.Stats.Cache != CacheMiss && r.Stats.Cache != CacheShared {
+		return false
+	}
+	return r.Stats.Strategy == a.strategy && r.Source == "" && !r.Approximate &&
+		r.Shortfall == 0 && c.req.Constraints.empty() && c.req.AsOf.IsZero()
+}
+
+// ResultVersion identifies the result an unconstrained calculation of
+// quantity with profile returns, e.g. for HTTP ETags. It changes when the
+// profile's pack sizes, deprecated sizes or pack limits are updated, when the
+// allocation rules differ, and when the quantity is pinned, repinned or
+// unpinned. It returns false when the profile has no recorded version, since
+// pack-size changes are then not tracked.
+func (a *Allocator) ResultVersion(quantity int, profile string) (string, bool) {
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	cfg := a.config()
+	version := strconv.Itoa(cfg.version(profile))
+	if version == "0" {
+		return "", false
+	}
+	// Maps and structs are printed in key and field order, so equal
+	// settings hash equally.
+	deprecated, limits := cfg.deprecated(profile), cfg.limits(profile)
+	if len(deprecated) > 0 || len(limits) > 0 || len(a.rules) > 0 {
+		h := fnv.New32a()
+		fmt.Fprintf(h, "%v|%v|%v", deprecated, limits, a.rules)
+		version += fmt.Sprintf("-cfg%08x", h.Sum32())
+	}
+	a.pinsMu.RLock()
+	p, pinned := a.pins[pinKey{profile, quantity}]
+	a.pinsMu.RUnlock()
+	if !pinned {
+		return version, true
+	}
+	h := fnv.New32a()
+	fmt.Fprint(h, p.Packs)
+	return fmt.Sprintf("%s-pin%08x", version, h.Sum32()), true
+}
+
+// Warm computes and stores the allocation for quantity with the pack sizes
+// of profile and the default strategy, so later requests are served from the
+// result cache. It does nothing if a current allocation is already stored
+// or the quantity is pinned, and reports whether it computed one.
+func (a *Allocator) Warm(ctx context.Context, quantity int, profile string) (bool, error) {
+	if a.storage == nil {
+		return false, ErrStorageNotConfigured
+	}
+	if a.ReadOnly().Enabled {
+		return false, ErrReadOnly
+	}
+	cfg := a.config()
+	if _, ok := a.storedResult(cfg, quantity, profile); ok {
+		return false, nil
+	}
+	if _, ok := a.pinned(quantity, profile); ok {
+		return false, nil
+	}
+	req := Request{Quantity: quantity, Profile: profile}
+	result, err := a.preview(ctx, cfg, req)
+	if err != nil {
+		return false, err
+	}
+	a.store(computed{req, result, cfg.version(profile)})
+	return true, nil
+}
+
+// WarmResult reports what WarmTop computed.
+type WarmResult struct {
+	// Warmed counts allocations computed and stored.
+	Warmed int `json:"warmed"`
+	// Skipped counts quantities already stored or pinned, and those of
+	// profiles no longer configured.
+	Skipped int `json:"skipped"`
+	// Failed counts quantities that could not be computed.
+	Failed int `json:"failed"`
+}
+
+// WarmTop warms the result cache (see Warm) with the n quantities requested
+// most often since since, most requested first, so they are not computed on
+// the first request after a deploy that changed pack sizes. Quantities of
+// profiles no longer configured are skipped; ones that fail to compute are
+// logged and skipped. It stops early when ctx is done.
+func (a *Allocator) WarmTop(ctx context.Context, n int, since time.Time) (WarmResult, error) {
+	start := time.Now()
+	var result WarmResult
+	if a.storage == nil {
+		return result, ErrStorageNotConfigured
+	}
+	if a.ReadOnly().Enabled {
+		return result, ErrReadOnly
+	}
+	top, err := a.storage.TopQuantities(since, n)
+	if err != nil {
+		return result, fmt.Errorf("read most requested quantities: %w", err)
+	}
+	for _, q := range top {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if _, err := a.config().sizes(q.Profile); err != nil || q.Profile == WeightProfile {
+			result.Skipped++
+			continue
+		}
+		warmed, err := a.Warm(ctx, q.Quantity, q.Profile)
+		switch {
+		case ctx.Err() != nil:
+			return result, ctx.Err()
+		case err != nil:
+			log.Printf("Failed to warm allocation for quantity %d of profile %q: %v", q.Quantity, q.Profile, err)
+			result.Failed++
+		case warmed:
+			result.Warmed++
+		default:
+			result.Skipped++
+		}
+	}
+	log.Printf("Warmed %d of the %d most requested quantities in %s (%d skipped, %d failed)",
+		result.Warmed, len(top), time.Since(start), result.Skipped, result.Failed)
+	return result, nil
+}
@@ -0,0 +1,331 @@
+package allocator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+// mapCache is a storage.Cache kept in memory.
+type mapCache struct {
+	entries map[string]storage.Allocation
+	closed  bool
+}
+
+func (c *mapCache) GetCachedAllocation(profile string, quantity int) (*storage.Allocation, error) {
+	a, ok := c.entries[fmt.Sprintf("%s:%d", profile, quantity)]
+	if !ok {
+		return nil, nil
+	}
+	return &a, nil
+}
+
+func (c *mapCache) CacheAllocation(a storage.Allocation) error {
+	c.entries[fmt.Sprintf("%s:%d", a.Profile, a.OrderQuantity)] = a
+	return nil
+}
+
+func (c *mapCache) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestResultCache(t *testing.T) {
+	store := newMockStorage()
+	a := NewAllocator([]int{250, 500, 1000}, store)
+	assert.NoError(t, a.SetProfiles(map[string][]int{"apparel": {10, 20}}, nil))
+	assert.NoError(t, a.RecordProfileVersions())
+	ctx := context.Background()
+
+	// Disabled by default
+	result, err := a.Allocate(ctx, Request{Quantity: 501})
+	assert.NoError(t, err)
+	assert.Equal(t, CacheMiss, result.Stats.Cache)
+	result, err = a.Allocate(ctx, Request{Quantity: 501})
+	assert.NoError(t, err)
+	assert.Equal(t, CacheMiss, result.Stats.Cache)
+
+	a.SetResultCache(true)
+	result, err = a.Allocate(ctx, Request{Quantity: 501})
+	assert.NoError(t, err)
+	assert.Equal(t, CacheHit, result.Stats.Cache)
+	assert.Equal(t, DefaultStrategy, result.Stats.Strategy)
+	assert.Equal(t, map[int]int{500: 1, 250: 1}, result.Packs)
+	assert.Equal(t, 750, result.Total)
+
+	// Another strategy, another profile or constraints are computed
+	result, err = a.Allocate(ctx, Request{Quantity: 501, Strategy: "backtracking"})
+	assert.NoError(t, err)
+	assert.Equal(t, CacheMiss, result.Stats.Cache)
+	result, err = a.Allocate(ctx, Request{Quantity: 501, Profile: "apparel"})
+	assert.NoError(t, err)
+	assert.Equal(t, CacheMiss, result.Stats.Cache)
+	result, err = a.Allocate(ctx, Request{Quantity: 501, Constraints: &Constraints{MaxPacks: 5}})
+	assert.NoError(t, err)
+	assert.Equal(t, CacheBypass, result.Stats.Cache)
+
+	// New pack sizes make stored results stale
+	_, err = a.UpdateProfile(ctx, DefaultProfile, []int{23, 31, 53})
+	assert.NoError(t, err)
+	result, err = a.Allocate(ctx, Request{Quantity: 501})
+	assert.NoError(t, err)
+	assert.Equal(t, CacheMiss, result.Stats.Cache)
+	assert.Equal(t, 501, result.Total)
+}
+
+func TestResultCacheSkipsApproximate(t *testing.T) {
+	RegisterStrategy("blocking", blockingStrategy)
+
+	a := NewAllocator([]int{23, 31, 53}, newMockStorage())
+	assert.NoError(t, a.SetStrategy("blocking"))
+	assert.NoError(t, a.RecordProfileVersions())
+	a.SetResultCache(true)
+	a.SetTimeouts(10*time.Millisecond, time.Second)
+	ctx := context.Background()
+
+	// Greedy fallbacks are not proven optimal, so they are recomputed
+	for i := 0; i < 2; i++ {
+		result, err := a.Allocate(ctx, Request{Quantity: 263})
+		assert.NoError(t, err)
+		assert.True(t, result.Approximate)
+		assert.Equal(t, CacheMiss, result.Stats.Cache)
+	}
+}
+
+func TestResultCacheSkipsOtherStrategies(t *testing.T) {
+	for name, stores := range map[string]func(*mockStorage) storage.Cache{
+		"history": func(s *mockStorage) storage.Cache { return nil },
+		"cache":   func(*mockStorage) storage.Cache { return &mapCache{entries: make(map[string]storage.Allocation)} },
+	} {
+		store := newMockStorage()
+		a := NewAllocator([]int{250, 500, 1000}, store)
+		if cache := stores(store); cache != nil {
+			a.SetCache(cache)
+		}
+		assert.NoError(t, a.RecordProfileVersions())
+		a.SetResultCache(true)
+		ctx := context.Background()
+
+		// Constrained, weighted and explicit-strategy results are not
+		// served to plain requests
+		for _, req := range []Request{
+			{Quantity: 1000, Constraints: &Constraints{MaxCounts: map[int]int{1000: 0, 500: 0}}},
+			{Quantity: 1001, Strategy: "greedy"},
+			{Quantity: 1600, Constraints: &Constraints{Weights: &Weights{Packs: 1}}},
+		} {
+			_, err := a.Allocate(ctx, req)
+			assert.NoError(t, err)
+			result, err := a.Allocate(ctx, Request{Quantity: req.Quantity})
+			assert.NoError(t, err)
+			assert.Equal(t, CacheMiss, result.Stats.Cache, "%s: %d", name, req.Quantity)
+		}
+		result, err := a.Allocate(ctx, Request{Quantity: 1600})
+		assert.NoError(t, err)
+		assert.Equal(t, CacheHit, result.Stats.Cache, name)
+		assert.Equal(t, 1750, result.Total, name)
+	}
+}
+
+func TestSeparateCache(t *testing.T) {
+	store := newMockStorage()
+	cache := &mapCache{entries: make(map[string]storage.Allocation)}
+	a := NewAllocator([]int{250, 500, 1000}, store)
+	a.SetCache(cache)
+	assert.NoError(t, a.RecordProfileVersions())
+	a.SetResultCache(true)
+	ctx := context.Background()
+
+	// Computed allocations are written to the history and the cache
+	result, err := a.Allocate(ctx, Request{Quantity: 501})
+	assert.NoError(t, err)
+	assert.Equal(t, CacheMiss, result.Stats.Cache)
+	assert.NotNil(t, store.allocations[501])
+	assert.Contains(t, cache.entries, "default:501")
+
+	// Hits are served from the cache alone
+	delete(store.allocations, 501)
+	result, err = a.Allocate(ctx, Request{Quantity: 501})
+	assert.NoError(t, err)
+	assert.Equal(t, CacheHit, result.Stats.Cache)
+	assert.Equal(t, 750, result.Total)
+
+	// Allocations only in the history are not
+	delete(cache.entries, "default:501")
+	store.allocations[501] = &storage.Allocation{OrderQuantity: 501, Packs: map[int]int{1000: 1}, Total: 1000, Profile: DefaultProfile, ProfileVersion: 1}
+	result, err = a.Allocate(ctx, Request{Quantity: 501})
+	assert.NoError(t, err)
+	assert.Equal(t, CacheMiss, result.Stats.Cache)
+	assert.Equal(t, 750, result.Total)
+
+	assert.NoError(t, a.Close())
+	assert.True(t, cache.closed)
+}
+
+func TestComputedAt(t *testing.T) {
+	cache := &mapCache{entries: make(map[string]storage.Allocation)}
+	a := NewAllocator([]int{250, 500, 1000}, newMockStorage())
+	a.SetCache(cache)
+	assert.NoError(t, a.RecordProfileVersions())
+	a.SetResultCache(true)
+	ctx := context.Background()
+
+	before := time.Now()
+	result, err := a.Allocate(ctx, Request{Quantity: 501})
+	assert.NoError(t, err)
+	assert.Equal(t, CacheMiss, result.Stats.Cache)
+	assert.False(t, result.ComputedAt.Before(before))
+	computedAt := cache.entries["default:501"].CreatedAt
+
+	// Hits report when the stored allocation was computed, and are not
+	// cached again, so later hits do too
+	result, err = a.Allocate(ctx, Request{Quantity: 501})
+	assert.NoError(t, err)
+	assert.Equal(t, CacheHit, result.Stats.Cache)
+	assert.Equal(t, computedAt, result.ComputedAt)
+	assert.Equal(t, computedAt, cache.entries["default:501"].CreatedAt)
+
+	// Pins report when they were pinned
+	pin, err := a.Pin(ctx, storage.Pin{Quantity: 600, Packs: map[int]int{250: 3}})
+	assert.NoError(t, err)
+	result, err = a.Allocate(ctx, Request{Quantity: 600})
+	assert.NoError(t, err)
+	assert.Equal(t, pin.CreatedAt, result.ComputedAt)
+}
+
+func TestWarm(t *testing.T) {
+	store := newMockStorage()
+	a := NewAllocator([]int{250, 500, 1000}, store)
+	assert.NoError(t, a.SetProfiles(map[string][]int{"apparel": {10, 20}}, nil))
+	assert.NoError(t, a.RecordProfileVersions())
+	a.SetResultCache(true)
+	ctx := context.Background()
+
+	warmed, err := a.Warm(ctx, 1200, "")
+	assert.NoError(t, err)
+	assert.True(t, warmed)
+	if assert.Contains(t, store.allocations, 1200) {
+		assert.Equal(t, DefaultProfile, store.allocations[1200].Profile)
+		assert.Equal(t, 1, store.allocations[1200].ProfileVersion)
+	}
+
+	warmed, err = a.Warm(ctx, 1200, DefaultProfile)
+	assert.NoError(t, err)
+	assert.False(t, warmed)
+
+	result, err := a.Allocate(ctx, Request{Quantity: 1200})
+	assert.NoError(t, err)
+	assert.Equal(t, CacheHit, result.Stats.Cache)
+
+	warmed, err = a.Warm(ctx, 35, "apparel")
+	assert.NoError(t, err)
+	assert.True(t, warmed)
+
+	_, err = a.Warm(ctx, 35, "unknown")
+	assert.ErrorIs(t, err, ErrUnknownProfile)
+	_, err = a.Warm(ctx, 0, "")
+	assert.ErrorIs(t, err, ErrInvalidQuantity)
+
+	assert.NoError(t, a.SetReadOnly(ReadOnly{Enabled: true, Mode: ReadOnlySkip}))
+	_, err = a.Warm(ctx, 1300, "")
+	assert.ErrorIs(t, err, ErrReadOnly)
+
+	_, err = NewAllocator([]int{250}, nil).Warm(ctx, 250, "")
+	assert.ErrorIs(t, err, ErrStorageNotConfigured)
+}
+
+func TestWarmTop(t *testing.T) {
+	store := newMockStorage()
+	a := NewAllocator([]int{250, 500, 1000}, store)
+	assert.NoError(t, a.SetProfiles(map[string][]int{"apparel": {10, 20}}, nil))
+	assert.NoError(t, a.RecordProfileVersions())
+	a.SetResultCache(true)
+	ctx := context.Background()
+
+	// Allocations of pack sizes no longer in use
+	for _, in := range []storage.AllocationInput{
+		{Quantity: 1200, Packs: map[int]int{600: 2}, Total: 1200, Profile: DefaultProfile},
+		{Quantity: 35, Packs: map[int]int{35: 1}, Total: 35, Profile: "apparel"},
+		{Quantity: 40, Packs: map[int]int{40: 1}, Total: 40, Profile: "discontinued"},
+		{Quantity: 300, Packs: map[int]int{300: 1}, Total: 300, Profile: DefaultProfile},
+	} {
+		assert.NoError(t, store.StoreAllocationInput(in))
+	}
+	_, err := a.Pin(ctx, storage.Pin{Quantity: 300, Packs: map[int]int{500: 1}})
+	assert.NoError(t, err)
+
+	result, err := a.WarmTop(ctx, 10, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, WarmResult{Warmed: 2, Skipped: 2}, result)
+	assert.Equal(t, 1, store.allocations[1200].ProfileVersion)
+	assert.Equal(t, 1250, store.allocations[1200].Total)
+
+	result, err = a.WarmTop(ctx, 10, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, WarmResult{Skipped: 4}, result)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = a.WarmTop(canceled, 10, time.Time{})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	assert.NoError(t, a.SetReadOnly(ReadOnly{Enabled: true, Mode: ReadOnlySkip}))
+	_, err = a.WarmTop(ctx, 10, time.Time{})
+	assert.ErrorIs(t, err, ErrReadOnly)
+
+	_, err = NewAllocator([]int{250}, nil).WarmTop(ctx, 10, time.Time{})
+	assert.ErrorIs(t, err, ErrStorageNotConfigured)
+}
+
+func TestResultVersion(t *testing.T) {
+	a := NewAllocator([]int{250, 500, 1000}, newMockStorage())
+	ctx := context.Background()
+
+	_, ok := a.ResultVersion(500, "")
+	assert.False(t, ok)
+
+	assert.NoError(t, a.RecordProfileVersions())
+	version, ok := a.ResultVersion(500, "")
+	assert.True(t, ok)
+	assert.Equal(t, "1", version)
+
+	_, err := a.Pin(ctx, storage.Pin{Quantity: 500, Packs: map[int]int{250: 2}})
+	assert.NoError(t, err)
+	pinned, _ := a.ResultVersion(500, DefaultProfile)
+	assert.Regexp(t, `^1-pin[0-9a-f]{8}$`, pinned)
+	_, err = a.Pin(ctx, storage.Pin{Quantity: 500, Packs: map[int]int{500: 1}})
+	assert.NoError(t, err)
+	repinned, _ := a.ResultVersion(500, DefaultProfile)
+	assert.NotEqual(t, pinned, repinned)
+
+	_, err = a.UpdateProfile(ctx, DefaultProfile, []int{250, 500})
+	assert.NoError(t, err)
+	version, _ = a.ResultVersion(501, DefaultProfile)
+	assert.Equal(t, "2", version)
+
+	// Deprecated sizes, pack limits and allocation rules change results
+	// without a new profile version.
+	seen := map[string]bool{version: true}
+	for _, change := range []func() error{
+		func() error { return a.SetDeprecatedSizes(map[string][]int{DefaultProfile: {250}}) },
+		func() error { return a.SetPackLimits(map[string]map[int]int{DefaultProfile: {500: 3}}) },
+		func() error {
+			return a.SetAllocationRules([]AllocationRule{{Name: "waste", WasteRate: 0.1, Note: "check"}})
+		},
+	} {
+		assert.NoError(t, change())
+		version, _ = a.ResultVersion(501, DefaultProfile)
+		assert.Regexp(t, `^2-cfg[0-9a-f]{8}$`, version)
+		assert.False(t, seen[version])
+		seen[version] = true
+	}
+	assert.NoError(t, a.SetDeprecatedSizes(nil))
+	assert.NoError(t, a.SetPackLimits(nil))
+	assert.NoError(t, a.SetAllocationRules(nil))
+	version, _ = a.ResultVersion(501, DefaultProfile)
+	assert.Equal(t, "2", version)
+}
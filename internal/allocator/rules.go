@@ -0,0 +1,131 @@
+package allocator
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// AllocationRule is a business rule post-processing allocations: when all
+// of its conditions hold for a calculation, its note and flags are attached
+// to the result, returned and stored with it. Zero conditions are not
+// checked, and a rule without any holds for every allocation.
+type AllocationRule struct {
+	// Name identifies the rule in results.
+	Name string
+	// WasteRate holds when the waste exceeds this fraction of the quantity,
+	// e.g. 0.1 for 10%.
+	WasteRate float64
+	// ShortfallRate holds when the quantity left uncovered, by constrained
+	// requests with AllowShortfall, exceeds this fraction of it.
+	ShortfallRate float64
+	// BelowSmallest holds when the quantity is less than the smallest pack
+	// size, so that one pack is mostly waste.
+	BelowSmallest bool
+	// MinQuantity and MaxQuantity hold for quantities within them.
+	MinQuantity int
+	MaxQuantity int
+	// Approximate holds for approximate results.
+	Approximate bool
+	// Profiles holds for allocations of these profiles only.
+	Profiles []string
+
+	// Note is a message for whoever handles the allocation, and Flags are
+	// markers for systems acting on it, e.g. "requires_approval".
+	Note  string
+	Flags []string
+}
+
+// RuleMatch is an allocation rule that held for a result.
+type RuleMatch struct {
+	Rule  string
+	Note  string
+	Flags []string
+}
+
+// Validate requires a name, something to attach and sensible conditions.
+func (r AllocationRule) Validate() error {
+	if r.Name == "" {
+		return errors.New("allocation rule name is required")
+	}
+	if r.Note == "" && len(r.Flags) == 0 {
+		return fmt.Errorf("allocation rule %q: a note or flags are required", r.Name)
+	}
+	if slices.Contains(r.Flags, "") {
+		return fmt.Errorf("allocation rule %q: flags must not be empty", r.Name)
+	}
+	if r.WasteRate < 0 || r.ShortfallRate < 0 || r.MinQuantity < 0 || r.MaxQuantity < 0 {
+		return fmt.Errorf("allocation rule %q: thresholds must not be negative", r.Name)
+	}
+	if r.MaxQuantity > 0 && r.MinQuantity > r.MaxQuantity {
+		return fmt.Errorf("allocation rule %q: min quantity %d is above max quantity %d", r.Name, r.MinQuantity, r.MaxQuantity)
+	}
+	return nil
+}
+
+// holds reports whether every condition of r holds for result, computed for
+// quantity of profile with sizes sorted largest first.
+func (r AllocationRule) holds(profile string, quantity int, sizes []int, result Result) bool {
+	switch {
+	case r.WasteRate > 0 && float64(result.Total-quantity) <= r.WasteRate*float64(quantity):
+		return false
+	case r.ShortfallRate > 0 && float64(result.Shortfall) <= r.ShortfallRate*float64(quantity):
+		return false
+	case r.BelowSmallest && (len(sizes) == 0 || quantity >= sizes[len(sizes)-1]):
+		return false
+	case r.MinQuantity > 0 && quantity < r.MinQuantity, r.MaxQuantity > 0 && quantity > r.MaxQuantity:
+		return false
+	case r.Approximate && !result.Approximate:
+		return false
+	case len(r.Profiles) > 0 && !slices.Contains(r.Profiles, profile):
+		return false
+	}
+	return true
+}
+
+// ValidateAllocationRules validates every rule and requires unique names.
+func ValidateAllocationRules(rules []AllocationRule) error {
+	seen := make(map[string]bool, len(rules))
+	for _, r := range rules {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+		if seen[r.Name] {
+			return fmt.Errorf("allocation rule %q is defined twice", r.Name)
+		}
+		seen[r.Name] = true
+	}
+	return nil
+}
+
+// SetAllocationRules evaluates rules, in order, on every result Allocate,
+// AllocateOrder and Preview return; see Result.Rules. It must be called
+// before the allocator is used concurrently.
+func (a *Allocator) SetAllocationRules(rules []AllocationRule) error {
+	if err := ValidateAllocationRules(rules); err != nil {
+		return err
+	}
+	a.rules = slices.Clone(rules)
+	return nil
+}
+
+// applyRules returns the rules that hold for result, or nil.
+func (a *Allocator) applyRules(cfg *snapshot, req Request, result Result) []RuleMatch {
+	if len(a.rules) == 0 {
+		return nil
+	}
+	profile := req.Profile
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	// cfg has the sizes the result was computed with, past ones for
+	// previews as of a time.
+	sizes, _ := cfg.sizes(req.Profile)
+	var matches []RuleMatch
+	for _, r := range a.rules {
+		if r.holds(profile, req.Quantity, sizes, result) {
+			matches = append(matches, RuleMatch{Rule: r.Name, Note: r.Note, Flags: r.Flags})
+		}
+	}
+	return matches
+}
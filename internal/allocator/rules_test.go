@@ -0,0 +1,78 @@
+package allocator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllocationRules(t *testing.T) {
+	store := newMockStorage()
+	allocator := NewAllocator([]int{250, 500, 1000}, store)
+	assert.NoError(t, allocator.SetProfiles(map[string][]int{"bulk": {1000}}, nil))
+	assert.NoError(t, allocator.SetAllocationRules([]AllocationRule{
+		{Name: "high_waste", WasteRate: 0.1, Note: "Waste above 10%"},
+		{Name: "small", BelowSmallest: true, Flags: []string{"requires_approval"}},
+		{Name: "bulk_large", MinQuantity: 1500, Profiles: []string{"bulk"}, Flags: []string{"split_shipment"}},
+	}))
+
+	// 251 is shipped in a 500 pack: 249 waste
+	result, err := allocator.Allocate(context.Background(), Request{Quantity: 251})
+	assert.NoError(t, err)
+	assert.Equal(t, []RuleMatch{{Rule: "high_waste", Note: "Waste above 10%"}}, result.Rules)
+
+	result, err = allocator.Allocate(context.Background(), Request{Quantity: 100})
+	assert.NoError(t, err)
+	assert.Equal(t, []RuleMatch{
+		{Rule: "high_waste", Note: "Waste above 10%"},
+		{Rule: "small", Flags: []string{"requires_approval"}},
+	}, result.Rules)
+
+	result, err = allocator.Allocate(context.Background(), Request{Quantity: 750})
+	assert.NoError(t, err)
+	assert.Nil(t, result.Rules)
+
+	result, err = allocator.Allocate(context.Background(), Request{Quantity: 2000, Profile: "bulk"})
+	assert.NoError(t, err)
+	assert.Equal(t, []RuleMatch{{Rule: "bulk_large", Flags: []string{"split_shipment"}}}, result.Rules)
+	result, err = allocator.Allocate(context.Background(), Request{Quantity: 2000})
+	assert.NoError(t, err)
+	assert.Nil(t, result.Rules)
+
+	// Matches are stored with the allocation
+	stored, err := store.GetAllocationByQuantity(100)
+	assert.NoError(t, err)
+	if assert.NotNil(t, stored) {
+		assert.Len(t, stored.Rules, 2)
+	}
+}
+
+func TestAllocationRuleShortfall(t *testing.T) {
+	allocator := NewAllocator([]int{250, 500}, newMockStorage())
+	assert.NoError(t, allocator.SetAllocationRules([]AllocationRule{
+		{Name: "short", ShortfallRate: 0.25, Flags: []string{"backorder"}},
+	}))
+
+	result, err := allocator.Allocate(context.Background(), Request{
+		Quantity:    1000,
+		Constraints: &Constraints{MaxCounts: map[int]int{500: 1, 250: 0}, AllowShortfall: true},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 500, result.Shortfall)
+	assert.Equal(t, []RuleMatch{{Rule: "short", Flags: []string{"backorder"}}}, result.Rules)
+}
+
+func TestSetAllocationRulesInvalid(t *testing.T) {
+	allocator := NewAllocator([]int{250}, nil)
+	for name, rules := range map[string][]AllocationRule{
+		"no name":       {{Note: "x"}},
+		"no output":     {{Name: "a", WasteRate: 0.1}},
+		"empty flag":    {{Name: "a", Flags: []string{""}}},
+		"negative":      {{Name: "a", Note: "x", WasteRate: -1}},
+		"inverted":      {{Name: "a", Note: "x", MinQuantity: 10, MaxQuantity: 5}},
+		"defined twice": {{Name: "a", Note: "x"}, {Name: "a", Note: "y"}},
+	} {
+		assert.Error(t, allocator.SetAllocationRules(rules), name)
+	}
+}
@@ -0,0 +1,144 @@
+package allocator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// SelfTestCase is a quantity of a profile and the packs the configured
+// strategy is expected to allocate for it.
+type SelfTestCase struct {
+	// Profile is empty for the default pack sizes.
+	Profile  string
+	Quantity int
+	Packs    map[int]int
+}
+
+// SelfTestFailure is a case the allocator got wrong. Got is empty when the
+// calculation failed with Err.
+type SelfTestFailure struct {
+	Case   SelfTestCase
+	Got    Result
+	Err    error
+	Reason string
+}
+
+func (f SelfTestFailure) Error() string {
+	profile := f.Case.Profile
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	if f.Err != nil {
+		return fmt.Sprintf("profile %q, quantity %d: %v", profile, f.Case.Quantity, f.Err)
+	}
+	return fmt.Sprintf("profile %q, quantity %d: %s", profile, f.Case.Quantity, f.Reason)
+}
+
+// SelfTest calculates every case with the configured strategy and the
+// profile's current pack sizes, and reports those whose packs differ from the
+// expected ones. It also checks that the allocations of a built-in set of
+// edge quantities for every profile (1, each size and its neighbours, the sum
+// of the sizes) are well formed: made of the profile's sizes, adding up to
+// their total and covering the quantity. Pins, pack limits and the result
+// cache do not apply and nothing is stored, so it can run before serving.
+// The error is only set when ctx is done.
+func (a *Allocator) SelfTest(ctx context.Context, cases []SelfTestCase) ([]SelfTestFailure, error) {
+	cfg := a.config()
+	var failures []SelfTestFailure
+	for _, c := range cases {
+		sizes, err := cfg.sizes(c.Profile)
+		if err != nil {
+			failures = append(failures, SelfTestFailure{Case: c, Err: err})
+			continue
+		}
+		result, err := a.Evaluate(ctx, c.Quantity, sizes, "")
+		if err := ctx.Err(); err != nil {
+			return failures, err
+		}
+		switch {
+		case err != nil:
+			failures = append(failures, SelfTestFailure{Case: c, Err: err})
+		case !equalPacks(result.Packs, c.Packs):
+			failures = append(failures, SelfTestFailure{Case: c, Got: result,
+				Reason: fmt.Sprintf("got packs %v (total %d), want %v", result.Packs, result.Total, c.Packs)})
+		}
+	}
+
+	names := []string{DefaultProfile}
+	for name := range cfg.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	for _, name := range names {
+		sizes, _ := cfg.sizes(name)
+		if len(sizes) == 0 {
+			continue
+		}
+		for _, q := range edgeQuantities(sizes) {
+			c := SelfTestCase{Profile: name, Quantity: q}
+			result, err := a.Evaluate(ctx, q, sizes, "")
+			if err := ctx.Err(); err != nil {
+				return failures, err
+			}
+			var limit *LimitError
+			if errors.As(err, &limit) {
+				continue
+			}
+			if err != nil {
+				failures = append(failures, SelfTestFailure{Case: c, Err: err})
+				continue
+			}
+			if reason := malformed(q, sizes, result); reason != "" {
+				failures = append(failures, SelfTestFailure{Case: c, Got: result, Reason: reason})
+			}
+		}
+	}
+	return failures, nil
+}
+
+// edgeQuantities returns the quantities SelfTest checks for sizes.
+func edgeQuantities(sizes []int) []int {
+	seen := map[int]bool{}
+	var quantities []int
+	add := func(q int) {
+		if q > 0 && !seen[q] {
+			seen[q] = true
+			quantities = append(quantities, q)
+		}
+	}
+	add(1)
+	sum := 0
+	for _, size := range sizes {
+		add(size - 1)
+		add(size)
+		add(size + 1)
+		sum += size
+	}
+	add(sum)
+	sort.Ints(quantities)
+	return quantities
+}
+
+// malformed describes what is wrong with result as an allocation of
+// quantity in sizes, or returns "" if nothing is.
+func malformed(quantity int, sizes []int, result Result) string {
+	total := 0
+	for size, n := range result.Packs {
+		if !containsSize(sizes, size) {
+			return fmt.Sprintf("packs %v use size %d, not one of %v", result.Packs, size, sizes)
+		}
+		if n <= 0 {
+			return fmt.Sprintf("packs %v have %d packs of size %d", result.Packs, n, size)
+		}
+		total += size * n
+	}
+	if total != result.Total {
+		return fmt.Sprintf("packs %v add up to %d, not the reported total %d", result.Packs, total, result.Total)
+	}
+	if total < quantity {
+		return fmt.Sprintf("packs %v total %d, less than the quantity", result.Packs, total)
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package allocator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelfTest(t *testing.T) {
+	ctx := context.Background()
+	a := NewAllocator([]int{23, 31, 53}, newMockStorage())
+	assert.NoError(t, a.SetProfiles(map[string][]int{"apparel": {250, 500, 1000}}, nil))
+	assert.NoError(t, a.SetStrategy("dp"))
+
+	failures, err := a.SelfTest(ctx, []SelfTestCase{
+		{Quantity: 263, Packs: map[int]int{23: 2, 31: 7}},
+		{Quantity: 500000, Packs: map[int]int{23: 2, 31: 7, 53: 9429}},
+		{Profile: "apparel", Quantity: 501, Packs: map[int]int{500: 1, 250: 1}},
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, failures)
+
+	failures, err = a.SelfTest(ctx, []SelfTestCase{
+		// Expected for other pack sizes
+		{Quantity: 501, Packs: map[int]int{500: 1, 250: 1}},
+		{Profile: "missing", Quantity: 10, Packs: map[int]int{23: 1}},
+		{Quantity: 0, Packs: map[int]int{23: 1}},
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, failures, 3) {
+		assert.Contains(t, failures[0].Error(), `profile "default", quantity 501: got packs map[23:`)
+		assert.ErrorIs(t, failures[1].Err, ErrUnknownProfile)
+		assert.ErrorIs(t, failures[2].Err, ErrInvalidQuantity)
+	}
+
+	// A strategy returning malformed allocations fails the edge quantities
+	RegisterStrategy("selftest-short", StrategyFunc(func(_ context.Context, quantity int, sizes []int) (Result, error) {
+		return Result{Packs: map[int]int{sizes[0]: 1}, Total: sizes[0]}, nil
+	}))
+	assert.NoError(t, a.SetStrategy("selftest-short"))
+	failures, err = a.SelfTest(ctx, nil)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, failures)
+	for _, f := range failures {
+		assert.Greater(t, f.Case.Quantity, 53)
+		assert.Contains(t, f.Reason, "less than the quantity")
+	}
+
+	// Quantities above the limit are skipped
+	a.SetLimits(Limits{MaxQuantity: 10})
+	failures, err = a.SelfTest(ctx, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, failures)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = a.SelfTest(cancelled, []SelfTestCase{{Quantity: 10, Packs: map[int]int{53: 1}}})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestMalformed(t *testing.T) {
+	sizes := []int{53, 31, 23}
+	assert.Empty(t, malformed(50, sizes, Result{Packs: map[int]int{53: 1}, Total: 53}))
+	assert.Contains(t, malformed(50, sizes, Result{Packs: map[int]int{50: 1}, Total: 50}), "size 50")
+	assert.Contains(t, malformed(50, sizes, Result{Packs: map[int]int{53: 1, 23: 0}, Total: 53}), "0 packs")
+	assert.Contains(t, malformed(50, sizes, Result{Packs: map[int]int{53: 1}, Total: 60}), "reported total 60")
+	assert.Contains(t, malformed(60, sizes, Result{Packs: map[int]int{53: 1}, Total: 53}), "less than the quantity")
+}
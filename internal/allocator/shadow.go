@@ -0,0 +1,256 @@
+package allocator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Shadow runs a second strategy alongside the one answering requests, for a
+// sample of calculations, and records where their results differ, so that a
+// new strategy can be validated against production traffic before it
+// answers any request.
+type Shadow struct {
+	// Strategy names the strategy run in shadow.
+	Strategy string
+	// Rate is the fraction of calculations, from 0 to 1, also run in shadow.
+	Rate float64
+	// Timeout bounds each shadow calculation; zero uses the hard timeout.
+	Timeout time.Duration
+}
+
+// Shadow verdicts compare the shadow result with the one answered.
+const (
+	// VerdictBetter means the shadow result wastes less, or as much with
+	// fewer packs.
+	VerdictBetter = "better"
+	// VerdictWorse means the shadow result wastes more, or as much with
+	// more packs.
+	VerdictWorse = "worse"
+	// VerdictTie means the shadow result wastes as much with as many packs,
+	// only of other sizes.
+	VerdictTie = "tie"
+)
+
+// ShadowMismatch is a calculation the shadow strategy answered differently.
+type ShadowMismatch struct {
+	Time     time.Time
+	Profile  string
+	Quantity int
+	// Strategy, Packs, Total and Approximate describe the result answered,
+	// the Shadow fields the shadow strategy's.
+	Strategy          string
+	Packs             map[int]int
+	Total             int
+	Approximate       bool
+	ShadowStrategy    string
+	ShadowPacks       map[int]int
+	ShadowTotal       int
+	ShadowApproximate bool
+	Verdict           string
+}
+
+// ShadowStats counts shadow calculations since SetShadow.
+type ShadowStats struct {
+	Strategy string
+	Rate     float64
+	// Comparisons counts shadow calculations compared with the result
+	// answered, Mismatches those that differed. Errors counts shadow
+	// calculations that failed or timed out, and Skipped those not run
+	// because too many were already running.
+	Comparisons int64
+	Mismatches  int64
+	Errors      int64
+	Skipped     int64
+	// Recent holds the latest mismatches, newest first.
+	Recent []ShadowMismatch
+}
+
+// maxShadowInFlight bounds the shadow calculations running at once, so that
+// shadowing never takes more than a few cores from the requests.
+const maxShadowInFlight = 4
+
+// maxShadowMismatches is how many recent mismatches are kept.
+const maxShadowMismatches = 100
+
+// shadowRunner runs and compares shadow calculations.
+type shadowRunner struct {
+	config   Shadow
+	strategy AllocationStrategy
+	slots    chan struct{}
+	wg       sync.WaitGroup
+
+	comparisons atomic.Int64
+	mismatches  atomic.Int64
+	errors      atomic.Int64
+	skipped     atomic.Int64
+
+	mu     sync.Mutex
+	recent []ShadowMismatch
+}
+
+// SetShadow runs s.Strategy in shadow for a fraction s.Rate of the
+// calculations computed for Allocate and AllocateOrder with an unconstrained
+// strategy other than s.Strategy, once the response is ready. Results served
+// from a cache, pinned, shared with another request or calculated under
+// constraints or pack limits are not shadowed. Shadow calculations only
+// count towards ShadowStats: they are never stored or returned. A zero Rate
+// disables shadowing. It must be called before the allocator is used
+// concurrently.
+func (a *Allocator) SetShadow(s Shadow) error {
+	if s.Rate < 0 || s.Rate > 1 {
+		return fmt.Errorf("shadow rate %v must be between 0 and 1", s.Rate)
+	}
+	if s.Timeout < 0 {
+		return errors.New("shadow timeout must not be negative")
+	}
+	if s.Rate == 0 {
+		a.shadow = nil
+		return nil
+	}
+	strategy, err := LookupStrategy(s.Strategy)
+	if err != nil {
+		return fmt.Errorf("shadow: %w", err)
+	}
+	a.shadow = &shadowRunner{config: s, strategy: strategy, slots: make(chan struct{}, maxShadowInFlight)}
+	return nil
+}
+
+// ShadowStats returns the shadow comparisons so far, all zero when
+// shadowing is disabled.
+func (a *Allocator) ShadowStats() ShadowStats {
+	s := a.shadow
+	if s == nil {
+		return ShadowStats{}
+	}
+	s.mu.Lock()
+	recent := make([]ShadowMismatch, len(s.recent))
+	for i, m := range s.recent {
+		recent[len(recent)-1-i] = m
+	}
+	s.mu.Unlock()
+	return ShadowStats{
+		Strategy:    s.config.Strategy,
+		Rate:        s.config.Rate,
+		Comparisons: s.comparisons.Load(),
+		Mismatches:  s.mismatches.Load(),
+		Errors:      s.errors.Load(),
+		Skipped:     s.skipped.Load(),
+		Recent:      recent,
+	}
+}
+
+// runShadow starts the shadow calculation of req, answered with result, if
+// it is sampled.
+func (a *Allocator) runShadow(cfg *snapshot, req Request, result Result) {
+	s := a.shadow
+	if s == nil || result.Stats.Cache != CacheMiss || result.Stats.Strategy == s.config.Strategy {
+		return
+	}
+	if rand.Float64() >= s.config.Rate {
+		return
+	}
+	sizes, err := cfg.sizes(req.Profile)
+	if err != nil {
+		return
+	}
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		s.skipped.Add(1)
+		return
+	}
+
+	strategy := s.strategy
+	// Shadow results are compared with answers that avoid deprecated sizes.
+	if deprecated := cfg.deprecated(req.Profile); len(deprecated) > 0 && s.config.Strategy != FallbackStrategy {
+		strategy = avoidingDeprecated{strategy, deprecated}
+	}
+	timeout := s.config.Timeout
+	if timeout == 0 {
+		timeout = a.hardTimeout
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() { <-s.slots }()
+		ctx := context.Background()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		var shadow Result
+		err := checkOverflow(req.Quantity, sizes)
+		if err == nil {
+			shadow, err = strategy.Allocate(ctx, req.Quantity, sizes)
+		}
+		if err != nil {
+			s.errors.Add(1)
+			log.Printf("Shadow strategy %s failed for quantity %d: %v", s.config.Strategy, req.Quantity, err)
+			return
+		}
+		s.compare(req, result, shadow)
+	}()
+}
+
+// compare records whether shadow differs from result, the answer to req.
+func (s *shadowRunner) compare(req Request, result, shadow Result) {
+	s.comparisons.Add(1)
+	if maps.Equal(result.Packs, shadow.Packs) {
+		return
+	}
+	s.mismatches.Add(1)
+	profile := req.Profile
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	m := ShadowMismatch{
+		Time:              time.Now(),
+		Profile:           profile,
+		Quantity:          req.Quantity,
+		Strategy:          result.Stats.Strategy,
+		Packs:             result.Packs,
+		Total:             result.Total,
+		Approximate:       result.Approximate,
+		ShadowStrategy:    s.config.Strategy,
+		ShadowPacks:       shadow.Packs,
+		ShadowTotal:       shadow.Total,
+		ShadowApproximate: shadow.Approximate,
+		Verdict:           shadowVerdict(outcome(req.Quantity, result), outcome(req.Quantity, shadow)),
+	}
+	log.Printf("Shadow strategy %s mismatch for quantity %d of profile %s (%s): %v (total %d) with %s, %v (total %d) in shadow",
+		m.ShadowStrategy, m.Quantity, m.Profile, m.Verdict, m.Packs, m.Total, m.Strategy, m.ShadowPacks, m.ShadowTotal)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.recent) == maxShadowMismatches {
+		s.recent = append(s.recent[:0], s.recent[1:]...)
+	}
+	s.recent = append(s.recent, m)
+}
+
+// shadowVerdict compares a shadow outcome with the answered one, by waste
+// and then pack count.
+func shadowVerdict(answered, shadow SimulationOutcome) string {
+	switch {
+	case shadow.Waste < answered.Waste, shadow.Waste == answered.Waste && shadow.PackCount < answered.PackCount:
+		return VerdictBetter
+	case shadow.Waste > answered.Waste, shadow.PackCount > answered.PackCount:
+		return VerdictWorse
+	}
+	return VerdictTie
+}
+
+// waitShadow waits for the shadow calculations started so far.
+func (a *Allocator) waitShadow() {
+	if a.shadow != nil {
+		a.shadow.wg.Wait()
+	}
+}
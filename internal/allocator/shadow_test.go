@@ -0,0 +1,56 @@
+package allocator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShadow(t *testing.T) {
+	a := NewAllocator([]int{23, 31, 53}, nil)
+	assert.NoError(t, a.SetStrategy("dp"))
+	assert.Error(t, a.SetShadow(Shadow{Strategy: "greedy", Rate: 1.5}))
+	assert.Error(t, a.SetShadow(Shadow{Strategy: "unknown", Rate: 1}))
+	assert.NoError(t, a.SetShadow(Shadow{Strategy: "greedy", Rate: 1}))
+
+	_, err := a.Allocate(context.Background(), Request{Quantity: 62})
+	assert.NoError(t, err)
+	_, err = a.Allocate(context.Background(), Request{Quantity: 53})
+	assert.NoError(t, err)
+	// Calculations with the shadow strategy itself are not compared.
+	_, err = a.Allocate(context.Background(), Request{Quantity: 62, Strategy: "greedy"})
+	assert.NoError(t, err)
+	_, err = a.AllocateOrder(context.Background(), OrderRequest{Lines: []OrderLine{{SKU: "SKU-1", Quantity: 62}}})
+	assert.NoError(t, err)
+	a.waitShadow()
+
+	stats := a.ShadowStats()
+	assert.Equal(t, "greedy", stats.Strategy)
+	assert.Equal(t, int64(3), stats.Comparisons)
+	assert.Equal(t, int64(2), stats.Mismatches)
+	assert.Zero(t, stats.Errors)
+	if assert.Len(t, stats.Recent, 2) {
+		m := stats.Recent[0]
+		assert.Equal(t, DefaultProfile, m.Profile)
+		assert.Equal(t, 62, m.Quantity)
+		assert.Equal(t, "dp", m.Strategy)
+		assert.Equal(t, map[int]int{31: 2}, m.Packs)
+		assert.Equal(t, 62, m.Total)
+		assert.Equal(t, "greedy", m.ShadowStrategy)
+		assert.Equal(t, 76, m.ShadowTotal)
+		assert.Equal(t, VerdictWorse, m.Verdict)
+	}
+
+	assert.NoError(t, a.SetShadow(Shadow{}))
+	assert.Equal(t, ShadowStats{}, a.ShadowStats())
+}
+
+func TestShadowVerdict(t *testing.T) {
+	answered := SimulationOutcome{Waste: 2, PackCount: 3}
+	assert.Equal(t, VerdictBetter, shadowVerdict(answered, SimulationOutcome{Waste: 1, PackCount: 5}))
+	assert.Equal(t, VerdictBetter, shadowVerdict(answered, SimulationOutcome{Waste: 2, PackCount: 2}))
+	assert.Equal(t, VerdictWorse, shadowVerdict(answered, SimulationOutcome{Waste: 3, PackCount: 1}))
+	assert.Equal(t, VerdictWorse, shadowVerdict(answered, SimulationOutcome{Waste: 2, PackCount: 4}))
+	assert.Equal(t, VerdictTie, shadowVerdict(answered, SimulationOutcome{Waste: 2, PackCount: 3}))
+}
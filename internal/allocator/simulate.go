@@ -0,0 +1,188 @@
+package allocator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+var (
+	ErrInvalidPackSize = invalid("pack sizes must be positive")
+	ErrNoQuantities    = invalid("no quantities to simulate")
+)
+
+// SimulationOutcome is one pack-size set's allocation for a quantity.
+type SimulationOutcome struct {
+	Packs     map[int]int
+	Total     int
+	Waste     int
+	PackCount int
+}
+
+// SimulationRow compares both pack-size sets for a single quantity.
+type SimulationRow struct {
+	Quantity  int
+	Baseline  SimulationOutcome
+	Candidate SimulationOutcome
+}
+
+// SimulationTotals aggregates one pack-size set across all simulated quantities.
+type SimulationTotals struct {
+	Waste     int
+	Packs     int
+	AvgWaste  float64
+	AvgPacks  float64
+	ZeroWaste int
+}
+
+// Simulation is the side-by-side comparison of two pack-size sets.
+type Simulation struct {
+	BaselineSizes  []int
+	CandidateSizes []int
+	Rows           []SimulationRow
+	Baseline       SimulationTotals
+	Candidate      SimulationTotals
+}
+
+// Evaluate computes an allocation for arbitrary pack sizes without persisting it.
+// It is intended for what-if analysis and comparisons.
+func (a *Allocator) Evaluate(ctx context.Context, quantity int, sizes []int, strategyName string) (Result, error) {
+	if quantity <= 0 {
+		return Result{}, ErrInvalidQuantity
+	}
+	if err := a.checkQuantity(quantity); err != nil {
+		return Result{}, err
+	}
+	if err := a.validateSizesCached(sizes); err != nil {
+		return Result{}, err
+	}
+	if strategyName == "" {
+		strategyName = a.strategy
+	}
+	strategy, err := LookupStrategy(strategyName)
+	if err != nil {
+		return Result{}, err
+	}
+	return a.run(ctx, strategy, quantity, sortedSizes(sizes), a.softTimeout)
+}
+
+// Simulate allocates every quantity under both pack-size sets and compares
+// waste and pack counts. A nil baseline uses the allocator's default sizes.
+// Nothing is written to storage.
+func (a *Allocator) Simulate(ctx context.Context, quantities []int, baseline, candidate []int, strategyName string) (Simulation, error) {
+	if len(quantities) == 0 {
+		return Simulation{}, ErrNoQuantities
+	}
+	// Repeated quantities are only computed once, so only distinct ones count
+	// towards the batch limit.
+	distinct := make(map[int]struct{}, len(quantities))
+	for _, q := range quantities {
+		distinct[q] = struct{}{}
+	}
+	if err := a.checkBatchSize(len(distinct)); err != nil {
+		return Simulation{}, err
+	}
+	if baseline == nil {
+		baseline, _ = a.profileSizes(DefaultProfile)
+	}
+	return a.simulate(ctx, quantities, baseline, candidate, strategyName)
+}
+
+// simulate compares baseline and candidate for quantities, which must not be
+// empty, without checking the batch limit.
+func (a *Allocator) simulate(ctx context.Context, quantities []int, baseline, candidate []int, strategyName string) (Simulation, error) {
+	sim := Simulation{BaselineSizes: sortedSizes(baseline), CandidateSizes: sortedSizes(candidate)}
+	memo := make(map[int]SimulationRow)
+	for _, q := range quantities {
+		row, ok := memo[q]
+		if !ok {
+			b, err := a.Evaluate(ctx, q, baseline, strategyName)
+			if err != nil {
+				return Simulation{}, fmt.Errorf("baseline, quantity %d: %w", q, err)
+			}
+			c, err := a.Evaluate(ctx, q, candidate, strategyName)
+			if err != nil {
+				return Simulation{}, fmt.Errorf("candidate, quantity %d: %w", q, err)
+			}
+			row = SimulationRow{Quantity: q, Baseline: outcome(q, b), Candidate: outcome(q, c)}
+			memo[q] = row
+			sim.Rows = append(sim.Rows, row)
+		}
+		sim.Baseline.add(row.Baseline)
+		sim.Candidate.add(row.Candidate)
+	}
+
+	sim.Baseline.finish(len(quantities))
+	sim.Candidate.finish(len(quantities))
+	return sim, nil
+}
+
+// SimulateHistory replays the quantities of allocations stored in [from, to)
+// against both pack-size sets. Repeated quantities are weighted by how often
+// they were ordered.
+func (a *Allocator) SimulateHistory(ctx context.Context, from, to time.Time, baseline, candidate []int, strategyName string) (Simulation, error) {
+	if a.storage == nil {
+		return Simulation{}, ErrStorageNotConfigured
+	}
+
+	var quantities []int
+	err := a.storage.ExportAllocations(from, to, func(al storage.Allocation) error {
+		quantities = append(quantities, al.OrderQuantity)
+		return ctx.Err()
+	})
+	if err != nil {
+		return Simulation{}, err
+	}
+
+	return a.Simulate(ctx, quantities, baseline, candidate, strategyName)
+}
+
+func outcome(quantity int, r Result) SimulationOutcome {
+	count := 0
+	for _, n := range r.Packs {
+		count += n
+	}
+	return SimulationOutcome{Packs: r.Packs, Total: r.Total, Waste: r.Total - quantity, PackCount: count}
+}
+
+func (t *SimulationTotals) add(o SimulationOutcome) {
+	t.Waste += o.Waste
+	t.Packs += o.PackCount
+	if o.Waste == 0 {
+		t.ZeroWaste++
+	}
+}
+
+func (t *SimulationTotals) finish(n int) {
+	t.AvgWaste = float64(t.Waste) / float64(n)
+	t.AvgPacks = float64(t.Packs) / float64(n)
+}
+
+// validateSizesCached validates an ad-hoc pack-size set, remembering
+// failures in the negative cache when it is enabled.
+func (a *Allocator) validateSizesCached(sizes []int) error {
+	key := sizesKey(sizes)
+	if err, ok := a.negative.get(key); ok {
+		return err
+	}
+	err := validateSizes(sizes)
+	if err != nil {
+		a.negative.put(key, err)
+	}
+	return err
+}
+
+// validateSizes checks that a pack-size set is non-empty and positive.
+func validateSizes(sizes []int) error {
+	if len(sizes) == 0 {
+		return ErrNoPackSizes
+	}
+	for _, size := range sizes {
+		if size <= 0 {
+			return fmt.Errorf("%w: %d", ErrInvalidPackSize, size)
+		}
+	}
+	return nil
+}
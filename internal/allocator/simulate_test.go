@@ -0,0 +1,65 @@
+package allocator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulate(t *testing.T) {
+	storage := newMockStorage()
+	allocator := NewAllocator([]int{250, 500, 1000}, storage)
+	assert.NoError(t, allocator.SetStrategy("dp"))
+
+	sim, err := allocator.Simulate(context.Background(), []int{700, 700, 1000}, nil, []int{250, 500, 750, 1000}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1000, 500, 250}, sim.BaselineSizes)
+	assert.Equal(t, []int{1000, 750, 500, 250}, sim.CandidateSizes)
+
+	// Repeated quantities are computed once but weighted in the totals
+	assert.Len(t, sim.Rows, 2)
+	assert.Equal(t, 700, sim.Rows[0].Quantity)
+	assert.Equal(t, 50, sim.Rows[0].Baseline.Waste)
+	assert.Equal(t, 2, sim.Rows[0].Baseline.PackCount)
+	assert.Equal(t, 1, sim.Rows[0].Candidate.PackCount)
+
+	assert.Equal(t, 100, sim.Baseline.Waste)
+	assert.Equal(t, 5, sim.Baseline.Packs)
+	assert.Equal(t, 3, sim.Candidate.Packs)
+	assert.Equal(t, 1, sim.Candidate.ZeroWaste)
+	assert.InDelta(t, 33.33, sim.Baseline.AvgWaste, 0.01)
+
+	// Simulations never touch storage
+	assert.Empty(t, storage.allocations)
+}
+
+func TestSimulateValidation(t *testing.T) {
+	allocator := NewAllocator([]int{23, 31, 53}, newMockStorage())
+
+	_, err := allocator.Simulate(context.Background(), nil, nil, []int{10}, "")
+	assert.ErrorIs(t, err, ErrNoQuantities)
+
+	_, err = allocator.Simulate(context.Background(), []int{10}, nil, []int{10, -5}, "")
+	assert.ErrorIs(t, err, ErrInvalidPackSize)
+
+	_, err = allocator.Simulate(context.Background(), []int{10}, nil, []int{}, "")
+	assert.ErrorIs(t, err, ErrNoPackSizes)
+}
+
+func TestSimulateHistory(t *testing.T) {
+	storage := newMockStorage()
+	allocator := NewAllocator([]int{23, 31, 53}, storage)
+	_, _, err := allocator.CalculatePacks(50)
+	assert.NoError(t, err)
+
+	sim, err := allocator.SimulateHistory(context.Background(), time.Time{}, time.Time{}, nil, []int{50}, "")
+	assert.NoError(t, err)
+	assert.Len(t, sim.Rows, 1)
+	assert.Equal(t, 0, sim.Rows[0].Candidate.Waste)
+
+	allocator.storage = nil
+	_, err = allocator.SimulateHistory(context.Background(), time.Time{}, time.Time{}, nil, []int{50}, "")
+	assert.ErrorIs(t, err, ErrStorageNotConfigured)
+}
@@ -0,0 +1,104 @@
+package allocator
+
+import "fmt"
+
+// snapshot is the pack-size configuration at one point in time: the default
+// sizes, the named profiles and the SKUs mapped to them, the recorded
+// profile versions, the pack limits, the deprecated sizes, the pack and
+// carton dimensions, the pack-size rules and the profile defaults. A
+// published snapshot is never modified. Changes copy it, replace the maps or
+// slices they change and swap the copy in, so a calculation that loaded a
+// snapshot uses one consistent set of sizes, version and limits however the
+// configuration changes while it runs.
+type snapshot struct {
+	packSizes   []int
+	profiles    map[string][]int
+	skuProfiles map[string]string
+	versions    map[string]int
+	packLimits  map[string]map[int]int
+	// deprecatedSizes are sorted like the sizes; see SetDeprecatedSizes.
+	deprecatedSizes map[string][]int
+	// packDimensions and cartons are used by FitCartons.
+	packDimensions map[string]map[int]Dimensions
+	cartons        []Carton
+	// packRules are checked by profile updates and ValidateProfiles.
+	packRules PackSizeRules
+	// defaults are the profiles' calculation defaults; see
+	// SetProfileDefaults.
+	defaults map[string]ProfileDefaults
+}
+
+// sizes resolves a profile name to its sorted pack sizes.
+func (s *snapshot) sizes(name string) ([]int, error) {
+	if name == "" || name == DefaultProfile {
+		return s.packSizes, nil
+	}
+	sizes, ok := s.profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownProfile, name)
+	}
+	return sizes, nil
+}
+
+// version returns the recorded version of a profile, or zero.
+func (s *snapshot) version(name string) int {
+	if name == "" {
+		name = DefaultProfile
+	}
+	return s.versions[name]
+}
+
+// limits returns the pack limits of a profile, or nil if it has none.
+func (s *snapshot) limits(name string) map[int]int {
+	if name == "" {
+		name = DefaultProfile
+	}
+	return s.packLimits[name]
+}
+
+// deprecated returns the deprecated sizes of a profile, or nil if it has none.
+func (s *snapshot) deprecated(name string) []int {
+	if name == "" {
+		name = DefaultProfile
+	}
+	return s.deprecatedSizes[name]
+}
+
+// profileDefaults returns the calculation defaults of a profile, zero if it
+// has none.
+func (s *snapshot) profileDefaults(name string) ProfileDefaults {
+	if name == "" {
+		name = DefaultProfile
+	}
+	return s.defaults[name]
+}
+
+// config returns the current configuration snapshot. It never blocks.
+func (a *Allocator) config() *snapshot {
+	return a.current.Load()
+}
+
+// updateConfig publishes the snapshot fn makes of a copy of the current one.
+// fn must replace, never modify, the maps and slices it changes; if it fails
+// nothing is published. Updates are serialized, so none is lost, but do not
+// block calculations, which keep the snapshot they started with.
+func (a *Allocator) updateConfig(fn func(next *snapshot) error) error {
+	a.configMu.Lock()
+	defer a.configMu.Unlock()
+	next := *a.current.Load()
+	if err := fn(&next); err != nil {
+		return err
+	}
+	a.current.Store(&next)
+	return nil
+}
+
+// profileSizes resolves a profile name to its current sorted pack sizes.
+func (a *Allocator) profileSizes(name string) ([]int, error) {
+	return a.config().sizes(name)
+}
+
+// profileVersion returns the current recorded version of a profile, or zero.
+func (a *Allocator) profileVersion(name string) int {
+	return a.config().version(name)
+}
@@ -0,0 +1,100 @@
+package allocator
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotInFlightCalculation(t *testing.T) {
+	started := make(chan []int)
+	release := make(chan struct{})
+	RegisterStrategy("blocking", StrategyFunc(func(ctx context.Context, quantity int, sizes []int) (Result, error) {
+		started <- sizes
+		<-release
+		return backtrackingStrategy(ctx, quantity, sizes)
+	}))
+
+	store := newMockStorage()
+	a := NewAllocator([]int{250, 500, 1000}, store)
+	assert.NoError(t, a.RecordProfileVersions())
+	ctx := context.Background()
+
+	done := make(chan Result)
+	go func() {
+		result, err := a.Allocate(ctx, Request{Quantity: 501, Strategy: "blocking"})
+		assert.NoError(t, err)
+		done <- result
+	}()
+	sizes := <-started
+
+	// The update is not blocked by the calculation, nor visible to it
+	version, err := a.UpdateProfile(ctx, DefaultProfile, []int{23, 31, 53})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, version)
+	close(release)
+
+	result := <-done
+	assert.Equal(t, []int{1000, 500, 250}, sizes)
+	assert.Equal(t, map[int]int{500: 1, 250: 1}, result.Packs)
+	// It is stored under the version that produced it
+	assert.Equal(t, 1, store.allocations[501].ProfileVersion)
+	assert.Equal(t, []int{53, 31, 23}, a.config().packSizes)
+}
+
+func TestSnapshotConcurrentUpdates(t *testing.T) {
+	small := []int{23, 31, 53}
+	large := []int{250, 500, 1000}
+	a := NewAllocator(large, nil)
+	assert.NoError(t, a.SetProfiles(map[string][]int{"apparel": {10, 20}}, map[string]string{"TSHIRT": "apparel"}))
+	ctx := context.Background()
+
+	// Every result must use one of the two size sets, never a mix
+	inSet := func(packs map[int]int, set []int) bool {
+		for size := range packs {
+			if !containsSize(set, size) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				result, err := a.Allocate(ctx, Request{Quantity: 500 + w*10 + i})
+				assert.NoError(t, err)
+				assert.True(t, inSet(result.Packs, small) || inSet(result.Packs, large), "packs %v mix size sets", result.Packs)
+				a.Profiles()
+				a.ProfileForSKU("TSHIRT")
+				a.PackLimits(DefaultProfile)
+			}
+		}(w)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			sizes := small
+			if i%2 == 1 {
+				sizes = large
+			}
+			_, err := a.UpdateProfile(ctx, DefaultProfile, sizes)
+			assert.NoError(t, err)
+			_, err = a.UpdateProfile(ctx, "apparel", []int{10, 20 + i})
+			assert.NoError(t, err)
+		}
+	}()
+	wg.Wait()
+
+	// No update was lost
+	sizes, err := a.profileSizes("apparel")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{119, 10}, sizes)
+	assert.Equal(t, sortedSizes(large), a.config().packSizes)
+}
@@ -0,0 +1,278 @@
+package allocator
+
+import (
+	"context"
+
+	"github.com/n-th/gymshark/pkg/packer"
+)
+
+var ErrNoCombination = infeasible("no valid pack combination found")
+
+// ctxCheckInterval controls how often long-running strategies poll ctx.
+const ctxCheckInterval = 1 << 14
+
+// combinationStrategy tries, for every pack size, each possible count of that
+// size and tops up the remainder with smaller sizes, keeping the combination
+// with the least overage, then the fewest packs, then the canonical one.
+// Topping up adds every smaller size in turn, so totals reach about
+// len(sizes)-1 times the quantity; sums that would overflow end the top-up,
+// since such a candidate can never beat the first, which fits.
+func combinationStrategy(ctx context.Context, orderQuantity int, sizes []int) (Result, error) {
+	// Special case: order is smaller than all pack sizes
+	smallest := sizes[len(sizes)-1]
+	if orderQuantity < smallest {
+		return Result{Packs: map[int]int{smallest: 1}, Total: smallest}, nil
+	}
+
+	// Calculate the minimum number of packs needed for each pack size
+	minPacks := make(map[int]int)
+	for _, size := range sizes {
+		minPacks[size] = (orderQuantity + size - 1) / size
+	}
+
+	// Find the best combination by trying different combinations
+	bestResult := make(map[int]int)
+	bestTotal := 0
+	bestOverage := orderQuantity
+	bestCount := 0
+	candidates := 0
+	consider := func(current map[int]int, total int) {
+		overage := total - orderQuantity
+		if overage > bestOverage {
+			return
+		}
+		count := packCount(current)
+		if overage == bestOverage && bestCount > 0 &&
+			(count > bestCount || count == bestCount && !largerPacksFirst(current, bestResult, sizes)) {
+			return
+		}
+		bestResult = cloneMap(current)
+		bestTotal = total
+		bestOverage = overage
+		bestCount = count
+	}
+
+	// Try combinations starting from the largest pack size
+	for _, size := range sizes {
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
+		}
+
+		currentResult := make(map[int]int)
+		currentTotal := 0
+		remaining := orderQuantity
+
+		// Start with the maximum possible number of current pack size
+		maxPacks := minPacks[size]
+		for packs := maxPacks; packs >= 0; packs-- {
+			if packs%ctxCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return Result{}, err
+				}
+			}
+			// Top-ups of the previous count must not leak into this one.
+			clear(currentResult)
+			currentResult[size] = packs
+			// At most the quantity plus one pack, within checkOverflow's bound.
+			currentTotal = packs * size
+			remaining = orderQuantity - currentTotal
+
+			candidates++
+
+			// If we've met or exceeded the order quantity, check if this is better
+			if remaining <= 0 {
+				consider(currentResult, currentTotal)
+				continue
+			}
+
+			// Try to fill the remaining quantity with smaller packs
+			for _, smallerSize := range sizes {
+				if smallerSize >= size {
+					continue
+				}
+				candidates++
+				smallerPacks := (remaining + smallerSize - 1) / smallerSize
+				topUp, ok := mulInt(smallerPacks, smallerSize)
+				if ok {
+					currentTotal, ok = addInt(currentTotal, topUp)
+				}
+				if !ok {
+					break
+				}
+				currentResult[smallerSize] = smallerPacks
+				consider(currentResult, currentTotal)
+			}
+		}
+	}
+
+	// Drop zero counts from the best result
+	result := make(map[int]int)
+	for k, v := range bestResult {
+		if v > 0 {
+			result[k] = v
+		}
+	}
+
+	return Result{Packs: result, Total: bestTotal, Stats: Stats{Iterations: candidates}}, nil
+}
+
+// searchState tracks the best candidate found by the backtracking search.
+type searchState struct {
+	packs     map[int]int
+	total     int
+	waste     int
+	packCount int
+	found     bool
+	nodes     int
+	err       error
+}
+
+// backtrackingStrategy explores every pack combination recursively, preferring
+// the least waste, then the fewest packs, then the canonical combination.
+func backtrackingStrategy(ctx context.Context, quantity int, sizes []int) (Result, error) {
+	best := searchState{}
+	findOptimal(ctx, sizes, quantity, 0, map[int]int{}, 0, 0, &best)
+	if best.err != nil {
+		return Result{}, best.err
+	}
+	if !best.found {
+		return Result{}, ErrNoCombination
+	}
+	return Result{Packs: best.packs, Total: best.total, Stats: Stats{Iterations: best.nodes}}, nil
+}
+
+// findOptimal is a helper function that finds the optimal pack distribution
+// for a given quantity using a recursive backtracking approach.
+func findOptimal(ctx context.Context, sizes []int, target, index int, current map[int]int, total, packCount int, best *searchState) {
+	if best.err != nil {
+		return
+	}
+	best.nodes++
+	if best.nodes%ctxCheckInterval == 0 {
+		if err := ctx.Err(); err != nil {
+			best.err = err
+			return
+		}
+	}
+
+	if total >= target {
+		waste := total - target
+		if !best.found || waste < best.waste || (waste == best.waste && (packCount < best.packCount ||
+			packCount == best.packCount && largerPacksFirst(current, best.packs, sizes))) {
+			best.found = true
+			best.total = total
+			best.waste = waste
+			best.packCount = packCount
+			best.packs = cloneMap(current)
+		}
+		return
+	}
+
+	if index >= len(sizes) {
+		return
+	}
+
+	size := sizes[index]
+	maxQty := (target - total + size - 1) / size // minimal fill
+
+	for q := maxQty; q >= 0; q-- {
+		if q > 0 {
+			current[size] = q
+		} else {
+			delete(current, size)
+		}
+		findOptimal(ctx, sizes, target, index+1, current, total+q*size, packCount+q, best)
+	}
+}
+
+// greedyStrategy wraps greedyWithCorrection as an AllocationStrategy.
+func greedyStrategy(_ context.Context, quantity int, sizes []int) (Result, error) {
+	packs, total := greedyWithCorrection(quantity, sizes)
+	return Result{Packs: packs, Total: total}, nil
+}
+
+// greedyWithCorrection computes an approximate pack distribution
+// using a greedy approach followed by local correction to reduce waste.
+func greedyWithCorrection(quantity int, packSizes []int) (map[int]int, int) {
+	packs := make(map[int]int)
+	total := 0
+	remaining := quantity
+
+	// Greedy phase: use as many large packs as possible
+	for _, size := range packSizes {
+		count := remaining / size
+		if count > 0 {
+			packs[size] = count
+			total += size * count
+			remaining -= size * count
+		}
+	}
+
+	// Add smallest pack if needed. The total stays below the quantity plus
+	// the smallest size, within checkOverflow's bound.
+	if remaining > 0 {
+		smallest := packSizes[len(packSizes)-1]
+		packs[smallest]++
+		total += smallest
+	}
+
+	// Local correction phase
+	// Try replacing a small pack with a larger one that reduces waste
+	for i := len(packSizes) - 1; i > 0; i-- {
+		small := packSizes[i]
+		if packs[small] == 0 {
+			continue
+		}
+		for j := i - 1; j >= 0; j-- {
+			large := packSizes[j]
+			newTotal, ok := addInt(total-small, large)
+			if ok && newTotal >= quantity && newTotal < total {
+				packs[small]--
+				if packs[small] == 0 {
+					delete(packs, small)
+				}
+				packs[large]++
+				total = newTotal
+				break
+			}
+		}
+	}
+
+	return packs, total
+}
+
+// dpStrategy solves the allocation exactly with the table of pkg/packer
+// over every reachable total up to quantity + smallest - 1, which is the
+// largest total an optimal answer can ever need.
+func dpStrategy(ctx context.Context, quantity int, sizes []int) (Result, error) {
+	limit := quantity + sizes[len(sizes)-1] - 1
+	table, err := packer.NewTable(ctx, limit, sizes, nil)
+	if err != nil {
+		return Result{}, err
+	}
+	result, ok := solve(table, quantity)
+	if !ok {
+		return Result{}, ErrNoCombination
+	}
+	result.Stats.Iterations = limit * len(sizes)
+	return result, nil
+}
+
+// solve returns the allocation table records for quantity, or false if no
+// total the table covers reaches it.
+func solve(table *packer.Table, quantity int) (Result, bool) {
+	a, ok := table.Solve(quantity)
+	if !ok {
+		return Result{}, false
+	}
+	return Result{Packs: a.Packs, Total: a.Total}, true
+}
+
+// cloneMap creates a deep copy of a map[int]int.
+func cloneMap(src map[int]int) map[int]int {
+	dst := make(map[int]int, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
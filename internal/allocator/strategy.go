@@ -0,0 +1,148 @@
+package allocator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultStrategy is the name of the strategy used when none is configured.
+// It is exact, unlike combination, which does not try every combination.
+const DefaultStrategy = "dp"
+
+var ErrUnknownStrategy = invalid("unknown allocation strategy")
+
+// Result is the outcome of a single allocation.
+type Result struct {
+	Packs map[int]int
+	Total int
+	// Approximate is set when the result came from the greedy fallback
+	// after the requested strategy exceeded the soft timeout.
+	Approximate bool
+	// Source is SourceManual for pinned results and empty for computed ones.
+	Source string
+	// Shortfall is how much of the quantity the packs do not cover. It is
+	// only set for constrained requests with AllowShortfall.
+	Shortfall int
+	// DeprecatedPacks are the packs of sizes deprecated in the profile,
+	// nil when there are none; see SetDeprecatedSizes.
+	DeprecatedPacks map[int]int
+	// Rules are the allocation rules that held for the result, nil when
+	// none did; see SetAllocationRules. Strategies leave it nil.
+	Rules []RuleMatch
+	// ComputedAt is when the packs were computed: the time of the
+	// calculation, when the stored allocation was computed for a result
+	// cache hit (Stats.Cache is CacheHit), or when the quantity was pinned.
+	// Strategies leave it zero; Allocate and Preview set it.
+	ComputedAt time.Time
+	// Stats describes how the result was computed.
+	Stats Stats
+}
+
+// Cache statuses reported in Stats.
+const (
+	// CacheMiss means the negative cache was consulted and the result computed.
+	CacheMiss = "miss"
+	// CacheBypass means the request skipped the cache, as constrained ones do.
+	CacheBypass = "bypass"
+	// CacheHit means the result was a stored allocation; see SetResultCache.
+	CacheHit = "hit"
+)
+
+// AlgorithmCache is the algorithm recorded for allocations served from the
+// result cache. Computed ones record Stats.Strategy and pinned ones
+// SourceManual.
+const AlgorithmCache = "cache"
+
+// FallbackStrategy produces the approximate result returned when an exact
+// strategy passes the soft timeout.
+const FallbackStrategy = "greedy"
+
+// Stats is diagnostic information about a computation.
+type Stats struct {
+	// Strategy names the strategy that produced the result; "greedy" after
+	// a soft timeout fallback.
+	Strategy string
+	// Iterations counts the work done: search nodes for backtracking and
+	// branchbound, table cells for dp and candidate combinations for
+	// combination. Strategies that do not count leave it zero.
+	Iterations int
+	Cache      string
+	Duration   time.Duration
+	// Estimate is the duration the cost model expected and Deadline the
+	// soft deadline the calculation ran with; see SetCostModel. Both are
+	// zero for results that were not computed.
+	Estimate time.Duration
+	Deadline time.Duration
+}
+
+// AllocationStrategy computes a pack distribution for a quantity.
+// The sizes slice is sorted in descending order and must not be modified.
+// Implementations should honour ctx cancellation for long-running searches.
+//
+// Implementations must be deterministic: the same quantity and sizes give the
+// same result, which stored allocations, caches and shadow comparisons rely
+// on, and which makes any result reproducible for debugging. None of the
+// built-in strategies uses randomness. A randomized heuristic, such as
+// simulated annealing, would have to take its seed from the request and
+// record it with the stored allocation, neither of which exists yet.
+type AllocationStrategy interface {
+	Allocate(ctx context.Context, quantity int, sizes []int) (Result, error)
+}
+
+// StrategyFunc adapts an ordinary function to the AllocationStrategy interface.
+type StrategyFunc func(ctx context.Context, quantity int, sizes []int) (Result, error)
+
+// Allocate calls f(ctx, quantity, sizes).
+func (f StrategyFunc) Allocate(ctx context.Context, quantity int, sizes []int) (Result, error) {
+	return f(ctx, quantity, sizes)
+}
+
+var (
+	strategiesMu sync.RWMutex
+	strategies   = map[string]AllocationStrategy{}
+)
+
+// RegisterStrategy makes a strategy available under the given name.
+// Registering the same name twice replaces the previous strategy.
+func RegisterStrategy(name string, s AllocationStrategy) {
+	if name == "" || s == nil {
+		panic("allocator: RegisterStrategy requires a name and a strategy")
+	}
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+	strategies[name] = s
+}
+
+// LookupStrategy returns the strategy registered under name.
+func LookupStrategy(name string) (AllocationStrategy, error) {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+	s, ok := strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownStrategy, name)
+	}
+	return s, nil
+}
+
+// Strategies returns the names of all registered strategies in sorted order.
+func Strategies() []string {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterStrategy("combination", StrategyFunc(combinationStrategy))
+	RegisterStrategy("backtracking", StrategyFunc(backtrackingStrategy))
+	RegisterStrategy("greedy", StrategyFunc(greedyStrategy))
+	RegisterStrategy("dp", StrategyFunc(dpStrategy))
+	RegisterStrategy(ConstrainedStrategy, StrategyFunc(branchAndBoundStrategy))
+}
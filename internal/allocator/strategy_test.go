@@ -0,0 +1,207 @@
+package allocator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupStrategy(t *testing.T) {
+	for _, name := range []string{"combination", "backtracking", "greedy", "dp"} {
+		s, err := LookupStrategy(name)
+		assert.NoError(t, err, name)
+		assert.NotNil(t, s, name)
+	}
+
+	_, err := LookupStrategy("missing")
+	assert.ErrorIs(t, err, ErrUnknownStrategy)
+}
+
+func TestRegisterStrategy(t *testing.T) {
+	RegisterStrategy("fixed", StrategyFunc(func(_ context.Context, quantity int, sizes []int) (Result, error) {
+		return Result{Packs: map[int]int{sizes[0]: 1}, Total: sizes[0]}, nil
+	}))
+	assert.Contains(t, Strategies(), "fixed")
+
+	allocator := NewAllocator([]int{10, 20}, newMockStorage())
+	packs, total, err := allocator.Calculate(context.Background(), 5, "fixed")
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{20: 1}, packs)
+	assert.Equal(t, 20, total)
+}
+
+func TestSetStrategy(t *testing.T) {
+	allocator := NewAllocator([]int{23, 31, 53}, newMockStorage())
+	assert.Equal(t, DefaultStrategy, allocator.Strategy())
+
+	assert.ErrorIs(t, allocator.SetStrategy("missing"), ErrUnknownStrategy)
+	assert.Equal(t, DefaultStrategy, allocator.Strategy())
+
+	assert.NoError(t, allocator.SetStrategy("dp"))
+	assert.Equal(t, "dp", allocator.Strategy())
+}
+
+func TestExactStrategies(t *testing.T) {
+	sizes := []int{5000, 2000, 1000, 500, 250}
+
+	tests := []struct {
+		quantity      int
+		expectedPacks map[int]int
+		expectedTotal int
+	}{
+		{1, map[int]int{250: 1}, 250},
+		{250, map[int]int{250: 1}, 250},
+		{251, map[int]int{500: 1}, 500},
+		{501, map[int]int{500: 1, 250: 1}, 750},
+		{12001, map[int]int{5000: 2, 2000: 1, 250: 1}, 12250},
+	}
+
+	for _, name := range []string{"backtracking", "dp"} {
+		s, err := LookupStrategy(name)
+		assert.NoError(t, err)
+		for _, tt := range tests {
+			result, err := s.Allocate(context.Background(), tt.quantity, sizes)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedPacks, result.Packs, "%s(%d)", name, tt.quantity)
+			assert.Equal(t, tt.expectedTotal, result.Total, "%s(%d)", name, tt.quantity)
+		}
+	}
+}
+
+func TestStrategiesDeterministic(t *testing.T) {
+	sizes := []int{53, 31, 23}
+	for _, name := range []string{"combination", "backtracking", "greedy", "dp", ConstrainedStrategy} {
+		s, err := LookupStrategy(name)
+		assert.NoError(t, err)
+		for _, quantity := range []int{1, 263, 500, 4999} {
+			first, err := s.Allocate(context.Background(), quantity, sizes)
+			assert.NoError(t, err)
+			for i := 0; i < 3; i++ {
+				again, err := s.Allocate(context.Background(), quantity, sizes)
+				assert.NoError(t, err)
+				assert.Equal(t, first.Packs, again.Packs, "%s(%d)", name, quantity)
+			}
+		}
+	}
+}
+
+func TestStrategiesConsistent(t *testing.T) {
+	sizes := []int{53, 31, 23}
+	dp, err := LookupStrategy("dp")
+	assert.NoError(t, err)
+	for _, name := range []string{DefaultStrategy, "combination", "backtracking", "greedy"} {
+		s, err := LookupStrategy(name)
+		assert.NoError(t, err)
+		for quantity := 1; quantity <= 3000; quantity++ {
+			result, err := s.Allocate(context.Background(), quantity, sizes)
+			assert.NoError(t, err)
+			sum := 0
+			for size, count := range result.Packs {
+				sum += size * count
+			}
+			assert.Equal(t, result.Total, sum, "%s(%d) packs %v", name, quantity, result.Packs)
+			assert.GreaterOrEqual(t, result.Total, quantity, "%s(%d)", name, quantity)
+			if name == DefaultStrategy || name == "backtracking" {
+				exact, err := dp.Allocate(context.Background(), quantity, sizes)
+				assert.NoError(t, err)
+				assert.Equal(t, exact.Total, result.Total, "%s(%d)", name, quantity)
+			}
+		}
+	}
+}
+
+func TestAllocateStats(t *testing.T) {
+	allocator := NewAllocator([]int{23, 31, 53}, newMockStorage())
+
+	for _, name := range []string{"combination", "backtracking", "dp"} {
+		result, err := allocator.Allocate(context.Background(), Request{Quantity: 500, Strategy: name})
+		assert.NoError(t, err)
+		assert.Equal(t, name, result.Stats.Strategy)
+		assert.Positive(t, result.Stats.Iterations, name)
+		assert.Equal(t, CacheMiss, result.Stats.Cache)
+		assert.Positive(t, result.Stats.Duration, name)
+	}
+
+	result, err := allocator.Allocate(context.Background(), Request{Quantity: 500, Constraints: &Constraints{MaxPacks: 20}})
+	assert.NoError(t, err)
+	assert.Equal(t, ConstrainedStrategy, result.Stats.Strategy)
+	assert.Positive(t, result.Stats.Iterations)
+	assert.Equal(t, CacheBypass, result.Stats.Cache)
+}
+
+func TestDPStrategyCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := dpStrategy(ctx, 1000000, []int{53, 31, 23})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// blockingStrategy never finishes on its own; it only returns once ctx is done.
+var blockingStrategy = StrategyFunc(func(ctx context.Context, _ int, _ []int) (Result, error) {
+	<-ctx.Done()
+	return Result{}, ctx.Err()
+})
+
+func TestSoftTimeoutFallsBackToGreedy(t *testing.T) {
+	RegisterStrategy("blocking", blockingStrategy)
+
+	allocator := NewAllocator([]int{23, 31, 53}, newMockStorage())
+	allocator.SetTimeouts(10*time.Millisecond, time.Second)
+
+	result, err := allocator.Allocate(context.Background(), Request{Quantity: 500, Strategy: "blocking"})
+	assert.NoError(t, err)
+	assert.True(t, result.Approximate)
+	assert.GreaterOrEqual(t, result.Total, 500)
+	assert.Equal(t, "greedy", result.Stats.Strategy)
+
+	result, err = allocator.Allocate(context.Background(), Request{Quantity: 500, Strategy: "dp"})
+	assert.NoError(t, err)
+	assert.False(t, result.Approximate)
+}
+
+func TestHardTimeoutCancels(t *testing.T) {
+	RegisterStrategy("blocking", blockingStrategy)
+
+	allocator := NewAllocator([]int{23, 31, 53}, newMockStorage())
+	allocator.SetTimeouts(0, 10*time.Millisecond)
+
+	_, err := allocator.Allocate(context.Background(), Request{Quantity: 500, Strategy: "blocking"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestCanonicalTies(t *testing.T) {
+	tests := []struct {
+		quantity int
+		sizes    []int
+		expected map[int]int
+	}{
+		// 5+3 and 4+4 both make 8 from two packs
+		{8, []int{5, 4, 3}, map[int]int{5: 1, 3: 1}},
+		// 6+6, 9+3 and 8+4 make 12 from two packs
+		{12, []int{9, 8, 6, 4, 3}, map[int]int{9: 1, 3: 1}},
+		// 10+6+6, 10+10+2 and 9+9+4 make 22 from three packs
+		{22, []int{10, 9, 6, 4, 2}, map[int]int{10: 2, 2: 1}},
+	}
+	for _, name := range []string{"backtracking", "dp", ConstrainedStrategy} {
+		s, err := LookupStrategy(name)
+		assert.NoError(t, err)
+		for _, tt := range tests {
+			for run := 0; run < 3; run++ {
+				result, err := s.Allocate(context.Background(), tt.quantity, tt.sizes)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, result.Packs, "%s(%d, %v)", name, tt.quantity, tt.sizes)
+			}
+		}
+	}
+}
+
+func TestLargerPacksFirst(t *testing.T) {
+	sizes := []int{5, 4, 3}
+	assert.True(t, largerPacksFirst(map[int]int{5: 1, 3: 1}, map[int]int{4: 2}, sizes))
+	assert.False(t, largerPacksFirst(map[int]int{4: 2}, map[int]int{5: 1, 3: 1}, sizes))
+	assert.True(t, largerPacksFirst(map[int]int{5: 1, 4: 1}, map[int]int{5: 1, 3: 2}, sizes))
+	assert.False(t, largerPacksFirst(map[int]int{4: 2}, map[int]int{4: 2}, sizes))
+}
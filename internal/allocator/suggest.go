@@ -0,0 +1,105 @@
+package allocator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/n-th/gymshark/pkg/packer"
+)
+
+// MaxSuggestQuantity bounds the quantities Suggest takes, since its table
+// holds two integers for every total up to the quantity plus the largest
+// pack size.
+const MaxSuggestQuantity = 1_000_000
+
+// Suggestion is the quantities nearest to an ordered one that allocate
+// without waste.
+type Suggestion struct {
+	Quantity int
+	Profile  string
+	// Exact is set when Quantity itself allocates without waste.
+	Exact bool
+	// Below is the nearest quantity below Quantity that allocates without
+	// waste, nil when Quantity is at most the smallest pack size.
+	Below *SuggestedQuantity
+	// Above is the nearest quantity above Quantity that allocates without
+	// waste; there always is one.
+	Above SuggestedQuantity
+}
+
+// SuggestedQuantity is a quantity the packs hold exactly.
+type SuggestedQuantity struct {
+	Quantity int
+	Packs    map[int]int
+}
+
+// Suggest returns the quantities nearest to quantity, below and above, that
+// the sizes of profile allocate without waste, so that customers can be
+// nudged towards them, each with the fewest packs holding it. Like the
+// allocations, the packs avoid deprecated sizes where they can. Nothing is
+// stored. Profiles with pack limits are not supported, since the table
+// ignores them.
+func (a *Allocator) Suggest(ctx context.Context, quantity int, profile string) (Suggestion, error) {
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	if quantity <= 0 {
+		return Suggestion{}, ErrInvalidQuantity
+	}
+	if err := a.checkQuantity(quantity); err != nil {
+		return Suggestion{}, err
+	}
+	if quantity > MaxSuggestQuantity {
+		return Suggestion{}, &LimitError{Field: "quantity", Value: quantity, Limit: MaxSuggestQuantity}
+	}
+	if profile == WeightProfile {
+		return Suggestion{}, fmt.Errorf("%w: %q", ErrUnknownProfile, profile)
+	}
+	cfg := a.config()
+	if len(cfg.limits(profile)) > 0 {
+		return Suggestion{}, fmt.Errorf("%w: profile %q has pack limits", ErrConstraintsUnsupported, profile)
+	}
+	sizes, err := cfg.sizes(profile)
+	if err != nil {
+		return Suggestion{}, err
+	}
+	if len(sizes) == 0 {
+		return Suggestion{}, ErrNoPackSizes
+	}
+	if err := checkOverflow(quantity, sizes); err != nil {
+		return Suggestion{}, err
+	}
+
+	release, err := a.admit(ctx)
+	if err != nil {
+		return Suggestion{}, err
+	}
+	defer release()
+	if a.hardTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.hardTimeout)
+		defer cancel()
+	}
+
+	// The totals packs reach include every one reached plus the largest
+	// size, so the nearest ones on either side are within that size.
+	largest := sizes[0]
+	table, err := packer.NewTable(ctx, quantity+largest, sizes, cfg.deprecated(profile))
+	if err != nil {
+		return Suggestion{}, err
+	}
+	s := Suggestion{Quantity: quantity, Profile: profile, Exact: table.Packs(quantity) != nil}
+	for t := quantity - 1; t > 0 && t >= quantity-largest; t-- {
+		if packs := table.Packs(t); packs != nil {
+			s.Below = &SuggestedQuantity{Quantity: t, Packs: packs}
+			break
+		}
+	}
+	for t := quantity + 1; t <= quantity+largest; t++ {
+		if packs := table.Packs(t); packs != nil {
+			s.Above = SuggestedQuantity{Quantity: t, Packs: packs}
+			break
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,61 @@
+package allocator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuggest(t *testing.T) {
+	ctx := context.Background()
+	a := NewAllocator([]int{250, 500, 1000}, nil)
+	assert.NoError(t, a.SetProfiles(map[string][]int{"odd": {23, 31, 53}}, nil))
+
+	s, err := a.Suggest(ctx, 600, "")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultProfile, s.Profile)
+	assert.False(t, s.Exact)
+	if assert.NotNil(t, s.Below) {
+		assert.Equal(t, SuggestedQuantity{Quantity: 500, Packs: map[int]int{500: 1}}, *s.Below)
+	}
+	assert.Equal(t, SuggestedQuantity{Quantity: 750, Packs: map[int]int{500: 1, 250: 1}}, s.Above)
+
+	// Nothing below the smallest size is waste-free.
+	s, err = a.Suggest(ctx, 250, "")
+	assert.NoError(t, err)
+	assert.True(t, s.Exact)
+	assert.Nil(t, s.Below)
+	assert.Equal(t, 500, s.Above.Quantity)
+
+	s, err = a.Suggest(ctx, 50, "odd")
+	assert.NoError(t, err)
+	assert.False(t, s.Exact)
+	if assert.NotNil(t, s.Below) {
+		assert.Equal(t, 46, s.Below.Quantity)
+		assert.Equal(t, map[int]int{23: 2}, s.Below.Packs)
+	}
+	assert.Equal(t, SuggestedQuantity{Quantity: 53, Packs: map[int]int{53: 1}}, s.Above)
+
+	// Deprecated sizes are avoided where they can be.
+	assert.NoError(t, a.SetDeprecatedSizes(map[string][]int{DefaultProfile: {1000}}))
+	s, err = a.Suggest(ctx, 900, "")
+	assert.NoError(t, err)
+	assert.Equal(t, SuggestedQuantity{Quantity: 1000, Packs: map[int]int{500: 2}}, s.Above)
+}
+
+func TestSuggestErrors(t *testing.T) {
+	ctx := context.Background()
+	a := NewAllocator([]int{250, 500, 1000}, nil)
+
+	_, err := a.Suggest(ctx, 0, "")
+	assert.ErrorIs(t, err, ErrInvalidQuantity)
+	_, err = a.Suggest(ctx, 1, "apparel")
+	assert.ErrorIs(t, err, ErrUnknownProfile)
+	_, err = a.Suggest(ctx, MaxSuggestQuantity+1, "")
+	assert.ErrorIs(t, err, ErrTooLarge)
+
+	assert.NoError(t, a.SetPackLimits(map[string]map[int]int{DefaultProfile: {1000: 1}}))
+	_, err = a.Suggest(ctx, 600, "")
+	assert.ErrorIs(t, err, ErrConstraintsUnsupported)
+}
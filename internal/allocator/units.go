@@ -0,0 +1,115 @@
+package allocator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var ErrUnknownUnit = invalid("invalid unit")
+
+// Rounding is how a quantity converted to items is rounded to a whole
+// number.
+type Rounding string
+
+const (
+	// RoundUp rounds up, so the order is never short. It is the default.
+	RoundUp Rounding = "up"
+	// RoundDown rounds down.
+	RoundDown Rounding = "down"
+	// RoundNearest rounds to the nearest item, halves up.
+	RoundNearest Rounding = "nearest"
+)
+
+// Unit converts quantities a caller expresses in something other than
+// items, e.g. cases of 12, to items before they are allocated.
+type Unit struct {
+	// Multiplier is the number of items in one unit, e.g. 12 for cases
+	// of a dozen or 0.5 for half-items.
+	Multiplier float64
+	// Rounding rounds a fractional number of items; empty is RoundUp.
+	Rounding Rounding
+}
+
+// Validate returns an error for a non-positive multiplier or an unknown
+// rounding.
+func (u Unit) Validate() error {
+	if !(u.Multiplier > 0) || math.IsInf(u.Multiplier, 0) {
+		return fmt.Errorf("multiplier must be positive, got %v", u.Multiplier)
+	}
+	switch u.Rounding {
+	case "", RoundUp, RoundDown, RoundNearest:
+		return nil
+	}
+	return fmt.Errorf("unknown rounding %q (want up, down or nearest)", u.Rounding)
+}
+
+// items converts quantity units to items.
+func (u Unit) items(quantity int) (int, error) {
+	items := float64(quantity) * u.Multiplier
+	switch u.Rounding {
+	case RoundDown:
+		items = math.Floor(items)
+	case RoundNearest:
+		items = math.Floor(items + 0.5)
+	default:
+		// Guard against 0.1*30 = 3.0000000000000004 rounding up to 4.
+		items = math.Ceil(items - 1e-9)
+	}
+	if items <= 0 || items > math.MaxInt32 {
+		return 0, fmt.Errorf("%w: %d x %v is %v items", ErrInvalidQuantity, quantity, u.Multiplier, items)
+	}
+	return int(items), nil
+}
+
+// SetUnits configures the units requests may express quantities in, by
+// name. It must be called before the allocator is used concurrently.
+func (a *Allocator) SetUnits(units map[string]Unit) error {
+	for name, u := range units {
+		if name == "" {
+			return errors.New("unit name must not be empty")
+		}
+		if err := u.Validate(); err != nil {
+			return fmt.Errorf("unit %q: %w", name, err)
+		}
+	}
+	a.units = units
+	return nil
+}
+
+// Units returns the configured units by name. The map must not be modified.
+func (a *Allocator) Units() map[string]Unit {
+	return a.units
+}
+
+// NormalizeQuantity converts quantity, expressed in the named unit, to
+// items. An empty unit leaves it unchanged. It fails with ErrUnknownUnit for
+// units not configured with SetUnits, and with ErrInvalidQuantity when the
+// quantity rounds to no items.
+func (a *Allocator) NormalizeQuantity(unit string, quantity int) (int, error) {
+	if unit == "" {
+		return quantity, nil
+	}
+	u, ok := a.units[unit]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownUnit, unit)
+	}
+	if quantity <= 0 {
+		return 0, ErrInvalidQuantity
+	}
+	return u.items(quantity)
+}
+
+// normalize converts the quantity of a request expressed in a unit to
+// items, keeping the requested quantity so it is stored with the result.
+func (a *Allocator) normalize(req Request) (Request, error) {
+	if req.Unit == "" {
+		return req, nil
+	}
+	items, err := a.NormalizeQuantity(req.Unit, req.Quantity)
+	if err != nil {
+		return Request{}, err
+	}
+	req.requested, req.Quantity = req.Quantity, items
+	return req, nil
+}
@@ -0,0 +1,95 @@
+package allocator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeQuantity(t *testing.T) {
+	a := NewAllocator([]int{250, 500, 1000}, nil)
+	assert.NoError(t, a.SetUnits(map[string]Unit{
+		"case":    {Multiplier: 12},
+		"tenth":   {Multiplier: 0.1},
+		"half":    {Multiplier: 0.5, Rounding: RoundDown},
+		"third":   {Multiplier: 1.0 / 3, Rounding: RoundNearest},
+		"rounded": {Multiplier: 2.5, Rounding: RoundNearest},
+	}))
+
+	tests := []struct {
+		unit     string
+		quantity int
+		want     int
+	}{
+		{"", 7, 7},
+		{"case", 3, 36},
+		{"tenth", 30, 3},
+		{"tenth", 31, 4},
+		{"half", 5, 2},
+		{"third", 4, 1},
+		{"third", 5, 2},
+		{"rounded", 1, 3},
+	}
+	for _, tt := range tests {
+		got, err := a.NormalizeQuantity(tt.unit, tt.quantity)
+		assert.NoError(t, err, "%d %s", tt.quantity, tt.unit)
+		assert.Equal(t, tt.want, got, "%d %s", tt.quantity, tt.unit)
+	}
+
+	_, err := a.NormalizeQuantity("pallet", 1)
+	assert.ErrorIs(t, err, ErrUnknownUnit)
+	_, err = a.NormalizeQuantity("half", 1)
+	assert.ErrorIs(t, err, ErrInvalidQuantity)
+	_, err = a.NormalizeQuantity("case", 0)
+	assert.ErrorIs(t, err, ErrInvalidQuantity)
+	_, err = a.NormalizeQuantity("case", 1<<40)
+	assert.ErrorIs(t, err, ErrInvalidQuantity)
+}
+
+func TestSetUnits(t *testing.T) {
+	a := NewAllocator([]int{250}, nil)
+	for _, units := range []map[string]Unit{
+		{"": {Multiplier: 12}},
+		{"case": {}},
+		{"case": {Multiplier: -12}},
+		{"case": {Multiplier: 12, Rounding: "banker"}},
+	} {
+		assert.Error(t, a.SetUnits(units), "%v", units)
+	}
+	assert.Empty(t, a.Units())
+}
+
+func TestAllocateInUnits(t *testing.T) {
+	store := newMockStorage()
+	a := NewAllocator([]int{250, 500, 1000}, store)
+	assert.NoError(t, a.SetUnits(map[string]Unit{"case": {Multiplier: 12}}))
+	ctx := context.Background()
+
+	result, err := a.Allocate(ctx, Request{Quantity: 21, Unit: "case"})
+	assert.NoError(t, err)
+	assert.Equal(t, 500, result.Total)
+	if assert.Contains(t, store.allocations, 252) {
+		stored := store.allocations[252]
+		assert.Equal(t, "case", stored.Unit)
+		assert.Equal(t, 21, stored.RequestedQuantity)
+	}
+
+	// Quantities in items record neither.
+	_, err = a.Allocate(ctx, Request{Quantity: 21})
+	assert.NoError(t, err)
+	assert.Empty(t, store.allocations[21].Unit)
+	assert.Zero(t, store.allocations[21].RequestedQuantity)
+
+	result, err = a.Preview(ctx, Request{Quantity: 100, Unit: "case"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1250, result.Total)
+	assert.NotContains(t, store.allocations, 1200)
+
+	_, err = a.Allocate(ctx, Request{Quantity: 1, Unit: "pallet"})
+	assert.ErrorIs(t, err, ErrUnknownUnit)
+
+	a.SetLimits(Limits{MaxQuantity: 1000})
+	_, err = a.Allocate(ctx, Request{Quantity: 100, Unit: "case"})
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+}
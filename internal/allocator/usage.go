@@ -0,0 +1,142 @@
+package allocator
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+var ErrQuotaExceeded = errors.New("usage quota exceeded")
+
+// Quota bounds what a client may consume in a calendar month (UTC). A zero
+// field is unlimited.
+type Quota struct {
+	Requests int64
+	Compute  time.Duration
+}
+
+// Metering configures usage tracking and quotas.
+type Metering struct {
+	// Enabled records the requests and compute time of every client.
+	Enabled bool
+	// Default is the quota of clients without one in Clients.
+	Default Quota
+	// Clients maps clients, e.g. "key:3f2a9c1b0d4e", to their quotas.
+	Clients map[string]Quota
+}
+
+// QuotaError reports a client over its monthly quota.
+// It matches ErrQuotaExceeded with errors.Is.
+type QuotaError struct {
+	Client string
+	// Resource is "requests" or "compute".
+	Resource string
+	// Reset is when the next month, and with it a fresh quota, starts.
+	Reset time.Time
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s: monthly %s quota of %s used up", ErrQuotaExceeded, e.Resource, e.Client)
+}
+
+// Is makes errors.Is(err, ErrQuotaExceeded) true for exceeded quotas.
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// Validate returns an error for negative quotas or an empty client.
+func (m Metering) Validate() error {
+	for client, q := range m.Clients {
+		if client == "" {
+			return errors.New("quota client must not be empty")
+		}
+		if q.Requests < 0 || q.Compute < 0 {
+			return fmt.Errorf("quota of %s must not be negative", client)
+		}
+	}
+	if m.Default.Requests < 0 || m.Default.Compute < 0 {
+		return errors.New("default quota must not be negative")
+	}
+	return nil
+}
+
+// SetMetering configures usage tracking. It must be called before the
+// allocator is used concurrently.
+func (a *Allocator) SetMetering(m Metering) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	a.metering = m
+	return nil
+}
+
+// Metering returns the usage tracking configuration.
+func (a *Allocator) Metering() Metering {
+	return a.metering
+}
+
+// QuotaFor returns the monthly quota of client.
+func (a *Allocator) QuotaFor(client string) Quota {
+	if q, ok := a.metering.Clients[client]; ok {
+		return q
+	}
+	return a.metering.Default
+}
+
+// CheckQuota fails with a QuotaError if client has used up its quota for
+// the current month. Clients without a quota, and every client while
+// metering is disabled, always pass.
+func (a *Allocator) CheckQuota(client string) error {
+	q := a.QuotaFor(client)
+	if !a.metering.Enabled || q == (Quota{}) {
+		return nil
+	}
+	if a.storage == nil {
+		return ErrStorageNotConfigured
+	}
+	now := time.Now()
+	usage, err := a.storage.GetUsage(now, client)
+	if err != nil {
+		return fmt.Errorf("read usage of %s: %w", client, err)
+	}
+	if len(usage) == 0 {
+		return nil
+	}
+	u := usage[0]
+	resource := ""
+	switch {
+	case q.Requests > 0 && u.Requests >= q.Requests:
+		resource = "requests"
+	case q.Compute > 0 && u.Compute >= q.Compute:
+		resource = "compute"
+	default:
+		return nil
+	}
+	year, month, _ := now.UTC().Date()
+	reset := time.Date(year, month+1, 1, 0, 0, 0, 0, time.UTC)
+	return &QuotaError{Client: client, Resource: resource, Reset: reset}
+}
+
+// RecordUsage counts a request of client that took compute towards its
+// usage this month. Nothing is recorded while metering is disabled or the
+// allocator is read-only.
+func (a *Allocator) RecordUsage(client string, compute time.Duration) error {
+	if !a.metering.Enabled || a.ReadOnly().Enabled {
+		return nil
+	}
+	if a.storage == nil {
+		return ErrStorageNotConfigured
+	}
+	return a.storage.RecordUsage(client, time.Now(), compute)
+}
+
+// Usage returns the usage of every client in the month of at, or only that
+// of client if it is not empty.
+func (a *Allocator) Usage(at time.Time, client string) ([]storage.Usage, error) {
+	if a.storage == nil {
+		return nil, ErrStorageNotConfigured
+	}
+	return a.storage.GetUsage(at, client)
+}
@@ -0,0 +1,82 @@
+package allocator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetering(t *testing.T) {
+	store := newMockStorage()
+	a := NewAllocator([]int{250, 500, 1000}, store)
+
+	// Disabled by default
+	assert.NoError(t, a.RecordUsage("key:a", time.Second))
+	assert.Empty(t, store.usage)
+
+	assert.NoError(t, a.SetMetering(Metering{
+		Enabled: true,
+		Default: Quota{Requests: 2},
+		Clients: map[string]Quota{
+			"key:batch": {Compute: time.Second},
+			"key:free":  {},
+		},
+	}))
+	assert.Equal(t, Quota{Requests: 2}, a.QuotaFor("key:other"))
+	assert.Equal(t, Quota{}, a.QuotaFor("key:free"))
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, a.CheckQuota("key:a"))
+		assert.NoError(t, a.RecordUsage("key:a", time.Millisecond))
+	}
+	err := a.CheckQuota("key:a")
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	var quota *QuotaError
+	if assert.ErrorAs(t, err, &quota) {
+		assert.Equal(t, "requests", quota.Resource)
+		assert.Equal(t, 1, quota.Reset.Day())
+		assert.True(t, quota.Reset.After(time.Now()))
+	}
+
+	assert.NoError(t, a.RecordUsage("key:batch", 600*time.Millisecond))
+	assert.NoError(t, a.CheckQuota("key:batch"))
+	assert.NoError(t, a.RecordUsage("key:batch", 600*time.Millisecond))
+	err = a.CheckQuota("key:batch")
+	if assert.ErrorAs(t, err, &quota) {
+		assert.Equal(t, "compute", quota.Resource)
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, a.RecordUsage("key:free", time.Second))
+	}
+	assert.NoError(t, a.CheckQuota("key:free"))
+
+	usage, err := a.Usage(time.Now(), "")
+	assert.NoError(t, err)
+	if assert.Len(t, usage, 3) {
+		assert.Equal(t, "key:a", usage[0].Client)
+		assert.Equal(t, int64(2), usage[0].Requests)
+		assert.Equal(t, 2*time.Millisecond, usage[0].Compute)
+	}
+
+	// Nothing is recorded while read-only.
+	assert.NoError(t, a.SetReadOnly(ReadOnly{Enabled: true, Mode: ReadOnlySkip}))
+	assert.NoError(t, a.RecordUsage("key:ro", time.Second))
+	usage, err = a.Usage(time.Now(), "key:ro")
+	assert.NoError(t, err)
+	assert.Empty(t, usage)
+}
+
+func TestSetMetering(t *testing.T) {
+	a := NewAllocator([]int{250}, nil)
+	assert.Error(t, a.SetMetering(Metering{Default: Quota{Requests: -1}}))
+	assert.Error(t, a.SetMetering(Metering{Clients: map[string]Quota{"key:a": {Compute: -time.Second}}}))
+	assert.Error(t, a.SetMetering(Metering{Clients: map[string]Quota{"": {Requests: 1}}}))
+
+	assert.NoError(t, a.SetMetering(Metering{Enabled: true, Default: Quota{Requests: 1}}))
+	assert.ErrorIs(t, a.CheckQuota("key:a"), ErrStorageNotConfigured)
+	assert.ErrorIs(t, a.RecordUsage("key:a", time.Second), ErrStorageNotConfigured)
+	_, err := a.Usage(time.Now(), "")
+	assert.ErrorIs(t, err, ErrStorageNotConfigured)
+}
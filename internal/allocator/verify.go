@@ -0,0 +1,144 @@
+package allocator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+// ExactStrategy is the strategy VerifyHistory recomputes allocations with:
+// it always finds the least waste, then the fewest packs.
+const ExactStrategy = "dp"
+
+// Mismatch is a stored allocation the exact solver beats, with less waste or
+// as little waste in fewer packs.
+type Mismatch struct {
+	ID             int64
+	Quantity       int
+	Profile        string
+	ProfileVersion int
+	CreatedAt      time.Time
+	Stored         SimulationOutcome
+	Optimal        SimulationOutcome
+}
+
+// Verification is the outcome of VerifyHistory.
+type Verification struct {
+	// Checked allocations were recomputed. Skipped ones were pinned, short
+	// of stock, imported without packs, or computed with pack sizes that are
+	// no longer known.
+	Checked    int
+	Skipped    int
+	Mismatches []Mismatch
+	// ExcessWaste is how much more the mismatches wasted than optimal.
+	ExcessWaste int
+}
+
+// VerifyHistory recomputes the allocations stored in [from, to) with
+// ExactStrategy and the pack sizes each was computed with, and reports those
+// that were not optimal; a zero from or to leaves the range open. Allocations
+// record their profile version, whose sizes are used; those stored before
+// versions were recorded are checked against the current sizes when their
+// packs use only those. Pack limits and request constraints are not stored,
+// so allocations they restricted are reported too. Nothing is written to
+// storage.
+func (a *Allocator) VerifyHistory(ctx context.Context, from, to time.Time) (Verification, error) {
+	if a.storage == nil {
+		return Verification{}, ErrStorageNotConfigured
+	}
+
+	// Load every profile's versions up front: the storage may not serve
+	// other queries while the export streams.
+	cfg := a.config()
+	names := []string{DefaultProfile}
+	for name := range cfg.profiles {
+		names = append(names, name)
+	}
+	versions := make(map[string]map[int][]int, len(names))
+	for _, name := range names {
+		history, err := a.storage.GetProfileVersions(name)
+		if err != nil {
+			return Verification{}, fmt.Errorf("profile %q versions: %w", name, err)
+		}
+		byVersion := make(map[int][]int, len(history))
+		for _, v := range history {
+			byVersion[v.Version] = sortedSizes(v.PackSizes)
+		}
+		versions[name] = byVersion
+	}
+
+	type solved struct {
+		sizes    string
+		quantity int
+	}
+	optimal := make(map[solved]Result)
+	var v Verification
+	err := a.storage.ExportAllocations(from, to, func(s storage.Allocation) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		sizes, ok := verifiedSizes(cfg, versions, s)
+		if !ok || s.Algorithm == storage.AlgorithmImport {
+			v.Skipped++
+			return nil
+		}
+
+		key := solved{fmt.Sprint(sizes), s.OrderQuantity}
+		best, ok := optimal[key]
+		if !ok {
+			var err error
+			best, err = a.Evaluate(ctx, s.OrderQuantity, sizes, ExactStrategy)
+			if err != nil {
+				return fmt.Errorf("allocation %d, quantity %d: %w", s.ID, s.OrderQuantity, err)
+			}
+			optimal[key] = best
+		}
+		v.Checked++
+
+		stored, opt := outcome(s.OrderQuantity, Result{Packs: s.Packs, Total: s.Total}), outcome(s.OrderQuantity, best)
+		if stored.Waste > opt.Waste || stored.Waste == opt.Waste && stored.PackCount > opt.PackCount {
+			v.Mismatches = append(v.Mismatches, Mismatch{
+				ID:             s.ID,
+				Quantity:       s.OrderQuantity,
+				Profile:        s.Profile,
+				ProfileVersion: s.ProfileVersion,
+				CreatedAt:      s.CreatedAt,
+				Stored:         stored,
+				Optimal:        opt,
+			})
+			v.ExcessWaste += stored.Waste - opt.Waste
+		}
+		return nil
+	})
+	return v, err
+}
+
+// verifiedSizes returns the pack sizes a stored allocation was computed
+// with, or false if it was pinned, fell short of its quantity for lack of
+// stock, or they are unknown.
+func verifiedSizes(cfg *snapshot, versions map[string]map[int][]int, s storage.Allocation) ([]int, bool) {
+	if s.Source == SourceManual || s.Total < s.OrderQuantity {
+		return nil, false
+	}
+	name := s.Profile
+	if name == "" {
+		name = DefaultProfile
+	}
+	var sizes []int
+	if s.ProfileVersion > 0 {
+		sizes = versions[name][s.ProfileVersion]
+	} else {
+		sizes, _ = cfg.sizes(name)
+	}
+	if len(sizes) == 0 {
+		return nil, false
+	}
+	for size, n := range s.Packs {
+		if n > 0 && !containsSize(sizes, size) {
+			return nil, false
+		}
+	}
+	return sizes, true
+}
@@ -0,0 +1,64 @@
+package allocator
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/n-th/gymshark/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyHistory(t *testing.T) {
+	store := newMockStorage()
+	a := NewAllocator([]int{250, 500, 1000}, store)
+	assert.NoError(t, a.RecordProfileVersions())
+	_, err := a.UpdateProfile(context.Background(), DefaultProfile, []int{23, 31, 53})
+	assert.NoError(t, err)
+
+	stored := []storage.AllocationInput{
+		// Optimal for version 2
+		{Quantity: 50, Packs: map[int]int{53: 1}, Total: 53, Profile: DefaultProfile, ProfileVersion: 2},
+		// Optimal for version 1, though not for the current sizes
+		{Quantity: 600, Packs: map[int]int{250: 1, 500: 1}, Total: 750, Profile: DefaultProfile, ProfileVersion: 1},
+		// Wasteful: 100 is 3x23 + 31
+		{Quantity: 100, Packs: map[int]int{53: 2}, Total: 106, Profile: DefaultProfile, ProfileVersion: 2},
+		// No waste, but 31x2 + 53 takes fewer packs
+		{Quantity: 115, Packs: map[int]int{23: 5}, Total: 115, Profile: DefaultProfile, ProfileVersion: 2},
+		// Stored before versions were recorded, checked against the current sizes
+		{Quantity: 46, Packs: map[int]int{23: 2}, Total: 46},
+		// Skipped: pinned, short of stock, an unknown version, or sizes no longer used
+		{Quantity: 60, Packs: map[int]int{53: 2}, Total: 106, Profile: DefaultProfile, ProfileVersion: 2, Source: SourceManual},
+		{Quantity: 200, Packs: map[int]int{53: 1}, Total: 53, Profile: DefaultProfile, ProfileVersion: 2},
+		{Quantity: 10, Packs: map[int]int{23: 1}, Total: 23, Profile: DefaultProfile, ProfileVersion: 7},
+		{Quantity: 5, Packs: map[int]int{250: 1}, Total: 250},
+	}
+	for _, in := range stored {
+		assert.NoError(t, store.StoreAllocationInput(in))
+	}
+
+	v, err := a.VerifyHistory(context.Background(), time.Time{}, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 5, v.Checked)
+	assert.Equal(t, 4, v.Skipped)
+	assert.Equal(t, 6, v.ExcessWaste)
+
+	sort.Slice(v.Mismatches, func(i, j int) bool { return v.Mismatches[i].Quantity < v.Mismatches[j].Quantity })
+	if assert.Len(t, v.Mismatches, 2) {
+		assert.Equal(t, 100, v.Mismatches[0].Quantity)
+		assert.Equal(t, 6, v.Mismatches[0].Stored.Waste)
+		assert.Equal(t, SimulationOutcome{Packs: map[int]int{23: 3, 31: 1}, Total: 100, Waste: 0, PackCount: 4}, v.Mismatches[0].Optimal)
+
+		assert.Equal(t, 115, v.Mismatches[1].Quantity)
+		assert.Equal(t, 5, v.Mismatches[1].Stored.PackCount)
+		assert.Equal(t, 3, v.Mismatches[1].Optimal.PackCount)
+		assert.Equal(t, 0, v.Mismatches[1].Optimal.Waste)
+	}
+
+	// Nothing was stored
+	assert.Len(t, store.allocations, len(stored))
+
+	_, err = NewAllocator([]int{1}, nil).VerifyHistory(context.Background(), time.Time{}, time.Now())
+	assert.ErrorIs(t, err, ErrStorageNotConfigured)
+}
@@ -0,0 +1,159 @@
+package allocator
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WeightProfile is the reserved profile holding the weight pack sizes, in
+// grams. Weight allocations run on it like any other profile, so they are
+// cached, limited, stored and versioned the same way.
+const WeightProfile = "weight"
+
+// ErrInvalidWeight is returned for weights that are not positive or are
+// more precise than a gram.
+var ErrInvalidWeight = invalid("invalid weight")
+
+// Weight is a fixed-point mass in grams. Weights are exchanged as decimal
+// kilograms with up to three fractional digits.
+type Weight int64
+
+const (
+	Gram     Weight = 1
+	Kilogram Weight = 1000
+)
+
+// ParseWeight parses decimal kilograms, e.g. "2.75", exactly.
+func ParseWeight(s string) (Weight, error) {
+	s = strings.TrimSpace(s)
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" || len(frac) > 3 || strings.HasPrefix(whole, "-") || strings.HasPrefix(whole, "+") {
+		return 0, fmt.Errorf("%w: %q (want kilograms with at most 3 decimals)", ErrInvalidWeight, s)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	kg, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || kg > math.MaxInt64/int64(Kilogram)-1 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidWeight, s)
+	}
+	grams := int64(0)
+	if frac != "" {
+		frac += strings.Repeat("0", 3-len(frac))
+		grams, err = strconv.ParseInt(frac, 10, 64)
+		if err != nil || strings.HasPrefix(frac, "-") || strings.HasPrefix(frac, "+") {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidWeight, s)
+		}
+	}
+	return Weight(kg)*Kilogram + Weight(grams), nil
+}
+
+// WeightFromKilograms converts kilograms to a Weight, failing if kg is not a
+// whole number of grams.
+func WeightFromKilograms(kg float64) (Weight, error) {
+	grams := kg * float64(Kilogram)
+	rounded := math.Round(grams)
+	if math.IsNaN(kg) || math.IsInf(kg, 0) || math.Abs(grams-rounded) > 1e-6 || math.Abs(rounded) > float64(math.MaxInt64/2) {
+		return 0, fmt.Errorf("%w: %v kg (want at most 3 decimals)", ErrInvalidWeight, kg)
+	}
+	return Weight(rounded), nil
+}
+
+// Kilograms returns w in kilograms.
+func (w Weight) Kilograms() float64 {
+	return float64(w) / float64(Kilogram)
+}
+
+// String formats w as decimal kilograms without trailing zeros, e.g. "2.5".
+func (w Weight) String() string {
+	sign := ""
+	if w < 0 {
+		sign, w = "-", -w
+	}
+	kg, grams := w/Kilogram, w%Kilogram
+	if grams == 0 {
+		return fmt.Sprintf("%s%d", sign, kg)
+	}
+	return strings.TrimRight(fmt.Sprintf("%s%d.%03d", sign, kg, grams), "0")
+}
+
+// SetWeightPackSizes configures the pack sizes used by AllocateWeight.
+func (a *Allocator) SetWeightPackSizes(sizes []Weight) error {
+	grams := make([]int, len(sizes))
+	for i, size := range sizes {
+		if size <= 0 || int64(size) > math.MaxInt32 {
+			return fmt.Errorf("%w: pack size %s kg", ErrInvalidWeight, size)
+		}
+		grams[i] = int(size)
+	}
+	if len(grams) == 0 {
+		return fmt.Errorf("profile %q: %w", WeightProfile, ErrNoPackSizes)
+	}
+
+	return a.updateConfig(func(next *snapshot) error {
+		profiles := make(map[string][]int, len(next.profiles)+1)
+		for name, s := range next.profiles {
+			profiles[name] = s
+		}
+		profiles[WeightProfile] = sortedSizes(grams)
+		next.profiles = profiles
+		return nil
+	})
+}
+
+// WeightRequest describes a calculation for a quantity by weight.
+type WeightRequest struct {
+	Quantity   Weight
+	Strategy   string
+	OrderID    string
+	CustomerID string
+	Metadata   map[string]interface{}
+}
+
+// WeightResult is the pack distribution for a quantity by weight.
+type WeightResult struct {
+	// Packs maps pack weight to the number of packs of that weight.
+	Packs       map[Weight]int
+	Total       Weight
+	Approximate bool
+	// ComputedAt is as for Result.
+	ComputedAt time.Time
+	Stats      Stats
+}
+
+// AllocateWeight computes the pack distribution for a quantity by weight
+// using the weight pack sizes, and persists it like Allocate. Quantities
+// are stored in grams under WeightProfile.
+func (a *Allocator) AllocateWeight(ctx context.Context, req WeightRequest) (WeightResult, error) {
+	if req.Quantity <= 0 {
+		return WeightResult{}, ErrInvalidQuantity
+	}
+	if int64(req.Quantity) > math.MaxInt32 {
+		return WeightResult{}, fmt.Errorf("%w: %s kg is too large", ErrInvalidWeight, req.Quantity)
+	}
+	if _, err := a.profileSizes(WeightProfile); err != nil {
+		return WeightResult{}, fmt.Errorf("no weight pack sizes configured: %w", err)
+	}
+
+	result, err := a.Allocate(ctx, Request{
+		Quantity:   int(req.Quantity),
+		Strategy:   req.Strategy,
+		Profile:    WeightProfile,
+		OrderID:    req.OrderID,
+		CustomerID: req.CustomerID,
+		Metadata:   req.Metadata,
+	})
+	if err != nil {
+		return WeightResult{}, err
+	}
+
+	packs := make(map[Weight]int, len(result.Packs))
+	for size, n := range result.Packs {
+		packs[Weight(size)] = n
+	}
+	return WeightResult{Packs: packs, Total: Weight(result.Total), Approximate: result.Approximate, ComputedAt: result.ComputedAt, Stats: result.Stats}, nil
+}
@@ -0,0 +1,122 @@
+package allocator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWeight(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Weight
+		wantErr bool
+	}{
+		{in: "2", want: 2 * Kilogram},
+		{in: "2.75", want: 2750},
+		{in: "0.005", want: 5},
+		{in: ".5", want: 500},
+		{in: "1.", want: Kilogram},
+		{in: " 3.1 ", want: 3100},
+		{in: "0", want: 0},
+		{in: "1.2345", wantErr: true},
+		{in: "-1", wantErr: true},
+		{in: "+1", wantErr: true},
+		{in: "1.-5", wantErr: true},
+		{in: "1e3", wantErr: true},
+		{in: ".", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseWeight(tt.in)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidWeight)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWeightFromKilograms(t *testing.T) {
+	w, err := WeightFromKilograms(0.1)
+	assert.NoError(t, err)
+	assert.Equal(t, Weight(100), w)
+
+	w, err = WeightFromKilograms(2.5)
+	assert.NoError(t, err)
+	assert.Equal(t, Weight(2500), w)
+
+	_, err = WeightFromKilograms(0.0001)
+	assert.ErrorIs(t, err, ErrInvalidWeight)
+}
+
+func TestWeightString(t *testing.T) {
+	assert.Equal(t, "2", (2 * Kilogram).String())
+	assert.Equal(t, "2.5", Weight(2500).String())
+	assert.Equal(t, "0.005", Weight(5).String())
+	assert.Equal(t, "-1.25", Weight(-1250).String())
+}
+
+func TestAllocateWeight(t *testing.T) {
+	store := newMockStorage()
+	a := NewAllocator([]int{23, 31, 53}, store)
+	assert.NoError(t, a.SetWeightPackSizes([]Weight{500, Kilogram, 2500}))
+
+	result, err := a.AllocateWeight(context.Background(), WeightRequest{Quantity: 3200})
+	assert.NoError(t, err)
+	assert.Equal(t, map[Weight]int{2500: 1, Kilogram: 1}, result.Packs)
+	assert.Equal(t, Weight(3500), result.Total)
+
+	stored := store.allocations[3200]
+	if assert.NotNil(t, stored) {
+		assert.Equal(t, WeightProfile, stored.Profile)
+	}
+
+	// Unit allocations are unaffected.
+	units, err := a.Allocate(context.Background(), Request{Quantity: 50})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{53: 1}, units.Packs)
+
+	_, err = a.AllocateWeight(context.Background(), WeightRequest{Quantity: 0})
+	assert.ErrorIs(t, err, ErrInvalidQuantity)
+}
+
+func TestAllocateWeightWithoutSizes(t *testing.T) {
+	a := NewAllocator([]int{23, 31, 53}, newMockStorage())
+	_, err := a.AllocateWeight(context.Background(), WeightRequest{Quantity: Kilogram})
+	assert.ErrorIs(t, err, ErrUnknownProfile)
+}
+
+func TestSetWeightPackSizes(t *testing.T) {
+	a := NewAllocator([]int{23, 31, 53}, newMockStorage())
+	assert.ErrorIs(t, a.SetWeightPackSizes([]Weight{500, 0}), ErrInvalidWeight)
+	assert.ErrorIs(t, a.SetWeightPackSizes(nil), ErrNoPackSizes)
+
+	assert.NoError(t, a.SetWeightPackSizes([]Weight{500}))
+	assert.Error(t, a.SetProfiles(map[string][]int{WeightProfile: {1}}, nil))
+
+	// Replacing the unit profiles keeps the weight sizes.
+	assert.NoError(t, a.SetProfiles(map[string][]int{"apparel": {250, 500}}, nil))
+	sizes, err := a.profileSizes(WeightProfile)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{500}, sizes)
+}
+
+func TestCalculatePacksOptimizedIgnoresWeightResults(t *testing.T) {
+	store := newMockStorage()
+	a := NewAllocator([]int{250, 500, 1000}, store)
+	assert.NoError(t, a.SetWeightPackSizes([]Weight{300}))
+
+	_, err := a.AllocateWeight(context.Background(), WeightRequest{Quantity: 600})
+	assert.NoError(t, err)
+
+	packs, total, err := a.CalculatePacksOptimized(600)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{250: 1, 500: 1}, packs)
+	assert.Equal(t, 750, total)
+}
@@ -0,0 +1,430 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/allocator"
+)
+
+// @Summary Prune allocation history
+// @Description Remove stored allocations outside the retention policy. max_age and max_rows override the configured policy for this run.
+// @Tags admin
+// @Produce json
+// @Param max_age query string false "Remove allocations older than this duration, e.g. 720h"
+// @Param max_rows query int false "Keep only this many of the most recent allocations"
+// @Success 200 {object} storage.PruneResult "Number of allocations removed"
+// @Failure 400 {object} ErrorResponse "Error message"
+// @Failure 500 {object} ErrorResponse "Error message"
+// @Failure 503 {object} ErrorResponse "Read-only mode"
+// @Router /v1/admin/prune [post]
+func (h *Handler) pruneAllocations(c *gin.Context) {
+	policy := h.allocator.Retention()
+	if v := c.Query("max_age"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid max_age"})
+			return
+		}
+		policy.MaxAge = d
+	}
+	if v := c.Query("max_rows"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid max_rows"})
+			return
+		}
+		policy.MaxRows = n
+	}
+	if !policy.Enabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no retention policy configured; pass max_age or max_rows"})
+		return
+	}
+
+	result, err := h.allocator.Prune(policy)
+	if err != nil {
+		writeDomainError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// @Summary Precompute allocations
+// @Description Compute and store the allocation of every quantity in [from, to] with one dynamic-programming pass, so calculation.result_cache serves them. Quantities already stored for the current profile version, or pinned, are skipped. Ranges end at 1000000 at most.
+// @Tags admin
+// @Produce json
+// @Param from query int true "First quantity"
+// @Param to query int true "Last quantity"
+// @Param profile query string false "Profile name (default when empty)"
+// @Success 200 {object} allocator.PrecomputeResult "Allocations stored and skipped"
+// @Failure 400 {object} ErrorResponse "Error message"
+// @Failure 422 {object} ErrorResponse "Range exceeds the quantity limit"
+// @Failure 500 {object} ErrorResponse "Error message"
+// @Failure 503 {object} ErrorResponse "Read-only mode"
+// @Failure 504 {object} ErrorResponse "Request timeout; allocations stored so far are kept"
+// @Router /v1/admin/precompute [post]
+func (h *Handler) precompute(c *gin.Context) {
+	from, err := strconv.Atoi(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from"})
+		return
+	}
+	to, err := strconv.Atoi(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to"})
+		return
+	}
+
+	result, err := h.allocator.Precompute(c.Request.Context(), from, to, c.Query("profile"))
+	if err != nil {
+		writeAllocationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// readOnlyRequest is the body accepted by POST /admin/read-only.
+type readOnlyRequest struct {
+	Enabled bool   `json:"enabled"`
+	Mode    string `json:"mode" binding:"omitempty,oneof=skip reject"`
+}
+
+// @Summary Get read-only state
+// @Description Report whether the service is in read-only (maintenance) mode and how writes are handled
+// @Tags admin
+// @Produce json
+// @Success 200 {object} ReadOnlyResponse "Read-only state"
+// @Router /v1/admin/read-only [get]
+func (h *Handler) getReadOnly(c *gin.Context) {
+	c.JSON(http.StatusOK, readOnlyResponse(h.allocator.ReadOnly()))
+}
+
+// @Summary Get the allocation outbox state
+// @Description Report allocation writes the storage failed: pending ones queued for retry, ones persisted on a retry and ones dropped from the history
+// @Tags admin
+// @Produce json
+// @Success 200 {object} OutboxResponse "Outbox counters"
+// @Router /v1/admin/outbox [get]
+func (h *Handler) getOutbox(c *gin.Context) {
+	stats := h.allocator.OutboxStats()
+	c.JSON(http.StatusOK, OutboxResponse{
+		Pending:   stats.Pending,
+		Persisted: stats.Persisted,
+		Failed:    stats.Failed,
+		LastError: stats.LastError,
+	})
+}
+
+// @Summary Set read-only state
+// @Description Switch read-only (maintenance) mode. In "skip" mode calculations are served but not stored; in "reject" mode they fail with 503.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body readOnlyRequest true "Read-only state; mode is skip (default) or reject"
+// @Success 200 {object} ReadOnlyResponse "Read-only state"
+// @Failure 400 {object} ValidationErrorResponse "Invalid fields"
+// @Router /v1/admin/read-only [post]
+func (h *Handler) setReadOnly(c *gin.Context) {
+	var body readOnlyRequest
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	state := allocator.ReadOnly{Enabled: body.Enabled, Mode: allocator.ReadOnlyMode(body.Mode)}
+	if err := h.allocator.SetReadOnly(state); err != nil {
+		writeDomainError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, readOnlyResponse(h.allocator.ReadOnly()))
+}
+
+func readOnlyResponse(r allocator.ReadOnly) ReadOnlyResponse {
+	return ReadOnlyResponse{Enabled: r.Enabled, Mode: string(r.Mode)}
+}
+
+// @Summary Back up the database
+// @Description Stream a consistent snapshot of the allocation database: a SQLite database file, or a bbolt file with storage.backend bolt. Writes continue while it is taken. Restore it with "gymshark restore".
+// @Tags admin
+// @Produce octet-stream
+// @Success 200 {file} file "Database snapshot"
+// @Failure 500 {object} ErrorResponse "Error message"
+// @Router /v1/admin/backup [post]
+func (h *Handler) backup(c *gin.Context) {
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="gymshark-%s.backup"`, time.Now().UTC().Format("20060102T150405Z")))
+	if err := h.allocator.Backup(c.Writer); err != nil {
+		if c.Writer.Written() {
+			// Headers are already sent, so the best we can do is truncate the stream.
+			log.Printf("Backup failed: %v", err)
+			return
+		}
+		c.Writer.Header().Del("Content-Type")
+		c.Writer.Header().Del("Content-Disposition")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// @Summary Purge cached outcomes
+// @Description Drop every cached calculation outcome on this instance and, when cache invalidation is configured, on every replica
+// @Tags admin
+// @Produce json
+// @Success 200 {object} PurgeResponse "Cache purged"
+// @Failure 502 {object} ErrorResponse "Purged locally but not propagated"
+// @Router /v1/admin/cache/purge [post]
+func (h *Handler) purgeCache(c *gin.Context) {
+	if err := h.allocator.PurgeCache(c.Request.Context()); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, PurgeResponse{Purged: true})
+}
+
+// @Summary Get the running configuration
+// @Description Report the strategy, the pack sizes, limits, dimensions, SKUs and defaults of every profile, the request limits, the read-only state and the cartons, including changes made at runtime
+// @Tags admin
+// @Produce json
+// @Success 200 {object} ConfigResponse "Running configuration"
+// @Router /v1/admin/config [get]
+func (h *Handler) getConfig(c *gin.Context) {
+	profiles := h.allocator.Profiles()
+	response := ConfigResponse{
+		Strategy:     h.allocator.Strategy(),
+		Profiles:     make([]ProfileResponse, 0, len(profiles)),
+		MaxQuantity:  h.allocator.Limits().MaxQuantity,
+		MaxBatchSize: h.allocator.Limits().MaxBatchSize,
+		ReadOnly:     readOnlyResponse(h.allocator.ReadOnly()),
+		Cartons:      cartonsResponse(h.allocator.Cartons()),
+	}
+	for _, p := range profiles {
+		response.Profiles = append(response.Profiles, ProfileResponse{
+			Name:       p.Name,
+			PackSizes:  p.PackSizes,
+			Version:    p.Version,
+			Limits:     p.Limits,
+			Deprecated: p.Deprecated,
+			Dimensions: dimensionsResponse(p.Dimensions),
+			SKUs:       p.SKUs,
+			Defaults:   profileDefaultsResponse(p.Defaults),
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Get cache statistics
+// @Description Report whether the result cache is enabled, the negative cache entries and infeasible quantities held on this instance, and the number of pinned allocations
+// @Tags admin
+// @Produce json
+// @Success 200 {object} CacheStatsResponse "Cache statistics"
+// @Router /v1/admin/cache [get]
+func (h *Handler) getCacheStats(c *gin.Context) {
+	stats := h.allocator.CacheStats()
+	response := CacheStatsResponse{
+		ResultCache:          stats.ResultCache,
+		NegativeEntries:      stats.NegativeEntries,
+		InfeasibleQuantities: stats.InfeasibleQuantities,
+		Pins:                 stats.Pins,
+	}
+	if stats.NegativeTTL > 0 {
+		response.NegativeTTL = stats.NegativeTTL.String()
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Get shadow comparisons
+// @Description Report how often the strategy run in shadow, on a sample of calculations, disagreed with the results answered since this instance started, and the latest disagreements. All counts are zero when shadowing is disabled.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} ShadowResponse "Shadow comparisons"
+// @Router /v1/admin/shadow [get]
+func (h *Handler) getShadow(c *gin.Context) {
+	stats := h.allocator.ShadowStats()
+	response := ShadowResponse{
+		Strategy:    stats.Strategy,
+		Rate:        stats.Rate,
+		Comparisons: stats.Comparisons,
+		Mismatches:  stats.Mismatches,
+		Errors:      stats.Errors,
+		Skipped:     stats.Skipped,
+		Recent:      make([]ShadowMismatchResponse, len(stats.Recent)),
+	}
+	if stats.Comparisons > 0 {
+		response.MismatchRate = float64(stats.Mismatches) / float64(stats.Comparisons)
+	}
+	for i, m := range stats.Recent {
+		response.Recent[i] = ShadowMismatchResponse{
+			Time:              m.Time,
+			Profile:           m.Profile,
+			Quantity:          m.Quantity,
+			Strategy:          m.Strategy,
+			Packs:             m.Packs,
+			Total:             m.Total,
+			Approximate:       m.Approximate,
+			ShadowStrategy:    m.ShadowStrategy,
+			ShadowPacks:       m.ShadowPacks,
+			ShadowTotal:       m.ShadowTotal,
+			ShadowApproximate: m.ShadowApproximate,
+			Verdict:           m.Verdict,
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// profileUpdateRequest is the body accepted by PUT /admin/profiles/{name}.
+type profileUpdateRequest struct {
+	PackSizes []int `json:"pack_sizes" binding:"required,min=1,dive,gt=0"`
+}
+
+// @Summary Update a pack-size profile
+// @Description Replace the pack sizes of a profile at runtime ("default" for the configured pack sizes), record a new profile version and purge cached outcomes. The update is propagated to every replica when cache invalidation is configured. When profile_guardrail is configured, the profile's latest allocations are replayed with the new sizes first, and an update raising their waste rate by more than max_waste_increase percentage points is rejected unless forced.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param name path string true "Profile name"
+// @Param force query bool false "Update even if waste rises beyond the guardrail"
+// @Param request body profileUpdateRequest true "New pack sizes"
+// @Success 200 {object} ProfileUpdateResponse "Updated profile"
+// @Failure 400 {object} ValidationErrorResponse "Invalid fields"
+// @Failure 409 {object} ProfileGuardrailResponse "Waste rises beyond the guardrail"
+// @Failure 500 {object} ErrorResponse "Error message"
+// @Failure 502 {object} ErrorResponse "Updated locally but not propagated"
+// @Failure 503 {object} ErrorResponse "Read-only mode"
+// @Router /v1/admin/profiles/{name} [put]
+func (h *Handler) updateProfile(c *gin.Context) {
+	var body profileUpdateRequest
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	force := false
+	if v := c.Query("force"); v != "" {
+		var err error
+		if force, err = strconv.ParseBool(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid force"})
+			return
+		}
+	}
+
+	name := c.Param("name")
+	impact, err := h.allocator.ProfileImpact(c.Request.Context(), name, body.PackSizes)
+	if err != nil {
+		writeDomainError(c, err)
+		return
+	}
+	if impact != nil && impact.Exceeded && !force {
+		c.JSON(http.StatusConflict, ProfileGuardrailResponse{
+			Error: fmt.Sprintf("waste rate would rise by %.2f percentage points, more than the maximum of %.2f; pass force=true to update anyway",
+				impact.WasteIncrease, impact.MaxWasteIncrease),
+			Impact: profileImpactResponse(*impact),
+		})
+		return
+	}
+
+	version, err := h.allocator.UpdateProfile(c.Request.Context(), name, body.PackSizes)
+	if err != nil {
+		writeDomainError(c, err)
+		return
+	}
+	response := ProfileUpdateResponse{Name: name, Version: version, PackSizes: body.PackSizes}
+	if impact != nil {
+		if impact.Exceeded {
+			log.Printf("Profile %s updated despite waste rising by %.2f percentage points", name, impact.WasteIncrease)
+		}
+		r := profileImpactResponse(*impact)
+		response.Impact = &r
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func profileImpactResponse(i allocator.ProfileImpact) ProfileImpactResponse {
+	return ProfileImpactResponse{
+		CurrentSizes:     i.CurrentSizes,
+		Allocations:      i.Allocations,
+		Current:          simulationTotalsResponse(i.Current),
+		Updated:          simulationTotalsResponse(i.Updated),
+		CurrentWasteRate: i.CurrentWasteRate,
+		WasteRate:        i.WasteRate,
+		WasteIncrease:    i.WasteIncrease,
+		MaxWasteIncrease: i.MaxWasteIncrease,
+		Exceeded:         i.Exceeded,
+	}
+}
+
+// profileDefaultsRequest is the body accepted by
+// PUT /admin/profiles/{name}/defaults. Fields left out are left to the
+// allocator; an empty body removes the profile's defaults.
+type profileDefaultsRequest struct {
+	Strategy string          `json:"strategy" example:"dp"`
+	Weights  *weightsRequest `json:"weights,omitempty" binding:"omitempty"`
+	// Timeout is a duration such as "2s" replacing the soft timeout.
+	Timeout string `json:"timeout" example:"2s"`
+}
+
+// @Summary Set the defaults of a pack-size profile
+// @Description Store the strategy, objective weights and soft timeout applied to the profile's calculations that do not set them ("default" for the configured pack sizes), replacing its previous defaults; an empty body removes them. Weights apply to requests naming no strategy and are solved with branchbound, so they cannot be combined with a strategy. The change is propagated to every replica when cache invalidation is configured.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param name path string true "Profile name"
+// @Param request body profileDefaultsRequest true "Profile defaults"
+// @Success 200 {object} ProfileDefaultsResponse "Stored defaults"
+// @Failure 400 {object} ErrorResponse "Invalid defaults or unknown profile"
+// @Failure 500 {object} ErrorResponse "Error message"
+// @Failure 502 {object} ErrorResponse "Set locally but not propagated"
+// @Failure 503 {object} ErrorResponse "Read-only mode"
+// @Router /v1/admin/profiles/{name}/defaults [put]
+func (h *Handler) setProfileDefaults(c *gin.Context) {
+	var body profileDefaultsRequest
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	defaults := allocator.ProfileDefaults{Strategy: body.Strategy}
+	if body.Weights != nil {
+		defaults.Weights = &allocator.Weights{
+			Waste: body.Weights.Waste,
+			Packs: body.Weights.Packs,
+			Cost:  body.Weights.Cost,
+		}
+	}
+	if body.Timeout != "" {
+		d, err := time.ParseDuration(body.Timeout)
+		if err != nil || d < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timeout"})
+			return
+		}
+		defaults.Timeout = d
+	}
+
+	defaults, err := h.allocator.SetProfileDefaults(c.Request.Context(), c.Param("name"), defaults)
+	if err != nil {
+		writeDomainError(c, err)
+		return
+	}
+	response := profileDefaultsResponse(defaults)
+	if response == nil {
+		response = &ProfileDefaultsResponse{}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// profileDefaultsResponse returns the response for d, nil if d is zero.
+func profileDefaultsResponse(d allocator.ProfileDefaults) *ProfileDefaultsResponse {
+	if d.IsZero() {
+		return nil
+	}
+	response := &ProfileDefaultsResponse{Strategy: d.Strategy}
+	if w := d.Weights; w != nil {
+		response.Weights = &WeightsResponse{Waste: w.Waste, Packs: w.Packs, Cost: w.Cost}
+	}
+	if d.Timeout > 0 {
+		response.Timeout = d.Timeout.String()
+	}
+	if !d.UpdatedAt.IsZero() {
+		response.UpdatedAt = &d.UpdatedAt
+	}
+	return response
+}
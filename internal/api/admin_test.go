@@ -0,0 +1,416 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPruneAllocations(t *testing.T) {
+	router, handler := setupTestRouter()
+	for _, q := range []string{"10", "50", "100"} {
+		req := httptest.NewRequest("GET", "/calculate?quantity="+q, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		time.Sleep(time.Millisecond)
+	}
+
+	// No policy configured and none given
+	req := httptest.NewRequest("POST", "/admin/prune", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	for _, query := range []string{"max_age=soon", "max_rows=0", "max_rows=x"} {
+		req = httptest.NewRequest("POST", "/admin/prune?"+query, nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+
+	req = httptest.NewRequest("POST", "/admin/prune?max_rows=1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var result storage.PruneResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, storage.PruneResult{Excess: 2}, result)
+
+	// The configured policy applies when no overrides are given
+	handler.allocator.SetRetention(storage.RetentionPolicy{MaxAge: time.Nanosecond})
+	req = httptest.NewRequest("POST", "/admin/prune", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, storage.PruneResult{Expired: 1}, result)
+}
+
+func TestReadOnlyMode(t *testing.T) {
+	router, handler := setupTestRouter()
+
+	req := httptest.NewRequest("GET", "/admin/read-only", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"enabled": false, "mode": "skip"}`, w.Body.String())
+
+	setReadOnly := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/admin/read-only", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Skip mode serves calculations without storing them
+	w = setReadOnly(`{"enabled": true}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"enabled": true, "mode": "skip"}`, w.Body.String())
+
+	req = httptest.NewRequest("POST", "/calculate", strings.NewReader(`{"quantity": 50, "order_id": "ORD-RO"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	stored, err := handler.allocator.GetAllocationsByOrderID("ORD-RO")
+	assert.NoError(t, err)
+	assert.Empty(t, stored)
+
+	req = httptest.NewRequest("POST", "/admin/prune?max_rows=1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	// Reject mode answers calculations with 503
+	w = setReadOnly(`{"enabled": true, "mode": "reject"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req = httptest.NewRequest("GET", "/calculate?quantity=50", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	w = setReadOnly(`{"enabled": true, "mode": "later"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = setReadOnly(`{"enabled": false}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	req = httptest.NewRequest("GET", "/calculate?quantity=50", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestUpdateProfile(t *testing.T) {
+	router, handler := setupTestRouter()
+
+	update := func(name, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/admin/profiles/"+name, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := update("default", `{"pack_sizes": [250, 500, 1000]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"name": "default", "version": 1, "pack_sizes": [250, 500, 1000]}`, w.Body.String())
+
+	req := httptest.NewRequest("GET", "/calculate?quantity=251", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"packs": {"500": 1}, "total": 500, "approximate": false, "cached": false}`, withoutComputedAt(t, w.Body.String()))
+
+	w = update("default", `{"pack_sizes": []}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = update("default", `{"pack_sizes": [0]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	assert.NoError(t, handler.allocator.SetReadOnly(allocator.ReadOnly{Enabled: true}))
+	w = update("default", `{"pack_sizes": [10]}`)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestUpdateProfileGuardrail(t *testing.T) {
+	router, handler := setupTestRouter()
+	assert.NoError(t, handler.allocator.SetProfileGuardrail(allocator.ProfileGuardrail{MaxWasteIncrease: 5}))
+	for _, q := range []string{"23", "31", "46", "53"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/calculate?quantity="+q, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	packsFor := func(quantity string) string {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/calculate?quantity="+quantity, nil))
+		var response struct {
+			Packs json.RawMessage `json:"packs"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return string(response.Packs)
+	}
+
+	update := func(query, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/admin/profiles/default"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Packs of 50 waste 97 of the 153 items the four allocations ordered.
+	w := update("", `{"pack_sizes": [50]}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	var rejected ProfileGuardrailResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rejected))
+	assert.Contains(t, rejected.Error, "force=true")
+	assert.Equal(t, 4, rejected.Impact.Allocations)
+	assert.Equal(t, []int{53, 31, 23}, rejected.Impact.CurrentSizes)
+	assert.Equal(t, 97, rejected.Impact.Updated.TotalWaste)
+	assert.InDelta(t, 63.40, rejected.Impact.WasteIncrease, 0.01)
+	assert.True(t, rejected.Impact.Exceeded)
+	assert.Equal(t, `{"53":1}`, packsFor("50"))
+
+	w = update("?force=maybe", `{"pack_sizes": [50]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = update("?force=true", `{"pack_sizes": [50]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var updated ProfileUpdateResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.Equal(t, 1, updated.Version)
+	if assert.NotNil(t, updated.Impact) {
+		assert.True(t, updated.Impact.Exceeded)
+	}
+	assert.Equal(t, `{"50":1}`, packsFor("50"))
+}
+
+func TestSetProfileDefaults(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	set := func(name, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/admin/profiles/"+name+"/defaults", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	packsFor := func(quantity string) string {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/calculate?quantity="+quantity, nil))
+		var response struct {
+			Packs json.RawMessage `json:"packs"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return string(response.Packs)
+	}
+
+	assert.JSONEq(t, `{"23": 3, "31": 1}`, packsFor("100"))
+
+	// Packs weighing 1000 items of waste trade 6 items for two packs fewer.
+	w := set("default", `{"weights": {"w_waste": 1, "w_packs": 1000}, "timeout": "2s"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, map[string]interface{}{"w_waste": 1.0, "w_packs": 1000.0, "w_cost": 0.0}, response["weights"])
+	assert.Equal(t, "2s", response["timeout"])
+	assert.NotEmpty(t, response["updated_at"])
+	assert.JSONEq(t, `{"53": 2}`, packsFor("100"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/config", nil))
+	assert.Contains(t, w.Body.String(), `"timeout":"2s"`)
+
+	for _, body := range []string{
+		`{"timeout": "soon"}`,
+		`{"timeout": "-1s"}`,
+		`{"strategy": "magic"}`,
+		`{"strategy": "dp", "weights": {"w_packs": 1}}`,
+		`{"weights": {"w_waste": -1}}`,
+	} {
+		assert.Equal(t, http.StatusBadRequest, set("default", body).Code, body)
+	}
+	assert.Equal(t, http.StatusBadRequest, set("apparel", `{"strategy": "dp"}`).Code)
+
+	// An empty body removes the defaults.
+	w = set("default", `{}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{}`, w.Body.String())
+	assert.JSONEq(t, `{"23": 3, "31": 1}`, packsFor("100"))
+}
+
+func TestPrecompute(t *testing.T) {
+	router, handler := setupTestRouter()
+	assert.NoError(t, handler.allocator.RecordProfileVersions())
+	handler.allocator.SetLimits(allocator.Limits{MaxQuantity: 1000})
+
+	req := httptest.NewRequest("POST", "/admin/precompute?from=1&to=100", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var result allocator.PrecomputeResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, allocator.PrecomputeResult{Profile: "default", From: 1, To: 100, Stored: 100}, result)
+
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{"to=100", http.StatusBadRequest},
+		{"from=1&to=x", http.StatusBadRequest},
+		{"from=100&to=1", http.StatusBadRequest},
+		{"from=1&to=100&profile=missing", http.StatusBadRequest},
+		{"from=1&to=2000", http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/admin/precompute?"+tt.query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, tt.status, w.Code, tt.query)
+	}
+}
+
+func TestPurgeCache(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	req := httptest.NewRequest("POST", "/admin/cache/purge", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"purged": true}`, w.Body.String())
+}
+
+func TestGetOutbox(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	req := httptest.NewRequest("GET", "/admin/outbox", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"pending":0,"persisted":0,"failed":0}`, w.Body.String())
+}
+
+func TestGetConfig(t *testing.T) {
+	router, handler := setupTestRouter()
+	assert.NoError(t, handler.allocator.SetProfiles(map[string][]int{"bulk": {1000, 500}}, map[string]string{"SKU-2": "bulk", "SKU-1": "bulk"}))
+	assert.NoError(t, handler.allocator.SetPackLimits(map[string]map[int]int{"bulk": {1000: 2}}))
+	assert.NoError(t, handler.allocator.RecordProfileVersions())
+	handler.allocator.SetLimits(allocator.Limits{MaxQuantity: 1000000})
+
+	req := httptest.NewRequest("GET", "/v1/admin/config", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"strategy": "dp",
+		"profiles": [
+			{"name": "default", "pack_sizes": [53, 31, 23], "version": 1},
+			{"name": "bulk", "pack_sizes": [1000, 500], "version": 1, "limits": {"1000": 2}, "skus": ["SKU-1", "SKU-2"]}
+		],
+		"max_quantity": 1000000,
+		"max_batch_size": 0,
+		"read_only": {"enabled": false, "mode": "skip"}
+	}`, w.Body.String())
+}
+
+func TestGetCacheStats(t *testing.T) {
+	allocator.RegisterStrategy("never", allocator.StrategyFunc(func(context.Context, int, []int) (allocator.Result, error) {
+		return allocator.Result{}, allocator.ErrNoCombination
+	}))
+
+	router, handler := setupTestRouter()
+	handler.allocator.SetNegativeCacheTTL(time.Minute)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/calculate?quantity=7&strategy=never", nil))
+
+	req := httptest.NewRequest("GET", "/v1/admin/cache", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"result_cache": false,
+		"negative_ttl": "1m0s",
+		"negative_entries": 1,
+		"infeasible_quantities": 1,
+		"pins": 0
+	}`, w.Body.String())
+}
+
+func TestAdminUI(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	req := httptest.NewRequest("GET", "/admin", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "Pack Allocation Admin")
+	// The page calls the versioned admin routes relative to itself
+	assert.Contains(t, w.Body.String(), `"v1/" + path`)
+}
+
+func TestBackup(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/admin/backup", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/octet-stream", w.Header().Get("Content-Type"))
+	assert.Regexp(t, `^attachment; filename="gymshark-\d{8}T\d{6}Z\.backup"$`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "backup", w.Body.String())
+
+	gin.SetMode(gin.TestMode)
+	router = gin.New()
+	NewHandler(allocator.NewAllocator([]int{23, 31, 53}, nil)).RegisterRoutes(router)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/admin/backup", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Empty(t, w.Header().Get("Content-Disposition"))
+}
+
+func TestGetShadow(t *testing.T) {
+	router, handler := setupTestRouter()
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/admin/shadow", nil))
+		return w
+	}
+	w := get()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"rate":0,"comparisons":0,"mismatches":0,"mismatch_rate":0,"errors":0,"skipped":0,"recent":[]}`, w.Body.String())
+
+	assert.NoError(t, handler.allocator.SetShadow(allocator.Shadow{Strategy: "greedy", Rate: 1}))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/calculate?quantity=62&strategy=dp", nil))
+	assert.Eventually(t, func() bool {
+		return len(handler.allocator.ShadowStats().Recent) == 1
+	}, time.Second, time.Millisecond)
+
+	var response ShadowResponse
+	w = get()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "greedy", response.Strategy)
+	assert.Equal(t, int64(1), response.Mismatches)
+	assert.Equal(t, 1.0, response.MismatchRate)
+	if assert.Len(t, response.Recent, 1) {
+		m := response.Recent[0]
+		assert.Equal(t, "dp", m.Strategy)
+		assert.Equal(t, map[int]int{31: 2}, m.Packs)
+		assert.Equal(t, map[int]int{53: 1, 23: 1}, m.ShadowPacks)
+		assert.Equal(t, allocator.VerdictWorse, m.Verdict)
+	}
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/auth"
+)
+
+// subjectKey is the context key under which authorize passes the subject
+// of a verified token to the audit log.
+const subjectKey = "subject"
+
+// AdminVerifier verifies the bearer tokens of admin requests; see
+// auth.Verifier.
+type AdminVerifier interface {
+	Verify(ctx context.Context, token string) (auth.Claims, error)
+}
+
+// SetAdminAuth requires a bearer token verified by v on the /admin routes:
+// with the reader role to read them, and the admin role to change anything,
+// including pins and deleting or restoring allocations. Listing deleted
+// allocations takes the reader role. Without it these routes are open. It
+// must be called before RegisterRoutes.
+func (h *Handler) SetAdminAuth(v AdminVerifier) {
+	h.adminAuth = v
+}
+
+// requireRole rejects requests without a bearer token granting role, when
+// admin authentication is configured.
+func (h *Handler) requireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if h.authorize(c, role) {
+			c.Next()
+		}
+	}
+}
+
+// authorize reports whether the request carries a bearer token granting
+// role, or admin authentication is not configured. Otherwise it aborts the
+// request with 401, 403 or 503 and returns false. Handlers use it directly
+// for options only some callers may use.
+func (h *Handler) authorize(c *gin.Context, role string) bool {
+	if h.adminAuth == nil {
+		return true
+	}
+
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if token = strings.TrimSpace(token); !ok || token == "" {
+		c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+		c.Abort()
+		writeError(c, http.StatusUnauthorized, codeUnauthorized)
+		return false
+	}
+	claims, err := h.adminAuth.Verify(c.Request.Context(), token)
+	switch {
+	case errors.Is(err, auth.ErrInvalidToken):
+		c.Header("WWW-Authenticate", `Bearer realm="admin", error="invalid_token"`)
+		c.Abort()
+		writeError(c, http.StatusUnauthorized, codeUnauthorized)
+		return false
+	case err != nil:
+		log.Printf("Verifying admin token failed: %v", err)
+		c.Abort()
+		writeError(c, http.StatusServiceUnavailable, codeAuthUnavailable)
+		return false
+	case !claims.HasRole(role):
+		c.Header("WWW-Authenticate", `Bearer realm="admin", error="insufficient_scope"`)
+		c.Abort()
+		writeError(c, http.StatusForbidden, codeForbidden, role)
+		return false
+	}
+	c.Set(subjectKey, claims.Subject)
+	return true
+}
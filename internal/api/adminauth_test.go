@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/auth"
+	"github.com/n-th/gymshark/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeVerifier grants the claims keyed by token.
+type fakeVerifier struct {
+	tokens map[string]auth.Claims
+	err    error
+}
+
+func (v fakeVerifier) Verify(ctx context.Context, token string) (auth.Claims, error) {
+	if v.err != nil {
+		return auth.Claims{}, v.err
+	}
+	claims, ok := v.tokens[token]
+	if !ok {
+		return auth.Claims{}, fmt.Errorf("%w: bad signature", auth.ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+func setupAdminAuthRouter(v AdminVerifier) (*gin.Engine, *Handler) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler := NewHandler(allocator.NewAllocator([]int{23, 31, 53}, newMockStorage()))
+	handler.SetAdminAuth(v)
+	handler.RegisterRoutes(router)
+	return router, handler
+}
+
+func TestAdminAuth(t *testing.T) {
+	router, handler := setupAdminAuthRouter(fakeVerifier{tokens: map[string]auth.Claims{
+		"reader-token": {Subject: "bob", Roles: []string{auth.RoleReader}},
+		"admin-token":  {Subject: "alice", Roles: []string{auth.RoleAdmin}},
+		"other-token":  {Subject: "eve", Roles: []string{"billing"}},
+		"ops-token":    {Subject: "carol", Roles: []string{auth.RoleAdmin}},
+	}})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		status int
+		auth   string
+	}{
+		{"no token", "GET", "/admin/config", "", http.StatusUnauthorized, `Bearer realm="admin"`},
+		{"invalid token", "GET", "/admin/config", "forged", http.StatusUnauthorized, `Bearer realm="admin", error="invalid_token"`},
+		{"no role", "GET", "/admin/config", "other-token", http.StatusForbidden, `Bearer realm="admin", error="insufficient_scope"`},
+		{"reader reads", "GET", "/admin/config", "reader-token", http.StatusOK, ""},
+		{"reader cannot write", "POST", "/admin/cache/purge", "reader-token", http.StatusForbidden, `Bearer realm="admin", error="insufficient_scope"`},
+		{"admin reads", "GET", "/admin/cache", "admin-token", http.StatusOK, ""},
+		{"admin writes", "POST", "/admin/cache/purge", "admin-token", http.StatusOK, ""},
+		{"pin needs a token", "PUT", "/v1/allocations/pin", "", http.StatusUnauthorized, `Bearer realm="admin"`},
+		{"reader cannot unpin", "DELETE", "/v1/allocations/pin?quantity=600", "reader-token", http.StatusForbidden, `Bearer realm="admin", error="insufficient_scope"`},
+		{"delete needs a token", "DELETE", "/v1/allocations/1", "", http.StatusUnauthorized, `Bearer realm="admin"`},
+		{"reader cannot restore", "POST", "/allocations/1/restore", "reader-token", http.StatusForbidden, `Bearer realm="admin", error="insufficient_scope"`},
+		{"admin deletes", "DELETE", "/v1/allocations/1", "ops-token", http.StatusNotFound, ""},
+		{"recent stays open", "GET", "/v1/recent", "", http.StatusOK, ""},
+		{"deleted allocations need a token", "GET", "/v1/recent?include_deleted=true", "", http.StatusUnauthorized, `Bearer realm="admin"`},
+		{"deleted allocations need a role", "GET", "/v1/recent?include_deleted=true", "other-token", http.StatusForbidden, `Bearer realm="admin", error="insufficient_scope"`},
+		{"reader lists deleted allocations", "GET", "/v1/recent?include_deleted=true", "reader-token", http.StatusOK, ""},
+		{"public routes stay open", "GET", "/health", "", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+			assert.Equal(t, tt.auth, w.Header().Get("WWW-Authenticate"))
+		})
+	}
+
+	// The audit log names the token's subject
+	entries, err := handler.allocator.AuditEntries(storage.AuditFilter{Actor: "user:alice"})
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "/admin/cache/purge", entries[0].Path)
+		assert.Equal(t, http.StatusOK, entries[0].Status)
+	}
+}
+
+func TestAdminAuthUnavailable(t *testing.T) {
+	router, _ := setupAdminAuthRouter(fakeVerifier{err: errors.New("auth: load jwks: connection refused")})
+
+	req := httptest.NewRequest("GET", "/admin/config", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), codeAuthUnavailable)
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/allocator"
+)
+
+// @Summary Delete an allocation
+// @Description Soft-delete a stored allocation: it is kept, marked with its deletion time, but left out of /recent, order lookups, exports and /stats, and no longer reused by the result cache, until it is restored. /recent?include_deleted=true still lists it.
+// @Tags allocations
+// @Produce json
+// @Param id path int true "Allocation ID"
+// @Success 204 "Allocation deleted"
+// @Failure 400 {object} ErrorResponse "Error message"
+// @Failure 404 {object} ErrorResponse "No such allocation, or already deleted"
+// @Failure 500 {object} ErrorResponse "Error message"
+// @Failure 503 {object} ErrorResponse "Read-only mode"
+// @Router /v1/allocations/{id} [delete]
+func (h *Handler) deleteAllocation(c *gin.Context) {
+	id, ok := allocationID(c)
+	if !ok {
+		return
+	}
+	deleted, err := h.allocator.DeleteAllocation(id)
+	if err != nil {
+		writeDomainError(c, err)
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "allocation not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Restore an allocation
+// @Description Undo the soft deletion of an allocation
+// @Tags allocations
+// @Produce json
+// @Param id path int true "Allocation ID"
+// @Success 204 "Allocation restored"
+// @Failure 400 {object} ErrorResponse "Error message"
+// @Failure 404 {object} ErrorResponse "No such deleted allocation"
+// @Failure 500 {object} ErrorResponse "Error message"
+// @Failure 503 {object} ErrorResponse "Read-only mode"
+// @Router /v1/allocations/{id}/restore [post]
+func (h *Handler) restoreAllocation(c *gin.Context) {
+	id, ok := allocationID(c)
+	if !ok {
+		return
+	}
+	restored, err := h.allocator.RestoreAllocation(id)
+	if err != nil {
+		writeDomainError(c, err)
+		return
+	}
+	if !restored {
+		c.JSON(http.StatusNotFound, gin.H{"error": "deleted allocation not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// allocationID parses the id path parameter, writing a 400 response if it
+// is not a positive integer.
+func allocationID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid allocation id"})
+		return 0, false
+	}
+	return id, true
+}
+
+// @Summary Allocation history of a quantity
+// @Description Show how the stored allocation of a quantity changed over time, for example after a profile update: one entry per run of allocations that packed it the same way with the same profile version, oldest first, each with the change in packs from the one before. Built from the latest 1000 allocations of the quantity; soft-deleted allocations are left out.
+// @Tags allocations
+// @Produce json
+// @Param quantity path int true "Order quantity in items"
+// @Param profile query string false "Profile name (default \"default\")"
+// @Success 200 {object} AllocationHistoryResponse "Allocation changes, oldest first"
+// @Failure 400 {object} ErrorResponse "Error message"
+// @Failure 404 {object} ErrorResponse "No allocations stored for the quantity"
+// @Failure 500 {object} ErrorResponse "Error message"
+// @Router /v1/allocations/{quantity}/history [get]
+func (h *Handler) getAllocationHistory(c *gin.Context) {
+	quantity, err := strconv.Atoi(c.Param("quantity"))
+	if err != nil || quantity <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid quantity"})
+		return
+	}
+	profile := c.DefaultQuery("profile", allocator.DefaultProfile)
+	changes, err := h.allocator.AllocationHistory(profile, quantity)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(changes) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no allocations stored for quantity"})
+		return
+	}
+
+	response := AllocationHistoryResponse{
+		Quantity: quantity,
+		Profile:  profile,
+		Changes:  make([]AllocationChangeResponse, len(changes)),
+	}
+	for i, ch := range changes {
+		response.Changes[i] = AllocationChangeResponse{
+			Packs:          ch.Packs,
+			Total:          ch.Total,
+			Waste:          max(ch.Total-quantity, 0),
+			ProfileVersion: ch.ProfileVersion,
+			Algorithm:      ch.Algorithm,
+			FirstSeen:      ch.First,
+			LastSeen:       ch.Last,
+			Allocations:    ch.Allocations,
+			Delta:          ch.Delta,
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
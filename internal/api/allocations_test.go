@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteAndRestoreAllocation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store, err := storage.NewInMemorySQLite()
+	assert.NoError(t, err)
+	alloc := allocator.NewAllocator([]int{23, 31, 53}, store)
+	defer alloc.Close()
+	router := gin.New()
+	NewHandler(alloc).RegisterRoutes(router)
+
+	do := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+	recent := func(query string) []storage.Allocation {
+		w := do("GET", "/v1/recent"+query)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response AllocationsResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response.Allocations
+	}
+
+	assert.Equal(t, http.StatusOK, do("GET", "/v1/calculate?quantity=50").Code)
+	allocations := recent("")
+	if !assert.Len(t, allocations, 1) {
+		return
+	}
+	id := allocations[0].ID
+
+	assert.Equal(t, http.StatusNoContent, do("DELETE", fmt.Sprintf("/v1/allocations/%d", id)).Code)
+	assert.Equal(t, http.StatusNotFound, do("DELETE", fmt.Sprintf("/v1/allocations/%d", id)).Code)
+	assert.Empty(t, recent(""))
+	allocations = recent("?include_deleted=true")
+	if assert.Len(t, allocations, 1) {
+		assert.NotNil(t, allocations[0].DeletedAt)
+	}
+
+	assert.Equal(t, http.StatusNoContent, do("POST", fmt.Sprintf("/v1/allocations/%d/restore", id)).Code)
+	assert.Equal(t, http.StatusNotFound, do("POST", fmt.Sprintf("/v1/allocations/%d/restore", id)).Code)
+	assert.Len(t, recent(""), 1)
+
+	assert.Equal(t, http.StatusBadRequest, do("DELETE", "/v1/allocations/abc").Code)
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/v1/allocations/0/restore").Code)
+	assert.Equal(t, http.StatusBadRequest, do("GET", "/v1/recent?include_deleted=maybe").Code)
+	// The pin route is not shadowed
+	assert.Equal(t, http.StatusBadRequest, do("DELETE", "/v1/allocations/pin").Code)
+
+	assert.NoError(t, alloc.SetReadOnly(allocator.ReadOnly{Enabled: true}))
+	assert.Equal(t, http.StatusServiceUnavailable, do("DELETE", fmt.Sprintf("/v1/allocations/%d", id)).Code)
+}
+
+func TestAllocationHistory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store, err := storage.NewInMemorySQLite()
+	assert.NoError(t, err)
+	alloc := allocator.NewAllocator([]int{23, 31, 53}, store)
+	defer alloc.Close()
+	assert.NoError(t, alloc.RecordProfileVersions())
+	router := gin.New()
+	NewHandler(alloc).RegisterRoutes(router)
+
+	do := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+	history := func() AllocationHistoryResponse {
+		w := do("/v1/allocations/100/history")
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response AllocationHistoryResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	assert.Equal(t, http.StatusNotFound, do("/v1/allocations/100/history").Code)
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusOK, do("/v1/calculate?quantity=100").Code)
+	}
+	response := history()
+	assert.Equal(t, 100, response.Quantity)
+	assert.Equal(t, allocator.DefaultProfile, response.Profile)
+	if assert.Len(t, response.Changes, 1) {
+		first := response.Changes[0]
+		assert.Equal(t, map[int]int{23: 3, 31: 1}, first.Packs)
+		assert.Equal(t, 100, first.Total)
+		assert.Equal(t, 1, first.ProfileVersion)
+		assert.Equal(t, 2, first.Allocations)
+		assert.Nil(t, first.Delta)
+	}
+
+	// The profile changes, and with it the allocation.
+	_, err = alloc.UpdateProfile(context.Background(), allocator.DefaultProfile, []int{25, 50})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, do("/v1/calculate?quantity=100").Code)
+	response = history()
+	if assert.Len(t, response.Changes, 2) {
+		current := response.Changes[1]
+		assert.Equal(t, map[int]int{50: 2}, current.Packs)
+		assert.Equal(t, 0, current.Waste)
+		assert.Equal(t, 2, current.ProfileVersion)
+		assert.Equal(t, map[int]int{23: -3, 31: -1, 50: 2}, current.Delta)
+	}
+
+	assert.Equal(t, http.StatusNotFound, do("/v1/allocations/100/history?profile=apparel").Code)
+	assert.Equal(t, http.StatusBadRequest, do("/v1/allocations/0/history").Code)
+	assert.Equal(t, http.StatusBadRequest, do("/v1/allocations/many/history").Code)
+	// Static routes under /allocations still win.
+	assert.Equal(t, http.StatusOK, do("/v1/allocations/pins").Code)
+}
@@ -0,0 +1,259 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+const (
+	// auditMaxParams caps the query string and body recorded per request.
+	auditMaxParams = 4096
+	// auditMaxError caps the error response captured per request.
+	auditMaxError = 1024
+)
+
+// auditable reports whether a request is recorded in the audit log: every
+// mutating request, plus calculations served over GET. Live WebSocket
+// previews are not recorded.
+func auditable(method, route string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return route == "/calculate"
+	}
+	return true
+}
+
+// SetTrustedProxies lists the IPs and CIDRs of the authenticating proxies
+// whose X-Forwarded-User header names the caller. The header is ignored on
+// requests from anywhere else, which is everywhere by default.
+func (h *Handler) SetTrustedProxies(proxies []string) error {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, proxy := range proxies {
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			addr, aerr := netip.ParseAddr(proxy)
+			if aerr != nil {
+				return fmt.Errorf("invalid trusted proxy %q: want an IP or CIDR", proxy)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	h.trustedProxies = prefixes
+	return nil
+}
+
+// trustedProxy reports whether the request came straight from a trusted
+// proxy.
+func (h *Handler) trustedProxy(c *gin.Context) bool {
+	addr, err := netip.ParseAddr(c.RemoteIP())
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range h.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditActor identifies the caller. The subject of a verified admin token
+// wins, then a user set in X-Forwarded-User by a trusted proxy (see
+// SetTrustedProxies); API keys, from X-API-Key or a bearer token, are
+// recorded by fingerprint and never in full.
+func (h *Handler) auditActor(c *gin.Context) string {
+	if subject := c.GetString(subjectKey); subject != "" {
+		return "user:" + subject
+	}
+	if user := strings.TrimSpace(c.GetHeader("X-Forwarded-User")); user != "" && h.trustedProxy(c) {
+		return "user:" + user
+	}
+	key := c.GetHeader("X-API-Key")
+	if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok && key == "" {
+		key = token
+	}
+	if key = strings.TrimSpace(key); key != "" {
+		return "key:" + apiKeyDigest(key)[:12]
+	}
+	return "anonymous"
+}
+
+// apiKeyDigest returns the SHA-256 digest of an API key in hex. Its first 12
+// digits are the key's fingerprint.
+func apiKeyDigest(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// audit records auditable requests with their actor, parameters and outcome.
+// Failures to record are logged and never fail the request.
+func (h *Handler) audit(c *gin.Context) {
+	if !auditable(c.Request.Method, routeKey(c.FullPath(), h.basePath)) {
+		c.Next()
+		return
+	}
+
+	body := &captureReader{limit: auditMaxParams}
+	if c.Request.Body != nil {
+		body.ReadCloser = c.Request.Body
+		c.Request.Body = body
+	}
+	writer := &auditWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+
+	start := time.Now()
+	c.Next()
+
+	entry := storage.AuditEntry{
+		Actor:      h.auditActor(c),
+		ClientIP:   c.ClientIP(),
+		Method:     c.Request.Method,
+		Path:       c.Request.URL.Path,
+		Params:     auditParams(c.Request.URL.RawQuery, body),
+		Status:     writer.Status(),
+		Error:      writer.errorMessage(),
+		DurationMS: time.Since(start).Milliseconds(),
+		CreatedAt:  start,
+	}
+	if !writer.Written() && errors.Is(c.Request.Context().Err(), context.DeadlineExceeded) {
+		// The Timeout middleware answers once this handler returns.
+		entry.Status = http.StatusGatewayTimeout
+		entry.Error = "request timeout"
+	}
+	if err := h.allocator.RecordAudit(entry); err != nil {
+		log.Printf("Failed to record audit entry for %s %s: %v", entry.Method, entry.Path, err)
+	}
+}
+
+// auditParams joins the query string and the part of the body the handler read.
+func auditParams(query string, body *captureReader) string {
+	params := query
+	if body.buf.Len() > 0 {
+		if params != "" {
+			params += " "
+		}
+		params += body.buf.String()
+	}
+	if body.truncated {
+		params += " (truncated)"
+	}
+	return params
+}
+
+// captureReader keeps a copy of the first limit bytes read from a body.
+type captureReader struct {
+	io.ReadCloser
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (r *captureReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	room := r.limit - r.buf.Len()
+	if n > room {
+		r.truncated = true
+		r.buf.Write(p[:room])
+	} else {
+		r.buf.Write(p[:n])
+	}
+	return n, err
+}
+
+// auditWriter keeps a copy of the start of error responses.
+type auditWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditWriter) Write(p []byte) (int, error) {
+	w.capture(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *auditWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *auditWriter) capture(p []byte) {
+	if w.Status() < http.StatusBadRequest {
+		return
+	}
+	if room := auditMaxError - w.body.Len(); room > 0 {
+		w.body.Write(p[:min(len(p), room)])
+	}
+}
+
+// errorMessage returns the "error" field of a JSON error response, or the
+// raw response for other formats.
+func (w *auditWriter) errorMessage() string {
+	if w.body.Len() == 0 {
+		return ""
+	}
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(w.body.Bytes(), &body) == nil && body.Error != "" {
+		return body.Error
+	}
+	return strings.TrimSpace(w.body.String())
+}
+
+// @Summary Query the audit log
+// @Description List audited requests (every mutating or calculating request) with the caller, parameters and outcome, most recent first
+// @Tags admin
+// @Produce json
+// @Param actor query string false "Only entries by this actor, e.g. user:alice or key:3f2a9c1b0d4e"
+// @Param path query string false "Only entries for this request path, e.g. /calculate"
+// @Param from query string false "Earliest time (RFC 3339 or YYYY-MM-DD), inclusive"
+// @Param to query string false "Latest time (RFC 3339 or YYYY-MM-DD), exclusive"
+// @Param limit query int false "Maximum entries to return (default 100, max 1000)"
+// @Success 200 {object} AuditResponse "Audit entries"
+// @Failure 400 {object} ErrorResponse "Error message"
+// @Failure 500 {object} ErrorResponse "Error message"
+// @Router /v1/admin/audit [get]
+func (h *Handler) getAudit(c *gin.Context) {
+	filter := storage.AuditFilter{Actor: c.Query("actor"), Path: c.Query("path")}
+
+	var err error
+	if filter.From, err = parseTimeParam(c.Query("from")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from: " + err.Error()})
+		return
+	}
+	if filter.To, err = parseTimeParam(c.Query("to")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to: " + err.Error()})
+		return
+	}
+	if v := c.Query("limit"); v != "" {
+		filter.Limit, err = strconv.Atoi(v)
+		if err != nil || filter.Limit <= 0 || filter.Limit > storage.MaxAuditLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+	}
+
+	entries, err := h.allocator.AuditEntries(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, AuditResponse{Entries: entries})
+}
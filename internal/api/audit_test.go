@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditActor(t *testing.T) {
+	handler := NewHandler(nil)
+	assert.NoError(t, handler.SetTrustedProxies([]string{"10.0.0.0/8", "192.0.2.7"}))
+
+	tests := []struct {
+		name     string
+		peer     string
+		subject  string
+		headers  map[string]string
+		expected string
+	}{
+		{"anonymous", "", "", nil, "anonymous"},
+		{"proxy user", "10.1.2.3:4000", "", map[string]string{"X-Forwarded-User": "alice", "X-API-Key": "secret"}, "user:alice"},
+		{"proxy user from a trusted IP", "192.0.2.7:4000", "", map[string]string{"X-Forwarded-User": "alice"}, "user:alice"},
+		{"proxy user from an untrusted peer", "192.0.2.8:4000", "", map[string]string{"X-Forwarded-User": "alice", "X-API-Key": "secret"}, "key:2bb80d537b1d"},
+		{"forged proxy user", "", "", map[string]string{"X-Forwarded-User": "alice"}, "anonymous"},
+		{"verified subject wins", "10.1.2.3:4000", "bob", map[string]string{"X-Forwarded-User": "alice"}, "user:bob"},
+		{"api key", "", "", map[string]string{"X-API-Key": "secret"}, "key:2bb80d537b1d"},
+		{"bearer token", "", "", map[string]string{"Authorization": "Bearer secret"}, "key:2bb80d537b1d"},
+		{"basic auth ignored", "", "", map[string]string{"Authorization": "Basic dXNlcjpwYXNz"}, "anonymous"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/calculate", nil)
+			if tt.peer != "" {
+				c.Request.RemoteAddr = tt.peer
+			}
+			for k, v := range tt.headers {
+				c.Request.Header.Set(k, v)
+			}
+			if tt.subject != "" {
+				c.Set(subjectKey, tt.subject)
+			}
+			assert.Equal(t, tt.expected, handler.auditActor(c))
+		})
+	}
+
+	assert.Error(t, handler.SetTrustedProxies([]string{"proxy.internal"}))
+}
+
+func TestAuditLog(t *testing.T) {
+	router, handler := setupTestRouter()
+	// httptest requests come from 192.0.2.1.
+	assert.NoError(t, handler.SetTrustedProxies([]string{"192.0.2.1"}))
+
+	req := httptest.NewRequest("POST", "/calculate", strings.NewReader(`{"quantity": 50, "order_id": "ORD-1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Forwarded-User", "alice")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("GET", "/calculate?quantity=0", nil)
+	req.Header.Set("X-API-Key", "secret")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Reads are not audited
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/recent", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+
+	entries, err := handler.allocator.AuditEntries(storage.AuditFilter{})
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		failed, ok := entries[0], entries[1]
+		assert.Equal(t, "key:2bb80d537b1d", failed.Actor)
+		assert.Equal(t, "GET", failed.Method)
+		assert.Equal(t, "/calculate", failed.Path)
+		assert.Equal(t, "quantity=0", failed.Params)
+		assert.Equal(t, http.StatusBadRequest, failed.Status)
+		assert.Equal(t, "invalid quantity", failed.Error)
+
+		assert.Equal(t, "user:alice", ok.Actor)
+		assert.Equal(t, "POST", ok.Method)
+		assert.Equal(t, `{"quantity": 50, "order_id": "ORD-1"}`, ok.Params)
+		assert.Equal(t, http.StatusOK, ok.Status)
+		assert.Empty(t, ok.Error)
+		assert.False(t, ok.CreatedAt.IsZero())
+	}
+
+	// GET /admin/audit filters the log
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/audit?actor=user:alice", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response AuditResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	if assert.Len(t, response.Entries, 1) {
+		assert.Equal(t, "user:alice", response.Entries[0].Actor)
+	}
+
+	for _, query := range []string{"limit=0", "limit=x", "limit=1001", "from=yesterday"} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/audit?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestAuditParamsTruncated(t *testing.T) {
+	router, handler := setupTestRouter()
+
+	body := `{"quantity": 50, "metadata": {"note": "` + strings.Repeat("x", auditMaxParams) + `"}}`
+	req := httptest.NewRequest("POST", "/calculate", strings.NewReader(body))
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries, err := handler.allocator.AuditEntries(storage.AuditFilter{})
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, body[:auditMaxParams]+" (truncated)", entries[0].Params)
+	}
+}
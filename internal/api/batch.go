@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/allocator"
+)
+
+// batchRequest is the body accepted by POST /calculate/batch.
+type batchRequest struct {
+	Quantities []int  `json:"quantities" binding:"required,min=1,dive,gt=0"`
+	Profile    string `json:"profile"`
+}
+
+// @Summary Calculate pack distributions for many quantities
+// @Description Allocate every quantity with one profile, answering them all from one dynamic-programming table built up to the largest quantity, so a batch costs about as much as its largest quantity. Results come in the order of the quantities, with the exact strategy's packs avoiding deprecated sizes, unless the quantity is pinned. Nothing is stored and no cache or allocation rule applies. The number of quantities counts towards the batch limit, quantities end at 1000000 at most, and profiles with pack limits are rejected.
+// @Tags packs
+// @Accept json
+// @Produce json
+// @Param request body batchRequest true "Quantities and profile"
+// @Param format query string false "Packs format: map (default), list or flat" Enums(map, list, flat)
+// @Success 200 {object} BatchResponse "One result per quantity"
+// @Failure 400 {object} ValidationErrorResponse "Invalid fields"
+// @Failure 413 {object} ErrorResponse "Request body too large"
+// @Failure 422 {object} ErrorResponse "Too many or too large quantities, or a profile with pack limits"
+// @Failure 429 {object} ErrorResponse "Usage quota exceeded"
+// @Failure 503 {object} ErrorResponse "Overloaded"
+// @Failure 504 {object} ErrorResponse "Error message"
+// @Router /v1/calculate/batch [post]
+func (h *Handler) calculateBatch(c *gin.Context) {
+	format, err := requestedFormat(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, codeInvalidFormat)
+		return
+	}
+	var body batchRequest
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	results, err := h.allocator.AllocateBatch(c.Request.Context(), allocator.BatchRequest{
+		Quantities: body.Quantities,
+		Profile:    body.Profile,
+	})
+	if err != nil {
+		writeAllocationError(c, err)
+		return
+	}
+	profile := body.Profile
+	if profile == "" {
+		profile = allocator.DefaultProfile
+	}
+	response := BatchResponse{Profile: profile, Results: make([]BatchItemResponse, len(results))}
+	for i, r := range results {
+		count := 0
+		for _, n := range r.Packs {
+			count += n
+		}
+		response.Results[i] = BatchItemResponse{
+			Quantity:  body.Quantities[i],
+			Packs:     format.formatPacks(r.Packs),
+			Total:     r.Total,
+			Waste:     r.Total - body.Quantities[i],
+			PackCount: count,
+			Source:    r.Source,
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalculateBatch(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	body := `{"quantities": [53, 1, 106]}`
+	req := httptest.NewRequest("POST", "/calculate/batch?format=flat", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response BatchResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "default", response.Profile)
+	assert.Len(t, response.Results, 3)
+	assert.Equal(t, 53, response.Results[0].Quantity)
+	assert.Equal(t, "1x53", response.Results[0].Packs)
+	assert.Equal(t, 22, response.Results[1].Waste)
+	assert.Equal(t, 106, response.Results[2].Total)
+	assert.Equal(t, 2, response.Results[2].PackCount)
+
+	for body, status := range map[string]int{
+		`{"quantities": []}`:                      http.StatusBadRequest,
+		`{"quantities": [10, 0]}`:                 http.StatusBadRequest,
+		`{"quantities": [2000000]}`:               http.StatusUnprocessableEntity,
+		`{"quantities": [10], "profile": "nope"}`: http.StatusBadRequest,
+	} {
+		req = httptest.NewRequest("POST", "/calculate/batch", strings.NewReader(body))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, status, w.Code, body)
+	}
+}
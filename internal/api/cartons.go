@@ -0,0 +1,59 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/allocator"
+)
+
+// cartonsRequested reports whether the request asked for ?cartons=true.
+func cartonsRequested(c *gin.Context) (bool, error) {
+	v := c.Query("cartons")
+	if v == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(v)
+}
+
+// cartonPlanResponse converts a carton plan for a response, rendering the
+// packs of each load in format.
+func cartonPlanResponse(plan allocator.CartonPlan, format packsFormat) *CartonPlanResponse {
+	response := &CartonPlanResponse{
+		Loads:   make([]CartonLoadResponse, len(plan.Loads)),
+		Cartons: plan.Cartons,
+		Weight:  plan.Weight,
+	}
+	for i, l := range plan.Loads {
+		response.Loads[i] = CartonLoadResponse{
+			Carton: l.Carton,
+			Count:  l.Count,
+			Packs:  format.formatPacks(l.Packs),
+			Weight: l.Weight,
+			Fill:   l.Fill,
+		}
+	}
+	return response
+}
+
+func dimensionsResponse(dimensions map[int]allocator.Dimensions) map[int]DimensionsResponse {
+	if len(dimensions) == 0 {
+		return nil
+	}
+	response := make(map[int]DimensionsResponse, len(dimensions))
+	for size, d := range dimensions {
+		response[size] = DimensionsResponse{Length: d.Length, Width: d.Width, Height: d.Height, Weight: d.Weight}
+	}
+	return response
+}
+
+func cartonsResponse(cartons []allocator.Carton) []CartonResponse {
+	if len(cartons) == 0 {
+		return nil
+	}
+	response := make([]CartonResponse, len(cartons))
+	for i, c := range cartons {
+		response[i] = CartonResponse{Name: c.Name, Length: c.Length, Width: c.Width, Height: c.Height, MaxWeight: c.MaxWeight}
+	}
+	return response
+}
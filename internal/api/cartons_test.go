@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalculateCartons(t *testing.T) {
+	router, handler := setupTestRouter()
+
+	// Without cartons configured the plan cannot be made
+	req := httptest.NewRequest("GET", "/calculate?quantity=106&cartons=true", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"error": "cannot fit packs into cartons: no cartons configured", "code": "cartons"}`, w.Body.String())
+
+	assert.NoError(t, handler.allocator.SetPackDimensions(map[string]map[int]allocator.Dimensions{
+		allocator.DefaultProfile: {53: {Length: 300, Width: 250, Height: 150, Weight: 2700}},
+	}))
+	assert.NoError(t, handler.allocator.SetCartons([]allocator.Carton{
+		{Name: "small", Length: 400, Width: 300, Height: 300, MaxWeight: 15000},
+	}))
+
+	req = httptest.NewRequest("GET", "/calculate?quantity=106&cartons=true&format=flat", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"packs": "2x53",
+		"total": 106,
+		"approximate": false, "cached": false,
+		"cartons": {
+			"loads": [{"carton": "small", "count": 1, "packs": "2x53", "weight": 5400, "fill": 0.625}],
+			"cartons": 1,
+			"weight": 5400
+		}
+	}`, withoutComputedAt(t, w.Body.String()))
+
+	req = httptest.NewRequest("GET", "/calculate?quantity=106&cartons=maybe", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req = httptest.NewRequest("GET", "/v1/admin/config", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"strategy": "dp",
+		"profiles": [{
+			"name": "default",
+			"pack_sizes": [53, 31, 23],
+			"dimensions": {"53": {"length": 300, "width": 250, "height": 150, "weight": 2700}}
+		}],
+		"max_quantity": 0,
+		"max_batch_size": 0,
+		"read_only": {"enabled": false, "mode": "skip"},
+		"cartons": [{"name": "small", "length": 400, "width": 300, "height": 300, "max_weight": 15000}]
+	}`, w.Body.String())
+}
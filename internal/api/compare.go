@@ -0,0 +1,83 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/allocator"
+)
+
+// packSizeSetRequest is one pack-size set of a compare request.
+type packSizeSetRequest struct {
+	Name      string          `json:"name"`
+	PackSizes []int           `json:"pack_sizes" binding:"required,min=1,dive,gt=0"`
+	Costs     map[int]float64 `json:"costs" binding:"omitempty,dive,keys,gt=0,endkeys,gte=0"`
+}
+
+// compareRequest is the body accepted by POST /calculate/compare.
+type compareRequest struct {
+	Quantity int                  `json:"quantity" binding:"gt=0"`
+	Strategy string               `json:"strategy"`
+	Sets     []packSizeSetRequest `json:"sets" binding:"required,min=2,dive"`
+}
+
+// @Summary Compare pack-size sets for a quantity
+// @Description Allocate one quantity under several pack-size sets and report which set is best by waste, pack count and cost. Costs per pack size are optional and default to 1, so cost is the pack count. Nothing is stored.
+// @Tags packs
+// @Accept json
+// @Produce json
+// @Param request body compareRequest true "Quantity and at least two pack-size sets"
+// @Param format query string false "Packs format: map (default), list or flat" Enums(map, list, flat)
+// @Success 200 {object} CompareResponse "Allocation per set and the best sets"
+// @Failure 400 {object} ValidationErrorResponse "Invalid fields"
+// @Failure 413 {object} ErrorResponse "Request body too large"
+// @Failure 422 {object} ErrorResponse "Too many sets or too large a quantity"
+// @Failure 429 {object} ErrorResponse "Usage quota exceeded"
+// @Failure 503 {object} ErrorResponse "Overloaded"
+// @Failure 504 {object} ErrorResponse "Error message"
+// @Router /v1/calculate/compare [post]
+func (h *Handler) compare(c *gin.Context) {
+	format, err := requestedFormat(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, codeInvalidFormat)
+		return
+	}
+	var body compareRequest
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	sets := make([]allocator.PackSizeSet, len(body.Sets))
+	for i, set := range body.Sets {
+		sets[i] = allocator.PackSizeSet{Name: set.Name, Sizes: set.PackSizes, Costs: set.Costs}
+	}
+	cmp, err := h.allocator.Compare(c.Request.Context(), body.Quantity, sets, body.Strategy)
+	if err != nil {
+		writeAllocationError(c, err)
+		return
+	}
+
+	response := CompareResponse{
+		Quantity: cmp.Quantity,
+		Results:  make([]CompareOutcomeResponse, len(cmp.Outcomes)),
+		Summary: CompareSummaryResponse{
+			Best:        cmp.Outcomes[cmp.Best].Name,
+			BestByWaste: cmp.Outcomes[cmp.BestByWaste].Name,
+			BestByPacks: cmp.Outcomes[cmp.BestByPacks].Name,
+			BestByCost:  cmp.Outcomes[cmp.BestByCost].Name,
+		},
+	}
+	for i, o := range cmp.Outcomes {
+		response.Results[i] = CompareOutcomeResponse{
+			Name:        o.Name,
+			PackSizes:   o.Sizes,
+			Packs:       format.formatPacks(o.Packs),
+			Total:       o.Total,
+			Waste:       o.Waste,
+			PackCount:   o.PackCount,
+			Cost:        o.Cost,
+			Approximate: o.Approximate,
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	body := `{"quantity":501,"strategy":"dp","sets":[
+		{"name":"current","pack_sizes":[1000,500]},
+		{"name":"proposed","pack_sizes":[250,500,1000],"costs":{"250":5}}
+	]}`
+	req, _ := http.NewRequest(http.MethodPost, "/calculate/compare?format=flat", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"quantity": 501,
+		"results": [
+			{"name":"current","pack_sizes":[1000,500],"packs":"1x1000","total":1000,"waste":499,"pack_count":1,"cost":1,"approximate":false},
+			{"name":"proposed","pack_sizes":[1000,500,250],"packs":"1x500,1x250","total":750,"waste":249,"pack_count":2,"cost":6,"approximate":false}
+		],
+		"summary": {"best":"proposed","best_by_waste":"proposed","best_by_packs":"current","best_by_cost":"current"}
+	}`, w.Body.String())
+}
+
+func TestCompareErrors(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{name: "one set", body: `{"quantity":10,"sets":[{"pack_sizes":[5]}]}`, want: http.StatusBadRequest},
+		{name: "bad size", body: `{"quantity":10,"sets":[{"pack_sizes":[5]},{"pack_sizes":[-1]}]}`, want: http.StatusBadRequest},
+		{name: "no quantity", body: `{"sets":[{"pack_sizes":[5]},{"pack_sizes":[3]}]}`, want: http.StatusBadRequest},
+		{name: "malformed", body: `{"quantity":`, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/calculate/compare", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
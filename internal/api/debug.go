@@ -0,0 +1,34 @@
+package api
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/allocator"
+)
+
+// debugRequested reports whether the request asked for ?debug=true.
+func debugRequested(c *gin.Context) (bool, error) {
+	v := c.Query("debug")
+	if v == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(v)
+}
+
+// debugResponse converts allocator stats for a response, or returns nil
+// when debug output was not requested.
+func debugResponse(debug bool, stats allocator.Stats) *DebugResponse {
+	if !debug {
+		return nil
+	}
+	return &DebugResponse{
+		Algorithm:   stats.Strategy,
+		Iterations:  stats.Iterations,
+		Cache:       stats.Cache,
+		ComputeMS:   float64(stats.Duration) / float64(time.Millisecond),
+		EstimatedMS: float64(stats.Estimate) / float64(time.Millisecond),
+		DeadlineMS:  float64(stats.Deadline) / float64(time.Millisecond),
+	}
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalculateDebug(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/calculate?quantity=500&strategy=backtracking&debug=true", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp CalculateResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.NotNil(t, resp.Debug) {
+		assert.Equal(t, "backtracking", resp.Debug.Algorithm)
+		assert.Positive(t, resp.Debug.Iterations)
+		assert.Equal(t, "miss", resp.Debug.Cache)
+		assert.GreaterOrEqual(t, resp.Debug.ComputeMS, 0.0)
+	}
+}
+
+func TestCalculateDebugPost(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	body := `{"quantity":500,"constraints":{"max_packs":20}}`
+	req, _ := http.NewRequest(http.MethodPost, "/calculate?debug=1", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp CalculateResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.NotNil(t, resp.Debug) {
+		assert.Equal(t, "branchbound", resp.Debug.Algorithm)
+		assert.Equal(t, "bypass", resp.Debug.Cache)
+	}
+}
+
+func TestCalculateWithoutDebug(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	for _, query := range []string{"quantity=500", "quantity=500&debug=false"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/calculate?"+query, nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "debug", query)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/calculate?quantity=500&debug=maybe", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":"invalid debug","code":"invalid_debug"}`, w.Body.String())
+}
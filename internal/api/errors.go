@@ -0,0 +1,39 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+// errorStatus maps an error returned by the allocator to the HTTP status
+// reporting it. Requests the client must correct are 400, valid requests
+// that cannot be satisfied 422; errors of no known class are the service's
+// own and 500.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, allocator.ErrInvalidRequest), errors.Is(err, storage.ErrInvalidArgument):
+		return http.StatusBadRequest
+	case errors.Is(err, allocator.ErrInfeasible), errors.Is(err, allocator.ErrTooLarge):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, allocator.ErrQuotaExceeded):
+		return http.StatusTooManyRequests
+	case errors.Is(err, allocator.ErrNotPropagated):
+		return http.StatusBadGateway
+	case errors.Is(err, allocator.ErrReadOnly), errors.Is(err, allocator.ErrOverloaded):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// writeDomainError writes err, as returned by the allocator, with its
+// status.
+func writeDomainError(c *gin.Context, err error) {
+	c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+}
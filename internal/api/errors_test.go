@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("calculate: %w", context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{allocator.ErrInvalidQuantity, http.StatusBadRequest},
+		{fmt.Errorf("item 1: %w", allocator.ErrUnknownProfile), http.StatusBadRequest},
+		{fmt.Errorf("%w: no packs", allocator.ErrInvalidPin), http.StatusBadRequest},
+		{allocator.ErrNoPackSizes, http.StatusBadRequest},
+		{storage.ErrInvalidArgument, http.StatusBadRequest},
+		{&allocator.InfeasibleError{Quantity: 7}, http.StatusUnprocessableEntity},
+		{allocator.ErrCartons, http.StatusUnprocessableEntity},
+		{allocator.ErrNoProfileVersion, http.StatusUnprocessableEntity},
+		{&allocator.LimitError{Field: "quantity", Value: 2, Limit: 1}, http.StatusUnprocessableEntity},
+		{&allocator.OverflowError{Quantity: 7}, http.StatusUnprocessableEntity},
+		{&allocator.QuotaError{Client: "acme", Resource: "requests"}, http.StatusTooManyRequests},
+		{allocator.ErrNotPropagated, http.StatusBadGateway},
+		{allocator.ErrReadOnly, http.StatusServiceUnavailable},
+		{&allocator.OverloadedError{}, http.StatusServiceUnavailable},
+		{allocator.ErrStorageNotConfigured, http.StatusInternalServerError},
+		{errors.New("disk full"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, errorStatus(tt.err), tt.err.Error())
+	}
+}
+
+func TestOrderMissingSKUStatus(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	body := `{"items": [{"sku": "TEE", "quantity": 5}, {"quantity": 5}]}`
+	req := httptest.NewRequest("POST", "/calculate/order", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "item 1: sku is required")
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/allocator"
+)
+
+// etagKey is the context key under which calculatePacks passes the ETag of
+// a cacheable response to calculate.
+const etagKey = "etag"
+
+// SetCacheMaxAge sets how long caches may reuse a GET /calculate response
+// without revalidating it. Zero, the default, makes them revalidate every
+// time, which with an unchanged ETag costs a 304 and no calculation.
+func (h *Handler) SetCacheMaxAge(d time.Duration) {
+	h.cacheMaxAge = d
+}
+
+// resultETag returns the ETag of the GET /calculate response for req: a weak
+// tag derived from the quantity and the profile's result version, plus the
+// strategy, the profile's default weights and the packs format, which change
+// the body too. It returns false for responses that must not be cached:
+// debug telemetry varies between calls, carton plans depend on dimensions
+// the profile version does not track, labels are written in the language
+// negotiated per request, historical (as_of) results are not versioned by
+// the current profile, and results are untracked without recorded profile
+// versions.
+func (h *Handler) resultETag(c *gin.Context, req allocator.Request) (string, bool) {
+	debug, err := debugRequested(c)
+	if err != nil || debug {
+		return "", false
+	}
+	cartons, err := cartonsRequested(c)
+	if err != nil || cartons || c.Query("as_of") != "" {
+		return "", false
+	}
+	labels, err := labelsRequested(c)
+	if err != nil || labels {
+		return "", false
+	}
+	format, err := requestedFormat(c)
+	if err != nil {
+		return "", false
+	}
+	quantity, err := h.allocator.NormalizeQuantity(req.Unit, req.Quantity)
+	if err != nil {
+		return "", false
+	}
+	version, ok := h.allocator.ResultVersion(quantity, req.Profile)
+	if !ok {
+		return "", false
+	}
+	// Profile defaults apply to requests naming no strategy, and do not
+	// change the result version.
+	strategy := req.Strategy
+	var weights *allocator.Weights
+	if strategy == "" {
+		defaults := h.allocator.ProfileDefaults(req.Profile)
+		strategy, weights = defaults.Strategy, defaults.Weights
+		if strategy == "" {
+			strategy = h.allocator.Strategy()
+		}
+	}
+	variant := fnv.New32a()
+	fmt.Fprintf(variant, "%s|%s", strategy, format)
+	if weights != nil {
+		fmt.Fprintf(variant, "|%v", *weights)
+	}
+	if req.Unit != "" {
+		// The response names the unit.
+		fmt.Fprintf(variant, "|%s", req.Unit)
+	}
+	return fmt.Sprintf(`W/"%d-v%s-%08x"`, quantity, version, variant.Sum32()), true
+}
+
+// setCacheHeaders marks a response as cacheable under etag.
+func (h *Handler) setCacheHeaders(c *gin.Context, etag string) {
+	c.Header("ETag", etag)
+	if h.cacheMaxAge > 0 {
+		c.Header("Cache-Control", "public, max-age="+strconv.Itoa(int(h.cacheMaxAge.Seconds())))
+	} else {
+		c.Header("Cache-Control", "no-cache")
+	}
+}
+
+// notModified answers a conditional request whose If-None-Match matches
+// etag with 304, and reports whether it did. Tags are compared weakly.
+func (h *Handler) notModified(c *gin.Context, etag string) bool {
+	header := c.GetHeader("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			h.setCacheHeaders(c, etag)
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
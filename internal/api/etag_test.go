@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+func TestCalculateETag(t *testing.T) {
+	router, handler := setupTestRouter()
+	get := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Without recorded profile versions nothing is cacheable
+	w := get("/calculate?quantity=500", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("ETag"))
+
+	assert.NoError(t, handler.allocator.RecordProfileVersions())
+	w = get("/calculate?quantity=500", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.Regexp(t, `^W/"500-v1-[0-9a-f]{8}"$`, etag)
+	assert.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+
+	// A matching If-None-Match is answered without a body
+	w = get("/calculate?quantity=500", etag)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+	w = get("/calculate?quantity=500", `"other", `+etag[2:])
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	// Another quantity, format or strategy is another representation
+	for _, path := range []string{"/calculate?quantity=501", "/calculate?quantity=500&format=list", "/calculate?quantity=500&strategy=greedy"} {
+		w = get(path, etag)
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.NotEqual(t, etag, w.Header().Get("ETag"), path)
+	}
+
+	// Debug telemetry, labels and errors are not cacheable
+	for _, path := range []string{"/calculate?quantity=500&debug=true", "/calculate?quantity=500&labels=true"} {
+		w = get(path, etag)
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.Empty(t, w.Header().Get("ETag"), path)
+	}
+	w = get("/calculate?quantity=500&format=xml", etag)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, w.Header().Get("ETag"))
+
+	// Pinning the quantity changes the ETag
+	_, err := handler.allocator.Pin(context.Background(), storage.Pin{Quantity: 500, Packs: map[int]int{53: 10}})
+	assert.NoError(t, err)
+	w = get("/calculate?quantity=500", etag)
+	assert.Equal(t, http.StatusOK, w.Code)
+	pinned := w.Header().Get("ETag")
+	assert.NotEqual(t, etag, pinned)
+
+	// So does a profile update
+	_, err = handler.allocator.UpdateProfile(context.Background(), "default", []int{250, 500})
+	assert.NoError(t, err)
+	w = get("/calculate?quantity=501", etag)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Regexp(t, `^W/"501-v2-`, w.Header().Get("ETag"))
+
+	// So do the profile's default strategy and weights, which leave the
+	// version alone
+	w = get("/calculate?quantity=12001", "")
+	etag = w.Header().Get("ETag")
+	_, err = handler.allocator.SetProfileDefaults(context.Background(), "default", allocator.ProfileDefaults{Strategy: "greedy"})
+	assert.NoError(t, err)
+	w = get("/calculate?quantity=12001", etag)
+	assert.Equal(t, http.StatusOK, w.Code)
+	greedy := w.Header().Get("ETag")
+	assert.Regexp(t, `^W/"12001-v2-`, greedy)
+	assert.NotEqual(t, etag, greedy)
+	_, err = handler.allocator.SetProfileDefaults(context.Background(), "default", allocator.ProfileDefaults{Weights: &allocator.Weights{Packs: 1}})
+	assert.NoError(t, err)
+	w = get("/calculate?quantity=12001", greedy)
+	assert.Equal(t, http.StatusOK, w.Code)
+	weighted := w.Header().Get("ETag")
+	assert.NotEqual(t, etag, weighted)
+	assert.NotEqual(t, greedy, weighted)
+
+	handler.SetCacheMaxAge(time.Minute)
+	w = get("/calculate?quantity=501", "")
+	assert.Equal(t, "public, max-age=60", w.Header().Get("Cache-Control"))
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+// exportFlushEvery controls how many rows are buffered before flushing to the client.
+const exportFlushEvery = 500
+
+// exportWriter writes allocations in one export format.
+type exportWriter interface {
+	begin() error
+	write(a storage.Allocation) error
+	end() error
+}
+
+var exportContentTypes = map[string]string{
+	"csv":    "text/csv; charset=utf-8",
+	"json":   "application/json",
+	"ndjson": "application/x-ndjson",
+}
+
+// @Summary Export allocation history
+// @Description Stream every stored allocation in the requested format, optionally limited to a date range
+// @Tags packs
+// @Produce text/csv
+// @Produce json
+// @Produce application/x-ndjson
+// @Param format query string false "Export format: csv, json or ndjson (default csv)"
+// @Param from query string false "Inclusive start (RFC 3339 or YYYY-MM-DD)"
+// @Param to query string false "Exclusive end (RFC 3339 or YYYY-MM-DD)"
+// @Success 200 {string} string "Allocation history"
+// @Failure 400 {object} ErrorResponse "Error message"
+// @Router /v1/allocations/export [get]
+func (h *Handler) exportAllocations(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be one of csv, json, ndjson"})
+		return
+	}
+
+	from, err := parseTimeParam(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from: " + err.Error()})
+		return
+	}
+	to, err := parseTimeParam(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to: " + err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="allocations.%s"`, format))
+	c.Status(http.StatusOK)
+
+	buf := bufio.NewWriter(c.Writer)
+	var w exportWriter
+	switch format {
+	case "csv":
+		w = &csvExportWriter{w: csv.NewWriter(buf)}
+	case "json":
+		w = &jsonExportWriter{w: buf}
+	case "ndjson":
+		w = &ndjsonExportWriter{enc: json.NewEncoder(buf)}
+	}
+
+	if err := w.begin(); err != nil {
+		log.Printf("Export failed: %v", err)
+		return
+	}
+
+	rows := 0
+	err = h.allocator.ExportAllocations(from, to, func(a storage.Allocation) error {
+		if err := w.write(a); err != nil {
+			return err
+		}
+		rows++
+		if rows%exportFlushEvery == 0 {
+			if err := buf.Flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		// Headers are already sent, so the best we can do is truncate the stream.
+		log.Printf("Export failed after %d rows: %v", rows, err)
+		return
+	}
+
+	if err := w.end(); err != nil {
+		log.Printf("Export failed: %v", err)
+		return
+	}
+	if err := buf.Flush(); err != nil {
+		log.Printf("Export failed: %v", err)
+	}
+}
+
+// parseTimeParam accepts RFC 3339 timestamps or plain dates; empty means unbounded.
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, errors.New("expected RFC 3339 timestamp or YYYY-MM-DD date")
+	}
+	return t, nil
+}
+
+type csvExportWriter struct {
+	w *csv.Writer
+}
+
+func (e *csvExportWriter) begin() error {
+	return e.w.Write([]string{"id", "order_quantity", "packs", "total", "order_id", "customer_id", "metadata", "created_at"})
+}
+
+func (e *csvExportWriter) write(a storage.Allocation) error {
+	packs, err := json.Marshal(a.Packs)
+	if err != nil {
+		return err
+	}
+	metadata := ""
+	if len(a.Metadata) > 0 {
+		b, err := json.Marshal(a.Metadata)
+		if err != nil {
+			return err
+		}
+		metadata = string(b)
+	}
+	return e.w.Write([]string{
+		strconv.FormatInt(a.ID, 10),
+		strconv.Itoa(a.OrderQuantity),
+		string(packs),
+		strconv.Itoa(a.Total),
+		a.OrderID,
+		a.CustomerID,
+		metadata,
+		a.CreatedAt.UTC().Format(time.RFC3339),
+	})
+}
+
+func (e *csvExportWriter) end() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type jsonExportWriter struct {
+	w     *bufio.Writer
+	count int
+}
+
+func (e *jsonExportWriter) begin() error {
+	_, err := e.w.WriteString("[")
+	return err
+}
+
+func (e *jsonExportWriter) write(a storage.Allocation) error {
+	if e.count > 0 {
+		if _, err := e.w.WriteString(","); err != nil {
+			return err
+		}
+	}
+	e.count++
+	b, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(b)
+	return err
+}
+
+func (e *jsonExportWriter) end() error {
+	_, err := e.w.WriteString("]\n")
+	return err
+}
+
+type ndjsonExportWriter struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonExportWriter) begin() error { return nil }
+
+func (e *ndjsonExportWriter) write(a storage.Allocation) error { return e.enc.Encode(a) }
+
+func (e *ndjsonExportWriter) end() error { return nil }
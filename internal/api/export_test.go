@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportAllocations(t *testing.T) {
+	router, handler := setupTestRouter()
+	_, _, err := handler.allocator.CalculatePacks(50)
+	assert.NoError(t, err)
+
+	// CSV (default)
+	req := httptest.NewRequest("GET", "/allocations/export", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	records, err := csv.NewReader(w.Body).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, "order_quantity", records[0][1])
+	assert.Equal(t, "50", records[1][1])
+	assert.Equal(t, `{"53":1}`, records[1][2])
+
+	// JSON array
+	req = httptest.NewRequest("GET", "/allocations/export?format=json", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var allocations []map[string]interface{}
+	err = json.Unmarshal(w.Body.Bytes(), &allocations)
+	assert.NoError(t, err)
+	assert.Len(t, allocations, 1)
+	assert.Equal(t, float64(53), allocations[0]["Total"])
+
+	// NDJSON
+	req = httptest.NewRequest("GET", "/allocations/export?format=ndjson", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	assert.Len(t, lines, 1)
+
+	// Invalid parameters
+	for _, query := range []string{"format=xml", "from=yesterday", "to=2024-13-01"} {
+		req = httptest.NewRequest("GET", "/allocations/export?"+query, nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestExportAllocationsEmptyJSON(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	req := httptest.NewRequest("GET", "/allocations/export?format=json", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "[]\n", w.Body.String())
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/allocator"
+)
+
+// packsFormat selects how a pack distribution is rendered in responses.
+type packsFormat string
+
+const (
+	// formatMap is the legacy object keyed by pack size: {"500": 2, "250": 1}.
+	formatMap packsFormat = "map"
+	// formatList is an array sorted by size, largest first:
+	// [{"size": 500, "count": 2}, {"size": 250, "count": 1}].
+	formatList packsFormat = "list"
+	// formatFlat is a compact string, largest size first: "2x500,1x250".
+	formatFlat packsFormat = "flat"
+)
+
+var errInvalidFormat = errors.New("invalid format: want map, list or flat")
+
+// requestedFormat reads the ?format parameter, defaulting to formatMap.
+func requestedFormat(c *gin.Context) (packsFormat, error) {
+	switch f := packsFormat(c.Query("format")); f {
+	case "":
+		return formatMap, nil
+	case formatMap, formatList, formatFlat:
+		return f, nil
+	}
+	return "", errInvalidFormat
+}
+
+// PackCount is one entry of a pack distribution in list format.
+type PackCount struct {
+	Size  json.Number `json:"size" swaggertype:"number" example:"500"`
+	Count int         `json:"count" example:"2"`
+}
+
+// packEntry is a pack size, rendered as a JSON number, and its count.
+type packEntry struct {
+	size  string
+	count int
+}
+
+// render returns legacy for formatMap, or the entries in the other formats.
+// Entries must be sorted largest size first.
+func (f packsFormat) render(legacy interface{}, entries []packEntry) interface{} {
+	switch f {
+	case formatList:
+		list := make([]PackCount, len(entries))
+		for i, e := range entries {
+			list[i] = PackCount{Size: json.Number(e.size), Count: e.count}
+		}
+		return list
+	case formatFlat:
+		parts := make([]string, len(entries))
+		for i, e := range entries {
+			parts[i] = strconv.Itoa(e.count) + "x" + e.size
+		}
+		return strings.Join(parts, ",")
+	}
+	return legacy
+}
+
+// formatPacks renders a pack distribution keyed by unit pack size.
+func (f packsFormat) formatPacks(packs map[int]int) interface{} {
+	if f == formatMap {
+		return packs
+	}
+	sizes := make([]int, 0, len(packs))
+	for size := range packs {
+		sizes = append(sizes, size)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+
+	entries := make([]packEntry, len(sizes))
+	for i, size := range sizes {
+		entries[i] = packEntry{size: strconv.Itoa(size), count: packs[size]}
+	}
+	return f.render(packs, entries)
+}
+
+// formatWeightPacks renders a pack distribution keyed by pack weight, with
+// weights in kilograms.
+func (f packsFormat) formatWeightPacks(packs map[allocator.Weight]int) interface{} {
+	sizes := make([]allocator.Weight, 0, len(packs))
+	for size := range packs {
+		sizes = append(sizes, size)
+	}
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] > sizes[j] })
+
+	legacy := make(map[string]int, len(packs))
+	entries := make([]packEntry, len(sizes))
+	for i, size := range sizes {
+		legacy[size.String()] = packs[size]
+		entries[i] = packEntry{size: size.String(), count: packs[size]}
+	}
+	return f.render(legacy, entries)
+}
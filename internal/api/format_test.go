@@ -0,0 +1,67 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalculatePacksFormats(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: "", want: `{"packs":{"53":3,"31":1},"total":190,"approximate":false,"cached":false}`},
+		{format: "map", want: `{"packs":{"53":3,"31":1},"total":190,"approximate":false,"cached":false}`},
+		{format: "list", want: `{"packs":[{"size":53,"count":3},{"size":31,"count":1}],"total":190,"approximate":false,"cached":false}`},
+		{format: "flat", want: `{"packs":"3x53,1x31","total":190,"approximate":false,"cached":false}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/calculate?quantity=190&strategy=dp&format="+tt.format, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.JSONEq(t, tt.want, withoutComputedAt(t, w.Body.String()))
+		})
+	}
+}
+
+func TestCalculatePacksInvalidFormat(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/calculate?quantity=190&format=csv", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":"invalid format: want map, list or flat","code":"invalid_format"}`, w.Body.String())
+}
+
+func TestCalculateOrderFormat(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	body := `{"items":[{"sku":"A","quantity":190}],"strategy":"dp"}`
+	req, _ := http.NewRequest(http.MethodPost, "/calculate/order?format=flat", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"packs":"3x53,1x31"`)
+}
+
+func TestFormatWeightPacks(t *testing.T) {
+	packs := map[allocator.Weight]int{2500: 1, 500: 2}
+
+	assert.Equal(t, map[string]int{"2.5": 1, "0.5": 2}, formatMap.formatWeightPacks(packs))
+	assert.Equal(t, "1x2.5,2x0.5", formatFlat.formatWeightPacks(packs))
+	assert.Equal(t, []PackCount{{Size: "2.5", Count: 1}, {Size: "0.5", Count: 2}}, formatList.formatWeightPacks(packs))
+}
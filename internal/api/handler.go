@@ -5,21 +5,56 @@ package api
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"net/netip"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/auth"
+	"github.com/n-th/gymshark/internal/health"
+	"github.com/n-th/gymshark/internal/storage"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
+// allowedOrigin is the frontend origin permitted by CORS and WebSocket upgrades.
+const allowedOrigin = "http://localhost:3000"
+
 // Handler handles HTTP requests for the pack allocation service.
 // It provides endpoints for calculating pack distributions and
 // retrieving allocation history.
 type Handler struct {
-	allocator *allocator.Allocator
+	allocator   *allocator.Allocator
+	live        LiveOptions
+	basePath    string
+	metrics     http.Handler
+	legacy      LegacyRoutes
+	signer      *Signer
+	cacheMaxAge time.Duration
+	health      *health.Checker
+	recent      RecentLimits
+	adminAuth   AdminVerifier
+	debug       DebugEndpoints
+	lifecycle   lifecycle
+	security    Security
+
+	latencyObserver LatencyObserver
+	// trustedProxies may name the caller in X-Forwarded-User.
+	trustedProxies []netip.Prefix
+	// apiKeys holds the SHA-256 digests of the metered API keys.
+	apiKeys map[string]bool
+}
+
+// SetBasePath mounts every route under prefix, which must already be
+// normalized with NormalizeBasePath. It must be called before RegisterRoutes.
+func (h *Handler) SetBasePath(prefix string) {
+	h.basePath = prefix
 }
 
 // NewHandler creates a new handler instance.
@@ -27,20 +62,68 @@ type Handler struct {
 func NewHandler(allocator *allocator.Allocator) *Handler {
 	return &Handler{
 		allocator: allocator,
+		live:      DefaultLiveOptions(),
+		recent:    DefaultRecentLimits(),
+		lifecycle: lifecycle{done: make(chan struct{})},
 	}
 }
 
 // RegisterRoutes registers the API routes with the provided Gin router.
-// The following endpoints are registered:
-//   - GET /calculate - Calculate pack distribution for a quantity
-//   - GET /recent - Get recent allocation history
+// The API routes are mounted under /v1 (see APIVersion) and, unless
+// disabled with SetLegacyRoutes, at their deprecated unversioned paths:
+//   - GET /v1/calculate - Calculate pack distribution for a quantity
+//   - POST /v1/calculate - Calculate pack distribution with an order reference
+//   - POST /v1/calculate/order - Calculate pack distributions for a multi-item order
+//   - POST /v1/calculate/batch - Calculate pack distributions for many quantities of one profile
+//   - POST /v1/calculate/compare - Compare pack-size sets for a quantity
+//   - POST /v1/simulate - Compare two pack-size sets for a quantity or date range
+//   - GET /v1/suggest - Suggest the nearest quantities that allocate with zero waste
+//   - GET /v1/recent - Get recent allocation history
+//   - GET /v1/profiles/validate - Check the profiles against the pack-size rules
+//   - GET /v1/profiles/:name/versions - Get the version history of a pack-size profile
+//   - GET /v1/ws/calculate - Live calculator over WebSocket
+//   - GET /v1/allocations - Look up allocations by order ID
+//   - GET /v1/allocations/export - Stream allocation history as CSV, JSON or NDJSON
+//   - GET /v1/reports/allocations.xlsx - Excel report of allocations with a summary sheet
+//   - GET /v1/allocations/search - Search allocations by quantity, waste, date, pack size and profile
+//   - GET /v1/allocations/pins - List pinned (manual) allocations
+//   - GET /v1/allocations/:quantity/history - Show how the allocation of a quantity changed over time
+//   - PUT /v1/allocations/pin - Pin a manual pack breakdown for a quantity
+//   - DELETE /v1/allocations/pin - Remove a pin
+//   - DELETE /v1/allocations/:id - Soft-delete an allocation
+//   - POST /v1/allocations/:id/restore - Restore a soft-deleted allocation
+//   - POST /v1/admin/prune - Remove allocation history outside the retention policy
+//   - POST /v1/admin/precompute - Store the allocations of a quantity range for the result cache
+//   - GET /v1/admin/config - Get the running configuration
+//   - GET /v1/admin/cache - Get cache statistics
+//   - GET /v1/admin/read-only - Get the read-only (maintenance) state
+//   - POST /v1/admin/read-only - Switch read-only mode on or off
+//   - POST /v1/admin/cache/purge - Drop cached outcomes on every replica
+//   - POST /v1/admin/backup - Stream a snapshot of the database
+//   - PUT /v1/admin/profiles/:name - Replace the pack sizes of a profile on every replica
+//   - PUT /v1/admin/profiles/:name/defaults - Set the default strategy, weights and timeout of a profile
+//   - GET /v1/admin/audit - Query the audit log of mutating and calculating requests
+//   - GET /v1/admin/outbox - Inspect allocation writes queued for retry
+//   - GET /v1/admin/usage - Report requests and compute time by client for a month
+//   - POST /v1/admin/prestop - Drain requests and flush writes before termination
+//
+// Operational routes are not versioned:
+//   - GET /admin - Admin UI for pack sizes, recent allocations and caches
 //   - GET /health - Health check endpoint
+//   - GET /health/ready - Readiness probes, when configured with SetHealthChecker, failing once stopping
+//   - GET /metrics - Prometheus metrics, when configured with SetMetrics
+//   - GET /debug/pprof/*profile - Go runtime profiles, when enabled with SetDebugEndpoints
+//   - GET /debug/vars - expvar variables, when enabled with SetDebugEndpoints
+//   - GET /openapi.json - The API specification as JSON
 //   - GET /swagger/*any - Swagger documentation
 func (h *Handler) RegisterRoutes(router *gin.Engine) {
+	// Security headers, see SetSecurity
+	router.Use(h.securityHeaders)
+
 	// CORS middleware
 	router.Use(func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "http://localhost:3000")
-		c.Header("Access-Control-Allow-Methods", "GET, OPTIONS")
+		c.Header("Access-Control-Allow-Origin", allowedOrigin)
+		c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
 		c.Header("Access-Control-Allow-Headers", "Content-Type")
 
 		if c.Request.Method == http.MethodOptions {
@@ -50,103 +133,500 @@ func (h *Handler) RegisterRoutes(router *gin.Engine) {
 		}
 	})
 
-	// API routes
-	router.GET("/calculate", h.calculatePacks)
-	router.GET("/recent", h.getRecentAllocations)
+	// Requests in flight, drained before the instance stops
+	router.Use(h.track)
 
-	// Health check
-	router.GET("/health", h.healthCheck)
+	// Audit log of mutating and calculating requests
+	router.Use(h.audit)
 
-	// Swagger documentation
-	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	// Latency of calculations by quantity magnitude
+	if h.latencyObserver != nil {
+		router.Use(h.latency)
+	}
+
+	// Every route, including the documentation, lives under the base path.
+	// Groups copy the middleware registered so far, so this comes last.
+	routes := router.Group(h.basePath)
+
+	h.registerV1(routes.Group("/"+APIVersion, versioned(APIVersion)))
+	if !h.legacy.Disabled {
+		h.registerV1(routes.Group("", versioned(APIVersion), h.deprecated))
+	}
+
+	// Health check and metrics
+	routes.GET("/health", h.healthCheck)
+	routes.GET("/health/ready", h.readinessCheck)
+	if h.metrics != nil {
+		routes.GET("/metrics", h.getMetrics)
+	}
+
+	// Runtime profiling, when enabled with SetDebugEndpoints
+	if h.debug.Enabled {
+		h.registerDebug(routes)
+	}
+
+	// Admin UI
+	routes.GET("/admin", h.adminUI)
+
+	// API documentation
+	routes.GET("/openapi.json", h.openAPISpec)
+	routes.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+}
+
+// registerV1 registers the version 1 API routes on r.
+func (h *Handler) registerV1(r *gin.RouterGroup) {
+	// Calculations count towards the caller's usage quota, and their
+	// responses are signed when a signer is configured
+	r.GET("/calculate", h.metered, h.signed, h.calculatePacks)
+	r.POST("/calculate", h.metered, h.signed, h.calculatePacksWithReference)
+	r.POST("/calculate/order", h.metered, h.signed, h.calculateOrder)
+	r.POST("/calculate/batch", h.metered, h.calculateBatch)
+	r.POST("/calculate/compare", h.metered, h.compare)
+	r.POST("/simulate", h.metered, h.simulate)
+	r.GET("/suggest", h.metered, h.suggest)
+	r.GET("/recent", h.getRecentAllocations)
+	r.GET("/stats", h.getStats)
+	r.GET("/profiles/validate", h.validateProfiles)
+	r.GET("/profiles/:name/versions", h.getProfileVersions)
+	r.GET("/ws/calculate", h.liveCalculate)
+	r.GET("/allocations", h.getAllocations)
+	r.GET("/allocations/export", h.exportAllocations)
+	r.GET("/reports/allocations.xlsx", h.allocationsReport)
+	r.GET("/allocations/search", h.searchAllocations)
+	r.GET("/allocations/pins", h.getPins)
+	r.GET("/allocations/:quantity/history", h.getAllocationHistory)
+
+	// Changes to the stored allocations and pins are for admins
+	allocations := r.Group("/allocations", h.requireRole(auth.RoleAdmin), h.csrf)
+	allocations.PUT("/pin", h.pinAllocation)
+	allocations.DELETE("/pin", h.unpinAllocation)
+	allocations.DELETE("/:id", h.deleteAllocation)
+	allocations.POST("/:id/restore", h.restoreAllocation)
+
+	// Administration, for readers and admins when SetAdminAuth is used
+	read := r.Group("/admin", h.requireRole(auth.RoleReader))
+	read.GET("/config", h.getConfig)
+	read.GET("/cache", h.getCacheStats)
+	read.GET("/shadow", h.getShadow)
+	read.GET("/read-only", h.getReadOnly)
+	read.GET("/audit", h.getAudit)
+	read.GET("/outbox", h.getOutbox)
+	read.GET("/usage", h.getUsage)
+
+	admin := r.Group("/admin", h.requireRole(auth.RoleAdmin), h.csrf)
+	admin.POST("/prune", h.pruneAllocations)
+	admin.POST("/precompute", h.precompute)
+	admin.POST("/read-only", h.setReadOnly)
+	admin.POST("/cache/purge", h.purgeCache)
+	admin.POST("/backup", h.backup)
+	admin.PUT("/profiles/:name", h.updateProfile)
+	admin.PUT("/profiles/:name/defaults", h.setProfileDefaults)
+	admin.POST("/prestop", h.preStop)
 }
 
 // @Summary Calculate pack distribution
-// @Description Calculate the optimal pack distribution for a given quantity
+// @Description Calculate the optimal pack distribution for a given quantity. Responses for a quantity in units carry a weak ETag derived from the quantity and the profile version; a request whose If-None-Match matches it is answered with 304 without calculating or storing the allocation.
 // @Tags packs
 // @Accept json
 // @Produce json
-// @Param quantity query int true "Order quantity"
-// @Success 200 {object} map[string]interface{} "Pack distribution"
-// @Failure 400 {object} map[string]string "Error message"
-// @Router /calculate [get]
+// @Param quantity query number true "Order quantity; decimal kilograms when unit=weight"
+// @Param unit query string false "units (default), weight or a unit configured under units, e.g. case"
+// @Param strategy query string false "Allocation strategy (defaults to the configured strategy)"
+// @Param debug query bool false "Include algorithm telemetry in the response"
+// @Param format query string false "Packs format: map (default), list or flat" Enums(map, list, flat)
+// @Param cartons query bool false "Include how the packs ship in the configured cartons"
+// @Param labels query bool false "Include labels describing the packs in the language negotiated from Accept-Language"
+// @Param as_of query string false "Calculate with the pack sizes active at this RFC 3339 time or date (midnight UTC); the result is not stored"
+// @Param If-None-Match header string false "ETag of a previous response"
+// @Success 200 {object} CalculateResponse "Pack distribution (WeightCalculateResponse when unit=weight)"
+// @Success 304 "Not modified"
+// @Failure 400 {object} ErrorResponse "Error message"
+// @Failure 422 {object} InfeasibleResponse "No feasible combination, packs that fit no carton, or no profile version at as_of"
+// @Failure 429 {object} ErrorResponse "Usage quota exceeded"
+// @Failure 503 {object} ErrorResponse "Read-only mode or overloaded"
+// @Failure 504 {object} ErrorResponse "Error message"
+// @Router /v1/calculate [get]
 func (h *Handler) calculatePacks(c *gin.Context) {
 	quantityStr := c.Query("quantity")
+	unit := c.Query("unit")
+	switch _, configured := h.allocator.Units()[unit]; {
+	case unit == "", unit == unitUnits:
+		unit = ""
+	case unit == unitWeight:
+		h.calculateByWeight(c, quantityStr, allocator.WeightRequest{Strategy: c.Query("strategy")})
+		return
+	case !configured:
+		writeError(c, http.StatusBadRequest, codeInvalidUnit)
+		return
+	}
+
 	quantity, err := strconv.Atoi(quantityStr)
 	if err != nil || quantity <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid quantity"})
+		writeError(c, http.StatusBadRequest, codeInvalidQuantity)
 		return
 	}
 
-	type allocationResult struct {
-		Packs map[int]int
-		Total int
-		Err   error
+	req := allocator.Request{Quantity: quantity, Strategy: c.Query("strategy"), Unit: unit}
+	if etag, ok := h.resultETag(c, req); ok {
+		if h.notModified(c, etag) {
+			return
+		}
+		c.Set(etagKey, etag)
 	}
+	h.calculate(c, req)
+}
 
-	resultChan := make(chan allocationResult, 1)
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 20*time.Minute)
-	defer cancel()
-
-	// const maxExactQuantity = 10000
+// calculateRequest is the body accepted by POST /calculate.
+type calculateRequest struct {
+	// Quantity is a whole number of items, or decimal kilograms when Unit
+	// is weight, or a whole number of a unit configured under units, e.g.
+	// cases.
+	Quantity   json.Number            `json:"quantity" binding:"required" swaggertype:"number"`
+	Unit       string                 `json:"unit" example:"units"`
+	Strategy   string                 `json:"strategy"`
+	OrderID    string                 `json:"order_id"`
+	CustomerID string                 `json:"customer_id"`
+	Metadata   map[string]interface{} `json:"metadata"`
+	// Available is the stock per pack size for this request. Unlike
+	// constraints.available, a quantity the stock cannot cover is not an
+	// error: the stock is allocated and the rest reported as shortfall.
+	Available   map[int]int         `json:"available" binding:"omitempty,dive,keys,gt=0,endkeys,gte=0"`
+	Constraints *constraintsRequest `json:"constraints,omitempty" binding:"omitempty"`
+	Weights     *weightsRequest     `json:"weights,omitempty" binding:"omitempty"`
+}
 
-	// if quantity >= maxExactQuantity {
-	// 	packs, total := h.allocator.GreedyWithCorrectionPacks(quantity)
-	// 	resultChan <- allocationResult{packs, total, err}
-	// } else {
-	// 	packs, total, err := h.allocator.CalculatePacksOptimized(quantity)
-	// 	resultChan <- allocationResult{packs, total, err}
-	// }
+// constraintsRequest restricts the packs a calculation may use.
+// Map keys are pack sizes.
+type constraintsRequest struct {
+	Available map[int]int     `json:"available" binding:"omitempty,dive,keys,gt=0,endkeys,gte=0"`
+	MaxCounts map[int]int     `json:"max_counts" binding:"omitempty,dive,keys,gt=0,endkeys,gte=0"`
+	MaxPacks  int             `json:"max_packs" binding:"gte=0"`
+	Costs     map[int]float64 `json:"costs" binding:"omitempty,dive,keys,gt=0,endkeys,gte=0"`
+	// AllowShortfall allocates what the constraints allow, reporting the
+	// rest as shortfall, instead of failing with 422.
+	AllowShortfall bool `json:"allow_shortfall"`
+}
 
-	packs, total, err := h.allocator.CalculatePacks(quantity)
-	resultChan <- allocationResult{packs, total, err}
+// weightsRequest replaces the least-waste-first objective with the weighted
+// sum w_waste*waste + w_packs*packs + w_cost*cost, where cost sums
+// constraints.costs (default 1 per pack). The lowest sum wins.
+type weightsRequest struct {
+	Waste float64 `json:"w_waste" binding:"gte=0" example:"1"`
+	Packs float64 `json:"w_packs" binding:"gte=0" example:"100"`
+	Cost  float64 `json:"w_cost" binding:"gte=0" example:"0"`
+}
 
-	select {
-	case <-ctx.Done():
-		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "calculation timeout"})
-	case result := <-resultChan:
-		if result.Err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": result.Err.Error()})
+// @Summary Calculate pack distribution for an order
+// @Description Calculate the optimal pack distribution and record it against an order reference. Optional constraints (stock per size, max count per size, max packs, cost per size) are solved with the branchbound strategy. Stock given as available, or constraints with allow_shortfall, turns a quantity the stock cannot cover into a best-effort allocation with a shortfall instead of a 422. Weights trade waste against pack count and cost.
+// @Tags packs
+// @Accept json
+// @Produce json
+// @Param request body calculateRequest true "Quantity, order reference and metadata"
+// @Param debug query bool false "Include algorithm telemetry in the response"
+// @Param format query string false "Packs format: map (default), list or flat" Enums(map, list, flat)
+// @Param cartons query bool false "Include how the packs ship in the configured cartons"
+// @Param labels query bool false "Include labels describing the packs in the language negotiated from Accept-Language"
+// @Param as_of query string false "Calculate with the pack sizes active at this RFC 3339 time or date (midnight UTC); the result is not stored"
+// @Success 200 {object} CalculateResponse "Pack distribution (WeightCalculateResponse when unit is weight)"
+// @Failure 400 {object} ValidationErrorResponse "Invalid fields"
+// @Failure 413 {object} ErrorResponse "Request body too large"
+// @Failure 422 {object} InfeasibleResponse "No feasible combination, packs that fit no carton, or no profile version at as_of"
+// @Failure 429 {object} ErrorResponse "Usage quota exceeded"
+// @Failure 503 {object} ErrorResponse "Read-only mode or overloaded"
+// @Failure 504 {object} ErrorResponse "Error message"
+// @Router /v1/calculate [post]
+func (h *Handler) calculatePacksWithReference(c *gin.Context) {
+	var body calculateRequest
+	if !bindJSON(c, &body) {
+		return
+	}
+	if body.Unit == unitWeight {
+		if body.Constraints != nil || body.Available != nil || body.Weights != nil {
+			writeError(c, http.StatusBadRequest, codeWeightConstraints)
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{
-			"packs": result.Packs,
-			"total": result.Total,
+		h.calculateByWeight(c, body.Quantity.String(), allocator.WeightRequest{
+			Strategy:   body.Strategy,
+			OrderID:    body.OrderID,
+			CustomerID: body.CustomerID,
+			Metadata:   body.Metadata,
 		})
+		return
+	}
+	unit := body.Unit
+	if unit == unitUnits {
+		unit = ""
+	} else if _, ok := h.allocator.Units()[unit]; unit != "" && !ok {
+		writeFieldErrors(c, FieldError{Field: "unit", Rule: h.unitRule()})
+		return
+	}
+	quantity, err := strconv.Atoi(body.Quantity.String())
+	switch {
+	case err != nil:
+		writeFieldErrors(c, FieldError{Field: "quantity", Rule: "type=integer"})
+		return
+	case quantity <= 0:
+		writeFieldErrors(c, FieldError{Field: "quantity", Rule: "gt=0"})
+		return
+	}
+
+	req := allocator.Request{
+		Quantity:   quantity,
+		Strategy:   body.Strategy,
+		OrderID:    body.OrderID,
+		CustomerID: body.CustomerID,
+		Metadata:   body.Metadata,
+		Unit:       unit,
+	}
+	if body.Constraints != nil {
+		req.Constraints = &allocator.Constraints{
+			Available:      body.Constraints.Available,
+			MaxCounts:      body.Constraints.MaxCounts,
+			MaxPacks:       body.Constraints.MaxPacks,
+			Costs:          body.Constraints.Costs,
+			AllowShortfall: body.Constraints.AllowShortfall,
+		}
+	}
+	if body.Available != nil {
+		if req.Constraints == nil {
+			req.Constraints = &allocator.Constraints{}
+		} else if req.Constraints.Available != nil {
+			writeFieldErrors(c, FieldError{Field: "available", Rule: "excluded_with=constraints.available"})
+			return
+		}
+		req.Constraints.Available = body.Available
+		req.Constraints.AllowShortfall = true
+	}
+	if body.Weights != nil {
+		if req.Constraints == nil {
+			req.Constraints = &allocator.Constraints{}
+		}
+		req.Constraints.Weights = &allocator.Weights{
+			Waste: body.Weights.Waste,
+			Packs: body.Weights.Packs,
+			Cost:  body.Weights.Cost,
+		}
+	}
+	h.calculate(c, req)
+}
+
+// calculate runs an allocation and writes the JSON response.
+// Deadlines are enforced by the allocator; an exceeded hard deadline maps to 504.
+func (h *Handler) calculate(c *gin.Context, req allocator.Request) {
+	items, err := h.allocator.NormalizeQuantity(req.Unit, req.Quantity)
+	if err != nil {
+		writeAllocationError(c, err)
+		return
+	}
+	c.Set(quantityKey, items)
+	debug, err := debugRequested(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, codeInvalidDebug)
+		return
+	}
+	format, err := requestedFormat(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, codeInvalidFormat)
+		return
+	}
+	cartons, err := cartonsRequested(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, codeInvalidCartons)
+		return
 	}
+	labels, err := labelsRequested(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, codeInvalidLabels)
+		return
+	}
+	if req.AsOf, err = parseTimeParam(c.Query("as_of")); err != nil {
+		writeError(c, http.StatusBadRequest, codeInvalidAsOf, err)
+		return
+	}
+
+	result, err := h.allocator.Allocate(c.Request.Context(), req)
+	if err != nil {
+		writeAllocationError(c, err)
+		return
+	}
+	var plan *CartonPlanResponse
+	if cartons {
+		fitted, err := h.allocator.FitCartons(req.Profile, result.Packs)
+		if err != nil {
+			writeAllocationError(c, err)
+			return
+		}
+		plan = cartonPlanResponse(fitted, format)
+	}
+
+	// Approximate results may improve on the next calculation.
+	if etag := c.GetString(etagKey); etag != "" && !result.Approximate {
+		h.setCacheHeaders(c, etag)
+	}
+	resp := CalculateResponse{
+		Packs:           format.formatPacks(result.Packs),
+		Total:           result.Total,
+		Approximate:     result.Approximate,
+		Cached:          result.Stats.Cache == allocator.CacheHit,
+		ComputedAt:      result.ComputedAt,
+		Shortfall:       result.Shortfall,
+		Source:          result.Source,
+		DeprecatedPacks: result.DeprecatedPacks,
+		OrderID:         req.OrderID,
+		CustomerID:      req.CustomerID,
+		Cartons:         plan,
+		Debug:           debugResponse(debug, result.Stats),
+	}
+	resp.Rules, resp.Flags = ruleMatchesResponse(result.Rules)
+	if req.Unit != "" {
+		resp.Unit, resp.Items = req.Unit, items
+	}
+	if labels {
+		lang := requestLanguage(c)
+		c.Header("Content-Language", lang)
+		resp.Labels = packLabels(lang, result.Packs, result.Total)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// writeAllocationError maps an allocation error to an HTTP response in the
+// request's language, with the status errorStatus gives it. Timeouts are
+// 504, and shed calculations carry a Retry-After header.
+func writeAllocationError(c *gin.Context, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		writeError(c, http.StatusGatewayTimeout, codeCalculationTimeout)
+		return
+	}
+	lang := requestLanguage(c)
+	c.Header("Content-Language", lang)
+	code, msg := localizeError(lang, err)
+
+	var infeasible *allocator.InfeasibleError
+	var overloaded *allocator.OverloadedError
+	switch {
+	case errors.As(err, &infeasible):
+		c.JSON(errorStatus(err), InfeasibleResponse{Error: msg, Code: code, Cached: infeasible.Cached})
+		return
+	case errors.As(err, &overloaded) && overloaded.RetryAfter > 0:
+		seconds := int(math.Ceil(overloaded.RetryAfter.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(seconds))
+	}
+	c.JSON(errorStatus(err), ErrorResponse{Error: msg, Code: code})
+}
+
+// RecentLimits bounds the allocations /recent returns.
+type RecentLimits struct {
+	// Default applies when the request has no limit; requests for more
+	// than Max are rejected.
+	Default int
+	Max     int
+}
+
+// DefaultRecentLimits returns the /recent limits used by NewHandler.
+func DefaultRecentLimits() RecentLimits {
+	return RecentLimits{Default: 10, Max: 100}
+}
+
+// SetRecentLimits configures the default and maximum limit of /recent.
+func (h *Handler) SetRecentLimits(l RecentLimits) {
+	h.recent = l
 }
 
 // @Summary Get recent allocations
-// @Description Get the most recent pack allocations
+// @Description Get the most recent pack allocations, and how many are stored in all. Soft-deleted allocations are left out unless include_deleted is set, which takes the reader role when admin authentication is configured.
 // @Tags packs
 // @Accept json
 // @Produce json
-// @Success 200 {object} map[string]interface{} "Recent allocations"
-// @Failure 500 {object} map[string]string "Error message"
-// @Router /recent [get]
+// @Param limit query int false "Maximum allocations to return (server.recent.default_limit by default, at most server.recent.max_limit)"
+// @Param include_deleted query bool false "Include soft-deleted allocations, marked with DeletedAt"
+// @Success 200 {object} RecentResponse "Recent allocations"
+// @Failure 400 {object} ErrorResponse "Error message"
+// @Failure 500 {object} ErrorResponse "Error message"
+// @Router /v1/recent [get]
 func (h *Handler) getRecentAllocations(c *gin.Context) {
-	allocations, err := h.allocator.GetRecentAllocations(10)
+	limit := h.recent.Default
+	if v := c.Query("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		if limit > h.recent.Max {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit exceeds the maximum of %d", h.recent.Max)})
+			return
+		}
+	}
+	includeDeleted := false
+	if v := c.Query("include_deleted"); v != "" {
+		var err error
+		if includeDeleted, err = strconv.ParseBool(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid include_deleted"})
+			return
+		}
+	}
+	if includeDeleted && !h.authorize(c, auth.RoleReader) {
+		return
+	}
+
+	allocations, err := h.allocator.GetRecentAllocations(limit, includeDeleted)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
+	total, err := h.allocator.CountAllocations(storage.AllocationFilter{IncludeDeleted: includeDeleted})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if allocations == nil {
+		allocations = []storage.Allocation{}
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"allocations": allocations,
-	})
+	c.JSON(http.StatusOK, RecentResponse{Allocations: allocations, Total: total, Limit: limit})
 }
 
 // @Summary Health check
-// @Description Check if the service is healthy
+// @Description Check if the service is alive. It does not check dependencies; see /health/ready.
 // @Tags health
 // @Accept json
 // @Produce json
-// @Success 200 {object} map[string]string "Health status"
+// @Success 200 {object} HealthResponse "Health status"
 // @Router /health [get]
 func (h *Handler) healthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status": "ok",
-	})
+	c.JSON(http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+// @Summary Get allocations for an order
+// @Description Get all allocations recorded against an order ID
+// @Tags packs
+// @Accept json
+// @Produce json
+// @Param order_id query string true "Order ID"
+// @Success 200 {object} AllocationsResponse "Allocations for the order"
+// @Failure 400 {object} ErrorResponse "Error message"
+// @Failure 500 {object} ErrorResponse "Error message"
+// @Router /v1/allocations [get]
+func (h *Handler) getAllocations(c *gin.Context) {
+	orderID := c.Query("order_id")
+	if orderID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order_id is required"})
+		return
+	}
+
+	allocations, err := h.allocator.GetAllocationsByOrderID(orderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if allocations == nil {
+		allocations = []storage.Allocation{}
+	}
+
+	c.JSON(http.StatusOK, AllocationsResponse{Allocations: allocations})
 }
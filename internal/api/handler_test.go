@@ -1,10 +1,19 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/n-th/gymshark/internal/allocator"
@@ -15,11 +24,20 @@ import (
 // mockStorage implements storage.Storage for testing
 type mockStorage struct {
 	allocations map[int]*storage.Allocation
+	profiles    map[string][]storage.ProfileVersion
+	audit       []storage.AuditEntry
+	pins        map[string]storage.Pin
+	defaults    map[string]storage.ProfileDefaults
+	usage       map[string]storage.Usage
 }
 
 func newMockStorage() *mockStorage {
 	return &mockStorage{
 		allocations: make(map[int]*storage.Allocation),
+		profiles:    make(map[string][]storage.ProfileVersion),
+		pins:        make(map[string]storage.Pin),
+		defaults:    make(map[string]storage.ProfileDefaults),
+		usage:       make(map[string]storage.Usage),
 	}
 }
 
@@ -32,14 +50,327 @@ func (m *mockStorage) StoreAllocation(quantity int, packs map[int]int, total int
 	return nil
 }
 
-func (m *mockStorage) GetRecentAllocations(limit int) ([]storage.Allocation, error) {
+func (m *mockStorage) GetRecentAllocations(limit int, includeDeleted bool) ([]storage.Allocation, error) {
 	return []storage.Allocation{}, nil
 }
 
+func (m *mockStorage) CountAllocations(f storage.AllocationFilter) (int, error) {
+	return len(m.allocations), nil
+}
+
+func (m *mockStorage) StoreAllocationInput(in storage.AllocationInput) error {
+	m.allocations[in.Quantity] = &storage.Allocation{
+		OrderQuantity:     in.Quantity,
+		Packs:             in.Packs,
+		Total:             in.Total,
+		OrderID:           in.OrderID,
+		CustomerID:        in.CustomerID,
+		Metadata:          in.Metadata,
+		Profile:           in.Profile,
+		ProfileVersion:    in.ProfileVersion,
+		Source:            in.Source,
+		Algorithm:         in.Algorithm,
+		Approximate:       in.Approximate,
+		Cacheable:         in.Cacheable,
+		Unit:              in.Unit,
+		RequestedQuantity: in.RequestedQuantity,
+		Rules:             in.Rules,
+		CreatedAt:         time.Now(),
+	}
+	return nil
+}
+
+func (m *mockStorage) StoreAllocations(ins []storage.AllocationInput) error {
+	for _, in := range ins {
+		m.StoreAllocationInput(in)
+	}
+	return nil
+}
+
 func (m *mockStorage) GetAllocationByQuantity(quantity int) (*storage.Allocation, error) {
 	return m.allocations[quantity], nil
 }
 
+func (m *mockStorage) GetCachedAllocation(profile string, quantity int) (*storage.Allocation, error) {
+	if a := m.allocations[quantity]; a != nil && a.Profile == profile {
+		return a, nil
+	}
+	return nil, nil
+}
+
+func (m *mockStorage) CacheAllocation(storage.Allocation) error {
+	return nil
+}
+
+func (m *mockStorage) GetAllocationsByOrderID(orderID string) ([]storage.Allocation, error) {
+	var allocations []storage.Allocation
+	for _, a := range m.allocations {
+		if a.OrderID == orderID {
+			allocations = append(allocations, *a)
+		}
+	}
+	return allocations, nil
+}
+
+func (m *mockStorage) SearchAllocations(f storage.AllocationFilter) ([]storage.Allocation, int, error) {
+	// Only the profile filter and the limit are supported; matches are
+	// ordered by quantity.
+	var matches []storage.Allocation
+	for _, a := range m.allocations {
+		if f.Profile == "" || a.Profile == f.Profile {
+			matches = append(matches, *a)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].OrderQuantity < matches[j].OrderQuantity })
+	total := len(matches)
+	if f.Limit > 0 && len(matches) > f.Limit {
+		matches = matches[:f.Limit]
+	}
+	return matches, total, nil
+}
+
+func (m *mockStorage) ExportAllocations(from, to time.Time, fn func(storage.Allocation) error) error {
+	for _, a := range m.allocations {
+		if err := fn(*a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *mockStorage) RecordProfileVersion(name string, packSizes []int) (storage.ProfileVersion, error) {
+	versions := m.profiles[name]
+	if n := len(versions); n > 0 && reflect.DeepEqual(versions[n-1].PackSizes, packSizes) {
+		return versions[n-1], nil
+	}
+	v := storage.ProfileVersion{Name: name, Version: len(versions) + 1, PackSizes: packSizes, EffectiveFrom: time.Now()}
+	m.profiles[name] = append(versions, v)
+	return v, nil
+}
+
+func (m *mockStorage) GetProfileVersions(name string) ([]storage.ProfileVersion, error) {
+	return append([]storage.ProfileVersion{}, m.profiles[name]...), nil
+}
+
+func (m *mockStorage) DeleteAllocation(id int64) (bool, error) {
+	// Allocations have no IDs
+	return false, nil
+}
+
+func (m *mockStorage) RestoreAllocation(id int64) (bool, error) {
+	return false, nil
+}
+
+func (m *mockStorage) DeleteOlderThan(t time.Time) (int64, error) {
+	var n int64
+	for q, a := range m.allocations {
+		if a.CreatedAt.Before(t) {
+			delete(m.allocations, q)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *mockStorage) DeleteAllButNewest(keep int) (int64, error) {
+	all := make([]*storage.Allocation, 0, len(m.allocations))
+	for _, a := range m.allocations {
+		all = append(all, a)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].CreatedAt.After(all[j].CreatedAt) })
+	var n int64
+	for i := keep; i < len(all); i++ {
+		delete(m.allocations, all[i].OrderQuantity)
+		n++
+	}
+	return n, nil
+}
+
+func (m *mockStorage) RecordAudit(e storage.AuditEntry) error {
+	e.ID = int64(len(m.audit) + 1)
+	m.audit = append(m.audit, e)
+	return nil
+}
+
+func (m *mockStorage) GetAuditEntries(f storage.AuditFilter) ([]storage.AuditEntry, error) {
+	entries := []storage.AuditEntry{}
+	for i := len(m.audit) - 1; i >= 0; i-- {
+		e := m.audit[i]
+		if (f.Actor == "" || e.Actor == f.Actor) && (f.Path == "" || e.Path == f.Path) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func (m *mockStorage) PinAllocation(p storage.Pin) error {
+	m.pins[fmt.Sprintf("%s/%d", p.Profile, p.Quantity)] = p
+	return nil
+}
+
+func (m *mockStorage) UnpinAllocation(profile string, quantity int) (bool, error) {
+	key := fmt.Sprintf("%s/%d", profile, quantity)
+	_, ok := m.pins[key]
+	delete(m.pins, key)
+	return ok, nil
+}
+
+func (m *mockStorage) GetPins() ([]storage.Pin, error) {
+	pins := []storage.Pin{}
+	for _, p := range m.pins {
+		pins = append(pins, p)
+	}
+	return pins, nil
+}
+
+func (m *mockStorage) SetProfileDefaults(d storage.ProfileDefaults) error {
+	m.defaults[d.Profile] = d
+	return nil
+}
+
+func (m *mockStorage) DeleteProfileDefaults(profile string) (bool, error) {
+	_, ok := m.defaults[profile]
+	delete(m.defaults, profile)
+	return ok, nil
+}
+
+func (m *mockStorage) GetProfileDefaults() ([]storage.ProfileDefaults, error) {
+	defaults := []storage.ProfileDefaults{}
+	for _, d := range m.defaults {
+		defaults = append(defaults, d)
+	}
+	return defaults, nil
+}
+
+func (m *mockStorage) GetAlgorithmStats(from, to time.Time) ([]storage.AlgorithmStats, error) {
+	counts := map[string]*storage.AlgorithmStats{}
+	for _, a := range m.allocations {
+		if a.CreatedAt.Before(from) || !to.IsZero() && !a.CreatedAt.Before(to) {
+			continue
+		}
+		c := counts[a.Algorithm]
+		if c == nil {
+			c = &storage.AlgorithmStats{Algorithm: a.Algorithm}
+			counts[a.Algorithm] = c
+		}
+		c.Allocations++
+		if a.Approximate {
+			c.Approximate++
+		}
+	}
+	stats := []storage.AlgorithmStats{}
+	for _, c := range counts {
+		stats = append(stats, *c)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Algorithm < stats[j].Algorithm })
+	return stats, nil
+}
+
+func (m *mockStorage) AggregateWaste(groupBy string, from, to time.Time) ([]storage.WasteStats, error) {
+	if groupBy != storage.GroupByAlgorithm {
+		return nil, storage.ErrInvalidArgument
+	}
+	sums := map[string]*storage.WasteStats{}
+	for _, a := range m.allocations {
+		if a.CreatedAt.Before(from) || !to.IsZero() && !a.CreatedAt.Before(to) {
+			continue
+		}
+		w := sums[a.Algorithm]
+		if w == nil {
+			w = &storage.WasteStats{Group: a.Algorithm}
+			sums[a.Algorithm] = w
+		}
+		w.Allocations++
+		w.Quantity += int64(a.OrderQuantity)
+		w.Waste += int64(a.Total - a.OrderQuantity)
+		w.MaxWaste = max(w.MaxWaste, a.Total-a.OrderQuantity)
+	}
+	stats := []storage.WasteStats{}
+	for _, w := range sums {
+		stats = append(stats, *w)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Group < stats[j].Group })
+	return stats, nil
+}
+
+func (m *mockStorage) PackUsage(from, to time.Time) ([]storage.PackUsage, error) {
+	type key struct {
+		profile string
+		size    int
+	}
+	sums := map[key]*storage.PackUsage{}
+	for _, a := range m.allocations {
+		if a.CreatedAt.Before(from) || !to.IsZero() && !a.CreatedAt.Before(to) {
+			continue
+		}
+		for size, n := range a.Packs {
+			u := sums[key{a.Profile, size}]
+			if u == nil {
+				u = &storage.PackUsage{Profile: a.Profile, Size: size}
+				sums[key{a.Profile, size}] = u
+			}
+			u.Packs += int64(n)
+			u.Allocations++
+		}
+	}
+	usage := []storage.PackUsage{}
+	for _, u := range sums {
+		usage = append(usage, *u)
+	}
+	return usage, nil
+}
+
+func (m *mockStorage) TopQuantities(since time.Time, n int) ([]storage.QuantityCount, error) {
+	counts := map[storage.QuantityCount]int64{}
+	for _, a := range m.allocations {
+		if a.CreatedAt.Before(since) {
+			continue
+		}
+		counts[storage.QuantityCount{Profile: a.Profile, Quantity: a.OrderQuantity}]++
+	}
+	top := []storage.QuantityCount{}
+	for c, requests := range counts {
+		c.Requests = requests
+		top = append(top, c)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Requests != top[j].Requests {
+			return top[i].Requests > top[j].Requests
+		}
+		return top[i].Quantity < top[j].Quantity
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top, nil
+}
+
+func (m *mockStorage) RecordUsage(client string, at time.Time, compute time.Duration) error {
+	key := storage.UsageMonth(at) + "/" + client
+	u := m.usage[key]
+	u.Client, u.Month = client, storage.UsageMonth(at)
+	u.Requests++
+	u.Compute += compute
+	m.usage[key] = u
+	return nil
+}
+
+func (m *mockStorage) GetUsage(at time.Time, client string) ([]storage.Usage, error) {
+	usage := []storage.Usage{}
+	for _, u := range m.usage {
+		if u.Month == storage.UsageMonth(at) && (client == "" || u.Client == client) {
+			usage = append(usage, u)
+		}
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Client < usage[j].Client })
+	return usage, nil
+}
+
+func (m *mockStorage) Backup(w io.Writer) error {
+	_, err := io.WriteString(w, "backup")
+	return err
+}
+
 func (m *mockStorage) Close() error {
 	return nil
 }
@@ -54,6 +385,27 @@ func setupTestRouter() (*gin.Engine, *Handler) {
 	return router, handler
 }
 
+// withoutComputedAt returns a response body without its computed_at time,
+// checking that it is one, so calculation responses can be compared with
+// JSONEq. Bodies without computed_at are returned unchanged.
+func withoutComputedAt(t *testing.T, body string) string {
+	var response map[string]interface{}
+	if json.Unmarshal([]byte(body), &response) != nil {
+		return body
+	}
+	computedAt, ok := response["computed_at"]
+	if !ok {
+		return body
+	}
+	s, _ := computedAt.(string)
+	_, err := time.Parse(time.RFC3339Nano, s)
+	assert.NoError(t, err, "computed_at")
+	delete(response, "computed_at")
+	stripped, err := json.Marshal(response)
+	assert.NoError(t, err)
+	return string(stripped)
+}
+
 func TestCalculatePacks(t *testing.T) {
 	router, _ := setupTestRouter()
 
@@ -92,9 +444,8 @@ func TestCalculatePacks(t *testing.T) {
 			expectedStatus: http.StatusOK,
 			expectedBody: map[string]interface{}{
 				"packs": map[string]interface{}{
-					"53": float64(3),
-					"23": float64(13),
-					"31": float64(11),
+					"53": float64(9),
+					"23": float64(1),
 				},
 				"total": float64(500),
 			},
@@ -167,6 +518,30 @@ func TestHealthCheck(t *testing.T) {
 	assert.Equal(t, "ok", response["status"])
 }
 
+func TestCalculatePacksCached(t *testing.T) {
+	router, handler := setupTestRouter()
+	assert.NoError(t, handler.allocator.RecordProfileVersions())
+	handler.allocator.SetResultCache(true)
+
+	calculate := func() (response struct {
+		Cached     bool      `json:"cached"`
+		ComputedAt time.Time `json:"computed_at"`
+	}) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/calculate?quantity=50", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	computed := calculate()
+	assert.False(t, computed.Cached)
+	assert.WithinDuration(t, time.Now(), computed.ComputedAt, time.Minute)
+	cached := calculate()
+	assert.True(t, cached.Cached)
+	assert.WithinDuration(t, computed.ComputedAt, cached.ComputedAt, time.Second)
+}
+
 func TestGetRecentAllocations(t *testing.T) {
 	router, _ := setupTestRouter()
 
@@ -182,6 +557,46 @@ func TestGetRecentAllocations(t *testing.T) {
 	assert.NotNil(t, response["allocations"])
 }
 
+func TestGetRecentAllocationsLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store, err := storage.NewInMemorySQLite()
+	assert.NoError(t, err)
+	alloc := allocator.NewAllocator([]int{23, 31, 53}, store)
+	defer alloc.Close()
+	router := gin.New()
+	handler := NewHandler(alloc)
+	handler.SetRecentLimits(RecentLimits{Default: 2, Max: 3})
+	handler.RegisterRoutes(router)
+
+	for q := 1; q <= 5; q++ {
+		_, _, err := alloc.CalculatePacks(q * 10)
+		assert.NoError(t, err)
+	}
+	recent := func(query string) (int, RecentResponse) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/recent"+query, nil))
+		var response RecentResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+
+	code, response := recent("")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, response.Allocations, 2)
+	assert.Equal(t, 5, response.Total)
+	assert.Equal(t, 2, response.Limit)
+
+	code, response = recent("?limit=3")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, response.Allocations, 3)
+	assert.Equal(t, 3, response.Limit)
+
+	for _, query := range []string{"?limit=4", "?limit=0", "?limit=ten"} {
+		code, _ = recent(query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
+
 func TestCORSHeaders(t *testing.T) {
 	router, _ := setupTestRouter()
 
@@ -192,7 +607,7 @@ func TestCORSHeaders(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, "http://localhost:3000", w.Header().Get("Access-Control-Allow-Origin"))
-	assert.Equal(t, "GET, OPTIONS", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "GET, POST, OPTIONS", w.Header().Get("Access-Control-Allow-Methods"))
 	assert.Equal(t, "Content-Type", w.Header().Get("Access-Control-Allow-Headers"))
 
 	// Test actual request
@@ -202,6 +617,455 @@ func TestCORSHeaders(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, "http://localhost:3000", w.Header().Get("Access-Control-Allow-Origin"))
-	assert.Equal(t, "GET, OPTIONS", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "GET, POST, OPTIONS", w.Header().Get("Access-Control-Allow-Methods"))
 	assert.Equal(t, "Content-Type", w.Header().Get("Access-Control-Allow-Headers"))
 }
+
+func TestCalculatePacksWithStrategy(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	req := httptest.NewRequest("GET", "/calculate?quantity=500&strategy=dp", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(500), response["total"])
+
+	req = httptest.NewRequest("GET", "/calculate?quantity=500&strategy=unknown", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCalculatePacksWithReference(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	body := `{"quantity": 50, "order_id": "ORD-1", "customer_id": "CUST-9", "metadata": {"channel": "web"}}`
+	req := httptest.NewRequest("POST", "/calculate", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(53), response["total"])
+	assert.Equal(t, "ORD-1", response["order_id"])
+	assert.Equal(t, "CUST-9", response["customer_id"])
+
+	// Look the allocation up by order ID
+	req = httptest.NewRequest("GET", "/allocations?order_id=ORD-1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var lookup struct {
+		Allocations []storage.Allocation `json:"allocations"`
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &lookup)
+	assert.NoError(t, err)
+	assert.Len(t, lookup.Allocations, 1)
+	assert.Equal(t, "CUST-9", lookup.Allocations[0].CustomerID)
+	assert.Equal(t, "web", lookup.Allocations[0].Metadata["channel"])
+
+	// Invalid bodies
+	for _, body := range []string{`{"quantity": 0}`, `not json`} {
+		req = httptest.NewRequest("POST", "/calculate", strings.NewReader(body))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+
+	// Missing order_id
+	req = httptest.NewRequest("GET", "/allocations", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCalculatePacksWithConstraints(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedPacks  map[string]float64
+	}{
+		{
+			name:           "out of stock",
+			body:           `{"quantity": 50, "constraints": {"available": {"53": 0}}}`,
+			expectedStatus: http.StatusOK,
+			expectedPacks:  map[string]float64{"31": 1, "23": 1},
+		},
+		{
+			name:           "costs",
+			body:           `{"quantity": 46, "constraints": {"costs": {"23": 5}}}`,
+			expectedStatus: http.StatusOK,
+			expectedPacks:  map[string]float64{"23": 2},
+		},
+		{
+			name:           "max counts",
+			body:           `{"quantity": 106, "constraints": {"max_counts": {"53": 1}}}`,
+			expectedStatus: http.StatusOK,
+			expectedPacks:  map[string]float64{"31": 1, "23": 1, "53": 1},
+		},
+		{
+			name:           "infeasible",
+			body:           `{"quantity": 500, "constraints": {"max_packs": 2}}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "unsupported strategy",
+			body:           `{"quantity": 50, "strategy": "dp", "constraints": {"max_packs": 2}}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid constraints",
+			body:           `{"quantity": 50, "constraints": {"available": {"53": -1}}}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "weights trade waste for fewer packs",
+			body:           `{"quantity": 100, "weights": {"w_waste": 1, "w_packs": 10}}`,
+			expectedStatus: http.StatusOK,
+			expectedPacks:  map[string]float64{"53": 2},
+		},
+		{
+			name:           "weights with constraints",
+			body:           `{"quantity": 100, "constraints": {"available": {"53": 1}}, "weights": {"w_waste": 1, "w_packs": 10}}`,
+			expectedStatus: http.StatusOK,
+			expectedPacks:  map[string]float64{"53": 1, "31": 1, "23": 1},
+		},
+		{
+			name:           "zero weights",
+			body:           `{"quantity": 100, "weights": {"w_waste": 0}}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "negative weight",
+			body:           `{"quantity": 100, "weights": {"w_packs": -1}}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "weights for weight",
+			body:           `{"quantity": 1.5, "unit": "weight", "weights": {"w_packs": 1}}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/calculate", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedPacks != nil {
+				var response map[string]interface{}
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				packs := response["packs"].(map[string]interface{})
+				assert.Len(t, packs, len(tt.expectedPacks))
+				for size, count := range tt.expectedPacks {
+					assert.Equal(t, count, packs[size])
+				}
+			}
+		})
+	}
+}
+
+func TestCalculatePacksWithAvailable(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/calculate", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Stock short of the quantity is allocated with a shortfall
+	w := post(`{"quantity": 200, "available": {"53": 1, "31": 1, "23": 2}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"packs": {"53": 1, "31": 1, "23": 2}, "total": 130, "shortfall": 70, "approximate": false, "cached": false}`, withoutComputedAt(t, w.Body.String()))
+
+	// Enough stock, no shortfall
+	w = post(`{"quantity": 50, "available": {"53": 0}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"packs": {"31": 1, "23": 1}, "total": 54, "approximate": false, "cached": false}`, withoutComputedAt(t, w.Body.String()))
+
+	// Combined with constraints
+	w = post(`{"quantity": 500, "available": {"53": 5}, "constraints": {"max_packs": 3}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"packs": {"53": 3}, "total": 159, "shortfall": 341, "approximate": false, "cached": false}`, withoutComputedAt(t, w.Body.String()))
+	w = post(`{"quantity": 500, "constraints": {"max_packs": 2, "allow_shortfall": true}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"packs": {"53": 2}, "total": 106, "shortfall": 394, "approximate": false, "cached": false}`, withoutComputedAt(t, w.Body.String()))
+
+	w = post(`{"quantity": 50, "available": {"53": 1}, "constraints": {"available": {"31": 1}}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error": "invalid request body", "code": "invalid_body", "errors": [{"field": "available", "rule": "excluded_with=constraints.available"}]}`, w.Body.String())
+
+	w = post(`{"quantity": 50, "available": {"53": -1}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = post(`{"quantity": 1.5, "unit": "weight", "available": {"53": 1}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCalculatePacksTimeouts(t *testing.T) {
+	allocator.RegisterStrategy("blocking", allocator.StrategyFunc(func(ctx context.Context, _ int, _ []int) (allocator.Result, error) {
+		<-ctx.Done()
+		return allocator.Result{}, ctx.Err()
+	}))
+
+	router, handler := setupTestRouter()
+
+	// Soft timeout falls back to an approximate result
+	handler.allocator.SetTimeouts(10*time.Millisecond, time.Second)
+	req := httptest.NewRequest("GET", "/calculate?quantity=500&strategy=blocking", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, true, response["approximate"])
+
+	// Hard timeout without a soft deadline is reported as 504
+	handler.allocator.SetTimeouts(0, 10*time.Millisecond)
+	req = httptest.NewRequest("GET", "/calculate?quantity=500&strategy=blocking", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+}
+
+func TestCalculatePacksInfeasible(t *testing.T) {
+	allocator.RegisterStrategy("never", allocator.StrategyFunc(func(context.Context, int, []int) (allocator.Result, error) {
+		return allocator.Result{}, allocator.ErrNoCombination
+	}))
+
+	router, handler := setupTestRouter()
+	handler.allocator.SetNegativeCacheTTL(time.Minute)
+
+	for _, cached := range []bool{false, true} {
+		req := httptest.NewRequest("GET", "/calculate?quantity=7&strategy=never", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+		var response map[string]interface{}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, cached, response["cached"])
+	}
+}
+
+func TestCalculatePacksLimits(t *testing.T) {
+	router, handler := setupTestRouter()
+	handler.allocator.SetLimits(allocator.Limits{MaxQuantity: 100})
+
+	req := httptest.NewRequest("GET", "/calculate?quantity=101", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "quantity 101 exceeds the maximum of 100", response["error"])
+}
+
+func TestCalculatePacksOverflow(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	req := httptest.NewRequest("GET", "/calculate?quantity="+strconv.Itoa(math.MaxInt-1)+"&strategy=greedy", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	var response ErrorResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, codeOverflow, response.Code)
+	assert.Equal(t, "quantity 9223372036854775806 is too large for exact calculation", response.Error)
+}
+
+func TestCalculatePacksOverloaded(t *testing.T) {
+	allocator.RegisterStrategy("held", allocator.StrategyFunc(func(ctx context.Context, _ int, _ []int) (allocator.Result, error) {
+		<-ctx.Done()
+		return allocator.Result{}, ctx.Err()
+	}))
+
+	router, handler := setupTestRouter()
+	err := handler.allocator.SetAdmission(allocator.Admission{MaxInFlight: 1, RetryAfter: 1500 * time.Millisecond})
+	assert.NoError(t, err)
+
+	// Hold the only slot with a calculation that runs until cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req := httptest.NewRequest("GET", "/calculate?quantity=500&strategy=held", nil).WithContext(ctx)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	assert.Eventually(t, func() bool { return handler.allocator.AdmissionStats().InFlight == 1 }, time.Second, time.Millisecond)
+
+	req := httptest.NewRequest("GET", "/calculate?quantity=501", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	var response map[string]interface{}
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "too many calculations in progress, retry later", response["error"])
+
+	cancel()
+	<-done
+	req = httptest.NewRequest("GET", "/calculate?quantity=501", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestCalculatePacksAsOf(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := newMockStorage()
+	alloc := allocator.NewAllocator([]int{23, 31, 53}, store)
+	assert.NoError(t, alloc.RecordProfileVersions())
+	store.profiles[allocator.DefaultProfile][0].EffectiveFrom = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err := alloc.UpdateProfile(context.Background(), allocator.DefaultProfile, []int{250, 500, 1000})
+	assert.NoError(t, err)
+	router := gin.New()
+	NewHandler(alloc).RegisterRoutes(router)
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		body   string
+	}{
+		{name: "historical", query: "quantity=106&as_of=2024-06-01", status: http.StatusOK, body: `{"packs":{"53":2},"total":106,"approximate":false,"cached":false}`},
+		{name: "timestamp", query: "quantity=106&as_of=2024-01-01T00:00:00Z", status: http.StatusOK, body: `{"packs":{"53":2},"total":106,"approximate":false,"cached":false}`},
+		{name: "current", query: "quantity=106", status: http.StatusOK, body: `{"packs":{"250":1},"total":250,"approximate":false,"cached":false}`},
+		{name: "before history", query: "quantity=106&as_of=2023-12-31", status: http.StatusUnprocessableEntity, body: `{"error":"no profile version active: profile \"default\" at 2023-12-31T00:00:00Z","code":"no_profile_version"}`},
+		{name: "invalid", query: "quantity=106&as_of=yesterday", status: http.StatusBadRequest, body: `{"error":"invalid as_of: expected RFC 3339 timestamp or YYYY-MM-DD date","code":"invalid_as_of"}`},
+		{name: "weight", query: "quantity=1&unit=weight&as_of=2024-06-01", status: http.StatusBadRequest, body: `{"error":"as_of is not supported for weight","code":"weight_as_of"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/calculate?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.JSONEq(t, tt.body, withoutComputedAt(t, w.Body.String()))
+		})
+	}
+	// Historical results are neither stored nor cacheable
+	assert.Equal(t, 250, store.allocations[106].Total)
+	req := httptest.NewRequest("GET", "/calculate?quantity=106&as_of=2024-06-01", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Empty(t, w.Header().Get("ETag"))
+}
+
+func TestBasePath(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Timeout(Timeouts{
+		Default:  time.Second,
+		Routes:   map[string]time.Duration{"/stream": 0},
+		BasePath: "/pack-api",
+	}))
+	handler := NewHandler(allocator.NewAllocator([]int{23, 31, 53}, newMockStorage()))
+	handler.SetBasePath("/pack-api")
+	handler.RegisterRoutes(router)
+
+	// Route timeouts are keyed without the base path
+	router.GET("/pack-api/stream", func(c *gin.Context) {
+		_, ok := c.Request.Context().Deadline()
+		assert.False(t, ok)
+		c.Status(http.StatusOK)
+	})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/pack-api/stream", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/pack-api/calculate?quantity=50", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/pack-api/swagger/index.html", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Nothing is served outside the base path
+	for _, path := range []string{"/calculate?quantity=50", "/health", "/swagger/index.html"} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, http.StatusNotFound, w.Code, path)
+	}
+
+	// Calculations are audited under the base path too
+	entries, err := handler.allocator.AuditEntries(storage.AuditFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestNormalizeBasePath(t *testing.T) {
+	for in, want := range map[string]string{
+		"":               "",
+		"/":              "",
+		"/pack-api":      "/pack-api",
+		"/pack-api/":     "/pack-api",
+		"/api/v1/packs/": "/api/v1/packs",
+	} {
+		got, err := NormalizeBasePath(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+	for _, in := range []string{"pack-api", "/pack-api/:id", "/pack api"} {
+		_, err := NormalizeBasePath(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestMetricsRoute(t *testing.T) {
+	router, _ := setupTestRouter()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	gin.SetMode(gin.TestMode)
+	router = gin.New()
+	handler := NewHandler(allocator.NewAllocator([]int{23, 31, 53}, newMockStorage()))
+	handler.SetMetrics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("# EOF\n"))
+	}))
+	handler.RegisterRoutes(router)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "# EOF\n", w.Body.String())
+}
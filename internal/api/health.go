@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/health"
+)
+
+// statusStopping is the readiness status once PreStop was called.
+const statusStopping = "stopping"
+
+// SetHealthChecker runs checker's probes on GET /health/ready. Without it the
+// service is always reported ready.
+func (h *Handler) SetHealthChecker(checker *health.Checker) {
+	h.health = checker
+}
+
+// @Summary Readiness check
+// @Description Run the readiness probes, such as database query latency and data volume usage, and report each against its warning and critical thresholds. The service is not ready while any probe is critical; warnings are reported but keep it ready. Once the instance prepares for termination (see /admin/prestop) it is not ready either, with status stopping, and the probes are not run.
+// @Tags health
+// @Produce json
+// @Success 200 {object} ReadinessResponse "Ready, possibly with warnings"
+// @Failure 503 {object} ReadinessResponse "A probe is critical or the instance is stopping"
+// @Router /health/ready [get]
+func (h *Handler) readinessCheck(c *gin.Context) {
+	response := ReadinessResponse{Status: string(health.StatusOK), Checks: map[string]CheckResponse{}}
+	if h.Stopping() {
+		response.Status = statusStopping
+		c.JSON(http.StatusServiceUnavailable, response)
+		return
+	}
+	if h.health == nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	report := h.health.Check(c.Request.Context())
+	response.Status = string(report.Status)
+	for name, r := range report.Checks {
+		response.Checks[name] = CheckResponse{
+			Status:   string(r.Status),
+			Value:    r.Value,
+			Unit:     r.Unit,
+			Warning:  r.Warning,
+			Critical: r.Critical,
+			Error:    r.Error,
+		}
+	}
+	status := http.StatusOK
+	if report.Status == health.StatusCritical {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, response)
+}
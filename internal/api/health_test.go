@@ -0,0 +1,44 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/n-th/gymshark/internal/health"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadinessCheck(t *testing.T) {
+	router, handler := setupTestRouter()
+
+	// Without probes the service is ready
+	req := httptest.NewRequest("GET", "/health/ready", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status": "ok", "checks": {}}`, w.Body.String())
+
+	var queryErr error
+	handler.SetHealthChecker(health.NewChecker(time.Second,
+		health.Latency("database", func(context.Context) error { return queryErr }, 0, time.Hour),
+	))
+	req = httptest.NewRequest("GET", "/health/ready", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"database":{"status":"ok"`)
+
+	// A critical probe makes the service unready
+	queryErr = errors.New("disk I/O error")
+	req = httptest.NewRequest("GET", "/health/ready", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"critical"`)
+	assert.Contains(t, w.Body.String(), `"error":"disk I/O error"`)
+	assert.Contains(t, w.Body.String(), `"critical":3600000`)
+}
@@ -0,0 +1,284 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/allocator"
+)
+
+// errorCode identifies an error message independently of its language. It
+// is returned with the message, so clients can handle errors without
+// matching on text.
+type errorCode string
+
+const (
+	codeInvalidUnit            errorCode = "invalid_unit"
+	codeInvalidQuantity        errorCode = "invalid_quantity"
+	codeInvalidWeight          errorCode = "invalid_weight"
+	codeInvalidDebug           errorCode = "invalid_debug"
+	codeInvalidFormat          errorCode = "invalid_format"
+	codeInvalidCartons         errorCode = "invalid_cartons"
+	codeInvalidLabels          errorCode = "invalid_labels"
+	codeInvalidAsOf            errorCode = "invalid_as_of"
+	codeInvalidBody            errorCode = "invalid_body"
+	codeInvalidMessage         errorCode = "invalid_message"
+	codeBodyTooLarge           errorCode = "body_too_large"
+	codeRateLimited            errorCode = "rate_limited"
+	codeRequestTimeout         errorCode = "request_timeout"
+	codeCalculationTimeout     errorCode = "calculation_timeout"
+	codeWeightConstraints      errorCode = "weight_constraints"
+	codeWeightAsOf             errorCode = "weight_as_of"
+	codeQuantityNotPositive    errorCode = "quantity_not_positive"
+	codeNoCombination          errorCode = "no_combination"
+	codeNoCombinationLimited   errorCode = "no_combination_constrained"
+	codeOverflow               errorCode = "quantity_too_large"
+	codeLimitExceeded          errorCode = "limit_exceeded"
+	codeOverloaded             errorCode = "overloaded"
+	codeQuotaExceeded          errorCode = "quota_exceeded"
+	codeReadOnly               errorCode = "read_only"
+	codeUnknownProfile         errorCode = "unknown_profile"
+	codeUnknownStrategy        errorCode = "unknown_strategy"
+	codeNoPackSizes            errorCode = "no_pack_sizes"
+	codeInvalidConstraints     errorCode = "invalid_constraints"
+	codeConstraintsUnsupported errorCode = "constraints_unsupported"
+	codeEmptyOrder             errorCode = "empty_order"
+	codeCartons                errorCode = "cartons"
+	codeNoProfileVersion       errorCode = "no_profile_version"
+	codeUnauthorized           errorCode = "unauthorized"
+	codeForbidden              errorCode = "forbidden"
+	codeAuthUnavailable        errorCode = "auth_unavailable"
+	codeDebugForbidden         errorCode = "debug_forbidden"
+	codeCSRF                   errorCode = "csrf"
+)
+
+// defaultLanguage answers requests without a supported Accept-Language.
+const defaultLanguage = "en"
+
+// errorMessages holds the error messages of every supported language, as
+// fmt formats. The English messages of allocator errors must match the
+// errors' own text, which localizeError replaces.
+var errorMessages = map[string]map[errorCode]string{
+	"en": {
+		codeInvalidUnit:            "invalid unit",
+		codeInvalidQuantity:        "invalid quantity",
+		codeInvalidWeight:          "invalid quantity: want kilograms with at most 3 decimals",
+		codeInvalidDebug:           "invalid debug",
+		codeInvalidFormat:          "invalid format: want map, list or flat",
+		codeInvalidCartons:         "invalid cartons",
+		codeInvalidLabels:          "invalid labels",
+		codeInvalidAsOf:            "invalid as_of: %s",
+		codeInvalidBody:            "invalid request body",
+		codeInvalidMessage:         "invalid message",
+		codeBodyTooLarge:           "request body exceeds %d bytes",
+		codeRateLimited:            "rate limit exceeded",
+		codeRequestTimeout:         "request timeout",
+		codeCalculationTimeout:     "calculation timeout",
+		codeWeightConstraints:      "constraints are not supported for weight",
+		codeWeightAsOf:             "as_of is not supported for weight",
+		codeQuantityNotPositive:    "quantity must be greater than 0",
+		codeNoCombination:          "no valid pack combination found for quantity %d",
+		codeNoCombinationLimited:   "no valid pack combination found for quantity %d within the pack constraints and limits",
+		codeOverflow:               "quantity %d is too large for exact calculation",
+		codeLimitExceeded:          "%s %d exceeds the maximum of %d",
+		codeOverloaded:             "too many calculations in progress, retry later",
+		codeQuotaExceeded:          "usage quota exceeded",
+		codeReadOnly:               "service is in read-only mode",
+		codeUnknownProfile:         "unknown pack size profile",
+		codeUnknownStrategy:        "unknown allocation strategy",
+		codeNoPackSizes:            "no pack sizes configured",
+		codeInvalidConstraints:     "invalid allocation constraints",
+		codeConstraintsUnsupported: "strategy does not support constraints",
+		codeEmptyOrder:             "order must contain at least one item",
+		codeCartons:                "cannot fit packs into cartons",
+		codeNoProfileVersion:       "no profile version active",
+		codeUnauthorized:           "a valid bearer token is required",
+		codeForbidden:              "the %s role is required",
+		codeAuthUnavailable:        "token verification is unavailable, retry later",
+		codeDebugForbidden:         "debug endpoints are only served to local clients",
+		codeCSRF:                   "missing or invalid CSRF token; reload the admin UI",
+	},
+	"de": {
+		codeInvalidUnit:            "ungültige Einheit",
+		codeInvalidQuantity:        "ungültige Menge",
+		codeInvalidWeight:          "ungültige Menge: erwartet Kilogramm mit höchstens 3 Nachkommastellen",
+		codeInvalidDebug:           "ungültiger Wert für debug",
+		codeInvalidFormat:          "ungültiges Format: erwartet map, list oder flat",
+		codeInvalidCartons:         "ungültiger Wert für cartons",
+		codeInvalidLabels:          "ungültiger Wert für labels",
+		codeInvalidAsOf:            "ungültiges as_of: %s",
+		codeInvalidBody:            "ungültiger Anfragetext",
+		codeInvalidMessage:         "ungültige Nachricht",
+		codeBodyTooLarge:           "Anfragetext überschreitet %d Bytes",
+		codeRateLimited:            "Anfragelimit überschritten",
+		codeRequestTimeout:         "Zeitüberschreitung der Anfrage",
+		codeCalculationTimeout:     "Zeitüberschreitung der Berechnung",
+		codeWeightConstraints:      "Einschränkungen werden für Gewichte nicht unterstützt",
+		codeWeightAsOf:             "as_of wird für Gewichte nicht unterstützt",
+		codeQuantityNotPositive:    "Menge muss größer als 0 sein",
+		codeNoCombination:          "keine gültige Packungskombination für Menge %d gefunden",
+		codeNoCombinationLimited:   "keine gültige Packungskombination für Menge %d innerhalb der Packungseinschränkungen und -grenzen gefunden",
+		codeOverflow:               "Menge %d ist zu groß für eine exakte Berechnung",
+		codeLimitExceeded:          "%s %d überschreitet das Maximum von %d",
+		codeOverloaded:             "zu viele Berechnungen in Bearbeitung, bitte später erneut versuchen",
+		codeQuotaExceeded:          "Nutzungskontingent überschritten",
+		codeReadOnly:               "Dienst ist im Nur-Lese-Modus",
+		codeUnknownProfile:         "unbekanntes Packungsgrößenprofil",
+		codeUnknownStrategy:        "unbekannte Zuteilungsstrategie",
+		codeNoPackSizes:            "keine Packungsgrößen konfiguriert",
+		codeInvalidConstraints:     "ungültige Zuteilungseinschränkungen",
+		codeConstraintsUnsupported: "Strategie unterstützt keine Einschränkungen",
+		codeEmptyOrder:             "Bestellung muss mindestens einen Artikel enthalten",
+		codeCartons:                "Packungen passen nicht in die Kartons",
+		codeNoProfileVersion:       "keine Profilversion aktiv",
+		codeUnauthorized:           "ein gültiges Bearer-Token ist erforderlich",
+		codeForbidden:              "die Rolle %s ist erforderlich",
+		codeAuthUnavailable:        "Tokenprüfung nicht verfügbar, bitte später erneut versuchen",
+		codeDebugForbidden:         "Debug-Endpunkte stehen nur lokalen Clients zur Verfügung",
+		codeCSRF:                   "CSRF-Token fehlt oder ist ungültig; Admin-Oberfläche neu laden",
+	},
+	"fr": {
+		codeInvalidUnit:            "unité invalide",
+		codeInvalidQuantity:        "quantité invalide",
+		codeInvalidWeight:          "quantité invalide : kilogrammes avec au plus 3 décimales attendus",
+		codeInvalidDebug:           "valeur de debug invalide",
+		codeInvalidFormat:          "format invalide : map, list ou flat attendu",
+		codeInvalidCartons:         "valeur de cartons invalide",
+		codeInvalidLabels:          "valeur de labels invalide",
+		codeInvalidAsOf:            "as_of invalide : %s",
+		codeInvalidBody:            "corps de requête invalide",
+		codeInvalidMessage:         "message invalide",
+		codeBodyTooLarge:           "le corps de la requête dépasse %d octets",
+		codeRateLimited:            "limite de requêtes dépassée",
+		codeRequestTimeout:         "délai de la requête dépassé",
+		codeCalculationTimeout:     "délai du calcul dépassé",
+		codeWeightConstraints:      "les contraintes ne sont pas prises en charge pour les poids",
+		codeWeightAsOf:             "as_of n'est pas pris en charge pour les poids",
+		codeQuantityNotPositive:    "la quantité doit être supérieure à 0",
+		codeNoCombination:          "aucune combinaison de colis valide pour la quantité %d",
+		codeNoCombinationLimited:   "aucune combinaison de colis valide pour la quantité %d dans les contraintes et limites de colis",
+		codeOverflow:               "la quantité %d est trop grande pour un calcul exact",
+		codeLimitExceeded:          "%s %d dépasse le maximum de %d",
+		codeOverloaded:             "trop de calculs en cours, réessayez plus tard",
+		codeQuotaExceeded:          "quota d'utilisation dépassé",
+		codeReadOnly:               "le service est en mode lecture seule",
+		codeUnknownProfile:         "profil de tailles de colis inconnu",
+		codeUnknownStrategy:        "stratégie d'allocation inconnue",
+		codeNoPackSizes:            "aucune taille de colis configurée",
+		codeInvalidConstraints:     "contraintes d'allocation invalides",
+		codeConstraintsUnsupported: "la stratégie ne prend pas en charge les contraintes",
+		codeEmptyOrder:             "la commande doit contenir au moins un article",
+		codeCartons:                "impossible de placer les colis dans les cartons",
+		codeNoProfileVersion:       "aucune version de profil active",
+		codeUnauthorized:           "un jeton bearer valide est requis",
+		codeForbidden:              "le rôle %s est requis",
+		codeAuthUnavailable:        "vérification du jeton indisponible, réessayez plus tard",
+		codeDebugForbidden:         "les points de terminaison de débogage ne sont servis qu'aux clients locaux",
+		codeCSRF:                   "jeton CSRF manquant ou invalide ; rechargez l'interface d'administration",
+	},
+}
+
+// sentinelCodes maps allocator errors without parameters to their codes.
+var sentinelCodes = []struct {
+	err  error
+	code errorCode
+}{
+	{allocator.ErrInvalidQuantity, codeQuantityNotPositive},
+	{allocator.ErrReadOnly, codeReadOnly},
+	{allocator.ErrQuotaExceeded, codeQuotaExceeded},
+	{allocator.ErrUnknownProfile, codeUnknownProfile},
+	{allocator.ErrUnknownStrategy, codeUnknownStrategy},
+	{allocator.ErrNoPackSizes, codeNoPackSizes},
+	{allocator.ErrInvalidConstraints, codeInvalidConstraints},
+	{allocator.ErrConstraintsUnsupported, codeConstraintsUnsupported},
+	{allocator.ErrEmptyOrder, codeEmptyOrder},
+	{allocator.ErrCartons, codeCartons},
+	{allocator.ErrNoProfileVersion, codeNoProfileVersion},
+	{allocator.ErrUnknownUnit, codeInvalidUnit},
+}
+
+// negotiateLanguage picks the supported language with the highest quality
+// in an Accept-Language header, e.g. "de-CH, fr;q=0.8". Region subtags are
+// ignored; the default language answers when none is supported.
+func negotiateLanguage(header string) string {
+	best, bestQ := defaultLanguage, 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		lang, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if _, ok := errorMessages[lang]; ok && q > bestQ {
+			best, bestQ = lang, q
+		}
+	}
+	return best
+}
+
+// requestLanguage is the language errors to c are written in.
+func requestLanguage(c *gin.Context) string {
+	return negotiateLanguage(c.GetHeader("Accept-Language"))
+}
+
+// localize formats the message of code in lang, falling back to English.
+func localize(lang string, code errorCode, args ...interface{}) string {
+	format, ok := errorMessages[lang][code]
+	if !ok {
+		format = errorMessages[defaultLanguage][code]
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// localizeError translates the allocator error within err, keeping any
+// context wrapped around it, such as the order item or profile, as is.
+// Errors without a code are returned in English with an empty code.
+func localizeError(lang string, err error) (errorCode, string) {
+	var limit *allocator.LimitError
+	var infeasible *allocator.InfeasibleError
+	var overloaded *allocator.OverloadedError
+	var overflow *allocator.OverflowError
+	var code errorCode
+	var source string
+	var args []interface{}
+	switch {
+	case errors.As(err, &limit):
+		code, source, args = codeLimitExceeded, limit.Error(), []interface{}{limit.Field, limit.Value, limit.Limit}
+	case errors.As(err, &infeasible):
+		code, source, args = codeNoCombination, infeasible.Error(), []interface{}{infeasible.Quantity}
+		if infeasible.Constrained {
+			code = codeNoCombinationLimited
+		}
+	case errors.As(err, &overloaded):
+		code, source = codeOverloaded, overloaded.Error()
+	case errors.As(err, &overflow):
+		code, source, args = codeOverflow, overflow.Error(), []interface{}{overflow.Quantity}
+	default:
+		for _, s := range sentinelCodes {
+			if errors.Is(err, s.err) {
+				code, source = s.code, s.err.Error()
+				break
+			}
+		}
+	}
+	if code == "" {
+		return "", err.Error()
+	}
+	return code, strings.Replace(err.Error(), source, localize(lang, code, args...), 1)
+}
+
+// writeError writes the message of code in the request's language.
+func writeError(c *gin.Context, status int, code errorCode, args ...interface{}) {
+	lang := requestLanguage(c)
+	c.Header("Content-Language", lang)
+	c.JSON(status, ErrorResponse{Error: localize(lang, code, args...), Code: code})
+}
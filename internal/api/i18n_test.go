@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "en"},
+		{"de", "de"},
+		{"de-CH, fr;q=0.8", "de"},
+		{"fr-FR,fr;q=0.9,en;q=0.8", "fr"},
+		{"en;q=0.5, FR;q=0.7", "fr"},
+		{"es, de;q=0.3", "de"},
+		{"es, pt", "en"},
+		{"*", "en"},
+		{"de;q=bad, fr;q=0.1", "fr"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, negotiateLanguage(tt.header), tt.header)
+	}
+}
+
+func TestErrorMessagesComplete(t *testing.T) {
+	for lang, messages := range errorMessages {
+		assert.Len(t, messages, len(errorMessages[defaultLanguage]), lang)
+		for code, english := range errorMessages[defaultLanguage] {
+			assert.Equal(t, strings.Count(english, "%"), strings.Count(messages[code], "%"), "%s %s", lang, code)
+		}
+	}
+
+	// localizeError replaces the English text of allocator errors.
+	for _, s := range sentinelCodes {
+		assert.Equal(t, s.err.Error(), errorMessages[defaultLanguage][s.code], s.code)
+	}
+	limit := &allocator.LimitError{Field: "quantity", Value: 2, Limit: 1}
+	assert.Equal(t, limit.Error(), localize(defaultLanguage, codeLimitExceeded, "quantity", 2, 1))
+	infeasible := &allocator.InfeasibleError{Quantity: 7, Constrained: true}
+	assert.Equal(t, infeasible.Error(), localize(defaultLanguage, codeNoCombinationLimited, 7))
+	assert.Equal(t, (&allocator.OverloadedError{}).Error(), localize(defaultLanguage, codeOverloaded))
+}
+
+func TestLocalizeError(t *testing.T) {
+	wrapped := fmt.Errorf("item 1: %w", fmt.Errorf("%w: %q", allocator.ErrUnknownProfile, "bags"))
+	code, msg := localizeError("de", wrapped)
+	assert.Equal(t, codeUnknownProfile, code)
+	assert.Equal(t, `item 1: unbekanntes Packungsgrößenprofil: "bags"`, msg)
+
+	code, msg = localizeError("fr", &allocator.LimitError{Field: "quantity", Value: 101, Limit: 100})
+	assert.Equal(t, codeLimitExceeded, code)
+	assert.Equal(t, "quantity 101 dépasse le maximum de 100", msg)
+
+	code, msg = localizeError("de", fmt.Errorf("disk full"))
+	assert.Empty(t, code)
+	assert.Equal(t, "disk full", msg)
+}
+
+func TestCalculatePacksLocalizedErrors(t *testing.T) {
+	router, handler := setupTestRouter()
+	handler.allocator.SetLimits(allocator.Limits{MaxQuantity: 100})
+
+	tests := []struct {
+		language string
+		query    string
+		status   int
+		body     string
+		content  string
+	}{
+		{"de-DE,de;q=0.9", "quantity=abc", http.StatusBadRequest, `{"error":"ungültige Menge","code":"invalid_quantity"}`, "de"},
+		{"fr", "quantity=101", http.StatusUnprocessableEntity, `{"error":"quantity 101 dépasse le maximum de 100","code":"limit_exceeded"}`, "fr"},
+		{"es", "quantity=1&unit=kg", http.StatusBadRequest, `{"error":"invalid unit","code":"invalid_unit"}`, "en"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/calculate?"+tt.query, nil)
+		req.Header.Set("Accept-Language", tt.language)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, tt.status, w.Code, tt.query)
+		assert.JSONEq(t, tt.body, w.Body.String(), tt.query)
+		assert.Equal(t, tt.content, w.Header().Get("Content-Language"), tt.query)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/calculate", strings.NewReader(`{"quantity":0}`))
+	req.Header.Set("Accept-Language", "fr")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var body ValidationErrorResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "corps de requête invalide", body.Error)
+	assert.Equal(t, codeInvalidBody, body.Code)
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// labelsRequested reads the ?labels parameter.
+func labelsRequested(c *gin.Context) (bool, error) {
+	v := c.Query("labels")
+	if v == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(v)
+}
+
+// labelKey identifies a label independently of its language.
+type labelKey string
+
+const (
+	// labelPack takes the pack count and the pack size.
+	labelPack labelKey = "pack"
+	// labelPacks and labelUnits take a number of packs and of units.
+	labelPacks labelKey = "packs"
+	labelUnits labelKey = "units"
+)
+
+// pluralForms is a label as fmt formats for a count of one and for any
+// other count.
+type pluralForms struct {
+	one, other string
+}
+
+// labelMessages holds the labels of every language errors are written in.
+var labelMessages = map[string]map[labelKey]pluralForms{
+	"en": {
+		labelPack:  {"%s × %s-unit box", "%s × %s-unit boxes"},
+		labelPacks: {"%s box", "%s boxes"},
+		labelUnits: {"%s unit", "%s units"},
+	},
+	"de": {
+		labelPack:  {"%s × %ser-Packung", "%s × %ser-Packungen"},
+		labelPacks: {"%s Packung", "%s Packungen"},
+		labelUnits: {"%s Stück", "%s Stück"},
+	},
+	"fr": {
+		labelPack:  {"%s × colis de %s unités", "%s × colis de %s unités"},
+		labelPacks: {"%s colis", "%s colis"},
+		labelUnits: {"%s unité", "%s unités"},
+	},
+}
+
+// thousandsSeparators groups the digits of numbers in labels. French uses a
+// narrow no-break space.
+var thousandsSeparators = map[string]string{
+	"en": ",",
+	"de": ".",
+	"fr": "\u202f",
+}
+
+// formatNumber renders n with the thousands separator of lang.
+func formatNumber(lang string, n int) string {
+	digits := strconv.Itoa(n)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	sep, ok := thousandsSeparators[lang]
+	if !ok {
+		sep = thousandsSeparators[defaultLanguage]
+	}
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(sep)
+		}
+		b.WriteRune(d)
+	}
+	return sign + b.String()
+}
+
+// label formats key in lang, in the plural form for count, falling back to
+// English.
+func label(lang string, key labelKey, count int, args ...int) string {
+	forms, ok := labelMessages[lang][key]
+	if !ok {
+		lang, forms = defaultLanguage, labelMessages[defaultLanguage][key]
+	}
+	format := forms.other
+	// French treats zero as singular.
+	if count == 1 || (count == 0 && lang == "fr") {
+		format = forms.one
+	}
+	formatted := make([]interface{}, len(args))
+	for i, arg := range args {
+		formatted[i] = formatNumber(lang, arg)
+	}
+	return fmt.Sprintf(format, formatted...)
+}
+
+// packLabels labels packs, keyed by unit pack size, in lang for display,
+// e.g. "9 × 53-unit boxes" for each size largest first and "9 boxes, 477
+// units" for the whole.
+func packLabels(lang string, packs map[int]int, total int) *LabelsResponse {
+	sizes := make([]int, 0, len(packs))
+	count := 0
+	for size, n := range packs {
+		sizes = append(sizes, size)
+		count += n
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+
+	labels := &LabelsResponse{Packs: make([]string, len(sizes))}
+	for i, size := range sizes {
+		labels.Packs[i] = label(lang, labelPack, packs[size], packs[size], size)
+	}
+	labels.Summary = label(lang, labelPacks, count, count) + ", " + label(lang, labelUnits, total, total)
+	return labels
+}
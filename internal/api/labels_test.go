@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabelMessagesComplete(t *testing.T) {
+	for lang := range errorMessages {
+		assert.Len(t, labelMessages[lang], len(labelMessages[defaultLanguage]), lang)
+		assert.Contains(t, thousandsSeparators, lang)
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	assert.Equal(t, "999", formatNumber("en", 999))
+	assert.Equal(t, "1,234,567", formatNumber("en", 1234567))
+	assert.Equal(t, "1.000", formatNumber("de", 1000))
+	assert.Equal(t, "12\u202f500", formatNumber("fr", 12500))
+	assert.Equal(t, "-1,000", formatNumber("es", -1000))
+}
+
+func TestPackLabels(t *testing.T) {
+	packs := map[int]int{1000: 1, 250: 3}
+	assert.Equal(t, &LabelsResponse{
+		Packs:   []string{"1 × 1,000-unit box", "3 × 250-unit boxes"},
+		Summary: "4 boxes, 1,750 units",
+	}, packLabels("en", packs, 1750))
+	assert.Equal(t, &LabelsResponse{
+		Packs:   []string{"1 × 1.000er-Packung", "3 × 250er-Packungen"},
+		Summary: "4 Packungen, 1.750 Stück",
+	}, packLabels("de", packs, 1750))
+	assert.Equal(t, &LabelsResponse{
+		Packs:   []string{"1 × colis de 1\u202f000 unités", "3 × colis de 250 unités"},
+		Summary: "4 colis, 1\u202f750 unités",
+	}, packLabels("fr", packs, 1750))
+	assert.Equal(t, &LabelsResponse{Packs: []string{}, Summary: "0 colis, 0 unité"}, packLabels("fr", nil, 0))
+}
+
+func TestCalculatePacksLabels(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/calculate?quantity=106&strategy=dp", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "labels")
+
+	req := httptest.NewRequest("GET", "/calculate?quantity=106&strategy=dp&labels=true", nil)
+	req.Header.Set("Accept-Language", "de-DE")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "de", w.Header().Get("Content-Language"))
+	assert.Contains(t, w.Body.String(), `"labels":{"packs":["2 × 53er-Packungen"],"summary":"2 Packungen, 106 Stück"}`)
+
+	req = httptest.NewRequest("POST", "/calculate/order?labels=1", strings.NewReader(`{"items": [{"sku": "SKU-1", "quantity": 50}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"labels":{"packs":["1 × 53-unit box"],"summary":"1 box, 53 units"}`)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/calculate?quantity=106&labels=maybe", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":"invalid labels","code":"invalid_labels"}`, w.Body.String())
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LatencyObserver records how long calculation requests take, so separate
+// latency SLOs can be set for small interactive quantities and huge batch
+// ones. metrics.Metrics is one. Implementations must be safe for concurrent
+// use.
+type LatencyObserver interface {
+	// ObserveLatency records a request to route (without base path and
+	// version, e.g. "/calculate") for a quantity in bucket; see
+	// QuantityBucket.
+	ObserveLatency(method, route, bucket string, status int, d time.Duration)
+}
+
+// SetLatencyObserver reports the latency of calculation requests to o. It
+// must be called before RegisterRoutes; without it nothing is measured.
+func (h *Handler) SetLatencyObserver(o LatencyObserver) {
+	h.latencyObserver = o
+}
+
+// quantityKey is the context key under which calculation handlers pass the
+// quantity they calculate to the latency middleware.
+const quantityKey = "quantity"
+
+// Quantity magnitude buckets, by their inclusive upper bound.
+var quantityBuckets = []struct {
+	max   int
+	label string
+}{
+	{100, "1-100"},
+	{10_000, "100-10k"},
+	{1_000_000, "10k-1M"},
+}
+
+// QuantityBucket returns the magnitude bucket of a quantity: "1-100",
+// "100-10k", "10k-1M" or "1M+", each including its upper bound.
+func QuantityBucket(quantity int) string {
+	for _, b := range quantityBuckets {
+		if quantity <= b.max {
+			return b.label
+		}
+	}
+	return "1M+"
+}
+
+// latency reports the duration of requests whose handler set quantityKey to
+// the latency observer, tagged with the quantity's bucket. A multi-item
+// order counts as the sum of its lines, a weight in grams.
+func (h *Handler) latency(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	quantity, ok := c.Get(quantityKey)
+	if !ok {
+		return
+	}
+	status := c.Writer.Status()
+	if !c.Writer.Written() && errors.Is(c.Request.Context().Err(), context.DeadlineExceeded) {
+		// The Timeout middleware answers once this handler returns.
+		status = http.StatusGatewayTimeout
+	}
+	route := routeKey(c.FullPath(), h.basePath)
+	h.latencyObserver.ObserveLatency(c.Request.Method, route, QuantityBucket(quantity.(int)), status, time.Since(start))
+}
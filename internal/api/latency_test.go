@@ -0,0 +1,82 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/n-th/gymshark/internal/allocator"
+)
+
+type observation struct {
+	method, route, bucket string
+	status                int
+}
+
+type recordingObserver struct {
+	mu           sync.Mutex
+	observations []observation
+}
+
+func (o *recordingObserver) ObserveLatency(method, route, bucket string, status int, d time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.observations = append(o.observations, observation{method, route, bucket, status})
+}
+
+func TestQuantityBucket(t *testing.T) {
+	for quantity, want := range map[int]string{
+		1:         "1-100",
+		100:       "1-100",
+		101:       "100-10k",
+		10000:     "100-10k",
+		10001:     "10k-1M",
+		1000000:   "10k-1M",
+		1000001:   "1M+",
+		100000000: "1M+",
+	} {
+		assert.Equal(t, want, QuantityBucket(quantity), quantity)
+	}
+}
+
+func TestLatencyObserver(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler := NewHandler(allocator.NewAllocator([]int{23, 31, 53}, newMockStorage()))
+	assert.NoError(t, handler.allocator.SetWeightPackSizes([]allocator.Weight{500, allocator.Kilogram, 2500}))
+	observer := &recordingObserver{}
+	handler.SetLatencyObserver(observer)
+	handler.RegisterRoutes(router)
+
+	for _, r := range []struct{ method, path, body string }{
+		{"GET", "/calculate?quantity=50", ""},
+		{"GET", "/v1/calculate?quantity=20000", ""},
+		{"POST", "/calculate", `{"quantity": 500}`},
+		{"GET", "/calculate?unit=weight&quantity=3.2", ""},
+		{"POST", "/calculate/order", `{"items": [{"sku": "A", "quantity": 60}, {"sku": "B", "quantity": 60}]}`},
+		// Not calculations
+		{"GET", "/recent", ""},
+		{"GET", "/calculate?quantity=abc", ""},
+		{"GET", "/calculate?quantity=0", ""},
+	} {
+		req := httptest.NewRequest(r.method, r.path, strings.NewReader(r.body))
+		if r.body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	assert.Equal(t, []observation{
+		{"GET", "/calculate", "1-100", http.StatusOK},
+		{"GET", "/calculate", "10k-1M", http.StatusOK},
+		{"POST", "/calculate", "100-10k", http.StatusOK},
+		{"GET", "/calculate", "100-10k", http.StatusOK},
+		{"POST", "/calculate/order", "100-10k", http.StatusOK},
+	}, observer.observations)
+}
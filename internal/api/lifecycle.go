@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PreStop configures what runs before the instance is terminated, so that a
+// rolling update loses no requests and no writes.
+type PreStop struct {
+	// DrainDelay is how long the instance keeps serving while reported not
+	// ready, so that the ingress and load balancers stop routing to it.
+	DrainDelay time.Duration
+	// DrainTimeout bounds the wait for requests in flight to finish after
+	// the delay; live calculator connections, for one, may never finish.
+	DrainTimeout time.Duration
+	// Flush runs last, e.g. to write the allocations queued in the outbox.
+	Flush func() error
+}
+
+// drainPoll is how often requests in flight are counted while draining.
+const drainPoll = 50 * time.Millisecond
+
+// lifecycle tracks the requests in flight and the pre-stop sequence.
+type lifecycle struct {
+	preStop  PreStop
+	inFlight atomic.Int64
+	stopping atomic.Bool
+	once     sync.Once
+	// done is closed when the sequence has run, with remaining and err
+	// set.
+	done      chan struct{}
+	remaining int64
+	err       error
+}
+
+// SetPreStop configures the sequence PreStop runs. It must be called before
+// the handler serves requests.
+func (h *Handler) SetPreStop(p PreStop) {
+	h.lifecycle.preStop = p
+}
+
+// track counts the requests in flight.
+func (h *Handler) track(c *gin.Context) {
+	h.lifecycle.inFlight.Add(1)
+	defer h.lifecycle.inFlight.Add(-1)
+	c.Next()
+}
+
+// Stopping reports whether PreStop was called.
+func (h *Handler) Stopping() bool {
+	return h.lifecycle.stopping.Load()
+}
+
+// PreStop reports the instance not ready on /health/ready, waits for the
+// drain delay and for the requests in flight to finish, up to the drain
+// timeout, and then flushes pending writes. The sequence runs once: later
+// calls wait for it and return its outcome. It returns how many requests
+// were still in flight when draining ended, and ctx's error if ctx is done
+// first, in which case the sequence carries on.
+func (h *Handler) PreStop(ctx context.Context) (int64, error) {
+	l := &h.lifecycle
+	l.once.Do(func() {
+		l.stopping.Store(true)
+		go h.runPreStop()
+	})
+	select {
+	case <-l.done:
+		return l.remaining, l.err
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+func (h *Handler) runPreStop() {
+	l := &h.lifecycle
+	defer close(l.done)
+	p := l.preStop
+
+	log.Printf("Pre-stop: reporting not ready and draining for %v", p.DrainDelay)
+	time.Sleep(p.DrainDelay)
+
+	deadline := time.Now().Add(p.DrainTimeout)
+	for l.inFlight.Load() > 0 && time.Now().Before(deadline) {
+		time.Sleep(drainPoll)
+	}
+	l.remaining = l.inFlight.Load()
+	if l.remaining > 0 {
+		log.Printf("Pre-stop: %d requests still in flight after %v", l.remaining, p.DrainTimeout)
+	}
+
+	if p.Flush != nil {
+		l.err = p.Flush()
+	}
+	if l.err != nil {
+		log.Printf("Pre-stop: flushing failed: %v", l.err)
+		return
+	}
+	log.Println("Pre-stop: drained and flushed, ready to stop")
+}
+
+// @Summary Prepare for termination
+// @Description Report the instance not ready on /health/ready, keep serving for server.prestop.drain_delay so that the ingress stops routing to it, wait up to drain_timeout for the requests in flight to finish, then flush the allocation outbox and the storage fallback spool. Call it from a Kubernetes preStop hook; SIGTERM runs the same sequence. It runs once: later calls wait for it.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} PreStopResponse "Drained and flushed"
+// @Failure 500 {object} ErrorResponse "Flushing failed"
+// @Failure 504 {object} ErrorResponse "Request timed out before draining ended"
+// @Router /v1/admin/prestop [post]
+func (h *Handler) preStop(c *gin.Context) {
+	// This request is in flight until the sequence ends, so it does not
+	// count towards the requests being drained.
+	h.lifecycle.inFlight.Add(-1)
+	defer h.lifecycle.inFlight.Add(1)
+
+	remaining, err := h.PreStop(c.Request.Context())
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, PreStopResponse{Stopping: true, InFlight: remaining})
+	}
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreStop(t *testing.T) {
+	router, handler := setupTestRouter()
+	flushes := 0
+	handler.SetPreStop(PreStop{DrainDelay: 10 * time.Millisecond, DrainTimeout: time.Second, Flush: func() error {
+		flushes++
+		return nil
+	}})
+
+	ready := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/health/ready", nil))
+		return w
+	}
+	preStop := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/admin/prestop", nil))
+		return w
+	}
+	assert.Equal(t, http.StatusOK, ready().Code)
+
+	// A request in flight is waited for.
+	handler.lifecycle.inFlight.Add(1)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		handler.lifecycle.inFlight.Add(-1)
+	}()
+	start := time.Now()
+	w := preStop()
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"stopping": true, "in_flight": 0}`, w.Body.String())
+	assert.Equal(t, 1, flushes)
+
+	w = ready()
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"status": "stopping", "checks": {}}`, w.Body.String())
+
+	// The sequence only runs once.
+	w = preStop()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, flushes)
+}
+
+func TestPreStopDrainTimeout(t *testing.T) {
+	router, handler := setupTestRouter()
+	handler.SetPreStop(PreStop{DrainTimeout: 50 * time.Millisecond, Flush: func() error {
+		return errors.New("storage down")
+	}})
+	handler.lifecycle.inFlight.Add(1)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/admin/prestop", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"error": "storage down"}`, w.Body.String())
+
+	remaining, err := handler.PreStop(context.Background())
+	assert.Equal(t, int64(1), remaining)
+	assert.EqualError(t, err, "storage down")
+}
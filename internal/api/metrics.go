@@ -0,0 +1,23 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetMetrics serves m on GET /metrics. It must be called before
+// RegisterRoutes; without it the route is not registered.
+func (h *Handler) SetMetrics(m http.Handler) {
+	h.metrics = m
+}
+
+// @Summary Get service metrics
+// @Description Prometheus metrics, in the OpenMetrics format when requested through the Accept header. Includes histograms of waste and packs per allocation by profile a gauge of distinct quantities in the negative cache, the calculations in flight, queued and shed by admission control, and a histogram of calculation request durations by route, status and quantity bucket (1-100, 100-10k, 10k-1M, 1M+).
+// @Tags health
+// @Produce plain
+// @Success 200 {string} string "Metrics exposition"
+// @Router /metrics [get]
+func (h *Handler) getMetrics(c *gin.Context) {
+	h.metrics.ServeHTTP(c.Writer, c.Request)
+}
@@ -0,0 +1,325 @@
+package api
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultCompressionMinSize is the smallest response body worth compressing.
+const DefaultCompressionMinSize = 1024
+
+// compressor is a streaming response encoder.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// encoders lists the supported content encodings in order of preference.
+var encoders = []struct {
+	name string
+	new  func(w io.Writer) compressor
+}{
+	{"br", func(w io.Writer) compressor { return brotli.NewWriter(w) }},
+	{"gzip", func(w io.Writer) compressor { return gzip.NewWriter(w) }},
+	{"deflate", func(w io.Writer) compressor {
+		fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+		return fw
+	}},
+}
+
+// Compression returns middleware that compresses response bodies of at least
+// minSize bytes using the best encoding the client accepts. Streamed responses
+// that flush before reaching minSize are compressed as soon as they flush.
+func Compression(minSize int) gin.HandlerFunc {
+	if minSize < 0 {
+		minSize = 0
+	}
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{ResponseWriter: c.Writer, encoding: encoding, minSize: minSize}
+		c.Writer = w
+		defer w.close()
+		c.Next()
+	}
+}
+
+// negotiateEncoding picks a supported encoding from an Accept-Encoding header,
+// honouring q-values and breaking ties by server preference.
+func negotiateEncoding(header string) string {
+	if header == "" {
+		return ""
+	}
+
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		accepted[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, e := range encoders {
+		q, ok := accepted[e.name]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = e.name, q
+		}
+	}
+	return best
+}
+
+// compressWriter buffers the start of a response until it knows whether the
+// body is large enough to compress, then streams through the encoder.
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	minSize  int
+	buf      []byte
+	started  bool
+	enc      compressor
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.started {
+		return w.write(p)
+	}
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.minSize {
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush sends buffered output to the client, committing to compression.
+func (w *compressWriter) Flush() {
+	if !w.started {
+		if err := w.start(true); err != nil {
+			return
+		}
+	}
+	if w.enc != nil {
+		if err := w.enc.Flush(); err != nil {
+			return
+		}
+	}
+	w.ResponseWriter.Flush()
+}
+
+// close finishes the response once the handler chain has returned.
+func (w *compressWriter) close() {
+	if !w.started {
+		if err := w.start(len(w.buf) > 0 && len(w.buf) >= w.minSize); err != nil {
+			return
+		}
+	}
+	if w.enc != nil {
+		w.enc.Close()
+	}
+}
+
+// start decides whether to compress and writes out anything buffered so far.
+func (w *compressWriter) start(compress bool) error {
+	w.started = true
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" || !bodyAllowed(w.Status()) {
+		compress = false
+	}
+	if compress {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		for _, e := range encoders {
+			if e.name == w.encoding {
+				w.enc = e.new(w.ResponseWriter)
+				break
+			}
+		}
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := w.write(buf)
+	return err
+}
+
+func (w *compressWriter) write(p []byte) (int, error) {
+	if w.enc != nil {
+		return w.enc.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// bodyAllowed reports whether a response with the given status may carry a body.
+func bodyAllowed(status int) bool {
+	return status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// MaxBodySize returns middleware that limits POST, PUT and PATCH request
+// bodies to limit bytes. Requests declaring a larger Content-Length are
+// rejected with 413 up front; others fail with 413 when decoding overruns.
+// A limit of zero or less disables the check.
+func MaxBodySize(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limit <= 0 || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+		default:
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength > limit {
+			c.Abort()
+			writeError(c, http.StatusRequestEntityTooLarge, codeBodyTooLarge, limit)
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+// bindJSON decodes the request body into v and validates it against the
+// binding tags of v. On failure it writes a 413 when the body limit was
+// exceeded, or a 400 otherwise, listing the failed fields when there are
+// any, and returns false.
+func bindJSON(c *gin.Context, v interface{}) bool {
+	err := c.ShouldBindJSON(v)
+	if err == nil {
+		return true
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(c, http.StatusRequestEntityTooLarge, codeBodyTooLarge, tooLarge.Limit)
+		return false
+	}
+	if errs := fieldErrors(err); len(errs) > 0 {
+		writeFieldErrors(c, errs...)
+		return false
+	}
+	writeError(c, http.StatusBadRequest, codeInvalidBody)
+	return false
+}
+
+// Timeouts configures the Timeout middleware. Routes are keyed by the
+// registered route pattern, optionally prefixed with a method, e.g.
+// "/recent" or "POST /calculate"; a method-specific entry wins. Routes
+// without an entry use Default. A zero duration disables the deadline.
+type Timeouts struct {
+	Default time.Duration
+	Routes  map[string]time.Duration
+	// BasePath is the prefix the routes are mounted under; route keys
+	// leave it out.
+	BasePath string
+}
+
+// For returns the timeout for a request to the given route pattern.
+func (t Timeouts) For(method, route string) time.Duration {
+	if d, ok := t.Routes[method+" "+route]; ok {
+		return d
+	}
+	if d, ok := t.Routes[route]; ok {
+		return d
+	}
+	return t.Default
+}
+
+// NormalizeBasePath validates a URL prefix for the routes, such as
+// "/pack-api", and strips any trailing slash. "" and "/" mount the routes
+// at the root.
+func NormalizeBasePath(p string) (string, error) {
+	p = strings.TrimRight(p, "/")
+	if p == "" {
+		return "", nil
+	}
+	if !strings.HasPrefix(p, "/") || strings.ContainsAny(p, ":*?# ") {
+		return "", fmt.Errorf("invalid base path %q: want a path such as \"/pack-api\"", p)
+	}
+	return p, nil
+}
+
+// Validate checks that every timeout is non-negative and every route key
+// is a path, optionally prefixed with a method.
+func (t Timeouts) Validate() error {
+	if t.Default < 0 {
+		return errors.New("default timeout must not be negative")
+	}
+	for key, d := range t.Routes {
+		if d < 0 {
+			return fmt.Errorf("timeout for %q must not be negative", key)
+		}
+		route := key
+		if i := strings.IndexByte(key, ' '); i >= 0 {
+			route = key[i+1:]
+		}
+		if !strings.HasPrefix(route, "/") {
+			return fmt.Errorf("invalid timeout route %q: want \"/path\" or \"METHOD /path\"", key)
+		}
+	}
+	return nil
+}
+
+// Timeout returns middleware that bounds each request by its route's
+// timeout. The deadline is set on the request context, so context-aware
+// work downstream is cancelled when it passes; if the handler gives up
+// without writing a response, a 504 is sent.
+func Timeout(t Timeouts) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		d := t.For(c.Request.Method, routeKey(c.FullPath(), t.BasePath))
+		if d <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			c.Abort()
+			writeError(c, http.StatusGatewayTimeout, codeRequestTimeout)
+		}
+	}
+}
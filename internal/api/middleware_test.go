@@ -0,0 +1,227 @@
+package api
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"deflate, gzip", "gzip"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"gzip;q=0", ""},
+		{"br", "br"},
+		{"gzip, br", "br"},
+		{"br;q=0.1, gzip", "gzip"},
+		{"*", "br"},
+		{"identity", ""},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, negotiateEncoding(tt.header), tt.header)
+	}
+}
+
+func setupCompressionRouter(minSize int, body string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Compression(minSize))
+	router.GET("/body", func(c *gin.Context) {
+		c.String(http.StatusOK, body)
+	})
+	router.GET("/stream", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+		c.Writer.WriteString("first,")
+		c.Writer.Flush()
+		c.Writer.WriteString("second")
+	})
+	return router
+}
+
+func TestCompression(t *testing.T) {
+	large := strings.Repeat("allocation,", 200)
+	router := setupCompressionRouter(1024, large)
+
+	req := httptest.NewRequest("GET", "/body", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	assert.Less(t, w.Body.Len(), len(large))
+
+	zr, err := gzip.NewReader(w.Body)
+	assert.NoError(t, err)
+	decoded, err := io.ReadAll(zr)
+	assert.NoError(t, err)
+	assert.Equal(t, large, string(decoded))
+
+	req = httptest.NewRequest("GET", "/body", nil)
+	req.Header.Set("Accept-Encoding", "br")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "br", w.Header().Get("Content-Encoding"))
+	decoded, err = io.ReadAll(brotli.NewReader(w.Body))
+	assert.NoError(t, err)
+	assert.Equal(t, large, string(decoded))
+
+	// Clients that do not ask for compression get the plain body
+	req = httptest.NewRequest("GET", "/body", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, large, w.Body.String())
+}
+
+func TestCompressionSmallBody(t *testing.T) {
+	router := setupCompressionRouter(1024, "small")
+
+	req := httptest.NewRequest("GET", "/body", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "small", w.Body.String())
+}
+
+func TestCompressionStreaming(t *testing.T) {
+	router := setupCompressionRouter(1024, "")
+
+	req := httptest.NewRequest("GET", "/stream", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	zr, err := gzip.NewReader(w.Body)
+	assert.NoError(t, err)
+	decoded, err := io.ReadAll(zr)
+	assert.NoError(t, err)
+	assert.Equal(t, "first,second", string(decoded))
+}
+
+func TestMaxBodySize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(MaxBodySize(64))
+	handler := NewHandler(nil)
+	router.POST("/calculate", handler.calculatePacksWithReference)
+
+	body := `{"quantity": 1, "order_id": "` + strings.Repeat("x", 100) + `"}`
+
+	// Declared Content-Length over the limit is rejected before reading
+	req := httptest.NewRequest("POST", "/calculate", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), "exceeds 64 bytes")
+
+	// Chunked bodies are cut off while decoding
+	req = httptest.NewRequest("POST", "/calculate", io.NopCloser(strings.NewReader(body)))
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	// Malformed bodies within the limit are still a 400
+	req = httptest.NewRequest("POST", "/calculate", strings.NewReader("{"))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestTimeoutsFor(t *testing.T) {
+	timeouts := Timeouts{
+		Default: 30 * time.Second,
+		Routes: map[string]time.Duration{
+			"/recent":         5 * time.Second,
+			"/calculate":      time.Minute,
+			"POST /calculate": 2 * time.Minute,
+			"/ws/calculate":   0,
+		},
+	}
+
+	assert.Equal(t, 5*time.Second, timeouts.For(http.MethodGet, "/recent"))
+	assert.Equal(t, time.Minute, timeouts.For(http.MethodGet, "/calculate"))
+	assert.Equal(t, 2*time.Minute, timeouts.For(http.MethodPost, "/calculate"))
+	assert.Equal(t, time.Duration(0), timeouts.For(http.MethodGet, "/ws/calculate"))
+	assert.Equal(t, 30*time.Second, timeouts.For(http.MethodGet, "/health"))
+	assert.NoError(t, timeouts.Validate())
+
+	assert.Error(t, Timeouts{Default: -time.Second}.Validate())
+	assert.Error(t, Timeouts{Routes: map[string]time.Duration{"/recent": -time.Second}}.Validate())
+	assert.Error(t, Timeouts{Routes: map[string]time.Duration{"recent": time.Second}}.Validate())
+}
+
+func TestTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Timeout(Timeouts{
+		Default: time.Second,
+		Routes: map[string]time.Duration{
+			"/slow":     20 * time.Millisecond,
+			"/unbound":  0,
+			"/answered": 20 * time.Millisecond,
+		},
+	}))
+
+	// waitForDeadline blocks until the request context is done, like a
+	// cancellable calculation, and reports whether it was cancelled.
+	cancelled := make(chan bool, 1)
+	waitForDeadline := func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+			cancelled <- true
+		case <-time.After(time.Second):
+			cancelled <- false
+			c.String(http.StatusOK, "done")
+		}
+	}
+	router.GET("/slow", waitForDeadline)
+	router.GET("/unbound", func(c *gin.Context) {
+		_, ok := c.Request.Context().Deadline()
+		assert.False(t, ok)
+		c.String(http.StatusOK, "ok")
+	})
+	router.GET("/answered", func(c *gin.Context) {
+		<-c.Request.Context().Done()
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "calculation timeout"})
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.JSONEq(t, `{"error":"request timeout","code":"request_timeout"}`, w.Body.String())
+	assert.True(t, <-cancelled)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/unbound", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// A handler that reports the timeout itself keeps its response
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/answered", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.JSONEq(t, `{"error":"calculation timeout"}`, w.Body.String())
+}
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	_ "github.com/n-th/gymshark/docs" // registers the generated spec
+	"github.com/swaggo/swag"
+)
+
+// @Summary OpenAPI specification
+// @Description Serve the generated API specification (Swagger 2.0 / OpenAPI 2) as JSON for client code generation
+// @Tags docs
+// @Produce json
+// @Success 200 {object} object "API specification"
+// @Failure 500 {object} ErrorResponse "Error message"
+// @Router /openapi.json [get]
+func (h *Handler) openAPISpec(c *gin.Context) {
+	doc, err := swag.ReadDoc()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", []byte(doc))
+}
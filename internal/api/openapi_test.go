@@ -0,0 +1,40 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/openapi.json", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+
+	var spec struct {
+		Swagger     string                     `json:"swagger"`
+		Paths       map[string]json.RawMessage `json:"paths"`
+		Definitions map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"definitions"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+	assert.Equal(t, "2.0", spec.Swagger)
+	assert.Contains(t, spec.Paths, "/v1/calculate")
+	assert.Contains(t, spec.Paths, "/openapi.json")
+
+	// Responses are described by typed models, not empty objects
+	calculate := spec.Definitions["api.CalculateResponse"]
+	assert.Contains(t, calculate.Properties, "packs")
+	assert.Contains(t, calculate.Properties, "total")
+	assert.Contains(t, spec.Definitions["api.ErrorResponse"].Properties, "error")
+	assert.Contains(t, spec.Definitions["storage.Allocation"].Properties, "OrderQuantity")
+}
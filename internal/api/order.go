@@ -0,0 +1,119 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/allocator"
+)
+
+// orderItem is a single line of an order calculation request.
+type orderItem struct {
+	SKU      string `json:"sku"`
+	Quantity int    `json:"quantity" binding:"gt=0"`
+	Profile  string `json:"profile,omitempty"`
+}
+
+// orderRequest is the body accepted by POST /calculate/order.
+type orderRequest struct {
+	OrderID    string                 `json:"order_id"`
+	CustomerID string                 `json:"customer_id"`
+	Strategy   string                 `json:"strategy"`
+	Metadata   map[string]interface{} `json:"metadata"`
+	Items      []orderItem            `json:"items" binding:"required,min=1,dive"`
+}
+
+// @Summary Calculate pack distribution for a multi-item order
+// @Description Allocate packs for every SKU line of an order using each SKU's pack-size profile, and summarise the order
+// @Tags packs
+// @Accept json
+// @Produce json
+// @Param request body orderRequest true "Order lines"
+// @Param format query string false "Packs format: map (default), list or flat" Enums(map, list, flat)
+// @Param labels query bool false "Include labels describing each line's packs in the language negotiated from Accept-Language"
+// @Success 200 {object} OrderResponse "Per-item allocations and order summary"
+// @Failure 400 {object} ValidationErrorResponse "Invalid fields"
+// @Failure 413 {object} ErrorResponse "Request body too large"
+// @Failure 422 {object} InfeasibleResponse "No feasible combination"
+// @Failure 429 {object} ErrorResponse "Usage quota exceeded"
+// @Failure 503 {object} ErrorResponse "Read-only mode or overloaded"
+// @Failure 504 {object} ErrorResponse "Error message"
+// @Router /v1/calculate/order [post]
+func (h *Handler) calculateOrder(c *gin.Context) {
+	format, err := requestedFormat(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, codeInvalidFormat)
+		return
+	}
+	labels, err := labelsRequested(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, codeInvalidLabels)
+		return
+	}
+	var body orderRequest
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	req := allocator.OrderRequest{
+		OrderID:    body.OrderID,
+		CustomerID: body.CustomerID,
+		Strategy:   body.Strategy,
+		Metadata:   body.Metadata,
+	}
+	quantity := 0
+	for _, item := range body.Items {
+		req.Lines = append(req.Lines, allocator.OrderLine{
+			SKU:      item.SKU,
+			Quantity: item.Quantity,
+			Profile:  item.Profile,
+		})
+		quantity += item.Quantity
+	}
+	c.Set(quantityKey, quantity)
+
+	order, err := h.allocator.AllocateOrder(c.Request.Context(), req)
+	if err != nil {
+		writeAllocationError(c, err)
+		return
+	}
+
+	response := OrderResponse{
+		Items: make([]OrderItemResponse, 0, len(order.Lines)),
+		Summary: OrderSummaryResponse{
+			TotalQuantity: order.TotalQuantity,
+			TotalItems:    order.TotalItems,
+			TotalPacks:    order.TotalPacks,
+			TotalWaste:    order.TotalWaste,
+			Approximate:   order.Approximate,
+		},
+		OrderID:    body.OrderID,
+		CustomerID: body.CustomerID,
+	}
+	lang := requestLanguage(c)
+	if labels {
+		c.Header("Content-Language", lang)
+	}
+	for _, line := range order.Lines {
+		item := OrderItemResponse{
+			SKU:             line.SKU,
+			Quantity:        line.Quantity,
+			Profile:         line.Profile,
+			Packs:           format.formatPacks(line.Packs),
+			Total:           line.Total,
+			Waste:           line.Waste(),
+			PackCount:       line.PackCount(),
+			Approximate:     line.Approximate,
+			Cached:          line.Stats.Cache == allocator.CacheHit,
+			ComputedAt:      line.ComputedAt,
+			Source:          line.Source,
+			DeprecatedPacks: line.DeprecatedPacks,
+		}
+		item.Rules, item.Flags = ruleMatchesResponse(line.Rules)
+		if labels {
+			item.Labels = packLabels(lang, line.Packs, line.Total)
+		}
+		response.Items = append(response.Items, item)
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalculateOrder(t *testing.T) {
+	router, handler := setupTestRouter()
+	assert.NoError(t, handler.allocator.SetProfiles(
+		map[string][]int{"small": {5, 10, 20}},
+		map[string]string{"SKU-SMALL": "small"},
+	))
+
+	body := `{"order_id": "ORD-1", "items": [{"sku": "SKU-SMALL", "quantity": 7}, {"sku": "SKU-BIG", "quantity": 50}]}`
+	req := httptest.NewRequest("POST", "/calculate/order", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		OrderID string `json:"order_id"`
+		Items   []struct {
+			SKU     string         `json:"sku"`
+			Profile string         `json:"profile"`
+			Packs   map[string]int `json:"packs"`
+			Total   int            `json:"total"`
+			Waste   int            `json:"waste"`
+		} `json:"items"`
+		Summary struct {
+			TotalPacks int `json:"total_packs"`
+			TotalWaste int `json:"total_waste"`
+		} `json:"summary"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "ORD-1", response.OrderID)
+	assert.Len(t, response.Items, 2)
+	assert.Equal(t, "small", response.Items[0].Profile)
+	assert.Equal(t, map[string]int{"10": 1}, response.Items[0].Packs)
+	assert.Equal(t, 3, response.Items[0].Waste)
+	assert.Equal(t, "default", response.Items[1].Profile)
+	assert.Equal(t, 2, response.Summary.TotalPacks)
+	assert.Equal(t, 6, response.Summary.TotalWaste)
+
+	// Invalid orders
+	for _, body := range []string{`{"items": []}`, `{"items": [{"sku": "A", "quantity": -1}]}`, `{"items": [{"sku": "A", "quantity": 1, "profile": "missing"}]}`, `[]`} {
+		req = httptest.NewRequest("POST", "/calculate/order", strings.NewReader(body))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+// pinRequest is the body accepted by PUT /allocations/pin.
+// Packs maps pack size to the number of packs of that size.
+type pinRequest struct {
+	Quantity int         `json:"quantity" binding:"gt=0" example:"600"`
+	Profile  string      `json:"profile" example:"default"`
+	Packs    map[int]int `json:"packs" binding:"required,min=1,dive,keys,gt=0,endkeys,gt=0"`
+	Reason   string      `json:"reason" example:"summer bundle"`
+}
+
+// @Summary Pin an allocation
+// @Description Store a manual pack breakdown that /calculate returns for the quantity and profile, flagged with "source": "manual", until it is unpinned. Pinning a pinned quantity replaces its pin. Constrained calculations are not affected.
+// @Tags allocations
+// @Accept json
+// @Produce json
+// @Param request body pinRequest true "Quantity, profile (default when empty) and packs"
+// @Success 200 {object} PinResponse "The stored pin"
+// @Failure 400 {object} ValidationErrorResponse "Invalid fields"
+// @Failure 500 {object} ErrorResponse "Error message"
+// @Failure 502 {object} ErrorResponse "Pinned locally but not propagated to other replicas"
+// @Failure 503 {object} ErrorResponse "Read-only mode"
+// @Router /v1/allocations/pin [put]
+func (h *Handler) pinAllocation(c *gin.Context) {
+	var body pinRequest
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	pin, err := h.allocator.Pin(c.Request.Context(), storage.Pin{
+		Quantity: body.Quantity,
+		Profile:  body.Profile,
+		Packs:    body.Packs,
+		Reason:   body.Reason,
+	})
+	if err != nil {
+		writeDomainError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, pinResponse(pin))
+}
+
+// @Summary Unpin an allocation
+// @Description Remove the pin of a quantity, so /calculate computes it again
+// @Tags allocations
+// @Produce json
+// @Param quantity query int true "Pinned quantity"
+// @Param profile query string false "Profile name (default when empty)"
+// @Success 204 "Pin removed"
+// @Failure 400 {object} ErrorResponse "Error message"
+// @Failure 404 {object} ErrorResponse "Quantity not pinned"
+// @Failure 500 {object} ErrorResponse "Error message"
+// @Failure 502 {object} ErrorResponse "Unpinned locally but not propagated to other replicas"
+// @Failure 503 {object} ErrorResponse "Read-only mode"
+// @Router /v1/allocations/pin [delete]
+func (h *Handler) unpinAllocation(c *gin.Context) {
+	quantity, err := strconv.Atoi(c.Query("quantity"))
+	if err != nil || quantity <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid quantity"})
+		return
+	}
+
+	removed, err := h.allocator.Unpin(c.Request.Context(), quantity, c.Query("profile"))
+	if err != nil {
+		writeDomainError(c, err)
+		return
+	}
+	if !removed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "quantity not pinned"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary List pinned allocations
+// @Description List the manual pack breakdowns served by /calculate, ordered by profile and quantity
+// @Tags allocations
+// @Produce json
+// @Success 200 {object} PinsResponse "Pinned allocations"
+// @Router /v1/allocations/pins [get]
+func (h *Handler) getPins(c *gin.Context) {
+	pins := h.allocator.Pins()
+	response := PinsResponse{Pins: make([]PinResponse, 0, len(pins))}
+	for _, p := range pins {
+		response.Pins = append(response.Pins, pinResponse(p))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func pinResponse(p storage.Pin) PinResponse {
+	return PinResponse{
+		Quantity:  p.Quantity,
+		Profile:   p.Profile,
+		Packs:     p.Packs,
+		Total:     p.Total,
+		Reason:    p.Reason,
+		CreatedAt: p.CreatedAt,
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPinAllocation(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	req := httptest.NewRequest("PUT", "/allocations/pin", strings.NewReader(`{"quantity": 50, "packs": {"23": 1, "31": 1}, "reason": "bundle"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var pin PinResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &pin))
+	assert.Equal(t, "default", pin.Profile)
+	assert.Equal(t, 54, pin.Total)
+
+	req = httptest.NewRequest("GET", "/calculate?quantity=50", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var calc CalculateResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &calc))
+	assert.Equal(t, "manual", calc.Source)
+	assert.Equal(t, 54, calc.Total)
+
+	req = httptest.NewRequest("GET", "/allocations/pins", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var pins PinsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &pins))
+	assert.Len(t, pins.Pins, 1)
+	assert.Equal(t, "bundle", pins.Pins[0].Reason)
+
+	req = httptest.NewRequest("DELETE", "/allocations/pin?quantity=50", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	req = httptest.NewRequest("GET", "/calculate?quantity=50", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.NotContains(t, w.Body.String(), `"source"`)
+	assert.Contains(t, w.Body.String(), `"total":53`)
+
+	req = httptest.NewRequest("DELETE", "/allocations/pin?quantity=50", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestPinAllocationErrors(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		status int
+	}{
+		{"unknown size", "PUT", "/allocations/pin", `{"quantity": 50, "packs": {"25": 2}}`, http.StatusBadRequest},
+		{"short", "PUT", "/allocations/pin", `{"quantity": 50, "packs": {"23": 1}}`, http.StatusBadRequest},
+		{"unknown profile", "PUT", "/allocations/pin", `{"quantity": 50, "profile": "missing", "packs": {"53": 1}}`, http.StatusBadRequest},
+		{"malformed", "PUT", "/allocations/pin", `{"quantity": "fifty"}`, http.StatusBadRequest},
+		{"unpin without quantity", "DELETE", "/allocations/pin", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
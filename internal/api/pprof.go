@@ -0,0 +1,80 @@
+package api
+
+import (
+	"expvar"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/auth"
+)
+
+// DebugEndpoints controls GET /debug/pprof and GET /debug/vars, the Go
+// runtime's profiles and expvar variables, for profiling the allocator
+// under production load.
+type DebugEndpoints struct {
+	Enabled bool
+	// AllowRemote serves them to every client rather than only to clients
+	// connecting from a loopback address or a UNIX socket. Either way they
+	// require the admin role when SetAdminAuth is used.
+	AllowRemote bool
+}
+
+// SetDebugEndpoints serves the debug endpoints as d allows. It must be called
+// before RegisterRoutes; without it the routes are not registered.
+func (h *Handler) SetDebugEndpoints(d DebugEndpoints) {
+	h.debug = d
+}
+
+// registerDebug registers the debug endpoints on r.
+func (h *Handler) registerDebug(r *gin.RouterGroup) {
+	debug := r.Group("/debug", h.localOnly, h.requireRole(auth.RoleAdmin))
+	debug.GET("/pprof/*profile", debugProfile)
+	debug.GET("/vars", gin.WrapH(expvar.Handler()))
+}
+
+// localOnly rejects requests from clients other than local ones with 403,
+// unless remote debug access is allowed. It looks at the connection's
+// address, not at X-Forwarded-For: behind a proxy on the same host every
+// request is local.
+func (h *Handler) localOnly(c *gin.Context) {
+	if h.debug.AllowRemote || isLocal(c.Request.RemoteAddr) {
+		c.Next()
+		return
+	}
+	c.Abort()
+	writeError(c, http.StatusForbidden, codeDebugForbidden)
+}
+
+// isLocal reports whether a connection's remote address is a loopback
+// address or, having no host and port, a UNIX socket peer.
+func isLocal(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr == "" || remoteAddr == "@"
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// debugProfile serves the pprof index and profiles. The handlers are
+// dispatched here rather than by net/http/pprof's path matching, which
+// assumes the routes are mounted at the root.
+func debugProfile(c *gin.Context) {
+	switch name := strings.TrimPrefix(c.Param("profile"), "/"); name {
+	case "":
+		pprof.Index(c.Writer, c.Request)
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		pprof.Handler(name).ServeHTTP(c.Writer, c.Request)
+	}
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setup := func(d DebugEndpoints, v AdminVerifier) *gin.Engine {
+		router := gin.New()
+		handler := NewHandler(allocator.NewAllocator([]int{23, 31, 53}, newMockStorage()))
+		handler.SetDebugEndpoints(d)
+		if v != nil {
+			handler.SetAdminAuth(v)
+		}
+		handler.RegisterRoutes(router)
+		return router
+	}
+	do := func(router *gin.Engine, target, remoteAddr, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		req.RemoteAddr = remoteAddr
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Not registered unless enabled.
+	router := setup(DebugEndpoints{}, nil)
+	assert.Equal(t, http.StatusNotFound, do(router, "/debug/vars", "127.0.0.1:5000", "").Code)
+
+	router = setup(DebugEndpoints{Enabled: true}, nil)
+	w := do(router, "/debug/pprof/", "127.0.0.1:5000", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "goroutine")
+	w = do(router, "/debug/pprof/goroutine?debug=1", "[::1]:5000", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.HasPrefix(w.Body.String(), "goroutine profile:"))
+	assert.Equal(t, http.StatusOK, do(router, "/debug/pprof/cmdline", "", "").Code)
+
+	w = do(router, "/debug/vars", "127.0.0.1:5000", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var vars map[string]json.RawMessage
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &vars))
+	assert.Contains(t, vars, "memstats")
+
+	// Remote clients are refused, even behind a proxy claiming otherwise.
+	req := httptest.NewRequest("GET", "/debug/vars", nil)
+	req.Header.Set("X-Forwarded-For", "127.0.0.1")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	var errResp ErrorResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+	assert.Equal(t, codeDebugForbidden, errResp.Code)
+
+	router = setup(DebugEndpoints{Enabled: true, AllowRemote: true}, nil)
+	assert.Equal(t, http.StatusOK, do(router, "/debug/vars", "192.0.2.1:5000", "").Code)
+
+	// With admin auth, the admin role is required too.
+	router = setup(DebugEndpoints{Enabled: true}, fakeVerifier{tokens: map[string]auth.Claims{
+		"reader-token": {Subject: "bob", Roles: []string{auth.RoleReader}},
+		"admin-token":  {Subject: "alice", Roles: []string{auth.RoleAdmin}},
+	}})
+	assert.Equal(t, http.StatusUnauthorized, do(router, "/debug/vars", "127.0.0.1:5000", "").Code)
+	assert.Equal(t, http.StatusForbidden, do(router, "/debug/vars", "127.0.0.1:5000", "reader-token").Code)
+	assert.Equal(t, http.StatusOK, do(router, "/debug/vars", "127.0.0.1:5000", "admin-token").Code)
+	assert.Equal(t, http.StatusForbidden, do(router, "/debug/vars", "192.0.2.1:5000", "admin-token").Code)
+}
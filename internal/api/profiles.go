@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/allocator"
+)
+
+// @Summary Get profile versions
+// @Description Get every recorded version of a pack-size profile with its effective date range
+// @Tags profiles
+// @Produce json
+// @Param name path string true "Profile name (\"default\" for the configured pack sizes)"
+// @Success 200 {object} ProfileVersionsResponse "Profile versions, oldest first"
+// @Failure 404 {object} ErrorResponse "Error message"
+// @Failure 500 {object} ErrorResponse "Error message"
+// @Router /v1/profiles/{name}/versions [get]
+func (h *Handler) getProfileVersions(c *gin.Context) {
+	name := c.Param("name")
+	versions, err := h.allocator.ProfileVersions(name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(versions) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "profile not found"})
+		return
+	}
+
+	response := ProfileVersionsResponse{
+		Name:     name,
+		Versions: make([]ProfileVersionResponse, 0, len(versions)),
+	}
+	for i, v := range versions {
+		var effectiveTo *time.Time
+		if i+1 < len(versions) {
+			effectiveTo = &versions[i+1].EffectiveFrom
+		}
+		response.Versions = append(response.Versions, ProfileVersionResponse{
+			Version:       v.Version,
+			PackSizes:     v.PackSizes,
+			EffectiveFrom: v.EffectiveFrom,
+			EffectiveTo:   effectiveTo,
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Validate pack-size profiles
+// @Description Check the default and named profiles against the configured pack-size rules: duplicate sizes, a size of 1 next to other sizes, sizes that are multiples of each other and too large a ratio between the largest and smallest size. Valid is false when any issue has error severity; profile updates with such issues are rejected.
+// @Tags profiles
+// @Produce json
+// @Success 200 {object} ProfileValidationResponse "Rule issues by profile"
+// @Router /v1/profiles/validate [get]
+func (h *Handler) validateProfiles(c *gin.Context) {
+	response := ProfileValidationResponse{Valid: true, Issues: []PackSizeIssueResponse{}}
+	for _, issue := range h.allocator.ValidateProfiles() {
+		if issue.Severity == allocator.SeverityError {
+			response.Valid = false
+		}
+		response.Issues = append(response.Issues, PackSizeIssueResponse{
+			Profile:  issue.Profile,
+			Rule:     issue.Rule,
+			Severity: string(issue.Severity),
+			Message:  issue.Message,
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
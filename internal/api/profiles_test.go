@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetProfileVersions(t *testing.T) {
+	router, handler := setupTestRouter()
+	assert.NoError(t, handler.allocator.RecordProfileVersions())
+
+	req := httptest.NewRequest("GET", "/profiles/default/versions", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Name     string `json:"name"`
+		Versions []struct {
+			Version     int         `json:"version"`
+			PackSizes   []int       `json:"pack_sizes"`
+			EffectiveTo interface{} `json:"effective_to"`
+		} `json:"versions"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "default", response.Name)
+	assert.Len(t, response.Versions, 1)
+	assert.Equal(t, 1, response.Versions[0].Version)
+	assert.Equal(t, []int{53, 31, 23}, response.Versions[0].PackSizes)
+	assert.Nil(t, response.Versions[0].EffectiveTo)
+
+	req = httptest.NewRequest("GET", "/profiles/missing/versions", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestValidateProfiles(t *testing.T) {
+	router, handler := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/profiles/validate", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"valid": true, "issues": []}`, w.Body.String())
+
+	assert.NoError(t, handler.allocator.SetProfiles(map[string][]int{"bulk": {1, 5000}, "dup": {250, 250}}, nil))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/profiles/validate", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"valid": false, "issues": [
+		{"profile": "bulk", "rule": "unit_pack", "severity": "warning", "message": "pack size 1 meets every quantity exactly, so the other sizes only reduce the pack count"},
+		{"profile": "bulk", "rule": "ratio", "severity": "warning", "message": "largest pack size 5000 is more than 1000 times the smallest, 1"},
+		{"profile": "dup", "rule": "duplicate", "severity": "error", "message": "pack size 250 is listed more than once"}
+	]}`, w.Body.String())
+
+	// Updates breaking a rule of error severity are rejected
+	req := httptest.NewRequest("PUT", "/admin/profiles/default", strings.NewReader(`{"pack_sizes": [500, 500]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "pack size 500 is listed more than once")
+}
@@ -0,0 +1,311 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/storage"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+	reportSummarySheet = "Summary"
+	reportDetailSheet  = "Allocations"
+)
+
+// reportColumns are the columns of the detail sheet, with their widths.
+var reportColumns = []struct {
+	title string
+	width float64
+}{
+	{"ID", 8},
+	{"Created (UTC)", 20},
+	{"Order ID", 16},
+	{"Customer ID", 16},
+	{"Profile", 12},
+	{"Quantity", 10},
+	{"Packed", 10},
+	{"Waste", 8},
+	{"Packs", 28},
+}
+
+// allocationReport accumulates the summary of the allocations in a report.
+type allocationReport struct {
+	allocations int
+	ordered     int64
+	packed      int64
+	waste       int64
+	// packs counts the packs of each size, profiles the allocations of
+	// each profile.
+	packs    map[int]int64
+	profiles map[string]int
+}
+
+func (r *allocationReport) add(a storage.Allocation) {
+	r.allocations++
+	r.ordered += int64(a.OrderQuantity)
+	r.packed += int64(a.Total)
+	r.waste += int64(allocationWaste(a))
+	for size, count := range a.Packs {
+		r.packs[size] += int64(count)
+	}
+	profile := a.Profile
+	if profile == "" {
+		profile = "default"
+	}
+	r.profiles[profile]++
+}
+
+// allocationWaste is how many items an allocation packs beyond its quantity;
+// allocations short of it waste none.
+func allocationWaste(a storage.Allocation) int {
+	return max(a.Total-a.OrderQuantity, 0)
+}
+
+// formatReportPacks lists packs largest first, e.g. "1000 x 2, 250 x 1".
+func formatReportPacks(packs map[int]int) string {
+	sizes := make([]int, 0, len(packs))
+	for size := range packs {
+		sizes = append(sizes, size)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+	parts := make([]string, len(sizes))
+	for i, size := range sizes {
+		parts[i] = fmt.Sprintf("%d x %d", size, packs[size])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// reportStyles are the cell styles of a report workbook.
+type reportStyles struct {
+	title, header, date, percent int
+}
+
+func newReportStyles(f *excelize.File) (reportStyles, error) {
+	var s reportStyles
+	var err error
+	if s.title, err = f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true, Size: 14}}); err != nil {
+		return s, err
+	}
+	if s.header, err = f.NewStyle(&excelize.Style{
+		Font:   &excelize.Font{Bold: true, Color: "FFFFFF"},
+		Fill:   excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"305496"}},
+		Border: []excelize.Border{{Type: "bottom", Color: "1F3864", Style: 2}},
+	}); err != nil {
+		return s, err
+	}
+	dateFormat := "yyyy-mm-dd hh:mm:ss"
+	if s.date, err = f.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat}); err != nil {
+		return s, err
+	}
+	// Built-in format 10 is "0.00%".
+	s.percent, err = f.NewStyle(&excelize.Style{NumFmt: 10})
+	return s, err
+}
+
+// @Summary Allocation report as Excel
+// @Description Build an Excel workbook of the allocations created in a date range: a Summary sheet with totals, waste, packs by size and allocations by profile, and an Allocations sheet with one row per allocation.
+// @Tags packs
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param from query string false "Inclusive start (RFC 3339 or YYYY-MM-DD)"
+// @Param to query string false "Exclusive end (RFC 3339 or YYYY-MM-DD)"
+// @Success 200 {file} file "Excel workbook"
+// @Failure 400 {object} ErrorResponse "Error message"
+// @Failure 500 {object} ErrorResponse "Error message"
+// @Router /v1/reports/allocations.xlsx [get]
+func (h *Handler) allocationsReport(c *gin.Context) {
+	from, err := parseTimeParam(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from: " + err.Error()})
+		return
+	}
+	to, err := parseTimeParam(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to: " + err.Error()})
+		return
+	}
+
+	f, err := h.buildAllocationsReport(from, to)
+	if err != nil {
+		log.Printf("Allocation report failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer f.Close()
+
+	c.Header("Content-Type", xlsxContentType)
+	c.Header("Content-Disposition", `attachment; filename="allocations.xlsx"`)
+	c.Status(http.StatusOK)
+	if err := f.Write(c.Writer); err != nil {
+		log.Printf("Allocation report failed: %v", err)
+	}
+}
+
+// buildAllocationsReport streams the allocations created in [from, to) into
+// the detail sheet of a new workbook, then writes their summary.
+func (h *Handler) buildAllocationsReport(from, to time.Time) (*excelize.File, error) {
+	f := excelize.NewFile()
+	build := func() error {
+		styles, err := newReportStyles(f)
+		if err != nil {
+			return err
+		}
+		if err := f.SetSheetName("Sheet1", reportSummarySheet); err != nil {
+			return err
+		}
+		if _, err := f.NewSheet(reportDetailSheet); err != nil {
+			return err
+		}
+		report, err := h.writeReportDetail(f, styles, from, to)
+		if err != nil {
+			return err
+		}
+		return writeReportSummary(f, styles, report, from, to)
+	}
+	if err := build(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// writeReportDetail writes one row per allocation to the detail sheet and
+// returns their summary.
+func (h *Handler) writeReportDetail(f *excelize.File, styles reportStyles, from, to time.Time) (*allocationReport, error) {
+	sw, err := f.NewStreamWriter(reportDetailSheet)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]interface{}, len(reportColumns))
+	for i, col := range reportColumns {
+		if err := sw.SetColWidth(i+1, i+1, col.width); err != nil {
+			return nil, err
+		}
+		header[i] = col.title
+	}
+	if err := sw.SetPanes(&excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+		return nil, err
+	}
+	if err := sw.SetRow("A1", header, excelize.RowOpts{StyleID: styles.header}); err != nil {
+		return nil, err
+	}
+
+	report := &allocationReport{packs: map[int]int64{}, profiles: map[string]int{}}
+	row := 1
+	err = h.allocator.ExportAllocations(from, to, func(a storage.Allocation) error {
+		report.add(a)
+		row++
+		cell, err := excelize.CoordinatesToCellName(1, row)
+		if err != nil {
+			return err
+		}
+		return sw.SetRow(cell, []interface{}{
+			a.ID,
+			excelize.Cell{StyleID: styles.date, Value: a.CreatedAt.UTC()},
+			a.OrderID,
+			a.CustomerID,
+			a.Profile,
+			a.OrderQuantity,
+			a.Total,
+			allocationWaste(a),
+			formatReportPacks(a.Packs),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := sw.Flush(); err != nil {
+		return nil, err
+	}
+	last, err := excelize.CoordinatesToCellName(len(reportColumns), row)
+	if err != nil {
+		return nil, err
+	}
+	return report, f.AutoFilter(reportDetailSheet, "A1:"+last, nil)
+}
+
+// writeReportSummary writes the totals of report, the packs by size and the
+// allocations by profile to the summary sheet.
+func writeReportSummary(f *excelize.File, styles reportStyles, report *allocationReport, from, to time.Time) error {
+	const sheet = reportSummarySheet
+	period := func(t time.Time, open string) string {
+		if t.IsZero() {
+			return open
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	wastePercent := 0.0
+	if report.packed > 0 {
+		wastePercent = float64(report.waste) / float64(report.packed)
+	}
+
+	rows := [][]interface{}{
+		{"Allocation report"},
+		{"From", period(from, "beginning")},
+		{"To", period(to, "now")},
+		{"Generated (UTC)", time.Now().UTC().Format(time.RFC3339)},
+		nil,
+		{"Allocations", report.allocations},
+		{"Items ordered", report.ordered},
+		{"Items packed", report.packed},
+		{"Waste (items)", report.waste},
+		{"Waste (% of packed)", wastePercent},
+	}
+	for i, values := range rows {
+		if err := f.SetSheetRow(sheet, "A"+strconv.Itoa(i+1), &values); err != nil {
+			return err
+		}
+	}
+	if err := f.SetCellStyle(sheet, "A1", "A1", styles.title); err != nil {
+		return err
+	}
+	if err := f.SetCellStyle(sheet, "B10", "B10", styles.percent); err != nil {
+		return err
+	}
+
+	// Packs by size, largest first, next to allocations by profile.
+	sizes := make([]int, 0, len(report.packs))
+	for size := range report.packs {
+		sizes = append(sizes, size)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+	profiles := make([]string, 0, len(report.profiles))
+	for profile := range report.profiles {
+		profiles = append(profiles, profile)
+	}
+	sort.Strings(profiles)
+
+	const tableRow = 12
+	if err := f.SetSheetRow(sheet, "A"+strconv.Itoa(tableRow), &[]interface{}{"Pack size", "Packs", "Items"}); err != nil {
+		return err
+	}
+	if err := f.SetSheetRow(sheet, "E"+strconv.Itoa(tableRow), &[]interface{}{"Profile", "Allocations"}); err != nil {
+		return err
+	}
+	if err := f.SetCellStyle(sheet, "A"+strconv.Itoa(tableRow), "F"+strconv.Itoa(tableRow), styles.header); err != nil {
+		return err
+	}
+	for i, size := range sizes {
+		packs := report.packs[size]
+		if err := f.SetSheetRow(sheet, "A"+strconv.Itoa(tableRow+1+i), &[]interface{}{size, packs, int64(size) * packs}); err != nil {
+			return err
+		}
+	}
+	for i, profile := range profiles {
+		if err := f.SetSheetRow(sheet, "E"+strconv.Itoa(tableRow+1+i), &[]interface{}{profile, report.profiles[profile]}); err != nil {
+			return err
+		}
+	}
+	if err := f.SetColWidth(sheet, "A", "A", 20); err != nil {
+		return err
+	}
+	return f.SetColWidth(sheet, "B", "F", 14)
+}
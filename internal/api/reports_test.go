@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestAllocationsReport(t *testing.T) {
+	router, handler := setupTestRouter()
+	for _, quantity := range []int{50, 100} {
+		_, _, err := handler.allocator.CalculatePacks(quantity)
+		assert.NoError(t, err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/reports/allocations.xlsx?from=2024-01-01", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, xlsxContentType, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "allocations.xlsx")
+
+	f, err := excelize.OpenReader(w.Body)
+	assert.NoError(t, err)
+	if f == nil {
+		return
+	}
+	defer f.Close()
+	assert.Equal(t, []string{reportSummarySheet, reportDetailSheet}, f.GetSheetList())
+
+	rows, err := f.GetRows(reportDetailSheet)
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 3) {
+		assert.Equal(t, "Quantity", rows[0][5])
+		assert.ElementsMatch(t, []string{"50", "100"}, []string{rows[1][5], rows[2][5]})
+	}
+
+	cell := func(ref string) string {
+		v, err := f.GetCellValue(reportSummarySheet, ref)
+		assert.NoError(t, err)
+		return v
+	}
+	assert.Equal(t, "2024-01-01T00:00:00Z", cell("B2"))
+	assert.Equal(t, "now", cell("B3"))
+	assert.Equal(t, "2", cell("B6"))
+	assert.Equal(t, "150", cell("B7"))
+	assert.Equal(t, "Pack size", cell("A12"))
+	assert.Equal(t, "default", cell("E13"))
+	assert.Equal(t, "2", cell("F13"))
+
+	for _, query := range []string{"from=yesterday", "to=2024-13-01"} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/reports/allocations.xlsx?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestFormatReportPacks(t *testing.T) {
+	assert.Equal(t, "1000 x 2, 250 x 1", formatReportPacks(map[int]int{250: 1, 1000: 2}))
+	assert.Empty(t, formatReportPacks(nil))
+}
@@ -0,0 +1,686 @@
+package api
+
+import (
+	"time"
+
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+// Response models. Handlers write these rather than ad-hoc maps so the
+// generated OpenAPI spec describes the real payloads.
+
+// ErrorResponse is returned with every 4xx and 5xx status.
+type ErrorResponse struct {
+	Error string `json:"error" example:"invalid quantity"`
+	// Code identifies the error independently of the language of Error. It
+	// is empty for errors without a translation.
+	Code errorCode `json:"code,omitempty" swaggertype:"string" example:"invalid_quantity"`
+}
+
+// ValidationErrorResponse is returned with 400 when a request body fails
+// validation, listing every failed field.
+type ValidationErrorResponse struct {
+	Error  string       `json:"error" example:"invalid request body"`
+	Code   errorCode    `json:"code" swaggertype:"string" example:"invalid_body"`
+	Errors []FieldError `json:"errors"`
+}
+
+// FieldError is a request body field that failed a validation rule. Field
+// is the JSON path of the field, e.g. "items[0].quantity"; Rule is the
+// failed rule with its parameter, e.g. "gt=0" or "required".
+type FieldError struct {
+	Field string `json:"field" example:"quantity"`
+	Rule  string `json:"rule" example:"gt=0"`
+}
+
+// InfeasibleResponse is returned with 422 when no combination of the
+// configured pack sizes satisfies the request.
+type InfeasibleResponse struct {
+	Error string    `json:"error"`
+	Code  errorCode `json:"code,omitempty" swaggertype:"string" example:"no_combination"`
+	// Cached is set when the failure was served from the infeasible cache.
+	Cached bool `json:"cached"`
+}
+
+// CalculateResponse is the pack distribution for a single quantity.
+type CalculateResponse struct {
+	// Packs maps pack size to the number of packs of that size. With
+	// ?format=list it is a []PackCount sorted largest first; with
+	// ?format=flat a string such as "2x500,1x250".
+	Packs interface{} `json:"packs" swaggertype:"object,integer"`
+	Total int         `json:"total" example:"750"`
+	// Approximate is set when the soft deadline passed before the search
+	// proved the result optimal.
+	Approximate bool `json:"approximate"`
+	// Cached is set when the result is a stored allocation reused by the
+	// result cache rather than computed for this request.
+	Cached bool `json:"cached"`
+	// ComputedAt is when the packs were computed: for a cached result when
+	// the stored allocation was, for a pinned one when it was pinned.
+	// Results computed before the current pack sizes are never cached.
+	ComputedAt time.Time `json:"computed_at" example:"2025-05-31T20:18:17Z"`
+	// Shortfall is how much of the quantity the available stock could not
+	// cover; the packs are everything the constraints allowed.
+	Shortfall int `json:"shortfall,omitempty" example:"0"`
+	// Source is manual when the quantity is pinned with PUT
+	// /allocations/pin, and omitted when the result was computed.
+	Source string `json:"source,omitempty" enums:"manual"`
+	// DeprecatedPacks are the packs of sizes being phased out, which are
+	// only used when needed for the least waste. Omitted when there are
+	// none.
+	DeprecatedPacks map[int]int `json:"deprecated_packs,omitempty"`
+	// Rules are the configured allocation rules that held for the result,
+	// and Flags every flag they set. Both are omitted when none held.
+	Rules []RuleMatchResponse `json:"rules,omitempty"`
+	Flags []string            `json:"flags,omitempty" example:"requires_approval"`
+	// Unit is the configured unit the quantity was requested in, and
+	// Items the number of items it was converted to and allocated. Both
+	// are omitted for quantities in items.
+	Unit       string `json:"unit,omitempty" example:"case"`
+	Items      int    `json:"items,omitempty" example:"144"`
+	OrderID    string `json:"order_id,omitempty"`
+	CustomerID string `json:"customer_id,omitempty"`
+	// Cartons is how the packs ship in the configured cartons, included
+	// with ?cartons=true.
+	Cartons *CartonPlanResponse `json:"cartons,omitempty"`
+	// Labels describes the packs for display in the request's language,
+	// included with ?labels=true.
+	Labels *LabelsResponse `json:"labels,omitempty"`
+	Debug  *DebugResponse  `json:"debug,omitempty"`
+}
+
+// RuleMatchResponse is an allocation rule that held for a result, with the
+// note and flags it attaches.
+type RuleMatchResponse struct {
+	Rule  string   `json:"rule" example:"high_waste"`
+	Note  string   `json:"note,omitempty" example:"Waste above 10%: check the pack sizes"`
+	Flags []string `json:"flags,omitempty" example:"requires_approval"`
+}
+
+// LabelsResponse describes an allocation for display, in the language
+// negotiated from Accept-Language, with numbers grouped the way that
+// language groups them.
+type LabelsResponse struct {
+	// Packs labels the packs of each size, largest first.
+	Packs []string `json:"packs" example:"9 × 53-unit boxes"`
+	// Summary labels the number of packs and the units they hold.
+	Summary string `json:"summary" example:"9 boxes, 477 units"`
+}
+
+// CartonPlanResponse is an estimate of the cartons an allocation ships in.
+type CartonPlanResponse struct {
+	Loads []CartonLoadResponse `json:"loads"`
+	// Cartons is the number of cartons, Weight the weight of all the packs in
+	// grams.
+	Cartons int `json:"cartons" example:"3"`
+	Weight  int `json:"weight" example:"12500"`
+}
+
+// CartonLoadResponse is Count cartons of one type holding the same packs.
+type CartonLoadResponse struct {
+	Carton string `json:"carton" example:"large"`
+	Count  int    `json:"count" example:"2"`
+	// Packs are the packs in each carton, in the requested format.
+	Packs interface{} `json:"packs" swaggertype:"object,integer"`
+	// Weight is the weight of the packs in each carton in grams.
+	Weight int `json:"weight" example:"5000"`
+	// Fill is the share of each carton's volume the packs take up.
+	Fill float64 `json:"fill" example:"0.82"`
+}
+
+// DebugResponse describes how a calculation was computed. It is included
+// with ?debug=true.
+type DebugResponse struct {
+	// Algorithm is the strategy that produced the result; greedy after a
+	// soft timeout fallback.
+	Algorithm string `json:"algorithm" example:"backtracking"`
+	// Iterations counts search nodes, DP cells or candidate combinations,
+	// depending on the algorithm.
+	Iterations int `json:"iterations" example:"1204"`
+	// Cache is miss when the result was computed, hit when it was a stored
+	// allocation (see calculation.result_cache), shared when it was computed
+	// for an identical concurrent request, or bypass for constrained
+	// requests, which are never cached.
+	Cache     string  `json:"cache" enums:"miss,hit,shared,bypass"`
+	ComputeMS float64 `json:"compute_ms" example:"0.42"`
+	// EstimatedMS is how long the cost model expected the calculation to
+	// take and DeadlineMS when it would have fallen back to greedy; see
+	// calculation.cost. Both are omitted for results not computed.
+	EstimatedMS float64 `json:"estimated_ms,omitempty" example:"0.12"`
+	DeadlineMS  float64 `json:"deadline_ms,omitempty" example:"50"`
+}
+
+// WeightCalculateResponse is the pack distribution for a quantity by weight.
+// Weights are in kilograms.
+type WeightCalculateResponse struct {
+	Unit string `json:"unit" example:"weight"`
+	// Packs maps pack weight, as a decimal string, to the number of packs.
+	// ?format=list and ?format=flat render it as for CalculateResponse.
+	Packs       interface{}    `json:"packs" swaggertype:"object,integer"`
+	Total       float64        `json:"total" example:"2.75"`
+	Approximate bool           `json:"approximate"`
+	Cached      bool           `json:"cached"`
+	ComputedAt  time.Time      `json:"computed_at" example:"2025-05-31T20:18:17Z"`
+	OrderID     string         `json:"order_id,omitempty"`
+	CustomerID  string         `json:"customer_id,omitempty"`
+	Debug       *DebugResponse `json:"debug,omitempty"`
+}
+
+// AllocationsResponse lists stored allocations.
+type AllocationsResponse struct {
+	Allocations []storage.Allocation `json:"allocations"`
+}
+
+// RecentResponse lists the most recent allocations.
+type RecentResponse struct {
+	Allocations []storage.Allocation `json:"allocations"`
+	// Total counts every stored allocation, not just those listed.
+	Total int `json:"total" example:"1200"`
+	Limit int `json:"limit" example:"10"`
+}
+
+// SearchResponse is a page of the allocations matching a search.
+type SearchResponse struct {
+	Allocations []storage.Allocation `json:"allocations"`
+	// Total counts every matching allocation, not just this page.
+	Total  int `json:"total" example:"240"`
+	Offset int `json:"offset" example:"0"`
+	Limit  int `json:"limit" example:"100"`
+}
+
+// StatsResponse counts stored allocations by the algorithm that produced
+// them.
+type StatsResponse struct {
+	Allocations int64 `json:"allocations" example:"1200"`
+	// Approximate counts allocations returned before being proven optimal,
+	// Fallbacks those of them computed by the greedy fallback after the
+	// soft timeout. The rates are their share of Allocations.
+	Approximate     int64                 `json:"approximate" example:"12"`
+	ApproximateRate float64               `json:"approximate_rate" example:"0.01"`
+	Fallbacks       int64                 `json:"fallbacks" example:"9"`
+	FallbackRate    float64               `json:"fallback_rate" example:"0.0075"`
+	Algorithms      []AlgorithmStatsEntry `json:"algorithms"`
+	// Waste sums the items shipped beyond the quantities ordered, and
+	// WasteRate is its share of the items ordered. WasteBy breaks it down
+	// by the group_by parameter.
+	Waste     int64             `json:"waste" example:"4800"`
+	WasteRate float64           `json:"waste_rate" example:"0.012"`
+	WasteBy   []WasteStatsEntry `json:"waste_by"`
+	// Deprecated counts the packs of deprecated sizes shipped, to follow
+	// the drain of their stock.
+	Deprecated []DeprecatedUsageEntry `json:"deprecated"`
+}
+
+// DeprecatedUsageEntry counts the packs of a deprecated size in the
+// allocations of a profile.
+type DeprecatedUsageEntry struct {
+	Profile     string `json:"profile" example:"default"`
+	Size        int    `json:"size" example:"250"`
+	Packs       int64  `json:"packs" example:"340"`
+	Allocations int64  `json:"allocations" example:"310"`
+}
+
+// AlgorithmStatsEntry counts the allocations of one algorithm: a strategy
+// such as dp, cache for result cache hits, manual for pins, or empty for
+// allocations stored before algorithms were recorded.
+type AlgorithmStatsEntry struct {
+	Algorithm   string  `json:"algorithm" example:"dp"`
+	Allocations int64   `json:"allocations" example:"900"`
+	Approximate int64   `json:"approximate" example:"3"`
+	Share       float64 `json:"share" example:"0.75"`
+}
+
+// WasteStatsEntry sums the allocations of one group: an algorithm as in
+// AlgorithmStatsEntry, a profile, empty for the default one, or a UTC day.
+// Days are listed in order, other groups most waste first.
+type WasteStatsEntry struct {
+	Group       string  `json:"group" example:"dp"`
+	Allocations int64   `json:"allocations" example:"900"`
+	Quantity    int64   `json:"quantity" example:"300000"`
+	Waste       int64   `json:"waste" example:"3600"`
+	WasteRate   float64 `json:"waste_rate" example:"0.012"`
+	// MaxWaste is the largest waste of a single allocation.
+	MaxWaste int `json:"max_waste" example:"22"`
+}
+
+// HealthResponse reports service health.
+type HealthResponse struct {
+	Status string `json:"status" example:"ok"`
+}
+
+// ReadinessResponse reports the readiness probes. Status is the worst of
+// the checks: ok, warning or critical, or stopping once the instance is
+// preparing for termination.
+type ReadinessResponse struct {
+	Status string                   `json:"status" example:"ok" enums:"ok,warning,critical,stopping"`
+	Checks map[string]CheckResponse `json:"checks"`
+}
+
+// CheckResponse is the outcome of one readiness probe: the value it measured
+// and the thresholds it is rated against, in Unit.
+type CheckResponse struct {
+	Status   string  `json:"status" example:"ok" enums:"ok,warning,critical"`
+	Value    float64 `json:"value" example:"1.8"`
+	Unit     string  `json:"unit" example:"ms"`
+	Warning  float64 `json:"warning,omitempty" example:"100"`
+	Critical float64 `json:"critical,omitempty" example:"1000"`
+	// Error says why the probe could not measure, which is critical.
+	Error string `json:"error,omitempty"`
+}
+
+// OrderItemResponse is the allocation for one line of a multi-item order.
+type OrderItemResponse struct {
+	SKU         string      `json:"sku"`
+	Quantity    int         `json:"quantity"`
+	Profile     string      `json:"profile"`
+	Packs       interface{} `json:"packs" swaggertype:"object,integer"`
+	Total       int         `json:"total"`
+	Waste       int         `json:"waste"`
+	PackCount   int         `json:"pack_count"`
+	Approximate bool        `json:"approximate"`
+	Cached      bool        `json:"cached"`
+	ComputedAt  time.Time   `json:"computed_at" example:"2025-05-31T20:18:17Z"`
+	Source      string      `json:"source,omitempty" enums:"manual"`
+	// DeprecatedPacks are the packs of sizes being phased out.
+	DeprecatedPacks map[int]int `json:"deprecated_packs,omitempty"`
+	// Rules and Flags are the allocation rules that held for the line.
+	Rules []RuleMatchResponse `json:"rules,omitempty"`
+	Flags []string            `json:"flags,omitempty" example:"requires_approval"`
+	// Labels describes the packs for display, included with ?labels=true.
+	Labels *LabelsResponse `json:"labels,omitempty"`
+}
+
+// OrderSummaryResponse totals every line of an order.
+type OrderSummaryResponse struct {
+	TotalQuantity int  `json:"total_quantity"`
+	TotalItems    int  `json:"total_items"`
+	TotalPacks    int  `json:"total_packs"`
+	TotalWaste    int  `json:"total_waste"`
+	Approximate   bool `json:"approximate"`
+}
+
+// OrderResponse is the result of POST /calculate/order.
+type OrderResponse struct {
+	Items      []OrderItemResponse  `json:"items"`
+	Summary    OrderSummaryResponse `json:"summary"`
+	OrderID    string               `json:"order_id,omitempty"`
+	CustomerID string               `json:"customer_id,omitempty"`
+}
+
+// SimulationOutcomeResponse is the allocation of one quantity under one
+// pack-size set.
+type SimulationOutcomeResponse struct {
+	Packs     map[int]int `json:"packs"`
+	Total     int         `json:"total"`
+	Waste     int         `json:"waste"`
+	PackCount int         `json:"pack_count"`
+}
+
+// SimulationRowResponse compares both pack-size sets for one quantity.
+// Deltas are candidate minus baseline.
+type SimulationRowResponse struct {
+	Quantity   int                       `json:"quantity"`
+	Baseline   SimulationOutcomeResponse `json:"baseline"`
+	Candidate  SimulationOutcomeResponse `json:"candidate"`
+	WasteDelta int                       `json:"waste_delta"`
+	PackDelta  int                       `json:"pack_delta"`
+}
+
+// SimulationTotalsResponse aggregates every row for one pack-size set.
+type SimulationTotalsResponse struct {
+	TotalWaste     int     `json:"total_waste"`
+	TotalPacks     int     `json:"total_packs"`
+	AvgWaste       float64 `json:"avg_waste"`
+	AvgPacks       float64 `json:"avg_packs"`
+	ZeroWasteCount int     `json:"zero_waste_count"`
+}
+
+// SimulationSummaryResponse compares the totals of both pack-size sets.
+type SimulationSummaryResponse struct {
+	Baseline   SimulationTotalsResponse `json:"baseline"`
+	Candidate  SimulationTotalsResponse `json:"candidate"`
+	WasteDelta int                      `json:"waste_delta"`
+	PackDelta  int                      `json:"pack_delta"`
+}
+
+// SimulationResponse is the result of POST /simulate.
+type SimulationResponse struct {
+	BaselineSizes  []int                     `json:"baseline_sizes"`
+	CandidateSizes []int                     `json:"candidate_sizes"`
+	Results        []SimulationRowResponse   `json:"results"`
+	Summary        SimulationSummaryResponse `json:"summary"`
+}
+
+// CompareOutcomeResponse is one pack-size set's allocation in a comparison.
+type CompareOutcomeResponse struct {
+	Name      string      `json:"name" example:"current"`
+	PackSizes []int       `json:"pack_sizes"`
+	Packs     interface{} `json:"packs" swaggertype:"object,integer"`
+	Total     int         `json:"total"`
+	Waste     int         `json:"waste"`
+	PackCount int         `json:"pack_count"`
+	// Cost is the sum of the pack costs, or the pack count without costs.
+	Cost        float64 `json:"cost"`
+	Approximate bool    `json:"approximate"`
+}
+
+// CompareSummaryResponse names the best sets. Best has the least waste,
+// then the fewest packs, then the lowest cost; ties go to the earlier set.
+type CompareSummaryResponse struct {
+	Best        string `json:"best"`
+	BestByWaste string `json:"best_by_waste"`
+	BestByPacks string `json:"best_by_packs"`
+	BestByCost  string `json:"best_by_cost"`
+}
+
+// CompareResponse is the result of POST /calculate/compare.
+type CompareResponse struct {
+	Quantity int                      `json:"quantity"`
+	Results  []CompareOutcomeResponse `json:"results"`
+	Summary  CompareSummaryResponse   `json:"summary"`
+}
+
+// BatchItemResponse is the allocation of one quantity of a batch.
+type BatchItemResponse struct {
+	Quantity  int         `json:"quantity" example:"251"`
+	Packs     interface{} `json:"packs" swaggertype:"object,integer"`
+	Total     int         `json:"total" example:"500"`
+	Waste     int         `json:"waste" example:"249"`
+	PackCount int         `json:"pack_count" example:"1"`
+	Source    string      `json:"source,omitempty" enums:"manual"`
+}
+
+// BatchResponse is the result of POST /calculate/batch, one result per
+// quantity in the order they were given.
+type BatchResponse struct {
+	Profile string              `json:"profile" example:"default"`
+	Results []BatchItemResponse `json:"results"`
+}
+
+// SuggestedQuantityResponse is a quantity the packs hold exactly.
+type SuggestedQuantityResponse struct {
+	Quantity int         `json:"quantity" example:"750"`
+	Packs    interface{} `json:"packs" swaggertype:"object,integer"`
+}
+
+// SuggestResponse is the result of GET /suggest. Exact is true when the
+// quantity itself allocates with zero waste; Below is null when no smaller
+// quantity does.
+type SuggestResponse struct {
+	Quantity int                        `json:"quantity" example:"600"`
+	Profile  string                     `json:"profile" example:"default"`
+	Exact    bool                       `json:"exact"`
+	Below    *SuggestedQuantityResponse `json:"below"`
+	Above    SuggestedQuantityResponse  `json:"above"`
+}
+
+// ProfileVersionResponse is one version of a pack-size profile.
+// EffectiveTo is null for the current version.
+type ProfileVersionResponse struct {
+	Version       int        `json:"version"`
+	PackSizes     []int      `json:"pack_sizes"`
+	EffectiveFrom time.Time  `json:"effective_from"`
+	EffectiveTo   *time.Time `json:"effective_to"`
+}
+
+// ProfileVersionsResponse is the version history of a profile, oldest first.
+type ProfileVersionsResponse struct {
+	Name     string                   `json:"name"`
+	Versions []ProfileVersionResponse `json:"versions"`
+}
+
+// AllocationChangeResponse is a run of stored allocations that packed a
+// quantity the same way with the same profile version.
+type AllocationChangeResponse struct {
+	Packs          map[int]int `json:"packs"`
+	Total          int         `json:"total" example:"500"`
+	Waste          int         `json:"waste" example:"0"`
+	ProfileVersion int         `json:"profile_version" example:"2"`
+	Algorithm      string      `json:"algorithm,omitempty" example:"dp"`
+	FirstSeen      time.Time   `json:"first_seen"`
+	LastSeen       time.Time   `json:"last_seen"`
+	Allocations    int         `json:"allocations" example:"12"`
+	// Delta is the change in packs of each size from the previous entry,
+	// negative for packs removed; it is left out of the first entry.
+	Delta map[int]int `json:"delta,omitempty"`
+}
+
+// AllocationHistoryResponse is how the allocation of a quantity changed
+// over time, oldest first; the last entry is the current one.
+type AllocationHistoryResponse struct {
+	Quantity int                        `json:"quantity" example:"500"`
+	Profile  string                     `json:"profile" example:"default"`
+	Changes  []AllocationChangeResponse `json:"changes"`
+}
+
+// PackSizeIssueResponse is a pack-size rule a profile breaks.
+type PackSizeIssueResponse struct {
+	Profile  string `json:"profile" example:"default"`
+	Rule     string `json:"rule" example:"duplicate"`
+	Severity string `json:"severity" example:"error"`
+	Message  string `json:"message" example:"pack size 250 is listed more than once"`
+}
+
+// ProfileValidationResponse is the result of GET /profiles/validate. Valid is
+// false when any issue has error severity.
+type ProfileValidationResponse struct {
+	Valid  bool                    `json:"valid"`
+	Issues []PackSizeIssueResponse `json:"issues"`
+}
+
+// PinResponse is a manual allocation served for a quantity of a profile.
+type PinResponse struct {
+	Quantity  int         `json:"quantity" example:"600"`
+	Profile   string      `json:"profile" example:"default"`
+	Packs     map[int]int `json:"packs"`
+	Total     int         `json:"total" example:"750"`
+	Reason    string      `json:"reason,omitempty" example:"summer bundle"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// PinsResponse lists the pinned allocations.
+type PinsResponse struct {
+	Pins []PinResponse `json:"pins"`
+}
+
+// ReadOnlyResponse reports the read-only (maintenance) state.
+type ReadOnlyResponse struct {
+	Enabled bool   `json:"enabled"`
+	Mode    string `json:"mode" enums:"skip,reject"`
+}
+
+// OutboxResponse reports on allocation writes queued for retry after the
+// storage failed them.
+type OutboxResponse struct {
+	Pending   int    `json:"pending" example:"3"`
+	Persisted int64  `json:"persisted" example:"120"`
+	Failed    int64  `json:"failed" example:"0"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// PreStopResponse reports that the instance drained and flushed its writes,
+// with the requests still in flight when draining ended.
+type PreStopResponse struct {
+	Stopping bool  `json:"stopping"`
+	InFlight int64 `json:"in_flight"`
+}
+
+// PurgeResponse confirms a cache purge.
+type PurgeResponse struct {
+	Purged bool `json:"purged"`
+}
+
+// ProfileUpdateResponse is the profile version created by an update.
+// Impact is set when the profile guardrail analysed the update.
+type ProfileUpdateResponse struct {
+	Name      string                 `json:"name"`
+	Version   int                    `json:"version"`
+	PackSizes []int                  `json:"pack_sizes"`
+	Impact    *ProfileImpactResponse `json:"impact,omitempty"`
+}
+
+// ProfileImpactResponse is the effect of a profile update on the waste of
+// the profile's latest allocations. Waste rates are percentages of the
+// quantities ordered, and the waste increase is in percentage points.
+type ProfileImpactResponse struct {
+	CurrentSizes     []int                    `json:"current_sizes"`
+	Allocations      int                      `json:"allocations"`
+	Current          SimulationTotalsResponse `json:"current"`
+	Updated          SimulationTotalsResponse `json:"updated"`
+	CurrentWasteRate float64                  `json:"current_waste_rate"`
+	WasteRate        float64                  `json:"waste_rate"`
+	WasteIncrease    float64                  `json:"waste_increase"`
+	MaxWasteIncrease float64                  `json:"max_waste_increase"`
+	Exceeded         bool                     `json:"exceeded"`
+}
+
+// ProfileGuardrailResponse rejects a profile update raising waste beyond the
+// guardrail.
+type ProfileGuardrailResponse struct {
+	Error  string                `json:"error"`
+	Impact ProfileImpactResponse `json:"impact"`
+}
+
+// AuditResponse lists audit log entries, most recent first.
+type AuditResponse struct {
+	Entries []storage.AuditEntry `json:"entries"`
+}
+
+// UsageResponse reports the usage of clients in a month.
+type UsageResponse struct {
+	Month string `json:"month" example:"2025-06"`
+	// Metering is false when usage is not being recorded.
+	Metering bool                  `json:"metering"`
+	Clients  []ClientUsageResponse `json:"clients"`
+}
+
+// ClientUsageResponse is what one client used in a month.
+type ClientUsageResponse struct {
+	Client         string  `json:"client" example:"key:3f2a9c1b0d4e"`
+	Requests       int64   `json:"requests" example:"1520"`
+	ComputeSeconds float64 `json:"compute_seconds" example:"12.5"`
+	// Quota is omitted for clients without one.
+	Quota *QuotaResponse `json:"quota,omitempty"`
+}
+
+// QuotaResponse is a monthly quota; a zero limit is unlimited.
+type QuotaResponse struct {
+	Requests       int64   `json:"requests" example:"100000"`
+	ComputeSeconds float64 `json:"compute_seconds" example:"3600"`
+}
+
+// ProfileResponse describes the current state of a pack-size profile.
+type ProfileResponse struct {
+	Name      string `json:"name" example:"default"`
+	PackSizes []int  `json:"pack_sizes"`
+	// Version is omitted when no profile version is recorded.
+	Version int `json:"version,omitempty"`
+	// Limits caps the packs of each size per allocation.
+	Limits map[int]int `json:"limits,omitempty"`
+	// Deprecated lists the sizes being phased out, which allocations avoid
+	// unless they are needed for the least waste.
+	Deprecated []int `json:"deprecated,omitempty"`
+	// Dimensions maps pack size to its dimensions, for carton fitting.
+	Dimensions map[int]DimensionsResponse `json:"dimensions,omitempty"`
+	SKUs       []string                   `json:"skus,omitempty"`
+	// Defaults are omitted when the profile has none.
+	Defaults *ProfileDefaultsResponse `json:"defaults,omitempty"`
+}
+
+// ProfileDefaultsResponse is the calculation settings of a profile's
+// requests that do not set them. Settings left to the allocator are omitted.
+type ProfileDefaultsResponse struct {
+	Strategy string           `json:"strategy,omitempty" example:"dp"`
+	Weights  *WeightsResponse `json:"weights,omitempty"`
+	// Timeout is the soft timeout of the profile's calculations.
+	Timeout   string     `json:"timeout,omitempty" example:"2s"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// WeightsResponse is the objective w_waste*waste + w_packs*packs +
+// w_cost*cost.
+type WeightsResponse struct {
+	Waste float64 `json:"w_waste" example:"1"`
+	Packs float64 `json:"w_packs" example:"100"`
+	Cost  float64 `json:"w_cost" example:"0"`
+}
+
+// DimensionsResponse is the size of a pack in millimetres and its weight in
+// grams.
+type DimensionsResponse struct {
+	Length int `json:"length" example:"300"`
+	Width  int `json:"width" example:"200"`
+	Height int `json:"height" example:"150"`
+	Weight int `json:"weight" example:"2500"`
+}
+
+// CartonResponse is a carton packs can be fitted into: its inner size in
+// millimetres and the weight in grams it may hold, 0 for no limit.
+type CartonResponse struct {
+	Name      string `json:"name" example:"large"`
+	Length    int    `json:"length" example:"600"`
+	Width     int    `json:"width" example:"400"`
+	Height    int    `json:"height" example:"400"`
+	MaxWeight int    `json:"max_weight" example:"25000"`
+}
+
+// ConfigResponse describes the configuration the service is running with,
+// including runtime changes such as profile updates.
+type ConfigResponse struct {
+	Strategy     string            `json:"strategy" example:"dp"`
+	Profiles     []ProfileResponse `json:"profiles"`
+	MaxQuantity  int               `json:"max_quantity"`
+	MaxBatchSize int               `json:"max_batch_size"`
+	ReadOnly     ReadOnlyResponse  `json:"read_only"`
+	// Cartons are the cartons ?cartons=true fits allocations into, smallest
+	// first.
+	Cartons []CartonResponse `json:"cartons,omitempty"`
+}
+
+// ShadowResponse describes the shadow comparisons of this instance.
+type ShadowResponse struct {
+	// Strategy is the strategy run in shadow, empty when shadowing is
+	// disabled, and Rate the fraction of calculations it runs for.
+	Strategy string  `json:"strategy,omitempty" example:"dp"`
+	Rate     float64 `json:"rate" example:"0.05"`
+	// Comparisons counts shadow results compared with the results
+	// answered, Mismatches those that differed and MismatchRate their
+	// share. Errors counts shadow calculations that failed or timed out,
+	// Skipped those not run because too many were running.
+	Comparisons  int64   `json:"comparisons" example:"1200"`
+	Mismatches   int64   `json:"mismatches" example:"3"`
+	MismatchRate float64 `json:"mismatch_rate" example:"0.0025"`
+	Errors       int64   `json:"errors"`
+	Skipped      int64   `json:"skipped"`
+	// Recent holds the latest mismatches, newest first.
+	Recent []ShadowMismatchResponse `json:"recent"`
+}
+
+// ShadowMismatchResponse is a calculation the shadow strategy answered
+// differently. Verdict is better when the shadow result wastes less, or as
+// much in fewer packs, worse in the opposite case and tie otherwise.
+type ShadowMismatchResponse struct {
+	Time              time.Time   `json:"time"`
+	Profile           string      `json:"profile"`
+	Quantity          int         `json:"quantity"`
+	Strategy          string      `json:"strategy"`
+	Packs             map[int]int `json:"packs"`
+	Total             int         `json:"total"`
+	Approximate       bool        `json:"approximate"`
+	ShadowStrategy    string      `json:"shadow_strategy"`
+	ShadowPacks       map[int]int `json:"shadow_packs"`
+	ShadowTotal       int         `json:"shadow_total"`
+	ShadowApproximate bool        `json:"shadow_approximate"`
+	Verdict           string      `json:"verdict" enums:"better,worse,tie"`
+}
+
+// CacheStatsResponse describes the state of the calculation caches.
+type CacheStatsResponse struct {
+	ResultCache bool `json:"result_cache"`
+	// NegativeTTL is how long failed outcomes are cached, e.g. "5m0s";
+	// empty when the negative cache is disabled.
+	NegativeTTL          string `json:"negative_ttl,omitempty" example:"5m0s"`
+	NegativeEntries      int    `json:"negative_entries"`
+	InfeasibleQuantities int    `json:"infeasible_quantities"`
+	Pins                 int    `json:"pins"`
+}
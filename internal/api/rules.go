@@ -0,0 +1,25 @@
+package api
+
+import "github.com/n-th/gymshark/internal/allocator"
+
+// ruleMatchesResponse converts the allocation rules that held for a result,
+// and collects the flags they set, each once, in rule order. Both are nil
+// when no rule held.
+func ruleMatchesResponse(matches []allocator.RuleMatch) ([]RuleMatchResponse, []string) {
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	rules := make([]RuleMatchResponse, len(matches))
+	var flags []string
+	seen := make(map[string]bool)
+	for i, m := range matches {
+		rules[i] = RuleMatchResponse{Rule: m.Rule, Note: m.Note, Flags: m.Flags}
+		for _, f := range m.Flags {
+			if !seen[f] {
+				seen[f] = true
+				flags = append(flags, f)
+			}
+		}
+	}
+	return rules, flags
+}
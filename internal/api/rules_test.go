@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalculateAllocationRules(t *testing.T) {
+	router, handler := setupTestRouter()
+	assert.NoError(t, handler.allocator.SetAllocationRules([]allocator.AllocationRule{
+		{Name: "high_waste", WasteRate: 0.1, Note: "Waste above 10%", Flags: []string{"review"}},
+		{Name: "small", BelowSmallest: true, Flags: []string{"requires_approval", "review"}},
+	}))
+
+	req := httptest.NewRequest("GET", "/calculate?quantity=10", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response CalculateResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []RuleMatchResponse{
+		{Rule: "high_waste", Note: "Waste above 10%", Flags: []string{"review"}},
+		{Rule: "small", Flags: []string{"requires_approval", "review"}},
+	}, response.Rules)
+	assert.Equal(t, []string{"review", "requires_approval"}, response.Flags)
+
+	// Both are omitted when no rule holds
+	req = httptest.NewRequest("GET", "/calculate?quantity=500", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), `"rules"`)
+	assert.NotContains(t, w.Body.String(), `"flags"`)
+
+	body := `{"items": [{"sku": "SKU-1", "quantity": 10}, {"sku": "SKU-2", "quantity": 500}]}`
+	req = httptest.NewRequest("POST", "/calculate/order", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var order OrderResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+	if assert.Len(t, order.Items, 2) {
+		assert.Equal(t, []string{"review", "requires_approval"}, order.Items[0].Flags)
+		assert.Empty(t, order.Items[1].Rules)
+	}
+}
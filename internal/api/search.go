@@ -0,0 +1,97 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+// @Summary Search allocations
+// @Description Find stored allocations by quantity, waste, creation time, pack size used and profile, e.g. every allocation that used the 5000 pack last week. Ranges are inclusive except to. Soft-deleted allocations are left out.
+// @Tags allocations
+// @Produce json
+// @Param min_quantity query int false "Smallest order quantity"
+// @Param max_quantity query int false "Largest order quantity"
+// @Param min_waste query int false "Least waste (total minus quantity)"
+// @Param max_waste query int false "Most waste; 0 finds exact allocations"
+// @Param from query string false "Earliest creation time (RFC 3339 or YYYY-MM-DD), inclusive"
+// @Param to query string false "Latest creation time (RFC 3339 or YYYY-MM-DD), exclusive"
+// @Param pack_size query int false "Only allocations using at least one pack of this size"
+// @Param profile query string false "Only allocations of this profile"
+// @Param sort query string false "created_at, quantity, waste or total, prefixed with - for descending (default -created_at)"
+// @Param offset query int false "Matches to skip"
+// @Param limit query int false "Maximum allocations to return (default 100, max 1000)"
+// @Success 200 {object} SearchResponse "Matching allocations"
+// @Failure 400 {object} ErrorResponse "Error message"
+// @Failure 500 {object} ErrorResponse "Error message"
+// @Router /v1/allocations/search [get]
+func (h *Handler) searchAllocations(c *gin.Context) {
+	filter := storage.AllocationFilter{Profile: c.Query("profile"), Sort: c.Query("sort")}
+	for _, p := range []struct {
+		name  string
+		field *int
+	}{
+		{"min_quantity", &filter.MinQuantity},
+		{"max_quantity", &filter.MaxQuantity},
+		{"min_waste", &filter.MinWaste},
+		{"pack_size", &filter.PackSize},
+		{"offset", &filter.Offset},
+		{"limit", &filter.Limit},
+	} {
+		if !intQuery(c, p.name, p.field) {
+			return
+		}
+	}
+	if v := c.Query("max_waste"); v != "" {
+		filter.MaxWaste = new(int)
+		if !intQuery(c, "max_waste", filter.MaxWaste) {
+			return
+		}
+	}
+	if filter.Limit > storage.MaxSearchLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+
+	var err error
+	if filter.From, err = parseTimeParam(c.Query("from")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from: " + err.Error()})
+		return
+	}
+	if filter.To, err = parseTimeParam(c.Query("to")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to: " + err.Error()})
+		return
+	}
+
+	allocations, total, err := h.allocator.SearchAllocations(filter)
+	if err != nil {
+		writeDomainError(c, err)
+		return
+	}
+	if allocations == nil {
+		allocations = []storage.Allocation{}
+	}
+	limit := filter.Limit
+	if limit == 0 {
+		limit = storage.DefaultSearchLimit
+	}
+	c.JSON(http.StatusOK, SearchResponse{Allocations: allocations, Total: total, Offset: filter.Offset, Limit: limit})
+}
+
+// intQuery parses the named query parameter into dst if it is set, writing a
+// 400 response if it is not a non-negative integer.
+func intQuery(c *gin.Context, name string, dst *int) bool {
+	v := c.Query(name)
+	if v == "" {
+		return true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+		return false
+	}
+	*dst = n
+	return true
+}
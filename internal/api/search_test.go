@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchAllocations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store, err := storage.NewInMemorySQLite()
+	assert.NoError(t, err)
+	alloc := allocator.NewAllocator([]int{23, 31, 53}, store)
+	defer alloc.Close()
+	router := gin.New()
+	NewHandler(alloc).RegisterRoutes(router)
+
+	do := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+	for _, q := range []string{"50", "53", "100"} {
+		assert.Equal(t, http.StatusOK, do("/v1/calculate?quantity="+q).Code)
+	}
+
+	w := do("/v1/allocations/search?pack_size=53&max_waste=0&sort=quantity")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response SearchResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	if assert.Len(t, response.Allocations, 1) {
+		assert.Equal(t, 53, response.Allocations[0].OrderQuantity)
+	}
+	assert.Equal(t, 1, response.Total)
+	assert.Equal(t, storage.DefaultSearchLimit, response.Limit)
+
+	w = do("/v1/allocations/search?limit=1&offset=1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	response = SearchResponse{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Allocations, 1)
+	assert.Equal(t, 3, response.Total)
+	assert.Equal(t, 1, response.Offset)
+
+	w = do("/v1/allocations/search?profile=missing")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"allocations": [], "total": 0, "offset": 0, "limit": 100}`, w.Body.String())
+
+	for _, query := range []string{"min_quantity=-1", "max_waste=x", "pack_size=big", "limit=1001", "from=yesterday", "sort=packs"} {
+		assert.Equal(t, http.StatusBadRequest, do("/v1/allocations/search?"+query).Code, query)
+	}
+}
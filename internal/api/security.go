@@ -0,0 +1,142 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// csrfCookie holds the CSRF token the admin UI echoes in csrfHeader.
+	csrfCookie = "gymshark_csrf"
+	csrfHeader = "X-CSRF-Token"
+)
+
+// Security controls the security response headers and the CSRF protection
+// of the admin UI. Every response is sent with X-Content-Type-Options,
+// X-Frame-Options and Referrer-Policy, and the admin UI with a strict
+// Content-Security-Policy, whatever the settings.
+type Security struct {
+	// HSTSMaxAge, when positive, sends Strict-Transport-Security so that
+	// browsers only reach the API over HTTPS for that long. Only set it when
+	// the API is served over HTTPS, natively or behind a proxy.
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	// CSRF requires the admin UI's mutating requests to echo, in the
+	// X-CSRF-Token header, the token the page set in a cookie. Requests with
+	// an Authorization header are exempt: browsers never add one on their
+	// own, so they cannot be forged by another site.
+	CSRF bool
+}
+
+// SetSecurity applies s. It must be called before RegisterRoutes.
+func (h *Handler) SetSecurity(s Security) {
+	h.security = s
+}
+
+// securityHeaders sets the security headers of every response.
+func (h *Handler) securityHeaders(c *gin.Context) {
+	header := c.Writer.Header()
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("X-Frame-Options", "DENY")
+	header.Set("Referrer-Policy", "no-referrer")
+	if h.security.HSTSMaxAge > 0 {
+		hsts := "max-age=" + strconv.FormatInt(int64(h.security.HSTSMaxAge/time.Second), 10)
+		if h.security.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		header.Set("Strict-Transport-Security", hsts)
+	}
+	c.Next()
+}
+
+// adminCSP is the Content-Security-Policy of the admin UI, allowing its own
+// inline script and style, marked with the nonce, and calls to the API only.
+func adminCSP(nonce string) string {
+	return fmt.Sprintf("default-src 'none'; script-src 'nonce-%[1]s'; style-src 'nonce-%[1]s'; "+
+		"connect-src 'self'; img-src 'self' data:; base-uri 'none'; form-action 'none'; frame-ancestors 'none'", nonce)
+}
+
+// securePage marks the inline script and style of page with a fresh nonce
+// and sets the Content-Security-Policy allowing them. With CSRF protection
+// it also sets the CSRF cookie, keeping the token of a previous visit so
+// that other open tabs keep working, and embeds the token in the page.
+func (h *Handler) securePage(c *gin.Context, page []byte) ([]byte, error) {
+	nonce, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	page = bytes.ReplaceAll(page, []byte("<script>"), []byte(`<script nonce="`+nonce+`">`))
+	page = bytes.ReplaceAll(page, []byte("<style>"), []byte(`<style nonce="`+nonce+`">`))
+	c.Header("Content-Security-Policy", adminCSP(nonce))
+	if !h.security.CSRF {
+		return page, nil
+	}
+
+	token, err := c.Cookie(csrfCookie)
+	if err != nil || !validCSRFToken(token) {
+		if token, err = randomToken(); err != nil {
+			return nil, err
+		}
+	}
+	path := h.basePath
+	if path == "" {
+		path = "/"
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    token,
+		Path:     path,
+		HttpOnly: true,
+		Secure:   c.Request.TLS != nil || h.security.HSTSMaxAge > 0,
+		SameSite: http.SameSiteStrictMode,
+	})
+	meta := `<meta name="csrf-token" content="` + token + `">` + "\n</head>"
+	return bytes.Replace(page, []byte("</head>"), []byte(meta), 1), nil
+}
+
+// csrf rejects mutating requests without an Authorization header whose
+// X-CSRF-Token header does not match their CSRF cookie with 403, when CSRF
+// protection is enabled.
+func (h *Handler) csrf(c *gin.Context) {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		c.Next()
+		return
+	}
+	if !h.security.CSRF || c.GetHeader("Authorization") != "" {
+		c.Next()
+		return
+	}
+	cookie, err := c.Cookie(csrfCookie)
+	header := c.GetHeader(csrfHeader)
+	if err != nil || !validCSRFToken(cookie) || subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) != 1 {
+		c.Abort()
+		writeError(c, http.StatusForbidden, codeCSRF)
+		return
+	}
+	c.Next()
+}
+
+// randomToken returns 32 random bytes, hex-encoded.
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validCSRFToken reports whether token looks like one randomToken returns,
+// so that nothing else set in the cookie is echoed into the page.
+func validCSRFToken(token string) bool {
+	b, err := hex.DecodeString(token)
+	return err == nil && len(b) == 32
+}
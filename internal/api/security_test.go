@@ -0,0 +1,118 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	router, _ := setupTestRouter()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+	assert.Equal(t, "no-referrer", w.Header().Get("Referrer-Policy"))
+	assert.Empty(t, w.Header().Get("Strict-Transport-Security"))
+	assert.Empty(t, w.Header().Get("Content-Security-Policy"))
+
+	gin.SetMode(gin.TestMode)
+	router = gin.New()
+	handler := NewHandler(allocator.NewAllocator([]int{23, 31, 53}, newMockStorage()))
+	handler.SetSecurity(Security{HSTSMaxAge: 365 * 24 * time.Hour, HSTSIncludeSubdomains: true})
+	handler.RegisterRoutes(router)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/calculate?quantity=1", nil))
+	assert.Equal(t, "max-age=31536000; includeSubDomains", w.Header().Get("Strict-Transport-Security"))
+
+	// The admin UI allows only its own script and style, by nonce.
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/admin", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	csp := w.Header().Get("Content-Security-Policy")
+	nonce := regexp.MustCompile(`script-src 'nonce-([0-9a-f]+)'`).FindStringSubmatch(csp)
+	if assert.Len(t, nonce, 2) {
+		assert.Contains(t, csp, "default-src 'none'")
+		assert.Contains(t, csp, "frame-ancestors 'none'")
+		assert.Contains(t, w.Body.String(), `<script nonce="`+nonce[1]+`">`)
+		assert.Contains(t, w.Body.String(), `<style nonce="`+nonce[1]+`">`)
+	}
+	assert.Empty(t, w.Result().Cookies())
+	assert.NotContains(t, w.Body.String(), `<meta name="csrf-token"`)
+
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, httptest.NewRequest("GET", "/admin", nil))
+	assert.NotEqual(t, csp, w2.Header().Get("Content-Security-Policy"), "a fresh nonce per page")
+}
+
+func TestCSRF(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler := NewHandler(allocator.NewAllocator([]int{23, 31, 53}, newMockStorage()))
+	handler.SetBasePath("/pack-api")
+	handler.SetSecurity(Security{CSRF: true})
+	handler.RegisterRoutes(router)
+
+	// The page sets the token in a cookie and embeds it.
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/pack-api/admin", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	cookies := w.Result().Cookies()
+	if !assert.Len(t, cookies, 1) {
+		return
+	}
+	cookie := cookies[0]
+	assert.Equal(t, csrfCookie, cookie.Name)
+	assert.Equal(t, "/pack-api", cookie.Path)
+	assert.True(t, cookie.HttpOnly)
+	assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+	assert.False(t, cookie.Secure)
+	assert.Contains(t, w.Body.String(), `<meta name="csrf-token" content="`+cookie.Value+`">`)
+
+	// A later visit keeps the token.
+	req := httptest.NewRequest("GET", "/pack-api/admin", nil)
+	req.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if assert.Len(t, w.Result().Cookies(), 1) {
+		assert.Equal(t, cookie.Value, w.Result().Cookies()[0].Value)
+	}
+
+	purge := func(cookie *http.Cookie, token, authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/pack-api/v1/admin/cache/purge", nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		if token != "" {
+			req.Header.Set(csrfHeader, token)
+		}
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	w = purge(nil, "", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"csrf"`)
+	assert.Equal(t, http.StatusForbidden, purge(cookie, "", "").Code)
+	assert.Equal(t, http.StatusForbidden, purge(cookie, strings.Repeat("0", 64), "").Code)
+	forged := &http.Cookie{Name: csrfCookie, Value: "x"}
+	assert.Equal(t, http.StatusForbidden, purge(forged, "x", "").Code)
+
+	assert.Equal(t, http.StatusOK, purge(cookie, cookie.Value, "").Code)
+	assert.Equal(t, http.StatusOK, purge(nil, "", "Bearer token").Code)
+
+	// Reads need no token.
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/pack-api/v1/admin/cache", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
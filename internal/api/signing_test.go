@@ -0,0 +1,84 @@
+package api
+
+import (
+	"compress/gzip"
+	"crypto/ed25519"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/pkg/client"
+)
+
+func TestSignedResponses(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	signer, err := NewHMACSigner("k1", secret)
+	assert.NoError(t, err)
+	_, err = NewHMACSigner("k1", []byte("short"))
+	assert.Error(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Compression(0))
+	handler := NewHandler(allocator.NewAllocator([]int{23, 31, 53}, newMockStorage()))
+	handler.SetSigner(signer)
+	handler.RegisterRoutes(router)
+	verifier := client.NewVerifier(time.Minute).AddHMACKey("k1", secret)
+
+	// The signature covers the body before compression
+	req := httptest.NewRequest("GET", "/v1/calculate?quantity=50", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "hmac-sha256", w.Header().Get(SignatureAlgorithmHeader))
+	assert.Equal(t, "k1", w.Header().Get(SignatureKeyIDHeader))
+	zr, err := gzip.NewReader(w.Body)
+	assert.NoError(t, err)
+	body, err := io.ReadAll(zr)
+	assert.NoError(t, err)
+	assert.NoError(t, verifier.Verify(w.Header(), body))
+
+	// Errors and orders are signed too
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/v1/calculate?quantity=0", nil),
+		httptest.NewRequest("POST", "/v1/calculate/order", strings.NewReader(`{"items": [{"quantity": 50}]}`)),
+	} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.NoError(t, verifier.Verify(w.Header(), w.Body.Bytes()), req.URL.Path)
+	}
+
+	// Other routes are not signed
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/recent", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(SignatureHeader))
+}
+
+func TestSignedResponsesEd25519(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	signer, err := NewEd25519Signer("", private)
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler := NewHandler(allocator.NewAllocator([]int{23, 31, 53}, newMockStorage()))
+	handler.SetSigner(signer)
+	handler.RegisterRoutes(router)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/calculate?quantity=50", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(SignatureKeyIDHeader))
+	assert.NoError(t, client.NewVerifier(time.Minute).AddEd25519Key("", public).Verify(w.Header(), w.Body.Bytes()))
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/allocator"
+)
+
+// simulateRequest is the body accepted by POST /simulate.
+// Either Quantity or a From/To history range must be given.
+type simulateRequest struct {
+	Quantity  int    `json:"quantity" binding:"gte=0"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Strategy  string `json:"strategy"`
+	Baseline  []int  `json:"baseline" binding:"omitempty,dive,gt=0"`
+	Candidate []int  `json:"candidate" binding:"required,min=1,dive,gt=0"`
+}
+
+// @Summary Compare two pack-size sets
+// @Description Allocate a quantity, or every quantity ordered in a date range, under a baseline and a candidate pack-size set and compare waste and pack counts. Nothing is stored.
+// @Tags packs
+// @Accept json
+// @Produce json
+// @Param request body simulateRequest true "Quantity or date range, and the pack-size sets to compare"
+// @Success 200 {object} SimulationResponse "Side-by-side comparison"
+// @Failure 400 {object} ValidationErrorResponse "Invalid fields"
+// @Failure 413 {object} ErrorResponse "Request body too large"
+// @Failure 422 {object} InfeasibleResponse "No feasible combination"
+// @Failure 429 {object} ErrorResponse "Usage quota exceeded"
+// @Failure 503 {object} ErrorResponse "Overloaded"
+// @Failure 504 {object} ErrorResponse "Error message"
+// @Router /v1/simulate [post]
+func (h *Handler) simulate(c *gin.Context) {
+	var body simulateRequest
+	if !bindJSON(c, &body) {
+		return
+	}
+	historical := body.From != "" || body.To != ""
+	if historical == (body.Quantity != 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "provide either quantity or a from/to date range"})
+		return
+	}
+
+	var sim allocator.Simulation
+	var err error
+	if historical {
+		from, perr := parseTimeParam(body.From)
+		if perr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from: " + perr.Error()})
+			return
+		}
+		to, perr := parseTimeParam(body.To)
+		if perr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to: " + perr.Error()})
+			return
+		}
+		sim, err = h.allocator.SimulateHistory(c.Request.Context(), from, to, body.Baseline, body.Candidate, body.Strategy)
+	} else {
+		sim, err = h.allocator.Simulate(c.Request.Context(), []int{body.Quantity}, body.Baseline, body.Candidate, body.Strategy)
+	}
+	if err != nil {
+		writeAllocationError(c, err)
+		return
+	}
+
+	response := SimulationResponse{
+		BaselineSizes:  sim.BaselineSizes,
+		CandidateSizes: sim.CandidateSizes,
+		Results:        make([]SimulationRowResponse, 0, len(sim.Rows)),
+		Summary: SimulationSummaryResponse{
+			Baseline:   simulationTotalsResponse(sim.Baseline),
+			Candidate:  simulationTotalsResponse(sim.Candidate),
+			WasteDelta: sim.Candidate.Waste - sim.Baseline.Waste,
+			PackDelta:  sim.Candidate.Packs - sim.Baseline.Packs,
+		},
+	}
+	for _, row := range sim.Rows {
+		response.Results = append(response.Results, SimulationRowResponse{
+			Quantity:   row.Quantity,
+			Baseline:   simulationOutcomeResponse(row.Baseline),
+			Candidate:  simulationOutcomeResponse(row.Candidate),
+			WasteDelta: row.Candidate.Waste - row.Baseline.Waste,
+			PackDelta:  row.Candidate.PackCount - row.Baseline.PackCount,
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func simulationOutcomeResponse(o allocator.SimulationOutcome) SimulationOutcomeResponse {
+	return SimulationOutcomeResponse{
+		Packs:     o.Packs,
+		Total:     o.Total,
+		Waste:     o.Waste,
+		PackCount: o.PackCount,
+	}
+}
+
+func simulationTotalsResponse(t allocator.SimulationTotals) SimulationTotalsResponse {
+	return SimulationTotalsResponse{
+		TotalWaste:     t.Waste,
+		TotalPacks:     t.Packs,
+		AvgWaste:       t.AvgWaste,
+		AvgPacks:       t.AvgPacks,
+		ZeroWasteCount: t.ZeroWaste,
+	}
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulate(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	body := `{"quantity": 50, "strategy": "dp", "candidate": [25, 50]}`
+	req := httptest.NewRequest("POST", "/simulate", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		BaselineSizes []int `json:"baseline_sizes"`
+		Results       []struct {
+			Quantity   int `json:"quantity"`
+			WasteDelta int `json:"waste_delta"`
+		} `json:"results"`
+		Summary struct {
+			Candidate struct {
+				TotalWaste int `json:"total_waste"`
+			} `json:"candidate"`
+		} `json:"summary"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{53, 31, 23}, response.BaselineSizes)
+	assert.Len(t, response.Results, 1)
+	assert.Equal(t, -3, response.Results[0].WasteDelta)
+	assert.Equal(t, 0, response.Summary.Candidate.TotalWaste)
+
+	for _, body := range []string{
+		`{"quantity": 50}`,
+		`{"candidate": [10]}`,
+		`{"quantity": 50, "from": "2024-01-01", "candidate": [10]}`,
+		`{"from": "bad", "candidate": [10]}`,
+		`{"quantity": 50, "candidate": [0]}`,
+	} {
+		req = httptest.NewRequest("POST", "/simulate", strings.NewReader(body))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
+
+func TestSimulateHistory(t *testing.T) {
+	router, handler := setupTestRouter()
+	_, _, err := handler.allocator.CalculatePacks(50)
+	assert.NoError(t, err)
+
+	body := `{"from": "2000-01-01", "candidate": [50]}`
+	req := httptest.NewRequest("POST", "/simulate", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"quantity":50`)
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+// @Summary Get algorithm statistics
+// @Description Count the stored allocations by the algorithm that produced them (a strategy, greedy after a soft timeout fallback, cache or manual), and how many were approximate, sum the items they shipped beyond the quantities ordered by algorithm, profile or day, and count the packs of deprecated sizes shipped, optionally limited to a date range. The sums are computed by the storage, without loading the allocations. Allocations deduplicated as hits count once per hit.
+// @Tags packs
+// @Produce json
+// @Param from query string false "Inclusive start (RFC 3339 or YYYY-MM-DD)"
+// @Param to query string false "Exclusive end (RFC 3339 or YYYY-MM-DD)"
+// @Param group_by query string false "What waste_by groups waste by" Enums(algorithm, profile, day) default(algorithm)
+// @Success 200 {object} StatsResponse "Allocations by algorithm, most used first, waste by group and deprecated packs by profile and size"
+// @Failure 400 {object} ErrorResponse "Error message"
+// @Failure 500 {object} ErrorResponse "Error message"
+// @Router /v1/stats [get]
+func (h *Handler) getStats(c *gin.Context) {
+	from, err := parseTimeParam(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from: " + err.Error()})
+		return
+	}
+	to, err := parseTimeParam(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to: " + err.Error()})
+		return
+	}
+
+	waste, err := h.allocator.WasteStats(c.DefaultQuery("group_by", storage.GroupByAlgorithm), from, to)
+	if errors.Is(err, storage.ErrInvalidArgument) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid group_by: " + err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	stats, err := h.allocator.AlgorithmStats(from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	deprecated, err := h.allocator.DeprecatedUsage(from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := StatsResponse{Algorithms: make([]AlgorithmStatsEntry, 0, len(stats))}
+	for _, s := range stats {
+		response.Allocations += s.Allocations
+		response.Approximate += s.Approximate
+		if s.Algorithm == allocator.FallbackStrategy {
+			response.Fallbacks += s.Approximate
+		}
+	}
+	for _, s := range stats {
+		response.Algorithms = append(response.Algorithms, AlgorithmStatsEntry{
+			Algorithm:   s.Algorithm,
+			Allocations: s.Allocations,
+			Approximate: s.Approximate,
+			Share:       rate(s.Allocations, response.Allocations),
+		})
+	}
+	response.ApproximateRate = rate(response.Approximate, response.Allocations)
+	response.FallbackRate = rate(response.Fallbacks, response.Allocations)
+
+	response.WasteBy = make([]WasteStatsEntry, 0, len(waste))
+	var quantity int64
+	for _, w := range waste {
+		quantity += w.Quantity
+		response.Waste += w.Waste
+		response.WasteBy = append(response.WasteBy, WasteStatsEntry{
+			Group:       w.Group,
+			Allocations: w.Allocations,
+			Quantity:    w.Quantity,
+			Waste:       w.Waste,
+			WasteRate:   rate(w.Waste, w.Quantity),
+			MaxWaste:    w.MaxWaste,
+		})
+	}
+	response.WasteRate = rate(response.Waste, quantity)
+
+	response.Deprecated = make([]DeprecatedUsageEntry, 0, len(deprecated))
+	for _, d := range deprecated {
+		response.Deprecated = append(response.Deprecated, DeprecatedUsageEntry{
+			Profile:     d.Profile,
+			Size:        d.Size,
+			Packs:       d.Packs,
+			Allocations: d.Allocations,
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// rate is n as a fraction of total, or 0 when total is 0.
+func rate(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
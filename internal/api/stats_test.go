@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetStats(t *testing.T) {
+	allocator.RegisterStrategy("stats-blocking", allocator.StrategyFunc(func(ctx context.Context, _ int, _ []int) (allocator.Result, error) {
+		<-ctx.Done()
+		return allocator.Result{}, ctx.Err()
+	}))
+	router, handler := setupTestRouter()
+
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+
+	w := get("/stats")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"allocations": 0, "approximate": 0, "approximate_rate": 0, "fallbacks": 0, "fallback_rate": 0, "algorithms": [], "waste": 0, "waste_rate": 0, "waste_by": [], "deprecated": []}`, w.Body.String())
+
+	assert.Equal(t, http.StatusOK, get("/calculate?quantity=50").Code)
+	assert.Equal(t, http.StatusOK, get("/calculate?quantity=60&strategy=combination").Code)
+	req := httptest.NewRequest("POST", "/calculate", strings.NewReader(`{"quantity": 70, "constraints": {"max_packs": 2}}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	handler.allocator.SetTimeouts(10*time.Millisecond, time.Second)
+	assert.Equal(t, http.StatusOK, get("/calculate?quantity=80&strategy=stats-blocking").Code)
+
+	w = get("/stats")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"allocations": 4,
+		"approximate": 1,
+		"approximate_rate": 0.25,
+		"fallbacks": 1,
+		"fallback_rate": 0.25,
+		"algorithms": [
+			{"algorithm": "branchbound", "allocations": 1, "approximate": 0, "share": 0.25},
+			{"algorithm": "combination", "allocations": 1, "approximate": 0, "share": 0.25},
+			{"algorithm": "dp", "allocations": 1, "approximate": 0, "share": 0.25},
+			{"algorithm": "greedy", "allocations": 1, "approximate": 1, "share": 0.25}
+		],
+		"waste": 30,
+		"waste_rate": 0.11538461538461539,
+		"waste_by": [
+			{"group": "branchbound", "allocations": 1, "quantity": 70, "waste": 6, "waste_rate": 0.08571428571428572, "max_waste": 6},
+			{"group": "combination", "allocations": 1, "quantity": 60, "waste": 2, "waste_rate": 0.03333333333333333, "max_waste": 2},
+			{"group": "dp", "allocations": 1, "quantity": 50, "waste": 3, "waste_rate": 0.06, "max_waste": 3},
+			{"group": "greedy", "allocations": 1, "quantity": 80, "waste": 19, "waste_rate": 0.2375, "max_waste": 19}
+		],
+		"deprecated": []
+	}`, w.Body.String())
+
+	w = get("/stats?from=" + time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"allocations":0`)
+
+	assert.Equal(t, http.StatusBadRequest, get("/stats?from=yesterday").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/stats?to=tomorrow").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/stats?group_by=week").Code)
+}
+
+func TestDeprecatedSizes(t *testing.T) {
+	router, handler := setupTestRouter()
+	assert.NoError(t, handler.allocator.SetDeprecatedSizes(map[string][]int{"default": {53}}))
+
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+
+	w := get("/calculate?quantity=115&strategy=dp")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"packs":{"23":5}`)
+	assert.NotContains(t, w.Body.String(), "deprecated_packs")
+
+	w = get("/calculate?quantity=53&strategy=dp")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"deprecated_packs":{"53":1}`)
+
+	w = get("/stats")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"deprecated":[{"profile":"default","size":53,"packs":1,"allocations":1}]`)
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/allocator"
+)
+
+// @Summary Suggest zero-waste quantities
+// @Description Find the quantities nearest to an order quantity, below and above, that the profile's pack sizes allocate with zero waste, so sales can nudge customers towards them. Quantities with pack limits are not supported, deprecated sizes are avoided where possible, and nothing is stored. Quantities end at 1000000 at most.
+// @Tags packs
+// @Produce json
+// @Param quantity query int true "Order quantity"
+// @Param profile query string false "Profile name (default when empty)"
+// @Param format query string false "Packs format: map (default), list or flat" Enums(map, list, flat)
+// @Success 200 {object} SuggestResponse "Nearest zero-waste quantities; below is null when there is none"
+// @Failure 400 {object} ErrorResponse "Error message"
+// @Failure 422 {object} ErrorResponse "Too large a quantity or a profile with pack limits"
+// @Failure 429 {object} ErrorResponse "Usage quota exceeded"
+// @Failure 503 {object} ErrorResponse "Overloaded"
+// @Failure 504 {object} ErrorResponse "Error message"
+// @Router /v1/suggest [get]
+func (h *Handler) suggest(c *gin.Context) {
+	format, err := requestedFormat(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, codeInvalidFormat)
+		return
+	}
+	quantity, err := strconv.Atoi(c.Query("quantity"))
+	if err != nil || quantity <= 0 {
+		writeError(c, http.StatusBadRequest, codeInvalidQuantity)
+		return
+	}
+
+	s, err := h.allocator.Suggest(c.Request.Context(), quantity, c.Query("profile"))
+	if err != nil {
+		writeAllocationError(c, err)
+		return
+	}
+	response := SuggestResponse{
+		Quantity: s.Quantity,
+		Profile:  s.Profile,
+		Exact:    s.Exact,
+		Above:    suggestedQuantityResponse(format, s.Above),
+	}
+	if s.Below != nil {
+		below := suggestedQuantityResponse(format, *s.Below)
+		response.Below = &below
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func suggestedQuantityResponse(format packsFormat, q allocator.SuggestedQuantity) SuggestedQuantityResponse {
+	return SuggestedQuantityResponse{Quantity: q.Quantity, Packs: format.formatPacks(q.Packs)}
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuggest(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/v1/suggest?quantity=100&format=flat", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"quantity": 100,
+		"profile": "default",
+		"exact": true,
+		"below": {"quantity": 99, "packs": "1x53,2x23"},
+		"above": {"quantity": 106, "packs": "2x53"}
+	}`, w.Body.String())
+
+	// Nothing below the smallest size allocates without waste.
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/v1/suggest?quantity=10", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"quantity": 10,
+		"profile": "default",
+		"exact": false,
+		"below": null,
+		"above": {"quantity": 23, "packs": {"23": 1}}
+	}`, w.Body.String())
+}
+
+func TestSuggestErrors(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	tests := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{name: "no quantity", query: "", want: http.StatusBadRequest},
+		{name: "negative quantity", query: "quantity=-5", want: http.StatusBadRequest},
+		{name: "bad format", query: "quantity=5&format=xml", want: http.StatusBadRequest},
+		{name: "unknown profile", query: "quantity=5&profile=apparel", want: http.StatusBadRequest},
+		{name: "too large", query: "quantity=1000001", want: http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/v1/suggest?"+tt.query, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uiFS holds the admin UI, a single page built on the /v1/admin routes.
+//
+//go:embed ui
+var uiFS embed.FS
+
+// adminUI serves the admin UI. The page calls the API with paths relative
+// to its own, so it works under any base path. It is sent with a strict
+// Content-Security-Policy and, with CSRF protection, the CSRF token; see
+// Security.
+func (h *Handler) adminUI(c *gin.Context) {
+	page, err := uiFS.ReadFile("ui/admin.html")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if page, err = h.securePage(c, page); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalculateInUnits(t *testing.T) {
+	router, handler := setupTestRouter()
+	assert.NoError(t, handler.allocator.SetUnits(map[string]allocator.Unit{"case": {Multiplier: 12}}))
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		status int
+		want   string
+	}{
+		{
+			name: "get", method: http.MethodGet, target: "/calculate?quantity=4&unit=case",
+			status: http.StatusOK,
+			want:   `{"packs":{"53":1},"total":53,"approximate":false,"cached":false,"unit":"case","items":48}`,
+		},
+		{
+			name: "post", method: http.MethodPost, target: "/calculate", body: `{"quantity":4,"unit":"case","order_id":"ORD-1"}`,
+			status: http.StatusOK,
+			want:   `{"packs":{"53":1},"total":53,"approximate":false,"cached":false,"unit":"case","items":48,"order_id":"ORD-1"}`,
+		},
+		{
+			name: "items", method: http.MethodPost, target: "/calculate", body: `{"quantity":46,"unit":"units"}`,
+			status: http.StatusOK,
+			want:   `{"packs":{"23":2},"total":46,"approximate":false,"cached":false}`,
+		},
+		{
+			name: "unknown get", method: http.MethodGet, target: "/calculate?quantity=4&unit=pallet",
+			status: http.StatusBadRequest,
+			want:   `{"error":"invalid unit","code":"invalid_unit"}`,
+		},
+		{
+			name: "unknown post", method: http.MethodPost, target: "/calculate", body: `{"quantity":4,"unit":"pallet"}`,
+			status: http.StatusBadRequest,
+			want:   `{"error":"invalid request body","code":"invalid_body","errors":[{"field":"unit","rule":"oneof=units weight case"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.JSONEq(t, tt.want, withoutComputedAt(t, w.Body.String()))
+		})
+	}
+
+	stored, err := handler.allocator.GetAllocationsByOrderID("ORD-1")
+	assert.NoError(t, err)
+	if assert.Len(t, stored, 1) {
+		assert.Equal(t, 48, stored[0].OrderQuantity)
+		assert.Equal(t, "case", stored[0].Unit)
+		assert.Equal(t, 4, stored[0].RequestedQuantity)
+	}
+}
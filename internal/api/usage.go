@@ -0,0 +1,143 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/auth"
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+// anonymousClient is the client every unauthenticated caller is metered as.
+const anonymousClient = "anonymous"
+
+// SetAPIKeys lists the SHA-256 digests, in hex, of the API keys metered as
+// clients of their own. It must be called before RegisterRoutes.
+func (h *Handler) SetAPIKeys(digests []string) error {
+	keys := make(map[string]bool, len(digests))
+	for _, digest := range digests {
+		digest = strings.ToLower(digest)
+		if b, err := hex.DecodeString(digest); err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("invalid API key digest %q: want 64 hex digits", digest)
+		}
+		keys[digest] = true
+	}
+	h.apiKeys = keys
+	return nil
+}
+
+// meteredClient identifies the caller for metering. Only authenticated
+// callers are metered as themselves: API keys listed with SetAPIKeys, by
+// fingerprint as in the audit log, and bearers of tokens verified as on the
+// admin routes, by subject. Everyone else, including callers named only by
+// X-Forwarded-User, shares the anonymous client.
+func (h *Handler) meteredClient(c *gin.Context) string {
+	key := strings.TrimSpace(c.GetHeader("X-API-Key"))
+	token, bearer := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	token = strings.TrimSpace(token)
+	if key == "" && bearer {
+		key = token
+	}
+	if key != "" {
+		if digest := apiKeyDigest(key); h.apiKeys[digest] {
+			return "key:" + digest[:12]
+		}
+	}
+	if bearer && token != "" && h.adminAuth != nil {
+		claims, err := h.adminAuth.Verify(c.Request.Context(), token)
+		switch {
+		case err == nil && claims.Subject != "":
+			// The audit log names the subject too.
+			c.Set(subjectKey, claims.Subject)
+			return "user:" + claims.Subject
+		case err != nil && !errors.Is(err, auth.ErrInvalidToken):
+			log.Printf("Verifying token for usage metering failed: %v", err)
+		}
+	}
+	return anonymousClient
+}
+
+// metered enforces the monthly quota of the caller, identified by
+// meteredClient, and records the request and the time spent serving it
+// towards the caller's usage. Requests over quota are answered with 429 and
+// are not counted. Failures to read or record usage are logged and never
+// fail the request.
+func (h *Handler) metered(c *gin.Context) {
+	if !h.allocator.Metering().Enabled {
+		return
+	}
+	client := h.meteredClient(c)
+
+	var quota *allocator.QuotaError
+	switch err := h.allocator.CheckQuota(client); {
+	case errors.As(err, &quota):
+		seconds := int(math.Ceil(time.Until(quota.Reset).Seconds()))
+		c.Header("Retry-After", strconv.Itoa(seconds))
+		writeError(c, http.StatusTooManyRequests, codeQuotaExceeded)
+		c.Abort()
+		return
+	case err != nil:
+		log.Printf("Failed to check usage quota of %s: %v", client, err)
+	}
+
+	start := time.Now()
+	c.Next()
+	if err := h.allocator.RecordUsage(client, time.Since(start)); err != nil {
+		log.Printf("Failed to record usage of %s: %v", client, err)
+	}
+}
+
+// @Summary Get usage by client
+// @Description Report the requests and compute time each client used in a calendar month (UTC), with its quota, for billing by consumption. Clients are configured API keys (key:<fingerprint>) and verified token subjects (user:<sub>); everyone else is anonymous.
+// @Tags admin
+// @Produce json
+// @Param month query string false "Month as YYYY-MM (default the current month)"
+// @Param client query string false "Only this client, e.g. user:alice or key:3f2a9c1b0d4e"
+// @Success 200 {object} UsageResponse "Usage by client"
+// @Failure 400 {object} ErrorResponse "Error message"
+// @Failure 500 {object} ErrorResponse "Error message"
+// @Router /v1/admin/usage [get]
+func (h *Handler) getUsage(c *gin.Context) {
+	at := time.Now()
+	if v := c.Query("month"); v != "" {
+		month, err := time.Parse("2006-01", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid month: want YYYY-MM"})
+			return
+		}
+		at = month
+	}
+
+	usage, err := h.allocator.Usage(at, c.Query("client"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	resp := UsageResponse{
+		Month:    storage.UsageMonth(at),
+		Metering: h.allocator.Metering().Enabled,
+		Clients:  make([]ClientUsageResponse, 0, len(usage)),
+	}
+	for _, u := range usage {
+		cu := ClientUsageResponse{
+			Client:         u.Client,
+			Requests:       u.Requests,
+			ComputeSeconds: u.Compute.Seconds(),
+		}
+		if q := h.allocator.QuotaFor(u.Client); q != (allocator.Quota{}) {
+			cu.Quota = &QuotaResponse{Requests: q.Requests, ComputeSeconds: q.Compute.Seconds()}
+		}
+		resp.Clients = append(resp.Clients, cu)
+	}
+	c.JSON(http.StatusOK, resp)
+}
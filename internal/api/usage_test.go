@@ -0,0 +1,153 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsageQuota(t *testing.T) {
+	router, handler := setupAdminAuthRouter(fakeVerifier{tokens: map[string]auth.Claims{
+		"alice":        {Subject: "alice"},
+		"bob":          {Subject: "bob"},
+		"batch":        {Subject: "batch"},
+		"reader-token": {Subject: "ops", Roles: []string{auth.RoleReader}},
+	}})
+	assert.NoError(t, handler.allocator.SetMetering(allocator.Metering{
+		Enabled: true,
+		Default: allocator.Quota{Requests: 2},
+		Clients: map[string]allocator.Quota{"user:batch": {}},
+	}))
+
+	calculate := func(user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/calculate", strings.NewReader(`{"quantity": 50}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+user)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	getUsage := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/admin/usage"+query, nil)
+		req.Header.Set("Authorization", "Bearer reader-token")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusOK, calculate("alice").Code)
+	}
+	w := calculate("alice")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	var errResp ErrorResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+	assert.Equal(t, codeQuotaExceeded, errResp.Code)
+	retry, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	assert.NoError(t, err)
+	assert.Positive(t, retry)
+
+	// Other clients have quotas of their own; an empty one is unlimited.
+	assert.Equal(t, http.StatusOK, calculate("bob").Code)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, calculate("batch").Code)
+	}
+
+	// Reads are not metered.
+	req := httptest.NewRequest("GET", "/recent", nil)
+	req.Header.Set("Authorization", "Bearer alice")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = getUsage("")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var usage UsageResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &usage))
+	assert.True(t, usage.Metering)
+	if assert.Len(t, usage.Clients, 3) {
+		// The rejected request is not counted.
+		alice, batch, bob := usage.Clients[0], usage.Clients[1], usage.Clients[2]
+		assert.Equal(t, "user:alice", alice.Client)
+		assert.Equal(t, int64(2), alice.Requests)
+		assert.Equal(t, &QuotaResponse{Requests: 2}, alice.Quota)
+		assert.Equal(t, "user:batch", batch.Client)
+		assert.Equal(t, int64(3), batch.Requests)
+		assert.Nil(t, batch.Quota)
+		assert.Equal(t, "user:bob", bob.Client)
+	}
+
+	w = getUsage("?client=user:bob")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &usage))
+	assert.Len(t, usage.Clients, 1)
+
+	w = getUsage("?month=2020-01")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &usage))
+	assert.Equal(t, "2020-01", usage.Month)
+	assert.Empty(t, usage.Clients)
+
+	w = getUsage("?month=June")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUsageClients(t *testing.T) {
+	router, handler := setupAdminAuthRouter(fakeVerifier{tokens: map[string]auth.Claims{
+		"alice-token": {Subject: "alice"},
+	}})
+	assert.NoError(t, handler.SetAPIKeys([]string{apiKeyDigest("team-key")}))
+	// httptest requests come from 192.0.2.1.
+	assert.NoError(t, handler.SetTrustedProxies([]string{"192.0.2.1"}))
+	assert.NoError(t, handler.allocator.SetMetering(allocator.Metering{Enabled: true}))
+
+	for _, headers := range []map[string]string{
+		{"X-API-Key": "team-key"},
+		{"Authorization": "Bearer team-key"},
+		{"Authorization": "Bearer alice-token"},
+		// Unauthenticated callers share the anonymous client.
+		{},
+		{"X-API-Key": "made-up-key"},
+		{"Authorization": "Bearer forged-token"},
+		{"X-Forwarded-User": "mallory"},
+	} {
+		req := httptest.NewRequest("GET", "/calculate?quantity=50", nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, "%v", headers)
+	}
+
+	usage, err := handler.allocator.Usage(time.Now(), "")
+	assert.NoError(t, err)
+	requests := make(map[string]int64)
+	for _, u := range usage {
+		requests[u.Client] = u.Requests
+	}
+	assert.Equal(t, map[string]int64{
+		"key:" + apiKeyDigest("team-key")[:12]: 2,
+		"user:alice":                           1,
+		anonymousClient:                        4,
+	}, requests)
+
+	assert.Error(t, handler.SetAPIKeys([]string{"3f2a9c1b0d4e"}))
+}
+
+func TestUsageNotMetered(t *testing.T) {
+	router, handler := setupTestRouter()
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/calculate?quantity=50", nil))
+
+	usage, err := handler.allocator.Usage(time.Now(), "")
+	assert.NoError(t, err)
+	assert.Empty(t, usage)
+}
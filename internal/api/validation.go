@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	// Name fields in validation errors by their JSON keys rather than their
+	// Go names, so clients can map errors back to what they sent.
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(jsonFieldName)
+	}
+}
+
+// jsonFieldName returns the JSON key of a struct field.
+func jsonFieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return f.Name
+	}
+	return name
+}
+
+// fieldErrors lists the fields of a binding error, or returns nil if it is
+// not about particular fields, e.g. malformed JSON.
+func fieldErrors(err error) []FieldError {
+	var invalid validator.ValidationErrors
+	if errors.As(err, &invalid) {
+		errs := make([]FieldError, len(invalid))
+		for i, e := range invalid {
+			// The namespace starts with the request struct's name.
+			_, field, _ := strings.Cut(e.Namespace(), ".")
+			rule := e.Tag()
+			if e.Param() != "" {
+				rule += "=" + e.Param()
+			}
+			errs[i] = FieldError{Field: field, Rule: rule}
+		}
+		return errs
+	}
+	var mistyped *json.UnmarshalTypeError
+	if errors.As(err, &mistyped) && mistyped.Field != "" {
+		return []FieldError{{Field: indexPath(mistyped.Field), Rule: "type=" + jsonType(mistyped.Type)}}
+	}
+	return nil
+}
+
+// indexPath rewrites the array indexes of a decoder field path such as
+// "items.0.quantity" the way validation errors write them,
+// "items[0].quantity".
+func indexPath(path string) string {
+	var b strings.Builder
+	for i, part := range strings.Split(path, ".") {
+		if _, err := strconv.Atoi(part); err == nil && i > 0 {
+			b.WriteString("[" + part + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+// jsonType names the JSON Schema type a Go type is decoded from.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Pointer:
+		return jsonType(t.Elem())
+	}
+	return "object"
+}
+
+// writeFieldErrors writes a 400 listing the fields that failed validation.
+func writeFieldErrors(c *gin.Context, errs ...FieldError) {
+	lang := requestLanguage(c)
+	c.Header("Content-Language", lang)
+	c.JSON(http.StatusBadRequest, ValidationErrorResponse{Error: localize(lang, codeInvalidBody), Code: codeInvalidBody, Errors: errs})
+}
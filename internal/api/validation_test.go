@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidationErrors(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   []FieldError
+	}{
+		{"missing quantity", "POST", "/calculate", `{}`, []FieldError{{"quantity", "required"}}},
+		{"zero quantity", "POST", "/calculate", `{"quantity":0}`, []FieldError{{"quantity", "gt=0"}}},
+		{"unknown unit", "POST", "/calculate", `{"quantity":1,"unit":"litres"}`, []FieldError{{"unit", "oneof=units weight"}}},
+		{"negative max packs", "POST", "/calculate", `{"quantity":1,"constraints":{"max_packs":-1}}`, []FieldError{{"constraints.max_packs", "gte=0"}}},
+		{"negative stock", "POST", "/calculate", `{"quantity":1,"constraints":{"available":{"23":-1}}}`, []FieldError{{"constraints.available[23]", "gte=0"}}},
+		{"mistyped field", "POST", "/calculate/order", `{"items":"A"}`, []FieldError{{"items", "type=array"}}},
+		{"mistyped quantity", "POST", "/calculate/order", `{"items":[{"sku":"A","quantity":"one"}]}`, []FieldError{{"items[0].quantity", "type=integer"}}},
+		{"decimal quantity", "POST", "/calculate", `{"quantity":2.5}`, []FieldError{{"quantity", "type=integer"}}},
+		{"no items", "POST", "/calculate/order", `{"items":[]}`, []FieldError{{"items", "min=1"}}},
+		{"several items", "POST", "/calculate/order", `{"items":[{"sku":"A","quantity":1},{"sku":"B","quantity":0},{"sku":"C","quantity":-1}]}`,
+			[]FieldError{{"items[1].quantity", "gt=0"}, {"items[2].quantity", "gt=0"}}},
+		{"one set", "POST", "/calculate/compare", `{"quantity":10,"sets":[{"pack_sizes":[5]}]}`, []FieldError{{"sets", "min=2"}}},
+		{"bad size", "POST", "/calculate/compare", `{"quantity":10,"sets":[{"pack_sizes":[5]},{"pack_sizes":[-1]}]}`, []FieldError{{"sets[1].pack_sizes[0]", "gt=0"}}},
+		{"no candidate", "POST", "/simulate", `{"quantity":10}`, []FieldError{{"candidate", "required"}}},
+		{"pin without packs", "PUT", "/allocations/pin", `{"quantity":50}`, []FieldError{{"packs", "required"}}},
+		{"read-only mode", "POST", "/admin/read-only", `{"enabled":true,"mode":"drop"}`, []FieldError{{"mode", "oneof=skip reject"}}},
+		{"profile sizes", "PUT", "/admin/profiles/default", `{"pack_sizes":[]}`, []FieldError{{"pack_sizes", "min=1"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var response ValidationErrorResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, "invalid request body", response.Error)
+			assert.Equal(t, tt.want, response.Errors)
+		})
+	}
+
+	// Malformed JSON has no fields to report
+	req := httptest.NewRequest("POST", "/calculate", strings.NewReader(`{"quantity":`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":"invalid request body","code":"invalid_body"}`, w.Body.String())
+}
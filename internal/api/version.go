@@ -0,0 +1,97 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIVersion is the current API version. Its routes are mounted under
+// /v1, e.g. /v1/calculate.
+//
+// Response shapes only change in a new version: a /v2 group registers the
+// changed handlers and reuses the v1 handlers for everything else, while /v1
+// keeps answering as before. Every versioned route reports its version in
+// the API-Version response header and stores it in the request context
+// under versionKey, so a handler shared by several versions can pick the
+// shape it writes. Clients may send API-Version to assert the version they
+// expect; a request for another version is rejected with 400 rather than
+// answered in a shape the client cannot read.
+const APIVersion = "v1"
+
+const (
+	// versionHeader carries the API version in requests and responses.
+	versionHeader = "API-Version"
+	// versionKey is the context key of the version serving a request.
+	versionKey = "apiVersion"
+)
+
+// apiVersions lists the mounted versions, oldest first.
+var apiVersions = []string{APIVersion}
+
+// LegacyRoutes configures the unversioned paths of the first release, such
+// as /calculate, which remain as deprecated aliases of the v1 routes.
+type LegacyRoutes struct {
+	// Disabled stops serving the unversioned paths, e.g. after the sunset.
+	Disabled bool
+	// Deprecated is announced in the Deprecation header; when zero the
+	// header is "true".
+	Deprecated time.Time
+	// Sunset, when set, is announced in the Sunset header as the date the
+	// unversioned paths stop being served.
+	Sunset time.Time
+}
+
+// SetLegacyRoutes configures the unversioned aliases of the v1 routes. They
+// are served, and announced as deprecated, by default. It must be called
+// before RegisterRoutes.
+func (h *Handler) SetLegacyRoutes(l LegacyRoutes) {
+	h.legacy = l
+}
+
+// versioned marks requests as served by version, rejecting those that ask
+// for another one through the API-Version header.
+func versioned(version string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if v := c.GetHeader(versionHeader); v != "" && v != version {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("unsupported API version %q for this path; use /%s", v, v),
+			})
+			return
+		}
+		c.Set(versionKey, version)
+		c.Header(versionHeader, version)
+	}
+}
+
+// deprecated announces that an unversioned path is deprecated, when it
+// will be removed and which versioned path replaces it (RFC 9745, RFC 8594
+// and RFC 8288).
+func (h *Handler) deprecated(c *gin.Context) {
+	if h.legacy.Deprecated.IsZero() {
+		c.Header("Deprecation", "true")
+	} else {
+		c.Header("Deprecation", fmt.Sprintf("@%d", h.legacy.Deprecated.Unix()))
+	}
+	if !h.legacy.Sunset.IsZero() {
+		c.Header("Sunset", h.legacy.Sunset.UTC().Format(http.TimeFormat))
+	}
+	successor := h.basePath + "/" + APIVersion + strings.TrimPrefix(c.Request.URL.Path, h.basePath)
+	c.Header("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+}
+
+// routeKey returns the route pattern of a request without the base path
+// and version prefix, e.g. "/calculate" for "/pack-api/v1/calculate", so
+// per-route settings apply to every version and to the legacy paths alike.
+func routeKey(fullPath, basePath string) string {
+	route := strings.TrimPrefix(fullPath, basePath)
+	for _, v := range apiVersions {
+		if rest, ok := strings.CutPrefix(route, "/"+v); ok && (rest == "" || rest[0] == '/') {
+			return rest
+		}
+	}
+	return route
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+func TestVersionedRoutes(t *testing.T) {
+	router, handler := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/calculate?quantity=50", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "v1", w.Header().Get("API-Version"))
+	assert.Empty(t, w.Header().Get("Deprecation"))
+
+	// The requested version must match the path
+	req := httptest.NewRequest("GET", "/v1/calculate?quantity=50", nil)
+	req.Header.Set("API-Version", "v1")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req = httptest.NewRequest("GET", "/v1/calculate?quantity=50", nil)
+	req.Header.Set("API-Version", "v2")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "unsupported API version")
+
+	// Operational routes are not versioned
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("API-Version"))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/health", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Versioned calculations are audited like the legacy ones
+	entries, err := handler.allocator.AuditEntries(storage.AuditFilter{})
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, "/v1/calculate", entries[0].Path)
+	}
+}
+
+func TestLegacyRoutes(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/calculate?quantity=50", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "v1", w.Header().Get("API-Version"))
+	assert.Equal(t, "true", w.Header().Get("Deprecation"))
+	assert.Empty(t, w.Header().Get("Sunset"))
+	assert.Equal(t, `</v1/calculate>; rel="successor-version"`, w.Header().Get("Link"))
+
+	gin.SetMode(gin.TestMode)
+	router = gin.New()
+	handler := NewHandler(allocator.NewAllocator([]int{23, 31, 53}, newMockStorage()))
+	handler.SetBasePath("/pack-api")
+	handler.SetLegacyRoutes(LegacyRoutes{
+		Deprecated: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Sunset:     time.Date(2027, 6, 30, 0, 0, 0, 0, time.UTC),
+	})
+	handler.RegisterRoutes(router)
+	assert.NoError(t, handler.allocator.RecordProfileVersions())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/pack-api/profiles/default/versions", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "@1767225600", w.Header().Get("Deprecation"))
+	assert.Equal(t, "Wed, 30 Jun 2027 00:00:00 GMT", w.Header().Get("Sunset"))
+	assert.Equal(t, `</pack-api/v1/profiles/default/versions>; rel="successor-version"`, w.Header().Get("Link"))
+
+	// Disabled legacy routes are not served
+	router = gin.New()
+	handler = NewHandler(allocator.NewAllocator([]int{23, 31, 53}, newMockStorage()))
+	handler.SetLegacyRoutes(LegacyRoutes{Disabled: true})
+	handler.RegisterRoutes(router)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/calculate?quantity=50", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/calculate?quantity=50", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRouteKey(t *testing.T) {
+	tests := []struct {
+		fullPath string
+		basePath string
+		expected string
+	}{
+		{"/calculate", "", "/calculate"},
+		{"/v1/calculate", "", "/calculate"},
+		{"/pack-api/v1/admin/profiles/:name", "/pack-api", "/admin/profiles/:name"},
+		{"/pack-api/recent", "/pack-api", "/recent"},
+		{"/v10/calculate", "", "/v10/calculate"},
+		{"/health", "", "/health"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, routeKey(tt.fullPath, tt.basePath), tt.fullPath)
+	}
+}
+
+func TestTimeoutVersionedRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Timeout(Timeouts{Default: time.Second, Routes: map[string]time.Duration{"/stream": 0}}))
+	router.GET("/v1/stream", func(c *gin.Context) {
+		_, ok := c.Request.Context().Deadline()
+		assert.False(t, ok)
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/stream", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
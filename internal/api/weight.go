@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/allocator"
+)
+
+// Units built into the calculate endpoints. Others are configured with
+// allocator.SetUnits.
+const (
+	unitUnits  = "units"
+	unitWeight = "weight"
+)
+
+// unitRule is the validation rule listing the units a request may name.
+func (h *Handler) unitRule() string {
+	var configured []string
+	for name := range h.allocator.Units() {
+		configured = append(configured, name)
+	}
+	sort.Strings(configured)
+	return "oneof=" + strings.Join(append([]string{unitUnits, unitWeight}, configured...), " ")
+}
+
+// calculateByWeight parses quantity as decimal kilograms, allocates it with
+// the weight pack sizes and writes the JSON response.
+func (h *Handler) calculateByWeight(c *gin.Context, quantity string, req allocator.WeightRequest) {
+	debug, err := debugRequested(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, codeInvalidDebug)
+		return
+	}
+	format, err := requestedFormat(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, codeInvalidFormat)
+		return
+	}
+	// The weight profile has no version history to travel back through.
+	if c.Query("as_of") != "" {
+		writeError(c, http.StatusBadRequest, codeWeightAsOf)
+		return
+	}
+	weight, err := allocator.ParseWeight(quantity)
+	if err != nil || weight <= 0 {
+		writeError(c, http.StatusBadRequest, codeInvalidWeight)
+		return
+	}
+	req.Quantity = weight
+	c.Set(quantityKey, int(weight))
+
+	result, err := h.allocator.AllocateWeight(c.Request.Context(), req)
+	if err != nil {
+		writeAllocationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, WeightCalculateResponse{
+		Unit:        unitWeight,
+		Packs:       format.formatWeightPacks(result.Packs),
+		Total:       result.Total.Kilograms(),
+		Approximate: result.Approximate,
+		Cached:      result.Stats.Cache == allocator.CacheHit,
+		ComputedAt:  result.ComputedAt,
+		OrderID:     req.OrderID,
+		CustomerID:  req.CustomerID,
+		Debug:       debugResponse(debug, result.Stats),
+	})
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupWeightRouter(t *testing.T) http.Handler {
+	router, handler := setupTestRouter()
+	assert.NoError(t, handler.allocator.SetWeightPackSizes([]allocator.Weight{500, allocator.Kilogram, 2500}))
+	return router
+}
+
+func TestCalculatePacksByWeight(t *testing.T) {
+	router := setupWeightRouter(t)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/calculate?unit=weight&quantity=3.2", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp WeightCalculateResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "weight", resp.Unit)
+	assert.Equal(t, map[string]interface{}{"2.5": float64(1), "1": float64(1)}, resp.Packs)
+	assert.Equal(t, 3.5, resp.Total)
+}
+
+func TestCalculatePacksByWeightErrors(t *testing.T) {
+	router := setupWeightRouter(t)
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{name: "too precise", query: "unit=weight&quantity=1.2345", want: "invalid quantity"},
+		{name: "negative", query: "unit=weight&quantity=-1", want: "invalid quantity"},
+		{name: "zero", query: "unit=weight&quantity=0", want: "invalid quantity"},
+		{name: "unknown unit", query: "unit=litres&quantity=1", want: "invalid unit"},
+		{name: "decimal units", query: "quantity=1.5", want: "invalid quantity"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/calculate?"+tt.query, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tt.want)
+		})
+	}
+}
+
+func TestCalculatePacksPostByWeight(t *testing.T) {
+	router := setupWeightRouter(t)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "weight",
+			body:       `{"unit":"weight","quantity":3.2,"order_id":"ORD-7"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"unit":"weight","packs":{"1":1,"2.5":1},"total":3.5,"approximate":false,"cached":false,"order_id":"ORD-7"}`,
+		},
+		{
+			name:       "units",
+			body:       `{"unit":"units","quantity":50}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"packs":{"53":1},"total":53,"approximate":false,"cached":false}`,
+		},
+		{
+			name:       "decimal units",
+			body:       `{"quantity":2.5}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"invalid request body","code":"invalid_body","errors":[{"field":"quantity","rule":"type=integer"}]}`,
+		},
+		{
+			name:       "weight with constraints",
+			body:       `{"unit":"weight","quantity":1,"constraints":{"max_packs":1}}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"constraints are not supported for weight","code":"weight_constraints"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/calculate", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.JSONEq(t, tt.wantBody, withoutComputedAt(t, w.Body.String()))
+		})
+	}
+}
+
+func TestCalculatePacksByWeightNotConfigured(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/calculate?unit=weight&quantity=1", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "no weight pack sizes configured")
+}
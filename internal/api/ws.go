@@ -0,0 +1,231 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/n-th/gymshark/internal/allocator"
+)
+
+// liveMaxMessageBytes bounds a single client message on /ws/calculate.
+const liveMaxMessageBytes = 4096
+
+// LiveOptions tunes the /ws/calculate live calculator.
+type LiveOptions struct {
+	// Debounce is how long a connection must stay quiet before the latest
+	// quantity is calculated. Zero calculates every message immediately.
+	Debounce time.Duration
+	// Rate and Burst bound the messages accepted per connection per second.
+	// Messages over the limit are answered with an error. Zero Rate disables it.
+	Rate  float64
+	Burst int
+}
+
+// DefaultLiveOptions returns the live calculator settings used by NewHandler.
+func DefaultLiveOptions() LiveOptions {
+	return LiveOptions{Debounce: 150 * time.Millisecond, Rate: 10, Burst: 20}
+}
+
+// SetLiveOptions configures debouncing and rate limiting for /ws/calculate.
+func (h *Handler) SetLiveOptions(o LiveOptions) {
+	h.live = o
+}
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     checkOrigin,
+}
+
+// checkOrigin accepts same-origin requests and the frontend allowed by CORS.
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || origin == allowedOrigin {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// liveRequest is a client message on /ws/calculate. A bare number is
+// accepted as shorthand for {"quantity": n}.
+type liveRequest struct {
+	Quantity int    `json:"quantity"`
+	Strategy string `json:"strategy"`
+	Profile  string `json:"profile"`
+}
+
+// liveMessage carries a parsed request, or a rejection, to the writer loop.
+type liveMessage struct {
+	req liveRequest
+	err string
+}
+
+// @Summary Live pack calculator
+// @Description Upgrade to a WebSocket. Send quantities (a number or {"quantity", "strategy", "profile"}) and receive {"quantity", "packs", "total", "approximate"} or {"quantity", "error"}. Rapid messages are debounced so only the latest quantity is calculated. Results are not stored.
+// @Tags packs
+// @Success 101 {string} string "Switching Protocols"
+// @Failure 400 {string} string "Not a WebSocket handshake"
+// @Router /v1/ws/calculate [get]
+func (h *Handler) liveCalculate(c *gin.Context) {
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already written the error response
+		log.Printf("WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(liveMaxMessageBytes)
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	lang := requestLanguage(c)
+
+	in := make(chan liveMessage)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer cancel()
+		h.writeLive(ctx, conn, in, lang)
+	}()
+
+	limiter := newTokenBucket(h.live.Rate, h.live.Burst)
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("WebSocket read failed: %v", err)
+			}
+			break
+		}
+
+		msg := liveMessage{}
+		if !limiter.allow(time.Now()) {
+			msg.err = localize(lang, codeRateLimited)
+		} else if msg.req, err = parseLiveRequest(data); err != nil {
+			msg.err = localize(lang, codeInvalidMessage)
+		}
+
+		select {
+		case in <- msg:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	cancel()
+	<-done
+}
+
+// writeLive owns the connection's write side. It debounces requests, runs
+// the latest one and reports results and rejections back to the client in
+// lang.
+func (h *Handler) writeLive(ctx context.Context, conn *websocket.Conn, in <-chan liveMessage, lang string) {
+	var pending *liveRequest
+	var fire <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-in:
+			if msg.err != "" {
+				if err := conn.WriteJSON(gin.H{"quantity": msg.req.Quantity, "error": msg.err}); err != nil {
+					return
+				}
+				continue
+			}
+			pending = &msg.req
+			if h.live.Debounce > 0 {
+				fire = time.After(h.live.Debounce)
+				continue
+			}
+		case <-fire:
+		}
+
+		if pending == nil {
+			continue
+		}
+		req := *pending
+		pending, fire = nil, nil
+		if err := conn.WriteJSON(h.liveResult(ctx, req, lang)); err != nil {
+			return
+		}
+	}
+}
+
+// liveResult calculates a live request without persisting it.
+func (h *Handler) liveResult(ctx context.Context, req liveRequest, lang string) gin.H {
+	if req.Quantity <= 0 {
+		return gin.H{"quantity": req.Quantity, "error": localize(lang, codeInvalidQuantity)}
+	}
+	result, err := h.allocator.Preview(ctx, allocator.Request{
+		Quantity: req.Quantity,
+		Strategy: req.Strategy,
+		Profile:  req.Profile,
+	})
+	if err != nil {
+		_, msg := localizeError(lang, err)
+		return gin.H{"quantity": req.Quantity, "error": msg}
+	}
+	return gin.H{
+		"quantity":    req.Quantity,
+		"packs":       result.Packs,
+		"total":       result.Total,
+		"approximate": result.Approximate,
+	}
+}
+
+func parseLiveRequest(data []byte) (liveRequest, error) {
+	if n, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil {
+		return liveRequest{Quantity: n}, nil
+	}
+	var req liveRequest
+	err := json.Unmarshal(data, &req)
+	return req, err
+}
+
+// tokenBucket is a per-connection rate limiter. It is not safe for
+// concurrent use.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+// allow reports whether an event at now is within the limit, consuming a token if so.
+func (b *tokenBucket) allow(now time.Time) bool {
+	if b.rate <= 0 {
+		return true
+	}
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
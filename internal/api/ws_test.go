@@ -0,0 +1,132 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+type liveResponse struct {
+	Quantity int            `json:"quantity"`
+	Packs    map[string]int `json:"packs"`
+	Total    int            `json:"total"`
+	Error    string         `json:"error"`
+}
+
+func dialLive(t *testing.T, opts LiveOptions) (*websocket.Conn, *Handler) {
+	t.Helper()
+	router, handler := setupTestRouter()
+	handler.SetLiveOptions(opts)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/calculate"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn, handler
+}
+
+func TestLiveCalculate(t *testing.T) {
+	conn, handler := dialLive(t, LiveOptions{})
+
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("50")))
+	var response liveResponse
+	assert.NoError(t, conn.ReadJSON(&response))
+	assert.Equal(t, 50, response.Quantity)
+	assert.Equal(t, map[string]int{"53": 1}, response.Packs)
+	assert.Equal(t, 53, response.Total)
+
+	assert.NoError(t, conn.WriteJSON(map[string]interface{}{"quantity": 46, "strategy": "dp"}))
+	assert.NoError(t, conn.ReadJSON(&response))
+	assert.Equal(t, 46, response.Quantity)
+	assert.Equal(t, 46, response.Total)
+
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("0")))
+	response = liveResponse{}
+	assert.NoError(t, conn.ReadJSON(&response))
+	assert.Equal(t, "invalid quantity", response.Error)
+
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("{not json")))
+	response = liveResponse{}
+	assert.NoError(t, conn.ReadJSON(&response))
+	assert.Equal(t, "invalid message", response.Error)
+
+	// Live results are previews and must not be stored
+	recent, err := handler.allocator.GetAllocationsByOrderID("")
+	assert.NoError(t, err)
+	assert.Empty(t, recent)
+}
+
+func TestLiveCalculateDebounce(t *testing.T) {
+	conn, _ := dialLive(t, LiveOptions{Debounce: 100 * time.Millisecond})
+
+	for _, q := range []string{"5", "50", "500"} {
+		assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(q)))
+	}
+
+	var response liveResponse
+	assert.NoError(t, conn.ReadJSON(&response))
+	assert.Equal(t, 500, response.Quantity)
+
+	// Nothing else is sent for the superseded quantities
+	conn.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+	assert.Error(t, conn.ReadJSON(&response))
+}
+
+func TestLiveCalculateRateLimit(t *testing.T) {
+	conn, _ := dialLive(t, LiveOptions{Rate: 0.001, Burst: 1})
+
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("50")))
+	var response liveResponse
+	assert.NoError(t, conn.ReadJSON(&response))
+	assert.Equal(t, 53, response.Total)
+
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("60")))
+	response = liveResponse{}
+	assert.NoError(t, conn.ReadJSON(&response))
+	assert.Equal(t, "rate limit exceeded", response.Error)
+}
+
+func TestLiveCalculateOrigin(t *testing.T) {
+	router, _ := setupTestRouter()
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/calculate"
+	_, resp, err := websocket.DefaultDialer.Dial(url, map[string][]string{"Origin": {"http://evil.example"}})
+	assert.Error(t, err)
+	if resp != nil {
+		assert.Equal(t, 403, resp.StatusCode)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, map[string][]string{"Origin": {allowedOrigin}})
+	if assert.NoError(t, err) {
+		conn.Close()
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Now()
+	b := newTokenBucket(2, 2)
+
+	assert.True(t, b.allow(start))
+	assert.True(t, b.allow(start))
+	assert.False(t, b.allow(start))
+
+	// Half a second refills one token at 2/s
+	assert.True(t, b.allow(start.Add(500*time.Millisecond)))
+	assert.False(t, b.allow(start.Add(500*time.Millisecond)))
+
+	unlimited := newTokenBucket(0, 0)
+	for i := 0; i < 100; i++ {
+		assert.True(t, unlimited.allow(start))
+	}
+}
@@ -0,0 +1,208 @@
+// Package auth verifies the JWT bearer tokens administrators present to the
+// admin routes: signed by a key the identity provider publishes in its JWKS,
+// issued by the configured issuer, and carrying the roles the route needs.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Roles granted by the roles claim. An admin is a reader too.
+const (
+	RoleAdmin  = "admin"
+	RoleReader = "reader"
+)
+
+// ErrInvalidToken is returned for tokens that are malformed, expired, from
+// another issuer or audience, or signed by an unknown key.
+var ErrInvalidToken = errors.New("invalid token")
+
+// DefaultRolesClaim is the claim holding the roles when Config leaves it
+// empty.
+const DefaultRolesClaim = "roles"
+
+// DefaultRefreshInterval is how often the JWKS is reloaded when Config
+// leaves it zero.
+const DefaultRefreshInterval = time.Hour
+
+// minRefetch bounds how often the JWKS is loaded, so tokens with made-up key
+// IDs cannot flood the identity provider.
+const minRefetch = 30 * time.Second
+
+// validMethods are the signing algorithms accepted. Symmetric algorithms are
+// not: a JWKS publishes public keys.
+var validMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// Config configures a Verifier.
+type Config struct {
+	// Issuer must match the iss claim. Required.
+	Issuer string
+	// Audience, when set, must be among the aud claim.
+	Audience string
+	// JWKSURL serves the identity provider's signing keys. Required.
+	JWKSURL string
+	// RolesClaim names the claim listing the caller's roles, a string
+	// array or a space-separated string.
+	RolesClaim string
+	// RefreshInterval is how often the keys are reloaded.
+	RefreshInterval time.Duration
+	// Client fetches the JWKS; nil uses a client with a 10 second timeout.
+	Client *http.Client
+}
+
+// Claims are what a verified token says about its bearer.
+type Claims struct {
+	Subject string
+	Roles   []string
+}
+
+// HasRole reports whether the claims grant role. Admins are readers too.
+func (c Claims) HasRole(role string) bool {
+	if slices.Contains(c.Roles, RoleAdmin) {
+		return true
+	}
+	return slices.Contains(c.Roles, role)
+}
+
+// Verifier checks bearer tokens against the keys of a JWKS. It is safe for
+// concurrent use.
+type Verifier struct {
+	cfg    Config
+	parser *jwt.Parser
+
+	mu   sync.Mutex
+	keys map[string]interface{}
+	// fetched is when keys were loaded, attempted when a load was last
+	// tried, and err why it failed.
+	fetched   time.Time
+	attempted time.Time
+	err       error
+}
+
+// NewVerifier returns a Verifier for cfg. Keys are fetched on first use.
+func NewVerifier(cfg Config) (*Verifier, error) {
+	if cfg.Issuer == "" || cfg.JWKSURL == "" {
+		return nil, errors.New("auth: issuer and jwks_url are required")
+	}
+	if cfg.RolesClaim == "" {
+		cfg.RolesClaim = DefaultRolesClaim
+	}
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = DefaultRefreshInterval
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(validMethods),
+		jwt.WithIssuer(cfg.Issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30 * time.Second),
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	return &Verifier{cfg: cfg, parser: jwt.NewParser(opts...)}, nil
+}
+
+// Verify checks token and returns its claims. It fails with ErrInvalidToken
+// if the token does not verify, and with another error if the keys cannot
+// be loaded.
+func (v *Verifier) Verify(ctx context.Context, token string) (Claims, error) {
+	var fetchErr error
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := v.key(ctx, kid)
+		if err != nil {
+			fetchErr = err
+		}
+		return key, err
+	})
+	if fetchErr != nil && !errors.Is(fetchErr, ErrInvalidToken) {
+		return Claims{}, fetchErr
+	}
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	subject, _ := claims.GetSubject()
+	return Claims{Subject: subject, Roles: roles(claims[v.cfg.RolesClaim])}, nil
+}
+
+// key returns the signing key with ID kid, reloading the JWKS when it is
+// stale or when kid is unknown, but at most every minRefetch. An empty kid
+// matches the only key of a single-key set.
+func (v *Verifier) key(ctx context.Context, kid string) (interface{}, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := time.Now()
+	canFetch := now.Sub(v.attempted) >= minRefetch
+	if canFetch && (v.keys == nil || now.Sub(v.fetched) >= v.cfg.RefreshInterval) {
+		v.refresh(ctx, now)
+		canFetch = false
+	}
+	if key, ok := v.lookup(kid); ok {
+		return key, nil
+	}
+	if canFetch {
+		v.refresh(ctx, now)
+		if key, ok := v.lookup(kid); ok {
+			return key, nil
+		}
+	}
+	if v.keys == nil {
+		return nil, v.err
+	}
+	return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+}
+
+// lookup finds kid among the loaded keys. v.mu must be held.
+func (v *Verifier) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+	key, ok := v.keys[kid]
+	return key, ok
+}
+
+// refresh reloads the keys. v.mu must be held. A failed reload keeps the
+// keys already loaded and records the error in v.err.
+func (v *Verifier) refresh(ctx context.Context, now time.Time) {
+	v.attempted = now
+	keys, err := fetchJWKS(ctx, v.cfg.Client, v.cfg.JWKSURL)
+	if err != nil {
+		v.err = fmt.Errorf("auth: load jwks: %w", err)
+		return
+	}
+	v.keys, v.fetched, v.err = keys, now, nil
+}
+
+// roles reads a roles claim: an array of strings or a space-separated string.
+func roles(claim interface{}) []string {
+	switch c := claim.(type) {
+	case string:
+		return strings.Fields(c)
+	case []interface{}:
+		var roles []string
+		for _, r := range c {
+			if s, ok := r.(string); ok {
+				roles = append(roles, s)
+			}
+		}
+		return roles
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// testJWKS serves the public halves of its keys as a JWKS.
+type testJWKS struct {
+	mu      sync.Mutex
+	keys    []jwk
+	fetches int
+	down    bool
+}
+
+func (s *testJWKS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetches++
+	if s.down {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string][]jwk{"keys": s.keys})
+}
+
+func (s *testJWKS) addRSA(kid string, key *rsa.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = append(s.keys, jwk{
+		Kty: "RSA", Kid: kid, Use: "sig",
+		N: b64(key.N), E: b64(big.NewInt(int64(key.E))),
+	})
+}
+
+func (s *testJWKS) addEC(kid string, key *ecdsa.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = append(s.keys, jwk{Kty: "EC", Kid: kid, Crv: "P-256", X: b64(key.X), Y: b64(key.Y)})
+}
+
+func b64(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	s, err := token.SignedString(key)
+	assert.NoError(t, err)
+	return s
+}
+
+func claims(overrides jwt.MapClaims) jwt.MapClaims {
+	c := jwt.MapClaims{
+		"iss":   "https://idp.example.com",
+		"aud":   "gymshark",
+		"sub":   "alice",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"roles": []string{RoleAdmin},
+	}
+	for k, v := range overrides {
+		if v == nil {
+			delete(c, k)
+			continue
+		}
+		c[k] = v
+	}
+	return c
+}
+
+func setupVerifier(t *testing.T) (*Verifier, *testJWKS, *rsa.PrivateKey) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	jwks := &testJWKS{}
+	jwks.addRSA("rsa-1", &rsaKey.PublicKey)
+	server := httptest.NewServer(jwks)
+	t.Cleanup(server.Close)
+
+	v, err := NewVerifier(Config{Issuer: "https://idp.example.com", Audience: "gymshark", JWKSURL: server.URL})
+	assert.NoError(t, err)
+	return v, jwks, rsaKey
+}
+
+func TestNewVerifier(t *testing.T) {
+	_, err := NewVerifier(Config{JWKSURL: "https://idp.example.com/jwks"})
+	assert.Error(t, err)
+	_, err = NewVerifier(Config{Issuer: "https://idp.example.com"})
+	assert.Error(t, err)
+}
+
+func TestVerify(t *testing.T) {
+	v, _, key := setupVerifier(t)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	got, err := v.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, "rsa-1", key, claims(nil)))
+	assert.NoError(t, err)
+	assert.Equal(t, Claims{Subject: "alice", Roles: []string{RoleAdmin}}, got)
+	assert.True(t, got.HasRole(RoleReader))
+
+	got, err = v.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, "rsa-1", key, claims(jwt.MapClaims{"roles": "reader billing"})))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{RoleReader, "billing"}, got.Roles)
+	assert.False(t, got.HasRole(RoleAdmin))
+
+	for name, token := range map[string]string{
+		"malformed":      "not.a.token",
+		"wrong issuer":   sign(t, jwt.SigningMethodRS256, "rsa-1", key, claims(jwt.MapClaims{"iss": "https://evil.example.com"})),
+		"wrong audience": sign(t, jwt.SigningMethodRS256, "rsa-1", key, claims(jwt.MapClaims{"aud": "billing"})),
+		"expired":        sign(t, jwt.SigningMethodRS256, "rsa-1", key, claims(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()})),
+		"no expiry":      sign(t, jwt.SigningMethodRS256, "rsa-1", key, claims(jwt.MapClaims{"exp": nil})),
+		"wrong key":      sign(t, jwt.SigningMethodRS256, "rsa-1", other, claims(nil)),
+		"unknown key":    sign(t, jwt.SigningMethodRS256, "rsa-2", other, claims(nil)),
+		"hmac":           sign(t, jwt.SigningMethodHS256, "rsa-1", []byte("secret"), claims(nil)),
+	} {
+		_, err := v.Verify(context.Background(), token)
+		assert.ErrorIs(t, err, ErrInvalidToken, name)
+	}
+}
+
+func TestVerifyKeyRotation(t *testing.T) {
+	v, jwks, _ := setupVerifier(t)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	token := sign(t, jwt.SigningMethodES256, "ec-1", ecKey, claims(nil))
+
+	// Unknown keys are refetched at most every minRefetch
+	_, err = v.Verify(context.Background(), token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	jwks.addEC("ec-1", &ecKey.PublicKey)
+	_, err = v.Verify(context.Background(), token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.Equal(t, 1, jwks.fetches)
+
+	v.attempted = v.attempted.Add(-minRefetch)
+	got, err := v.Verify(context.Background(), token)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", got.Subject)
+	assert.Equal(t, 2, jwks.fetches)
+}
+
+func TestVerifyJWKSUnavailable(t *testing.T) {
+	v, jwks, key := setupVerifier(t)
+	jwks.down = true
+	token := sign(t, jwt.SigningMethodRS256, "rsa-1", key, claims(nil))
+
+	_, err := v.Verify(context.Background(), token)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrInvalidToken)
+
+	// Keys already loaded outlive a failed refresh
+	jwks.down = false
+	v.attempted = v.attempted.Add(-minRefetch)
+	_, err = v.Verify(context.Background(), token)
+	assert.NoError(t, err)
+	jwks.down = true
+	v.fetched = v.fetched.Add(-DefaultRefreshInterval)
+	v.attempted = v.attempted.Add(-minRefetch)
+	_, err = v.Verify(context.Background(), token)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, jwks.fetches)
+}
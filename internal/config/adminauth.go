@@ -0,0 +1,25 @@
+package config
+
+import "time"
+
+// AdminAuthConfig requires JWT bearer tokens on the /admin routes: the
+// reader role to read them, admin to change anything. It is enabled when
+// JWKSURL is set.
+type AdminAuthConfig struct {
+	// Issuer must match the tokens' iss claim.
+	Issuer string `yaml:"issuer"`
+	// Audience, when set, must be among the tokens' aud claim.
+	Audience string `yaml:"audience"`
+	// JWKSURL serves the identity provider's public signing keys.
+	JWKSURL string `yaml:"jwks_url"`
+	// RolesClaim names the claim listing the roles; empty means "roles".
+	RolesClaim string `yaml:"roles_claim"`
+	// RefreshInterval is how often the keys are reloaded; zero means hourly.
+	// Tokens signed by an unknown key reload them sooner.
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+}
+
+// Enabled reports whether admin routes require a token.
+func (c AdminAuthConfig) Enabled() bool {
+	return c.JWKSURL != ""
+}
@@ -0,0 +1,509 @@
+// Package config loads the service configuration from YAML. Loading is
+// strict: unknown fields are rejected, omitted sections get their defaults
+// and every problem found is reported at once.
+package config
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/api"
+	"github.com/n-th/gymshark/internal/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the service configuration, config/config.yaml.
+type Config struct {
+	PackSizes       []int                  `yaml:"pack_sizes"`
+	WeightPackSizes []float64              `yaml:"weight_pack_sizes"`
+	Strategy        string                 `yaml:"strategy"`
+	Profiles        map[string][]int       `yaml:"profiles"`
+	SKUProfiles     map[string]string      `yaml:"sku_profiles"`
+	PackLimits      map[string]map[int]int `yaml:"pack_limits"`
+	// DeprecatedSizes lists the sizes of each profile being phased out.
+	DeprecatedSizes map[string][]int    `yaml:"deprecated_sizes"`
+	PackSizeRules   PackSizeRulesConfig `yaml:"pack_size_rules"`
+	// ProfileGuardrail checks the waste profile updates cause.
+	ProfileGuardrail ProfileGuardrailConfig `yaml:"profile_guardrail"`
+	// AllocationRules attach notes and flags to allocations, such as ones
+	// wasting too much.
+	AllocationRules AllocationRulesConfig `yaml:"allocation_rules"`
+	// ProfileSync declares profiles in a file synced while running, for
+	// managing them through GitOps.
+	ProfileSync ProfileSyncConfig `yaml:"profile_sync"`
+	// Units are what requests may give quantities in besides items.
+	Units map[string]UnitConfig `yaml:"units"`
+	// PackDimensions and Cartons let ?cartons=true estimate how allocations
+	// ship; see allocator.FitCartons.
+	PackDimensions map[string]map[int]DimensionsConfig `yaml:"pack_dimensions"`
+	Cartons        []CartonConfig                      `yaml:"cartons"`
+	Calculation    CalculationConfig                   `yaml:"calculation"`
+	Retention      RetentionConfig                     `yaml:"retention"`
+	Storage        StorageConfig                       `yaml:"storage"`
+	ReadOnly       ReadOnlyConfig                      `yaml:"read_only"`
+	Usage          UsageConfig                         `yaml:"usage"`
+	Invalidation   InvalidationConfig                  `yaml:"invalidation"`
+	Events         EventsConfig                        `yaml:"events"`
+	Notifications  NotificationsConfig                 `yaml:"notifications"`
+	Metrics        MetricsConfig                       `yaml:"metrics"`
+	Health         HealthConfig                        `yaml:"health"`
+	SelfTest       SelfTestConfig                      `yaml:"self_test"`
+	Server         ServerConfig                        `yaml:"server"`
+	// Worker is read by cmd/worker; the API accepts it unchecked.
+	Worker yaml.Node `yaml:"worker"`
+}
+
+// StorageConfig selects the storage backend and sets up a local fallback for
+// it. Backend "sqlite" (the default) keeps the history in allocations.db,
+// "bolt" in a pure-Go bbolt file, allocations.bolt, for binaries built
+// without cgo. When FallbackPath is set, allocation and audit writes the
+// primary fails are buffered in a SQLite file at that path and replayed
+// every ReplayInterval. WriteMode "dedup" counts repeated identical
+// allocations as hits on one row instead of appending a row for each.
+type StorageConfig struct {
+	Backend        string            `yaml:"backend"`
+	WriteMode      storage.WriteMode `yaml:"write_mode"`
+	FallbackPath   string            `yaml:"fallback_path"`
+	ReplayInterval time.Duration     `yaml:"replay_interval"`
+	Outbox         OutboxConfig      `yaml:"outbox"`
+	Cache          CacheConfig       `yaml:"cache"`
+	Replicas       ReplicaConfig     `yaml:"replicas"`
+	// Instrumentation measures, traces and logs the primary's calls.
+	Instrumentation InstrumentationConfig `yaml:"instrumentation"`
+}
+
+// InstrumentationConfig wraps the primary storage in a
+// storage.InstrumentedStorage when Enabled. Its calls are then measured by
+// method when metrics are enabled, traced through the global OpenTelemetry
+// tracer provider when Tracing is set, and logged when they take at least
+// SlowThreshold (0 = never).
+type InstrumentationConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	SlowThreshold time.Duration `yaml:"slow_threshold"`
+	Tracing       bool          `yaml:"tracing"`
+}
+
+// ReplicaConfig serves result cache lookups from read-only replicas of the
+// SQLite database, file paths or "file:" URIs listed in DSNs, while writes go
+// to the primary. Replicas lagging the primary by more than MaxStaleness,
+// as checked every CheckInterval, are not read. No DSNs disables it.
+type ReplicaConfig struct {
+	DSNs          []string      `yaml:"dsns"`
+	MaxStaleness  time.Duration `yaml:"max_staleness"`
+	CheckInterval time.Duration `yaml:"check_interval"`
+}
+
+// DefaultReplicaCheckInterval is how often replica lag is checked when
+// storage.replicas.check_interval is omitted.
+const DefaultReplicaCheckInterval = time.Second
+
+// CacheConfig serves the result cache from Redis instead of the allocation
+// history, keeping database reads off the hot path. Entries are stored under
+// Prefix and expire after TTL (0 = never). An empty RedisURL reads cached
+// allocations from the history. Calls to Redis go through Breaker.
+type CacheConfig struct {
+	RedisURL string        `yaml:"redis_url"`
+	Prefix   string        `yaml:"prefix"`
+	TTL      time.Duration `yaml:"ttl"`
+	Breaker  BreakerConfig `yaml:"breaker"`
+}
+
+// BreakerConfig retries failed calls to an external backend up to Retries
+// times, after Backoff doubling with each retry, and stops calling it for
+// Cooldown after FailureThreshold failed calls in a row (0 = never). An
+// omitted block uses storage.DefaultBreakerPolicy.
+type BreakerConfig struct {
+	Retries          int           `yaml:"retries"`
+	Backoff          time.Duration `yaml:"backoff"`
+	FailureThreshold int           `yaml:"failure_threshold"`
+	Cooldown         time.Duration `yaml:"cooldown"`
+}
+
+// Policy converts the breaker settings, defaulting an omitted block.
+func (c BreakerConfig) Policy() storage.BreakerPolicy {
+	if c == (BreakerConfig{}) {
+		return storage.DefaultBreakerPolicy
+	}
+	return storage.BreakerPolicy{Retries: c.Retries, Backoff: c.Backoff, Threshold: c.FailureThreshold, Cooldown: c.Cooldown}
+}
+
+// OutboxConfig queues allocation writes the storage fails and retries them
+// every RetryInterval, up to MaxAttempts times (0 = until they succeed).
+// Zero Capacity disables the queue; an omitted block uses the defaults.
+type OutboxConfig struct {
+	Capacity      int           `yaml:"capacity"`
+	MaxAttempts   int           `yaml:"max_attempts"`
+	RetryInterval time.Duration `yaml:"retry_interval"`
+}
+
+var DefaultOutboxConfig = OutboxConfig{
+	Capacity:      allocator.DefaultOutboxCapacity,
+	MaxAttempts:   allocator.DefaultOutboxMaxAttempts,
+	RetryInterval: 5 * time.Second,
+}
+
+// EventsConfig publishes allocation.completed and profile.changed events as
+// JSON to a Kafka topic, through a Kafka REST Proxy, or to a NATS subject.
+// With neither configured nothing is published.
+type EventsConfig struct {
+	// Buffer is how many events are queued for the broker before new ones
+	// are dropped.
+	Buffer int             `yaml:"buffer"`
+	Kafka  KafkaConfig     `yaml:"kafka"`
+	NATS   NATSEventConfig `yaml:"nats"`
+}
+
+type KafkaConfig struct {
+	RESTProxyURL string `yaml:"rest_proxy_url"`
+	Topic        string `yaml:"topic"`
+}
+
+type NATSEventConfig struct {
+	URL     string `yaml:"url"`
+	Subject string `yaml:"subject"`
+}
+
+// MetricsConfig exposes Prometheus metrics on GET /metrics.
+type MetricsConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// HealthConfig sets up the readiness probes on GET /health/ready: how long
+// a database query takes and how full the data volume is. A measurement at
+// or above Warning is reported; at or above Critical the service is not
+// ready. Zero thresholds are never reached, and a probe with both zero is
+// disabled. A probe still running after Timeout is critical.
+type HealthConfig struct {
+	Timeout  time.Duration      `yaml:"timeout"`
+	Database LatencyProbeConfig `yaml:"database"`
+	Disk     DiskProbeConfig    `yaml:"disk"`
+}
+
+type LatencyProbeConfig struct {
+	Warning  time.Duration `yaml:"warning"`
+	Critical time.Duration `yaml:"critical"`
+}
+
+// Enabled reports whether either threshold is set.
+func (c LatencyProbeConfig) Enabled() bool {
+	return c.Warning > 0 || c.Critical > 0
+}
+
+// DiskProbeConfig rates the percentage of the data volume in use.
+type DiskProbeConfig struct {
+	WarningPercent  float64 `yaml:"warning_percent"`
+	CriticalPercent float64 `yaml:"critical_percent"`
+}
+
+// Enabled reports whether either threshold is set.
+func (c DiskProbeConfig) Enabled() bool {
+	return c.WarningPercent > 0 || c.CriticalPercent > 0
+}
+
+var DefaultHealthConfig = HealthConfig{
+	Timeout:  2 * time.Second,
+	Database: LatencyProbeConfig{Warning: 100 * time.Millisecond, Critical: time.Second},
+	Disk:     DiskProbeConfig{WarningPercent: 80, CriticalPercent: 95},
+}
+
+// Validate checks that thresholds are non-negative, percentages at most 100
+// and warnings below critical thresholds.
+func (c HealthConfig) Validate() error {
+	db, disk := c.Database, c.Disk
+	if c.Timeout < 0 || db.Warning < 0 || db.Critical < 0 || disk.WarningPercent < 0 || disk.CriticalPercent < 0 {
+		return errors.New("health settings must not be negative")
+	}
+	if disk.WarningPercent > 100 || disk.CriticalPercent > 100 {
+		return errors.New("health disk thresholds are percentages and must not exceed 100")
+	}
+	if db.Critical > 0 && db.Warning > db.Critical || disk.CriticalPercent > 0 && disk.WarningPercent > disk.CriticalPercent {
+		return errors.New("health warning thresholds must not exceed critical thresholds")
+	}
+	return nil
+}
+
+// InvalidationConfig connects replicas over Redis pub/sub so cache purges and
+// profile updates made on one instance reach all of them. An empty RedisURL
+// disables it.
+type InvalidationConfig struct {
+	RedisURL string `yaml:"redis_url"`
+	Channel  string `yaml:"channel"`
+}
+
+// ReadOnlyConfig starts the service in read-only (maintenance) mode.
+// Mode "skip" serves calculations without storing them; "reject" answers
+// them with 503. It can be toggled at runtime via /admin/read-only.
+type ReadOnlyConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Mode    string `yaml:"mode"`
+}
+
+// State converts the configured mode.
+func (c ReadOnlyConfig) State() allocator.ReadOnly {
+	return allocator.ReadOnly{Enabled: c.Enabled, Mode: allocator.ReadOnlyMode(c.Mode)}
+}
+
+// RetentionConfig bounds the stored allocation history. A background job
+// prunes it every Interval; POST /admin/prune runs the same policy on demand.
+// Zero MaxAge and MaxRows keep everything.
+type RetentionConfig struct {
+	MaxAge   time.Duration `yaml:"max_age"`
+	MaxRows  int           `yaml:"max_rows"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+// DimensionsConfig is the size of a pack in millimetres and its weight in
+// grams.
+type DimensionsConfig struct {
+	Length int `yaml:"length"`
+	Width  int `yaml:"width"`
+	Height int `yaml:"height"`
+	Weight int `yaml:"weight"`
+}
+
+// CartonConfig is a carton or pallet: its inner size in millimetres and the
+// weight in grams it may hold, 0 for no limit.
+type CartonConfig struct {
+	Name      string `yaml:"name"`
+	Length    int    `yaml:"length"`
+	Width     int    `yaml:"width"`
+	Height    int    `yaml:"height"`
+	MaxWeight int    `yaml:"max_weight"`
+}
+
+// CalculationConfig bounds how long a single calculation may run.
+// Past SoftTimeout the greedy fallback is returned; past HardTimeout the
+// calculation is cancelled. Zero disables the respective deadline.
+type CalculationConfig struct {
+	SoftTimeout time.Duration `yaml:"soft_timeout"`
+	HardTimeout time.Duration `yaml:"hard_timeout"`
+	// NegativeCacheTTL is how long unfulfillable requests are remembered.
+	NegativeCacheTTL time.Duration `yaml:"negative_cache_ttl"`
+	// MaxQuantity and MaxBatchSize reject oversized requests with 422.
+	// Zero means unlimited.
+	MaxQuantity  int `yaml:"max_quantity"`
+	MaxBatchSize int `yaml:"max_batch_size"`
+	// ResultCache serves requests from allocations already stored for the
+	// same quantity and pack sizes, e.g. ones pre-computed by cmd/worker.
+	ResultCache bool `yaml:"result_cache"`
+	// Admission sheds calculations with 503 when too many are running.
+	Admission AdmissionConfig `yaml:"admission"`
+	// Warm fills the result cache with the most requested quantities on
+	// start.
+	Warm WarmConfig `yaml:"warm"`
+	// Cost chooses each calculation's strategy and soft deadline from its
+	// estimated cost.
+	Cost CostConfig `yaml:"cost"`
+	// Shadow runs a second strategy alongside the configured one to compare
+	// their results.
+	Shadow ShadowConfig `yaml:"shadow"`
+}
+
+// ShadowConfig configures allocator.Shadow: Strategy runs in shadow for a
+// fraction Rate, from 0 to 1, of the calculations, each for at most Timeout,
+// or the hard timeout when zero. A zero Rate disables it.
+type ShadowConfig struct {
+	Strategy string        `yaml:"strategy"`
+	Rate     float64       `yaml:"rate"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+// Shadow returns the shadow configuration of the allocator.
+func (c ShadowConfig) Shadow() allocator.Shadow {
+	return allocator.Shadow{Strategy: c.Strategy, Rate: c.Rate, Timeout: c.Timeout}
+}
+
+// CostConfig configures allocator.CostModel: calculations are expected to get
+// through OpsPerSecond operations per second and fall back to greedy past
+// Slack times their estimate, but not before MinDeadline. Settings left zero
+// take the allocator.DefaultCostModel values.
+type CostConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	OpsPerSecond float64       `yaml:"ops_per_second"`
+	Slack        float64       `yaml:"slack"`
+	MinDeadline  time.Duration `yaml:"min_deadline"`
+}
+
+// Model returns the cost model, with a zero Rate when disabled.
+func (c CostConfig) Model() allocator.CostModel {
+	if !c.Enabled {
+		return allocator.CostModel{}
+	}
+	return allocator.CostModel{Rate: c.OpsPerSecond, Slack: c.Slack, MinDeadline: c.MinDeadline}
+}
+
+// WarmConfig warms the result cache in the background on start with the Top
+// quantities requested most often over the last Lookback (0 = the whole
+// history), so the first requests after a deploy that changed pack sizes are
+// not all computed. Zero Top disables it, as does a disabled result cache.
+type WarmConfig struct {
+	Top      int           `yaml:"top"`
+	Lookback time.Duration `yaml:"lookback"`
+}
+
+// AdmissionConfig lets MaxInFlight calculations run at once and MaxQueue more
+// wait up to MaxWait for one to finish; the rest are rejected with 503 and a
+// Retry-After of RetryAfter. Zero MaxInFlight disables admission control.
+type AdmissionConfig struct {
+	MaxInFlight int           `yaml:"max_in_flight"`
+	MaxQueue    int           `yaml:"max_queue"`
+	MaxWait     time.Duration `yaml:"max_wait"`
+	RetryAfter  time.Duration `yaml:"retry_after"`
+}
+
+type ServerConfig struct {
+	Port int    `yaml:"port"`
+	Host string `yaml:"host"`
+	// Listen lists further host:port addresses served alongside Host and
+	// Port, and Socket the path of a UNIX domain socket served as well,
+	// created with SocketMode permissions when set. With either configured,
+	// Port 0 serves only those.
+	Listen     []string    `yaml:"listen"`
+	Socket     string      `yaml:"socket"`
+	SocketMode os.FileMode `yaml:"socket_mode"`
+	TLS        TLSConfig   `yaml:"tls"`
+	// Signing signs calculation responses for downstream verification.
+	Signing SigningConfig `yaml:"signing"`
+	// AdminAuth requires JWT bearer tokens on the /admin routes.
+	AdminAuth AdminAuthConfig `yaml:"admin_auth"`
+	// MaxBodyBytes caps POST request bodies; larger requests get 413.
+	// Zero means unlimited.
+	MaxBodyBytes int64             `yaml:"max_body_bytes"`
+	Compression  CompressionConfig `yaml:"compression"`
+	WebSocket    WebSocketConfig   `yaml:"websocket"`
+	Timeouts     TimeoutConfig     `yaml:"timeouts"`
+	// Mode is the Gin mode: debug, release or test. Empty keeps Gin's
+	// default, which honours GIN_MODE.
+	Mode string `yaml:"mode"`
+	// TrustedProxies lists the IPs and CIDRs whose X-Forwarded-For headers
+	// are believed when working out client IPs, and whose X-Forwarded-User
+	// headers name the caller. Empty trusts none.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	// BasePath mounts every route, including the documentation, under a
+	// prefix such as "/pack-api".
+	BasePath string `yaml:"base_path"`
+	// LegacyRoutes controls the unversioned aliases of the /v1 routes.
+	LegacyRoutes LegacyRoutesConfig `yaml:"legacy_routes"`
+	// CacheMaxAge is how long caches may reuse GET /calculate responses
+	// without revalidating their ETag. Zero makes them revalidate every time.
+	CacheMaxAge time.Duration `yaml:"cache_max_age"`
+	Recent      RecentConfig  `yaml:"recent"`
+	Debug       DebugConfig   `yaml:"debug"`
+	// PreStop drains the instance before it stops; see api.PreStop.
+	PreStop PreStopConfig `yaml:"prestop"`
+	// Security sets HSTS and the admin UI's CSRF protection; see
+	// api.Security.
+	Security SecurityConfig `yaml:"security"`
+}
+
+// SecurityConfig configures the security headers and CSRF protection.
+type SecurityConfig struct {
+	// HSTSMaxAge sends Strict-Transport-Security when positive. Only set it
+	// when the API is served over HTTPS.
+	HSTSMaxAge            time.Duration `yaml:"hsts_max_age"`
+	HSTSIncludeSubdomains bool          `yaml:"hsts_include_subdomains"`
+	// CSRF requires the admin UI's token on mutating admin requests sent
+	// without an Authorization header.
+	CSRF bool `yaml:"csrf"`
+}
+
+// Security converts the security settings.
+func (c SecurityConfig) Security() api.Security {
+	return api.Security{
+		HSTSMaxAge:            c.HSTSMaxAge,
+		HSTSIncludeSubdomains: c.HSTSIncludeSubdomains,
+		CSRF:                  c.CSRF,
+	}
+}
+
+// PreStopConfig times what POST /admin/prestop and SIGTERM do before the
+// instance stops: report it not ready for DrainDelay, then wait up to
+// DrainTimeout for the requests in flight.
+type PreStopConfig struct {
+	DrainDelay   time.Duration `yaml:"drain_delay"`
+	DrainTimeout time.Duration `yaml:"drain_timeout"`
+}
+
+// DefaultPreStopConfig is used when the prestop section is left out. Both
+// waits together, plus the shutdown, fit Kubernetes' default grace period of
+// 30 seconds.
+var DefaultPreStopConfig = PreStopConfig{
+	DrainDelay:   5 * time.Second,
+	DrainTimeout: 15 * time.Second,
+}
+
+// DebugConfig serves /debug/pprof and /debug/vars, to local clients only
+// unless AllowRemote is set, and to admins only when admin auth is
+// configured.
+type DebugConfig struct {
+	Enabled     bool `yaml:"enabled"`
+	AllowRemote bool `yaml:"allow_remote"`
+}
+
+// RecentConfig bounds the allocations GET /recent returns.
+type RecentConfig struct {
+	// DefaultLimit applies when a request has no limit; requests for more
+	// than MaxLimit are rejected. Zero keeps api.DefaultRecentLimits.
+	DefaultLimit int `yaml:"default_limit"`
+	MaxLimit     int `yaml:"max_limit"`
+}
+
+// Limits returns the configured limits, keeping the defaults of those
+// left zero.
+func (c RecentConfig) Limits() api.RecentLimits {
+	l := api.DefaultRecentLimits()
+	if c.DefaultLimit > 0 {
+		l.Default = c.DefaultLimit
+	}
+	if c.MaxLimit > 0 {
+		l.Max = c.MaxLimit
+	}
+	return l
+}
+
+// LegacyRoutesConfig controls the deprecated unversioned paths, such as
+// /calculate, kept as aliases of the /v1 routes.
+type LegacyRoutesConfig struct {
+	// Disabled stops serving the unversioned paths.
+	Disabled bool `yaml:"disabled"`
+	// Deprecated and Sunset, when set, are announced in the Deprecation and
+	// Sunset response headers of the unversioned paths.
+	Deprecated time.Time `yaml:"deprecated"`
+	Sunset     time.Time `yaml:"sunset"`
+}
+
+// LegacyRoutes converts the legacy route settings.
+func (c LegacyRoutesConfig) LegacyRoutes() api.LegacyRoutes {
+	return api.LegacyRoutes{Disabled: c.Disabled, Deprecated: c.Deprecated, Sunset: c.Sunset}
+}
+
+// TimeoutConfig bounds how long a request may take before it is cancelled
+// with 504. Routes are keyed by route pattern, optionally prefixed with a
+// method ("/recent", "POST /calculate"); zero disables the deadline.
+type TimeoutConfig struct {
+	Default time.Duration            `yaml:"default"`
+	Routes  map[string]time.Duration `yaml:"routes"`
+}
+
+// Timeouts converts the request timeouts.
+func (c TimeoutConfig) Timeouts() api.Timeouts {
+	return api.Timeouts{Default: c.Default, Routes: c.Routes}
+}
+
+// WebSocketConfig tunes the /ws/calculate live calculator.
+type WebSocketConfig struct {
+	// Debounce is how long a client must stop typing before a calculation runs.
+	Debounce time.Duration `yaml:"debounce"`
+	// RateLimit and Burst bound the messages accepted per connection per second.
+	RateLimit float64 `yaml:"rate_limit"`
+	Burst     int     `yaml:"burst"`
+}
+
+// CompressionConfig controls brotli/gzip/deflate response compression.
+type CompressionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MinSize is the smallest response body, in bytes, that is compressed.
+	MinSize int `yaml:"min_size"`
+}
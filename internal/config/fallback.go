@@ -0,0 +1,56 @@
+package config
+
+import (
+	"time"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/api"
+)
+
+// Fallback returns the config the API runs with in development when it
+// cannot load one and -strict-config is off. Its pack sizes, 1, 2 and 3,
+// are placeholders: allocations made with them are wrong for any real
+// catalogue.
+func Fallback() *Config {
+	return &Config{
+		PackSizes: []int{1, 2, 3},
+		Strategy:  allocator.DefaultStrategy,
+		Calculation: CalculationConfig{
+			SoftTimeout:      5 * time.Second,
+			HardTimeout:      time.Minute,
+			NegativeCacheTTL: 10 * time.Minute,
+			MaxQuantity:      10_000_000,
+			MaxBatchSize:     1000,
+		},
+		Retention: RetentionConfig{Interval: time.Hour},
+		Storage:   StorageConfig{Outbox: DefaultOutboxConfig},
+		Metrics:   MetricsConfig{Enabled: true},
+		Health:    DefaultHealthConfig,
+		Server: ServerConfig{
+			Port:         8080,
+			Host:         "0.0.0.0",
+			MaxBodyBytes: 1 << 20,
+			Compression:  CompressionConfig{Enabled: true, MinSize: api.DefaultCompressionMinSize},
+			WebSocket: WebSocketConfig{
+				Debounce:  150 * time.Millisecond,
+				RateLimit: 10,
+				Burst:     20,
+			},
+			Timeouts: TimeoutConfig{
+				Default: 30 * time.Second,
+				Routes: map[string]time.Duration{
+					"/recent":             5 * time.Second,
+					"/calculate":          time.Minute,
+					"/calculate/order":    time.Minute,
+					"/calculate/compare":  time.Minute,
+					"/simulate":           time.Minute,
+					"/suggest":            time.Minute,
+					"/ws/calculate":       0,
+					"/allocations/export": 0,
+					"/admin/precompute":   10 * time.Minute,
+					"/admin/backup":       0,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,322 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/api"
+	"github.com/n-th/gymshark/internal/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPort is the port served when server.port is omitted.
+const DefaultPort = 8080
+
+// Errors is every problem found in a config, in the order they were found.
+type Errors []error
+
+func (e Errors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d problems:", len(e))
+	for _, err := range e {
+		b.WriteString("\n  - ")
+		b.WriteString(err.Error())
+	}
+	return b.String()
+}
+
+// Unwrap makes errors.Is and errors.As look at every problem.
+func (e Errors) Unwrap() []error {
+	return e
+}
+
+// Load reads the config at path. Fields the config does not know are
+// rejected, so typos do not silently fall back to defaults. Omitted sections
+// get their defaults, and the result is validated; the returned Errors lists
+// every problem at once.
+func Load(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Decode(f)
+}
+
+// Decode reads a config from r as Load does.
+func Decode(r io.Reader) (*Config, error) {
+	var cfg Config
+	var errs Errors
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && err != io.EOF {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return nil, err
+		}
+		// The rest of the document was decoded; report these along with
+		// what validation finds.
+		for _, msg := range typeErr.Errors {
+			errs = append(errs, errors.New(msg))
+		}
+	}
+
+	cfg.ApplyDefaults()
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err.(Errors)...)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return &cfg, nil
+}
+
+// ApplyDefaults fills in the settings and sections left out of c.
+func (c *Config) ApplyDefaults() {
+	if c.Strategy == "" {
+		c.Strategy = allocator.DefaultStrategy
+	}
+	if c.Server.Port == 0 && c.Server.Socket == "" && len(c.Server.Listen) == 0 {
+		c.Server.Port = DefaultPort
+	}
+	if c.Storage.FallbackPath != "" && c.Storage.ReplayInterval == 0 {
+		c.Storage.ReplayInterval = 30 * time.Second
+	}
+	if len(c.Storage.Replicas.DSNs) > 0 && c.Storage.Replicas.CheckInterval == 0 {
+		c.Storage.Replicas.CheckInterval = DefaultReplicaCheckInterval
+	}
+	if c.Notifications.Enabled() && c.Notifications.Cooldown == 0 {
+		c.Notifications.Cooldown = DefaultNotificationCooldown
+	}
+	if c.ProfileSync.Path != "" && c.ProfileSync.Interval == 0 {
+		c.ProfileSync.Interval = DefaultProfileSyncInterval
+	}
+	if cost := &c.Calculation.Cost; cost.Enabled {
+		if cost.OpsPerSecond == 0 {
+			cost.OpsPerSecond = allocator.DefaultCostModel.Rate
+		}
+		if cost.Slack == 0 {
+			cost.Slack = allocator.DefaultCostModel.Slack
+		}
+		if cost.MinDeadline == 0 {
+			cost.MinDeadline = allocator.DefaultCostModel.MinDeadline
+		}
+	}
+	if c.Storage.Outbox == (OutboxConfig{}) {
+		c.Storage.Outbox = DefaultOutboxConfig
+	}
+	if c.Health == (HealthConfig{}) {
+		c.Health = DefaultHealthConfig
+	}
+	if c.Server.PreStop == (PreStopConfig{}) {
+		c.Server.PreStop = DefaultPreStopConfig
+	}
+	if basePath, err := api.NormalizeBasePath(c.Server.BasePath); err == nil {
+		c.Server.BasePath = basePath
+	}
+}
+
+// Validate checks every setting of c, and settings against each other, and
+// returns Errors listing all problems found, or nil.
+func (c *Config) Validate() error {
+	var errs Errors
+	check := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	// Pack sizes
+	if len(c.PackSizes) == 0 {
+		fail("no pack sizes configured")
+	}
+	rejectDuplicates := c.PackSizeRules.Duplicate == "" || c.PackSizeRules.Duplicate == string(allocator.SeverityError)
+	checkSizes := func(where string, sizes []int) {
+		seen := make(map[int]bool, len(sizes))
+		for i, size := range sizes {
+			switch {
+			case size <= 0:
+				fail("invalid pack size in %s at index %d: %d (must be positive)", where, i, size)
+			case seen[size] && rejectDuplicates:
+				fail("duplicate pack size in %s at index %d: %d (allow it with pack_size_rules.duplicate: warning)", where, i, size)
+			}
+			seen[size] = true
+		}
+	}
+	checkSizes("pack_sizes", c.PackSizes)
+	for name, sizes := range c.Profiles {
+		checkSizes(fmt.Sprintf("profile %q", name), sizes)
+	}
+	for sku, profile := range c.SKUProfiles {
+		if _, ok := c.Profiles[profile]; !ok && profile != allocator.DefaultProfile {
+			fail("sku_profiles: sku %q uses unknown profile %q", sku, profile)
+		}
+	}
+	for i, kg := range c.WeightPackSizes {
+		if w, err := allocator.WeightFromKilograms(kg); err != nil || w <= 0 {
+			fail("invalid weight pack size at index %d: %v (must be positive kilograms with at most 3 decimals)", i, kg)
+		}
+	}
+	check(c.PackSizeRules.Rules().Validate())
+	check(c.ProfileGuardrail.Guardrail().Validate())
+	check(allocator.ValidateAllocationRules(c.AllocationRules.Rules()))
+	check(c.ProfileSync.Validate())
+	_, err := QuantityUnits(c.Units)
+	check(err)
+	if err := c.Usage.Validate(); err != nil {
+		fail("invalid usage config: %w", err)
+	}
+	if c.Strategy != "" {
+		_, err = allocator.LookupStrategy(c.Strategy)
+		check(err)
+	}
+
+	// Calculation
+	calc := c.Calculation
+	if calc.SoftTimeout < 0 || calc.HardTimeout < 0 || calc.NegativeCacheTTL < 0 {
+		fail("calculation durations must not be negative")
+	}
+	if calc.SoftTimeout > 0 && calc.HardTimeout > 0 && calc.SoftTimeout > calc.HardTimeout {
+		fail("calculation soft_timeout %v exceeds hard_timeout %v", calc.SoftTimeout, calc.HardTimeout)
+	}
+	if calc.MaxQuantity < 0 || calc.MaxBatchSize < 0 {
+		fail("calculation limits must not be negative")
+	}
+	if ad := calc.Admission; ad.MaxInFlight < 0 || ad.MaxQueue < 0 || ad.MaxWait < 0 || ad.RetryAfter < 0 {
+		fail("calculation admission settings must not be negative")
+	}
+	if w := calc.Warm; w.Top < 0 || w.Lookback < 0 {
+		fail("calculation warm settings must not be negative")
+	}
+	if cost := calc.Cost; cost.OpsPerSecond < 0 || cost.MinDeadline < 0 {
+		fail("calculation cost settings must not be negative")
+	}
+	if cost := calc.Cost; cost.Enabled && cost.Slack < 1 {
+		fail("calculation cost slack %v must be at least 1", cost.Slack)
+	}
+	if sh := calc.Shadow; sh.Rate < 0 || sh.Rate > 1 || sh.Timeout < 0 {
+		fail("calculation shadow rate must be between 0 and 1 and timeout must not be negative")
+	} else if sh.Rate > 0 {
+		if _, err := allocator.LookupStrategy(sh.Strategy); err != nil {
+			fail("calculation shadow strategy %q: %w", sh.Strategy, err)
+		}
+	}
+
+	// Server
+	s := c.Server
+	if s.Port < 0 || s.Port > 65535 || s.Port == 0 && s.Socket == "" && len(s.Listen) == 0 {
+		fail("server port %d is out of range (1-65535)", s.Port)
+	}
+	for _, addr := range s.Listen {
+		if _, port, err := net.SplitHostPort(addr); err != nil {
+			fail("invalid server listen address %q: %v", addr, err)
+		} else if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			fail("invalid server listen address %q: port out of range (1-65535)", addr)
+		}
+	}
+	if s.SocketMode&^os.ModePerm != 0 {
+		fail("invalid server socket_mode %#o: want permission bits such as 0660", s.SocketMode)
+	}
+	if p := s.TLS.RedirectPort; p < 0 || p > 65535 {
+		fail("server tls redirect_port %d is out of range (1-65535)", p)
+	} else if p > 0 && p == s.Port {
+		fail("server tls redirect_port must differ from port %d", s.Port)
+	} else if p > 0 && s.Port == 0 {
+		fail("server tls redirect_port needs a port to redirect to")
+	}
+	if s.MaxBodyBytes < 0 || s.Compression.MinSize < 0 {
+		fail("server size limits must not be negative")
+	}
+	if s.CacheMaxAge < 0 {
+		fail("server cache max age must not be negative")
+	}
+	if r := s.Recent; r.DefaultLimit < 0 || r.MaxLimit < 0 {
+		fail("server recent limits must not be negative")
+	} else if l := r.Limits(); l.Default > l.Max {
+		fail("server recent default_limit %d exceeds max_limit %d", l.Default, l.Max)
+	}
+	if ws := s.WebSocket; ws.Debounce < 0 || ws.RateLimit < 0 || ws.Burst < 0 {
+		fail("websocket settings must not be negative")
+	}
+	if p := s.PreStop; p.DrainDelay < 0 || p.DrainTimeout < 0 {
+		fail("server prestop durations must not be negative")
+	}
+	if s.Security.HSTSMaxAge < 0 {
+		fail("server security hsts_max_age must not be negative")
+	}
+	if err := s.Timeouts.Timeouts().Validate(); err != nil {
+		fail("invalid server timeouts: %w", err)
+	}
+	switch s.Mode {
+	case "", gin.DebugMode, gin.ReleaseMode, gin.TestMode:
+	default:
+		fail("invalid server mode %q: want debug, release or test", s.Mode)
+	}
+	for _, proxy := range s.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			fail("invalid trusted proxy %q: want an IP or CIDR", proxy)
+		}
+	}
+	_, err = api.NormalizeBasePath(s.BasePath)
+	check(err)
+
+	// Storage and the rest
+	if c.Retention.MaxAge < 0 || c.Retention.MaxRows < 0 || c.Retention.Interval < 0 {
+		fail("retention settings must not be negative")
+	}
+	if c.Storage.ReplayInterval < 0 || c.Storage.Cache.TTL < 0 || c.Storage.Instrumentation.SlowThreshold < 0 {
+		fail("storage durations must not be negative")
+	}
+	if err := c.Storage.Cache.Breaker.Policy().Validate(); err != nil {
+		fail("storage cache: %w", err)
+	}
+	if m := c.Storage.WriteMode; m != "" && m != storage.WriteAppend && m != storage.WriteDedup {
+		fail("unknown storage write_mode %q (want append or dedup)", m)
+	}
+	check(storage.CheckBackend(c.Storage.Backend))
+	if r := c.Storage.Replicas; len(r.DSNs) > 0 {
+		if r.MaxStaleness < 0 || r.CheckInterval < 0 {
+			fail("storage replicas durations must not be negative")
+		}
+		if c.Storage.Backend == storage.BackendBolt {
+			fail("storage replicas need the %s backend", storage.BackendSQLite)
+		}
+		if c.Storage.Cache.RedisURL != "" {
+			fail("storage replicas and cache redis_url both serve the result cache; configure one")
+		}
+	}
+	if o := c.Storage.Outbox; o.Capacity < 0 || o.MaxAttempts < 0 || o.RetryInterval < 0 {
+		fail("storage outbox settings must not be negative")
+	} else if o.Capacity > 0 && o.RetryInterval == 0 {
+		fail("storage outbox retry_interval must be positive")
+	}
+	check(c.Health.Validate())
+	check(c.SelfTest.Validate())
+	check(c.ReadOnly.State().Validate())
+	if c.Events.Buffer < 0 {
+		fail("events buffer must not be negative")
+	}
+	if c.Events.Kafka.RESTProxyURL != "" && c.Events.NATS.URL != "" {
+		fail("events: configure either kafka or nats, not both")
+	}
+	check(c.Notifications.Validate())
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadShippedConfig(t *testing.T) {
+	cfg, err := Load("../../config/config.yaml")
+	assert.NoError(t, err)
+	if assert.NotNil(t, cfg) {
+		assert.NotEmpty(t, cfg.PackSizes)
+	}
+}
+
+func TestDecodeDefaults(t *testing.T) {
+	cfg, err := Decode(strings.NewReader("pack_sizes: [250, 500]\nstorage:\n  fallback_path: fallback.db\n"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, cfg) {
+		assert.Equal(t, allocator.DefaultStrategy, cfg.Strategy)
+		assert.Equal(t, DefaultPort, cfg.Server.Port)
+		assert.Equal(t, 30*time.Second, cfg.Storage.ReplayInterval)
+		assert.Equal(t, DefaultOutboxConfig, cfg.Storage.Outbox)
+		assert.Equal(t, DefaultHealthConfig, cfg.Health)
+	}
+}
+
+func TestDecodeUnknownField(t *testing.T) {
+	_, err := Decode(strings.NewReader("pack_sizes: [250]\npack_size: [500]\n"))
+	assert.ErrorContains(t, err, "field pack_size not found")
+}
+
+func TestDecodeEmpty(t *testing.T) {
+	_, err := Decode(strings.NewReader(""))
+	assert.EqualError(t, err, "no pack sizes configured")
+}
+
+func TestDecodeReportsEveryProblem(t *testing.T) {
+	_, err := Decode(strings.NewReader(`
+pack_sizes: [23, 31, 31]
+profiles:
+  apparel: [250, -1]
+sku_profiles:
+  TSHIRT: shoes
+calculation:
+  soft_timeout: 2m
+  hard_timeout: 1m
+server:
+  port: 70000
+`))
+	var errs Errors
+	if assert.True(t, errors.As(err, &errs)) {
+		assert.Len(t, errs, 5)
+	}
+	for _, want := range []string{
+		"duplicate pack size in pack_sizes at index 2: 31",
+		`invalid pack size in profile "apparel" at index 1: -1`,
+		`sku "TSHIRT" uses unknown profile "shoes"`,
+		"soft_timeout 2m0s exceeds hard_timeout 1m0s",
+		"server port 70000 is out of range",
+	} {
+		assert.ErrorContains(t, err, want)
+	}
+	assert.True(t, strings.HasPrefix(err.Error(), "5 problems:"))
+
+	// Duplicates are allowed when the rules only warn about them.
+	_, err = Decode(strings.NewReader("pack_sizes: [23, 31, 31]\npack_size_rules:\n  duplicate: warning\n"))
+	assert.NoError(t, err)
+}
+
+func TestDecodeTypeErrors(t *testing.T) {
+	// Type errors are reported with the problems validation finds.
+	_, err := Decode(strings.NewReader("pack_sizes: [23, big]\nserver:\n  port: -1\n"))
+	var errs Errors
+	if assert.True(t, errors.As(err, &errs)) {
+		assert.Len(t, errs, 2)
+	}
+	assert.ErrorContains(t, err, "cannot unmarshal")
+	assert.ErrorContains(t, err, "server port -1 is out of range")
+}
+
+func TestFallback(t *testing.T) {
+	assert.NoError(t, Fallback().Validate())
+}
+
+func TestDecodeListeners(t *testing.T) {
+	// With only a socket, nothing is served on TCP.
+	cfg, err := Decode(strings.NewReader("pack_sizes: [250]\nserver:\n  socket: /run/gymshark/api.sock\n  socket_mode: 0660\n"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, cfg) {
+		assert.Zero(t, cfg.Server.Port)
+		assert.Equal(t, os.FileMode(0660), cfg.Server.SocketMode)
+	}
+
+	_, err = Decode(strings.NewReader("pack_sizes: [250]\nserver:\n  listen: [\"127.0.0.1:8081\", \"localhost\", \"[::1]:99999\"]\n  socket_mode: 01777\n"))
+	var errs Errors
+	if assert.True(t, errors.As(err, &errs)) {
+		assert.Len(t, errs, 3)
+	}
+	assert.ErrorContains(t, err, `invalid server listen address "localhost"`)
+}
+
+func TestDecodeCost(t *testing.T) {
+	cfg, err := Decode(strings.NewReader("pack_sizes: [250]\ncalculation:\n  cost:\n    enabled: true\n    slack: 2\n"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, cfg) {
+		want := allocator.DefaultCostModel
+		want.Slack = 2
+		assert.Equal(t, want, cfg.Calculation.Cost.Model())
+	}
+
+	cfg, err = Decode(strings.NewReader("pack_sizes: [250]\ncalculation:\n  cost:\n    slack: 2\n"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, cfg) {
+		assert.Zero(t, cfg.Calculation.Cost.Model())
+	}
+
+	_, err = Decode(strings.NewReader("pack_sizes: [250]\ncalculation:\n  cost:\n    enabled: true\n    slack: 0.5\n"))
+	assert.ErrorContains(t, err, "calculation cost slack 0.5 must be at least 1")
+}
+
+func TestDecodeReplicas(t *testing.T) {
+	cfg, err := Decode(strings.NewReader("pack_sizes: [250]\nstorage:\n  replicas:\n    dsns: [replica.db]\n"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, cfg) {
+		assert.Equal(t, ReplicaConfig{DSNs: []string{"replica.db"}, CheckInterval: DefaultReplicaCheckInterval}, cfg.Storage.Replicas)
+	}
+
+	_, err = Decode(strings.NewReader("pack_sizes: [250]\nstorage:\n  backend: bolt\n  cache:\n    redis_url: redis://localhost\n  replicas:\n    dsns: [replica.db]\n    max_staleness: -1s\n"))
+	var errs Errors
+	if assert.True(t, errors.As(err, &errs)) {
+		assert.Len(t, errs, 3)
+	}
+	assert.ErrorContains(t, err, "storage replicas need the sqlite backend")
+}
+
+func TestDecodeInstrumentation(t *testing.T) {
+	cfg, err := Decode(strings.NewReader("pack_sizes: [250]\nstorage:\n  instrumentation:\n    enabled: true\n    slow_threshold: 100ms\n"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, cfg) {
+		assert.Equal(t, InstrumentationConfig{Enabled: true, SlowThreshold: 100 * time.Millisecond}, cfg.Storage.Instrumentation)
+	}
+
+	_, err = Decode(strings.NewReader("pack_sizes: [250]\nstorage:\n  instrumentation:\n    slow_threshold: -1s\n"))
+	assert.ErrorContains(t, err, "storage durations must not be negative")
+}
+
+func TestDecodeNotifications(t *testing.T) {
+	cfg, err := Decode(strings.NewReader("pack_sizes: [250]\nnotifications:\n  waste_percent: 20\n  slack:\n    webhook_url: https://hooks.slack.com/services/x\n"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, cfg) {
+		assert.Equal(t, DefaultNotificationCooldown, cfg.Notifications.Cooldown)
+		assert.Equal(t, 0.2, cfg.Notifications.Policy().WasteRate)
+	}
+
+	_, err = Decode(strings.NewReader("pack_sizes: [250]\nnotifications:\n  email:\n    smtp_addr: localhost:25\n"))
+	assert.ErrorContains(t, err, "notifications need waste_percent or approximate to alert on")
+	_, err = Decode(strings.NewReader("pack_sizes: [250]\nnotifications:\n  approximate: true\n  email:\n    smtp_addr: localhost:25\n"))
+	assert.ErrorContains(t, err, "notifications email needs from and to addresses")
+}
+
+func TestDecodeProfileGuardrail(t *testing.T) {
+	cfg, err := Decode(strings.NewReader("pack_sizes: [250]\nprofile_guardrail:\n  max_waste_increase: 2.5\n  sample: 200\n"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, cfg) {
+		assert.Equal(t, allocator.ProfileGuardrail{MaxWasteIncrease: 2.5, Sample: 200}, cfg.ProfileGuardrail.Guardrail())
+	}
+
+	_, err = Decode(strings.NewReader("pack_sizes: [250]\nprofile_guardrail:\n  sample: 5000\n"))
+	assert.ErrorContains(t, err, "profile guardrail sample 5000 must be between 0 and 1000")
+}
+
+func TestDecodeAllocationRules(t *testing.T) {
+	cfg, err := Decode(strings.NewReader(`pack_sizes: [250]
+allocation_rules:
+  - name: high_waste
+    when:
+      waste_percent: 10
+      profiles: [bulk]
+    note: Check the pack sizes
+  - name: small
+    when:
+      below_smallest: true
+    flags: [requires_approval]
+`))
+	assert.NoError(t, err)
+	if assert.NotNil(t, cfg) {
+		assert.Equal(t, []allocator.AllocationRule{
+			{Name: "high_waste", WasteRate: 0.1, Profiles: []string{"bulk"}, Note: "Check the pack sizes"},
+			{Name: "small", BelowSmallest: true, Flags: []string{"requires_approval"}},
+		}, cfg.AllocationRules.Rules())
+	}
+
+	_, err = Decode(strings.NewReader("pack_sizes: [250]\nallocation_rules:\n  - name: a\n    note: x\n  - name: a\n    note: y\n"))
+	assert.ErrorContains(t, err, `allocation rule "a" is defined twice`)
+	_, err = Decode(strings.NewReader("pack_sizes: [250]\nallocation_rules:\n  - name: a\n    when:\n      waste_percent: 10\n"))
+	assert.ErrorContains(t, err, `allocation rule "a": a note or flags are required`)
+}
+
+func TestDecodePreStop(t *testing.T) {
+	cfg, err := Decode(strings.NewReader("pack_sizes: [250]\n"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, cfg) {
+		assert.Equal(t, DefaultPreStopConfig, cfg.Server.PreStop)
+	}
+
+	cfg, err = Decode(strings.NewReader("pack_sizes: [250]\nserver:\n  prestop:\n    drain_delay: 2s\n"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, cfg) {
+		assert.Equal(t, PreStopConfig{DrainDelay: 2 * time.Second}, cfg.Server.PreStop)
+	}
+
+	_, err = Decode(strings.NewReader("pack_sizes: [250]\nserver:\n  prestop:\n    drain_timeout: -1s\n"))
+	assert.ErrorContains(t, err, "server prestop durations must not be negative")
+}
+
+func TestDecodeSecurity(t *testing.T) {
+	cfg, err := Decode(strings.NewReader("pack_sizes: [250]\nserver:\n  security:\n    hsts_max_age: 8760h\n    csrf: true\n"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, cfg) {
+		assert.Equal(t, api.Security{HSTSMaxAge: 8760 * time.Hour, CSRF: true}, cfg.Server.Security.Security())
+	}
+
+	_, err = Decode(strings.NewReader("pack_sizes: [250]\nserver:\n  security:\n    hsts_max_age: -1s\n"))
+	assert.ErrorContains(t, err, "server security hsts_max_age must not be negative")
+}
+
+func TestDecodeShadow(t *testing.T) {
+	cfg, err := Decode(strings.NewReader("pack_sizes: [250]\ncalculation:\n  shadow:\n    strategy: dp\n    rate: 0.05\n"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, cfg) {
+		assert.Equal(t, ShadowConfig{Strategy: "dp", Rate: 0.05}, cfg.Calculation.Shadow)
+	}
+
+	_, err = Decode(strings.NewReader("pack_sizes: [250]\ncalculation:\n  shadow:\n    strategy: dp\n    rate: 2\n"))
+	assert.ErrorContains(t, err, "calculation shadow rate must be between 0 and 1")
+	_, err = Decode(strings.NewReader("pack_sizes: [250]\ncalculation:\n  shadow:\n    strategy: quantum\n    rate: 0.5\n"))
+	assert.ErrorContains(t, err, `calculation shadow strategy "quantum"`)
+}
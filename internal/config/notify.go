@@ -0,0 +1,72 @@
+package config
+
+import (
+	"errors"
+	"time"
+
+	"github.com/n-th/gymshark/internal/allocator"
+)
+
+// NotificationsConfig alerts operators over Slack and email to allocations
+// wasting more than WastePercent of their quantity, when positive, and to
+// approximate results when Approximate is set. Alerts are only sent with a
+// Slack webhook or an SMTP server configured.
+type NotificationsConfig struct {
+	WastePercent float64 `yaml:"waste_percent"`
+	Approximate  bool    `yaml:"approximate"`
+	// Cooldown suppresses repeats of an alert for the same profile,
+	// quantity and reasons.
+	Cooldown time.Duration `yaml:"cooldown"`
+	// Buffer is how many alerts are queued for delivery before new ones
+	// are dropped.
+	Buffer int         `yaml:"buffer"`
+	Slack  SlackConfig `yaml:"slack"`
+	Email  EmailConfig `yaml:"email"`
+}
+
+// SlackConfig posts alerts to a Slack incoming webhook.
+type SlackConfig struct {
+	WebhookURL string `yaml:"webhook_url"`
+}
+
+// EmailConfig mails alerts through the SMTP server at SMTPAddr, host:port.
+// With a Username, the password is read from PasswordFile, surrounding
+// whitespace ignored.
+type EmailConfig struct {
+	SMTPAddr     string   `yaml:"smtp_addr"`
+	From         string   `yaml:"from"`
+	To           []string `yaml:"to"`
+	Username     string   `yaml:"username"`
+	PasswordFile string   `yaml:"password_file"`
+}
+
+// DefaultNotificationCooldown is how long repeats of an alert are
+// suppressed when notifications.cooldown is omitted.
+const DefaultNotificationCooldown = 10 * time.Minute
+
+// Enabled reports whether alerts have a channel to be sent over.
+func (c NotificationsConfig) Enabled() bool {
+	return c.Slack.WebhookURL != "" || c.Email.SMTPAddr != ""
+}
+
+// Policy returns the allocations to alert on.
+func (c NotificationsConfig) Policy() allocator.AnomalyPolicy {
+	return allocator.AnomalyPolicy{WasteRate: c.WastePercent / 100, Approximate: c.Approximate}
+}
+
+// Validate requires non-negative settings and, with a channel configured,
+// something to alert on and complete email settings.
+func (c NotificationsConfig) Validate() error {
+	e := c.Email
+	switch {
+	case c.WastePercent < 0 || c.Cooldown < 0 || c.Buffer < 0:
+		return errors.New("notifications settings must not be negative")
+	case c.Enabled() && c.WastePercent == 0 && !c.Approximate:
+		return errors.New("notifications need waste_percent or approximate to alert on")
+	case e.SMTPAddr != "" && (e.From == "" || len(e.To) == 0):
+		return errors.New("notifications email needs from and to addresses")
+	case e.Username != "" && e.PasswordFile == "":
+		return errors.New("notifications email username needs a password_file")
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"github.com/n-th/gymshark/internal/allocator"
+)
+
+// PackSizeRulesConfig sets what each pack-size rule does: off, warning or
+// error. Empty keeps the rule's default; see allocator.PackSizeRules.
+type PackSizeRulesConfig struct {
+	Duplicate string `yaml:"duplicate"`
+	UnitPack  string `yaml:"unit_pack"`
+	Multiple  string `yaml:"multiple"`
+	Ratio     string `yaml:"ratio"`
+	// MaxRatio is the largest size allowed as a multiple of the smallest.
+	MaxRatio int `yaml:"max_ratio"`
+}
+
+// ProfileGuardrailConfig requires profile updates raising the waste rate of
+// the profile's recent allocations by more than MaxWasteIncrease percentage
+// points to be forced; see allocator.ProfileGuardrail.
+type ProfileGuardrailConfig struct {
+	MaxWasteIncrease float64 `yaml:"max_waste_increase"`
+	Sample           int     `yaml:"sample"`
+}
+
+// Guardrail converts the configured guardrail.
+func (c ProfileGuardrailConfig) Guardrail() allocator.ProfileGuardrail {
+	return allocator.ProfileGuardrail{MaxWasteIncrease: c.MaxWasteIncrease, Sample: c.Sample}
+}
+
+// Rules converts the configured severities.
+func (c PackSizeRulesConfig) Rules() allocator.PackSizeRules {
+	return allocator.PackSizeRules{
+		Duplicate: allocator.Severity(c.Duplicate),
+		UnitPack:  allocator.Severity(c.UnitPack),
+		Multiple:  allocator.Severity(c.Multiple),
+		Ratio:     allocator.Severity(c.Ratio),
+		MaxRatio:  c.MaxRatio,
+	}
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProfileSyncConfig declares pack-size profiles in a separate YAML or JSON
+// file, such as a mounted ConfigMap, checked for changes every Interval.
+// An empty Path disables it.
+type ProfileSyncConfig struct {
+	Path     string        `yaml:"path"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+// DefaultProfileSyncInterval is how often the profiles file is checked when
+// profile_sync.interval is omitted.
+const DefaultProfileSyncInterval = 30 * time.Second
+
+// Validate requires a positive interval when a path is set.
+func (c ProfileSyncConfig) Validate() error {
+	if c.Path != "" && c.Interval <= 0 {
+		return errors.New("profile_sync interval must be positive")
+	}
+	return nil
+}
+
+// ProfilesFile is the content of a profile_sync file: pack sizes by profile
+// name, "default" being the default pack sizes, e.g.
+//
+//	profiles:
+//	  default: [250, 500, 1000]
+//	  apparel: [10, 20, 30]
+type ProfilesFile struct {
+	Profiles map[string][]int `yaml:"profiles"`
+}
+
+// LoadProfiles reads the profiles file at path, rejecting unknown fields
+// and profiles without pack sizes. JSON is read as the YAML it also is.
+func LoadProfiles(path string) (map[string][]int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f ProfilesFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(f.Profiles) == 0 {
+		return nil, fmt.Errorf("%s: no profiles declared", path)
+	}
+	for name, sizes := range f.Profiles {
+		if len(sizes) == 0 {
+			return nil, fmt.Errorf("%s: profile %q has no pack sizes", path, name)
+		}
+	}
+	return f.Profiles, nil
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadProfiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0600))
+		return path
+	}
+
+	profiles, err := LoadProfiles(write("profiles.yaml", "profiles:\n  default: [250, 500]\n  apparel: [10, 20]\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]int{"default": {250, 500}, "apparel": {10, 20}}, profiles)
+
+	profiles, err = LoadProfiles(write("profiles.json", `{"profiles": {"bulk": [100]}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]int{"bulk": {100}}, profiles)
+
+	for content, want := range map[string]string{
+		"profile:\n  default: [250]\n":  "field profile not found",
+		"profiles:\n  default: []\n":    `profile "default" has no pack sizes`,
+		"":                              "no profiles declared",
+		"profiles:\n  default: [big]\n": "cannot unmarshal",
+	} {
+		_, err := LoadProfiles(write("bad.yaml", content))
+		assert.ErrorContains(t, err, want, content)
+	}
+	_, err = LoadProfiles(filepath.Join(dir, "missing.yaml"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestDecodeProfileSync(t *testing.T) {
+	cfg, err := Decode(strings.NewReader("pack_sizes: [250]\nprofile_sync:\n  path: /etc/gymshark/profiles.yaml\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, ProfileSyncConfig{Path: "/etc/gymshark/profiles.yaml", Interval: DefaultProfileSyncInterval}, cfg.ProfileSync)
+
+	_, err = Decode(strings.NewReader("pack_sizes: [250]\nprofile_sync:\n  path: profiles.yaml\n  interval: -1s\n"))
+	assert.ErrorContains(t, err, "profile_sync interval must be positive")
+}
@@ -0,0 +1,48 @@
+package config
+
+import "github.com/n-th/gymshark/internal/allocator"
+
+// AllocationRuleConfig attaches Note and Flags to the allocations for which
+// every condition in When holds; see allocator.AllocationRule.
+type AllocationRuleConfig struct {
+	Name  string               `yaml:"name"`
+	When  RuleConditionsConfig `yaml:"when"`
+	Note  string               `yaml:"note"`
+	Flags []string             `yaml:"flags"`
+}
+
+// RuleConditionsConfig are the conditions of an allocation rule. Omitted
+// ones are not checked. WastePercent and ShortfallPercent hold above that
+// percentage of the quantity.
+type RuleConditionsConfig struct {
+	WastePercent     float64  `yaml:"waste_percent"`
+	ShortfallPercent float64  `yaml:"shortfall_percent"`
+	BelowSmallest    bool     `yaml:"below_smallest"`
+	MinQuantity      int      `yaml:"min_quantity"`
+	MaxQuantity      int      `yaml:"max_quantity"`
+	Approximate      bool     `yaml:"approximate"`
+	Profiles         []string `yaml:"profiles"`
+}
+
+// AllocationRulesConfig lists the allocation rules, evaluated in order.
+type AllocationRulesConfig []AllocationRuleConfig
+
+// Rules converts the configured rules.
+func (c AllocationRulesConfig) Rules() []allocator.AllocationRule {
+	rules := make([]allocator.AllocationRule, len(c))
+	for i, r := range c {
+		rules[i] = allocator.AllocationRule{
+			Name:          r.Name,
+			WasteRate:     r.When.WastePercent / 100,
+			ShortfallRate: r.When.ShortfallPercent / 100,
+			BelowSmallest: r.When.BelowSmallest,
+			MinQuantity:   r.When.MinQuantity,
+			MaxQuantity:   r.When.MaxQuantity,
+			Approximate:   r.When.Approximate,
+			Profiles:      r.When.Profiles,
+			Note:          r.Note,
+			Flags:         r.Flags,
+		}
+	}
+	return rules
+}
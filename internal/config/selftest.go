@@ -0,0 +1,30 @@
+package config
+
+import "fmt"
+
+// SelfTestConfig lists the allocations --self-test expects the configured
+// strategy to return, e.g. {quantity: 263, packs: {23: 2, 31: 7}}.
+type SelfTestConfig struct {
+	Cases []SelfTestCaseConfig `yaml:"cases"`
+}
+
+// SelfTestCaseConfig is the packs expected for a quantity of a profile; an
+// empty profile is the default pack sizes.
+type SelfTestCaseConfig struct {
+	Profile  string      `yaml:"profile"`
+	Quantity int         `yaml:"quantity"`
+	Packs    map[int]int `yaml:"packs"`
+}
+
+// Validate requires a positive quantity and packs in every case.
+func (c SelfTestConfig) Validate() error {
+	for i, tc := range c.Cases {
+		if tc.Quantity <= 0 {
+			return fmt.Errorf("self_test case %d: quantity must be positive", i)
+		}
+		if len(tc.Packs) == 0 {
+			return fmt.Errorf("self_test case %d: packs are required", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelfTestValidate(t *testing.T) {
+	cfg := SelfTestConfig{Cases: []SelfTestCaseConfig{
+		{Quantity: 1, Packs: map[int]int{23: 1}},
+		{Quantity: 263, Packs: map[int]int{23: 2, 31: 7}},
+	}}
+	assert.NoError(t, cfg.Validate())
+
+	for _, c := range []SelfTestCaseConfig{{Quantity: 0, Packs: map[int]int{23: 1}}, {Quantity: 5}} {
+		assert.Error(t, SelfTestConfig{Cases: []SelfTestCaseConfig{c}}.Validate())
+	}
+}
@@ -0,0 +1,20 @@
+package config
+
+// SigningConfig configures signatures on calculation responses. Signing is
+// enabled when Algorithm is set.
+type SigningConfig struct {
+	// Algorithm is hmac-sha256 or ed25519.
+	Algorithm string `yaml:"algorithm"`
+	// KeyID is sent with every signature so clients can pick the key to
+	// verify with, e.g. during rotation.
+	KeyID string `yaml:"key_id"`
+	// KeyFile holds the HMAC secret (at least 32 bytes, surrounding
+	// whitespace ignored) or a PEM-encoded PKCS #8 Ed25519 private key, as
+	// written by "openssl genpkey -algorithm ed25519".
+	KeyFile string `yaml:"key_file"`
+}
+
+// Enabled reports whether responses should be signed.
+func (c SigningConfig) Enabled() bool {
+	return c.Algorithm != ""
+}
@@ -0,0 +1,19 @@
+package config
+
+// TLSConfig configures native TLS termination.
+// TLS is enabled when a certificate/key pair is configured or SelfSigned is set.
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// SelfSigned generates an in-memory certificate at startup. Development only.
+	SelfSigned bool `yaml:"self_signed"`
+	// HTTP2 enables HTTP/2 negotiation over TLS.
+	HTTP2 bool `yaml:"http2"`
+	// RedirectPort, when set, starts a plain HTTP listener that redirects to HTTPS.
+	RedirectPort int `yaml:"redirect_port"`
+}
+
+// Enabled reports whether the server should terminate TLS itself.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != "" || c.SelfSigned
+}
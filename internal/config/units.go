@@ -0,0 +1,32 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/n-th/gymshark/internal/allocator"
+)
+
+// UnitConfig is a unit requests may give quantities in with ?unit=<name>,
+// e.g. cases of 12: quantities are multiplied by Multiplier and rounded to
+// whole items by Rounding, up (the default), down or nearest.
+type UnitConfig struct {
+	Multiplier float64 `yaml:"multiplier"`
+	Rounding   string  `yaml:"rounding"`
+}
+
+// QuantityUnits converts the configured units, rejecting invalid ones and the
+// names built into the calculate endpoints.
+func QuantityUnits(c map[string]UnitConfig) (map[string]allocator.Unit, error) {
+	units := make(map[string]allocator.Unit, len(c))
+	for name, u := range c {
+		if name == "units" || name == "weight" {
+			return nil, fmt.Errorf("unit %q is built in and cannot be configured", name)
+		}
+		unit := allocator.Unit{Multiplier: u.Multiplier, Rounding: allocator.Rounding(u.Rounding)}
+		if err := unit.Validate(); err != nil {
+			return nil, fmt.Errorf("unit %q: %w", name, err)
+		}
+		units[name] = unit
+	}
+	return units, nil
+}
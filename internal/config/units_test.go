@@ -0,0 +1,30 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuantityUnits(t *testing.T) {
+	units, err := QuantityUnits(map[string]UnitConfig{
+		"case": {Multiplier: 12},
+		"half": {Multiplier: 0.5, Rounding: "down"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]allocator.Unit{
+		"case": {Multiplier: 12},
+		"half": {Multiplier: 0.5, Rounding: allocator.RoundDown},
+	}, units)
+
+	for _, c := range []map[string]UnitConfig{
+		{"weight": {Multiplier: 1000}},
+		{"units": {Multiplier: 1}},
+		{"case": {}},
+		{"case": {Multiplier: 12, Rounding: "sideways"}},
+	} {
+		_, err := QuantityUnits(c)
+		assert.Error(t, err, "%v", c)
+	}
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/n-th/gymshark/internal/allocator"
+)
+
+// UsageConfig records the requests and compute time of every client and
+// enforces monthly quotas. Clients are the API keys listed in APIKeys and
+// the subjects of tokens verified by admin_auth; all other callers share the
+// "anonymous" client. Calculations over quota are answered with 429 until
+// the next month (UTC).
+type UsageConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// APIKeys lists the SHA-256 digests, in hex, of the API keys metered
+	// as clients of their own, "key:" plus the first 12 digits.
+	APIKeys []string `yaml:"api_keys"`
+	// DefaultQuota applies to clients not listed in Quotas; zero limits
+	// are unlimited.
+	DefaultQuota QuotaConfig `yaml:"default_quota"`
+	// Quotas maps clients, e.g. "key:3f2a9c1b0d4e" or "user:alice", to
+	// their quotas.
+	Quotas map[string]QuotaConfig `yaml:"quotas"`
+}
+
+// QuotaConfig is a monthly quota of requests and compute time.
+type QuotaConfig struct {
+	Requests int64         `yaml:"requests"`
+	Compute  time.Duration `yaml:"compute"`
+}
+
+// Validate returns an error for malformed API key digests or quotas.
+func (c UsageConfig) Validate() error {
+	for _, digest := range c.APIKeys {
+		if b, err := hex.DecodeString(digest); err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("invalid API key digest %q: want 64 hex digits", digest)
+		}
+	}
+	return c.Metering().Validate()
+}
+
+// Metering converts the usage configuration.
+func (c UsageConfig) Metering() allocator.Metering {
+	m := allocator.Metering{
+		Enabled: c.Enabled,
+		Default: allocator.Quota(c.DefaultQuota),
+		Clients: make(map[string]allocator.Quota, len(c.Quotas)),
+	}
+	for client, q := range c.Quotas {
+		m.Clients[client] = allocator.Quota(q)
+	}
+	return m
+}
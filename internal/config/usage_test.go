@@ -0,0 +1,32 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsageMetering(t *testing.T) {
+	c := UsageConfig{
+		Enabled:      true,
+		DefaultQuota: QuotaConfig{Requests: 1000},
+		Quotas:       map[string]QuotaConfig{"user:alice": {Compute: time.Hour}},
+	}
+	assert.Equal(t, allocator.Metering{
+		Enabled: true,
+		Default: allocator.Quota{Requests: 1000},
+		Clients: map[string]allocator.Quota{"user:alice": {Compute: time.Hour}},
+	}, c.Metering())
+
+	for _, c := range []UsageConfig{
+		{DefaultQuota: QuotaConfig{Requests: -1}},
+		{Quotas: map[string]QuotaConfig{"user:alice": {Compute: -time.Second}}},
+		{Quotas: map[string]QuotaConfig{"": {Requests: 1}}},
+		{APIKeys: []string{"3f2a9c1b0d4e"}},
+	} {
+		assert.Error(t, c.Validate(), "%+v", c)
+	}
+	assert.NoError(t, UsageConfig{APIKeys: []string{"2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"}}.Validate())
+}
@@ -0,0 +1,80 @@
+package events
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBuffer is the number of events AsyncPublisher queues when none is
+// configured.
+const DefaultBuffer = 1000
+
+// publishTimeout bounds each delivery attempt to the broker.
+const publishTimeout = 10 * time.Second
+
+// AsyncPublisher queues events and delivers them to another Publisher in
+// the background, so that a slow or unavailable broker never delays a
+// request. Events are dropped when the queue is full or delivery fails.
+type AsyncPublisher struct {
+	next    Publisher
+	queue   chan Event
+	done    chan struct{}
+	once    sync.Once
+	dropped atomic.Int64
+}
+
+// NewAsyncPublisher starts delivering events to next, queueing up to buffer
+// of them (DefaultBuffer if buffer is not positive).
+func NewAsyncPublisher(next Publisher, buffer int) *AsyncPublisher {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	p := &AsyncPublisher{next: next, queue: make(chan Event, buffer), done: make(chan struct{})}
+	go p.run()
+	return p
+}
+
+func (p *AsyncPublisher) run() {
+	defer close(p.done)
+	for e := range p.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+		err := p.next.Publish(ctx, e)
+		cancel()
+		if err != nil {
+			p.dropped.Add(1)
+			log.Printf("Failed to publish %s event %s: %v", e.Type, e.ID, err)
+		}
+	}
+}
+
+// Publish queues e without blocking. It never fails; events that do not fit
+// in the queue are dropped and logged.
+func (p *AsyncPublisher) Publish(_ context.Context, e Event) error {
+	select {
+	case p.queue <- e:
+	default:
+		p.dropped.Add(1)
+		log.Printf("Event queue full, dropping %s event %s", e.Type, e.ID)
+	}
+	return nil
+}
+
+// Dropped returns the number of events that were never delivered.
+func (p *AsyncPublisher) Dropped() int64 {
+	return p.dropped.Load()
+}
+
+// Close delivers the queued events and closes the underlying publisher.
+// Publish must not be called afterwards.
+func (p *AsyncPublisher) Close() error {
+	var err error
+	p.once.Do(func() {
+		close(p.queue)
+		<-p.done
+		err = p.next.Close()
+	})
+	return err
+}
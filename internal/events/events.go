@@ -0,0 +1,96 @@
+// Package events publishes allocation and profile events to a message broker,
+// so downstream systems such as warehouse management can react to them
+// without polling the API.
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Type identifies what an Event reports.
+type Type string
+
+const (
+	// TypeAllocationCompleted reports a computed allocation.
+	TypeAllocationCompleted Type = "allocation.completed"
+	// TypeProfileChanged reports new pack sizes for a profile.
+	TypeProfileChanged Type = "profile.changed"
+)
+
+// Event is a single message published to the broker, encoded as JSON.
+type Event struct {
+	ID   string    `json:"id"`
+	Type Type      `json:"type"`
+	Time time.Time `json:"time"`
+	// Allocation is set for TypeAllocationCompleted, Profile for
+	// TypeProfileChanged.
+	Allocation *Allocation `json:"allocation,omitempty"`
+	Profile    *Profile    `json:"profile,omitempty"`
+}
+
+// Allocation describes a completed allocation.
+type Allocation struct {
+	Quantity       int                    `json:"quantity"`
+	Packs          map[int]int            `json:"packs"`
+	Total          int                    `json:"total"`
+	Approximate    bool                   `json:"approximate"`
+	Profile        string                 `json:"profile"`
+	ProfileVersion int                    `json:"profile_version,omitempty"`
+	OrderID        string                 `json:"order_id,omitempty"`
+	CustomerID     string                 `json:"customer_id,omitempty"`
+	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+	// Source is "manual" when the allocation was pinned.
+	Source string `json:"source,omitempty"`
+}
+
+// Profile describes the pack sizes of a profile after a change, and the
+// sizes the change added and removed.
+type Profile struct {
+	Name      string `json:"name"`
+	Version   int    `json:"version,omitempty"`
+	PackSizes []int  `json:"pack_sizes"`
+	Added     []int  `json:"added,omitempty"`
+	Removed   []int  `json:"removed,omitempty"`
+}
+
+// New returns an event of type t with a fresh ID and the current time.
+func New(t Type) Event {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("events: read random id: %v", err))
+	}
+	return Event{ID: hex.EncodeToString(b), Type: t, Time: time.Now().UTC()}
+}
+
+// Key returns the partitioning key of the event: the order ID for
+// allocations and the profile name for profile changes, so events about the
+// same order or profile stay in order. It is empty when there is neither.
+func (e Event) Key() string {
+	switch {
+	case e.Allocation != nil:
+		return e.Allocation.OrderID
+	case e.Profile != nil:
+		return e.Profile.Name
+	}
+	return ""
+}
+
+// Publisher delivers events to a broker.
+type Publisher interface {
+	// Publish sends an event. It may block until the broker acknowledges it.
+	Publish(ctx context.Context, e Event) error
+	Close() error
+}
+
+// Subscriber receives messages published by other systems, such as
+// order-created events.
+type Subscriber interface {
+	// Subscribe calls fn with the payload of every message received, one at
+	// a time, until ctx is done or the subscription fails.
+	Subscribe(ctx context.Context, fn func(payload []byte)) error
+	Close() error
+}
@@ -0,0 +1,345 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventKey(t *testing.T) {
+	e := New(TypeAllocationCompleted)
+	assert.Len(t, e.ID, 32)
+	assert.NotEqual(t, e.ID, New(TypeAllocationCompleted).ID)
+	assert.Empty(t, e.Key())
+
+	e.Allocation = &Allocation{Quantity: 500, OrderID: "ORD-1"}
+	assert.Equal(t, "ORD-1", e.Key())
+
+	e = New(TypeProfileChanged)
+	e.Profile = &Profile{Name: "apparel", PackSizes: []int{500, 250}}
+	assert.Equal(t, "apparel", e.Key())
+}
+
+// recorder is a Publisher that records events, optionally blocking until
+// release is closed.
+type recorder struct {
+	mu      sync.Mutex
+	events  []Event
+	err     error
+	release chan struct{}
+	closed  bool
+}
+
+func (r *recorder) Publish(ctx context.Context, e Event) error {
+	if r.release != nil {
+		<-r.release
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	return r.err
+}
+
+func (r *recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return nil
+}
+
+func TestAsyncPublisher(t *testing.T) {
+	next := &recorder{}
+	p := NewAsyncPublisher(next, 10)
+	for i := 0; i < 5; i++ {
+		e := New(TypeAllocationCompleted)
+		e.Allocation = &Allocation{Quantity: i}
+		assert.NoError(t, p.Publish(context.Background(), e))
+	}
+	assert.NoError(t, p.Close())
+	assert.NoError(t, p.Close())
+
+	// Close delivers everything queued, in order
+	assert.True(t, next.closed)
+	if assert.Len(t, next.events, 5) {
+		for i, e := range next.events {
+			assert.Equal(t, i, e.Allocation.Quantity)
+		}
+	}
+	assert.Zero(t, p.Dropped())
+}
+
+func TestAsyncPublisherDrops(t *testing.T) {
+	next := &recorder{release: make(chan struct{})}
+	p := NewAsyncPublisher(next, 1)
+
+	// One event is being delivered, one is queued and the rest are dropped
+	// rather than blocking the caller.
+	assert.NoError(t, p.Publish(context.Background(), New(TypeAllocationCompleted)))
+	assert.Eventually(t, func() bool { return len(p.queue) == 0 }, time.Second, time.Millisecond)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, p.Publish(context.Background(), New(TypeAllocationCompleted)))
+	}
+	assert.Equal(t, int64(2), p.Dropped())
+
+	close(next.release)
+	assert.NoError(t, p.Close())
+	assert.Len(t, next.events, 2)
+
+	// Delivery failures count as dropped too
+	failing := NewAsyncPublisher(&recorder{err: errors.New("broker down")}, 1)
+	assert.NoError(t, failing.Publish(context.Background(), New(TypeAllocationCompleted)))
+	assert.NoError(t, failing.Close())
+	assert.Equal(t, int64(1), failing.Dropped())
+}
+
+func TestKafkaPublisher(t *testing.T) {
+	var (
+		path, contentType string
+		body              map[string]interface{}
+		fail              bool
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, contentType = r.URL.Path, r.Header.Get("Content-Type")
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		if fail {
+			fmt.Fprint(w, `{"offsets":[{"partition":null,"offset":null,"error_code":40403,"error":"Schema not found"}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"offsets":[{"partition":0,"offset":42}]}`)
+	}))
+	defer server.Close()
+
+	p, err := NewKafkaPublisher(server.URL+"/", "")
+	assert.NoError(t, err)
+	defer p.Close()
+
+	e := New(TypeAllocationCompleted)
+	e.Allocation = &Allocation{Quantity: 501, Packs: map[int]int{500: 1, 250: 1}, Total: 750, Profile: "default", OrderID: "ORD-7"}
+	assert.NoError(t, p.Publish(context.Background(), e))
+	assert.Equal(t, "/topics/gymshark.events", path)
+	assert.Equal(t, "application/vnd.kafka.json.v2+json", contentType)
+	records := body["records"].([]interface{})
+	if assert.Len(t, records, 1) {
+		record := records[0].(map[string]interface{})
+		assert.Equal(t, "ORD-7", record["key"])
+		value := record["value"].(map[string]interface{})
+		assert.Equal(t, "allocation.completed", value["type"])
+		assert.Equal(t, map[string]interface{}{"500": 1.0, "250": 1.0}, value["allocation"].(map[string]interface{})["packs"])
+	}
+
+	fail = true
+	assert.ErrorContains(t, p.Publish(context.Background(), New(TypeProfileChanged)), "Schema not found")
+	assert.Nil(t, body["records"].([]interface{})[0].(map[string]interface{})["key"])
+
+	server.Close()
+	assert.Error(t, p.Publish(context.Background(), e))
+
+	_, err = NewKafkaPublisher("localhost:8082", "allocations")
+	assert.Error(t, err)
+}
+
+// fakeNATS is a minimal NATS server that accepts one client, records the
+// payloads it publishes and the subscriptions it makes, and can deliver
+// messages to it.
+type fakeNATS struct {
+	url        string
+	published  chan string
+	subscribed chan []string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func newFakeNATS(t *testing.T) *fakeNATS {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	s := &fakeNATS{url: "nats://" + ln.Addr().String(), published: make(chan string, 10), subscribed: make(chan []string, 10)}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		s.mu.Lock()
+		s.conn = conn
+		fmt.Fprintf(conn, "INFO {\"server_id\":\"fake\",\"version\":\"2.10.0\",\"proto\":1,\"max_payload\":1048576}\r\n")
+		s.mu.Unlock()
+
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			switch fields[0] {
+			case "PING":
+				s.mu.Lock()
+				fmt.Fprint(conn, "PONG\r\n")
+				s.mu.Unlock()
+			case "SUB":
+				s.subscribed <- fields[1:]
+			case "PUB":
+				n, _ := strconv.Atoi(fields[len(fields)-1])
+				payload := make([]byte, n+2)
+				if _, err := io.ReadFull(r, payload); err != nil {
+					return
+				}
+				s.published <- fields[1] + " " + string(payload[:n])
+			}
+		}
+	}()
+	return s
+}
+
+// deliver sends payload to the client's subscription sid.
+func (s *fakeNATS) deliver(subject, sid, payload string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintf(s.conn, "MSG %s %s %d\r\n%s\r\n", subject, sid, len(payload), payload)
+}
+
+func TestNATSPublisher(t *testing.T) {
+	server := newFakeNATS(t)
+	p, err := NewNATSPublisher(server.url, "warehouse.allocations")
+	assert.NoError(t, err)
+	defer p.Close()
+
+	e := New(TypeProfileChanged)
+	e.Profile = &Profile{Name: "apparel", Version: 2, PackSizes: []int{1000, 500}}
+	assert.NoError(t, p.Publish(context.Background(), e))
+
+	select {
+	case msg := <-server.published:
+		subject, payload, _ := strings.Cut(msg, " ")
+		assert.Equal(t, "warehouse.allocations", subject)
+		var got Event
+		assert.NoError(t, json.Unmarshal([]byte(payload), &got))
+		assert.Equal(t, e.ID, got.ID)
+		assert.Equal(t, TypeProfileChanged, got.Type)
+		assert.Equal(t, e.Profile, got.Profile)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "event not published")
+	}
+}
+
+func TestNATSSubscriber(t *testing.T) {
+	server := newFakeNATS(t)
+	s, err := NewNATSSubscriber(server.url, "orders.created", "gymshark-worker")
+	assert.NoError(t, err)
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	received := make(chan string, 2)
+	done := make(chan error)
+	go func() {
+		done <- s.Subscribe(ctx, func(payload []byte) {
+			received <- string(payload)
+			if len(received) == 2 {
+				cancel()
+			}
+		})
+	}()
+
+	var sub []string
+	select {
+	case sub = <-server.subscribed:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "not subscribed")
+		return
+	}
+	assert.Equal(t, []string{"orders.created", "gymshark-worker"}, sub[:2])
+	server.deliver("orders.created", sub[2], `{"quantity":501}`)
+	server.deliver("orders.created", sub[2], `{"quantity":250}`)
+
+	assert.ErrorIs(t, <-done, context.Canceled)
+	assert.Equal(t, `{"quantity":501}`, <-received)
+	assert.Equal(t, `{"quantity":250}`, <-received)
+
+	_, err = NewNATSSubscriber(server.url, "", "")
+	assert.Error(t, err)
+}
+
+func TestKafkaSubscriber(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []string
+		polls    int
+	)
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+string(body))
+		mu.Unlock()
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/consumers/warmers":
+			fmt.Fprintf(w, `{"instance_id":"w1","base_uri":"%s/consumers/warmers/instances/w1"}`, server.URL)
+		case r.URL.Path == "/consumers/warmers/instances/w1/subscription":
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/consumers/warmers/instances/w1/records":
+			assert.Equal(t, "application/vnd.kafka.json.v2+json", r.Header.Get("Accept"))
+			mu.Lock()
+			polls++
+			first := polls == 1
+			mu.Unlock()
+			if first {
+				fmt.Fprint(w, `[{"topic":"orders","key":"ORD-1","value":{"order_id":"ORD-1","quantity":501},"partition":0,"offset":0}]`)
+				return
+			}
+			fmt.Fprint(w, `[]`)
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	s, err := NewKafkaSubscriber(server.URL, "orders", "warmers")
+	assert.NoError(t, err)
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var received []string
+	err = s.Subscribe(ctx, func(payload []byte) {
+		received = append(received, string(payload))
+		cancel()
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []string{`{"order_id":"ORD-1","quantity":501}`}, received)
+
+	mu.Lock()
+	if assert.Len(t, requests, 4) {
+		assert.Contains(t, requests[0], `"auto.offset.reset":"earliest"`)
+		assert.Equal(t, `POST /consumers/warmers/instances/w1/subscription {"topics":["orders"]}`, requests[1])
+		assert.Equal(t, "DELETE /consumers/warmers/instances/w1 ", requests[3])
+	}
+	mu.Unlock()
+
+	// A proxy that refuses the consumer fails the subscription
+	failing, err := NewKafkaSubscriber(server.URL, "orders", "unknown")
+	assert.NoError(t, err)
+	assert.ErrorContains(t, failing.Subscribe(context.Background(), func([]byte) {}), "404")
+
+	_, err = NewKafkaSubscriber(server.URL, "", "warmers")
+	assert.Error(t, err)
+}
@@ -0,0 +1,228 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultTopic is the Kafka topic used when none is configured.
+const DefaultTopic = "gymshark.events"
+
+// kafkaJSONContentType is the REST Proxy v2 content type for JSON records.
+const kafkaJSONContentType = "application/vnd.kafka.json.v2+json"
+
+// KafkaPublisher publishes events to a Kafka topic through a Kafka REST
+// Proxy (v2 API), e.g. Confluent REST Proxy or Redpanda's HTTP proxy.
+type KafkaPublisher struct {
+	client   *http.Client
+	endpoint string
+}
+
+// NewKafkaPublisher publishes to topic through the REST Proxy at proxyURL,
+// e.g. "http://localhost:8082".
+func NewKafkaPublisher(proxyURL, topic string) (*KafkaPublisher, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid kafka rest proxy url %q", proxyURL)
+	}
+	if topic == "" {
+		topic = DefaultTopic
+	}
+	return &KafkaPublisher{
+		client:   &http.Client{Timeout: 30 * time.Second},
+		endpoint: strings.TrimRight(proxyURL, "/") + "/topics/" + url.PathEscape(topic),
+	}, nil
+}
+
+type kafkaRecords struct {
+	Records []kafkaRecord `json:"records"`
+}
+
+type kafkaRecord struct {
+	Key   *string `json:"key"`
+	Value Event   `json:"value"`
+}
+
+// Publish produces e as a single record keyed by e.Key().
+func (p *KafkaPublisher) Publish(ctx context.Context, e Event) error {
+	record := kafkaRecord{Value: e}
+	if key := e.Key(); key != "" {
+		record.Key = &key
+	}
+	body, err := json.Marshal(kafkaRecords{Records: []kafkaRecord{record}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", kafkaJSONContentType)
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("kafka rest proxy: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	// The proxy reports per-record failures in the offsets of a 200 response.
+	var result struct {
+		Offsets []struct {
+			ErrorCode *int   `json:"error_code"`
+			Error     string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("kafka rest proxy: decode response: %w", err)
+	}
+	for _, o := range result.Offsets {
+		if o.ErrorCode != nil {
+			return fmt.Errorf("kafka rest proxy: error %d: %s", *o.ErrorCode, o.Error)
+		}
+	}
+	return nil
+}
+
+// Close releases idle connections to the proxy.
+func (p *KafkaPublisher) Close() error {
+	p.client.CloseIdleConnections()
+	return nil
+}
+
+// kafkaPollInterval is how long KafkaSubscriber waits after an empty poll.
+const kafkaPollInterval = time.Second
+
+// KafkaSubscriber consumes a Kafka topic as a member of a consumer group
+// through a Kafka REST Proxy (v2 API). Offsets are committed automatically
+// by the proxy.
+type KafkaSubscriber struct {
+	client *http.Client
+	proxy  string
+	topic  string
+	group  string
+}
+
+// NewKafkaSubscriber consumes topic in group through the REST Proxy at
+// proxyURL, e.g. "http://localhost:8082".
+func NewKafkaSubscriber(proxyURL, topic, group string) (*KafkaSubscriber, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid kafka rest proxy url %q", proxyURL)
+	}
+	if topic == "" || group == "" {
+		return nil, errors.New("kafka topic and consumer group are required")
+	}
+	return &KafkaSubscriber{
+		client: &http.Client{Timeout: 30 * time.Second},
+		proxy:  strings.TrimRight(proxyURL, "/"),
+		topic:  topic,
+		group:  group,
+	}, nil
+}
+
+// Subscribe joins the consumer group and delivers records until ctx is done.
+// The consumer instance is removed from the proxy when it returns.
+func (s *KafkaSubscriber) Subscribe(ctx context.Context, fn func([]byte)) error {
+	var instance struct {
+		BaseURI string `json:"base_uri"`
+	}
+	err := s.do(ctx, http.MethodPost, s.proxy+"/consumers/"+url.PathEscape(s.group), map[string]string{
+		"format":             "json",
+		"auto.offset.reset":  "earliest",
+		"auto.commit.enable": "true",
+	}, &instance)
+	if err != nil {
+		return fmt.Errorf("create kafka consumer: %w", err)
+	}
+	defer func() {
+		// The caller's ctx is usually done by now.
+		ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+		defer cancel()
+		if err := s.do(ctx, http.MethodDelete, instance.BaseURI, nil, nil); err != nil {
+			log.Printf("Failed to remove kafka consumer: %v", err)
+		}
+	}()
+
+	err = s.do(ctx, http.MethodPost, instance.BaseURI+"/subscription", map[string][]string{"topics": {s.topic}}, nil)
+	if err != nil {
+		return fmt.Errorf("subscribe to %s: %w", s.topic, err)
+	}
+
+	for {
+		var records []struct {
+			Value json.RawMessage `json:"value"`
+		}
+		if err := s.do(ctx, http.MethodGet, instance.BaseURI+"/records", nil, &records); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("poll %s: %w", s.topic, err)
+		}
+		for _, r := range records {
+			fn(r.Value)
+		}
+		if len(records) > 0 {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(kafkaPollInterval):
+		}
+	}
+}
+
+// do sends a REST Proxy request with an optional JSON body and decodes the
+// JSON response into out, if given.
+func (s *KafkaSubscriber) do(ctx context.Context, method, endpoint string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/vnd.kafka.v2+json")
+	}
+	req.Header.Set("Accept", "application/vnd.kafka.json.v2+json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("kafka rest proxy: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Close releases idle connections to the proxy.
+func (s *KafkaSubscriber) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
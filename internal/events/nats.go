@@ -0,0 +1,120 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// DefaultSubject is the NATS subject used when none is configured.
+const DefaultSubject = "gymshark.events"
+
+// NATSPublisher publishes events to a NATS subject.
+type NATSPublisher struct {
+	conn    *nats.Conn
+	subject string
+}
+
+// NewNATSPublisher connects to the NATS server at url, e.g.
+// "nats://localhost:4222". The client reconnects on its own if the
+// connection drops.
+func NewNATSPublisher(url, subject string) (*NATSPublisher, error) {
+	if subject == "" {
+		subject = DefaultSubject
+	}
+	conn, err := connectNATS(url)
+	if err != nil {
+		return nil, err
+	}
+	return &NATSPublisher{conn: conn, subject: subject}, nil
+}
+
+func connectNATS(url string) (*nats.Conn, error) {
+	conn, err := nats.Connect(url, nats.Name("gymshark"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("connect to nats: %w", err)
+	}
+	return conn, nil
+}
+
+// Publish sends e to the subject and waits for the server to receive it,
+// for at most publishTimeout if ctx has no deadline.
+func (p *NATSPublisher) Publish(ctx context.Context, e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := p.conn.Publish(p.subject, payload); err != nil {
+		return err
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, publishTimeout)
+		defer cancel()
+	}
+	return p.conn.FlushWithContext(ctx)
+}
+
+// Close flushes pending messages and closes the connection.
+func (p *NATSPublisher) Close() error {
+	defer p.conn.Close()
+	if !p.conn.IsConnected() {
+		return nil
+	}
+	return p.conn.FlushTimeout(publishTimeout)
+}
+
+// NATSSubscriber receives messages from a NATS subject as a member of a
+// queue group, so several subscribers share the messages between them.
+type NATSSubscriber struct {
+	conn    *nats.Conn
+	subject string
+	queue   string
+}
+
+// NewNATSSubscriber connects to the NATS server at url. An empty queue
+// delivers every message to every subscriber.
+func NewNATSSubscriber(url, subject, queue string) (*NATSSubscriber, error) {
+	if subject == "" {
+		return nil, errors.New("nats subject is required")
+	}
+	conn, err := connectNATS(url)
+	if err != nil {
+		return nil, err
+	}
+	return &NATSSubscriber{conn: conn, subject: subject, queue: queue}, nil
+}
+
+// Subscribe delivers messages until ctx is done. Messages published while
+// the client is disconnected are lost.
+func (s *NATSSubscriber) Subscribe(ctx context.Context, fn func([]byte)) error {
+	messages := make(chan *nats.Msg, 64)
+	sub, err := s.conn.ChanQueueSubscribe(s.subject, s.queue, messages)
+	if err != nil {
+		return fmt.Errorf("subscribe to %s: %w", s.subject, err)
+	}
+	defer sub.Unsubscribe()
+	// Make sure the server registered the subscription before returning
+	// control, so nothing published from now on is missed.
+	if err := s.conn.FlushTimeout(publishTimeout); err != nil {
+		return fmt.Errorf("subscribe to %s: %w", s.subject, err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg := <-messages:
+			fn(msg.Data)
+		}
+	}
+}
+
+// Close closes the connection.
+func (s *NATSSubscriber) Close() error {
+	s.conn.Close()
+	return nil
+}
@@ -0,0 +1,128 @@
+//go:build faults
+
+// Package faults injects storage latency and errors and slow allocations, so
+// callers' retry and timeout handling can be tested against a misbehaving
+// service in staging. It is only compiled with the "faults" build tag and
+// must never be built into production images.
+package faults
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrInjected is returned by storage operations failed on purpose.
+var ErrInjected = errors.New("injected storage fault")
+
+// Config describes the faults to inject. The zero Config injects none.
+type Config struct {
+	// StorageLatency delays every storage operation.
+	StorageLatency time.Duration
+	// StorageErrorRate is the fraction of storage operations, from 0 to 1,
+	// that fail with ErrInjected after the latency.
+	StorageErrorRate float64
+	// Operations limits the storage faults to the named Storage methods,
+	// e.g. "StoreAllocationInput"; empty applies them to every operation.
+	Operations []string
+	// AllocationLatency delays every strategy computation. The delay counts
+	// against the calculation timeouts, so it triggers the greedy fallback
+	// and hard-timeout errors as a slow computation would.
+	AllocationLatency time.Duration
+}
+
+// Validate checks that the latencies are not negative, the error rate is a
+// fraction and the operations are Storage methods.
+func (c Config) Validate() error {
+	if c.StorageLatency < 0 || c.AllocationLatency < 0 {
+		return errors.New("latencies must not be negative")
+	}
+	if c.StorageErrorRate < 0 || c.StorageErrorRate > 1 {
+		return fmt.Errorf("storage error rate %g must be between 0 and 1", c.StorageErrorRate)
+	}
+	for _, op := range c.Operations {
+		if !operations[op] {
+			return fmt.Errorf("unknown storage operation %q", op)
+		}
+	}
+	return nil
+}
+
+// Injector holds the faults currently injected. It is safe for concurrent
+// use; faults take effect on the next operation.
+type Injector struct {
+	mu   sync.RWMutex
+	cfg  Config
+	ops  map[string]bool
+	rand func() float64
+}
+
+// New returns an injector that injects no faults until configured.
+func New() *Injector {
+	return &Injector{rand: rand.Float64}
+}
+
+// Set replaces the injected faults.
+func (i *Injector) Set(c Config) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	ops := make(map[string]bool, len(c.Operations))
+	for _, op := range c.Operations {
+		ops[op] = true
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.cfg = c
+	i.ops = ops
+	return nil
+}
+
+// Config returns the injected faults.
+func (i *Injector) Config() Config {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.cfg
+}
+
+// Reset stops injecting faults.
+func (i *Injector) Reset() {
+	i.Set(Config{})
+}
+
+// storage applies the storage faults to the operation op.
+func (i *Injector) storage(op string) error {
+	i.mu.RLock()
+	c, ops := i.cfg, i.ops
+	i.mu.RUnlock()
+	if len(ops) > 0 && !ops[op] {
+		return nil
+	}
+	if c.StorageLatency > 0 {
+		time.Sleep(c.StorageLatency)
+	}
+	if c.StorageErrorRate > 0 && i.rand() < c.StorageErrorRate {
+		return fmt.Errorf("%s: %w", op, ErrInjected)
+	}
+	return nil
+}
+
+// allocation applies the allocation latency, returning early with the
+// context's error.
+func (i *Injector) allocation(ctx context.Context) error {
+	d := i.Config().AllocationLatency
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,105 @@
+//go:build faults
+
+package faults
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+func TestStorageFaults(t *testing.T) {
+	inner, err := storage.NewInMemorySQLite()
+	assert.NoError(t, err)
+	defer inner.Close()
+	injector := New()
+	s := Storage(inner, injector)
+
+	assert.NoError(t, s.StoreAllocation(10, map[int]int{23: 1}, 23))
+
+	assert.NoError(t, injector.Set(Config{StorageErrorRate: 1, Operations: []string{"StoreAllocation"}}))
+	err = s.StoreAllocation(10, map[int]int{23: 1}, 23)
+	assert.True(t, errors.Is(err, ErrInjected))
+	recent, err := s.GetRecentAllocations(10, false)
+	assert.NoError(t, err)
+	assert.Len(t, recent, 1)
+
+	assert.NoError(t, injector.Set(Config{StorageLatency: 20 * time.Millisecond}))
+	start := time.Now()
+	_, err = s.GetRecentAllocations(10, false)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	injector.Reset()
+	assert.Equal(t, Config{}, injector.Config())
+
+	for _, c := range []Config{
+		{StorageErrorRate: 1.5},
+		{StorageLatency: -time.Second},
+		{Operations: []string{"DropTable"}},
+	} {
+		assert.Error(t, injector.Set(c), c)
+	}
+}
+
+func TestAllocationFaults(t *testing.T) {
+	injector := New()
+	assert.NoError(t, WrapStrategies(injector))
+	alloc := allocator.NewAllocator([]int{23, 31, 53}, nil)
+
+	_, err := alloc.Preview(context.Background(), allocator.Request{Quantity: 50})
+	assert.NoError(t, err)
+
+	// Slow allocations run into the hard timeout
+	assert.NoError(t, injector.Set(Config{AllocationLatency: time.Second}))
+	alloc.SetTimeouts(0, 20*time.Millisecond)
+	_, err = alloc.Preview(context.Background(), allocator.Request{Quantity: 50})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// and past the soft timeout fall back to greedy
+	alloc.SetTimeouts(20*time.Millisecond, 0)
+	result, err := alloc.Preview(context.Background(), allocator.Request{Quantity: 50})
+	assert.NoError(t, err)
+	assert.True(t, result.Approximate)
+}
+
+func TestRegister(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	injector := New()
+	Register(router, injector)
+
+	req := httptest.NewRequest("PUT", "/debug/faults", strings.NewReader(`{"storage_latency": "250ms", "storage_error_rate": 0.5, "allocation_latency": "2s"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"storage_latency": "250ms", "storage_error_rate": 0.5, "allocation_latency": "2s"}`, w.Body.String())
+	assert.Equal(t, Config{StorageLatency: 250 * time.Millisecond, StorageErrorRate: 0.5, AllocationLatency: 2 * time.Second}, injector.Config())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/debug/faults", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"storage_latency":"250ms"`)
+
+	for _, body := range []string{`{"storage_latency": "soon"}`, `{"storage_error_rate": 2}`, `[`} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("PUT", "/debug/faults", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/debug/faults", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"storage_error_rate": 0}`, w.Body.String())
+	assert.Equal(t, Config{}, injector.Config())
+}
@@ -0,0 +1,84 @@
+//go:build faults
+
+package faults
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// faultsBody is the body of PUT /debug/faults and the response of every
+// /debug/faults route. Latencies are durations such as "250ms".
+type faultsBody struct {
+	StorageLatency    string   `json:"storage_latency,omitempty"`
+	StorageErrorRate  float64  `json:"storage_error_rate"`
+	Operations        []string `json:"operations,omitempty"`
+	AllocationLatency string   `json:"allocation_latency,omitempty"`
+}
+
+// Register serves the faults of i on r:
+//   - GET /debug/faults - Get the injected faults
+//   - PUT /debug/faults - Replace the injected faults
+//   - DELETE /debug/faults - Stop injecting faults
+func Register(r gin.IRoutes, i *Injector) {
+	r.GET("/debug/faults", func(c *gin.Context) {
+		c.JSON(http.StatusOK, body(i.Config()))
+	})
+	r.PUT("/debug/faults", func(c *gin.Context) {
+		var b faultsBody
+		if err := c.ShouldBindJSON(&b); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+			return
+		}
+		cfg, err := b.config()
+		if err == nil {
+			err = i.Set(cfg)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, body(i.Config()))
+	})
+	r.DELETE("/debug/faults", func(c *gin.Context) {
+		i.Reset()
+		c.JSON(http.StatusOK, body(i.Config()))
+	})
+}
+
+func (b faultsBody) config() (Config, error) {
+	c := Config{StorageErrorRate: b.StorageErrorRate, Operations: b.Operations}
+	var err error
+	if c.StorageLatency, err = parseDuration("storage_latency", b.StorageLatency); err != nil {
+		return Config{}, err
+	}
+	if c.AllocationLatency, err = parseDuration("allocation_latency", b.AllocationLatency); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+func parseDuration(field, v string) (time.Duration, error) {
+	if v == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", field, v)
+	}
+	return d, nil
+}
+
+func body(c Config) faultsBody {
+	b := faultsBody{StorageErrorRate: c.StorageErrorRate, Operations: c.Operations}
+	if c.StorageLatency > 0 {
+		b.StorageLatency = c.StorageLatency.String()
+	}
+	if c.AllocationLatency > 0 {
+		b.AllocationLatency = c.AllocationLatency.String()
+	}
+	return b
+}
@@ -0,0 +1,286 @@
+//go:build faults
+
+package faults
+
+import (
+	"io"
+	"time"
+
+	"github.com/n-th/gymshark/internal/storage"
+)
+
+// faultyStorage applies the injected storage faults before every operation
+// but Close.
+type faultyStorage struct {
+	next   storage.Storage
+	faults *Injector
+}
+
+// operations lists the storage operations faults can be injected into.
+var operations = map[string]bool{
+	"StoreAllocation":         true,
+	"StoreAllocationInput":    true,
+	"StoreAllocations":        true,
+	"GetRecentAllocations":    true,
+	"CountAllocations":        true,
+	"GetAllocationByQuantity": true,
+	"GetCachedAllocation":     true,
+	"CacheAllocation":         true,
+	"GetAllocationsByOrderID": true,
+	"SearchAllocations":       true,
+	"ExportAllocations":       true,
+	"DeleteAllocation":        true,
+	"RestoreAllocation":       true,
+	"DeleteOlderThan":         true,
+	"DeleteAllButNewest":      true,
+	"RecordProfileVersion":    true,
+	"GetProfileVersions":      true,
+	"RecordAudit":             true,
+	"GetAuditEntries":         true,
+	"PinAllocation":           true,
+	"UnpinAllocation":         true,
+	"GetPins":                 true,
+	"SetProfileDefaults":      true,
+	"DeleteProfileDefaults":   true,
+	"GetProfileDefaults":      true,
+	"GetAlgorithmStats":       true,
+	"AggregateWaste":          true,
+	"PackUsage":               true,
+	"TopQuantities":           true,
+	"RecordUsage":             true,
+	"GetUsage":                true,
+	"Backup":                  true,
+}
+
+// Storage returns s with the storage faults of i applied.
+func Storage(s storage.Storage, i *Injector) storage.Storage {
+	return &faultyStorage{next: s, faults: i}
+}
+
+func (s *faultyStorage) StoreAllocation(quantity int, packs map[int]int, total int) error {
+	if err := s.faults.storage("StoreAllocation"); err != nil {
+		return err
+	}
+	return s.next.StoreAllocation(quantity, packs, total)
+}
+
+func (s *faultyStorage) StoreAllocationInput(in storage.AllocationInput) error {
+	if err := s.faults.storage("StoreAllocationInput"); err != nil {
+		return err
+	}
+	return s.next.StoreAllocationInput(in)
+}
+
+func (s *faultyStorage) StoreAllocations(ins []storage.AllocationInput) error {
+	if err := s.faults.storage("StoreAllocations"); err != nil {
+		return err
+	}
+	return s.next.StoreAllocations(ins)
+}
+
+func (s *faultyStorage) GetRecentAllocations(limit int, includeDeleted bool) ([]storage.Allocation, error) {
+	if err := s.faults.storage("GetRecentAllocations"); err != nil {
+		return nil, err
+	}
+	return s.next.GetRecentAllocations(limit, includeDeleted)
+}
+
+func (s *faultyStorage) CountAllocations(f storage.AllocationFilter) (int, error) {
+	if err := s.faults.storage("CountAllocations"); err != nil {
+		return 0, err
+	}
+	return s.next.CountAllocations(f)
+}
+
+func (s *faultyStorage) GetAllocationByQuantity(quantity int) (*storage.Allocation, error) {
+	if err := s.faults.storage("GetAllocationByQuantity"); err != nil {
+		return nil, err
+	}
+	return s.next.GetAllocationByQuantity(quantity)
+}
+
+func (s *faultyStorage) GetCachedAllocation(profile string, quantity int) (*storage.Allocation, error) {
+	if err := s.faults.storage("GetCachedAllocation"); err != nil {
+		return nil, err
+	}
+	return s.next.GetCachedAllocation(profile, quantity)
+}
+
+func (s *faultyStorage) CacheAllocation(a storage.Allocation) error {
+	if err := s.faults.storage("CacheAllocation"); err != nil {
+		return err
+	}
+	return s.next.CacheAllocation(a)
+}
+
+func (s *faultyStorage) GetAllocationsByOrderID(orderID string) ([]storage.Allocation, error) {
+	if err := s.faults.storage("GetAllocationsByOrderID"); err != nil {
+		return nil, err
+	}
+	return s.next.GetAllocationsByOrderID(orderID)
+}
+
+func (s *faultyStorage) SearchAllocations(f storage.AllocationFilter) ([]storage.Allocation, int, error) {
+	if err := s.faults.storage("SearchAllocations"); err != nil {
+		return nil, 0, err
+	}
+	return s.next.SearchAllocations(f)
+}
+
+func (s *faultyStorage) ExportAllocations(from, to time.Time, fn func(storage.Allocation) error) error {
+	if err := s.faults.storage("ExportAllocations"); err != nil {
+		return err
+	}
+	return s.next.ExportAllocations(from, to, fn)
+}
+
+func (s *faultyStorage) DeleteAllocation(id int64) (bool, error) {
+	if err := s.faults.storage("DeleteAllocation"); err != nil {
+		return false, err
+	}
+	return s.next.DeleteAllocation(id)
+}
+
+func (s *faultyStorage) RestoreAllocation(id int64) (bool, error) {
+	if err := s.faults.storage("RestoreAllocation"); err != nil {
+		return false, err
+	}
+	return s.next.RestoreAllocation(id)
+}
+
+func (s *faultyStorage) DeleteOlderThan(t time.Time) (int64, error) {
+	if err := s.faults.storage("DeleteOlderThan"); err != nil {
+		return 0, err
+	}
+	return s.next.DeleteOlderThan(t)
+}
+
+func (s *faultyStorage) DeleteAllButNewest(n int) (int64, error) {
+	if err := s.faults.storage("DeleteAllButNewest"); err != nil {
+		return 0, err
+	}
+	return s.next.DeleteAllButNewest(n)
+}
+
+func (s *faultyStorage) RecordProfileVersion(name string, packSizes []int) (storage.ProfileVersion, error) {
+	if err := s.faults.storage("RecordProfileVersion"); err != nil {
+		return storage.ProfileVersion{}, err
+	}
+	return s.next.RecordProfileVersion(name, packSizes)
+}
+
+func (s *faultyStorage) GetProfileVersions(name string) ([]storage.ProfileVersion, error) {
+	if err := s.faults.storage("GetProfileVersions"); err != nil {
+		return nil, err
+	}
+	return s.next.GetProfileVersions(name)
+}
+
+func (s *faultyStorage) RecordAudit(e storage.AuditEntry) error {
+	if err := s.faults.storage("RecordAudit"); err != nil {
+		return err
+	}
+	return s.next.RecordAudit(e)
+}
+
+func (s *faultyStorage) GetAuditEntries(f storage.AuditFilter) ([]storage.AuditEntry, error) {
+	if err := s.faults.storage("GetAuditEntries"); err != nil {
+		return nil, err
+	}
+	return s.next.GetAuditEntries(f)
+}
+
+func (s *faultyStorage) PinAllocation(p storage.Pin) error {
+	if err := s.faults.storage("PinAllocation"); err != nil {
+		return err
+	}
+	return s.next.PinAllocation(p)
+}
+
+func (s *faultyStorage) UnpinAllocation(profile string, quantity int) (bool, error) {
+	if err := s.faults.storage("UnpinAllocation"); err != nil {
+		return false, err
+	}
+	return s.next.UnpinAllocation(profile, quantity)
+}
+
+func (s *faultyStorage) GetPins() ([]storage.Pin, error) {
+	if err := s.faults.storage("GetPins"); err != nil {
+		return nil, err
+	}
+	return s.next.GetPins()
+}
+
+func (s *faultyStorage) SetProfileDefaults(d storage.ProfileDefaults) error {
+	if err := s.faults.storage("SetProfileDefaults"); err != nil {
+		return err
+	}
+	return s.next.SetProfileDefaults(d)
+}
+
+func (s *faultyStorage) DeleteProfileDefaults(profile string) (bool, error) {
+	if err := s.faults.storage("DeleteProfileDefaults"); err != nil {
+		return false, err
+	}
+	return s.next.DeleteProfileDefaults(profile)
+}
+
+func (s *faultyStorage) GetProfileDefaults() ([]storage.ProfileDefaults, error) {
+	if err := s.faults.storage("GetProfileDefaults"); err != nil {
+		return nil, err
+	}
+	return s.next.GetProfileDefaults()
+}
+
+func (s *faultyStorage) GetAlgorithmStats(from, to time.Time) ([]storage.AlgorithmStats, error) {
+	if err := s.faults.storage("GetAlgorithmStats"); err != nil {
+		return nil, err
+	}
+	return s.next.GetAlgorithmStats(from, to)
+}
+
+func (s *faultyStorage) AggregateWaste(groupBy string, from, to time.Time) ([]storage.WasteStats, error) {
+	if err := s.faults.storage("AggregateWaste"); err != nil {
+		return nil, err
+	}
+	return s.next.AggregateWaste(groupBy, from, to)
+}
+
+func (s *faultyStorage) PackUsage(from, to time.Time) ([]storage.PackUsage, error) {
+	if err := s.faults.storage("PackUsage"); err != nil {
+		return nil, err
+	}
+	return s.next.PackUsage(from, to)
+}
+
+func (s *faultyStorage) TopQuantities(since time.Time, n int) ([]storage.QuantityCount, error) {
+	if err := s.faults.storage("TopQuantities"); err != nil {
+		return nil, err
+	}
+	return s.next.TopQuantities(since, n)
+}
+
+func (s *faultyStorage) RecordUsage(client string, at time.Time, compute time.Duration) error {
+	if err := s.faults.storage("RecordUsage"); err != nil {
+		return err
+	}
+	return s.next.RecordUsage(client, at, compute)
+}
+
+func (s *faultyStorage) GetUsage(at time.Time, client string) ([]storage.Usage, error) {
+	if err := s.faults.storage("GetUsage"); err != nil {
+		return nil, err
+	}
+	return s.next.GetUsage(at, client)
+}
+
+func (s *faultyStorage) Backup(w io.Writer) error {
+	if err := s.faults.storage("Backup"); err != nil {
+		return err
+	}
+	return s.next.Backup(w)
+}
+
+func (s *faultyStorage) Close() error {
+	return s.next.Close()
+}
@@ -0,0 +1,29 @@
+//go:build faults
+
+package faults
+
+import (
+	"context"
+
+	"github.com/n-th/gymshark/internal/allocator"
+)
+
+// WrapStrategies re-registers every registered allocation strategy so it
+// waits out the injected allocation latency before computing. Constrained
+// calculations call branch-and-bound directly and are not slowed down.
+// Strategies registered afterwards are not wrapped.
+func WrapStrategies(i *Injector) error {
+	for _, name := range allocator.Strategies() {
+		inner, err := allocator.LookupStrategy(name)
+		if err != nil {
+			return err
+		}
+		allocator.RegisterStrategy(name, allocator.StrategyFunc(func(ctx context.Context, quantity int, sizes []int) (allocator.Result, error) {
+			if err := i.allocation(ctx); err != nil {
+				return allocator.Result{}, err
+			}
+			return inner.Allocate(ctx, quantity, sizes)
+		}))
+	}
+	return nil
+}
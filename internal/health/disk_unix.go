@@ -0,0 +1,23 @@
+//go:build unix
+
+package health
+
+import (
+	"errors"
+	"syscall"
+)
+
+// diskUsage returns the percentage of the filesystem holding path in use, as
+// df reports it: blocks reserved for root count as neither used nor free.
+func diskUsage(path string) (float64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	used := uint64(st.Blocks) - uint64(st.Bfree)
+	usable := used + uint64(st.Bavail)
+	if usable == 0 {
+		return 0, errors.New("filesystem reports no blocks")
+	}
+	return float64(used) / float64(usable) * 100, nil
+}
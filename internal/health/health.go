@@ -0,0 +1,214 @@
+// Package health runs readiness probes against the service's dependencies,
+// such as how long a database query takes and how full the data volume is,
+// and rates each against warning and critical thresholds.
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Status rates a probe's measurement.
+type Status string
+
+const (
+	StatusOK       Status = "ok"
+	StatusWarning  Status = "warning"
+	StatusCritical Status = "critical"
+)
+
+// severity orders statuses from best to worst.
+func (s Status) severity() int {
+	switch s {
+	case StatusOK:
+		return 0
+	case StatusWarning:
+		return 1
+	}
+	return 2
+}
+
+// Thresholds rate a measurement: at or above Warning it is a warning, at or
+// above Critical it is critical. A zero threshold is never reached.
+type Thresholds struct {
+	Warning  float64
+	Critical float64
+}
+
+func (t Thresholds) rate(value float64) Status {
+	switch {
+	case t.Critical > 0 && value >= t.Critical:
+		return StatusCritical
+	case t.Warning > 0 && value >= t.Warning:
+		return StatusWarning
+	}
+	return StatusOK
+}
+
+// Result is the outcome of one probe.
+type Result struct {
+	Status Status
+	// Value is what the probe measured, in Unit, e.g. milliseconds or percent.
+	Value float64
+	Unit  string
+	Thresholds
+	// Error is set when the probe could not measure, which is critical.
+	Error string
+}
+
+// Probe checks one dependency.
+type Probe interface {
+	Name() string
+	Check(ctx context.Context) Result
+}
+
+// Report is the outcome of every probe. Status is the worst of them.
+type Report struct {
+	Status Status
+	Checks map[string]Result
+}
+
+// Checker runs a set of probes.
+type Checker struct {
+	probes  []Probe
+	timeout time.Duration
+}
+
+// NewChecker returns a checker running probes concurrently. A probe that has
+// not finished within timeout is critical; zero waits for every probe.
+func NewChecker(timeout time.Duration, probes ...Probe) *Checker {
+	return &Checker{probes: probes, timeout: timeout}
+}
+
+// Check runs every probe and reports their results.
+func (c *Checker) Check(ctx context.Context) Report {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(c.probes))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, p := range c.probes {
+		wg.Add(1)
+		go func(p Probe) {
+			defer wg.Done()
+			result := run(ctx, p)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[p.Name()] = result
+			if result.Status.severity() > report.Status.severity() {
+				report.Status = result.Status
+			}
+		}(p)
+	}
+	wg.Wait()
+	return report
+}
+
+// run checks p, giving up when ctx is done. A probe that does not return is
+// left running; its result is discarded.
+func run(ctx context.Context, p Probe) Result {
+	done := make(chan Result, 1)
+	go func() {
+		done <- p.Check(ctx)
+	}()
+	select {
+	case result := <-done:
+		return result
+	case <-ctx.Done():
+		return Result{Status: StatusCritical, Error: "no response: " + ctx.Err().Error()}
+	}
+}
+
+// latencyProbe times a query.
+type latencyProbe struct {
+	name       string
+	query      func(ctx context.Context) error
+	thresholds Thresholds
+}
+
+// Latency returns a probe timing query, e.g. a read from the database, and
+// rating its duration in milliseconds against warning and critical. A query
+// that fails is critical.
+func Latency(name string, query func(ctx context.Context) error, warning, critical time.Duration) Probe {
+	return &latencyProbe{name: name, query: query, thresholds: Thresholds{Warning: milliseconds(warning), Critical: milliseconds(critical)}}
+}
+
+func (p *latencyProbe) Name() string { return p.name }
+
+func (p *latencyProbe) Check(ctx context.Context) Result {
+	start := time.Now()
+	err := p.query(ctx)
+	result := Result{Value: milliseconds(time.Since(start)), Unit: "ms", Thresholds: p.thresholds}
+	if err != nil {
+		result.Status = StatusCritical
+		result.Error = err.Error()
+		return result
+	}
+	result.Status = p.thresholds.rate(result.Value)
+	return result
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// diskProbe rates how full a filesystem is.
+type diskProbe struct {
+	name       string
+	path       string
+	thresholds Thresholds
+}
+
+// Disk returns a probe rating the percentage used of the filesystem holding
+// path against warning and critical percentages.
+func Disk(name, path string, warning, critical float64) Probe {
+	return &diskProbe{name: name, path: path, thresholds: Thresholds{Warning: warning, Critical: critical}}
+}
+
+func (p *diskProbe) Name() string { return p.name }
+
+func (p *diskProbe) Check(context.Context) Result {
+	result := Result{Unit: "percent", Thresholds: p.thresholds}
+	used, err := diskUsage(p.path)
+	if err != nil {
+		result.Status = StatusCritical
+		result.Error = err.Error()
+		return result
+	}
+	result.Value = used
+	result.Status = p.thresholds.rate(used)
+	return result
+}
+
+// Degradable is a dependency the service works without while it is failing,
+// such as a cache behind a circuit breaker.
+type Degradable interface {
+	// Degraded returns why the dependency is not used, or nil.
+	Degraded() error
+}
+
+// degradedProbe reports whether a dependency is degraded.
+type degradedProbe struct {
+	name string
+	dep  Degradable
+}
+
+// Degraded returns a probe that is a warning while dep is degraded, so the
+// service stays ready but is reported as running without it.
+func Degraded(name string, dep Degradable) Probe {
+	return &degradedProbe{name: name, dep: dep}
+}
+
+func (p *degradedProbe) Name() string { return p.name }
+
+func (p *degradedProbe) Check(context.Context) Result {
+	if err := p.dep.Degraded(); err != nil {
+		return Result{Status: StatusWarning, Error: err.Error()}
+	}
+	return Result{Status: StatusOK}
+}
@@ -0,0 +1,110 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// probeFunc is a probe returning a fixed result, after an optional delay.
+type probeFunc struct {
+	name   string
+	delay  time.Duration
+	result Result
+}
+
+func (p probeFunc) Name() string { return p.name }
+
+func (p probeFunc) Check(context.Context) Result {
+	time.Sleep(p.delay)
+	return p.result
+}
+
+func TestThresholds(t *testing.T) {
+	th := Thresholds{Warning: 80, Critical: 95}
+	assert.Equal(t, StatusOK, th.rate(79.9))
+	assert.Equal(t, StatusWarning, th.rate(80))
+	assert.Equal(t, StatusCritical, th.rate(95))
+	assert.Equal(t, StatusOK, Thresholds{}.rate(100))
+	assert.Equal(t, StatusWarning, Thresholds{Warning: 1}.rate(100))
+}
+
+func TestLatency(t *testing.T) {
+	ctx := context.Background()
+	quick := func(context.Context) error { return nil }
+	slow := func(context.Context) error {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}
+
+	result := Latency("database", quick, 10*time.Millisecond, time.Second).Check(ctx)
+	assert.Equal(t, StatusOK, result.Status)
+	assert.Equal(t, "ms", result.Unit)
+	assert.Equal(t, Thresholds{Warning: 10, Critical: 1000}, result.Thresholds)
+
+	result = Latency("database", slow, 10*time.Millisecond, time.Second).Check(ctx)
+	assert.Equal(t, StatusWarning, result.Status)
+	assert.GreaterOrEqual(t, result.Value, 20.0)
+
+	result = Latency("database", slow, 0, 10*time.Millisecond).Check(ctx)
+	assert.Equal(t, StatusCritical, result.Status)
+
+	result = Latency("database", func(context.Context) error { return errors.New("database is locked") }, 0, time.Second).Check(ctx)
+	assert.Equal(t, StatusCritical, result.Status)
+	assert.Equal(t, "database is locked", result.Error)
+}
+
+func TestDisk(t *testing.T) {
+	ctx := context.Background()
+	result := Disk("disk", t.TempDir(), 0, 100).Check(ctx)
+	assert.Empty(t, result.Error)
+	assert.Equal(t, "percent", result.Unit)
+	assert.True(t, result.Value >= 0 && result.Value <= 100)
+
+	// Any volume in use is at or above a tiny threshold
+	result = Disk("disk", t.TempDir(), 0, 1e-9).Check(ctx)
+	assert.Equal(t, StatusCritical, result.Status)
+
+	result = Disk("disk", "/does/not/exist", 80, 95).Check(ctx)
+	assert.Equal(t, StatusCritical, result.Status)
+	assert.NotEmpty(t, result.Error)
+}
+
+// degradable is a dependency degraded while err is set.
+type degradable struct{ err error }
+
+func (d *degradable) Degraded() error { return d.err }
+
+func TestDegraded(t *testing.T) {
+	dep := &degradable{}
+	probe := Degraded("cache", dep)
+	assert.Equal(t, "cache", probe.Name())
+	assert.Equal(t, Result{Status: StatusOK}, probe.Check(context.Background()))
+
+	dep.err = errors.New("circuit open")
+	assert.Equal(t, Result{Status: StatusWarning, Error: "circuit open"}, probe.Check(context.Background()))
+}
+
+func TestChecker(t *testing.T) {
+	ctx := context.Background()
+	ok := probeFunc{name: "a", result: Result{Status: StatusOK}}
+	warning := probeFunc{name: "b", result: Result{Status: StatusWarning}}
+	hung := probeFunc{name: "c", delay: time.Second, result: Result{Status: StatusOK}}
+
+	report := NewChecker(0).Check(ctx)
+	assert.Equal(t, Report{Status: StatusOK, Checks: map[string]Result{}}, report)
+
+	report = NewChecker(0, ok, warning).Check(ctx)
+	assert.Equal(t, StatusWarning, report.Status)
+	assert.Equal(t, map[string]Result{"a": ok.result, "b": warning.result}, report.Checks)
+
+	// A probe that does not answer in time is critical
+	report = NewChecker(10*time.Millisecond, ok, hung).Check(ctx)
+	assert.Equal(t, StatusCritical, report.Status)
+	assert.Equal(t, StatusOK, report.Checks["a"].Status)
+	assert.Equal(t, StatusCritical, report.Checks["c"].Status)
+	assert.Equal(t, "no response: context deadline exceeded", report.Checks["c"].Error)
+}
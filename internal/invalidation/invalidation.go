@@ -0,0 +1,80 @@
+// Package invalidation propagates cache purges, pack-size profile updates and
+// pin changes between replicas of the service over a pub/sub channel, so
+// every instance drops stale results as soon as one of them changes the pack
+// sizes.
+package invalidation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidEvent = errors.New("invalid invalidation event")
+
+// EventType identifies what an Event invalidates.
+type EventType string
+
+const (
+	// EventPurge drops every cached outcome.
+	EventPurge EventType = "purge"
+	// EventProfile replaces the pack sizes of a profile and drops cached
+	// outcomes computed with the old sizes.
+	EventProfile EventType = "profile"
+	// EventPins reloads pinned allocations from storage.
+	EventPins EventType = "pins"
+	// EventProfileDefaults reloads the profiles' calculation defaults from
+	// storage.
+	EventProfileDefaults EventType = "profile_defaults"
+)
+
+// Event is a single invalidation message.
+type Event struct {
+	Type EventType `json:"type"`
+	// Profile and PackSizes are set for EventProfile.
+	Profile   string `json:"profile,omitempty"`
+	PackSizes []int  `json:"pack_sizes,omitempty"`
+	// Origin identifies the publishing instance, so it can ignore its own events.
+	Origin string `json:"origin"`
+}
+
+// Validate checks that the event is well formed.
+func (e Event) Validate() error {
+	switch e.Type {
+	case EventPurge, EventPins, EventProfileDefaults:
+		return nil
+	case EventProfile:
+		if e.Profile == "" || len(e.PackSizes) == 0 {
+			return fmt.Errorf("%w: profile events need a profile and pack sizes", ErrInvalidEvent)
+		}
+		for _, size := range e.PackSizes {
+			if size <= 0 {
+				return fmt.Errorf("%w: pack size %d must be positive", ErrInvalidEvent, size)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: unknown type %q", ErrInvalidEvent, e.Type)
+}
+
+// Bus publishes invalidation events to, and receives them from, every
+// instance sharing the channel, including the publisher itself.
+type Bus interface {
+	// Publish sends an event to every subscriber.
+	Publish(ctx context.Context, e Event) error
+	// Subscribe calls fn for every event received until ctx is done or the
+	// subscription fails. Malformed events are skipped.
+	Subscribe(ctx context.Context, fn func(Event)) error
+	Close() error
+}
+
+// NewOrigin returns a random identifier for this instance.
+func NewOrigin() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("invalidation: read random origin: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,85 @@
+package invalidation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultChannel is the Redis channel used when none is configured.
+const DefaultChannel = "gymshark:invalidation"
+
+// RedisBus is a Bus backed by Redis pub/sub.
+type RedisBus struct {
+	client  *redis.Client
+	channel string
+}
+
+// NewRedisBus connects to the Redis server at url, e.g.
+// "redis://localhost:6379/0", and checks that it is reachable.
+func NewRedisBus(ctx context.Context, url, channel string) (*RedisBus, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("parse redis url: %w", err)
+	}
+	if channel == "" {
+		channel = DefaultChannel
+	}
+	client := redis.NewClient(opts)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connect to redis: %w", err)
+	}
+	return &RedisBus{client: client, channel: channel}, nil
+}
+
+// Publish sends e to every subscriber of the channel.
+func (b *RedisBus) Publish(ctx context.Context, e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, b.channel, payload).Err()
+}
+
+// Subscribe delivers events until ctx is done. The Redis client reconnects
+// on its own; events published while disconnected are lost.
+func (b *RedisBus) Subscribe(ctx context.Context, fn func(Event)) error {
+	sub := b.client.Subscribe(ctx, b.channel)
+	defer sub.Close()
+	// Wait for the subscription to be confirmed so no event published
+	// after Subscribe returns can be missed.
+	if _, err := sub.Receive(ctx); err != nil {
+		return fmt.Errorf("subscribe to %s: %w", b.channel, err)
+	}
+
+	messages := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-messages:
+			if !ok {
+				return fmt.Errorf("subscription to %s closed", b.channel)
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+				log.Printf("Ignoring malformed invalidation event: %v", err)
+				continue
+			}
+			if err := e.Validate(); err != nil {
+				log.Printf("Ignoring invalidation event: %v", err)
+				continue
+			}
+			fn(e)
+		}
+	}
+}
+
+// Close closes the Redis connection.
+func (b *RedisBus) Close() error {
+	return b.client.Close()
+}
@@ -0,0 +1,88 @@
+package invalidation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		event Event
+		valid bool
+	}{
+		{"purge", Event{Type: EventPurge}, true},
+		{"pins", Event{Type: EventPins}, true},
+		{"profile defaults", Event{Type: EventProfileDefaults}, true},
+		{"profile", Event{Type: EventProfile, Profile: "apparel", PackSizes: []int{250, 500}}, true},
+		{"profile without name", Event{Type: EventProfile, PackSizes: []int{250}}, false},
+		{"profile without sizes", Event{Type: EventProfile, Profile: "apparel"}, false},
+		{"non-positive size", Event{Type: EventProfile, Profile: "apparel", PackSizes: []int{0}}, false},
+		{"unknown type", Event{Type: "reload"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.event.Validate()
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidEvent)
+			}
+		})
+	}
+}
+
+func TestRedisBus(t *testing.T) {
+	server := miniredis.RunT(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	bus, err := NewRedisBus(ctx, "redis://"+server.Addr(), "")
+	if err != nil {
+		t.Fatalf("new bus: %v", err)
+	}
+	defer bus.Close()
+
+	received := make(chan Event, 4)
+	subCtx, stop := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() { done <- bus.Subscribe(subCtx, func(e Event) { received <- e }) }()
+
+	// Wait until the subscription is registered before publishing
+	for server.PubSubNumSub(DefaultChannel)[DefaultChannel] == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Malformed and invalid messages are skipped
+	server.Publish(DefaultChannel, "not json")
+	server.Publish(DefaultChannel, `{"type":"profile"}`)
+
+	want := Event{Type: EventProfile, Profile: "apparel", PackSizes: []int{500, 250}, Origin: "a"}
+	assert.NoError(t, bus.Publish(ctx, want))
+	select {
+	case got := <-received:
+		assert.Equal(t, want, got)
+	case <-ctx.Done():
+		t.Fatal("event not received")
+	}
+
+	stop()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	assert.Empty(t, received)
+}
+
+func TestNewRedisBusUnreachable(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := NewRedisBus(ctx, "redis://127.0.0.1:1", "")
+	assert.Error(t, err)
+
+	_, err = NewRedisBus(ctx, "not a url", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,157 @@
+// Package metrics exposes Prometheus metrics for the pack allocation service,
+// including domain metrics on how efficiently orders are fulfilled.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/n-th/gymshark/internal/allocator"
+)
+
+// Histogram buckets. Waste is in the profile's unit (grams for the weight
+// profile); a zero bucket separates exact fulfilment from any waste at all.
+// Latency is in seconds, from interactive quantities answered in a
+// millisecond to batch ones running up to the hard deadline. Storage calls
+// range from cached reads in microseconds to backups and exports.
+var (
+	WasteBuckets   = []float64{0, 1, 5, 10, 25, 50, 100, 250, 500, 1000, 5000}
+	PackBuckets    = []float64{1, 2, 3, 5, 10, 25, 50, 100, 250, 1000, 10000}
+	LatencyBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+	StorageBuckets = []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 5, 30}
+)
+
+// Metrics holds the service's collectors on a private registry.
+// It implements allocator.Observer, api.LatencyObserver and
+// storage.Observer.
+type Metrics struct {
+	registry      *prometheus.Registry
+	waste         *prometheus.HistogramVec
+	packs         *prometheus.HistogramVec
+	latency       *prometheus.HistogramVec
+	storage       *prometheus.HistogramVec
+	storageErrors *prometheus.CounterVec
+	handler       http.Handler
+}
+
+// New creates the metrics for alloc and registers itself as its observer.
+// Go runtime and process metrics are included.
+func New(alloc *allocator.Allocator) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		waste: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "gymshark",
+			Name:      "allocation_waste",
+			Help:      "Items shipped beyond the ordered quantity per allocation.",
+			Buckets:   WasteBuckets,
+		}, []string{"profile"}),
+		packs: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "gymshark",
+			Name:      "allocation_packs",
+			Help:      "Packs shipped per allocation.",
+			Buckets:   PackBuckets,
+		}, []string{"profile"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "gymshark",
+			Name:      "calculation_duration_seconds",
+			Help:      "Duration of calculation requests by quantity magnitude.",
+			Buckets:   LatencyBuckets,
+		}, []string{"method", "route", "quantity_bucket", "status"}),
+		storage: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "gymshark",
+			Name:      "storage_duration_seconds",
+			Help:      "Duration of storage calls by method.",
+			Buckets:   StorageBuckets,
+		}, []string{"method"}),
+		storageErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gymshark",
+			Name:      "storage_errors_total",
+			Help:      "Storage calls that failed, by method.",
+		}, []string{"method"}),
+	}
+	m.registry.MustRegister(
+		m.waste,
+		m.packs,
+		m.latency,
+		m.storage,
+		m.storageErrors,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "gymshark",
+			Name:      "cached_quantities",
+			Help:      "Distinct quantities held in the negative cache.",
+		}, func() float64 {
+			return float64(alloc.CachedQuantities())
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "gymshark",
+			Name:      "calculations_in_flight",
+			Help:      "Calculations running under admission control.",
+		}, func() float64 {
+			return float64(alloc.AdmissionStats().InFlight)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "gymshark",
+			Name:      "calculations_queued",
+			Help:      "Calculations waiting for admission.",
+		}, func() float64 {
+			return float64(alloc.AdmissionStats().Queued)
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: "gymshark",
+			Name:      "calculations_shed_total",
+			Help:      "Calculations rejected by admission control.",
+		}, func() float64 {
+			return float64(alloc.AdmissionStats().Shed)
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: "gymshark",
+			Name:      "shadow_comparisons_total",
+			Help:      "Shadow strategy results compared with the results answered.",
+		}, func() float64 {
+			return float64(alloc.ShadowStats().Comparisons)
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: "gymshark",
+			Name:      "shadow_mismatches_total",
+			Help:      "Shadow strategy results that differed from the results answered.",
+		}, func() float64 {
+			return float64(alloc.ShadowStats().Mismatches)
+		}),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	m.handler = promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+	alloc.SetObserver(m)
+	return m
+}
+
+// ObserveAllocation records the waste and pack count of an allocation.
+func (m *Metrics) ObserveAllocation(profile string, o allocator.SimulationOutcome) {
+	m.waste.WithLabelValues(profile).Observe(float64(o.Waste))
+	m.packs.WithLabelValues(profile).Observe(float64(o.PackCount))
+}
+
+// ObserveLatency records the duration of a calculation request.
+func (m *Metrics) ObserveLatency(method, route, bucket string, status int, d time.Duration) {
+	m.latency.WithLabelValues(method, route, bucket, strconv.Itoa(status)).Observe(d.Seconds())
+}
+
+// ObserveStorage records the duration of a storage call, and counts it when
+// it failed.
+func (m *Metrics) ObserveStorage(method string, d time.Duration, err error) {
+	m.storage.WithLabelValues(method).Observe(d.Seconds())
+	if err != nil {
+		m.storageErrors.WithLabelValues(method).Inc()
+	}
+}
+
+// ServeHTTP serves the metrics, in the OpenMetrics format when the scraper
+// asks for it and the Prometheus text format otherwise.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.handler.ServeHTTP(w, r)
+}
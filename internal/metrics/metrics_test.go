@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/n-th/gymshark/internal/allocator"
+)
+
+func scrape(t *testing.T, m *Metrics, accept string) (string, string) {
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	body, err := io.ReadAll(w.Body)
+	assert.NoError(t, err)
+	return string(body), w.Header().Get("Content-Type")
+}
+
+func TestAllocationMetrics(t *testing.T) {
+	alloc := allocator.NewAllocator([]int{250, 500, 1000}, nil)
+	alloc.SetNegativeCacheTTL(time.Minute)
+	m := New(alloc)
+
+	ctx := context.Background()
+	for _, q := range []int{250, 251, 1000} {
+		_, err := alloc.Allocate(ctx, allocator.Request{Quantity: q, Strategy: "dp"})
+		assert.NoError(t, err)
+	}
+
+	body, contentType := scrape(t, m, "")
+	assert.Contains(t, contentType, "text/plain")
+	// 250 and 1000 are exact; 251 ships 500, wasting 249
+	assert.Contains(t, body, `gymshark_allocation_waste_bucket{profile="default",le="0"} 2`)
+	assert.Contains(t, body, `gymshark_allocation_waste_bucket{profile="default",le="100"} 2`)
+	assert.Contains(t, body, `gymshark_allocation_waste_bucket{profile="default",le="250"} 3`)
+	assert.Contains(t, body, `gymshark_allocation_waste_sum{profile="default"} 249`)
+	assert.Contains(t, body, `gymshark_allocation_packs_bucket{profile="default",le="1"} 3`)
+	assert.Contains(t, body, `gymshark_allocation_packs_count{profile="default"} 3`)
+	assert.Contains(t, body, "gymshark_cached_quantities 0")
+	assert.Contains(t, body, "gymshark_shadow_mismatches_total 0")
+	assert.Contains(t, body, "go_goroutines")
+
+	body, contentType = scrape(t, m, "application/openmetrics-text; version=1.0.0")
+	assert.Contains(t, contentType, "application/openmetrics-text")
+	assert.Contains(t, body, `gymshark_allocation_waste_count{profile="default"} 3`)
+	assert.Contains(t, body, "# EOF")
+}
+
+func TestCachedQuantitiesGauge(t *testing.T) {
+	alloc := allocator.NewAllocator([]int{10, 20}, nil)
+	alloc.SetNegativeCacheTTL(time.Minute)
+	m := New(alloc)
+
+	// Infeasible outcomes are cached once per quantity
+	allocator.RegisterStrategy("exact-test", allocator.StrategyFunc(func(ctx context.Context, quantity int, sizes []int) (allocator.Result, error) {
+		return allocator.Result{}, allocator.ErrNoCombination
+	}))
+	for _, q := range []int{5, 15, 5} {
+		_, err := alloc.Allocate(context.Background(), allocator.Request{Quantity: q, Strategy: "exact-test"})
+		assert.ErrorIs(t, err, allocator.ErrNoCombination)
+	}
+
+	body, _ := scrape(t, m, "")
+	assert.Contains(t, body, "gymshark_cached_quantities 2")
+}
+
+func TestLatencyMetrics(t *testing.T) {
+	m := New(allocator.NewAllocator([]int{250, 500, 1000}, nil))
+	m.ObserveLatency("GET", "/calculate", "1-100", http.StatusOK, 3*time.Millisecond)
+	m.ObserveLatency("POST", "/calculate/order", "1M+", http.StatusGatewayTimeout, 20*time.Second)
+
+	body, _ := scrape(t, m, "")
+	assert.Contains(t, body, `gymshark_calculation_duration_seconds_bucket{method="GET",quantity_bucket="1-100",route="/calculate",status="200",le="0.005"} 1`)
+	assert.Contains(t, body, `gymshark_calculation_duration_seconds_bucket{method="POST",quantity_bucket="1M+",route="/calculate/order",status="504",le="10"} 0`)
+	assert.Contains(t, body, `gymshark_calculation_duration_seconds_count{method="POST",quantity_bucket="1M+",route="/calculate/order",status="504"} 1`)
+}
+
+func TestStorageMetrics(t *testing.T) {
+	m := New(allocator.NewAllocator([]int{250, 500, 1000}, nil))
+	m.ObserveStorage("GetPins", 300*time.Microsecond, nil)
+	m.ObserveStorage("StoreAllocations", 2*time.Second, errors.New("database is locked"))
+	m.ObserveStorage("StoreAllocations", 4*time.Millisecond, nil)
+
+	body, _ := scrape(t, m, "")
+	assert.Contains(t, body, `gymshark_storage_duration_seconds_bucket{method="GetPins",le="0.0005"} 1`)
+	assert.Contains(t, body, `gymshark_storage_duration_seconds_count{method="StoreAllocations"} 2`)
+	assert.Contains(t, body, `gymshark_storage_errors_total{method="StoreAllocations"} 1`)
+	assert.NotContains(t, body, `gymshark_storage_errors_total{method="GetPins"}`)
+}
@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// EmailSender mails alerts through an SMTP server.
+type EmailSender struct {
+	addr string
+	from string
+	to   []string
+	auth smtp.Auth
+	// sendMail is smtp.SendMail, replaced in tests.
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewEmailSender mails alerts from from to every address in to through the
+// SMTP server at addr, host:port, upgrading to TLS when the server offers
+// it. With a username, it authenticates with PLAIN, which net/smtp only
+// allows over TLS or to localhost.
+func NewEmailSender(addr, from string, to []string, username, password string) (*EmailSender, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid smtp address %q: %w", addr, err)
+	}
+	if from == "" || len(to) == 0 {
+		return nil, errors.New("email alerts need a from address and at least one to address")
+	}
+	s := &EmailSender{addr: addr, from: from, to: to, sendMail: smtp.SendMail}
+	if username != "" {
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return s, nil
+}
+
+// Send mails a as a plain text message. The SMTP exchange is not bound by
+// ctx, only by the server's own timeouts.
+func (s *EmailSender) Send(_ context.Context, a Alert) error {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", s.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", a.Subject()))
+	fmt.Fprintf(&msg, "Date: %s\r\n", a.Time.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(a.Text(), "\n", "\r\n"))
+	return s.sendMail(s.addr, s.auth, s.from, s.to, []byte(msg.String()))
+}
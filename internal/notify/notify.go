@@ -0,0 +1,212 @@
+// Package notify alerts operators to anomalous allocations, such as ones
+// wasting much of their quantity or approximated after a timeout, over Slack
+// and email, so pathological pack-size and quantity combinations are caught
+// quickly.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Reason is why an allocation is anomalous.
+type Reason string
+
+const (
+	// ReasonWaste reports an allocation wasting more of its quantity than
+	// the threshold allows.
+	ReasonWaste Reason = "waste"
+	// ReasonApproximate reports an allocation approximated because the
+	// exact search ran out of time, e.g. by falling back to greedy.
+	ReasonApproximate Reason = "approximate"
+)
+
+// Alert describes an anomalous allocation.
+type Alert struct {
+	Reasons  []Reason
+	Time     time.Time
+	Profile  string
+	Quantity int
+	Packs    map[int]int
+	Total    int
+	// Strategy names the strategy that produced the packs.
+	Strategy string
+	OrderID  string
+}
+
+// Waste is how many items the packs hold beyond the quantity.
+func (a Alert) Waste() int {
+	return a.Total - a.Quantity
+}
+
+// WasteRate is the waste as a fraction of the quantity.
+func (a Alert) WasteRate() float64 {
+	if a.Quantity <= 0 {
+		return 0
+	}
+	return float64(a.Waste()) / float64(a.Quantity)
+}
+
+// Subject summarizes the alert in one line.
+func (a Alert) Subject() string {
+	reasons := make([]string, len(a.Reasons))
+	for i, r := range a.Reasons {
+		reasons[i] = string(r)
+	}
+	return fmt.Sprintf("Allocation anomaly (%s): quantity %d of profile %s", strings.Join(reasons, ", "), a.Quantity, a.Profile)
+}
+
+// Text details the alert, one fact per line.
+func (a Alert) Text() string {
+	sizes := make([]int, 0, len(a.Packs))
+	for size := range a.Packs {
+		sizes = append(sizes, size)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+	packs := make([]string, len(sizes))
+	for i, size := range sizes {
+		packs[i] = fmt.Sprintf("%dx%d", a.Packs[size], size)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Profile: %s\n", a.Profile)
+	fmt.Fprintf(&b, "Quantity: %d\n", a.Quantity)
+	fmt.Fprintf(&b, "Packs: %s (total %d)\n", strings.Join(packs, ", "), a.Total)
+	fmt.Fprintf(&b, "Waste: %d (%.1f%%)\n", a.Waste(), a.WasteRate()*100)
+	fmt.Fprintf(&b, "Strategy: %s\n", a.Strategy)
+	if a.OrderID != "" {
+		fmt.Fprintf(&b, "Order: %s\n", a.OrderID)
+	}
+	fmt.Fprintf(&b, "Time: %s\n", a.Time.UTC().Format(time.RFC3339))
+	return b.String()
+}
+
+// key identifies alerts that repeat one another.
+func (a Alert) key() string {
+	reasons := make([]string, len(a.Reasons))
+	for i, r := range a.Reasons {
+		reasons[i] = string(r)
+	}
+	return fmt.Sprintf("%s/%d/%s", a.Profile, a.Quantity, strings.Join(reasons, ","))
+}
+
+// Sender delivers an alert to one channel.
+type Sender interface {
+	Send(ctx context.Context, a Alert) error
+}
+
+// DefaultBuffer is the number of alerts Notifier queues when none is
+// configured.
+const DefaultBuffer = 100
+
+// sendTimeout bounds each delivery attempt.
+const sendTimeout = 10 * time.Second
+
+// Notifier queues alerts and delivers them to every sender in the
+// background, so that a slow webhook or mail server never delays a request.
+// An alert repeating one sent within the cooldown, for the same profile,
+// quantity and reasons, is suppressed. Alerts are dropped when the queue is
+// full; delivery failures are logged.
+type Notifier struct {
+	senders  []Sender
+	cooldown time.Duration
+	queue    chan Alert
+	done     chan struct{}
+	once     sync.Once
+	dropped  atomic.Int64
+
+	mu   sync.Mutex
+	sent map[string]time.Time
+}
+
+// NewNotifier starts delivering alerts to senders, queueing up to buffer of
+// them (DefaultBuffer if buffer is not positive). A zero cooldown sends
+// every alert.
+func NewNotifier(senders []Sender, cooldown time.Duration, buffer int) *Notifier {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	n := &Notifier{
+		senders:  senders,
+		cooldown: cooldown,
+		queue:    make(chan Alert, buffer),
+		done:     make(chan struct{}),
+		sent:     make(map[string]time.Time),
+	}
+	go n.run()
+	return n
+}
+
+func (n *Notifier) run() {
+	defer close(n.done)
+	for a := range n.queue {
+		for _, s := range n.senders {
+			ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+			err := s.Send(ctx, a)
+			cancel()
+			if err != nil {
+				log.Printf("Failed to send alert %q: %v", a.Subject(), err)
+			}
+		}
+	}
+}
+
+// Notify queues a without blocking, unless it repeats an alert sent within
+// the cooldown. Alerts without a time are stamped with the current one.
+func (n *Notifier) Notify(a Alert) {
+	if a.Time.IsZero() {
+		a.Time = time.Now()
+	}
+	if !n.due(a) {
+		return
+	}
+	select {
+	case n.queue <- a:
+	default:
+		n.dropped.Add(1)
+		log.Printf("Alert queue full, dropping alert %q", a.Subject())
+	}
+}
+
+// due records a as sent unless it repeats an alert sent within the
+// cooldown.
+func (n *Notifier) due(a Alert) bool {
+	if n.cooldown <= 0 {
+		return true
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	key := a.key()
+	if last, ok := n.sent[key]; ok && a.Time.Sub(last) < n.cooldown {
+		return false
+	}
+	// Forget alerts past their cooldown, so that the map does not grow with
+	// every quantity ever alerted on.
+	for k, last := range n.sent {
+		if a.Time.Sub(last) >= n.cooldown {
+			delete(n.sent, k)
+		}
+	}
+	n.sent[key] = a.Time
+	return true
+}
+
+// Dropped returns the number of alerts dropped because the queue was full.
+func (n *Notifier) Dropped() int64 {
+	return n.dropped.Load()
+}
+
+// Close delivers the queued alerts. Notify must not be called afterwards.
+func (n *Notifier) Close() error {
+	n.once.Do(func() {
+		close(n.queue)
+		<-n.done
+	})
+	return nil
+}
@@ -0,0 +1,136 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testAlert = Alert{
+	Reasons:  []Reason{ReasonWaste, ReasonApproximate},
+	Time:     time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC),
+	Profile:  "default",
+	Quantity: 12,
+	Packs:    map[int]int{23: 1},
+	Total:    23,
+	Strategy: "greedy",
+	OrderID:  "ORD-1",
+}
+
+func TestAlertText(t *testing.T) {
+	assert.Equal(t, 11, testAlert.Waste())
+	assert.Equal(t, "Allocation anomaly (waste, approximate): quantity 12 of profile default", testAlert.Subject())
+	assert.Equal(t, "Profile: default\n"+
+		"Quantity: 12\n"+
+		"Packs: 1x23 (total 23)\n"+
+		"Waste: 11 (91.7%)\n"+
+		"Strategy: greedy\n"+
+		"Order: ORD-1\n"+
+		"Time: 2025-06-02T12:00:00Z\n", testAlert.Text())
+}
+
+// recorder is a Sender that records alerts.
+type recorder struct {
+	mu     sync.Mutex
+	alerts []Alert
+	err    error
+}
+
+func (r *recorder) Send(_ context.Context, a Alert) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.alerts = append(r.alerts, a)
+	return r.err
+}
+
+func TestNotifierCooldown(t *testing.T) {
+	first, failing := &recorder{}, &recorder{err: errors.New("unavailable")}
+	n := NewNotifier([]Sender{failing, first}, time.Minute, 0)
+
+	n.Notify(testAlert)
+	repeat := testAlert
+	repeat.Time = testAlert.Time.Add(30 * time.Second)
+	n.Notify(repeat)
+	other := repeat
+	other.Quantity = 13
+	n.Notify(other)
+	later := testAlert
+	later.Time = testAlert.Time.Add(time.Minute)
+	n.Notify(later)
+	assert.NoError(t, n.Close())
+
+	// Failing senders do not keep alerts from the others.
+	assert.Equal(t, []Alert{testAlert, other, later}, first.alerts)
+	assert.Len(t, failing.alerts, 3)
+	assert.Zero(t, n.Dropped())
+}
+
+func TestNotifierStampsTime(t *testing.T) {
+	r := &recorder{}
+	n := NewNotifier([]Sender{r}, 0, 1)
+	alert := testAlert
+	alert.Time = time.Time{}
+	n.Notify(alert)
+	n.Notify(alert)
+	assert.NoError(t, n.Close())
+	if assert.NotEmpty(t, r.alerts) {
+		assert.False(t, r.alerts[0].Time.IsZero())
+	}
+	// The second alert is either sent, without a cooldown, or dropped from
+	// the full queue.
+	assert.Equal(t, int64(2), int64(len(r.alerts))+n.Dropped())
+}
+
+func TestSlackSender(t *testing.T) {
+	var got map[string]string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(status)
+		w.Write([]byte("invalid_payload"))
+	}))
+	defer server.Close()
+
+	_, err := NewSlackSender("hooks.slack.com/services/x")
+	assert.Error(t, err)
+
+	s, err := NewSlackSender(server.URL)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Send(context.Background(), testAlert))
+	assert.Equal(t, "*"+testAlert.Subject()+"*\n```\n"+testAlert.Text()+"```", got["text"])
+
+	status = http.StatusBadRequest
+	assert.ErrorContains(t, s.Send(context.Background(), testAlert), "invalid_payload")
+}
+
+func TestEmailSender(t *testing.T) {
+	_, err := NewEmailSender("localhost", "alerts@example.com", []string{"ops@example.com"}, "", "")
+	assert.Error(t, err)
+	_, err = NewEmailSender("localhost:25", "alerts@example.com", nil, "", "")
+	assert.Error(t, err)
+
+	s, err := NewEmailSender("localhost:25", "alerts@example.com", []string{"ops@example.com", "dev@example.com"}, "alerts", "secret")
+	assert.NoError(t, err)
+	assert.NotNil(t, s.auth)
+	var msg string
+	s.sendMail = func(addr string, _ smtp.Auth, from string, to []string, body []byte) error {
+		assert.Equal(t, "localhost:25", addr)
+		assert.Equal(t, "alerts@example.com", from)
+		assert.Equal(t, []string{"ops@example.com", "dev@example.com"}, to)
+		msg = string(body)
+		return nil
+	}
+	assert.NoError(t, s.Send(context.Background(), testAlert))
+	assert.Contains(t, msg, "To: ops@example.com, dev@example.com\r\n")
+	assert.Contains(t, msg, "Subject: "+testAlert.Subject()+"\r\n")
+	assert.Contains(t, msg, "\r\n\r\nProfile: default\r\nQuantity: 12\r\n")
+}
@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// SlackSender posts alerts to a Slack incoming webhook.
+type SlackSender struct {
+	client     *http.Client
+	webhookURL string
+}
+
+// NewSlackSender posts to the incoming webhook at webhookURL, e.g.
+// "https://hooks.slack.com/services/T000/B000/XXXX".
+func NewSlackSender(webhookURL string) (*SlackSender, error) {
+	u, err := url.Parse(webhookURL)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid slack webhook url %q", webhookURL)
+	}
+	return &SlackSender{client: &http.Client{Timeout: 30 * time.Second}, webhookURL: webhookURL}, nil
+}
+
+// Send posts a as a message with its subject in bold, followed by its
+// details.
+func (s *SlackSender) Send(ctx context.Context, a Alert) error {
+	body, err := json.Marshal(map[string]string{"text": "*" + a.Subject() + "*\n```\n" + a.Text() + "```"})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("slack webhook: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
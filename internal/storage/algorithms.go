@@ -0,0 +1,45 @@
+package storage
+
+import "time"
+
+// AlgorithmStats counts the allocations one algorithm produced. Allocations
+// counted as hits on an identical row (see WriteDedup) count once per hit.
+type AlgorithmStats struct {
+	Algorithm   string `json:"algorithm"`
+	Allocations int64  `json:"allocations"`
+	// Approximate counts the allocations returned before being proven
+	// optimal.
+	Approximate int64 `json:"approximate"`
+}
+
+// GetAlgorithmStats counts the allocations created in [from, to) by
+// algorithm, most used first. Deleted allocations are not counted.
+func (s *SQLiteStorage) GetAlgorithmStats(from, to time.Time) ([]AlgorithmStats, error) {
+	query := "SELECT algorithm, SUM(hits), SUM(CASE WHEN approximate THEN hits ELSE 0 END) FROM allocations WHERE deleted_at IS NULL"
+	var args []interface{}
+	if !from.IsZero() {
+		query += " AND created_at >= ?"
+		args = append(args, sqliteTime(from))
+	}
+	if !to.IsZero() {
+		query += " AND created_at < ?"
+		args = append(args, sqliteTime(to))
+	}
+	query += " GROUP BY algorithm ORDER BY SUM(hits) DESC, algorithm"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []AlgorithmStats{}
+	for rows.Next() {
+		var a AlgorithmStats
+		if err := rows.Scan(&a.Algorithm, &a.Allocations, &a.Approximate); err != nil {
+			return nil, err
+		}
+		stats = append(stats, a)
+	}
+	return stats, rows.Err()
+}
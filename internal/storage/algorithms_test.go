@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAlgorithmStats(t *testing.T) {
+	s, err := NewInMemorySQLite()
+	assert.NoError(t, err)
+	defer s.Close()
+	assert.NoError(t, s.SetWriteMode(WriteDedup))
+
+	old := time.Now().Add(-48 * time.Hour)
+	for _, in := range []AllocationInput{
+		{Quantity: 50, Packs: map[int]int{53: 1}, Total: 53, Algorithm: "dp"},
+		{Quantity: 60, Packs: map[int]int{31: 2}, Total: 62, Algorithm: "dp"},
+		{Quantity: 50, Packs: map[int]int{53: 1}, Total: 53, Algorithm: "cache"},
+		{Quantity: 50, Packs: map[int]int{53: 1}, Total: 53, Algorithm: "cache"},
+		{Quantity: 70, Packs: map[int]int{53: 2}, Total: 106, Algorithm: "greedy", Approximate: true},
+		{Quantity: 70, Packs: map[int]int{53: 2}, Total: 106, Algorithm: "greedy", Approximate: true},
+		{Quantity: 90, Packs: map[int]int{53: 2}, Total: 106, Algorithm: "greedy", CreatedAt: old},
+		{Quantity: 40, Packs: map[int]int{53: 1}, Total: 53, CreatedAt: old},
+	} {
+		assert.NoError(t, s.StoreAllocationInput(in))
+	}
+
+	stats, err := s.GetAlgorithmStats(time.Time{}, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, []AlgorithmStats{
+		{Algorithm: "greedy", Allocations: 3, Approximate: 2},
+		{Algorithm: "cache", Allocations: 2},
+		{Algorithm: "dp", Allocations: 2},
+		{Algorithm: "", Allocations: 1},
+	}, stats)
+
+	recent, err := s.GetRecentAllocations(1, false)
+	assert.NoError(t, err)
+	if assert.Len(t, recent, 1) {
+		assert.Equal(t, "greedy", recent[0].Algorithm)
+		assert.True(t, recent[0].Approximate)
+	}
+
+	stats, err = s.GetAlgorithmStats(time.Now().Add(-time.Hour), time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, stats, 3)
+	stats, err = s.GetAlgorithmStats(time.Time{}, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, []AlgorithmStats{{Algorithm: "", Allocations: 1}, {Algorithm: "greedy", Allocations: 1}}, stats)
+	stats, err = s.GetAlgorithmStats(time.Now().Add(time.Hour), time.Time{})
+	assert.NoError(t, err)
+	assert.Empty(t, stats)
+}
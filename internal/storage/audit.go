@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"time"
+)
+
+// MaxAuditLimit caps how many audit entries a single query returns.
+const MaxAuditLimit = 1000
+
+// AuditEntry records who made a request, what they asked for and how it ended.
+type AuditEntry struct {
+	ID int64 `json:"id"`
+	// Actor identifies the caller, e.g. "user:alice", "key:3f2a9c1b0d4e" or "anonymous".
+	Actor    string `json:"actor"`
+	ClientIP string `json:"client_ip"`
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+	// Params holds the query string and request body, truncated.
+	Params string `json:"params"`
+	Status int    `json:"status"`
+	// Error is the error message of failed requests.
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// AuditFilter selects audit entries. Zero fields match everything; Limit
+// defaults to 100 and is capped at MaxAuditLimit.
+type AuditFilter struct {
+	Actor string
+	Path  string
+	From  time.Time
+	To    time.Time
+	Limit int
+}
+
+// RecordAudit appends an entry to the audit log. CreatedAt defaults to now.
+func (s *SQLiteStorage) RecordAudit(e AuditEntry) error {
+	if e.Method == "" || e.Path == "" {
+		return ErrInvalidArgument
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	_, err := s.insertAudit.Exec(
+		e.Actor, e.ClientIP, e.Method, e.Path, e.Params, e.Status, e.Error, e.DurationMS, sqliteTime(e.CreatedAt),
+	)
+	return err
+}
+
+// GetAuditEntries returns the audit entries matching f, most recent first.
+func (s *SQLiteStorage) GetAuditEntries(f AuditFilter) ([]AuditEntry, error) {
+	query := "SELECT id, actor, client_ip, method, path, params, status, error, duration_ms, created_at FROM audit_log WHERE 1 = 1"
+	var args []interface{}
+	if f.Actor != "" {
+		query += " AND actor = ?"
+		args = append(args, f.Actor)
+	}
+	if f.Path != "" {
+		query += " AND path = ?"
+		args = append(args, f.Path)
+	}
+	if !f.From.IsZero() {
+		query += " AND created_at >= ?"
+		args = append(args, sqliteTime(f.From))
+	}
+	if !f.To.IsZero() {
+		query += " AND created_at < ?"
+		args = append(args, sqliteTime(f.To))
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT ?"
+	args = append(args, auditLimit(f.Limit))
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.ID, &e.Actor, &e.ClientIP, &e.Method, &e.Path, &e.Params, &e.Status, &e.Error, &e.DurationMS, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// auditLimit applies the default and maximum to a requested limit.
+func auditLimit(limit int) int {
+	if limit <= 0 {
+		return 100
+	}
+	return min(limit, MaxAuditLimit)
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditLog(t *testing.T) {
+	storage, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	entries := []AuditEntry{
+		{Actor: "user:alice", Method: "POST", Path: "/calculate", Params: `{"quantity":5}`, Status: 200, CreatedAt: now.Add(-2 * time.Hour)},
+		{Actor: "key:3f2a9c1b0d4e", Method: "GET", Path: "/calculate", Params: "quantity=0", Status: 400, Error: "invalid quantity", CreatedAt: now.Add(-time.Hour)},
+		{Actor: "user:alice", Method: "POST", Path: "/admin/prune", Params: "max_rows=10", Status: 200, DurationMS: 12, CreatedAt: now},
+	}
+	for _, e := range entries {
+		assert.NoError(t, storage.RecordAudit(e))
+	}
+	assert.ErrorIs(t, storage.RecordAudit(AuditEntry{Actor: "anonymous"}), ErrInvalidArgument)
+
+	all, err := storage.GetAuditEntries(AuditFilter{})
+	assert.NoError(t, err)
+	if assert.Len(t, all, 3) {
+		assert.Equal(t, "/admin/prune", all[0].Path)
+		assert.Equal(t, int64(12), all[0].DurationMS)
+		assert.True(t, now.Equal(all[0].CreatedAt))
+		assert.Equal(t, "invalid quantity", all[1].Error)
+		assert.Equal(t, `{"quantity":5}`, all[2].Params)
+	}
+
+	byActor, err := storage.GetAuditEntries(AuditFilter{Actor: "user:alice"})
+	assert.NoError(t, err)
+	assert.Len(t, byActor, 2)
+
+	byPath, err := storage.GetAuditEntries(AuditFilter{Path: "/calculate", Actor: "user:alice"})
+	assert.NoError(t, err)
+	assert.Len(t, byPath, 1)
+
+	ranged, err := storage.GetAuditEntries(AuditFilter{From: now.Add(-90 * time.Minute), To: now})
+	assert.NoError(t, err)
+	if assert.Len(t, ranged, 1) {
+		assert.Equal(t, 400, ranged[0].Status)
+	}
+
+	limited, err := storage.GetAuditEntries(AuditFilter{Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, limited, 1)
+
+	none, err := storage.GetAuditEntries(AuditFilter{Actor: "nobody"})
+	assert.NoError(t, err)
+	assert.Empty(t, none)
+}
@@ -0,0 +1,76 @@
+package storage
+
+import "fmt"
+
+// Storage backends a deployment selects between.
+const (
+	// BackendSQLite is SQLiteStorage, the default. It needs cgo unless
+	// built with the modernc tag.
+	BackendSQLite = "sqlite"
+	// BackendBolt is BoltStorage, which builds without cgo.
+	BackendBolt = "bolt"
+)
+
+// writeModeStorage is a Storage whose write mode can be set.
+type writeModeStorage interface {
+	Storage
+	SetWriteMode(mode WriteMode) error
+}
+
+// CheckBackend returns an error unless backend is empty, meaning
+// BackendSQLite, or a known backend.
+func CheckBackend(backend string) error {
+	switch backend {
+	case "", BackendSQLite, BackendBolt:
+		return nil
+	}
+	return fmt.Errorf("unknown storage backend %q (want %q or %q)", backend, BackendSQLite, BackendBolt)
+}
+
+// DatabaseFile is the name of a backend's database file in a data directory.
+func DatabaseFile(backend string) string {
+	if backend == BackendBolt {
+		return "allocations.bolt"
+	}
+	return "allocations.db"
+}
+
+// Open opens the backend's database at path in the given write mode.
+func Open(backend, path string, mode WriteMode) (Storage, error) {
+	if err := CheckBackend(backend); err != nil {
+		return nil, err
+	}
+	if backend == BackendBolt {
+		s, err := NewBoltStorage(path)
+		return withWriteMode(s, err, mode)
+	}
+	s, err := NewSQLiteStorage(path)
+	return withWriteMode(s, err, mode)
+}
+
+// OpenTemporary opens a backend's database that nothing outlives: an
+// in-memory SQLite database, or a bbolt file removed on Close.
+func OpenTemporary(backend string, mode WriteMode) (Storage, error) {
+	if err := CheckBackend(backend); err != nil {
+		return nil, err
+	}
+	if backend == BackendBolt {
+		s, err := NewTempBoltStorage()
+		return withWriteMode(s, err, mode)
+	}
+	s, err := NewInMemorySQLite()
+	return withWriteMode(s, err, mode)
+}
+
+// withWriteMode sets the write mode of a storage just opened, closing it if
+// the mode is invalid.
+func withWriteMode(s writeModeStorage, err error, mode WriteMode) (Storage, error) {
+	if err != nil {
+		return nil, err
+	}
+	if err := s.SetWriteMode(mode); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
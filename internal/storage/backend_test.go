@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpen(t *testing.T) {
+	assert.NoError(t, CheckBackend(""))
+	assert.Error(t, CheckBackend("postgres"))
+	assert.Equal(t, "allocations.db", DatabaseFile(""))
+	assert.Equal(t, "allocations.bolt", DatabaseFile(BackendBolt))
+
+	s, err := Open(BackendBolt, filepath.Join(t.TempDir(), DatabaseFile(BackendBolt)), WriteDedup)
+	assert.NoError(t, err)
+	if assert.IsType(t, &BoltStorage{}, s) {
+		assert.Equal(t, WriteDedup, s.(*BoltStorage).writeMode)
+	}
+	assert.NoError(t, s.Close())
+
+	s, err = OpenTemporary("", "")
+	assert.NoError(t, err)
+	if assert.IsType(t, &SQLiteStorage{}, s) {
+		assert.Equal(t, WriteAppend, s.(*SQLiteStorage).writeMode)
+	}
+	assert.NoError(t, s.Close())
+
+	_, err = Open("postgres", filepath.Join(t.TempDir(), "allocations.db"), "")
+	assert.Error(t, err)
+	_, err = OpenTemporary(BackendBolt, "upsert")
+	assert.Error(t, err)
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Backup writes a consistent snapshot of the database to w, a SQLite
+// database file. The snapshot is taken with VACUUM INTO a temporary file, so
+// writes continue while it is copied to w.
+func (s *SQLiteStorage) Backup(w io.Writer) error {
+	dir, err := os.MkdirTemp("", "gymshark-backup-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "allocations.db")
+	if _, err := s.db.Exec("VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("snapshot database: %w", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// Backup writes a consistent snapshot of the database to w, a bbolt file.
+// It runs in a read transaction, so writes continue meanwhile.
+func (s *BoltStorage) Backup(w io.Writer) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		_, err := tx.WriteTo(w)
+		return err
+	})
+}
+
+// RestoreBackup replaces the backend's database at path with the backup at
+// src, written by Backup. The backup is copied next to path and opened
+// first, which also migrates a backup of an older schema, so a corrupt
+// backup or one of another backend leaves the database untouched. Nothing
+// may have the database open meanwhile.
+func RestoreBackup(backend, src, path string) error {
+	if err := CheckBackend(backend); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := path + ".restore"
+	if err := copyFile(tmp, in); err != nil {
+		removeDatabase(tmp)
+		return err
+	}
+	if err := checkDatabase(backend, tmp); err != nil {
+		removeDatabase(tmp)
+		return fmt.Errorf("invalid %s backup: %w", backend, err)
+	}
+
+	// The replaced database's write-ahead log must not be applied to the
+	// restored one.
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(path + suffix); err != nil && !os.IsNotExist(err) {
+			removeDatabase(tmp)
+			return err
+		}
+	}
+	return os.Rename(tmp, path)
+}
+
+// copyFile writes r to a new file at path.
+func copyFile(path string, r io.Reader) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// checkDatabase opens the database at path and reads from it.
+func checkDatabase(backend, path string) error {
+	s, err := Open(backend, path, WriteAppend)
+	if err != nil {
+		return err
+	}
+	if _, err := s.CountAllocations(AllocationFilter{IncludeDeleted: true}); err != nil {
+		s.Close()
+		return err
+	}
+	return s.Close()
+}
+
+// removeDatabase removes a database file and the SQLite write-ahead log and
+// shared memory files beside it.
+func removeDatabase(path string) {
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		os.Remove(path + suffix)
+	}
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	for _, backend := range []string{BackendSQLite, BackendBolt} {
+		t.Run(backend, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, DatabaseFile(backend))
+			s, err := Open(backend, path, WriteAppend)
+			assert.NoError(t, err)
+			assert.NoError(t, s.StoreAllocation(100, map[int]int{53: 2}, 106))
+			assert.NoError(t, s.StoreAllocation(50, map[int]int{53: 1}, 53))
+
+			var backup bytes.Buffer
+			assert.NoError(t, s.Backup(&backup))
+			// Writes after the backup are not in it
+			assert.NoError(t, s.StoreAllocation(23, map[int]int{23: 1}, 23))
+			assert.NoError(t, s.Close())
+
+			file := filepath.Join(dir, "gymshark.backup")
+			assert.NoError(t, os.WriteFile(file, backup.Bytes(), 0o644))
+			assert.NoError(t, RestoreBackup(backend, file, path))
+
+			s, err = Open(backend, path, WriteAppend)
+			assert.NoError(t, err)
+			n, err := s.CountAllocations(AllocationFilter{IncludeDeleted: true})
+			assert.NoError(t, err)
+			assert.Equal(t, 2, n)
+			a, err := s.GetAllocationByQuantity(100)
+			assert.NoError(t, err)
+			if assert.NotNil(t, a) {
+				assert.Equal(t, map[int]int{53: 2}, a.Packs)
+			}
+			assert.NoError(t, s.Close())
+
+			_, err = os.Stat(path + ".restore")
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
+
+func TestBackupInMemory(t *testing.T) {
+	s, err := NewInMemorySQLite()
+	assert.NoError(t, err)
+	defer s.Close()
+	assert.NoError(t, s.StoreAllocation(100, map[int]int{53: 2}, 106))
+
+	var backup bytes.Buffer
+	assert.NoError(t, s.Backup(&backup))
+	assert.True(t, bytes.HasPrefix(backup.Bytes(), []byte("SQLite format 3\x00")))
+}
+
+func TestRestoreInvalidBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "allocations.db")
+	s, err := NewSQLiteStorage(path)
+	assert.NoError(t, err)
+	assert.NoError(t, s.StoreAllocation(100, map[int]int{53: 2}, 106))
+	assert.NoError(t, s.Close())
+
+	garbage := filepath.Join(dir, "garbage.backup")
+	assert.NoError(t, os.WriteFile(garbage, bytes.Repeat([]byte("not a database "), 512), 0o644))
+	bolt := filepath.Join(dir, "bolt.backup")
+	b, err := NewBoltStorage(bolt)
+	assert.NoError(t, err)
+	assert.NoError(t, b.Close())
+
+	assert.Error(t, RestoreBackup(BackendSQLite, garbage, path))
+	assert.Error(t, RestoreBackup(BackendSQLite, bolt, path))
+	assert.Error(t, RestoreBackup(BackendBolt, garbage, filepath.Join(dir, "allocations.bolt")))
+	assert.Error(t, RestoreBackup(BackendSQLite, filepath.Join(dir, "missing"), path))
+	assert.Error(t, RestoreBackup("postgres", garbage, path))
+
+	// The database is untouched
+	s, err = NewSQLiteStorage(path)
+	assert.NoError(t, err)
+	n, err := s.CountAllocations(AllocationFilter{IncludeDeleted: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, s.Close())
+	_, err = os.Stat(path + ".restore")
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,1045 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Buckets of a BoltStorage database. Allocations are keyed by creation time
+// and ID, so they are scanned in the order SQLiteStorage sorts them; the
+// next three buckets index those keys by ID, quantity and order ID. Records
+// are JSON.
+var (
+	boltAllocations     = []byte("allocations")
+	boltAllocationIDs   = []byte("allocation_ids")
+	boltByQuantity      = []byte("allocations_by_quantity")
+	boltByOrderID       = []byte("allocations_by_order_id")
+	boltProfileVersions = []byte("profile_versions")
+	boltAudit           = []byte("audit_log")
+	boltPins            = []byte("pins")
+	boltProfileDefaults = []byte("profile_defaults")
+	boltUsage           = []byte("usage")
+)
+
+// boltOpenTimeout bounds how long NewBoltStorage waits for the lock another
+// process holds on the file.
+const boltOpenTimeout = time.Second
+
+// boltExportBatchSize bounds the allocations ExportAllocations reads per
+// transaction, so a slow consumer does not hold one open.
+const boltExportBatchSize = 100
+
+// BoltStorage implements Storage in a bbolt file. Unlike SQLiteStorage with
+// its default driver it is pure Go, so binaries using it build with
+// CGO_ENABLED=0. A bbolt file is locked by the process that opens it, so
+// unlike a SQLite database it cannot be shared by the API and the worker.
+// Times are kept to the second, as SQLite stores them.
+type BoltStorage struct {
+	db        *bolt.DB
+	writeMode WriteMode
+
+	// tempDir is removed on Close; see NewTempBoltStorage.
+	tempDir string
+}
+
+// NewBoltStorage opens the bbolt database at path, creating it if it
+// doesn't exist. It fails after boltOpenTimeout if another process has the
+// file open.
+func NewBoltStorage(path string) (*BoltStorage, error) {
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: boltOpenTimeout})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("%s is locked by another process: %w", path, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltAllocations, boltAllocationIDs, boltByQuantity, boltByOrderID, boltProfileVersions, boltAudit, boltPins, boltProfileDefaults, boltUsage} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStorage{db: db}, nil
+}
+
+// NewTempBoltStorage creates a bbolt storage in a new temporary directory,
+// which Close removes. It is the bbolt counterpart of NewInMemorySQLite.
+func NewTempBoltStorage() (*BoltStorage, error) {
+	dir, err := os.MkdirTemp("", "gymshark-bolt-")
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewBoltStorage(filepath.Join(dir, "allocations.bolt"))
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	s.tempDir = dir
+	return s, nil
+}
+
+// SetWriteMode sets how allocations are written; the empty mode is
+// WriteAppend. It must be called before the storage is used concurrently.
+func (s *BoltStorage) SetWriteMode(mode WriteMode) error {
+	mode, err := checkWriteMode(mode)
+	if err != nil {
+		return err
+	}
+	s.writeMode = mode
+	return nil
+}
+
+// StoreAllocation saves a pack allocation result.
+func (s *BoltStorage) StoreAllocation(quantity int, packs map[int]int, total int) error {
+	return s.StoreAllocationInput(AllocationInput{Quantity: quantity, Packs: packs, Total: total})
+}
+
+// StoreAllocationInput saves a pack allocation result with its order
+// reference. With WriteDedup, an allocation identical to a stored one counts
+// as a hit on it instead of being inserted. Returns ErrInvalidArgument if
+// packs is nil.
+func (s *BoltStorage) StoreAllocationInput(in AllocationInput) error {
+	return s.StoreAllocations([]AllocationInput{in})
+}
+
+// StoreAllocations saves several allocations in a single transaction: either
+// all are stored or none. Returns ErrInvalidArgument, storing nothing, if
+// any has nil packs.
+func (s *BoltStorage) StoreAllocations(ins []AllocationInput) error {
+	for _, in := range ins {
+		if in.Packs == nil {
+			return ErrInvalidArgument
+		}
+	}
+	if len(ins) == 0 {
+		return nil
+	}
+	now := time.Now()
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, in := range ins {
+			if in.CreatedAt.IsZero() {
+				in.CreatedAt = now
+			}
+			if err := s.store(tx, in); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// store saves in within tx, or counts it as a hit with WriteDedup.
+func (s *BoltStorage) store(tx *bolt.Tx, in AllocationInput) error {
+	createdAt := boltTime(in.CreatedAt)
+	if dedupable(s.writeMode, in) {
+		hit, err := boltDedupHit(tx, in, createdAt)
+		if err != nil || hit {
+			return err
+		}
+	}
+
+	id, err := tx.Bucket(boltAllocations).NextSequence()
+	if err != nil {
+		return err
+	}
+	a := Allocation{
+		ID:                int64(id),
+		OrderQuantity:     in.Quantity,
+		Packs:             in.Packs,
+		Total:             in.Total,
+		OrderID:           in.OrderID,
+		CustomerID:        in.CustomerID,
+		Profile:           in.Profile,
+		ProfileVersion:    in.ProfileVersion,
+		Source:            in.Source,
+		Algorithm:         in.Algorithm,
+		Approximate:       in.Approximate,
+		Cacheable:         in.Cacheable,
+		Unit:              in.Unit,
+		RequestedQuantity: in.RequestedQuantity,
+		Rules:             in.Rules,
+		CreatedAt:         createdAt,
+		Hits:              1,
+	}
+	if len(in.Metadata) > 0 {
+		a.Metadata = in.Metadata
+	}
+	return boltPutAllocation(tx, a)
+}
+
+// boltDedupHit counts in as a hit on the most recent identical allocation
+// without an order reference that is not deleted, and reports whether there
+// was one.
+func boltDedupHit(tx *bolt.Tx, in AllocationInput, at time.Time) (bool, error) {
+	var match *Allocation
+	err := boltEachByQuantity(tx, in.Quantity, func(a *Allocation) bool {
+		if a.Profile == in.Profile && a.ProfileVersion == in.ProfileVersion && a.Source == in.Source &&
+			a.Algorithm == in.Algorithm && a.Approximate == in.Approximate && a.Cacheable == in.Cacheable &&
+			a.Unit == in.Unit && a.RequestedQuantity == in.RequestedQuantity &&
+			equalPacks(a.Packs, in.Packs) && a.Total == in.Total &&
+			a.OrderID == "" && a.CustomerID == "" && len(a.Metadata) == 0 && a.DeletedAt == nil {
+			match = a
+			return false
+		}
+		return true
+	})
+	if err != nil || match == nil {
+		return false, err
+	}
+	match.Hits++
+	match.LastAccessedAt = &at
+	return true, boltPutAllocation(tx, *match)
+}
+
+// GetRecentAllocations retrieves the most recent allocations, newest first.
+func (s *BoltStorage) GetRecentAllocations(limit int, includeDeleted bool) ([]Allocation, error) {
+	var allocations []Allocation
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return boltReverse(tx.Bucket(boltAllocations).Cursor(), nil, func(k, v []byte) (bool, error) {
+			if len(allocations) >= limit {
+				return false, nil
+			}
+			a, err := boltDecodeAllocation(v)
+			if err != nil {
+				return false, err
+			}
+			if a.DeletedAt == nil || includeDeleted {
+				allocations = append(allocations, *a)
+			}
+			return true, nil
+		})
+	})
+	return allocations, err
+}
+
+// CountAllocations counts the allocations matching f. Only the count of
+// every allocation, deleted ones included, is known without reading them.
+func (s *BoltStorage) CountAllocations(f AllocationFilter) (int, error) {
+	if f.IncludeDeleted && f.matchesAll() {
+		n := 0
+		err := s.db.View(func(tx *bolt.Tx) error {
+			n = tx.Bucket(boltAllocations).Stats().KeyN
+			return nil
+		})
+		return n, err
+	}
+	n := 0
+	err := s.eachAllocation(f.From, f.To, f.IncludeDeleted, func(a Allocation) error {
+		if f.matches(a) {
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// GetAllocationByQuantity retrieves the most recent allocation for a given
+// quantity. Returns nil if no allocation is found for the quantity.
+func (s *BoltStorage) GetAllocationByQuantity(quantity int) (*Allocation, error) {
+	var found *Allocation
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return boltEachByQuantity(tx, quantity, func(a *Allocation) bool {
+			if a.DeletedAt != nil {
+				return true
+			}
+			found = a
+			return false
+		})
+	})
+	return found, err
+}
+
+// GetCachedAllocation retrieves the most recent allocation for a quantity
+// of a profile, skipping deleted ones, those served from the cache, imported
+// and approximate ones and those not stored as Cacheable, as SQLiteStorage
+// does. Returns nil if no allocation is found.
+func (s *BoltStorage) GetCachedAllocation(profile string, quantity int) (*Allocation, error) {
+	var found *Allocation
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return boltEachByQuantity(tx, quantity, func(a *Allocation) bool {
+			if a.Profile != profile || a.DeletedAt != nil || a.Algorithm == "cache" || a.Algorithm == AlgorithmImport ||
+				a.Approximate || !a.Cacheable {
+				return true
+			}
+			found = a
+			return false
+		})
+	})
+	return found, err
+}
+
+// CacheAllocation does nothing: every stored allocation is already served
+// by GetCachedAllocation.
+func (s *BoltStorage) CacheAllocation(Allocation) error {
+	return nil
+}
+
+// GetAllocationsByOrderID retrieves all allocations recorded for an order,
+// most recent first.
+func (s *BoltStorage) GetAllocationsByOrderID(orderID string) ([]Allocation, error) {
+	if orderID == "" {
+		return nil, ErrInvalidArgument
+	}
+	var allocations []Allocation
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltByOrderID).Cursor()
+		prefix := append([]byte(orderID), 0)
+		return boltReverse(c, prefix, func(k, _ []byte) (bool, error) {
+			a, err := boltGetAllocation(tx, k[len(prefix):])
+			if err != nil {
+				return false, err
+			}
+			if a.DeletedAt == nil {
+				allocations = append(allocations, *a)
+			}
+			return true, nil
+		})
+	})
+	return allocations, err
+}
+
+// ExportAllocations streams allocations created in [from, to) ordered by
+// creation time, reading them in batches so that fn may take its time.
+// Deleted allocations are left out.
+func (s *BoltStorage) ExportAllocations(from, to time.Time, fn func(Allocation) error) error {
+	return s.eachAllocation(from, to, false, fn)
+}
+
+// eachAllocation is ExportAllocations, also streaming deleted allocations
+// if includeDeleted is set.
+func (s *BoltStorage) eachAllocation(from, to time.Time, includeDeleted bool, fn func(Allocation) error) error {
+	var start []byte
+	if !from.IsZero() {
+		start = boltTimeKey(from)
+	}
+	var end []byte
+	if !to.IsZero() {
+		end = boltTimeKey(to)
+	}
+
+	for {
+		var batch []Allocation
+		var next []byte
+		err := s.db.View(func(tx *bolt.Tx) error {
+			c := tx.Bucket(boltAllocations).Cursor()
+			k, v := c.First()
+			if start != nil {
+				k, v = c.Seek(start)
+			}
+			for ; k != nil && (end == nil || bytes.Compare(k, end) < 0); k, v = c.Next() {
+				if len(batch) == boltExportBatchSize {
+					next = bytes.Clone(k)
+					return nil
+				}
+				a, err := boltDecodeAllocation(v)
+				if err != nil {
+					return err
+				}
+				if a.DeletedAt == nil || includeDeleted {
+					batch = append(batch, *a)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, a := range batch {
+			if err := fn(a); err != nil {
+				return err
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		start = next
+	}
+}
+
+// DeleteAllocation marks the allocation with the given ID deleted now.
+func (s *BoltStorage) DeleteAllocation(id int64) (bool, error) {
+	return s.setDeletedAt(id, func(a *Allocation) bool {
+		if a.DeletedAt != nil {
+			return false
+		}
+		now := boltTime(time.Now())
+		a.DeletedAt = &now
+		return true
+	})
+}
+
+// RestoreAllocation clears the deletion mark of the allocation with the
+// given ID.
+func (s *BoltStorage) RestoreAllocation(id int64) (bool, error) {
+	return s.setDeletedAt(id, func(a *Allocation) bool {
+		if a.DeletedAt == nil {
+			return false
+		}
+		a.DeletedAt = nil
+		return true
+	})
+}
+
+// setDeletedAt applies update to the allocation with the given ID and stores
+// it if update reports a change.
+func (s *BoltStorage) setDeletedAt(id int64, update func(*Allocation) bool) (bool, error) {
+	changed := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		key := tx.Bucket(boltAllocationIDs).Get(boltUint(uint64(id)))
+		if key == nil {
+			return nil
+		}
+		a, err := boltGetAllocation(tx, key)
+		if err != nil || !update(a) {
+			return err
+		}
+		changed = true
+		return boltPutAllocation(tx, *a)
+	})
+	return changed, err
+}
+
+// DeleteOlderThan removes allocations created before t.
+func (s *BoltStorage) DeleteOlderThan(t time.Time) (int64, error) {
+	end := boltTimeKey(t)
+	return s.deleteAllocations(func(c *bolt.Cursor, remove func(k, v []byte) error) error {
+		for k, v := c.First(); k != nil && bytes.Compare(k, end) < 0; k, v = c.Next() {
+			if err := remove(k, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteAllButNewest removes all allocations except the n most recent.
+// Returns ErrInvalidArgument if n is negative.
+func (s *BoltStorage) DeleteAllButNewest(n int) (int64, error) {
+	if n < 0 {
+		return 0, ErrInvalidArgument
+	}
+	return s.deleteAllocations(func(c *bolt.Cursor, remove func(k, v []byte) error) error {
+		skipped := 0
+		return boltReverse(c, nil, func(k, v []byte) (bool, error) {
+			if skipped < n {
+				skipped++
+				return true, nil
+			}
+			return true, remove(k, v)
+		})
+	})
+}
+
+// deleteAllocations removes the allocations that find passes to remove,
+// with their index entries. find walks the allocations bucket, which cannot
+// change under its cursor, so they are removed once it is done.
+func (s *BoltStorage) deleteAllocations(find func(c *bolt.Cursor, remove func(k, v []byte) error) error) (int64, error) {
+	var n int64
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var doomed []Allocation
+		err := find(tx.Bucket(boltAllocations).Cursor(), func(k, v []byte) error {
+			a, err := boltDecodeAllocation(v)
+			if err != nil {
+				return err
+			}
+			doomed = append(doomed, *a)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, a := range doomed {
+			if err := boltDeleteAllocation(tx, a); err != nil {
+				return err
+			}
+		}
+		n = int64(len(doomed))
+		return nil
+	})
+	return n, err
+}
+
+// GetAlgorithmStats counts the allocations created in [from, to) by
+// algorithm, most used first. Deleted allocations are not counted.
+func (s *BoltStorage) GetAlgorithmStats(from, to time.Time) ([]AlgorithmStats, error) {
+	byAlgorithm := map[string]*AlgorithmStats{}
+	err := s.ExportAllocations(from, to, func(a Allocation) error {
+		st, ok := byAlgorithm[a.Algorithm]
+		if !ok {
+			st = &AlgorithmStats{Algorithm: a.Algorithm}
+			byAlgorithm[a.Algorithm] = st
+		}
+		st.Allocations += int64(a.Hits)
+		if a.Approximate {
+			st.Approximate += int64(a.Hits)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := []AlgorithmStats{}
+	for _, st := range byAlgorithm {
+		stats = append(stats, *st)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Allocations != stats[j].Allocations {
+			return stats[i].Allocations > stats[j].Allocations
+		}
+		return stats[i].Algorithm < stats[j].Algorithm
+	})
+	return stats, nil
+}
+
+// AggregateWaste sums the allocations created in [from, to) by group: days
+// in order, other groups most waste first. It reads every allocation in the
+// time range, but holds only the groups in memory.
+func (s *BoltStorage) AggregateWaste(groupBy string, from, to time.Time) ([]WasteStats, error) {
+	if err := checkGroupBy(groupBy); err != nil {
+		return nil, err
+	}
+	byGroup := map[string]*WasteStats{}
+	err := s.ExportAllocations(from, to, func(a Allocation) error {
+		if a.Algorithm == AlgorithmImport {
+			return nil
+		}
+		var group string
+		switch groupBy {
+		case GroupByAlgorithm:
+			group = a.Algorithm
+		case GroupByProfile:
+			group = a.Profile
+		case GroupByDay:
+			group = a.CreatedAt.UTC().Format(time.DateOnly)
+		}
+		w, ok := byGroup[group]
+		if !ok {
+			w = &WasteStats{Group: group}
+			byGroup[group] = w
+		}
+		waste := a.Total - a.OrderQuantity
+		w.Allocations += int64(a.Hits)
+		w.Quantity += int64(a.OrderQuantity) * int64(a.Hits)
+		w.Waste += int64(waste) * int64(a.Hits)
+		w.MaxWaste = max(w.MaxWaste, waste)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := []WasteStats{}
+	for _, w := range byGroup {
+		stats = append(stats, *w)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if groupBy != GroupByDay && stats[i].Waste != stats[j].Waste {
+			return stats[i].Waste > stats[j].Waste
+		}
+		return stats[i].Group < stats[j].Group
+	})
+	return stats, nil
+}
+
+// PackUsage sums the packs of every size in the allocations created in
+// [from, to), by profile and then size, largest first. It reads every
+// allocation in the range.
+func (s *BoltStorage) PackUsage(from, to time.Time) ([]PackUsage, error) {
+	type key struct {
+		profile string
+		size    int
+	}
+	bySize := map[key]*PackUsage{}
+	err := s.ExportAllocations(from, to, func(a Allocation) error {
+		for size, n := range a.Packs {
+			if n <= 0 {
+				continue
+			}
+			u, ok := bySize[key{a.Profile, size}]
+			if !ok {
+				u = &PackUsage{Profile: a.Profile, Size: size}
+				bySize[key{a.Profile, size}] = u
+			}
+			u.Packs += int64(n) * int64(a.Hits)
+			u.Allocations += int64(a.Hits)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	usage := []PackUsage{}
+	for _, u := range bySize {
+		usage = append(usage, *u)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Profile != usage[j].Profile {
+			return usage[i].Profile < usage[j].Profile
+		}
+		return usage[i].Size > usage[j].Size
+	})
+	return usage, nil
+}
+
+// TopQuantities returns the n quantities requested most often since since,
+// most requested first. It reads every allocation since then.
+func (s *BoltStorage) TopQuantities(since time.Time, n int) ([]QuantityCount, error) {
+	type key struct {
+		profile  string
+		quantity int
+	}
+	byQuantity := map[key]int64{}
+	err := s.ExportAllocations(since, time.Time{}, func(a Allocation) error {
+		byQuantity[key{a.Profile, a.OrderQuantity}] += int64(a.Hits)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	counts := []QuantityCount{}
+	for k, requests := range byQuantity {
+		counts = append(counts, QuantityCount{Profile: k.profile, Quantity: k.quantity, Requests: requests})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Requests != counts[j].Requests {
+			return counts[i].Requests > counts[j].Requests
+		}
+		if counts[i].Profile != counts[j].Profile {
+			return counts[i].Profile < counts[j].Profile
+		}
+		return counts[i].Quantity < counts[j].Quantity
+	})
+	if len(counts) > n {
+		counts = counts[:n]
+	}
+	return counts, nil
+}
+
+// SearchAllocations returns the page of allocations matching f in its sort
+// order, and how many match in all. It reads every allocation in the time
+// range.
+func (s *BoltStorage) SearchAllocations(f AllocationFilter) ([]Allocation, int, error) {
+	field, desc, err := f.sortOrder()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var matched []Allocation
+	err = s.eachAllocation(f.From, f.To, f.IncludeDeleted, func(a Allocation) error {
+		if f.matches(a) {
+			matched = append(matched, a)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	key := func(a Allocation) int64 {
+		switch field {
+		case SortQuantity:
+			return int64(a.OrderQuantity)
+		case SortWaste:
+			return int64(a.Total - a.OrderQuantity)
+		case SortTotal:
+			return int64(a.Total)
+		}
+		return a.CreatedAt.Unix()
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		ki, kj := key(matched[i]), key(matched[j])
+		if ki == kj {
+			ki, kj = matched[i].ID, matched[j].ID
+		}
+		if desc {
+			return ki > kj
+		}
+		return ki < kj
+	})
+
+	start := min(max(f.Offset, 0), len(matched))
+	end := min(start+searchLimit(f.Limit), len(matched))
+	return append([]Allocation{}, matched[start:end]...), len(matched), nil
+}
+
+// RecordProfileVersion stores a new version of a profile when its pack sizes
+// changed since the latest version. Pack sizes are compared as sets.
+func (s *BoltStorage) RecordProfileVersion(name string, packSizes []int) (ProfileVersion, error) {
+	if name == "" || len(packSizes) == 0 {
+		return ProfileVersion{}, ErrInvalidArgument
+	}
+
+	sizes := make([]int, len(packSizes))
+	copy(sizes, packSizes)
+	sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+
+	var v ProfileVersion
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltProfileVersions)
+		prefix := append([]byte(name), 0)
+		latest := ProfileVersion{Name: name}
+		err := boltReverse(b.Cursor(), prefix, func(_, value []byte) (bool, error) {
+			return false, json.Unmarshal(value, &latest)
+		})
+		if err != nil {
+			return err
+		}
+		if latest.Version > 0 && equalSizes(latest.PackSizes, sizes) {
+			v = latest
+			return nil
+		}
+
+		v = ProfileVersion{
+			Name:          name,
+			Version:       latest.Version + 1,
+			PackSizes:     sizes,
+			EffectiveFrom: boltTime(time.Now()),
+		}
+		return boltPut(b, append(prefix, boltUint(uint64(v.Version))...), v)
+	})
+	return v, err
+}
+
+// GetProfileVersions retrieves every version of a profile, oldest first.
+func (s *BoltStorage) GetProfileVersions(name string) ([]ProfileVersion, error) {
+	versions := []ProfileVersion{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltProfileVersions).Cursor()
+		prefix := append([]byte(name), 0)
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var pv ProfileVersion
+			if err := json.Unmarshal(v, &pv); err != nil {
+				return err
+			}
+			versions = append(versions, pv)
+		}
+		return nil
+	})
+	return versions, err
+}
+
+// RecordAudit appends an entry to the audit log. CreatedAt defaults to now.
+func (s *BoltStorage) RecordAudit(e AuditEntry) error {
+	if e.Method == "" || e.Path == "" {
+		return ErrInvalidArgument
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	e.CreatedAt = boltTime(e.CreatedAt)
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltAudit)
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		e.ID = int64(id)
+		return boltPut(b, boltRecordKey(e.CreatedAt, e.ID), e)
+	})
+}
+
+// GetAuditEntries returns the audit entries matching f, most recent first.
+func (s *BoltStorage) GetAuditEntries(f AuditFilter) ([]AuditEntry, error) {
+	limit := auditLimit(f.Limit)
+	entries := []AuditEntry{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltAudit).Cursor()
+		k, v := c.Last()
+		if !f.To.IsZero() {
+			if k, v = c.Seek(boltTimeKey(f.To)); k == nil {
+				k, v = c.Last()
+			} else {
+				k, v = c.Prev()
+			}
+		}
+		var start []byte
+		if !f.From.IsZero() {
+			start = boltTimeKey(f.From)
+		}
+		for ; k != nil && len(entries) < limit && (start == nil || bytes.Compare(k, start) >= 0); k, v = c.Prev() {
+			var e AuditEntry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			if f.Actor != "" && e.Actor != f.Actor || f.Path != "" && e.Path != f.Path {
+				continue
+			}
+			entries = append(entries, e)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// PinAllocation stores p, replacing the pin for the same profile and
+// quantity if there is one.
+func (s *BoltStorage) PinAllocation(p Pin) error {
+	if p.Profile == "" || p.Quantity <= 0 || len(p.Packs) == 0 {
+		return ErrInvalidArgument
+	}
+	if p.CreatedAt.IsZero() {
+		p.CreatedAt = time.Now()
+	}
+	p.CreatedAt = boltTime(p.CreatedAt)
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return boltPut(tx.Bucket(boltPins), boltPinKey(p.Profile, p.Quantity), p)
+	})
+}
+
+// UnpinAllocation removes the pin for a quantity of a profile.
+func (s *BoltStorage) UnpinAllocation(profile string, quantity int) (bool, error) {
+	removed := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltPins)
+		key := boltPinKey(profile, quantity)
+		if b.Get(key) == nil {
+			return nil
+		}
+		removed = true
+		return b.Delete(key)
+	})
+	return removed, err
+}
+
+// GetPins retrieves every pin, ordered by profile and quantity.
+func (s *BoltStorage) GetPins() ([]Pin, error) {
+	pins := []Pin{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltPins).ForEach(func(_, v []byte) error {
+			var p Pin
+			if err := json.Unmarshal(v, &p); err != nil {
+				return err
+			}
+			pins = append(pins, p)
+			return nil
+		})
+	})
+	return pins, err
+}
+
+// SetProfileDefaults stores d, replacing the defaults of the same profile
+// if there are some.
+func (s *BoltStorage) SetProfileDefaults(d ProfileDefaults) error {
+	if d.Profile == "" || d.Timeout < 0 {
+		return ErrInvalidArgument
+	}
+	if d.UpdatedAt.IsZero() {
+		d.UpdatedAt = time.Now()
+	}
+	d.UpdatedAt = boltTime(d.UpdatedAt)
+	d.Timeout = d.Timeout.Truncate(time.Millisecond)
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return boltPut(tx.Bucket(boltProfileDefaults), []byte(d.Profile), d)
+	})
+}
+
+// DeleteProfileDefaults removes the defaults of a profile.
+func (s *BoltStorage) DeleteProfileDefaults(profile string) (bool, error) {
+	removed := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltProfileDefaults)
+		if b.Get([]byte(profile)) == nil {
+			return nil
+		}
+		removed = true
+		return b.Delete([]byte(profile))
+	})
+	return removed, err
+}
+
+// GetProfileDefaults retrieves the defaults of every profile that has some,
+// ordered by profile.
+func (s *BoltStorage) GetProfileDefaults() ([]ProfileDefaults, error) {
+	defaults := []ProfileDefaults{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltProfileDefaults).ForEach(func(_, v []byte) error {
+			var d ProfileDefaults
+			if err := json.Unmarshal(v, &d); err != nil {
+				return err
+			}
+			defaults = append(defaults, d)
+			return nil
+		})
+	})
+	return defaults, err
+}
+
+// Close closes the database, removing it if it is temporary.
+func (s *BoltStorage) Close() error {
+	err := s.db.Close()
+	if s.tempDir != "" {
+		err = errors.Join(err, os.RemoveAll(s.tempDir))
+	}
+	return err
+}
+
+// boltTime truncates t to the second in UTC, as SQLite stores timestamps.
+func boltTime(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Second)
+}
+
+// boltUint encodes n so that keys sort numerically.
+func boltUint(n uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, n)
+}
+
+// boltTimeKey encodes the second of t so that keys sort chronologically,
+// before 1970 too.
+func boltTimeKey(t time.Time) []byte {
+	return boltUint(uint64(t.Unix()) ^ 1<<63)
+}
+
+// boltRecordKey is the key of an allocation or audit entry: its creation
+// time, then its ID to order records created in the same second.
+func boltRecordKey(createdAt time.Time, id int64) []byte {
+	return append(boltTimeKey(createdAt), boltUint(uint64(id))...)
+}
+
+// boltPinKey orders pins by profile, then quantity.
+func boltPinKey(profile string, quantity int) []byte {
+	return append(append([]byte(profile), 0), boltUint(uint64(quantity))...)
+}
+
+// boltPut stores value as JSON under key.
+func boltPut(b *bolt.Bucket, key []byte, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return b.Put(key, data)
+}
+
+// boltPutAllocation stores a and its index entries, replacing any previous
+// version of it.
+func boltPutAllocation(tx *bolt.Tx, a Allocation) error {
+	key := boltRecordKey(a.CreatedAt, a.ID)
+	if err := boltPut(tx.Bucket(boltAllocations), key, a); err != nil {
+		return err
+	}
+	if err := tx.Bucket(boltAllocationIDs).Put(boltUint(uint64(a.ID)), key); err != nil {
+		return err
+	}
+	if err := tx.Bucket(boltByQuantity).Put(append(boltUint(uint64(a.OrderQuantity)), key...), nil); err != nil {
+		return err
+	}
+	if a.OrderID != "" {
+		return tx.Bucket(boltByOrderID).Put(append(append([]byte(a.OrderID), 0), key...), nil)
+	}
+	return nil
+}
+
+// boltDeleteAllocation removes a and its index entries.
+func boltDeleteAllocation(tx *bolt.Tx, a Allocation) error {
+	key := boltRecordKey(a.CreatedAt, a.ID)
+	if err := tx.Bucket(boltAllocations).Delete(key); err != nil {
+		return err
+	}
+	if err := tx.Bucket(boltAllocationIDs).Delete(boltUint(uint64(a.ID))); err != nil {
+		return err
+	}
+	if err := tx.Bucket(boltByQuantity).Delete(append(boltUint(uint64(a.OrderQuantity)), key...)); err != nil {
+		return err
+	}
+	if a.OrderID != "" {
+		return tx.Bucket(boltByOrderID).Delete(append(append([]byte(a.OrderID), 0), key...))
+	}
+	return nil
+}
+
+// boltGetAllocation reads the allocation stored under key.
+func boltGetAllocation(tx *bolt.Tx, key []byte) (*Allocation, error) {
+	v := tx.Bucket(boltAllocations).Get(key)
+	if v == nil {
+		return nil, fmt.Errorf("allocation index entry %x has no allocation", key)
+	}
+	return boltDecodeAllocation(v)
+}
+
+func boltDecodeAllocation(v []byte) (*Allocation, error) {
+	var a Allocation
+	if err := json.Unmarshal(v, &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// boltEachByQuantity calls fn for the allocations of a quantity, most recent
+// first, until it returns false.
+func boltEachByQuantity(tx *bolt.Tx, quantity int, fn func(*Allocation) bool) error {
+	prefix := boltUint(uint64(quantity))
+	return boltReverse(tx.Bucket(boltByQuantity).Cursor(), prefix, func(k, _ []byte) (bool, error) {
+		a, err := boltGetAllocation(tx, k[len(prefix):])
+		if err != nil {
+			return false, err
+		}
+		return fn(a), nil
+	})
+}
+
+// boltReverse calls fn for the entries of c whose keys start with prefix,
+// last first, until it returns false or an error. A nil prefix visits every
+// entry.
+func boltReverse(c *bolt.Cursor, prefix []byte, fn func(k, v []byte) (bool, error)) error {
+	var k, v []byte
+	if end := boltPrefixEnd(prefix); end == nil {
+		k, v = c.Last()
+	} else if k, v = c.Seek(end); k == nil {
+		k, v = c.Last()
+	} else {
+		k, v = c.Prev()
+	}
+	for ; k != nil && bytes.HasPrefix(k, prefix); k, v = c.Prev() {
+		more, err := fn(k, v)
+		if err != nil || !more {
+			return err
+		}
+	}
+	return nil
+}
+
+// boltPrefixEnd returns the first key after every key starting with prefix,
+// or nil if there is none.
+func boltPrefixEnd(prefix []byte) []byte {
+	end := bytes.Clone(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		end[i]++
+		if end[i] != 0 {
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+// equalPacks reports whether two pack breakdowns are identical.
+func equalPacks(a, b map[int]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for size, n := range a {
+		if m, ok := b[size]; !ok || m != n {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,336 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupBolt(t *testing.T) *BoltStorage {
+	s, err := NewTempBoltStorage()
+	assert.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestBoltAllocations(t *testing.T) {
+	s := setupBolt(t)
+
+	created := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	inputs := []AllocationInput{
+		{Quantity: 50, Packs: map[int]int{23: 1, 31: 1}, Total: 54, Profile: "default", Algorithm: "dp", Cacheable: true, CreatedAt: created},
+		{Quantity: 50, Packs: map[int]int{53: 1}, Total: 53, Profile: "default", Algorithm: "cache", Cacheable: true, CreatedAt: created.Add(time.Minute)},
+		{Quantity: 50, Packs: map[int]int{53: 1}, Total: 53, Profile: "small", Algorithm: "dp", CreatedAt: created.Add(time.Minute)},
+		{Quantity: 100, Packs: map[int]int{53: 2}, Total: 106, OrderID: "ORD-1", Metadata: map[string]interface{}{"channel": "web"}, Algorithm: "greedy", Approximate: true},
+		{Quantity: 24, Packs: map[int]int{31: 1}, Total: 31, OrderID: "ORD-1", Algorithm: "dp"},
+	}
+	for _, in := range inputs {
+		assert.NoError(t, s.StoreAllocationInput(in))
+	}
+	assert.ErrorIs(t, s.StoreAllocation(1, nil, 0), ErrInvalidArgument)
+
+	recent, err := s.GetRecentAllocations(2, false)
+	assert.NoError(t, err)
+	if assert.Len(t, recent, 2) {
+		assert.Equal(t, int64(5), recent[0].ID)
+		assert.Equal(t, 24, recent[0].OrderQuantity)
+		assert.Equal(t, 100, recent[1].OrderQuantity)
+		assert.Equal(t, map[string]interface{}{"channel": "web"}, recent[1].Metadata)
+		assert.Equal(t, 1, recent[1].Hits)
+	}
+
+	a, err := s.GetAllocationByQuantity(50)
+	assert.NoError(t, err)
+	if assert.NotNil(t, a) {
+		assert.Equal(t, int64(3), a.ID)
+	}
+	a, err = s.GetAllocationByQuantity(51)
+	assert.NoError(t, err)
+	assert.Nil(t, a)
+
+	// Allocations served from the cache are not cached again
+	a, err = s.GetCachedAllocation("default", 50)
+	assert.NoError(t, err)
+	if assert.NotNil(t, a) {
+		assert.Equal(t, map[int]int{23: 1, 31: 1}, a.Packs)
+		assert.True(t, a.CreatedAt.Equal(created))
+	}
+
+	order, err := s.GetAllocationsByOrderID("ORD-1")
+	assert.NoError(t, err)
+	if assert.Len(t, order, 2) {
+		assert.Equal(t, 24, order[0].OrderQuantity)
+		assert.Equal(t, 100, order[1].OrderQuantity)
+	}
+	_, err = s.GetAllocationsByOrderID("")
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+
+	stats, err := s.GetAlgorithmStats(time.Time{}, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, []AlgorithmStats{
+		{Algorithm: "dp", Allocations: 3},
+		{Algorithm: "cache", Allocations: 1},
+		{Algorithm: "greedy", Allocations: 1, Approximate: 1},
+	}, stats)
+}
+
+func TestBoltDeleteAndRestore(t *testing.T) {
+	s := setupBolt(t)
+	assert.NoError(t, s.StoreAllocationInput(AllocationInput{Quantity: 50, Packs: map[int]int{53: 1}, Total: 53, Profile: "default", OrderID: "ORD-1", Cacheable: true}))
+
+	deleted, err := s.DeleteAllocation(1)
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	deleted, err = s.DeleteAllocation(1)
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+	deleted, err = s.DeleteAllocation(2)
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+
+	a, err := s.GetCachedAllocation("default", 50)
+	assert.NoError(t, err)
+	assert.Nil(t, a)
+	order, err := s.GetAllocationsByOrderID("ORD-1")
+	assert.NoError(t, err)
+	assert.Empty(t, order)
+	recent, err := s.GetRecentAllocations(10, true)
+	assert.NoError(t, err)
+	if assert.Len(t, recent, 1) {
+		assert.NotNil(t, recent[0].DeletedAt)
+	}
+	count, err := s.CountAllocations(AllocationFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+	count, err = s.CountAllocations(AllocationFilter{IncludeDeleted: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	restored, err := s.RestoreAllocation(1)
+	assert.NoError(t, err)
+	assert.True(t, restored)
+	restored, err = s.RestoreAllocation(1)
+	assert.NoError(t, err)
+	assert.False(t, restored)
+	recent, err = s.GetRecentAllocations(10, false)
+	assert.NoError(t, err)
+	if assert.Len(t, recent, 1) {
+		assert.Nil(t, recent[0].DeletedAt)
+	}
+}
+
+func TestBoltDedup(t *testing.T) {
+	s := setupBolt(t)
+	assert.NoError(t, s.SetWriteMode(WriteDedup))
+	assert.Error(t, s.SetWriteMode("upsert"))
+
+	created := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	in := AllocationInput{Quantity: 50, Packs: map[int]int{23: 1, 31: 1}, Total: 54, Profile: "default", CreatedAt: created}
+	assert.NoError(t, s.StoreAllocationInput(in))
+	in.CreatedAt = created.Add(time.Minute)
+	assert.NoError(t, s.StoreAllocationInput(in))
+	// Different packs and allocations with an order are inserted
+	assert.NoError(t, s.StoreAllocationInput(AllocationInput{Quantity: 50, Packs: map[int]int{53: 1}, Total: 53, Profile: "default"}))
+	in.OrderID = "ORD-1"
+	assert.NoError(t, s.StoreAllocationInput(in))
+
+	recent, err := s.GetRecentAllocations(10, false)
+	assert.NoError(t, err)
+	if assert.Len(t, recent, 3) {
+		first := recent[2]
+		assert.Equal(t, 2, first.Hits)
+		assert.True(t, first.CreatedAt.Equal(created))
+		if assert.NotNil(t, first.LastAccessedAt) {
+			assert.True(t, first.LastAccessedAt.Equal(created.Add(time.Minute)))
+		}
+	}
+}
+
+func TestBoltStoreAllocations(t *testing.T) {
+	s := setupBolt(t)
+	assert.NoError(t, s.SetWriteMode(WriteDedup))
+
+	in := AllocationInput{Quantity: 50, Packs: map[int]int{23: 1, 31: 1}, Total: 54, Profile: "default"}
+	assert.NoError(t, s.StoreAllocations([]AllocationInput{in, in, {Quantity: 60, Packs: map[int]int{31: 2}, Total: 62, OrderID: "ORD-1"}}))
+	recent, err := s.GetRecentAllocations(10, false)
+	assert.NoError(t, err)
+	assert.Len(t, recent, 2)
+	stored, err := s.GetAllocationByQuantity(50)
+	assert.NoError(t, err)
+	if assert.NotNil(t, stored) {
+		assert.Equal(t, 2, stored.Hits)
+	}
+
+	// One invalid input stores none of the batch
+	err = s.StoreAllocations([]AllocationInput{
+		{Quantity: 70, Packs: map[int]int{23: 1, 53: 1}, Total: 76, OrderID: "ORD-2"},
+		{Quantity: 80, OrderID: "ORD-2"},
+	})
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+	allocations, err := s.GetAllocationsByOrderID("ORD-2")
+	assert.NoError(t, err)
+	assert.Empty(t, allocations)
+}
+
+func TestBoltExportAndRetention(t *testing.T) {
+	s := setupBolt(t)
+
+	// More than a batch, a day apart
+	now := time.Now().UTC().Truncate(time.Second)
+	n := boltExportBatchSize + 50
+	for i := 0; i < n; i++ {
+		in := AllocationInput{Quantity: i + 1, Packs: map[int]int{23: 1}, Total: 23, OrderID: "ORD-1", CreatedAt: now.AddDate(0, 0, i-n)}
+		assert.NoError(t, s.StoreAllocationInput(in))
+	}
+
+	var quantities []int
+	assert.NoError(t, s.ExportAllocations(time.Time{}, time.Time{}, func(a Allocation) error {
+		quantities = append(quantities, a.OrderQuantity)
+		return nil
+	}))
+	if assert.Len(t, quantities, n) {
+		assert.Equal(t, 1, quantities[0])
+		assert.Equal(t, n, quantities[n-1])
+	}
+
+	quantities = nil
+	assert.NoError(t, s.ExportAllocations(now.AddDate(0, 0, -3), now.AddDate(0, 0, -1), func(a Allocation) error {
+		quantities = append(quantities, a.OrderQuantity)
+		return nil
+	}))
+	assert.Equal(t, []int{n - 2, n - 1}, quantities)
+
+	removed, err := s.DeleteOlderThan(now.AddDate(0, 0, -10))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(n-10), removed)
+	removed, err = s.DeleteAllButNewest(3)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), removed)
+	_, err = s.DeleteAllButNewest(-1)
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+
+	// Index entries go with the allocations
+	order, err := s.GetAllocationsByOrderID("ORD-1")
+	assert.NoError(t, err)
+	assert.Len(t, order, 3)
+	a, err := s.GetAllocationByQuantity(1)
+	assert.NoError(t, err)
+	assert.Nil(t, a)
+}
+
+func TestBoltProfileVersions(t *testing.T) {
+	s := setupBolt(t)
+
+	v1, err := s.RecordProfileVersion("default", []int{23, 53, 31})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v1.Version)
+	assert.Equal(t, []int{53, 31, 23}, v1.PackSizes)
+	same, err := s.RecordProfileVersion("default", []int{31, 23, 53})
+	assert.NoError(t, err)
+	assert.Equal(t, v1, same)
+	v2, err := s.RecordProfileVersion("default", []int{250, 500})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, v2.Version)
+	_, err = s.RecordProfileVersion("default-large", []int{1000})
+	assert.NoError(t, err)
+	_, err = s.RecordProfileVersion("", []int{1})
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+
+	versions, err := s.GetProfileVersions("default")
+	assert.NoError(t, err)
+	assert.Equal(t, []ProfileVersion{v1, v2}, versions)
+	versions, err = s.GetProfileVersions("unknown")
+	assert.NoError(t, err)
+	assert.Empty(t, versions)
+}
+
+func TestBoltAuditAndPins(t *testing.T) {
+	s := setupBolt(t)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for i, actor := range []string{"user:alice", "user:bob", "user:alice"} {
+		assert.NoError(t, s.RecordAudit(AuditEntry{Actor: actor, Method: "POST", Path: "/calculate", Status: 200, CreatedAt: now.Add(time.Duration(i) * time.Minute)}))
+	}
+	assert.ErrorIs(t, s.RecordAudit(AuditEntry{Actor: "anonymous"}), ErrInvalidArgument)
+
+	entries, err := s.GetAuditEntries(AuditFilter{Actor: "user:alice"})
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, int64(3), entries[0].ID)
+		assert.Equal(t, int64(1), entries[1].ID)
+	}
+	entries, err = s.GetAuditEntries(AuditFilter{From: now.Add(time.Minute), To: now.Add(2 * time.Minute)})
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "user:bob", entries[0].Actor)
+	}
+	entries, err = s.GetAuditEntries(AuditFilter{Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	assert.NoError(t, s.PinAllocation(Pin{Profile: "small", Quantity: 5, Packs: map[int]int{5: 1}, Total: 5}))
+	assert.NoError(t, s.PinAllocation(Pin{Profile: "default", Quantity: 300, Packs: map[int]int{250: 2}, Total: 500}))
+	assert.NoError(t, s.PinAllocation(Pin{Profile: "default", Quantity: 12, Packs: map[int]int{23: 1}, Total: 23, Reason: "bundle"}))
+	assert.ErrorIs(t, s.PinAllocation(Pin{Profile: "default", Quantity: 0, Packs: map[int]int{23: 1}}), ErrInvalidArgument)
+
+	removed, err := s.UnpinAllocation("small", 5)
+	assert.NoError(t, err)
+	assert.True(t, removed)
+	removed, err = s.UnpinAllocation("small", 5)
+	assert.NoError(t, err)
+	assert.False(t, removed)
+
+	pins, err := s.GetPins()
+	assert.NoError(t, err)
+	if assert.Len(t, pins, 2) {
+		assert.Equal(t, 12, pins[0].Quantity)
+		assert.Equal(t, "bundle", pins[0].Reason)
+		assert.Equal(t, 300, pins[1].Quantity)
+	}
+}
+
+func TestBoltReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "allocations.bolt")
+	s, err := NewBoltStorage(path)
+	assert.NoError(t, err)
+	assert.NoError(t, s.StoreAllocation(50, map[int]int{53: 1}, 53))
+
+	// The file is locked while open
+	_, err = NewBoltStorage(path)
+	assert.ErrorContains(t, err, "locked by another process")
+	assert.NoError(t, s.Close())
+
+	s, err = NewBoltStorage(path)
+	assert.NoError(t, err)
+	assert.NoError(t, s.StoreAllocation(24, map[int]int{31: 1}, 31))
+	recent, err := s.GetRecentAllocations(10, false)
+	assert.NoError(t, err)
+	if assert.Len(t, recent, 2) {
+		assert.Equal(t, int64(2), recent[0].ID)
+	}
+	assert.NoError(t, s.Close())
+
+	// Temporary databases are removed on Close
+	temp, err := NewTempBoltStorage()
+	assert.NoError(t, err)
+	assert.NoError(t, temp.Close())
+	_, err = os.Stat(temp.tempDir)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestBoltUncacheableNotCached(t *testing.T) {
+	s := setupBolt(t)
+	assert.NoError(t, s.StoreAllocationInput(AllocationInput{Quantity: 50, Packs: map[int]int{53: 1}, Total: 53, Profile: "default", Cacheable: true}))
+	assert.NoError(t, s.StoreAllocationInput(AllocationInput{Quantity: 50, Packs: map[int]int{}, Profile: "default", Algorithm: AlgorithmImport}))
+	assert.NoError(t, s.StoreAllocationInput(AllocationInput{Quantity: 50, Packs: map[int]int{31: 2}, Total: 62, Profile: "default", Algorithm: "greedy", Approximate: true}))
+	assert.NoError(t, s.StoreAllocationInput(AllocationInput{Quantity: 50, Packs: map[int]int{23: 3}, Total: 69, Profile: "default", Algorithm: "branchbound"}))
+
+	a, err := s.GetCachedAllocation("default", 50)
+	assert.NoError(t, err)
+	if assert.NotNil(t, a) {
+		assert.Equal(t, 53, a.Total)
+	}
+}
@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("circuit open")
+
+// BreakerPolicy bounds how a Breaker retries a failing external backend and
+// when it stops calling it.
+type BreakerPolicy struct {
+	// Retries is how often a failed call is retried, after Backoff,
+	// doubling with each retry.
+	Retries int
+	Backoff time.Duration
+	// Threshold consecutive failed calls open the circuit; zero never
+	// opens it.
+	Threshold int
+	// Cooldown is how long an open circuit fails calls without making
+	// them, before a single call is let through to probe the backend.
+	Cooldown time.Duration
+}
+
+// DefaultBreakerPolicy retries once and opens the circuit for 30 seconds
+// after 5 consecutive failures.
+var DefaultBreakerPolicy = BreakerPolicy{
+	Retries:   1,
+	Backoff:   20 * time.Millisecond,
+	Threshold: 5,
+	Cooldown:  30 * time.Second,
+}
+
+// Validate returns an error for negative settings, or a threshold without a
+// cooldown.
+func (p BreakerPolicy) Validate() error {
+	if p.Retries < 0 || p.Backoff < 0 || p.Threshold < 0 || p.Cooldown < 0 {
+		return errors.New("breaker settings must not be negative")
+	}
+	if p.Threshold > 0 && p.Cooldown == 0 {
+		return errors.New("breaker cooldown must be positive")
+	}
+	return nil
+}
+
+// Breaker State values.
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// Breaker is a circuit breaker with bounded retries for calls to an external
+// backend, such as Redis, so a flapping backend is given up on quickly
+// instead of slowing down every request. It is safe for concurrent use.
+type Breaker struct {
+	name   string
+	policy BreakerPolicy
+	now    func() time.Time
+	sleep  func(time.Duration)
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+	lastErr   error
+}
+
+// NewBreaker returns a closed breaker for the backend called name in logs.
+func NewBreaker(name string, p BreakerPolicy) *Breaker {
+	return &Breaker{name: name, policy: p, now: time.Now, sleep: time.Sleep}
+}
+
+// Do calls fn, retrying it as the policy allows. While the circuit is open
+// it fails with ErrCircuitOpen without calling fn.
+func (b *Breaker) Do(fn func() error) error {
+	retries, err := b.allow()
+	if err != nil {
+		return err
+	}
+	backoff := b.policy.Backoff
+	for attempt := 0; ; attempt++ {
+		if err = fn(); err == nil || attempt >= retries {
+			break
+		}
+		b.sleep(backoff)
+		backoff *= 2
+	}
+	b.record(err)
+	return err
+}
+
+// allow reports how often a call may be retried, or ErrCircuitOpen if it may
+// not be made at all. After the cooldown one call, not retried, probes the
+// backend.
+func (b *Breaker) allow() (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open() {
+		return b.policy.Retries, nil
+	}
+	if b.probing || b.now().Before(b.openUntil) {
+		return 0, fmt.Errorf("%w: %s: %v", ErrCircuitOpen, b.name, b.lastErr)
+	}
+	b.probing = true
+	return 0, nil
+}
+
+// open reports whether enough calls failed in a row to open the circuit.
+func (b *Breaker) open() bool {
+	return b.policy.Threshold > 0 && b.failures >= b.policy.Threshold
+}
+
+// record counts the outcome of a call, opening or closing the circuit.
+func (b *Breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	wasOpen := b.open()
+	b.probing = false
+	if err == nil {
+		if wasOpen {
+			log.Printf("Circuit to %s closed", b.name)
+		}
+		b.failures, b.lastErr = 0, nil
+		return
+	}
+	b.failures++
+	b.lastErr = err
+	if b.open() {
+		b.openUntil = b.now().Add(b.policy.Cooldown)
+		if !wasOpen {
+			log.Printf("Circuit to %s opened after %d failures: %v", b.name, b.failures, err)
+		}
+	}
+}
+
+// State returns BreakerClosed, BreakerOpen or, once the cooldown is over,
+// BreakerHalfOpen.
+func (b *Breaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case !b.open():
+		return BreakerClosed
+	case b.probing || !b.now().Before(b.openUntil):
+		return BreakerHalfOpen
+	}
+	return BreakerOpen
+}
+
+// Degraded returns why the circuit is open, or nil while it is closed.
+func (b *Breaker) Degraded() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open() {
+		return nil
+	}
+	return fmt.Errorf("%w after %d failures: %v", ErrCircuitOpen, b.failures, b.lastErr)
+}
+
+// BreakerCache guards a Cache with a Breaker. While the circuit is open,
+// reads and writes fail fast with ErrCircuitOpen, so the allocator computes
+// allocations itself instead of waiting on the cache.
+type BreakerCache struct {
+	cache Cache
+	*Breaker
+}
+
+// NewBreakerCache wraps c, called name in logs, with a breaker following p.
+func NewBreakerCache(name string, c Cache, p BreakerPolicy) *BreakerCache {
+	return &BreakerCache{cache: c, Breaker: NewBreaker(name, p)}
+}
+
+// GetCachedAllocation retrieves the allocation cached for a quantity of a
+// profile through the breaker. A miss is not a failure.
+func (c *BreakerCache) GetCachedAllocation(profile string, quantity int) (*Allocation, error) {
+	var a *Allocation
+	err := c.Do(func() error {
+		var err error
+		a, err = c.cache.GetCachedAllocation(profile, quantity)
+		return err
+	})
+	return a, err
+}
+
+// CacheAllocation caches a through the breaker.
+func (c *BreakerCache) CacheAllocation(a Allocation) error {
+	return c.Do(func() error { return c.cache.CacheAllocation(a) })
+}
+
+// Close closes the wrapped cache.
+func (c *BreakerCache) Close() error {
+	return c.cache.Close()
+}
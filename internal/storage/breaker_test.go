@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	var slept []time.Duration
+	b := NewBreaker("test", BreakerPolicy{Retries: 2, Backoff: 10 * time.Millisecond, Threshold: 2, Cooldown: time.Minute})
+	b.now = func() time.Time { return now }
+	b.sleep = func(d time.Duration) { slept = append(slept, d) }
+
+	errDown := errors.New("connection refused")
+	calls := 0
+	failing := func() error { calls++; return errDown }
+	succeeding := func() error { calls++; return nil }
+
+	// Failed calls are retried with doubling backoff.
+	assert.ErrorIs(t, b.Do(failing), errDown)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}, slept)
+	assert.Equal(t, BreakerClosed, b.State())
+	assert.NoError(t, b.Degraded())
+
+	// A call that succeeds on retry resets the count.
+	calls = 0
+	assert.NoError(t, b.Do(func() error {
+		calls++
+		if calls == 1 {
+			return errDown
+		}
+		return nil
+	}))
+	assert.Equal(t, 2, calls)
+
+	// Threshold failed calls in a row open the circuit.
+	assert.Error(t, b.Do(failing))
+	assert.Error(t, b.Do(failing))
+	assert.Equal(t, BreakerOpen, b.State())
+	assert.ErrorIs(t, b.Degraded(), ErrCircuitOpen)
+	assert.ErrorContains(t, b.Degraded(), "connection refused")
+
+	calls = 0
+	assert.ErrorIs(t, b.Do(succeeding), ErrCircuitOpen)
+	assert.Zero(t, calls)
+
+	// After the cooldown one call, not retried, probes the backend; a
+	// failure opens the circuit again.
+	now = now.Add(time.Minute)
+	assert.Equal(t, BreakerHalfOpen, b.State())
+	assert.ErrorIs(t, b.Do(failing), errDown)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, BreakerOpen, b.State())
+
+	now = now.Add(time.Minute)
+	assert.NoError(t, b.Do(succeeding))
+	assert.Equal(t, BreakerClosed, b.State())
+	assert.NoError(t, b.Degraded())
+}
+
+func TestBreakerPolicyValidate(t *testing.T) {
+	assert.NoError(t, DefaultBreakerPolicy.Validate())
+	assert.NoError(t, BreakerPolicy{}.Validate())
+	assert.Error(t, BreakerPolicy{Retries: -1}.Validate())
+	assert.Error(t, BreakerPolicy{Threshold: 3}.Validate())
+}
+
+func TestBreakerCache(t *testing.T) {
+	server := miniredis.RunT(t)
+	redis, err := NewRedisCache(context.Background(), "redis://"+server.Addr(), "", 0)
+	assert.NoError(t, err)
+	if redis == nil {
+		return
+	}
+	cache := NewBreakerCache("redis", redis, BreakerPolicy{Threshold: 1, Cooldown: time.Hour})
+	defer cache.Close()
+
+	stored := Allocation{OrderQuantity: 50, Packs: map[int]int{23: 1, 31: 1}, Total: 54, Profile: "default", Cacheable: true}
+	assert.NoError(t, cache.CacheAllocation(stored))
+	allocation, err := cache.GetCachedAllocation("default", 50)
+	assert.NoError(t, err)
+	assert.Equal(t, stored.Packs, allocation.Packs)
+
+	// Misses are not failures.
+	allocation, err = cache.GetCachedAllocation("default", 51)
+	assert.NoError(t, err)
+	assert.Nil(t, allocation)
+	assert.Equal(t, BreakerClosed, cache.State())
+
+	server.SetError("LOADING")
+	_, err = cache.GetCachedAllocation("default", 50)
+	assert.Error(t, err)
+	assert.Equal(t, BreakerOpen, cache.State())
+
+	// Calls fail fast while the circuit is open, even once Redis is back.
+	server.SetError("")
+	_, err = cache.GetCachedAllocation("default", 50)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.ErrorIs(t, cache.CacheAllocation(stored), ErrCircuitOpen)
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// WriteMode selects how StoreAllocationInput handles an allocation identical
+// to one already stored.
+type WriteMode string
+
+const (
+	// WriteAppend inserts a row for every allocation stored.
+	WriteAppend WriteMode = "append"
+	// WriteDedup counts an allocation identical to the most recent matching
+	// row as a hit on that row, bumping its hit count and last access time,
+	// instead of inserting a duplicate. Allocations with an order ID,
+	// customer ID or metadata record an order and are always inserted.
+	WriteDedup WriteMode = "dedup"
+)
+
+// SetWriteMode sets how allocations are written; the empty mode is
+// WriteAppend. It must be called before the storage is used concurrently.
+func (s *SQLiteStorage) SetWriteMode(mode WriteMode) error {
+	mode, err := checkWriteMode(mode)
+	if err != nil {
+		return err
+	}
+	s.writeMode = mode
+	return nil
+}
+
+// checkWriteMode validates mode, defaulting the empty mode to WriteAppend.
+func checkWriteMode(mode WriteMode) (WriteMode, error) {
+	switch mode {
+	case "":
+		return WriteAppend, nil
+	case WriteAppend, WriteDedup:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown storage write mode %q (want %q or %q)", mode, WriteAppend, WriteDedup)
+}
+
+// dedupable reports whether in may be merged into an identical stored row
+// in the given mode.
+func dedupable(mode WriteMode, in AllocationInput) bool {
+	return mode == WriteDedup && in.OrderID == "" && in.CustomerID == "" && len(in.Metadata) == 0
+}
+
+// dedupHit counts row as a hit on the most recent identical row without an
+// order reference that is not deleted, and reports whether there was one.
+// Callers insert it otherwise, in the same transaction, so concurrent
+// identical writes insert a single row.
+func dedupHit(tx *sql.Tx, row allocationRow) (bool, error) {
+	in := row.in
+	res, err := tx.Exec(`
+		UPDATE allocations SET hits = hits + 1, last_accessed_at = ?
+		WHERE id = (
+			SELECT id FROM allocations
+			WHERE order_quantity = ? AND profile = ? AND profile_version = ? AND source = ?
+				AND algorithm = ? AND approximate = ? AND cacheable = ? AND unit = ? AND requested_quantity = ?
+				AND packs = ? AND total = ? AND order_id = '' AND customer_id = '' AND metadata = ''
+				AND deleted_at IS NULL
+			ORDER BY created_at DESC, id DESC LIMIT 1
+		)`,
+		sqliteTime(in.CreatedAt), in.Quantity, in.Profile, in.ProfileVersion, in.Source, in.Algorithm, in.Approximate, in.Cacheable, in.Unit, in.RequestedQuantity, row.packs, in.Total,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// scanNullTime converts a nullable time column such as last_accessed_at.
+func scanNullTime(v sql.NullTime) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	t := v.Time
+	return &t
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetWriteMode(t *testing.T) {
+	s, err := NewInMemorySQLite()
+	assert.NoError(t, err)
+	defer s.Close()
+
+	assert.NoError(t, s.SetWriteMode(""))
+	assert.Equal(t, WriteAppend, s.writeMode)
+	assert.NoError(t, s.SetWriteMode(WriteDedup))
+	assert.Error(t, s.SetWriteMode("upsert"))
+	assert.Equal(t, WriteDedup, s.writeMode)
+}
+
+func TestStoreAllocationAppend(t *testing.T) {
+	s, err := NewInMemorySQLite()
+	assert.NoError(t, err)
+	defer s.Close()
+
+	in := AllocationInput{Quantity: 50, Packs: map[int]int{23: 1, 31: 1}, Total: 54, Profile: "default"}
+	assert.NoError(t, s.StoreAllocationInput(in))
+	assert.NoError(t, s.StoreAllocationInput(in))
+
+	recent, err := s.GetRecentAllocations(10, false)
+	assert.NoError(t, err)
+	assert.Len(t, recent, 2)
+	for _, a := range recent {
+		assert.Equal(t, 1, a.Hits)
+		assert.Nil(t, a.LastAccessedAt)
+	}
+}
+
+func TestStoreAllocationDedup(t *testing.T) {
+	s, err := NewInMemorySQLite()
+	assert.NoError(t, err)
+	defer s.Close()
+	assert.NoError(t, s.SetWriteMode(WriteDedup))
+
+	created := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	accessed := created.Add(30 * time.Minute)
+	in := AllocationInput{Quantity: 50, Packs: map[int]int{23: 1, 31: 1}, Total: 54, Profile: "default", ProfileVersion: 2, CreatedAt: created}
+	assert.NoError(t, s.StoreAllocationInput(in))
+	in.CreatedAt = accessed
+	assert.NoError(t, s.StoreAllocationInput(in))
+	assert.NoError(t, s.StoreAllocationInput(in))
+
+	recent, err := s.GetRecentAllocations(10, false)
+	assert.NoError(t, err)
+	if assert.Len(t, recent, 1) {
+		assert.Equal(t, 3, recent[0].Hits)
+		assert.True(t, recent[0].CreatedAt.Equal(created))
+		if assert.NotNil(t, recent[0].LastAccessedAt) {
+			assert.True(t, recent[0].LastAccessedAt.Equal(accessed))
+		}
+	}
+
+	// A different profile version, source or result is a new row.
+	in.ProfileVersion = 3
+	assert.NoError(t, s.StoreAllocationInput(in))
+	in.Source = "manual"
+	assert.NoError(t, s.StoreAllocationInput(in))
+	in.Packs, in.Total = map[int]int{53: 1}, 53
+	assert.NoError(t, s.StoreAllocationInput(in))
+
+	// Allocations with an order reference are always inserted, and never
+	// counted as hits themselves.
+	order := AllocationInput{Quantity: 50, Packs: map[int]int{23: 1, 31: 1}, Total: 54, Profile: "default", ProfileVersion: 2, OrderID: "ORD-1"}
+	assert.NoError(t, s.StoreAllocationInput(order))
+	assert.NoError(t, s.StoreAllocationInput(order))
+	order.OrderID, order.Metadata = "", map[string]interface{}{"channel": "web"}
+	assert.NoError(t, s.StoreAllocationInput(order))
+
+	recent, err = s.GetRecentAllocations(10, false)
+	assert.NoError(t, err)
+	assert.Len(t, recent, 7)
+	for _, a := range recent {
+		if a.ProfileVersion == 2 && a.OrderID == "" && a.Metadata == nil {
+			assert.Equal(t, 3, a.Hits)
+		} else {
+			assert.Equal(t, 1, a.Hits)
+		}
+	}
+}
+
+func TestStoreAllocationsDedup(t *testing.T) {
+	s, err := NewInMemorySQLite()
+	assert.NoError(t, err)
+	defer s.Close()
+	assert.NoError(t, s.SetWriteMode(WriteDedup))
+
+	// Duplicates within a batch count as hits on the first
+	in := AllocationInput{Quantity: 50, Packs: map[int]int{23: 1, 31: 1}, Total: 54, Profile: "default"}
+	assert.NoError(t, s.StoreAllocations([]AllocationInput{in, in, {Quantity: 60, Packs: map[int]int{31: 2}, Total: 62, Profile: "default"}}))
+
+	recent, err := s.GetRecentAllocations(10, false)
+	assert.NoError(t, err)
+	assert.Len(t, recent, 2)
+	stored, err := s.GetAllocationByQuantity(50)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stored.Hits)
+}
+
+func TestStoreAllocationDedupExistingRows(t *testing.T) {
+	s, err := NewInMemorySQLite()
+	assert.NoError(t, err)
+	defer s.Close()
+
+	// Rows written before dedup was enabled count their hits from one.
+	in := AllocationInput{Quantity: 10, Packs: map[int]int{23: 1}, Total: 23}
+	assert.NoError(t, s.StoreAllocationInput(in))
+	assert.NoError(t, s.SetWriteMode(WriteDedup))
+	assert.NoError(t, s.StoreAllocationInput(in))
+
+	a, err := s.GetAllocationByQuantity(10)
+	assert.NoError(t, err)
+	if assert.NotNil(t, a) {
+		assert.Equal(t, 2, a.Hits)
+	}
+}
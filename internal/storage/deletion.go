@@ -0,0 +1,27 @@
+package storage
+
+import "time"
+
+// DeleteAllocation marks the allocation with the given ID deleted now.
+func (s *SQLiteStorage) DeleteAllocation(id int64) (bool, error) {
+	res, err := s.db.Exec(
+		"UPDATE allocations SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL",
+		sqliteTime(time.Now()), id,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// RestoreAllocation clears the deletion mark of the allocation with the
+// given ID.
+func (s *SQLiteStorage) RestoreAllocation(id int64) (bool, error) {
+	res, err := s.db.Exec("UPDATE allocations SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteAllocation(t *testing.T) {
+	s, err := NewInMemorySQLite()
+	assert.NoError(t, err)
+	defer s.Close()
+
+	assert.NoError(t, s.StoreAllocationInput(AllocationInput{Quantity: 50, Packs: map[int]int{53: 1}, Total: 53, Profile: "default", OrderID: "order-1", Cacheable: true}))
+	assert.NoError(t, s.StoreAllocationInput(AllocationInput{Quantity: 60, Packs: map[int]int{31: 2}, Total: 62, Profile: "default"}))
+	recent, err := s.GetRecentAllocations(10, false)
+	assert.NoError(t, err)
+	if !assert.Len(t, recent, 2) {
+		return
+	}
+	id := recent[1].ID
+	assert.Equal(t, 50, recent[1].OrderQuantity)
+	assert.Nil(t, recent[1].DeletedAt)
+
+	deleted, err := s.DeleteAllocation(id)
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	deleted, err = s.DeleteAllocation(id)
+	assert.NoError(t, err)
+	assert.False(t, deleted, "already deleted")
+	deleted, err = s.DeleteAllocation(id + 100)
+	assert.NoError(t, err)
+	assert.False(t, deleted, "unknown ID")
+
+	// Hidden from every read but recent allocations including deleted ones
+	recent, err = s.GetRecentAllocations(10, false)
+	assert.NoError(t, err)
+	assert.Len(t, recent, 1)
+	recent, err = s.GetRecentAllocations(10, true)
+	assert.NoError(t, err)
+	if assert.Len(t, recent, 2) {
+		assert.Equal(t, id, recent[1].ID)
+		if assert.NotNil(t, recent[1].DeletedAt) {
+			assert.WithinDuration(t, time.Now(), *recent[1].DeletedAt, 2*time.Second)
+		}
+	}
+	byOrder, err := s.GetAllocationsByOrderID("order-1")
+	assert.NoError(t, err)
+	assert.Empty(t, byOrder)
+	cached, err := s.GetCachedAllocation("default", 50)
+	assert.NoError(t, err)
+	assert.Nil(t, cached)
+	byQuantity, err := s.GetAllocationByQuantity(50)
+	assert.NoError(t, err)
+	assert.Nil(t, byQuantity)
+	exported := 0
+	assert.NoError(t, s.ExportAllocations(time.Time{}, time.Time{}, func(Allocation) error { exported++; return nil }))
+	assert.Equal(t, 1, exported)
+	count, err := s.CountAllocations(AllocationFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	count, err = s.CountAllocations(AllocationFilter{IncludeDeleted: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	stats, err := s.GetAlgorithmStats(time.Time{}, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, []AlgorithmStats{{Allocations: 1}}, stats)
+
+	restored, err := s.RestoreAllocation(id)
+	assert.NoError(t, err)
+	assert.True(t, restored)
+	restored, err = s.RestoreAllocation(id)
+	assert.NoError(t, err)
+	assert.False(t, restored, "not deleted")
+	byOrder, err = s.GetAllocationsByOrderID("order-1")
+	assert.NoError(t, err)
+	if assert.Len(t, byOrder, 1) {
+		assert.Nil(t, byOrder[0].DeletedAt)
+	}
+	cached, err = s.GetCachedAllocation("default", 50)
+	assert.NoError(t, err)
+	assert.NotNil(t, cached)
+}
+
+func TestDeletedAllocationNotDeduplicated(t *testing.T) {
+	s, err := NewInMemorySQLite()
+	assert.NoError(t, err)
+	defer s.Close()
+	assert.NoError(t, s.SetWriteMode(WriteDedup))
+
+	in := AllocationInput{Quantity: 50, Packs: map[int]int{53: 1}, Total: 53}
+	assert.NoError(t, s.StoreAllocationInput(in))
+	recent, err := s.GetRecentAllocations(10, false)
+	assert.NoError(t, err)
+	if !assert.Len(t, recent, 1) {
+		return
+	}
+	_, err = s.DeleteAllocation(recent[0].ID)
+	assert.NoError(t, err)
+
+	// An identical allocation is inserted rather than counted on the deleted row
+	assert.NoError(t, s.StoreAllocationInput(in))
+	recent, err = s.GetRecentAllocations(10, true)
+	assert.NoError(t, err)
+	if assert.Len(t, recent, 2) {
+		assert.Equal(t, 1, recent[0].Hits)
+		assert.Equal(t, 1, recent[1].Hits)
+	}
+}
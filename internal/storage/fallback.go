@@ -0,0 +1,407 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// replayBatchSize bounds the buffered writes loaded per replay round.
+const replayBatchSize = 100
+
+// FallbackStorage writes to a primary backend and, while the primary fails,
+// buffers allocation and audit writes in a local SQLite database instead of
+// losing them. Buffered writes keep their creation time and are replayed to
+// the primary, oldest first, by Replay once it recovers.
+//
+// Until the buffer is drained new writes are buffered too. Reads, profile
+// versions, pins and pruning always go to the primary, so buffered
+// allocations are not visible until they are replayed. Replay is at least
+// once: a crash between writing to the primary and removing the buffered copy
+// replays it again.
+type FallbackStorage struct {
+	primary Storage
+	buffer  *SQLiteStorage
+
+	// pending counts buffered writes not yet replayed.
+	pending  atomic.Int64
+	replayMu sync.Mutex
+}
+
+// NewFallbackStorage wraps primary with a local buffer. Writes left in the
+// buffer by a previous run are replayed by the next Replay.
+func NewFallbackStorage(primary Storage, buffer *SQLiteStorage) (*FallbackStorage, error) {
+	n, err := buffer.countBuffered()
+	if err != nil {
+		return nil, fmt.Errorf("count buffered writes: %w", err)
+	}
+	s := &FallbackStorage{primary: primary, buffer: buffer}
+	s.pending.Store(n)
+	if n > 0 {
+		log.Printf("Found %d buffered writes awaiting replay", n)
+	}
+	return s, nil
+}
+
+// Pending returns the number of buffered writes not yet replayed.
+func (s *FallbackStorage) Pending() int64 {
+	return s.pending.Load()
+}
+
+// write runs fn, which makes n writes, against the primary, falling back to
+// the buffer if the primary fails or older writes are still buffered.
+// Invalid input is reported rather than buffered.
+func (s *FallbackStorage) write(op string, n int64, fn func(Storage) error) error {
+	if s.pending.Load() <= 0 {
+		err := fn(s.primary)
+		if err == nil || errors.Is(err, ErrInvalidArgument) {
+			return err
+		}
+		log.Printf("Primary storage failed to %s, buffering locally: %v", op, err)
+	}
+
+	s.pending.Add(n)
+	if err := fn(s.buffer); err != nil {
+		s.pending.Add(-n)
+		return fmt.Errorf("buffer %s: %w", op, err)
+	}
+	return nil
+}
+
+// StoreAllocation saves an allocation, buffering it if the primary fails.
+func (s *FallbackStorage) StoreAllocation(quantity int, packs map[int]int, total int) error {
+	return s.StoreAllocationInput(AllocationInput{Quantity: quantity, Packs: packs, Total: total})
+}
+
+// StoreAllocationInput saves an allocation, buffering it if the primary fails.
+func (s *FallbackStorage) StoreAllocationInput(in AllocationInput) error {
+	if in.CreatedAt.IsZero() {
+		in.CreatedAt = time.Now()
+	}
+	return s.write("store allocation", 1, func(st Storage) error { return st.StoreAllocationInput(in) })
+}
+
+// StoreAllocations saves allocations in a single transaction, buffering them
+// all if the primary fails.
+func (s *FallbackStorage) StoreAllocations(ins []AllocationInput) error {
+	if len(ins) == 0 {
+		return nil
+	}
+	now := time.Now()
+	ins = append([]AllocationInput(nil), ins...)
+	for i := range ins {
+		if ins[i].CreatedAt.IsZero() {
+			ins[i].CreatedAt = now
+		}
+	}
+	return s.write("store allocations", int64(len(ins)), func(st Storage) error { return st.StoreAllocations(ins) })
+}
+
+// RecordAudit appends an audit entry, buffering it if the primary fails.
+func (s *FallbackStorage) RecordAudit(e AuditEntry) error {
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	return s.write("record audit entry", 1, func(st Storage) error { return st.RecordAudit(e) })
+}
+
+// Replay moves buffered writes to the primary, oldest first, and returns how
+// many were replayed. It stops at the first write the primary rejects; the
+// rest stay buffered for the next call.
+func (s *FallbackStorage) Replay() (int, error) {
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+
+	replayed := 0
+	for s.pending.Load() > 0 {
+		n, err := s.replayBatch()
+		replayed += n
+		if err != nil {
+			return replayed, err
+		}
+		if n == 0 {
+			// The counter drifted from the buffer, e.g. a write was
+			// counted but not yet inserted; trust the buffer.
+			count, err := s.buffer.countBuffered()
+			if err != nil {
+				return replayed, fmt.Errorf("count buffered writes: %w", err)
+			}
+			s.pending.Store(count)
+			break
+		}
+	}
+	return replayed, nil
+}
+
+// replayBatch replays up to replayBatchSize allocations and audit entries.
+func (s *FallbackStorage) replayBatch() (int, error) {
+	allocations, err := s.buffer.queryAllocations(
+		"SELECT "+allocationColumns+" FROM allocations ORDER BY created_at, id LIMIT ?", replayBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("read buffered allocations: %w", err)
+	}
+	entries, err := s.buffer.oldestAuditEntries(replayBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("read buffered audit entries: %w", err)
+	}
+
+	replayed := 0
+	for _, a := range allocations {
+		in := AllocationInput{
+			Quantity:          a.OrderQuantity,
+			Packs:             a.Packs,
+			Total:             a.Total,
+			OrderID:           a.OrderID,
+			CustomerID:        a.CustomerID,
+			Metadata:          a.Metadata,
+			Profile:           a.Profile,
+			ProfileVersion:    a.ProfileVersion,
+			Source:            a.Source,
+			Algorithm:         a.Algorithm,
+			Approximate:       a.Approximate,
+			Cacheable:         a.Cacheable,
+			Unit:              a.Unit,
+			RequestedQuantity: a.RequestedQuantity,
+			Rules:             a.Rules,
+			CreatedAt:         a.CreatedAt,
+		}
+		if err := s.primary.StoreAllocationInput(in); err != nil {
+			return replayed, fmt.Errorf("replay allocation %d: %w", a.ID, err)
+		}
+		if err := s.buffer.deleteBuffered("allocations", a.ID); err != nil {
+			return replayed, err
+		}
+		s.pending.Add(-1)
+		replayed++
+	}
+	for _, e := range entries {
+		id := e.ID
+		e.ID = 0
+		if err := s.primary.RecordAudit(e); err != nil {
+			return replayed, fmt.Errorf("replay audit entry %d: %w", id, err)
+		}
+		if err := s.buffer.deleteBuffered("audit_log", id); err != nil {
+			return replayed, err
+		}
+		s.pending.Add(-1)
+		replayed++
+	}
+	return replayed, nil
+}
+
+// RunReplayer replays buffered writes every interval until ctx is done.
+func (s *FallbackStorage) RunReplayer(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if s.Pending() <= 0 {
+				continue
+			}
+			n, err := s.Replay()
+			if n > 0 {
+				log.Printf("Replayed %d buffered writes to primary storage", n)
+			}
+			if err != nil {
+				log.Printf("Replaying buffered writes failed, %d left: %v", s.Pending(), err)
+			}
+		}
+	}
+}
+
+// GetRecentAllocations reads from the primary.
+func (s *FallbackStorage) GetRecentAllocations(limit int, includeDeleted bool) ([]Allocation, error) {
+	return s.primary.GetRecentAllocations(limit, includeDeleted)
+}
+
+// CountAllocations reads from the primary.
+func (s *FallbackStorage) CountAllocations(f AllocationFilter) (int, error) {
+	return s.primary.CountAllocations(f)
+}
+
+// GetAllocationByQuantity reads from the primary.
+func (s *FallbackStorage) GetAllocationByQuantity(quantity int) (*Allocation, error) {
+	return s.primary.GetAllocationByQuantity(quantity)
+}
+
+// GetCachedAllocation reads from the primary.
+func (s *FallbackStorage) GetCachedAllocation(profile string, quantity int) (*Allocation, error) {
+	return s.primary.GetCachedAllocation(profile, quantity)
+}
+
+// CacheAllocation caches in the primary. Cache entries can be recomputed,
+// so they are never buffered.
+func (s *FallbackStorage) CacheAllocation(a Allocation) error {
+	return s.primary.CacheAllocation(a)
+}
+
+// GetAllocationsByOrderID reads from the primary.
+func (s *FallbackStorage) GetAllocationsByOrderID(orderID string) ([]Allocation, error) {
+	return s.primary.GetAllocationsByOrderID(orderID)
+}
+
+// SearchAllocations reads from the primary.
+func (s *FallbackStorage) SearchAllocations(f AllocationFilter) ([]Allocation, int, error) {
+	return s.primary.SearchAllocations(f)
+}
+
+// ExportAllocations reads from the primary.
+func (s *FallbackStorage) ExportAllocations(from, to time.Time, fn func(Allocation) error) error {
+	return s.primary.ExportAllocations(from, to, fn)
+}
+
+// DeleteAllocation deletes in the primary. Allocations still buffered
+// cannot be addressed by ID, so it is never buffered.
+func (s *FallbackStorage) DeleteAllocation(id int64) (bool, error) {
+	return s.primary.DeleteAllocation(id)
+}
+
+// RestoreAllocation restores in the primary.
+func (s *FallbackStorage) RestoreAllocation(id int64) (bool, error) {
+	return s.primary.RestoreAllocation(id)
+}
+
+// DeleteOlderThan prunes the primary.
+func (s *FallbackStorage) DeleteOlderThan(t time.Time) (int64, error) {
+	return s.primary.DeleteOlderThan(t)
+}
+
+// DeleteAllButNewest prunes the primary.
+func (s *FallbackStorage) DeleteAllButNewest(n int) (int64, error) {
+	return s.primary.DeleteAllButNewest(n)
+}
+
+// RecordProfileVersion records the version in the primary. Versions are
+// numbered by the primary, so they are never buffered.
+func (s *FallbackStorage) RecordProfileVersion(name string, packSizes []int) (ProfileVersion, error) {
+	return s.primary.RecordProfileVersion(name, packSizes)
+}
+
+// GetProfileVersions reads from the primary.
+func (s *FallbackStorage) GetProfileVersions(name string) ([]ProfileVersion, error) {
+	return s.primary.GetProfileVersions(name)
+}
+
+// GetAuditEntries reads from the primary.
+func (s *FallbackStorage) GetAuditEntries(f AuditFilter) ([]AuditEntry, error) {
+	return s.primary.GetAuditEntries(f)
+}
+
+// PinAllocation pins in the primary. Pins are read back by every replica,
+// so they are never buffered.
+func (s *FallbackStorage) PinAllocation(p Pin) error {
+	return s.primary.PinAllocation(p)
+}
+
+// UnpinAllocation unpins in the primary.
+func (s *FallbackStorage) UnpinAllocation(profile string, quantity int) (bool, error) {
+	return s.primary.UnpinAllocation(profile, quantity)
+}
+
+// GetPins reads from the primary.
+func (s *FallbackStorage) GetPins() ([]Pin, error) {
+	return s.primary.GetPins()
+}
+
+// SetProfileDefaults stores the defaults in the primary. Like pins, they
+// are read back by every replica, so they are never buffered.
+func (s *FallbackStorage) SetProfileDefaults(d ProfileDefaults) error {
+	return s.primary.SetProfileDefaults(d)
+}
+
+// DeleteProfileDefaults removes the defaults from the primary.
+func (s *FallbackStorage) DeleteProfileDefaults(profile string) (bool, error) {
+	return s.primary.DeleteProfileDefaults(profile)
+}
+
+// GetProfileDefaults reads from the primary.
+func (s *FallbackStorage) GetProfileDefaults() ([]ProfileDefaults, error) {
+	return s.primary.GetProfileDefaults()
+}
+
+// GetAlgorithmStats reads from the primary.
+func (s *FallbackStorage) GetAlgorithmStats(from, to time.Time) ([]AlgorithmStats, error) {
+	return s.primary.GetAlgorithmStats(from, to)
+}
+
+// PackUsage reads from the primary.
+func (s *FallbackStorage) PackUsage(from, to time.Time) ([]PackUsage, error) {
+	return s.primary.PackUsage(from, to)
+}
+
+// AggregateWaste reads from the primary.
+func (s *FallbackStorage) AggregateWaste(groupBy string, from, to time.Time) ([]WasteStats, error) {
+	return s.primary.AggregateWaste(groupBy, from, to)
+}
+
+// TopQuantities reads from the primary.
+func (s *FallbackStorage) TopQuantities(since time.Time, n int) ([]QuantityCount, error) {
+	return s.primary.TopQuantities(since, n)
+}
+
+// RecordUsage records in the primary. Usage is not buffered: while the
+// primary is unavailable it goes uncounted.
+func (s *FallbackStorage) RecordUsage(client string, at time.Time, compute time.Duration) error {
+	return s.primary.RecordUsage(client, at, compute)
+}
+
+// GetUsage reads from the primary.
+func (s *FallbackStorage) GetUsage(at time.Time, client string) ([]Usage, error) {
+	return s.primary.GetUsage(at, client)
+}
+
+// Backup backs up the primary. Writes still buffered are not included.
+func (s *FallbackStorage) Backup(w io.Writer) error {
+	return s.primary.Backup(w)
+}
+
+// Close closes the primary and the buffer.
+func (s *FallbackStorage) Close() error {
+	return errors.Join(s.primary.Close(), s.buffer.Close())
+}
+
+// countBuffered returns the number of allocations and audit entries stored.
+func (s *SQLiteStorage) countBuffered() (int64, error) {
+	var n int64
+	err := s.db.QueryRow("SELECT (SELECT COUNT(*) FROM allocations) + (SELECT COUNT(*) FROM audit_log)").Scan(&n)
+	return n, err
+}
+
+// oldestAuditEntries returns up to limit audit entries, oldest first.
+func (s *SQLiteStorage) oldestAuditEntries(limit int) ([]AuditEntry, error) {
+	rows, err := s.db.Query(
+		"SELECT id, actor, client_ip, method, path, params, status, error, duration_ms, created_at FROM audit_log ORDER BY created_at, id LIMIT ?",
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.ID, &e.Actor, &e.ClientIP, &e.Method, &e.Path, &e.Params, &e.Status, &e.Error, &e.DurationMS, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// deleteBuffered removes a replayed row from table.
+func (s *SQLiteStorage) deleteBuffered(table string, id int64) error {
+	if _, err := s.db.Exec("DELETE FROM "+table+" WHERE id = ?", id); err != nil {
+		return fmt.Errorf("remove replayed row %d from %s: %w", id, table, err)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyStorage fails every write while down, like a database during an outage.
+type flakyStorage struct {
+	*SQLiteStorage
+	down atomic.Bool
+}
+
+var errPrimaryDown = errors.New("connection refused")
+
+func (s *flakyStorage) StoreAllocationInput(in AllocationInput) error {
+	if s.down.Load() {
+		return errPrimaryDown
+	}
+	return s.SQLiteStorage.StoreAllocationInput(in)
+}
+
+func (s *flakyStorage) StoreAllocations(ins []AllocationInput) error {
+	if s.down.Load() {
+		return errPrimaryDown
+	}
+	return s.SQLiteStorage.StoreAllocations(ins)
+}
+
+func (s *flakyStorage) RecordAudit(e AuditEntry) error {
+	if s.down.Load() {
+		return errPrimaryDown
+	}
+	return s.SQLiteStorage.RecordAudit(e)
+}
+
+func setupFallback(t *testing.T) (*FallbackStorage, *flakyStorage, *SQLiteStorage) {
+	primaryDB, err := NewInMemorySQLite()
+	assert.NoError(t, err)
+	buffer, err := NewInMemorySQLite()
+	assert.NoError(t, err)
+	primary := &flakyStorage{SQLiteStorage: primaryDB}
+
+	s, err := NewFallbackStorage(primary, buffer)
+	assert.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s, primary, buffer
+}
+
+func TestFallbackStorageWritesThroughWhenHealthy(t *testing.T) {
+	s, primary, buffer := setupFallback(t)
+
+	assert.NoError(t, s.StoreAllocation(50, map[int]int{53: 1}, 53))
+
+	stored, err := primary.GetAllocationByQuantity(50)
+	assert.NoError(t, err)
+	assert.NotNil(t, stored)
+	buffered, err := buffer.GetRecentAllocations(10, false)
+	assert.NoError(t, err)
+	assert.Empty(t, buffered)
+	assert.Equal(t, int64(0), s.Pending())
+}
+
+func TestFallbackStorageBuffersAndReplays(t *testing.T) {
+	s, primary, buffer := setupFallback(t)
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	primary.down.Store(true)
+	assert.NoError(t, s.StoreAllocationInput(AllocationInput{
+		Quantity:  50,
+		Packs:     map[int]int{53: 1},
+		Total:     53,
+		OrderID:   "ORD-1",
+		Profile:   "default",
+		CreatedAt: createdAt,
+	}))
+	assert.NoError(t, s.RecordAudit(AuditEntry{Actor: "anonymous", Method: "POST", Path: "/calculate", Status: 200}))
+	assert.Equal(t, int64(2), s.Pending())
+
+	// Writes stay buffered until the buffer is drained, even once the
+	// primary is back, so nothing is replayed out of order.
+	primary.down.Store(false)
+	assert.NoError(t, s.StoreAllocation(100, map[int]int{53: 2}, 106))
+	assert.Equal(t, int64(3), s.Pending())
+	recent, err := primary.GetRecentAllocations(10, false)
+	assert.NoError(t, err)
+	assert.Empty(t, recent)
+
+	n, err := s.Replay()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, int64(0), s.Pending())
+
+	replayed, err := primary.GetAllocationsByOrderID("ORD-1")
+	assert.NoError(t, err)
+	if assert.Len(t, replayed, 1) {
+		assert.Equal(t, map[int]int{53: 1}, replayed[0].Packs)
+		assert.Equal(t, "default", replayed[0].Profile)
+		assert.True(t, createdAt.Equal(replayed[0].CreatedAt))
+	}
+	entries, err := primary.GetAuditEntries(AuditFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	left, err := buffer.countBuffered()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), left)
+
+	// Healthy again: writes go straight to the primary.
+	assert.NoError(t, s.StoreAllocation(200, map[int]int{53: 4}, 212))
+	assert.Equal(t, int64(0), s.Pending())
+}
+
+func TestFallbackStorageBuffersBatches(t *testing.T) {
+	s, primary, _ := setupFallback(t)
+
+	primary.down.Store(true)
+	batch := []AllocationInput{
+		{Quantity: 50, Packs: map[int]int{53: 1}, Total: 53, OrderID: "ORD-1"},
+		{Quantity: 60, Packs: map[int]int{31: 2}, Total: 62, OrderID: "ORD-1"},
+	}
+	assert.NoError(t, s.StoreAllocations(batch))
+	assert.Equal(t, int64(2), s.Pending())
+	assert.True(t, batch[0].CreatedAt.IsZero(), "the caller's inputs are not modified")
+
+	primary.down.Store(false)
+	n, err := s.Replay()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	replayed, err := primary.GetAllocationsByOrderID("ORD-1")
+	assert.NoError(t, err)
+	assert.Len(t, replayed, 2)
+}
+
+func TestFallbackStorageReplayStopsWhilePrimaryIsDown(t *testing.T) {
+	s, primary, _ := setupFallback(t)
+
+	primary.down.Store(true)
+	assert.NoError(t, s.StoreAllocation(50, map[int]int{53: 1}, 53))
+
+	n, err := s.Replay()
+	assert.ErrorIs(t, err, errPrimaryDown)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, int64(1), s.Pending())
+}
+
+func TestFallbackStorageRejectsInvalidInput(t *testing.T) {
+	s, primary, _ := setupFallback(t)
+
+	primary.down.Store(false)
+	assert.ErrorIs(t, s.StoreAllocation(50, nil, 0), ErrInvalidArgument)
+	assert.Equal(t, int64(0), s.Pending())
+}
+
+func TestFallbackStorageResumesPendingWrites(t *testing.T) {
+	primaryDB, err := NewInMemorySQLite()
+	assert.NoError(t, err)
+	defer primaryDB.Close()
+	buffer, err := NewInMemorySQLite()
+	assert.NoError(t, err)
+	defer buffer.Close()
+
+	// Left behind by a previous run.
+	assert.NoError(t, buffer.StoreAllocation(50, map[int]int{53: 1}, 53))
+
+	s, err := NewFallbackStorage(primaryDB, buffer)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), s.Pending())
+
+	n, err := s.Replay()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	stored, err := primaryDB.GetAllocationByQuantity(50)
+	assert.NoError(t, err)
+	assert.NotNil(t, stored)
+}
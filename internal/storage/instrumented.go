@@ -0,0 +1,260 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Observer is notified of the duration and outcome of every call an
+// InstrumentedStorage makes, by method name. Implementations must be safe
+// for concurrent use.
+type Observer interface {
+	ObserveStorage(method string, d time.Duration, err error)
+}
+
+// Instrumentation configures an InstrumentedStorage.
+type Instrumentation struct {
+	// SlowThreshold logs calls taking at least this long; zero logs none.
+	SlowThreshold time.Duration
+	// Tracer records a span for every call; nil records none. Since storage
+	// calls carry no context, the spans are roots of their own traces.
+	Tracer trace.Tracer
+}
+
+// InstrumentedStorage wraps a Storage with per-method metrics, tracing spans
+// and slow-call logging, whatever its backend. The duration of
+// ExportAllocations and Backup includes the time spent consuming their
+// output. Close is passed through unmeasured.
+type InstrumentedStorage struct {
+	next     Storage
+	slow     time.Duration
+	tracer   trace.Tracer
+	observer atomic.Pointer[observerRef]
+}
+
+// observerRef holds an Observer for atomic replacement.
+type observerRef struct {
+	Observer
+}
+
+// NewInstrumentedStorage returns s instrumented as configured.
+func NewInstrumentedStorage(s Storage, in Instrumentation) *InstrumentedStorage {
+	return &InstrumentedStorage{next: s, slow: in.SlowThreshold, tracer: in.Tracer}
+}
+
+// SetObserver registers o to be notified of every call; nil stops the
+// notifications. It may be called while the storage is in use, e.g. once
+// the metrics are created.
+func (s *InstrumentedStorage) SetObserver(o Observer) {
+	if o == nil {
+		s.observer.Store(nil)
+		return
+	}
+	s.observer.Store(&observerRef{o})
+}
+
+// observe starts measuring a call of method. The returned function ends the
+// measurement with the call's error, so a method reports its outcome with
+//
+//	defer s.observe("Method")(&err)
+func (s *InstrumentedStorage) observe(method string) func(*error) {
+	start := time.Now()
+	var span trace.Span
+	if s.tracer != nil {
+		_, span = s.tracer.Start(context.Background(), "storage."+method,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attribute.String("db.operation", method)))
+	}
+	return func(errp *error) {
+		d := time.Since(start)
+		err := *errp
+		if span != nil {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}
+		if o := s.observer.Load(); o != nil {
+			o.ObserveStorage(method, d, err)
+		}
+		if s.slow > 0 && d >= s.slow {
+			if err != nil {
+				log.Printf("Slow storage call: %s took %s and failed: %v", method, d, err)
+			} else {
+				log.Printf("Slow storage call: %s took %s", method, d)
+			}
+		}
+	}
+}
+
+func (s *InstrumentedStorage) StoreAllocation(quantity int, packs map[int]int, total int) (err error) {
+	defer s.observe("StoreAllocation")(&err)
+	return s.next.StoreAllocation(quantity, packs, total)
+}
+
+func (s *InstrumentedStorage) StoreAllocationInput(in AllocationInput) (err error) {
+	defer s.observe("StoreAllocationInput")(&err)
+	return s.next.StoreAllocationInput(in)
+}
+
+func (s *InstrumentedStorage) StoreAllocations(ins []AllocationInput) (err error) {
+	defer s.observe("StoreAllocations")(&err)
+	return s.next.StoreAllocations(ins)
+}
+
+func (s *InstrumentedStorage) GetRecentAllocations(limit int, includeDeleted bool) (_ []Allocation, err error) {
+	defer s.observe("GetRecentAllocations")(&err)
+	return s.next.GetRecentAllocations(limit, includeDeleted)
+}
+
+func (s *InstrumentedStorage) CountAllocations(f AllocationFilter) (_ int, err error) {
+	defer s.observe("CountAllocations")(&err)
+	return s.next.CountAllocations(f)
+}
+
+func (s *InstrumentedStorage) GetAllocationByQuantity(quantity int) (_ *Allocation, err error) {
+	defer s.observe("GetAllocationByQuantity")(&err)
+	return s.next.GetAllocationByQuantity(quantity)
+}
+
+func (s *InstrumentedStorage) GetCachedAllocation(profile string, quantity int) (_ *Allocation, err error) {
+	defer s.observe("GetCachedAllocation")(&err)
+	return s.next.GetCachedAllocation(profile, quantity)
+}
+
+func (s *InstrumentedStorage) CacheAllocation(a Allocation) (err error) {
+	defer s.observe("CacheAllocation")(&err)
+	return s.next.CacheAllocation(a)
+}
+
+func (s *InstrumentedStorage) GetAllocationsByOrderID(orderID string) (_ []Allocation, err error) {
+	defer s.observe("GetAllocationsByOrderID")(&err)
+	return s.next.GetAllocationsByOrderID(orderID)
+}
+
+func (s *InstrumentedStorage) SearchAllocations(f AllocationFilter) (_ []Allocation, _ int, err error) {
+	defer s.observe("SearchAllocations")(&err)
+	return s.next.SearchAllocations(f)
+}
+
+func (s *InstrumentedStorage) ExportAllocations(from, to time.Time, fn func(Allocation) error) (err error) {
+	defer s.observe("ExportAllocations")(&err)
+	return s.next.ExportAllocations(from, to, fn)
+}
+
+func (s *InstrumentedStorage) DeleteAllocation(id int64) (_ bool, err error) {
+	defer s.observe("DeleteAllocation")(&err)
+	return s.next.DeleteAllocation(id)
+}
+
+func (s *InstrumentedStorage) RestoreAllocation(id int64) (_ bool, err error) {
+	defer s.observe("RestoreAllocation")(&err)
+	return s.next.RestoreAllocation(id)
+}
+
+func (s *InstrumentedStorage) DeleteOlderThan(t time.Time) (_ int64, err error) {
+	defer s.observe("DeleteOlderThan")(&err)
+	return s.next.DeleteOlderThan(t)
+}
+
+func (s *InstrumentedStorage) DeleteAllButNewest(n int) (_ int64, err error) {
+	defer s.observe("DeleteAllButNewest")(&err)
+	return s.next.DeleteAllButNewest(n)
+}
+
+func (s *InstrumentedStorage) RecordProfileVersion(name string, packSizes []int) (_ ProfileVersion, err error) {
+	defer s.observe("RecordProfileVersion")(&err)
+	return s.next.RecordProfileVersion(name, packSizes)
+}
+
+func (s *InstrumentedStorage) GetProfileVersions(name string) (_ []ProfileVersion, err error) {
+	defer s.observe("GetProfileVersions")(&err)
+	return s.next.GetProfileVersions(name)
+}
+
+func (s *InstrumentedStorage) RecordAudit(e AuditEntry) (err error) {
+	defer s.observe("RecordAudit")(&err)
+	return s.next.RecordAudit(e)
+}
+
+func (s *InstrumentedStorage) GetAuditEntries(f AuditFilter) (_ []AuditEntry, err error) {
+	defer s.observe("GetAuditEntries")(&err)
+	return s.next.GetAuditEntries(f)
+}
+
+func (s *InstrumentedStorage) PinAllocation(p Pin) (err error) {
+	defer s.observe("PinAllocation")(&err)
+	return s.next.PinAllocation(p)
+}
+
+func (s *InstrumentedStorage) UnpinAllocation(profile string, quantity int) (_ bool, err error) {
+	defer s.observe("UnpinAllocation")(&err)
+	return s.next.UnpinAllocation(profile, quantity)
+}
+
+func (s *InstrumentedStorage) GetPins() (_ []Pin, err error) {
+	defer s.observe("GetPins")(&err)
+	return s.next.GetPins()
+}
+
+func (s *InstrumentedStorage) SetProfileDefaults(d ProfileDefaults) (err error) {
+	defer s.observe("SetProfileDefaults")(&err)
+	return s.next.SetProfileDefaults(d)
+}
+
+func (s *InstrumentedStorage) DeleteProfileDefaults(profile string) (_ bool, err error) {
+	defer s.observe("DeleteProfileDefaults")(&err)
+	return s.next.DeleteProfileDefaults(profile)
+}
+
+func (s *InstrumentedStorage) GetProfileDefaults() (_ []ProfileDefaults, err error) {
+	defer s.observe("GetProfileDefaults")(&err)
+	return s.next.GetProfileDefaults()
+}
+
+func (s *InstrumentedStorage) GetAlgorithmStats(from, to time.Time) (_ []AlgorithmStats, err error) {
+	defer s.observe("GetAlgorithmStats")(&err)
+	return s.next.GetAlgorithmStats(from, to)
+}
+
+func (s *InstrumentedStorage) AggregateWaste(groupBy string, from, to time.Time) (_ []WasteStats, err error) {
+	defer s.observe("AggregateWaste")(&err)
+	return s.next.AggregateWaste(groupBy, from, to)
+}
+
+func (s *InstrumentedStorage) PackUsage(from, to time.Time) (_ []PackUsage, err error) {
+	defer s.observe("PackUsage")(&err)
+	return s.next.PackUsage(from, to)
+}
+
+func (s *InstrumentedStorage) TopQuantities(since time.Time, n int) (_ []QuantityCount, err error) {
+	defer s.observe("TopQuantities")(&err)
+	return s.next.TopQuantities(since, n)
+}
+
+func (s *InstrumentedStorage) RecordUsage(client string, at time.Time, compute time.Duration) (err error) {
+	defer s.observe("RecordUsage")(&err)
+	return s.next.RecordUsage(client, at, compute)
+}
+
+func (s *InstrumentedStorage) GetUsage(at time.Time, client string) (_ []Usage, err error) {
+	defer s.observe("GetUsage")(&err)
+	return s.next.GetUsage(at, client)
+}
+
+func (s *InstrumentedStorage) Backup(w io.Writer) (err error) {
+	defer s.observe("Backup")(&err)
+	return s.next.Backup(w)
+}
+
+func (s *InstrumentedStorage) Close() error {
+	return s.next.Close()
+}
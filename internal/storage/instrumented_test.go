@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"bytes"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordingObserver records the calls an InstrumentedStorage reports.
+type recordingObserver struct {
+	mu     sync.Mutex
+	calls  []string
+	failed []string
+}
+
+func (o *recordingObserver) ObserveStorage(method string, d time.Duration, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.calls = append(o.calls, method)
+	if err != nil {
+		o.failed = append(o.failed, method)
+	}
+}
+
+func TestInstrumentedStorage(t *testing.T) {
+	db, err := NewInMemorySQLite()
+	assert.NoError(t, err)
+	primary := &flakyStorage{SQLiteStorage: db}
+	spans := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)).Tracer("test")
+
+	s := NewInstrumentedStorage(primary, Instrumentation{Tracer: tracer})
+	defer s.Close()
+	// Calls before an observer is set are traced only.
+	assert.NoError(t, s.StoreAllocation(50, map[int]int{53: 1}, 53))
+	observer := &recordingObserver{}
+	s.SetObserver(observer)
+
+	a, err := s.GetAllocationByQuantity(50)
+	assert.NoError(t, err)
+	if assert.NotNil(t, a) {
+		assert.Equal(t, 53, a.Total)
+	}
+	primary.down.Store(true)
+	assert.ErrorIs(t, s.StoreAllocations([]AllocationInput{{Quantity: 1, Packs: map[int]int{1: 1}, Total: 1}}), errPrimaryDown)
+	primary.down.Store(false)
+
+	assert.Equal(t, []string{"GetAllocationByQuantity", "StoreAllocations"}, observer.calls)
+	assert.Equal(t, []string{"StoreAllocations"}, observer.failed)
+
+	ended := spans.Ended()
+	if assert.Len(t, ended, 3) {
+		assert.Equal(t, "storage.StoreAllocation", ended[0].Name())
+		assert.Equal(t, codes.Unset, ended[0].Status().Code)
+		assert.Equal(t, "storage.StoreAllocations", ended[2].Name())
+		assert.Equal(t, codes.Error, ended[2].Status().Code)
+	}
+
+	s.SetObserver(nil)
+	_, err = s.GetPins()
+	assert.NoError(t, err)
+	assert.Len(t, observer.calls, 2)
+}
+
+func TestInstrumentedStorageSlowCalls(t *testing.T) {
+	db, err := NewInMemorySQLite()
+	assert.NoError(t, err)
+	var logs bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logs)
+
+	s := NewInstrumentedStorage(db, Instrumentation{SlowThreshold: time.Nanosecond})
+	defer s.Close()
+	_, err = s.GetPins()
+	assert.NoError(t, err)
+	assert.Contains(t, logs.String(), "Slow storage call: GetPins took")
+
+	logs.Reset()
+	s = NewInstrumentedStorage(db, Instrumentation{})
+	_, err = s.GetPins()
+	assert.NoError(t, err)
+	assert.Empty(t, logs.String())
+}
@@ -0,0 +1,49 @@
+package storage
+
+import "time"
+
+// PackUsage sums the packs of one size in the allocations of a profile.
+// Allocations counted as hits on an identical row (see WriteDedup) count
+// once per hit.
+type PackUsage struct {
+	Profile string `json:"profile"`
+	Size    int    `json:"size"`
+	// Packs sums the packs of the size, and Allocations counts the
+	// allocations using it.
+	Packs       int64 `json:"packs"`
+	Allocations int64 `json:"allocations"`
+}
+
+// PackUsage sums the packs of every size in the allocations created in
+// [from, to), by profile and then size, largest first. Deleted allocations
+// are not counted.
+func (s *SQLiteStorage) PackUsage(from, to time.Time) ([]PackUsage, error) {
+	query := "SELECT profile, CAST(p.key AS INTEGER) AS size, SUM(p.value * hits), SUM(hits)" +
+		" FROM allocations, json_each(allocations.packs) AS p WHERE deleted_at IS NULL AND p.value > 0"
+	var args []interface{}
+	if !from.IsZero() {
+		query += " AND created_at >= ?"
+		args = append(args, sqliteTime(from))
+	}
+	if !to.IsZero() {
+		query += " AND created_at < ?"
+		args = append(args, sqliteTime(to))
+	}
+	query += " GROUP BY profile, size ORDER BY profile, size DESC"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := []PackUsage{}
+	for rows.Next() {
+		var u PackUsage
+		if err := rows.Scan(&u.Profile, &u.Size, &u.Packs, &u.Allocations); err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testPackUsage stores a few allocations in s, written with WriteDedup, and
+// checks their packs by profile and size.
+func testPackUsage(t *testing.T, s interface {
+	Storage
+	SetWriteMode(WriteMode) error
+}) {
+	assert.NoError(t, s.SetWriteMode(WriteDedup))
+	day := time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC)
+	for _, in := range []AllocationInput{
+		{Quantity: 50, Packs: map[int]int{53: 1}, Total: 53, Profile: "default", CreatedAt: day.AddDate(0, 0, -1)},
+		{Quantity: 100, Packs: map[int]int{53: 1, 23: 2}, Total: 99, Profile: "default", CreatedAt: day},
+		{Quantity: 100, Packs: map[int]int{53: 1, 23: 2}, Total: 99, Profile: "default", CreatedAt: day},
+		{Quantity: 250, Packs: map[int]int{250: 1}, Total: 250, Profile: "bulk", CreatedAt: day},
+	} {
+		assert.NoError(t, s.StoreAllocationInput(in))
+	}
+
+	usage, err := s.PackUsage(time.Time{}, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, []PackUsage{
+		{Profile: "bulk", Size: 250, Packs: 1, Allocations: 1},
+		{Profile: "default", Size: 53, Packs: 3, Allocations: 3},
+		{Profile: "default", Size: 23, Packs: 4, Allocations: 2},
+	}, usage)
+
+	usage, err = s.PackUsage(day, day.AddDate(0, 0, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, []PackUsage{
+		{Profile: "bulk", Size: 250, Packs: 1, Allocations: 1},
+		{Profile: "default", Size: 53, Packs: 2, Allocations: 2},
+		{Profile: "default", Size: 23, Packs: 4, Allocations: 2},
+	}, usage)
+}
+
+func TestPackUsage(t *testing.T) {
+	s, err := NewInMemorySQLite()
+	assert.NoError(t, err)
+	defer s.Close()
+	testPackUsage(t, s)
+}
+
+func TestBoltPackUsage(t *testing.T) {
+	testPackUsage(t, setupBolt(t))
+}
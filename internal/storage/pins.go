@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Pin is a manual pack breakdown served for a quantity of a profile instead
+// of a calculated one, until it is removed.
+type Pin struct {
+	Quantity int
+	Profile  string
+	Packs    map[int]int
+	Total    int
+	// Reason records why the pin was made, e.g. a marketing bundle.
+	Reason    string `json:",omitempty"`
+	CreatedAt time.Time
+}
+
+// PinAllocation stores p, replacing the pin for the same profile and
+// quantity if there is one.
+func (s *SQLiteStorage) PinAllocation(p Pin) error {
+	if p.Profile == "" || p.Quantity <= 0 || len(p.Packs) == 0 {
+		return ErrInvalidArgument
+	}
+	packsJSON, err := json.Marshal(p.Packs)
+	if err != nil {
+		return err
+	}
+	if p.CreatedAt.IsZero() {
+		p.CreatedAt = time.Now()
+	}
+	_, err = s.db.Exec(
+		"INSERT OR REPLACE INTO pins (profile, quantity, packs, total, reason, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		p.Profile, p.Quantity, string(packsJSON), p.Total, p.Reason, sqliteTime(p.CreatedAt),
+	)
+	return err
+}
+
+// UnpinAllocation removes the pin for a quantity of a profile.
+func (s *SQLiteStorage) UnpinAllocation(profile string, quantity int) (bool, error) {
+	res, err := s.db.Exec("DELETE FROM pins WHERE profile = ? AND quantity = ?", profile, quantity)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// GetPins retrieves every pin, ordered by profile and quantity.
+func (s *SQLiteStorage) GetPins() ([]Pin, error) {
+	rows, err := s.db.Query("SELECT profile, quantity, packs, total, reason, created_at FROM pins ORDER BY profile, quantity")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pins := []Pin{}
+	for rows.Next() {
+		var p Pin
+		var packsJSON string
+		if err := rows.Scan(&p.Profile, &p.Quantity, &packsJSON, &p.Total, &p.Reason, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(packsJSON), &p.Packs); err != nil {
+			return nil, err
+		}
+		pins = append(pins, p)
+	}
+	return pins, rows.Err()
+}
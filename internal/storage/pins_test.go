@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPins(t *testing.T) {
+	storage, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	assert.ErrorIs(t, storage.PinAllocation(Pin{Profile: "default", Quantity: 10}), ErrInvalidArgument)
+
+	assert.NoError(t, storage.PinAllocation(Pin{Profile: "default", Quantity: 263, Packs: map[int]int{250: 1, 23: 1}, Total: 273, Reason: "bundle"}))
+	assert.NoError(t, storage.PinAllocation(Pin{Profile: "apparel", Quantity: 10, Packs: map[int]int{12: 1}, Total: 12}))
+	// Pinning the same quantity again replaces the pin
+	assert.NoError(t, storage.PinAllocation(Pin{Profile: "default", Quantity: 263, Packs: map[int]int{263: 1}, Total: 263}))
+
+	pins, err := storage.GetPins()
+	assert.NoError(t, err)
+	assert.Len(t, pins, 2)
+	assert.Equal(t, "apparel", pins[0].Profile)
+	assert.Equal(t, 263, pins[1].Quantity)
+	assert.Equal(t, map[int]int{263: 1}, pins[1].Packs)
+	assert.Equal(t, "", pins[1].Reason)
+	assert.False(t, pins[1].CreatedAt.IsZero())
+
+	removed, err := storage.UnpinAllocation("default", 263)
+	assert.NoError(t, err)
+	assert.True(t, removed)
+	removed, err = storage.UnpinAllocation("default", 263)
+	assert.NoError(t, err)
+	assert.False(t, removed)
+
+	pins, err = storage.GetPins()
+	assert.NoError(t, err)
+	assert.Len(t, pins, 1)
+}
+
+func TestAllocationSource(t *testing.T) {
+	storage, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	assert.NoError(t, storage.StoreAllocationInput(AllocationInput{Quantity: 263, Packs: map[int]int{263: 1}, Total: 263, Source: "manual"}))
+
+	stored, err := storage.GetAllocationByQuantity(263)
+	assert.NoError(t, err)
+	assert.Equal(t, "manual", stored.Source)
+}
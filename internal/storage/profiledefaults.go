@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ProfileDefaults are the calculation settings applied to the requests of a
+// profile that do not set them.
+type ProfileDefaults struct {
+	Profile string
+	// Strategy names the default allocation strategy.
+	Strategy string `json:",omitempty"`
+	// Weights are the default objective weights.
+	Weights *Weights `json:",omitempty"`
+	// Timeout is the soft deadline of the profile's calculations.
+	Timeout   time.Duration `json:",omitempty"`
+	UpdatedAt time.Time
+}
+
+// Weights score combinations as Waste*waste + Packs*packs + Cost*cost.
+type Weights struct {
+	Waste float64
+	Packs float64
+	Cost  float64
+}
+
+// SetProfileDefaults stores d, replacing the defaults of the same profile
+// if there are some.
+func (s *SQLiteStorage) SetProfileDefaults(d ProfileDefaults) error {
+	if d.Profile == "" || d.Timeout < 0 {
+		return ErrInvalidArgument
+	}
+	weights := ""
+	if d.Weights != nil {
+		data, err := json.Marshal(d.Weights)
+		if err != nil {
+			return err
+		}
+		weights = string(data)
+	}
+	if d.UpdatedAt.IsZero() {
+		d.UpdatedAt = time.Now()
+	}
+	_, err := s.db.Exec(
+		"INSERT OR REPLACE INTO profile_defaults (profile, strategy, weights, timeout_ms, updated_at) VALUES (?, ?, ?, ?, ?)",
+		d.Profile, d.Strategy, weights, d.Timeout.Milliseconds(), sqliteTime(d.UpdatedAt),
+	)
+	return err
+}
+
+// DeleteProfileDefaults removes the defaults of a profile.
+func (s *SQLiteStorage) DeleteProfileDefaults(profile string) (bool, error) {
+	res, err := s.db.Exec("DELETE FROM profile_defaults WHERE profile = ?", profile)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// GetProfileDefaults retrieves the defaults of every profile that has some,
+// ordered by profile.
+func (s *SQLiteStorage) GetProfileDefaults() ([]ProfileDefaults, error) {
+	rows, err := s.db.Query("SELECT profile, strategy, weights, timeout_ms, updated_at FROM profile_defaults ORDER BY profile")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	defaults := []ProfileDefaults{}
+	for rows.Next() {
+		var d ProfileDefaults
+		var weights string
+		var timeoutMS int64
+		if err := rows.Scan(&d.Profile, &d.Strategy, &weights, &timeoutMS, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if weights != "" {
+			if err := json.Unmarshal([]byte(weights), &d.Weights); err != nil {
+				return nil, err
+			}
+		}
+		d.Timeout = time.Duration(timeoutMS) * time.Millisecond
+		defaults = append(defaults, d)
+	}
+	return defaults, rows.Err()
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileDefaults(t *testing.T) {
+	sqlite, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	for name, s := range map[string]History{"sqlite": sqlite, "bolt": setupBolt(t)} {
+		assert.ErrorIs(t, s.SetProfileDefaults(ProfileDefaults{Strategy: "dp"}), ErrInvalidArgument, name)
+		assert.ErrorIs(t, s.SetProfileDefaults(ProfileDefaults{Profile: "default", Timeout: -time.Second}), ErrInvalidArgument, name)
+
+		assert.NoError(t, s.SetProfileDefaults(ProfileDefaults{Profile: "default", Strategy: "dp"}))
+		assert.NoError(t, s.SetProfileDefaults(ProfileDefaults{Profile: "apparel", Strategy: "greedy"}))
+		// Setting the defaults again replaces them.
+		weights := &Weights{Waste: 1, Packs: 250.5}
+		assert.NoError(t, s.SetProfileDefaults(ProfileDefaults{Profile: "apparel", Weights: weights, Timeout: 1500 * time.Millisecond}))
+
+		defaults, err := s.GetProfileDefaults()
+		assert.NoError(t, err, name)
+		if assert.Len(t, defaults, 2, name) {
+			assert.Equal(t, "apparel", defaults[0].Profile, name)
+			assert.Empty(t, defaults[0].Strategy, name)
+			assert.Equal(t, weights, defaults[0].Weights, name)
+			assert.Equal(t, 1500*time.Millisecond, defaults[0].Timeout, name)
+			assert.False(t, defaults[0].UpdatedAt.IsZero(), name)
+			assert.Equal(t, ProfileDefaults{Profile: "default", Strategy: "dp", UpdatedAt: defaults[1].UpdatedAt}, defaults[1], name)
+		}
+
+		removed, err := s.DeleteProfileDefaults("apparel")
+		assert.NoError(t, err, name)
+		assert.True(t, removed, name)
+		removed, err = s.DeleteProfileDefaults("apparel")
+		assert.NoError(t, err, name)
+		assert.False(t, removed, name)
+
+		defaults, err = s.GetProfileDefaults()
+		assert.NoError(t, err, name)
+		assert.Len(t, defaults, 1, name)
+	}
+}
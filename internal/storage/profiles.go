@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// ProfileVersion is an immutable snapshot of a pack-size profile.
+// A version is effective from EffectiveFrom until the next version's EffectiveFrom.
+type ProfileVersion struct {
+	Name          string
+	Version       int
+	PackSizes     []int
+	EffectiveFrom time.Time
+}
+
+// RecordProfileVersion stores a new version of a profile when its pack sizes
+// changed since the latest version. Pack sizes are compared as sets.
+func (s *SQLiteStorage) RecordProfileVersion(name string, packSizes []int) (ProfileVersion, error) {
+	if name == "" || len(packSizes) == 0 {
+		return ProfileVersion{}, ErrInvalidArgument
+	}
+
+	sizes := make([]int, len(packSizes))
+	copy(sizes, packSizes)
+	sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return ProfileVersion{}, err
+	}
+	defer tx.Rollback()
+
+	latest, err := scanProfileVersion(tx.QueryRow(
+		"SELECT "+profileVersionColumns+" FROM profile_versions WHERE name = ? ORDER BY version DESC LIMIT 1",
+		name,
+	))
+	switch {
+	case err == sql.ErrNoRows:
+		latest = &ProfileVersion{Name: name}
+	case err != nil:
+		return ProfileVersion{}, err
+	case equalSizes(latest.PackSizes, sizes):
+		return *latest, nil
+	}
+
+	sizesJSON, err := json.Marshal(sizes)
+	if err != nil {
+		return ProfileVersion{}, err
+	}
+
+	v := ProfileVersion{
+		Name:          name,
+		Version:       latest.Version + 1,
+		PackSizes:     sizes,
+		EffectiveFrom: time.Now().UTC().Truncate(time.Second),
+	}
+	_, err = tx.Exec(
+		"INSERT INTO profile_versions (name, version, pack_sizes, effective_from) VALUES (?, ?, ?, ?)",
+		v.Name, v.Version, string(sizesJSON), sqliteTime(v.EffectiveFrom),
+	)
+	if err != nil {
+		return ProfileVersion{}, err
+	}
+
+	return v, tx.Commit()
+}
+
+// GetProfileVersions retrieves every version of a profile, oldest first.
+func (s *SQLiteStorage) GetProfileVersions(name string) ([]ProfileVersion, error) {
+	rows, err := s.db.Query(
+		"SELECT "+profileVersionColumns+" FROM profile_versions WHERE name = ? ORDER BY version",
+		name,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []ProfileVersion{}
+	for rows.Next() {
+		v, err := scanProfileVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, *v)
+	}
+
+	return versions, rows.Err()
+}
+
+// profileVersionColumns is the column list understood by scanProfileVersion.
+const profileVersionColumns = "name, version, pack_sizes, effective_from"
+
+func scanProfileVersion(row rowScanner) (*ProfileVersion, error) {
+	var v ProfileVersion
+	var sizesJSON string
+	if err := row.Scan(&v.Name, &v.Version, &sizesJSON, &v.EffectiveFrom); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(sizesJSON), &v.PackSizes); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// equalSizes reports whether two descending-sorted size lists are identical.
+func equalSizes(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordProfileVersion(t *testing.T) {
+	storage, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	v1, err := storage.RecordProfileVersion("default", []int{23, 31, 53})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v1.Version)
+	assert.Equal(t, []int{53, 31, 23}, v1.PackSizes)
+
+	// Same sizes in a different order do not create a new version
+	same, err := storage.RecordProfileVersion("default", []int{53, 23, 31})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, same.Version)
+
+	v2, err := storage.RecordProfileVersion("default", []int{23, 31, 53, 100})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, v2.Version)
+
+	_, err = storage.RecordProfileVersion("apparel", []int{250})
+	assert.NoError(t, err)
+
+	versions, err := storage.GetProfileVersions("default")
+	assert.NoError(t, err)
+	assert.Len(t, versions, 2)
+	assert.Equal(t, []int{53, 31, 23}, versions[0].PackSizes)
+	assert.Equal(t, []int{100, 53, 31, 23}, versions[1].PackSizes)
+	assert.False(t, versions[0].EffectiveFrom.IsZero())
+
+	versions, err = storage.GetProfileVersions("missing")
+	assert.NoError(t, err)
+	assert.Empty(t, versions)
+
+	_, err = storage.RecordProfileVersion("", []int{1})
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+	_, err = storage.RecordProfileVersion("empty", nil)
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+}
+
+func TestAllocationProfileVersion(t *testing.T) {
+	storage, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	err := storage.StoreAllocationInput(AllocationInput{
+		Quantity:       50,
+		Packs:          map[int]int{53: 1},
+		Total:          53,
+		Profile:        "default",
+		ProfileVersion: 3,
+	})
+	assert.NoError(t, err)
+
+	allocation, err := storage.GetAllocationByQuantity(50)
+	assert.NoError(t, err)
+	assert.Equal(t, "default", allocation.Profile)
+	assert.Equal(t, 3, allocation.ProfileVersion)
+}
@@ -0,0 +1,41 @@
+package storage
+
+import "time"
+
+// QuantityCount is how often a quantity of a profile was requested.
+type QuantityCount struct {
+	Profile  string `json:"profile"`
+	Quantity int    `json:"quantity"`
+	// Requests counts the stored allocations, and their hits (see
+	// WriteDedup).
+	Requests int64 `json:"requests"`
+}
+
+// TopQuantities returns the n quantities requested most often since since,
+// most requested first. Deleted allocations are not counted.
+func (s *SQLiteStorage) TopQuantities(since time.Time, n int) ([]QuantityCount, error) {
+	query := "SELECT profile, order_quantity, SUM(hits) FROM allocations WHERE deleted_at IS NULL"
+	var args []interface{}
+	if !since.IsZero() {
+		query += " AND created_at >= ?"
+		args = append(args, sqliteTime(since))
+	}
+	query += " GROUP BY profile, order_quantity ORDER BY SUM(hits) DESC, profile, order_quantity LIMIT ?"
+	args = append(args, n)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []QuantityCount{}
+	for rows.Next() {
+		var c QuantityCount
+		if err := rows.Scan(&c.Profile, &c.Quantity, &c.Requests); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopQuantities(t *testing.T) {
+	sqlite, err := NewInMemorySQLite()
+	assert.NoError(t, err)
+	defer sqlite.Close()
+
+	for name, s := range map[string]Storage{"sqlite": sqlite, "bolt": setupBolt(t)} {
+		t.Run(name, func(t *testing.T) {
+			old := time.Now().Add(-48 * time.Hour)
+			for _, in := range []AllocationInput{
+				{Quantity: 50, Packs: map[int]int{53: 1}, Total: 53, Profile: "default"},
+				{Quantity: 50, Packs: map[int]int{53: 1}, Total: 53, Profile: "default"},
+				{Quantity: 50, Packs: map[int]int{53: 1}, Total: 53, Profile: "apparel"},
+				{Quantity: 60, Packs: map[int]int{31: 2}, Total: 62, Profile: "default"},
+				{Quantity: 60, Packs: map[int]int{31: 2}, Total: 62, Profile: "default", CreatedAt: old},
+				{Quantity: 60, Packs: map[int]int{31: 2}, Total: 62, Profile: "default", CreatedAt: old},
+				{Quantity: 70, Packs: map[int]int{53: 2}, Total: 106, Profile: "default"},
+			} {
+				assert.NoError(t, s.StoreAllocationInput(in))
+			}
+			deleted, err := s.DeleteAllocation(7)
+			assert.NoError(t, err)
+			assert.True(t, deleted)
+
+			top, err := s.TopQuantities(time.Time{}, 10)
+			assert.NoError(t, err)
+			assert.Equal(t, []QuantityCount{
+				{Profile: "default", Quantity: 60, Requests: 3},
+				{Profile: "default", Quantity: 50, Requests: 2},
+				{Profile: "apparel", Quantity: 50, Requests: 1},
+			}, top)
+
+			top, err = s.TopQuantities(time.Now().Add(-time.Hour), 2)
+			assert.NoError(t, err)
+			assert.Equal(t, []QuantityCount{
+				{Profile: "default", Quantity: 50, Requests: 2},
+				{Profile: "apparel", Quantity: 50, Requests: 1},
+			}, top)
+
+			top, err = s.TopQuantities(time.Now().Add(time.Hour), 10)
+			assert.NoError(t, err)
+			assert.Empty(t, top)
+		})
+	}
+}
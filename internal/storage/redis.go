@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultCachePrefix is the Redis key prefix used when none is configured.
+const DefaultCachePrefix = "gymshark:allocation:"
+
+// redisTimeout bounds every cache operation, so a slow Redis delays a
+// calculation by at most this long.
+const redisTimeout = time.Second
+
+// RedisCache is a Cache backed by Redis, storing each allocation as JSON
+// under prefix + profile + ":" + quantity.
+type RedisCache struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+// NewRedisCache connects to the Redis server at url, e.g.
+// "redis://localhost:6379/0", and checks that it is reachable. Entries
+// expire after ttl; zero keeps them until they are replaced.
+func NewRedisCache(ctx context.Context, url, prefix string, ttl time.Duration) (*RedisCache, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("parse redis url: %w", err)
+	}
+	if prefix == "" {
+		prefix = DefaultCachePrefix
+	}
+	if ttl < 0 {
+		return nil, errors.New("cache ttl must not be negative")
+	}
+	client := redis.NewClient(opts)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connect to redis: %w", err)
+	}
+	return &RedisCache{client: client, prefix: prefix, ttl: ttl}, nil
+}
+
+func (c *RedisCache) key(profile string, quantity int) string {
+	return c.prefix + profile + ":" + strconv.Itoa(quantity)
+}
+
+// GetCachedAllocation retrieves the allocation cached for a quantity of a
+// profile. Returns nil if there is none or it is not cacheable.
+func (c *RedisCache) GetCachedAllocation(profile string, quantity int) (*Allocation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	payload, err := c.client.Get(ctx, c.key(profile, quantity)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var a Allocation
+	if err := json.Unmarshal(payload, &a); err != nil {
+		return nil, fmt.Errorf("decode cached allocation: %w", err)
+	}
+	if a.Approximate || !a.Cacheable {
+		return nil, nil
+	}
+	return &a, nil
+}
+
+// CacheAllocation caches a under its Profile and OrderQuantity.
+func (c *RedisCache) CacheAllocation(a Allocation) error {
+	payload, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return c.client.Set(ctx, c.key(a.Profile, a.OrderQuantity), payload, c.ttl).Err()
+}
+
+// Close closes the connection.
+func (c *RedisCache) Close() error {
+	return c.client.Close()
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisCache(t *testing.T) {
+	server := miniredis.RunT(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cache, err := NewRedisCache(ctx, "redis://"+server.Addr(), "", time.Hour)
+	assert.NoError(t, err)
+	if cache == nil {
+		return
+	}
+	defer cache.Close()
+
+	allocation, err := cache.GetCachedAllocation("default", 50)
+	assert.NoError(t, err)
+	assert.Nil(t, allocation)
+
+	stored := Allocation{
+		OrderQuantity:  50,
+		Packs:          map[int]int{23: 1, 31: 1},
+		Total:          54,
+		Profile:        "default",
+		ProfileVersion: 2,
+		Cacheable:      true,
+		CreatedAt:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	assert.NoError(t, cache.CacheAllocation(stored))
+	assert.True(t, server.Exists(DefaultCachePrefix+"default:50"))
+	assert.Equal(t, time.Hour, server.TTL(DefaultCachePrefix+"default:50"))
+
+	allocation, err = cache.GetCachedAllocation("default", 50)
+	assert.NoError(t, err)
+	assert.Equal(t, &stored, allocation)
+
+	// Entries are keyed by profile
+	allocation, err = cache.GetCachedAllocation("apparel", 50)
+	assert.NoError(t, err)
+	assert.Nil(t, allocation)
+
+	// Approximate and uncacheable entries are not served
+	approximate := stored
+	approximate.Approximate = true
+	assert.NoError(t, cache.CacheAllocation(approximate))
+	allocation, err = cache.GetCachedAllocation("default", 50)
+	assert.NoError(t, err)
+	assert.Nil(t, allocation)
+	uncacheable := stored
+	uncacheable.Cacheable = false
+	assert.NoError(t, cache.CacheAllocation(uncacheable))
+	allocation, err = cache.GetCachedAllocation("default", 50)
+	assert.NoError(t, err)
+	assert.Nil(t, allocation)
+
+	// Entries expire after the TTL
+	server.FastForward(time.Hour)
+	allocation, err = cache.GetCachedAllocation("default", 50)
+	assert.NoError(t, err)
+	assert.Nil(t, allocation)
+}
+
+func TestNewRedisCacheErrors(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := NewRedisCache(ctx, "http://localhost", "", 0)
+	assert.Error(t, err)
+
+	server := miniredis.RunT(t)
+	_, err = NewRedisCache(ctx, "redis://"+server.Addr(), "", -time.Second)
+	assert.Error(t, err)
+
+	addr := server.Addr()
+	server.Close()
+	_, err = NewRedisCache(ctx, "redis://"+addr, "", 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ReplicaCache serves the result cache from read-only replicas of a SQLite
+// primary, such as copies LiteFS or Litestream keep in other regions, so
+// cache lookups do not load the primary. Writes are left to the primary.
+// A replica is only read while it lags the primary by at most the staleness
+// tolerance, as last measured by CheckReplicas; otherwise, and when reading
+// it fails, the primary answers.
+type ReplicaCache struct {
+	primary      *SQLiteStorage
+	replicas     []*replica
+	maxStaleness time.Duration
+	// next picks replicas in turn.
+	next atomic.Uint64
+}
+
+// replica is one read-only replica of a ReplicaCache.
+type replica struct {
+	dsn   string
+	store *SQLiteStorage
+	// fresh is set while the replica lags by at most the staleness
+	// tolerance.
+	fresh atomic.Bool
+}
+
+// NewReplicaCache opens the replicas at dsns, file paths or "file:" URIs,
+// read-only. Replicas lagging primary by more than maxStaleness are not read;
+// zero only reads replicas that have caught up. Every replica is checked
+// before NewReplicaCache returns.
+func NewReplicaCache(primary *SQLiteStorage, dsns []string, maxStaleness time.Duration) (*ReplicaCache, error) {
+	if len(dsns) == 0 {
+		return nil, errors.New("no storage replicas configured")
+	}
+	c := &ReplicaCache{primary: primary, maxStaleness: maxStaleness}
+	for _, dsn := range dsns {
+		db, err := sql.Open(sqliteDriver, replicaDSN(dsn))
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		db.SetMaxOpenConns(sqliteMaxOpenConns)
+		db.SetMaxIdleConns(sqliteMaxOpenConns)
+		r := &replica{dsn: dsn, store: &SQLiteStorage{db: db}}
+		// Replicas are assumed fresh until checked, so that the first check
+		// logs those that are not.
+		r.fresh.Store(true)
+		c.replicas = append(c.replicas, r)
+	}
+	c.CheckReplicas()
+	return c, nil
+}
+
+// replicaDSN opens the database at dsn read-only.
+func replicaDSN(dsn string) string {
+	if !strings.HasPrefix(dsn, "file:") {
+		dsn = "file:" + dsn
+	}
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + sqliteReadOnlyParams(sqliteBusyTimeout)
+}
+
+// newestAllocation returns when the newest allocation was created, zero
+// without allocations.
+func (s *SQLiteStorage) newestAllocation() (time.Time, error) {
+	var t sql.NullTime
+	err := s.db.QueryRow("SELECT created_at FROM allocations ORDER BY created_at DESC LIMIT 1").Scan(&t)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	return t.Time, err
+}
+
+// CheckReplicas measures how far each replica lags the primary, by when
+// their newest allocations were created, and reads only those within the
+// staleness tolerance from then on. Replicas that cannot be read are not
+// read either. It returns the lag of each replica, in order, or -1 for
+// those that could not be measured.
+func (c *ReplicaCache) CheckReplicas() []time.Duration {
+	lags := make([]time.Duration, len(c.replicas))
+	newest, err := c.primary.newestAllocation()
+	for i, r := range c.replicas {
+		lags[i] = -1
+		if err != nil {
+			r.setFresh(false, err)
+			continue
+		}
+		replicated, rerr := r.store.newestAllocation()
+		if rerr != nil {
+			r.setFresh(false, rerr)
+			continue
+		}
+		lags[i] = max(newest.Sub(replicated), 0)
+		r.setFresh(lags[i] <= c.maxStaleness, nil)
+	}
+	return lags
+}
+
+// setFresh records whether r may be read, logging the change.
+func (r *replica) setFresh(fresh bool, err error) {
+	if r.fresh.Swap(fresh) == fresh {
+		return
+	}
+	switch {
+	case fresh:
+		log.Printf("Reading cached allocations from storage replica %s", r.dsn)
+	case err != nil:
+		log.Printf("Not reading storage replica %s: %v", r.dsn, err)
+	default:
+		log.Printf("Not reading storage replica %s: lagging beyond the staleness tolerance", r.dsn)
+	}
+}
+
+// RunReplicaChecks checks the replicas every interval until ctx is done.
+func (c *ReplicaCache) RunReplicaChecks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.CheckReplicas()
+		}
+	}
+}
+
+// GetCachedAllocation reads from the next fresh replica, or the primary
+// when there is none or reading the replica fails.
+func (c *ReplicaCache) GetCachedAllocation(profile string, quantity int) (*Allocation, error) {
+	n := uint64(len(c.replicas))
+	start := c.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		r := c.replicas[(start+i)%n]
+		if !r.fresh.Load() {
+			continue
+		}
+		a, err := r.store.GetCachedAllocation(profile, quantity)
+		if err == nil {
+			return a, nil
+		}
+		r.setFresh(false, err)
+	}
+	return c.primary.GetCachedAllocation(profile, quantity)
+}
+
+// CacheAllocation does nothing: allocations stored on the primary reach
+// the replicas through replication.
+func (c *ReplicaCache) CacheAllocation(Allocation) error {
+	return nil
+}
+
+// Close closes the replicas, but not the primary.
+func (c *ReplicaCache) Close() error {
+	var errs []error
+	for _, r := range c.replicas {
+		errs = append(errs, r.store.db.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplicaCache(t *testing.T) {
+	dir := t.TempDir()
+	primary, err := NewSQLiteStorage(filepath.Join(dir, "primary.db"))
+	assert.NoError(t, err)
+	defer primary.Close()
+	// replicated stands in for replication writing to the replica.
+	replicated, err := NewSQLiteStorage(filepath.Join(dir, "replica.db"))
+	assert.NoError(t, err)
+	defer replicated.Close()
+
+	now := time.Now()
+	assert.NoError(t, primary.StoreAllocationInput(AllocationInput{Quantity: 50, Packs: map[int]int{53: 1}, Total: 53, Algorithm: "dp", Cacheable: true, CreatedAt: now}))
+	assert.NoError(t, replicated.StoreAllocationInput(AllocationInput{Quantity: 50, Packs: map[int]int{31: 2}, Total: 62, Algorithm: "backtracking", Cacheable: true, CreatedAt: now.Add(-time.Hour)}))
+
+	c, err := NewReplicaCache(primary, []string{filepath.Join(dir, "replica.db"), filepath.Join(dir, "missing.db")}, time.Minute)
+	assert.NoError(t, err)
+	defer c.Close()
+
+	// The replica lags an hour, beyond the tolerance: the primary answers.
+	lags := c.CheckReplicas()
+	assert.InDelta(t, time.Hour, lags[0], float64(time.Second))
+	assert.Equal(t, time.Duration(-1), lags[1])
+	a, err := c.GetCachedAllocation("", 50)
+	assert.NoError(t, err)
+	if assert.NotNil(t, a) {
+		assert.Equal(t, "dp", a.Algorithm)
+	}
+
+	// Once caught up, the replica answers.
+	assert.NoError(t, replicated.StoreAllocationInput(AllocationInput{Quantity: 50, Packs: map[int]int{31: 2}, Total: 62, Algorithm: "backtracking", Cacheable: true, CreatedAt: now}))
+	lags = c.CheckReplicas()
+	assert.LessOrEqual(t, lags[0], time.Second)
+	for i := 0; i < 2; i++ {
+		a, err = c.GetCachedAllocation("", 50)
+		assert.NoError(t, err)
+		if assert.NotNil(t, a) {
+			assert.Equal(t, "backtracking", a.Algorithm)
+		}
+	}
+
+	// Replicas are opened read-only, and never written through the cache.
+	assert.NoError(t, c.CacheAllocation(Allocation{OrderQuantity: 60, Packs: map[int]int{31: 2}, Total: 62}))
+	_, err = c.replicas[0].store.db.Exec("DELETE FROM allocations")
+	assert.Error(t, err)
+}
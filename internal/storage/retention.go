@@ -0,0 +1,46 @@
+package storage
+
+import "time"
+
+// RetentionPolicy bounds how much allocation history is kept.
+type RetentionPolicy struct {
+	// MaxAge removes allocations older than this. Zero keeps any age.
+	MaxAge time.Duration
+	// MaxRows keeps only this many of the most recent allocations.
+	// Zero keeps any number.
+	MaxRows int
+}
+
+// Enabled reports whether the policy removes anything.
+func (p RetentionPolicy) Enabled() bool {
+	return p.MaxAge > 0 || p.MaxRows > 0
+}
+
+// PruneResult reports how many allocations a prune removed.
+type PruneResult struct {
+	// Expired counts allocations removed for exceeding MaxAge.
+	Expired int64 `json:"expired"`
+	// Excess counts allocations removed for exceeding MaxRows.
+	Excess int64 `json:"excess"`
+}
+
+// Prune applies p to s, removing expired allocations first and then any
+// beyond the row limit.
+func Prune(s History, p RetentionPolicy, now time.Time) (PruneResult, error) {
+	var result PruneResult
+	if p.MaxAge > 0 {
+		n, err := s.DeleteOlderThan(now.Add(-p.MaxAge))
+		if err != nil {
+			return result, err
+		}
+		result.Expired = n
+	}
+	if p.MaxRows > 0 {
+		n, err := s.DeleteAllButNewest(p.MaxRows)
+		if err != nil {
+			return result, err
+		}
+		result.Excess = n
+	}
+	return result, nil
+}
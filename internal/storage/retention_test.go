@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// seedAged stores one allocation per quantity, each created daysAgo[i] days before now.
+func seedAged(t *testing.T, s *SQLiteStorage, now time.Time, daysAgo ...int) {
+	for i, days := range daysAgo {
+		quantity := i + 1
+		assert.NoError(t, s.StoreAllocation(quantity, map[int]int{23: 1}, 23))
+		_, err := s.db.Exec("UPDATE allocations SET created_at = ? WHERE order_quantity = ?",
+			sqliteTime(now.AddDate(0, 0, -days)), quantity)
+		assert.NoError(t, err)
+	}
+}
+
+func countAllocations(t *testing.T, s *SQLiteStorage) int {
+	var n int
+	assert.NoError(t, s.db.QueryRow("SELECT COUNT(*) FROM allocations").Scan(&n))
+	return n
+}
+
+func TestDeleteOlderThan(t *testing.T) {
+	storage, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	seedAged(t, storage, now, 40, 20, 1)
+
+	n, err := storage.DeleteOlderThan(now.AddDate(0, 0, -30))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, 2, countAllocations(t, storage))
+
+	a, err := storage.GetAllocationByQuantity(1)
+	assert.NoError(t, err)
+	assert.Nil(t, a)
+}
+
+func TestDeleteAllButNewest(t *testing.T) {
+	storage, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	seedAged(t, storage, time.Now(), 3, 1, 2, 4)
+
+	n, err := storage.DeleteAllButNewest(2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	recent, err := storage.GetRecentAllocations(10, false)
+	assert.NoError(t, err)
+	assert.Len(t, recent, 2)
+	assert.Equal(t, 2, recent[0].OrderQuantity)
+	assert.Equal(t, 3, recent[1].OrderQuantity)
+
+	_, err = storage.DeleteAllButNewest(-1)
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+}
+
+func TestPrune(t *testing.T) {
+	storage, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	seedAged(t, storage, now, 100, 50, 5, 4, 3, 2)
+
+	result, err := Prune(storage, RetentionPolicy{MaxAge: 30 * 24 * time.Hour, MaxRows: 3}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, PruneResult{Expired: 2, Excess: 1}, result)
+	assert.Equal(t, 3, countAllocations(t, storage))
+
+	// A disabled policy removes nothing
+	result, err = Prune(storage, RetentionPolicy{}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, PruneResult{}, result)
+	assert.False(t, RetentionPolicy{}.Enabled())
+}
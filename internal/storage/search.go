@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Fields search results can be sorted by.
+const (
+	SortCreatedAt = "created_at"
+	SortQuantity  = "quantity"
+	SortWaste     = "waste"
+	SortTotal     = "total"
+)
+
+// Search page sizes: the default, and the most a search returns at once.
+const (
+	DefaultSearchLimit = 100
+	MaxSearchLimit     = 1000
+)
+
+// AllocationFilter selects allocations for SearchAllocations and
+// CountAllocations. Zero fields match everything; ranges are inclusive
+// except To, which is exclusive. Deleted allocations only match with
+// IncludeDeleted.
+type AllocationFilter struct {
+	MinQuantity int
+	MaxQuantity int
+	// Waste is the total minus the quantity. MaxWaste is a pointer since
+	// zero waste is a meaningful bound.
+	MinWaste int
+	MaxWaste *int
+	From     time.Time
+	To       time.Time
+	// PackSize matches allocations using at least one pack of the size.
+	PackSize       int
+	Profile        string
+	IncludeDeleted bool
+	// Sort is one of the Sort fields, descending when prefixed with "-".
+	// Empty sorts most recent first.
+	Sort string
+	// Offset skips the first matches, and Limit bounds the rest:
+	// DefaultSearchLimit when zero, at most MaxSearchLimit.
+	Offset int
+	Limit  int
+}
+
+// sortOrder returns the field f sorts by and whether it is descending.
+// Returns ErrInvalidArgument for an unknown field.
+func (f AllocationFilter) sortOrder() (string, bool, error) {
+	if f.Sort == "" {
+		return SortCreatedAt, true, nil
+	}
+	field, desc := strings.CutPrefix(f.Sort, "-")
+	switch field {
+	case SortCreatedAt, SortQuantity, SortWaste, SortTotal:
+		return field, desc, nil
+	}
+	return "", false, fmt.Errorf("%w: unknown sort field %q", ErrInvalidArgument, field)
+}
+
+// matches reports whether a matches f, except for its time range.
+func (f AllocationFilter) matches(a Allocation) bool {
+	waste := a.Total - a.OrderQuantity
+	switch {
+	case f.MinQuantity > 0 && a.OrderQuantity < f.MinQuantity,
+		f.MaxQuantity > 0 && a.OrderQuantity > f.MaxQuantity,
+		waste < f.MinWaste,
+		f.MaxWaste != nil && waste > *f.MaxWaste,
+		f.PackSize > 0 && a.Packs[f.PackSize] <= 0,
+		f.Profile != "" && a.Profile != f.Profile:
+		return false
+	}
+	return true
+}
+
+// matchesAll reports whether f sets no condition but IncludeDeleted, its
+// sort order and page.
+func (f AllocationFilter) matchesAll() bool {
+	return f.MinQuantity == 0 && f.MaxQuantity == 0 && f.MinWaste == 0 && f.MaxWaste == nil &&
+		f.From.IsZero() && f.To.IsZero() && f.PackSize == 0 && f.Profile == ""
+}
+
+// searchLimit applies the default and maximum to a requested limit.
+func searchLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultSearchLimit
+	}
+	return min(limit, MaxSearchLimit)
+}
+
+// sqliteSortColumns maps the Sort fields to the expressions they sort by.
+var sqliteSortColumns = map[string]string{
+	SortCreatedAt: "created_at",
+	SortQuantity:  "order_quantity",
+	SortWaste:     "total - order_quantity",
+	SortTotal:     "total",
+}
+
+// sqliteWhere returns the WHERE clause selecting the allocations matching f,
+// and its arguments.
+func (f AllocationFilter) sqliteWhere() (string, []interface{}) {
+	where := " WHERE deleted_at IS NULL"
+	if f.IncludeDeleted {
+		where = " WHERE 1 = 1"
+	}
+	var args []interface{}
+	if f.MinQuantity > 0 {
+		where += " AND order_quantity >= ?"
+		args = append(args, f.MinQuantity)
+	}
+	if f.MaxQuantity > 0 {
+		where += " AND order_quantity <= ?"
+		args = append(args, f.MaxQuantity)
+	}
+	if f.MinWaste != 0 {
+		where += " AND total - order_quantity >= ?"
+		args = append(args, f.MinWaste)
+	}
+	if f.MaxWaste != nil {
+		where += " AND total - order_quantity <= ?"
+		args = append(args, *f.MaxWaste)
+	}
+	if !f.From.IsZero() {
+		where += " AND created_at >= ?"
+		args = append(args, sqliteTime(f.From))
+	}
+	if !f.To.IsZero() {
+		where += " AND created_at < ?"
+		args = append(args, sqliteTime(f.To))
+	}
+	if f.PackSize > 0 {
+		where += " AND EXISTS (SELECT 1 FROM json_each(packs) WHERE CAST(key AS INTEGER) = ? AND value > 0)"
+		args = append(args, f.PackSize)
+	}
+	if f.Profile != "" {
+		where += " AND profile = ?"
+		args = append(args, f.Profile)
+	}
+	return where, args
+}
+
+// SearchAllocations returns the page of allocations matching f in its sort
+// order, and how many match in all.
+func (s *SQLiteStorage) SearchAllocations(f AllocationFilter) ([]Allocation, int, error) {
+	field, desc, err := f.sortOrder()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.CountAllocations(f)
+	if err != nil {
+		return nil, 0, err
+	}
+	where, args := f.sqliteWhere()
+
+	direction := " ASC"
+	if desc {
+		direction = " DESC"
+	}
+	query := "SELECT " + allocationColumns + " FROM allocations" + where +
+		" ORDER BY " + sqliteSortColumns[field] + direction + ", id" + direction + " LIMIT ? OFFSET ?"
+	allocations, err := s.queryAllocations(query, append(args, searchLimit(f.Limit), max(f.Offset, 0))...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return allocations, total, nil
+}
+
+// CountAllocations counts the allocations matching f, ignoring its sort
+// order and page.
+func (s *SQLiteStorage) CountAllocations(f AllocationFilter) (int, error) {
+	where, args := f.sqliteWhere()
+	var n int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM allocations"+where, args...).Scan(&n)
+	return n, err
+}
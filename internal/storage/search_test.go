@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testSearch stores a few allocations in s and checks searches over them.
+func testSearch(t *testing.T, s Storage) {
+	day := time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, s.StoreAllocations([]AllocationInput{
+		{Quantity: 4000, Packs: map[int]int{5000: 1}, Total: 5000, Profile: "default", CreatedAt: day.AddDate(0, 0, -10)},
+		{Quantity: 4900, Packs: map[int]int{5000: 1}, Total: 5000, Profile: "default", CreatedAt: day.AddDate(0, 0, -2)},
+		{Quantity: 5250, Packs: map[int]int{5000: 1, 250: 1}, Total: 5250, Profile: "default", CreatedAt: day.AddDate(0, 0, -1)},
+		{Quantity: 50, Packs: map[int]int{53: 1}, Total: 53, Profile: "small", CreatedAt: day},
+	}))
+
+	quantities := func(f AllocationFilter) ([]int, int) {
+		allocations, total, err := s.SearchAllocations(f)
+		assert.NoError(t, err)
+		var q []int
+		for _, a := range allocations {
+			q = append(q, a.OrderQuantity)
+		}
+		return q, total
+	}
+
+	q, total := quantities(AllocationFilter{})
+	assert.Equal(t, []int{50, 5250, 4900, 4000}, q)
+	assert.Equal(t, 4, total)
+
+	// Used the 5000 pack in the last week
+	q, _ = quantities(AllocationFilter{PackSize: 5000, From: day.AddDate(0, 0, -7)})
+	assert.Equal(t, []int{5250, 4900}, q)
+
+	noWaste := 0
+	q, _ = quantities(AllocationFilter{MaxWaste: &noWaste})
+	assert.Equal(t, []int{5250}, q)
+	q, _ = quantities(AllocationFilter{MinWaste: 100, Sort: "-waste"})
+	assert.Equal(t, []int{4000, 4900}, q)
+	q, _ = quantities(AllocationFilter{MinQuantity: 1000, MaxQuantity: 5000, Sort: SortQuantity})
+	assert.Equal(t, []int{4000, 4900}, q)
+	q, _ = quantities(AllocationFilter{Profile: "small", To: day})
+	assert.Empty(t, q)
+
+	// Pages keep the total of every match
+	q, total = quantities(AllocationFilter{Sort: SortTotal, Offset: 1, Limit: 2})
+	assert.Equal(t, []int{4000, 4900}, q)
+	assert.Equal(t, 4, total)
+
+	_, _, err := s.SearchAllocations(AllocationFilter{Sort: "packs"})
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+
+	// Counting takes the same filter, without its page
+	n, err := s.CountAllocations(AllocationFilter{MinWaste: 100, Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	// Deleted allocations only match when asked for
+	allocations, _, err := s.SearchAllocations(AllocationFilter{Profile: "small"})
+	assert.NoError(t, err)
+	if assert.Len(t, allocations, 1) {
+		_, err = s.DeleteAllocation(allocations[0].ID)
+		assert.NoError(t, err)
+	}
+	n, err = s.CountAllocations(AllocationFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	n, err = s.CountAllocations(AllocationFilter{IncludeDeleted: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+	q, total = quantities(AllocationFilter{Profile: "small", IncludeDeleted: true})
+	assert.Equal(t, []int{50}, q)
+	assert.Equal(t, 1, total)
+}
+
+func TestSearchAllocations(t *testing.T) {
+	s, err := NewInMemorySQLite()
+	assert.NoError(t, err)
+	defer s.Close()
+	testSearch(t, s)
+}
+
+func TestBoltSearchAllocations(t *testing.T) {
+	testSearch(t, setupBolt(t))
+}
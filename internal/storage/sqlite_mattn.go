@@ -0,0 +1,27 @@
+//go:build !modernc
+
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriver is the database/sql driver SQLiteStorage opens. By default it
+// is mattn/go-sqlite3, which needs cgo; see sqlite_modernc.go for the pure-Go
+// alternative.
+const sqliteDriver = "sqlite3"
+
+// sqliteFileParams are the DSN parameters NewSQLiteStorage opens file
+// databases with, in the driver's syntax.
+func sqliteFileParams(busyTimeout time.Duration) string {
+	return fmt.Sprintf("_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=%d&_txlock=immediate", busyTimeout.Milliseconds())
+}
+
+// sqliteReadOnlyParams are the DSN parameters NewReplicaCache opens
+// replicas with, which leave the journal mode as replication set it.
+func sqliteReadOnlyParams(busyTimeout time.Duration) string {
+	return fmt.Sprintf("mode=ro&_busy_timeout=%d", busyTimeout.Milliseconds())
+}
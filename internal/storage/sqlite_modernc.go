@@ -0,0 +1,28 @@
+//go:build modernc
+
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteDriver is the database/sql driver SQLiteStorage opens. Built with
+// -tags modernc it is modernc.org/sqlite, a translation of SQLite to Go, so
+// binaries build with CGO_ENABLED=0 and still read and write the same
+// database files.
+const sqliteDriver = "sqlite"
+
+// sqliteFileParams are the DSN parameters NewSQLiteStorage opens file
+// databases with, in the driver's syntax.
+func sqliteFileParams(busyTimeout time.Duration) string {
+	return fmt.Sprintf("_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_pragma=busy_timeout(%d)&_txlock=immediate", busyTimeout.Milliseconds())
+}
+
+// sqliteReadOnlyParams are the DSN parameters NewReplicaCache opens
+// replicas with, which leave the journal mode as replication set it.
+func sqliteReadOnlyParams(busyTimeout time.Duration) string {
+	return fmt.Sprintf("mode=ro&_pragma=busy_timeout(%d)", busyTimeout.Milliseconds())
+}
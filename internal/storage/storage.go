@@ -1,17 +1,20 @@
 // Package storage provides persistence functionality for pack allocation results.
 // It defines interfaces and implementations for storing and retrieving allocation data.
 //
-// The package currently implements SQLite-based storage, but the interface
-// allows for other storage backends to be implemented.
+// The package implements SQLite-based storage and, for binaries built
+// without cgo, bbolt-based storage; the interface allows for other storage
+// backends to be implemented.
 package storage
 
 import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
 	"time"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
 // Common errors
@@ -23,53 +26,332 @@ var (
 // It contains the order quantity, the calculated pack distribution,
 // the total number of items, and when the allocation was created.
 type Allocation struct {
-	ID            int64
-	OrderQuantity int
-	Packs         map[int]int
-	Total         int
-	CreatedAt     time.Time
+	ID             int64
+	OrderQuantity  int
+	Packs          map[int]int
+	Total          int
+	OrderID        string                 `json:",omitempty"`
+	CustomerID     string                 `json:",omitempty"`
+	Metadata       map[string]interface{} `json:",omitempty"`
+	Profile        string                 `json:",omitempty"`
+	ProfileVersion int                    `json:",omitempty"`
+	// Source is "manual" for allocations served from a pin and empty for
+	// computed ones.
+	Source string `json:",omitempty"`
+	// Algorithm names what produced the allocation: a strategy such as
+	// "dp", "greedy" after a timeout fallback, "cache", "manual" or
+	// AlgorithmImport. It is empty for allocations stored before it was
+	// recorded.
+	Algorithm   string `json:",omitempty"`
+	Approximate bool   `json:",omitempty"`
+	// Cacheable marks allocations the result cache may serve: exact ones
+	// computed by the default strategy without constraints or weights.
+	Cacheable bool `json:",omitempty"`
+	// Unit is the unit the quantity was requested in, and
+	// RequestedQuantity the quantity in that unit, before OrderQuantity
+	// items were derived from it. Both are empty for quantities requested
+	// in items.
+	Unit              string `json:",omitempty"`
+	RequestedQuantity int    `json:",omitempty"`
+	// Rules are the allocation rules that held for the allocation when it
+	// was calculated.
+	Rules     []RuleMatch `json:",omitempty"`
+	CreatedAt time.Time
+	// Hits counts how often the allocation was stored: above one only with
+	// WriteDedup, where LastAccessedAt is when it was last stored.
+	Hits           int
+	LastAccessedAt *time.Time `json:",omitempty"`
+	// DeletedAt is set on allocations soft-deleted with DeleteAllocation.
+	DeletedAt *time.Time `json:",omitempty"`
+}
+
+// AllocationInput describes an allocation to be persisted together with
+// the caller-supplied order reference and free-form metadata.
+type AllocationInput struct {
+	Quantity   int
+	Packs      map[int]int
+	Total      int
+	OrderID    string
+	CustomerID string
+	Metadata   map[string]interface{}
+	// Profile and ProfileVersion identify the pack-size profile version
+	// that produced the allocation.
+	Profile        string
+	ProfileVersion int
+	// Source is "manual" for allocations served from a pin.
+	Source string
+	// Algorithm names what produced the allocation, and Approximate whether
+	// it was returned before being proven optimal; see AlgorithmStats.
+	Algorithm   string
+	Approximate bool
+	// Cacheable marks allocations the result cache may serve; see
+	// Allocation.
+	Cacheable bool
+	// Unit and RequestedQuantity are the quantity as requested, when it was
+	// not in items; Quantity is then the items derived from it.
+	Unit              string
+	RequestedQuantity int
+	Rules             []RuleMatch
+	// CreatedAt defaults to now. It is set when replaying writes recorded
+	// earlier elsewhere.
+	CreatedAt time.Time
 }
 
-// Storage defines the interface for persistence operations.
-// Implementations should provide thread-safe storage and retrieval
-// of pack allocation results.
+// RuleMatch is an allocation rule that held for an allocation, with the
+// note and flags it attached.
+type RuleMatch struct {
+	Rule  string
+	Note  string   `json:",omitempty"`
+	Flags []string `json:",omitempty"`
+}
+
+// AlgorithmImport is the algorithm of historical orders loaded with
+// gymshark import-orders. They record demand only: their packs are empty and
+// their total zero, so they are never served from the cache and count
+// towards quantities but not waste.
+const AlgorithmImport = "import"
+
+// Storage is a History that also serves as the allocator's Cache, reading
+// cached allocations from the history itself. SQLiteStorage is one.
 type Storage interface {
+	History
+	Cache
+}
+
+// Cache holds computed allocations for reuse by the allocator's result
+// cache, keyed by profile and quantity. It is read on every calculation the
+// result cache may serve, so it should answer quickly; see RedisCache.
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	// GetCachedAllocation retrieves the allocation cached for a quantity of
+	// a profile. Returns nil if there is none.
+	GetCachedAllocation(profile string, quantity int) (*Allocation, error)
+
+	// CacheAllocation caches an allocation under its Profile and
+	// OrderQuantity, replacing any previous entry.
+	CacheAllocation(a Allocation) error
+
+	// Close releases the cache's connections.
+	Close() error
+}
+
+// History defines the persistence of allocations, profile versions, the
+// audit log and pins. Implementations should provide thread-safe storage
+// and retrieval of pack allocation results.
+type History interface {
 	// StoreAllocation saves a pack allocation result.
 	// Returns an error if the operation fails or if the input is invalid.
 	StoreAllocation(quantity int, packs map[int]int, total int) error
 
+	// StoreAllocationInput saves a pack allocation result along with its
+	// order reference and metadata.
+	// Returns an error if the operation fails or if the input is invalid.
+	StoreAllocationInput(in AllocationInput) error
+
+	// StoreAllocations saves several allocations as StoreAllocationInput
+	// does, in a single transaction: either all are stored or none.
+	// Returns an error if the operation fails or if any input is invalid.
+	StoreAllocations(ins []AllocationInput) error
+
 	// GetRecentAllocations retrieves the most recent allocations.
-	// The limit parameter controls how many allocations to return.
+	// The limit parameter controls how many allocations to return, and
+	// includeDeleted whether soft-deleted ones are among them.
+	// Returns an error if the operation fails.
+	GetRecentAllocations(limit int, includeDeleted bool) ([]Allocation, error)
+
+	// CountAllocations counts the allocations matching the filter, without
+	// reading them; its sort order and page are ignored.
 	// Returns an error if the operation fails.
-	GetRecentAllocations(limit int) ([]Allocation, error)
+	CountAllocations(f AllocationFilter) (int, error)
 
 	// GetAllocationByQuantity retrieves the most recent allocation for a given quantity.
 	// Returns nil if no allocation is found for the quantity.
 	// Returns an error if the operation fails.
 	GetAllocationByQuantity(quantity int) (*Allocation, error)
 
+	// GetAllocationsByOrderID retrieves all allocations recorded for an order,
+	// most recent first.
+	// Returns an error if the operation fails.
+	GetAllocationsByOrderID(orderID string) ([]Allocation, error)
+
+	// SearchAllocations retrieves the page of allocations matching the
+	// filter in its sort order, and how many match in all.
+	// Returns ErrInvalidArgument for an unknown sort field.
+	SearchAllocations(f AllocationFilter) ([]Allocation, int, error)
+
+	// ExportAllocations streams allocations created in [from, to) in creation
+	// order, calling fn once per allocation without loading them all in memory.
+	// A zero from or to leaves that end of the range open.
+	// Iteration stops at the first error returned by fn.
+	ExportAllocations(from, to time.Time, fn func(Allocation) error) error
+
+	// DeleteAllocation soft-deletes an allocation: it is kept, marked with
+	// its deletion time, but no other read but GetRecentAllocations with
+	// includeDeleted returns it. Returns false if there is no allocation
+	// with the ID or it is already deleted.
+	DeleteAllocation(id int64) (bool, error)
+
+	// RestoreAllocation undoes DeleteAllocation. Returns false if there is
+	// no deleted allocation with the ID.
+	RestoreAllocation(id int64) (bool, error)
+
+	// DeleteOlderThan removes allocations created before t.
+	// Returns the number of allocations removed.
+	DeleteOlderThan(t time.Time) (int64, error)
+
+	// DeleteAllButNewest removes all allocations except the n most recent.
+	// Returns the number of allocations removed.
+	DeleteAllButNewest(n int) (int64, error)
+
+	// RecordProfileVersion records the pack sizes of a profile. If they differ
+	// from the latest stored version, a new immutable version effective from
+	// now is created; otherwise the latest version is returned unchanged.
+	RecordProfileVersion(name string, packSizes []int) (ProfileVersion, error)
+
+	// GetProfileVersions retrieves every version of a profile, oldest first.
+	// Returns an empty slice if the profile has never been recorded.
+	GetProfileVersions(name string) ([]ProfileVersion, error)
+
+	// RecordAudit appends an entry to the audit log.
+	RecordAudit(e AuditEntry) error
+
+	// GetAuditEntries retrieves audit entries matching the filter, most
+	// recent first.
+	GetAuditEntries(f AuditFilter) ([]AuditEntry, error)
+
+	// PinAllocation stores a manual allocation for a quantity of a profile,
+	// replacing any pin it already has.
+	PinAllocation(p Pin) error
+
+	// UnpinAllocation removes the pin for a quantity of a profile.
+	// Returns false if there was none.
+	UnpinAllocation(profile string, quantity int) (bool, error)
+
+	// GetPins retrieves every pin, ordered by profile and quantity.
+	GetPins() ([]Pin, error)
+
+	// SetProfileDefaults stores the calculation defaults of a profile,
+	// replacing any it already has.
+	SetProfileDefaults(d ProfileDefaults) error
+
+	// DeleteProfileDefaults removes the defaults of a profile.
+	// Returns false if it had none.
+	DeleteProfileDefaults(profile string) (bool, error)
+
+	// GetProfileDefaults retrieves the defaults of every profile that has
+	// some, ordered by profile.
+	GetProfileDefaults() ([]ProfileDefaults, error)
+
+	// GetAlgorithmStats counts the allocations created in [from, to) by the
+	// algorithm that produced them, most used first. A zero from or to leaves
+	// that end of the range open.
+	GetAlgorithmStats(from, to time.Time) ([]AlgorithmStats, error)
+
+	// AggregateWaste sums the items and waste of the allocations created in
+	// [from, to) by group, one of the GroupBy fields, without reading them
+	// all into memory; imported allocations have no packs and are left out.
+	// A zero from or to leaves that end of the range open.
+	// Returns ErrInvalidArgument for an unknown group.
+	AggregateWaste(groupBy string, from, to time.Time) ([]WasteStats, error)
+
+	// PackUsage sums the packs of each size in the allocations created in
+	// [from, to), by profile and then size, largest first. A zero from or
+	// to leaves that end of the range open.
+	PackUsage(from, to time.Time) ([]PackUsage, error)
+
+	// TopQuantities returns the n quantities requested most often since
+	// since, with their profile, most requested first. A zero since counts
+	// the whole history.
+	TopQuantities(since time.Time, n int) ([]QuantityCount, error)
+
+	// RecordUsage counts a request of client at time at that took compute,
+	// towards the client's usage in the month of at.
+	RecordUsage(client string, at time.Time, compute time.Duration) error
+
+	// GetUsage returns the usage of every client in the month of at, by
+	// client, or only that of client if it is not empty.
+	GetUsage(at time.Time, client string) ([]Usage, error)
+
+	// Backup writes a consistent snapshot of the whole database to w, in
+	// the backend's file format; see RestoreBackup.
+	Backup(w io.Writer) error
+
 	// Close closes the storage connection.
 	// It should be called when the storage is no longer needed.
 	Close() error
 }
 
 // SQLiteStorage implements Storage using SQLite.
-// It provides persistent storage of allocation results in a SQLite database.
+// It provides persistent storage of allocation results in a SQLite database,
+// through the driver chosen at build time; see sqliteDriver.
 type SQLiteStorage struct {
 	db *sql.DB
+
+	// Statements for the hot write paths, prepared once per database.
+	insertAllocation *sql.Stmt
+	insertAudit      *sql.Stmt
+
+	writeMode WriteMode
 }
 
+// Connection settings for file databases. In WAL mode readers do not block
+// the writer, and writers wait up to sqliteBusyTimeout for each other instead
+// of failing with "database is locked". Transactions take the write lock up
+// front so one that reads before writing cannot deadlock with another.
+const (
+	sqliteBusyTimeout  = 5 * time.Second
+	sqliteMaxOpenConns = 8
+)
+
 // NewSQLiteStorage creates a new SQLite storage instance.
 // The dbPath parameter specifies the path to the SQLite database file.
 // If the database doesn't exist, it will be created with the necessary schema.
+// The database is opened in WAL mode with a busy timeout, so it can be
+// written from concurrent requests.
 func NewSQLiteStorage(dbPath string) (*SQLiteStorage, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
+	}
+	db, err := sql.Open(sqliteDriver, dbPath+sep+sqliteFileParams(sqliteBusyTimeout))
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite has a single writer, so a large pool only adds connections
+	// queueing on the lock; a few still let reads run alongside a write.
+	db.SetMaxOpenConns(sqliteMaxOpenConns)
+	db.SetMaxIdleConns(sqliteMaxOpenConns)
+
+	return newSQLiteStorage(db)
+}
+
+// memoryDBCounter gives each in-memory database a unique name.
+var memoryDBCounter atomic.Int64
+
+// NewInMemorySQLite creates a SQLite storage backed by a private in-memory database.
+// Nothing is written to disk, which makes it suitable for hermetic tests.
+// The database lives until Close is called.
+func NewInMemorySQLite() (*SQLiteStorage, error) {
+	dsn := fmt.Sprintf("file:gymshark-memdb-%d?mode=memory&cache=shared", memoryDBCounter.Add(1))
+	db, err := sql.Open(sqliteDriver, dsn)
 	if err != nil {
 		return nil, err
 	}
 
+	// The database is dropped when its last connection closes, so keep a
+	// single long-lived connection instead of letting the pool recycle it.
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+	db.SetConnMaxLifetime(0)
+
+	return newSQLiteStorage(db)
+}
+
+// newSQLiteStorage prepares the schema on an open database.
+func newSQLiteStorage(db *sql.DB) (*SQLiteStorage, error) {
 	// Create tables if they don't exist
-	_, err = db.Exec(`
+	_, err := db.Exec(`
 		CREATE TABLE IF NOT EXISTS allocations (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			order_quantity INTEGER NOT NULL,
@@ -79,93 +361,457 @@ func NewSQLiteStorage(dbPath string) (*SQLiteStorage, error) {
 		);
 		CREATE INDEX IF NOT EXISTS idx_order_quantity ON allocations(order_quantity);
 		CREATE INDEX IF NOT EXISTS idx_created_at ON allocations(created_at);
+		CREATE TABLE IF NOT EXISTS profile_versions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			version INTEGER NOT NULL,
+			pack_sizes TEXT NOT NULL,
+			effective_from TIMESTAMP NOT NULL,
+			UNIQUE(name, version)
+		);
+		CREATE TABLE IF NOT EXISTS audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			actor TEXT NOT NULL,
+			client_ip TEXT NOT NULL,
+			method TEXT NOT NULL,
+			path TEXT NOT NULL,
+			params TEXT NOT NULL,
+			status INTEGER NOT NULL,
+			error TEXT NOT NULL,
+			duration_ms INTEGER NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_audit_created_at ON audit_log(created_at);
+		CREATE INDEX IF NOT EXISTS idx_audit_actor ON audit_log(actor);
+		CREATE TABLE IF NOT EXISTS pins (
+			profile TEXT NOT NULL,
+			quantity INTEGER NOT NULL,
+			packs TEXT NOT NULL,
+			total INTEGER NOT NULL,
+			reason TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (profile, quantity)
+		);
+		CREATE TABLE IF NOT EXISTS profile_defaults (
+			profile TEXT PRIMARY KEY,
+			strategy TEXT NOT NULL,
+			weights TEXT NOT NULL,
+			timeout_ms INTEGER NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS usage (
+			client TEXT NOT NULL,
+			month TEXT NOT NULL,
+			requests INTEGER NOT NULL,
+			compute_us INTEGER NOT NULL,
+			PRIMARY KEY (month, client)
+		);
 	`)
 	if err != nil {
 		db.Close()
 		return nil, err
 	}
 
-	return &SQLiteStorage{db: db}, nil
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s := &SQLiteStorage{db: db}
+	if err := s.prepare(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// prepare prepares the statements used on every write.
+func (s *SQLiteStorage) prepare() error {
+	var err error
+	s.insertAllocation, err = s.db.Prepare(
+		"INSERT INTO allocations (order_quantity, packs, total, order_id, customer_id, metadata, profile, profile_version, source, algorithm, approximate, cacheable, unit, requested_quantity, rules, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+	)
+	if err != nil {
+		return err
+	}
+	s.insertAudit, err = s.db.Prepare(
+		"INSERT INTO audit_log (actor, client_ip, method, path, params, status, error, duration_ms, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+	)
+	return err
+}
+
+// columnMigrations lists columns added after the initial schema.
+// They are applied in order to databases created by older versions.
+var columnMigrations = []struct {
+	table  string
+	column string
+	ddl    string
+}{
+	{"allocations", "order_id", "TEXT NOT NULL DEFAULT ''"},
+	{"allocations", "customer_id", "TEXT NOT NULL DEFAULT ''"},
+	{"allocations", "metadata", "TEXT NOT NULL DEFAULT ''"},
+	{"allocations", "profile", "TEXT NOT NULL DEFAULT ''"},
+	{"allocations", "profile_version", "INTEGER NOT NULL DEFAULT 0"},
+	{"allocations", "source", "TEXT NOT NULL DEFAULT ''"},
+	{"allocations", "hits", "INTEGER NOT NULL DEFAULT 1"},
+	{"allocations", "last_accessed_at", "TIMESTAMP"},
+	{"allocations", "algorithm", "TEXT NOT NULL DEFAULT ''"},
+	{"allocations", "approximate", "INTEGER NOT NULL DEFAULT 0"},
+	{"allocations", "deleted_at", "TIMESTAMP"},
+	{"allocations", "unit", "TEXT NOT NULL DEFAULT ''"},
+	{"allocations", "requested_quantity", "INTEGER NOT NULL DEFAULT 0"},
+	{"allocations", "rules", "TEXT NOT NULL DEFAULT ''"},
+	{"allocations", "cacheable", "INTEGER NOT NULL DEFAULT 0"},
+}
+
+// migrate adds any missing columns and their indexes to an existing database.
+func migrate(db *sql.DB) error {
+	for _, m := range columnMigrations {
+		exists, err := columnExists(db, m.table, m.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if _, err := db.Exec("ALTER TABLE " + m.table + " ADD COLUMN " + m.column + " " + m.ddl); err != nil {
+			return err
+		}
+	}
+
+	_, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_order_id ON allocations(order_id)")
+	return err
+}
+
+// columnExists reports whether table has a column with the given name.
+func columnExists(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
 }
 
 // StoreAllocation saves a pack allocation result to the SQLite database.
 // The packs map is stored as a JSON string in the database.
 // Returns an error if the operation fails or if packs is nil.
 func (s *SQLiteStorage) StoreAllocation(quantity int, packs map[int]int, total int) error {
-	if packs == nil {
-		return ErrInvalidArgument
+	return s.StoreAllocationInput(AllocationInput{Quantity: quantity, Packs: packs, Total: total})
+}
+
+// StoreAllocationInput saves a pack allocation result with its order reference.
+// The packs and metadata maps are stored as JSON strings in the database.
+// With WriteDedup, an allocation identical to a stored one counts as a hit on
+// it instead of being inserted. Returns an error if the operation fails or if
+// packs is nil.
+func (s *SQLiteStorage) StoreAllocationInput(in AllocationInput) error {
+	if dedupable(s.writeMode, in) {
+		// The lookup and the write share a transaction.
+		return s.StoreAllocations([]AllocationInput{in})
 	}
+	row, err := newAllocationRow(in)
+	if err != nil {
+		return err
+	}
+	_, err = s.insertAllocation.Exec(row.args()...)
+	return err
+}
 
-	packsJSON, err := json.Marshal(packs)
+// StoreAllocations saves several allocations in a single transaction, so a
+// batch costs one commit instead of one per allocation. Either all are stored
+// or none. Returns ErrInvalidArgument, storing nothing, if any has nil packs.
+func (s *SQLiteStorage) StoreAllocations(ins []AllocationInput) error {
+	rows := make([]allocationRow, len(ins))
+	for i, in := range ins {
+		var err error
+		if rows[i], err = newAllocationRow(in); err != nil {
+			return err
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	_, err = s.db.Exec(
-		"INSERT INTO allocations (order_quantity, packs, total) VALUES (?, ?, ?)",
-		quantity, string(packsJSON), total,
-	)
-	return err
+	insert := tx.Stmt(s.insertAllocation)
+	for _, row := range rows {
+		if dedupable(s.writeMode, row.in) {
+			hit, err := dedupHit(tx, row)
+			if err != nil {
+				return err
+			}
+			if hit {
+				continue
+			}
+		}
+		if _, err := insert.Exec(row.args()...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// allocationRow is an allocation encoded for the allocations table.
+type allocationRow struct {
+	in       AllocationInput
+	packs    string
+	metadata string
+	rules    string
+}
+
+// newAllocationRow encodes in, defaulting its creation time to now.
+func newAllocationRow(in AllocationInput) (allocationRow, error) {
+	if in.Packs == nil {
+		return allocationRow{}, ErrInvalidArgument
+	}
+	packsJSON, err := json.Marshal(in.Packs)
+	if err != nil {
+		return allocationRow{}, err
+	}
+	var metadataJSON []byte
+	if len(in.Metadata) > 0 {
+		metadataJSON, err = json.Marshal(in.Metadata)
+		if err != nil {
+			return allocationRow{}, err
+		}
+	}
+	var rulesJSON []byte
+	if len(in.Rules) > 0 {
+		rulesJSON, err = json.Marshal(in.Rules)
+		if err != nil {
+			return allocationRow{}, err
+		}
+	}
+	if in.CreatedAt.IsZero() {
+		in.CreatedAt = time.Now()
+	}
+	return allocationRow{in: in, packs: string(packsJSON), metadata: string(metadataJSON), rules: string(rulesJSON)}, nil
+}
+
+// args are the parameters of the insertAllocation statement.
+func (r allocationRow) args() []interface{} {
+	in := r.in
+	return []interface{}{
+		in.Quantity, r.packs, in.Total, in.OrderID, in.CustomerID, r.metadata, in.Profile, in.ProfileVersion, in.Source, in.Algorithm, in.Approximate, in.Cacheable, in.Unit, in.RequestedQuantity, r.rules, sqliteTime(in.CreatedAt),
+	}
 }
 
 // GetRecentAllocations retrieves the most recent allocations from the database.
 // Results are ordered by creation time in descending order.
 // The limit parameter controls how many allocations to return.
-func (s *SQLiteStorage) GetRecentAllocations(limit int) ([]Allocation, error) {
-	rows, err := s.db.Query(
-		"SELECT id, order_quantity, packs, total, created_at FROM allocations ORDER BY created_at DESC LIMIT ?",
+func (s *SQLiteStorage) GetRecentAllocations(limit int, includeDeleted bool) ([]Allocation, error) {
+	where := " WHERE deleted_at IS NULL"
+	if includeDeleted {
+		where = ""
+	}
+	return s.queryAllocations(
+		"SELECT "+allocationColumns+" FROM allocations"+where+" ORDER BY created_at DESC LIMIT ?",
 		limit,
 	)
+}
+
+// GetAllocationByQuantity retrieves the most recent allocation for a given quantity.
+// Returns nil if no allocation is found for the quantity.
+func (s *SQLiteStorage) GetAllocationByQuantity(quantity int) (*Allocation, error) {
+	a, err := scanAllocation(s.db.QueryRow(
+		"SELECT "+allocationColumns+" FROM allocations WHERE order_quantity = ? AND deleted_at IS NULL ORDER BY created_at DESC LIMIT 1",
+		quantity,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
+
+	return a, nil
+}
+
+// GetCachedAllocation retrieves the most recent allocation for a quantity
+// of a profile, so the history doubles as the result cache. Deleted
+// allocations are not served, nor those recorded as served from the cache
+// (algorithm "cache"), so CreatedAt is when the packs were computed, or
+// imported without packs. Only allocations stored as Cacheable are served,
+// so results of other strategies, constrained or weighted ones and
+// approximate ones, which were not proven optimal, are not. Returns nil if
+// no allocation is found.
+func (s *SQLiteStorage) GetCachedAllocation(profile string, quantity int) (*Allocation, error) {
+	a, err := scanAllocation(s.db.QueryRow(
+		"SELECT "+allocationColumns+" FROM allocations WHERE order_quantity = ? AND profile = ? AND deleted_at IS NULL AND algorithm NOT IN ('cache', 'import') AND approximate = 0 AND cacheable = 1 ORDER BY created_at DESC, id DESC LIMIT 1",
+		quantity, profile,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// CacheAllocation does nothing: every stored allocation is already served
+// by GetCachedAllocation.
+func (s *SQLiteStorage) CacheAllocation(Allocation) error {
+	return nil
+}
+
+// GetAllocationsByOrderID retrieves all allocations recorded for an order.
+// Results are ordered by creation time in descending order.
+func (s *SQLiteStorage) GetAllocationsByOrderID(orderID string) ([]Allocation, error) {
+	if orderID == "" {
+		return nil, ErrInvalidArgument
+	}
+	return s.queryAllocations(
+		"SELECT "+allocationColumns+" FROM allocations WHERE order_id = ? AND deleted_at IS NULL ORDER BY created_at DESC, id DESC",
+		orderID,
+	)
+}
+
+// ExportAllocations streams allocations created in [from, to) ordered by
+// creation time. Deleted allocations are left out.
+func (s *SQLiteStorage) ExportAllocations(from, to time.Time, fn func(Allocation) error) error {
+	query := "SELECT " + allocationColumns + " FROM allocations WHERE deleted_at IS NULL"
+	var args []interface{}
+	if !from.IsZero() {
+		query += " AND created_at >= ?"
+		args = append(args, sqliteTime(from))
+	}
+	if !to.IsZero() {
+		query += " AND created_at < ?"
+		args = append(args, sqliteTime(to))
+	}
+	query += " ORDER BY created_at, id"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
 	defer rows.Close()
 
-	var allocations []Allocation
 	for rows.Next() {
-		var a Allocation
-		var packsJSON string
-		err := rows.Scan(&a.ID, &a.OrderQuantity, &packsJSON, &a.Total, &a.CreatedAt)
+		a, err := scanAllocation(rows)
 		if err != nil {
-			return nil, err
+			return err
 		}
-
-		err = json.Unmarshal([]byte(packsJSON), &a.Packs)
-		if err != nil {
-			return nil, err
+		if err := fn(*a); err != nil {
+			return err
 		}
+	}
+
+	return rows.Err()
+}
+
+// DeleteOlderThan removes allocations created before t.
+func (s *SQLiteStorage) DeleteOlderThan(t time.Time) (int64, error) {
+	res, err := s.db.Exec("DELETE FROM allocations WHERE created_at < ?", sqliteTime(t))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
 
-		allocations = append(allocations, a)
+// DeleteAllButNewest removes all allocations except the n most recent.
+// Returns ErrInvalidArgument if n is negative.
+func (s *SQLiteStorage) DeleteAllButNewest(n int) (int64, error) {
+	if n < 0 {
+		return 0, ErrInvalidArgument
 	}
+	res, err := s.db.Exec(
+		"DELETE FROM allocations WHERE id NOT IN (SELECT id FROM allocations ORDER BY created_at DESC, id DESC LIMIT ?)",
+		n,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
 
-	return allocations, rows.Err()
+// sqliteTime formats t the way CURRENT_TIMESTAMP stores it, so that
+// comparisons against created_at work lexically.
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
 }
 
-// GetAllocationByQuantity retrieves the most recent allocation for a given quantity.
-// Returns nil if no allocation is found for the quantity.
-func (s *SQLiteStorage) GetAllocationByQuantity(quantity int) (*Allocation, error) {
+// allocationColumns is the column list understood by scanAllocation.
+const allocationColumns = "id, order_quantity, packs, total, order_id, customer_id, metadata, profile, profile_version, source, algorithm, approximate, cacheable, unit, requested_quantity, rules, created_at, hits, last_accessed_at, deleted_at"
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanAllocation reads a row selected with allocationColumns.
+func scanAllocation(row rowScanner) (*Allocation, error) {
 	var a Allocation
-	var packsJSON string
-	err := s.db.QueryRow(
-		"SELECT id, order_quantity, packs, total, created_at FROM allocations WHERE order_quantity = ? ORDER BY created_at DESC LIMIT 1",
-		quantity,
-	).Scan(&a.ID, &a.OrderQuantity, &packsJSON, &a.Total, &a.CreatedAt)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
+	var packsJSON, metadataJSON, rulesJSON string
+	var lastAccessed, deleted sql.NullTime
+	err := row.Scan(&a.ID, &a.OrderQuantity, &packsJSON, &a.Total, &a.OrderID, &a.CustomerID, &metadataJSON, &a.Profile, &a.ProfileVersion, &a.Source, &a.Algorithm, &a.Approximate, &a.Cacheable, &a.Unit, &a.RequestedQuantity, &rulesJSON, &a.CreatedAt, &a.Hits, &lastAccessed, &deleted)
 	if err != nil {
 		return nil, err
 	}
+	a.LastAccessedAt = scanNullTime(lastAccessed)
+	a.DeletedAt = scanNullTime(deleted)
 
-	err = json.Unmarshal([]byte(packsJSON), &a.Packs)
-	if err != nil {
+	if err := json.Unmarshal([]byte(packsJSON), &a.Packs); err != nil {
 		return nil, err
 	}
+	if metadataJSON != "" {
+		if err := json.Unmarshal([]byte(metadataJSON), &a.Metadata); err != nil {
+			return nil, err
+		}
+	}
+	if rulesJSON != "" {
+		if err := json.Unmarshal([]byte(rulesJSON), &a.Rules); err != nil {
+			return nil, err
+		}
+	}
 
 	return &a, nil
 }
 
-// Close closes the SQLite database connection.
+// queryAllocations runs a query selecting allocationColumns and scans every row.
+func (s *SQLiteStorage) queryAllocations(query string, args ...interface{}) ([]Allocation, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var allocations []Allocation
+	for rows.Next() {
+		a, err := scanAllocation(rows)
+		if err != nil {
+			return nil, err
+		}
+		allocations = append(allocations, *a)
+	}
+
+	return allocations, rows.Err()
+}
+
+// Close closes the prepared statements and the SQLite database connection.
 func (s *SQLiteStorage) Close() error {
-	return s.db.Close()
+	var errs []error
+	for _, stmt := range []*sql.Stmt{s.insertAllocation, s.insertAudit} {
+		if stmt != nil {
+			errs = append(errs, stmt.Close())
+		}
+	}
+	return errors.Join(append(errs, s.db.Close())...)
 }
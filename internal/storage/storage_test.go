@@ -1,7 +1,10 @@
 package storage
 
 import (
+	"database/sql"
+	"errors"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -67,7 +70,7 @@ func TestGetRecentAllocations(t *testing.T) {
 	}
 
 	// Test getting all allocations
-	recent, err := storage.GetRecentAllocations(10)
+	recent, err := storage.GetRecentAllocations(10, false)
 	assert.NoError(t, err)
 	assert.Len(t, recent, 3)
 
@@ -77,7 +80,7 @@ func TestGetRecentAllocations(t *testing.T) {
 	assert.Equal(t, 50, recent[2].OrderQuantity)
 
 	// Test limit
-	recent, err = storage.GetRecentAllocations(2)
+	recent, err = storage.GetRecentAllocations(2, false)
 	assert.NoError(t, err)
 	assert.Len(t, recent, 2)
 	assert.Equal(t, 200, recent[0].OrderQuantity)
@@ -109,6 +112,82 @@ func TestGetAllocationByQuantity(t *testing.T) {
 	assert.Equal(t, total, allocation.Total)
 }
 
+func TestGetCachedAllocation(t *testing.T) {
+	storage, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	allocation, err := storage.GetCachedAllocation("default", 50)
+	assert.NoError(t, err)
+	assert.Nil(t, allocation)
+
+	for _, in := range []AllocationInput{
+		{Quantity: 50, Packs: map[int]int{23: 1, 31: 1}, Total: 54, Profile: "default", ProfileVersion: 1, Cacheable: true},
+		{Quantity: 50, Packs: map[int]int{250: 1}, Total: 250, Profile: "apparel", ProfileVersion: 1, Cacheable: true},
+	} {
+		assert.NoError(t, storage.StoreAllocationInput(in))
+	}
+
+	// Only allocations of the requested profile are served, although the
+	// apparel one is more recent
+	allocation, err = storage.GetCachedAllocation("default", 50)
+	assert.NoError(t, err)
+	if assert.NotNil(t, allocation) {
+		assert.Equal(t, "default", allocation.Profile)
+		assert.Equal(t, 54, allocation.Total)
+	}
+
+	// Allocations served from the cache are not, so CreatedAt stays the
+	// time the packs were computed
+	computedAt := allocation.CreatedAt
+	assert.NoError(t, storage.StoreAllocationInput(AllocationInput{
+		Quantity: 50, Packs: map[int]int{23: 1, 31: 1}, Total: 54, Profile: "default", ProfileVersion: 1,
+		Algorithm: "cache", Cacheable: true, CreatedAt: computedAt.Add(time.Hour),
+	}))
+	allocation, err = storage.GetCachedAllocation("default", 50)
+	assert.NoError(t, err)
+	if assert.NotNil(t, allocation) {
+		assert.Equal(t, computedAt, allocation.CreatedAt)
+	}
+
+	// Nor are imported orders, which have no packs
+	assert.NoError(t, storage.StoreAllocationInput(AllocationInput{
+		Quantity: 50, Packs: map[int]int{}, Profile: "default", Algorithm: AlgorithmImport, CreatedAt: computedAt.Add(2 * time.Hour),
+	}))
+	allocation, err = storage.GetCachedAllocation("default", 50)
+	assert.NoError(t, err)
+	if assert.NotNil(t, allocation) {
+		assert.Equal(t, 54, allocation.Total)
+	}
+
+	// Nor are approximate ones, which were not proven optimal
+	assert.NoError(t, storage.StoreAllocationInput(AllocationInput{
+		Quantity: 50, Packs: map[int]int{53: 1}, Total: 53, Profile: "default", ProfileVersion: 1,
+		Algorithm: "greedy", Approximate: true, CreatedAt: computedAt.Add(3 * time.Hour),
+	}))
+	allocation, err = storage.GetCachedAllocation("default", 50)
+	assert.NoError(t, err)
+	if assert.NotNil(t, allocation) {
+		assert.Equal(t, 54, allocation.Total)
+	}
+
+	// Nor are results of other strategies or constraints
+	assert.NoError(t, storage.StoreAllocationInput(AllocationInput{
+		Quantity: 50, Packs: map[int]int{53: 1}, Total: 53, Profile: "default", ProfileVersion: 1,
+		Algorithm: "branchbound", CreatedAt: computedAt.Add(4 * time.Hour),
+	}))
+	allocation, err = storage.GetCachedAllocation("default", 50)
+	assert.NoError(t, err)
+	if assert.NotNil(t, allocation) {
+		assert.Equal(t, 54, allocation.Total)
+	}
+
+	// Caching is a no-op: stored allocations already serve as the cache
+	assert.NoError(t, storage.CacheAllocation(Allocation{OrderQuantity: 60, Profile: "default", Total: 62}))
+	allocation, err = storage.GetCachedAllocation("default", 60)
+	assert.NoError(t, err)
+	assert.Nil(t, allocation)
+}
+
 func TestStoreAllocationWithInvalidData(t *testing.T) {
 	storage, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -121,3 +200,272 @@ func TestStoreAllocationWithInvalidData(t *testing.T) {
 	err = storage.StoreAllocation(50, map[int]int{}, 50)
 	assert.NoError(t, err)
 }
+
+func TestStoreAllocationInputWithReference(t *testing.T) {
+	storage, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	err := storage.StoreAllocationInput(AllocationInput{
+		Quantity:   50,
+		Packs:      map[int]int{53: 1},
+		Total:      53,
+		OrderID:    "ORD-1",
+		CustomerID: "CUST-9",
+		Metadata:   map[string]interface{}{"channel": "web"},
+	})
+	assert.NoError(t, err)
+	err = storage.StoreAllocation(60, map[int]int{31: 2}, 62)
+	assert.NoError(t, err)
+
+	allocations, err := storage.GetAllocationsByOrderID("ORD-1")
+	assert.NoError(t, err)
+	assert.Len(t, allocations, 1)
+	assert.Equal(t, 50, allocations[0].OrderQuantity)
+	assert.Equal(t, "CUST-9", allocations[0].CustomerID)
+	assert.Equal(t, map[string]interface{}{"channel": "web"}, allocations[0].Metadata)
+
+	allocations, err = storage.GetAllocationsByOrderID("ORD-2")
+	assert.NoError(t, err)
+	assert.Empty(t, allocations)
+
+	_, err = storage.GetAllocationsByOrderID("")
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+
+	// Allocations stored without a reference have no metadata
+	allocation, err := storage.GetAllocationByQuantity(60)
+	assert.NoError(t, err)
+	assert.Empty(t, allocation.OrderID)
+	assert.Nil(t, allocation.Metadata)
+}
+
+func TestStoreRequestedQuantity(t *testing.T) {
+	sqlite, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	type dedupStorage interface {
+		Storage
+		SetWriteMode(WriteMode) error
+	}
+	for name, s := range map[string]dedupStorage{"sqlite": sqlite, "bolt": setupBolt(t)} {
+		t.Run(name, func(t *testing.T) {
+			assert.NoError(t, s.SetWriteMode(WriteDedup))
+			for _, in := range []AllocationInput{
+				{Quantity: 36, Packs: map[int]int{23: 2}, Total: 46, Unit: "case", RequestedQuantity: 3},
+				{Quantity: 36, Packs: map[int]int{23: 2}, Total: 46, Unit: "case", RequestedQuantity: 3},
+				{Quantity: 36, Packs: map[int]int{23: 2}, Total: 46},
+			} {
+				assert.NoError(t, s.StoreAllocationInput(in))
+			}
+
+			// Quantities requested in items are not merged with ones in units.
+			recent, err := s.GetRecentAllocations(10, false)
+			assert.NoError(t, err)
+			if assert.Len(t, recent, 2) {
+				assert.Empty(t, recent[0].Unit)
+				assert.Zero(t, recent[0].RequestedQuantity)
+				assert.Equal(t, "case", recent[1].Unit)
+				assert.Equal(t, 3, recent[1].RequestedQuantity)
+				assert.Equal(t, 36, recent[1].OrderQuantity)
+				assert.Equal(t, 2, recent[1].Hits)
+			}
+		})
+	}
+}
+
+func TestStoreAllocations(t *testing.T) {
+	storage, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	assert.NoError(t, storage.StoreAllocations(nil))
+	assert.NoError(t, storage.StoreAllocations([]AllocationInput{
+		{Quantity: 50, Packs: map[int]int{53: 1}, Total: 53, OrderID: "ORD-1"},
+		{Quantity: 60, Packs: map[int]int{31: 2}, Total: 62, OrderID: "ORD-1"},
+	}))
+	allocations, err := storage.GetAllocationsByOrderID("ORD-1")
+	assert.NoError(t, err)
+	assert.Len(t, allocations, 2)
+
+	// One invalid input stores none of the batch
+	err = storage.StoreAllocations([]AllocationInput{
+		{Quantity: 70, Packs: map[int]int{23: 1, 53: 1}, Total: 76, OrderID: "ORD-2"},
+		{Quantity: 80, Total: 0, OrderID: "ORD-2"},
+	})
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+	allocations, err = storage.GetAllocationsByOrderID("ORD-2")
+	assert.NoError(t, err)
+	assert.Empty(t, allocations)
+}
+
+func TestMigrateLegacySchema(t *testing.T) {
+	dbPath := "legacy.db"
+	defer os.Remove(dbPath)
+
+	db, err := sql.Open(sqliteDriver, dbPath)
+	assert.NoError(t, err)
+	_, err = db.Exec(`CREATE TABLE allocations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		order_quantity INTEGER NOT NULL,
+		packs TEXT NOT NULL,
+		total INTEGER NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO allocations (order_quantity, packs, total) VALUES (10, '{"23":1}', 23)`)
+	assert.NoError(t, err)
+	db.Close()
+
+	storage, err := NewSQLiteStorage(dbPath)
+	assert.NoError(t, err)
+	defer storage.Close()
+
+	allocation, err := storage.GetAllocationByQuantity(10)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{23: 1}, allocation.Packs)
+	assert.Empty(t, allocation.OrderID)
+}
+
+func TestExportAllocations(t *testing.T) {
+	storage, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	for _, q := range []int{10, 20, 30} {
+		assert.NoError(t, storage.StoreAllocation(q, map[int]int{23: 1}, 23))
+	}
+	// Backdate one row so it falls outside the range below
+	_, err := storage.db.Exec("UPDATE allocations SET created_at = '2020-01-01 00:00:00' WHERE order_quantity = 10")
+	assert.NoError(t, err)
+
+	var quantities []int
+	collect := func(a Allocation) error {
+		quantities = append(quantities, a.OrderQuantity)
+		return nil
+	}
+
+	assert.NoError(t, storage.ExportAllocations(time.Time{}, time.Time{}, collect))
+	assert.Equal(t, []int{10, 20, 30}, quantities)
+
+	quantities = nil
+	from := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, storage.ExportAllocations(from, time.Time{}, collect))
+	assert.Equal(t, []int{20, 30}, quantities)
+
+	quantities = nil
+	to := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, storage.ExportAllocations(time.Time{}, to, collect))
+	assert.Equal(t, []int{10}, quantities)
+
+	// Callback errors stop the iteration
+	stop := errors.New("stop")
+	err = storage.ExportAllocations(time.Time{}, time.Time{}, func(Allocation) error { return stop })
+	assert.ErrorIs(t, err, stop)
+}
+
+func TestNewInMemorySQLite(t *testing.T) {
+	first, err := NewInMemorySQLite()
+	assert.NoError(t, err)
+	defer first.Close()
+
+	second, err := NewInMemorySQLite()
+	assert.NoError(t, err)
+	defer second.Close()
+
+	assert.NoError(t, first.StoreAllocation(50, map[int]int{53: 1}, 53))
+
+	allocation, err := first.GetAllocationByQuantity(50)
+	assert.NoError(t, err)
+	assert.NotNil(t, allocation)
+
+	// Each instance is isolated from the others
+	allocation, err = second.GetAllocationByQuantity(50)
+	assert.NoError(t, err)
+	assert.Nil(t, allocation)
+}
+
+func TestSQLiteWALMode(t *testing.T) {
+	storage, err := NewSQLiteStorage(t.TempDir() + "/wal.db")
+	assert.NoError(t, err)
+	defer storage.Close()
+
+	var mode string
+	assert.NoError(t, storage.db.QueryRow("PRAGMA journal_mode").Scan(&mode))
+	assert.Equal(t, "wal", mode)
+
+	var timeout int
+	assert.NoError(t, storage.db.QueryRow("PRAGMA busy_timeout").Scan(&timeout))
+	assert.Equal(t, int(sqliteBusyTimeout.Milliseconds()), timeout)
+}
+
+func TestConcurrentStoreAllocation(t *testing.T) {
+	dbPath := t.TempDir() + "/concurrent.db"
+	storage, err := NewSQLiteStorage(dbPath)
+	assert.NoError(t, err)
+	defer storage.Close()
+
+	// A second instance on the same file competes for the write lock the
+	// way another process would.
+	other, err := NewSQLiteStorage(dbPath)
+	assert.NoError(t, err)
+	defer other.Close()
+
+	const writers, perWriter = 16, 25
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*writers*perWriter)
+	for w := 0; w < writers; w++ {
+		s := storage
+		if w%2 == 1 {
+			s = other
+		}
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				errs <- s.StoreAllocationInput(AllocationInput{
+					Quantity: w*perWriter + i,
+					Packs:    map[int]int{23: 1},
+					Total:    23,
+					OrderID:  "ORD-CONCURRENT",
+				})
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				_, err := s.GetRecentAllocations(10, false)
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	allocations, err := storage.GetAllocationsByOrderID("ORD-CONCURRENT")
+	assert.NoError(t, err)
+	assert.Len(t, allocations, writers*perWriter)
+}
+
+func TestStoreAllocationRules(t *testing.T) {
+	sqlite, cleanup := setupTestDB(t)
+	defer cleanup()
+	rules := []RuleMatch{
+		{Rule: "high_waste", Note: "Waste above 10%"},
+		{Rule: "small", Flags: []string{"requires_approval"}},
+	}
+	for name, s := range map[string]Storage{"sqlite": sqlite, "bolt": setupBolt(t)} {
+		assert.NoError(t, s.StoreAllocationInput(AllocationInput{Quantity: 10, Packs: map[int]int{23: 1}, Total: 23, Rules: rules}), name)
+		assert.NoError(t, s.StoreAllocationInput(AllocationInput{Quantity: 500, Packs: map[int]int{250: 2}, Total: 500}), name)
+
+		a, err := s.GetAllocationByQuantity(10)
+		assert.NoError(t, err, name)
+		if assert.NotNil(t, a, name) {
+			assert.Equal(t, rules, a.Rules, name)
+		}
+		a, err = s.GetAllocationByQuantity(500)
+		assert.NoError(t, err, name)
+		if assert.NotNil(t, a, name) {
+			assert.Nil(t, a.Rules, name)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Usage is what one client consumed in one month.
+type Usage struct {
+	Client string `json:"client"`
+	// Month is the calendar month in UTC, e.g. "2025-06".
+	Month    string `json:"month"`
+	Requests int64  `json:"requests"`
+	// Compute is the time spent serving the requests.
+	Compute time.Duration `json:"compute"`
+}
+
+// UsageMonth formats the month at falls in, as usage is kept by.
+func UsageMonth(at time.Time) string {
+	return at.UTC().Format("2006-01")
+}
+
+// RecordUsage counts a request of client towards its usage in the month of
+// at. Compute is kept to the microsecond.
+func (s *SQLiteStorage) RecordUsage(client string, at time.Time, compute time.Duration) error {
+	if client == "" {
+		return ErrInvalidArgument
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO usage (client, month, requests, compute_us) VALUES (?, ?, 1, ?)
+		ON CONFLICT (month, client) DO UPDATE SET
+			requests = requests + 1, compute_us = compute_us + excluded.compute_us`,
+		client, UsageMonth(at), compute.Microseconds(),
+	)
+	return err
+}
+
+// GetUsage returns the usage of every client in the month of at, by client,
+// or only that of client if it is not empty.
+func (s *SQLiteStorage) GetUsage(at time.Time, client string) ([]Usage, error) {
+	query := "SELECT client, month, requests, compute_us FROM usage WHERE month = ?"
+	args := []interface{}{UsageMonth(at)}
+	if client != "" {
+		query += " AND client = ?"
+		args = append(args, client)
+	}
+	rows, err := s.db.Query(query+" ORDER BY client", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := []Usage{}
+	for rows.Next() {
+		var u Usage
+		var micros int64
+		if err := rows.Scan(&u.Client, &u.Month, &u.Requests, &micros); err != nil {
+			return nil, err
+		}
+		u.Compute = time.Duration(micros) * time.Microsecond
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// RecordUsage counts a request of client towards its usage in the month of
+// at. Compute is kept to the microsecond.
+func (s *BoltStorage) RecordUsage(client string, at time.Time, compute time.Duration) error {
+	if client == "" {
+		return ErrInvalidArgument
+	}
+	u := Usage{Client: client, Month: UsageMonth(at)}
+	key := boltUsageKey(u.Month, client)
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltUsage)
+		if v := b.Get(key); v != nil {
+			if err := json.Unmarshal(v, &u); err != nil {
+				return err
+			}
+		}
+		u.Requests++
+		u.Compute += compute.Truncate(time.Microsecond)
+		return boltPut(b, key, u)
+	})
+}
+
+// GetUsage returns the usage of every client in the month of at, by client,
+// or only that of client if it is not empty.
+func (s *BoltStorage) GetUsage(at time.Time, client string) ([]Usage, error) {
+	// The prefix of a client also matches clients it is a prefix of.
+	prefix := boltUsageKey(UsageMonth(at), client)
+	usage := []Usage{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltUsage).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var u Usage
+			if err := json.Unmarshal(v, &u); err != nil {
+				return err
+			}
+			if client == "" || u.Client == client {
+				usage = append(usage, u)
+			}
+		}
+		return nil
+	})
+	return usage, err
+}
+
+// boltUsageKey orders usage by month, then client.
+func boltUsageKey(month, client string) []byte {
+	return []byte(month + "/" + client)
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsage(t *testing.T) {
+	sqlite, err := NewInMemorySQLite()
+	assert.NoError(t, err)
+	defer sqlite.Close()
+
+	for name, s := range map[string]Storage{"sqlite": sqlite, "bolt": setupBolt(t)} {
+		t.Run(name, func(t *testing.T) {
+			june := time.Date(2025, 6, 30, 23, 0, 0, 0, time.UTC)
+			// Months are in UTC: this is still June there.
+			cest := time.Date(2025, 7, 1, 1, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+			july := cest.Add(time.Hour)
+			assert.NoError(t, s.RecordUsage("key:b", june, 1500*time.Microsecond))
+			assert.NoError(t, s.RecordUsage("key:a", june, 2*time.Millisecond))
+			assert.NoError(t, s.RecordUsage("key:b", june, 500*time.Microsecond+300))
+			assert.NoError(t, s.RecordUsage("key:bc", june, time.Millisecond))
+			assert.NoError(t, s.RecordUsage("key:a", cest, time.Millisecond))
+			assert.NoError(t, s.RecordUsage("key:a", july, time.Millisecond))
+			assert.ErrorIs(t, s.RecordUsage("", june, 0), ErrInvalidArgument)
+
+			usage, err := s.GetUsage(june, "")
+			assert.NoError(t, err)
+			assert.Equal(t, []Usage{
+				{Client: "key:a", Month: "2025-06", Requests: 2, Compute: 3 * time.Millisecond},
+				{Client: "key:b", Month: "2025-06", Requests: 2, Compute: 2 * time.Millisecond},
+				{Client: "key:bc", Month: "2025-06", Requests: 1, Compute: time.Millisecond},
+			}, usage)
+
+			usage, err = s.GetUsage(june, "key:b")
+			assert.NoError(t, err)
+			assert.Equal(t, []Usage{{Client: "key:b", Month: "2025-06", Requests: 2, Compute: 2 * time.Millisecond}}, usage)
+
+			usage, err = s.GetUsage(july, "key:a")
+			assert.NoError(t, err)
+			assert.Equal(t, []Usage{{Client: "key:a", Month: "2025-07", Requests: 1, Compute: time.Millisecond}}, usage)
+
+			usage, err = s.GetUsage(june.AddDate(0, -1, 0), "")
+			assert.NoError(t, err)
+			assert.Empty(t, usage)
+		})
+	}
+}
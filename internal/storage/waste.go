@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// Groups waste can be aggregated by.
+const (
+	GroupByAlgorithm = "algorithm"
+	GroupByProfile   = "profile"
+	// GroupByDay groups by the UTC day of creation, as YYYY-MM-DD.
+	GroupByDay = "day"
+)
+
+// WasteStats sums the allocations of one group. Allocations counted as hits
+// on an identical row (see WriteDedup) count once per hit.
+type WasteStats struct {
+	Group       string `json:"group"`
+	Allocations int64  `json:"allocations"`
+	// Quantity sums the quantities ordered, and Waste the items shipped
+	// beyond them.
+	Quantity int64 `json:"quantity"`
+	Waste    int64 `json:"waste"`
+	// MaxWaste is the largest waste of a single allocation.
+	MaxWaste int `json:"max_waste"`
+}
+
+// sqliteGroupColumns maps the GroupBy fields to the expressions they group by.
+var sqliteGroupColumns = map[string]string{
+	GroupByAlgorithm: "algorithm",
+	GroupByProfile:   "profile",
+	GroupByDay:       "substr(created_at, 1, 10)",
+}
+
+// checkGroupBy returns ErrInvalidArgument unless groupBy is a GroupBy field.
+func checkGroupBy(groupBy string) error {
+	if _, ok := sqliteGroupColumns[groupBy]; !ok {
+		return fmt.Errorf("%w: unknown group %q", ErrInvalidArgument, groupBy)
+	}
+	return nil
+}
+
+// AggregateWaste sums the allocations created in [from, to) by group: days
+// in order, other groups most waste first. Deleted and imported allocations
+// are not counted.
+func (s *SQLiteStorage) AggregateWaste(groupBy string, from, to time.Time) ([]WasteStats, error) {
+	if err := checkGroupBy(groupBy); err != nil {
+		return nil, err
+	}
+	group := sqliteGroupColumns[groupBy]
+	query := "SELECT " + group + ", SUM(hits), SUM(order_quantity * hits), SUM((total - order_quantity) * hits), MAX(total - order_quantity)" +
+		" FROM allocations WHERE deleted_at IS NULL AND algorithm != 'import'"
+	var args []interface{}
+	if !from.IsZero() {
+		query += " AND created_at >= ?"
+		args = append(args, sqliteTime(from))
+	}
+	if !to.IsZero() {
+		query += " AND created_at < ?"
+		args = append(args, sqliteTime(to))
+	}
+	query += " GROUP BY " + group
+	if groupBy == GroupByDay {
+		query += " ORDER BY " + group
+	} else {
+		query += " ORDER BY SUM((total - order_quantity) * hits) DESC, " + group
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []WasteStats{}
+	for rows.Next() {
+		var w WasteStats
+		if err := rows.Scan(&w.Group, &w.Allocations, &w.Quantity, &w.Waste, &w.MaxWaste); err != nil {
+			return nil, err
+		}
+		stats = append(stats, w)
+	}
+	return stats, rows.Err()
+}
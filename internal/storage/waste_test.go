@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testAggregateWaste stores a few allocations in s, written with
+// WriteDedup, and checks their waste by group.
+func testAggregateWaste(t *testing.T, s interface {
+	Storage
+	SetWriteMode(WriteMode) error
+}) {
+	assert.NoError(t, s.SetWriteMode(WriteDedup))
+	day := time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC)
+	for _, in := range []AllocationInput{
+		{Quantity: 50, Packs: map[int]int{53: 1}, Total: 53, Algorithm: "dp", CreatedAt: day.AddDate(0, 0, -1)},
+		{Quantity: 60, Packs: map[int]int{31: 2}, Total: 62, Algorithm: "dp", CreatedAt: day},
+		{Quantity: 70, Packs: map[int]int{53: 2}, Total: 106, Algorithm: "greedy", CreatedAt: day},
+		{Quantity: 70, Packs: map[int]int{53: 2}, Total: 106, Algorithm: "greedy", CreatedAt: day},
+		{Quantity: 250, Packs: map[int]int{250: 1}, Total: 250, Algorithm: "dp", Profile: "bulk", CreatedAt: day},
+		// Imported orders have no packs and count towards no group.
+		{Quantity: 400, Packs: map[int]int{}, Algorithm: AlgorithmImport, CreatedAt: day},
+	} {
+		assert.NoError(t, s.StoreAllocationInput(in))
+	}
+
+	stats, err := s.AggregateWaste(GroupByAlgorithm, time.Time{}, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, []WasteStats{
+		{Group: "greedy", Allocations: 2, Quantity: 140, Waste: 72, MaxWaste: 36},
+		{Group: "dp", Allocations: 3, Quantity: 360, Waste: 5, MaxWaste: 3},
+	}, stats)
+
+	stats, err = s.AggregateWaste(GroupByProfile, day, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, []WasteStats{
+		{Group: "", Allocations: 3, Quantity: 200, Waste: 74, MaxWaste: 36},
+		{Group: "bulk", Allocations: 1, Quantity: 250},
+	}, stats)
+
+	stats, err = s.AggregateWaste(GroupByDay, time.Time{}, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, []WasteStats{
+		{Group: "2025-06-01", Allocations: 1, Quantity: 50, Waste: 3, MaxWaste: 3},
+		{Group: "2025-06-02", Allocations: 4, Quantity: 450, Waste: 74, MaxWaste: 36},
+	}, stats)
+
+	stats, err = s.AggregateWaste(GroupByDay, day.AddDate(0, 0, 1), time.Time{})
+	assert.NoError(t, err)
+	assert.Empty(t, stats)
+
+	_, err = s.AggregateWaste("week", time.Time{}, time.Time{})
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+}
+
+func TestAggregateWaste(t *testing.T) {
+	s, err := NewInMemorySQLite()
+	assert.NoError(t, err)
+	defer s.Close()
+	testAggregateWaste(t, s)
+}
+
+func TestBoltAggregateWaste(t *testing.T) {
+	testAggregateWaste(t, setupBolt(t))
+}
@@ -0,0 +1,134 @@
+// Package worker pre-computes allocations for orders as they are created, so
+// that by the time the fulfilment service asks the API for them they are
+// served from the result cache.
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/events"
+)
+
+// resubscribeDelay is how long Run waits before resubscribing after the
+// subscription fails.
+const resubscribeDelay = time.Second
+
+// Order is an order-created message. It carries either a single line
+// (SKU, Profile and Quantity) or several Items.
+type Order struct {
+	OrderID  string      `json:"order_id"`
+	SKU      string      `json:"sku"`
+	Profile  string      `json:"profile"`
+	Quantity int         `json:"quantity"`
+	Items    []OrderItem `json:"items"`
+}
+
+// OrderItem is one line of an order. Profile overrides the profile mapped
+// to the SKU.
+type OrderItem struct {
+	SKU      string `json:"sku"`
+	Profile  string `json:"profile"`
+	Quantity int    `json:"quantity"`
+}
+
+// items returns the lines of the order.
+func (o Order) items() []OrderItem {
+	if len(o.Items) > 0 {
+		return o.Items
+	}
+	if o.Quantity == 0 {
+		return nil
+	}
+	return []OrderItem{{SKU: o.SKU, Profile: o.Profile, Quantity: o.Quantity}}
+}
+
+// Stats counts the order lines a Worker has handled.
+type Stats struct {
+	// Warmed lines had their allocation computed and stored.
+	Warmed int64
+	// Skipped lines already had a current allocation stored.
+	Skipped int64
+	// Failed lines could not be allocated, or were malformed.
+	Failed int64
+}
+
+// Worker warms the allocator's result cache from order-created messages.
+type Worker struct {
+	alloc   *allocator.Allocator
+	warmed  atomic.Int64
+	skipped atomic.Int64
+	failed  atomic.Int64
+}
+
+// New returns a worker storing allocations through alloc.
+func New(alloc *allocator.Allocator) *Worker {
+	return &Worker{alloc: alloc}
+}
+
+// Stats returns the lines handled so far.
+func (w *Worker) Stats() Stats {
+	return Stats{Warmed: w.warmed.Load(), Skipped: w.skipped.Load(), Failed: w.failed.Load()}
+}
+
+// Handle warms the allocation of every line of an order-created message.
+// Lines use the profile mapped to their SKU unless they name one. Every line
+// is attempted; the errors of those that fail are returned together.
+func (w *Worker) Handle(ctx context.Context, payload []byte) error {
+	var order Order
+	if err := json.Unmarshal(payload, &order); err != nil {
+		w.failed.Add(1)
+		return fmt.Errorf("decode order: %w", err)
+	}
+	items := order.items()
+	if len(items) == 0 {
+		w.failed.Add(1)
+		return fmt.Errorf("order %q has no quantity or items", order.OrderID)
+	}
+
+	var errs []error
+	for i, item := range items {
+		profile := item.Profile
+		if profile == "" {
+			profile = w.alloc.ProfileForSKU(item.SKU)
+		}
+		warmed, err := w.alloc.Warm(ctx, item.Quantity, profile)
+		switch {
+		case err != nil:
+			w.failed.Add(1)
+			errs = append(errs, fmt.Errorf("order %q item %d: %w", order.OrderID, i, err))
+		case warmed:
+			w.warmed.Add(1)
+		default:
+			w.skipped.Add(1)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run handles messages from sub until ctx is done, resubscribing if the
+// subscription fails. Failed messages are logged and skipped.
+func (w *Worker) Run(ctx context.Context, sub events.Subscriber) {
+	for {
+		err := sub.Subscribe(ctx, func(payload []byte) {
+			if err := w.Handle(ctx, payload); err != nil {
+				log.Printf("Failed to warm order: %v", err)
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Order subscription failed, retrying in %s: %v", resubscribeDelay, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(resubscribeDelay):
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/n-th/gymshark/internal/allocator"
+	"github.com/n-th/gymshark/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func newAllocator(t *testing.T) (*allocator.Allocator, storage.Storage) {
+	store, err := storage.NewInMemorySQLite()
+	assert.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	alloc := allocator.NewAllocator([]int{250, 500, 1000}, store)
+	assert.NoError(t, alloc.SetProfiles(map[string][]int{"apparel": {10, 20}}, map[string]string{"TSHIRT": "apparel"}))
+	assert.NoError(t, alloc.RecordProfileVersions())
+	alloc.SetResultCache(true)
+	return alloc, store
+}
+
+func TestHandle(t *testing.T) {
+	alloc, store := newAllocator(t)
+	w := New(alloc)
+	ctx := context.Background()
+
+	assert.NoError(t, w.Handle(ctx, []byte(`{"order_id": "ORD-1", "quantity": 501}`)))
+	assert.NoError(t, w.Handle(ctx, []byte(`{"order_id": "ORD-2", "items": [
+		{"sku": "TSHIRT", "quantity": 35},
+		{"sku": "BOTTLE", "quantity": 501},
+		{"sku": "MUG", "profile": "apparel", "quantity": 40}
+	]}`)))
+	assert.Equal(t, Stats{Warmed: 3, Skipped: 1}, w.Stats())
+
+	stored, err := store.GetAllocationByQuantity(35)
+	assert.NoError(t, err)
+	if assert.NotNil(t, stored) {
+		assert.Equal(t, "apparel", stored.Profile)
+		assert.Equal(t, map[int]int{20: 2}, stored.Packs)
+	}
+
+	// The API is now served from the cache
+	result, err := alloc.Allocate(ctx, allocator.Request{Quantity: 501})
+	assert.NoError(t, err)
+	assert.Equal(t, allocator.CacheHit, result.Stats.Cache)
+}
+
+func TestHandleErrors(t *testing.T) {
+	alloc, _ := newAllocator(t)
+	w := New(alloc)
+	ctx := context.Background()
+
+	assert.Error(t, w.Handle(ctx, []byte(`not json`)))
+	assert.Error(t, w.Handle(ctx, []byte(`{"order_id": "ORD-3"}`)))
+
+	// Good lines are warmed even when others fail
+	err := w.Handle(ctx, []byte(`{"order_id": "ORD-4", "items": [
+		{"sku": "A", "quantity": 0},
+		{"sku": "B", "profile": "unknown", "quantity": 10},
+		{"sku": "C", "quantity": 250}
+	]}`))
+	assert.ErrorIs(t, err, allocator.ErrInvalidQuantity)
+	assert.ErrorIs(t, err, allocator.ErrUnknownProfile)
+	assert.ErrorContains(t, err, `order "ORD-4" item 1`)
+	assert.Equal(t, Stats{Warmed: 1, Failed: 4}, w.Stats())
+}
+
+// fakeSubscriber delivers its messages on the first subscription, fails it,
+// and blocks on the next one until ctx is done.
+type fakeSubscriber struct {
+	mu            sync.Mutex
+	messages      []string
+	subscriptions int
+}
+
+func (s *fakeSubscriber) Subscribe(ctx context.Context, fn func([]byte)) error {
+	s.mu.Lock()
+	s.subscriptions++
+	first := s.subscriptions == 1
+	s.mu.Unlock()
+
+	if first {
+		for _, m := range s.messages {
+			fn([]byte(m))
+		}
+		return errors.New("connection reset")
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (s *fakeSubscriber) Close() error {
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	alloc, _ := newAllocator(t)
+	w := New(alloc)
+	sub := &fakeSubscriber{messages: []string{`{"quantity": 250}`, `bad`, `{"quantity": 750}`}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx, sub)
+		close(done)
+	}()
+
+	// Run resubscribes after the failure
+	assert.Eventually(t, func() bool {
+		sub.mu.Lock()
+		defer sub.mu.Unlock()
+		return sub.subscriptions == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	assert.Equal(t, Stats{Warmed: 2, Failed: 1}, w.Stats())
+}
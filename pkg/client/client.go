@@ -0,0 +1,378 @@
+// Package client is a typed Go client for the pack allocation API.
+//
+// Requests that fail with a network error, 429 or a 5xx status are retried
+// with exponential backoff until the retry budget or the context runs out.
+// Calculations are stored by the API, so they are only retried when the
+// request was not handled: it could not connect, or the API answered 429 or
+// 503 without calculating.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// calculatePath is the route of Calculate, whose responses are signed.
+const calculatePath = "/v1/calculate"
+
+// Defaults used by New unless overridden with an Option.
+const (
+	DefaultTimeout     = 30 * time.Second
+	DefaultMaxRetries  = 3
+	DefaultBackoff     = 100 * time.Millisecond
+	DefaultMaxBackoff  = 2 * time.Second
+	DefaultConcurrency = 4
+)
+
+// Client calls the pack allocation API. It is safe for concurrent use.
+type Client struct {
+	baseURL     *url.URL
+	httpClient  *http.Client
+	token       string
+	headers     http.Header
+	maxRetries  int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	concurrency int
+	verifier    *Verifier
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithToken sends token as a bearer token on every request.
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithHeader sets a header on every request, e.g. an API key.
+func WithHeader(key, value string) Option {
+	return func(c *Client) { c.headers.Set(key, value) }
+}
+
+// WithRetry sets how many times a failed request is retried and the
+// initial and maximum delay between attempts. Zero retries disables retrying.
+func WithRetry(maxRetries int, backoff, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// WithConcurrency sets how many requests CalculateBatch runs at once.
+func WithConcurrency(n int) Option {
+	return func(c *Client) { c.concurrency = n }
+}
+
+// WithVerifier verifies the signature of every calculation response with v.
+// Responses that are unsigned or fail verification return an error wrapping
+// ErrInvalidSignature and are not retried.
+func WithVerifier(v *Verifier) Option {
+	return func(c *Client) { c.verifier = v }
+}
+
+// New creates a client for the API served at baseURL, e.g. "http://localhost:8080".
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL %q: scheme must be http or https", baseURL)
+	}
+
+	c := &Client{
+		baseURL:     u,
+		httpClient:  &http.Client{Timeout: DefaultTimeout},
+		headers:     make(http.Header),
+		maxRetries:  DefaultMaxRetries,
+		backoff:     DefaultBackoff,
+		maxBackoff:  DefaultMaxBackoff,
+		concurrency: DefaultConcurrency,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.maxRetries < 0 {
+		c.maxRetries = 0
+	}
+	if c.concurrency < 1 {
+		c.concurrency = 1
+	}
+	return c, nil
+}
+
+// Calculate allocates packs for a single quantity.
+func (c *Client) Calculate(ctx context.Context, req CalculateRequest) (*Result, error) {
+	var result Result
+	if err := c.do(ctx, http.MethodPost, calculatePath, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CalculateBatch allocates packs for every request, running up to the
+// configured concurrency at once. Results are in request order. The first
+// failure cancels the remaining requests and is returned as a *BatchError.
+func (c *Client) CalculateBatch(ctx context.Context, reqs []CalculateRequest) ([]Result, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]Result, len(reqs))
+	sem := make(chan struct{}, c.concurrency)
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for i, req := range reqs {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, req CalculateRequest) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result, err := c.Calculate(ctx, req)
+			if err != nil {
+				once.Do(func() {
+					firstErr = &BatchError{Index: i, Err: err}
+					cancel()
+				})
+				return
+			}
+			results[i] = *result
+		}(i, req)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Recent returns the most recent allocations, newest first.
+func (c *Client) Recent(ctx context.Context) ([]Allocation, error) {
+	var body struct {
+		Allocations []Allocation `json:"allocations"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/v1/recent", nil, &body); err != nil {
+		return nil, err
+	}
+	return body.Allocations, nil
+}
+
+// do sends a request, retrying transient failures, and decodes a successful
+// JSON response into out.
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var payload []byte
+	if in != nil {
+		var err error
+		if payload, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			if err := c.wait(ctx, attempt); err != nil {
+				return lastErr
+			}
+		}
+
+		retry, err := c.attempt(ctx, method, path, payload, out)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry || ctx.Err() != nil {
+			return err
+		}
+	}
+	return lastErr
+}
+
+// attempt performs a single request and reports whether a failure is worth retrying.
+func (c *Client) attempt(ctx context.Context, method, path string, payload []byte, out interface{}) (bool, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL.String()+path, body)
+	if err != nil {
+		return false, err
+	}
+	for key, values := range c.headers {
+		req.Header[key] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// The request may have been handled unless it never connected.
+		var opErr *net.OpError
+		return idempotent(method) || errors.As(err, &opErr) && opErr.Op == "dial", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if c.verifier != nil && path == calculatePath {
+			return false, c.verified(resp, out)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return false, fmt.Errorf("decode response: %w", err)
+		}
+		return false, nil
+	}
+
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(raw, apiErr) != nil || apiErr.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(raw))
+	}
+	if !idempotent(method) {
+		// 429 and 503 are answered before anything is calculated or stored.
+		return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable, apiErr
+	}
+	return apiErr.Temporary(), apiErr
+}
+
+// idempotent reports whether repeating a request with method has the same
+// effect as sending it once. Each POST /v1/calculate stores an allocation,
+// so repeating one the API handled would store it twice.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// verified verifies the signature of a response before decoding it into out.
+func (c *Client) verified(resp *http.Response, out interface{}) error {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if err := c.verifier.Verify(resp.Header, body); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// wait sleeps before the given retry attempt, doubling the delay each time
+// with up to 50% jitter. It returns early with the context's error.
+func (c *Client) wait(ctx context.Context, attempt int) error {
+	delay := c.backoff << (attempt - 1)
+	if delay > c.maxBackoff || delay <= 0 {
+		delay = c.maxBackoff
+	}
+	if delay > 0 {
+		delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// APIError is a non-2xx response from the API.
+type APIError struct {
+	StatusCode int
+	Message    string `json:"error"`
+	// Code identifies the error independently of the language of Message,
+	// e.g. "no_combination". It is empty for errors without a code.
+	Code string `json:"code"`
+	// Cached is set on 422 responses served from the infeasible cache.
+	Cached bool `json:"cached"`
+	// Errors lists the request fields that failed validation on 400
+	// responses.
+	Errors []FieldError `json:"errors"`
+}
+
+// FieldError is a request field that failed a validation rule, e.g.
+// {Field: "quantity", Rule: "gt=0"}.
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("pack allocation API: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+	for i, f := range e.Errors {
+		sep := ", "
+		if i == 0 {
+			sep = ": "
+		}
+		msg += sep + f.Field + " " + f.Rule
+	}
+	return msg
+}
+
+// Temporary reports whether the request may succeed if retried.
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests ||
+		(e.StatusCode >= 500 && e.StatusCode != http.StatusNotImplemented)
+}
+
+// BatchError reports which request of a batch failed.
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch request %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// IsStatus reports whether err is an *APIError with the given status code.
+func IsStatus(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
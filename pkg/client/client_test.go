@@ -0,0 +1,221 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fastRetry keeps retry tests quick.
+var fastRetry = WithRetry(3, time.Millisecond, 5*time.Millisecond)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc, opts ...Option) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	c, err := New(server.URL, append([]Option{fastRetry}, opts...)...)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	return c
+}
+
+// calculateHandler answers POST /v1/calculate with a single pack covering the quantity.
+func calculateHandler(w http.ResponseWriter, r *http.Request) {
+	var req CalculateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Quantity <= 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid quantity"})
+		return
+	}
+	json.NewEncoder(w).Encode(Result{Packs: map[int]int{req.Quantity: 1}, Total: req.Quantity, OrderID: req.OrderID})
+}
+
+func TestNew(t *testing.T) {
+	_, err := New("localhost:8080")
+	assert.Error(t, err)
+
+	c, err := New("http://localhost:8080/")
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080", c.baseURL.String())
+}
+
+func TestCalculate(t *testing.T) {
+	var got *http.Request
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/v1/calculate", r.URL.Path)
+		calculateHandler(w, r)
+	}, WithToken("secret"), WithHeader("X-Api-Key", "key"))
+
+	result, err := c.Calculate(context.Background(), CalculateRequest{Quantity: 250, OrderID: "ORD-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{250: 1}, result.Packs)
+	assert.Equal(t, 250, result.Total)
+	assert.Equal(t, "ORD-1", result.OrderID)
+	assert.Equal(t, "Bearer secret", got.Header.Get("Authorization"))
+	assert.Equal(t, "key", got.Header.Get("X-Api-Key"))
+	assert.Equal(t, "application/json", got.Header.Get("Content-Type"))
+}
+
+func TestCalculateAPIError(t *testing.T) {
+	var calls int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"error":"no feasible combination","code":"no_combination","cached":true}`))
+	})
+
+	_, err := c.Calculate(context.Background(), CalculateRequest{Quantity: 7})
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusUnprocessableEntity, apiErr.StatusCode)
+	assert.Equal(t, "no feasible combination", apiErr.Message)
+	assert.Equal(t, "no_combination", apiErr.Code)
+	assert.True(t, apiErr.Cached)
+	assert.True(t, IsStatus(err, http.StatusUnprocessableEntity))
+	// Client errors are not retried
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestCalculateValidationError(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid request body","errors":[{"field":"quantity","rule":"gt=0"}]}`))
+	})
+
+	_, err := c.Calculate(context.Background(), CalculateRequest{Quantity: 0})
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, []FieldError{{Field: "quantity", Rule: "gt=0"}}, apiErr.Errors)
+	assert.EqualError(t, err, "pack allocation API: 400 Bad Request: invalid request body: quantity gt=0")
+}
+
+func TestRetry(t *testing.T) {
+	var calls int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("unavailable"))
+			return
+		}
+		calculateHandler(w, r)
+	})
+
+	result, err := c.Calculate(context.Background(), CalculateRequest{Quantity: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 10, result.Total)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestRetryExhausted(t *testing.T) {
+	var calls int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusGatewayTimeout)
+		w.Write([]byte(`{"error":"calculation timeout"}`))
+	})
+
+	_, err := c.Recent(context.Background())
+	assert.True(t, IsStatus(err, http.StatusGatewayTimeout))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+}
+
+func TestRetryCalculateNotRepeated(t *testing.T) {
+	// A calculation that failed after the API handled it may have been
+	// stored, so it is not sent again.
+	for _, status := range []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout} {
+		var calls int32
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(status)
+		})
+
+		_, err := c.Calculate(context.Background(), CalculateRequest{Quantity: 10})
+		assert.True(t, IsStatus(err, status))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls), status)
+	}
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		panic(http.ErrAbortHandler)
+	}))
+	defer server.Close()
+	c, err := New(server.URL, fastRetry)
+	assert.NoError(t, err)
+	_, err = c.Calculate(context.Background(), CalculateRequest{Quantity: 10})
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// Requests that never connected are retried.
+	server.Close()
+	var dials int32
+	dialer := &net.Dialer{}
+	c, err = New(server.URL, fastRetry, WithHTTPClient(&http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}}))
+	assert.NoError(t, err)
+	_, err = c.Calculate(context.Background(), CalculateRequest{Quantity: 10})
+	assert.Error(t, err)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&dials))
+}
+
+func TestRetryContextCancelled(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, WithRetry(10, time.Second, time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := c.Calculate(ctx, CalculateRequest{Quantity: 10})
+	assert.True(t, IsStatus(err, http.StatusServiceUnavailable))
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestCalculateBatch(t *testing.T) {
+	c := newTestClient(t, calculateHandler, WithConcurrency(2))
+
+	reqs := []CalculateRequest{{Quantity: 1}, {Quantity: 250}, {Quantity: 501}, {Quantity: 12001}}
+	results, err := c.CalculateBatch(context.Background(), reqs)
+	assert.NoError(t, err)
+	if assert.Len(t, results, len(reqs)) {
+		for i, req := range reqs {
+			assert.Equal(t, req.Quantity, results[i].Total)
+		}
+	}
+
+	_, err = c.CalculateBatch(context.Background(), []CalculateRequest{{Quantity: 1}, {Quantity: 0}})
+	var batchErr *BatchError
+	assert.True(t, errors.As(err, &batchErr))
+	assert.Equal(t, 1, batchErr.Index)
+	assert.True(t, IsStatus(err, http.StatusBadRequest))
+}
+
+func TestRecent(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/v1/recent", r.URL.Path)
+		w.Write([]byte(`{"allocations":[{"ID":1,"OrderQuantity":250,"Packs":{"250":1},"Total":250,"CreatedAt":"2024-01-02T03:04:05Z"}]}`))
+	})
+
+	allocations, err := c.Recent(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, allocations, 1) {
+		assert.Equal(t, 250, allocations[0].OrderQuantity)
+		assert.Equal(t, map[int]int{250: 1}, allocations[0].Packs)
+	}
+}
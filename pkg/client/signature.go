@@ -0,0 +1,114 @@
+package client
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrInvalidSignature is returned, wrapped, when a response signature is
+// missing or does not verify.
+var ErrInvalidSignature = errors.New("invalid response signature")
+
+// Response signature headers set by the API when response signing is
+// configured.
+const (
+	SignatureHeader          = "X-Signature"
+	SignatureKeyIDHeader     = "X-Signature-Key-Id"
+	SignatureAlgorithmHeader = "X-Signature-Algorithm"
+	SignatureTimestampHeader = "X-Signature-Timestamp"
+)
+
+// Verifier checks the signatures the API adds to calculation responses.
+// Keys are looked up by the key ID sent with the signature; a key added
+// with an empty ID verifies signatures sent without one.
+type Verifier struct {
+	hmacKeys    map[string][]byte
+	ed25519Keys map[string]ed25519.PublicKey
+	maxAge      time.Duration
+	now         func() time.Time
+}
+
+// NewVerifier returns a verifier without keys. Signatures older than
+// maxAge, or as far in the future, are rejected to limit replays; zero
+// accepts any age.
+func NewVerifier(maxAge time.Duration) *Verifier {
+	return &Verifier{
+		hmacKeys:    make(map[string][]byte),
+		ed25519Keys: make(map[string]ed25519.PublicKey),
+		maxAge:      maxAge,
+		now:         time.Now,
+	}
+}
+
+// AddHMACKey accepts hmac-sha256 signatures made with secret under keyID.
+func (v *Verifier) AddHMACKey(keyID string, secret []byte) *Verifier {
+	v.hmacKeys[keyID] = append([]byte(nil), secret...)
+	return v
+}
+
+// AddEd25519Key accepts ed25519 signatures made with the private key of
+// public under keyID.
+func (v *Verifier) AddEd25519Key(keyID string, public ed25519.PublicKey) *Verifier {
+	v.ed25519Keys[keyID] = public
+	return v
+}
+
+// Verify checks the signature headers of a response against its body, as
+// received after decompression.
+func (v *Verifier) Verify(header http.Header, body []byte) error {
+	encoded := header.Get(SignatureHeader)
+	if encoded == "" {
+		return fmt.Errorf("%w: response is not signed", ErrInvalidSignature)
+	}
+	signature, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("%w: malformed signature", ErrInvalidSignature)
+	}
+
+	timestamp := header.Get(SignatureTimestampHeader)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: malformed timestamp %q", ErrInvalidSignature, timestamp)
+	}
+	if v.maxAge > 0 {
+		age := v.now().Sub(time.Unix(seconds, 0))
+		if age > v.maxAge || age < -v.maxAge {
+			return fmt.Errorf("%w: signed %s ago, more than %s", ErrInvalidSignature, age.Round(time.Second), v.maxAge)
+		}
+	}
+
+	payload := make([]byte, 0, len(timestamp)+1+len(body))
+	payload = append(append(append(payload, timestamp...), '.'), body...)
+
+	keyID := header.Get(SignatureKeyIDHeader)
+	switch algorithm := header.Get(SignatureAlgorithmHeader); algorithm {
+	case "hmac-sha256":
+		secret, ok := v.hmacKeys[keyID]
+		if !ok {
+			return fmt.Errorf("%w: unknown hmac-sha256 key %q", ErrInvalidSignature, keyID)
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(payload)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return fmt.Errorf("%w: hmac mismatch", ErrInvalidSignature)
+		}
+	case "ed25519":
+		public, ok := v.ed25519Keys[keyID]
+		if !ok {
+			return fmt.Errorf("%w: unknown ed25519 key %q", ErrInvalidSignature, keyID)
+		}
+		if !ed25519.Verify(public, payload, signature) {
+			return fmt.Errorf("%w: ed25519 verification failed", ErrInvalidSignature)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidSignature, algorithm)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// signHMAC returns the headers the API sends for body signed at ts.
+func signHMAC(keyID string, secret, body []byte, ts time.Time) http.Header {
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	header := make(http.Header)
+	header.Set(SignatureHeader, base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	header.Set(SignatureAlgorithmHeader, "hmac-sha256")
+	header.Set(SignatureTimestampHeader, timestamp)
+	header.Set(SignatureKeyIDHeader, keyID)
+	return header
+}
+
+func TestVerifier(t *testing.T) {
+	body := []byte(`{"packs":{"250":1},"total":250}`)
+	now := time.Now()
+	v := NewVerifier(time.Minute).AddHMACKey("k1", testSecret)
+
+	assert.NoError(t, v.Verify(signHMAC("k1", testSecret, body, now), body))
+
+	tests := map[string]struct {
+		header http.Header
+		body   []byte
+	}{
+		"tampered body": {signHMAC("k1", testSecret, body, now), bytes.Replace(body, []byte("250"), []byte("500"), 1)},
+		"wrong secret":  {signHMAC("k1", []byte("another secret of thirty-two bytes"), body, now), body},
+		"unknown key":   {signHMAC("k2", testSecret, body, now), body},
+		"expired":       {signHMAC("k1", testSecret, body, now.Add(-2*time.Minute)), body},
+		"unsigned":      {make(http.Header), body},
+	}
+	for name, tt := range tests {
+		err := v.Verify(tt.header, tt.body)
+		assert.True(t, errors.Is(err, ErrInvalidSignature), name)
+	}
+
+	// Ed25519 signatures verify with the public key
+	public, private, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	header := make(http.Header)
+	header.Set(SignatureHeader, base64.StdEncoding.EncodeToString(ed25519.Sign(private, append([]byte(timestamp+"."), body...))))
+	header.Set(SignatureAlgorithmHeader, "ed25519")
+	header.Set(SignatureTimestampHeader, timestamp)
+
+	v = NewVerifier(0).AddEd25519Key("", public)
+	assert.NoError(t, v.Verify(header, body))
+	assert.True(t, errors.Is(v.Verify(header, append(body, ' ')), ErrInvalidSignature))
+}
+
+func TestCalculateVerified(t *testing.T) {
+	var secret []byte
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		calculateHandler(rec, r)
+		for key, values := range signHMAC("k1", secret, rec.Body.Bytes(), time.Now()) {
+			w.Header()[key] = values
+		}
+		w.Write(rec.Body.Bytes())
+	}, WithVerifier(NewVerifier(time.Minute).AddHMACKey("k1", testSecret)))
+
+	secret = testSecret
+	result, err := c.Calculate(context.Background(), CalculateRequest{Quantity: 250})
+	assert.NoError(t, err)
+	assert.Equal(t, 250, result.Total)
+
+	secret = []byte("an attacker's secret, 32 bytes long")
+	_, err = c.Calculate(context.Background(), CalculateRequest{Quantity: 250})
+	assert.True(t, errors.Is(err, ErrInvalidSignature))
+}
@@ -0,0 +1,83 @@
+package client
+
+import "time"
+
+// CalculateRequest is the body of a calculation. Only Quantity is required.
+type CalculateRequest struct {
+	Quantity   int                    `json:"quantity"`
+	Strategy   string                 `json:"strategy,omitempty"`
+	OrderID    string                 `json:"order_id,omitempty"`
+	CustomerID string                 `json:"customer_id,omitempty"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	// Available is the stock per pack size. A quantity it cannot cover
+	// is allocated as far as it goes, with the rest in Result.Shortfall.
+	Available   map[int]int  `json:"available,omitempty"`
+	Constraints *Constraints `json:"constraints,omitempty"`
+	Weights     *Weights     `json:"weights,omitempty"`
+}
+
+// Weights make the server minimize Waste*waste + Packs*packs + Cost*cost
+// instead of waste first, where cost sums Constraints.Costs (1 per pack by
+// default).
+type Weights struct {
+	Waste float64 `json:"w_waste"`
+	Packs float64 `json:"w_packs"`
+	Cost  float64 `json:"w_cost"`
+}
+
+// Constraints restrict the packs a calculation may use. Map keys are pack
+// sizes. Constrained calculations are solved with the branchbound strategy.
+type Constraints struct {
+	Available map[int]int     `json:"available,omitempty"`
+	MaxCounts map[int]int     `json:"max_counts,omitempty"`
+	MaxPacks  int             `json:"max_packs,omitempty"`
+	Costs     map[int]float64 `json:"costs,omitempty"`
+	// AllowShortfall returns what the constraints allow, with the rest in
+	// Result.Shortfall, instead of an error when they cannot cover the
+	// quantity.
+	AllowShortfall bool `json:"allow_shortfall,omitempty"`
+}
+
+// Result is the pack distribution for a quantity.
+type Result struct {
+	// Packs maps pack size to the number of packs of that size.
+	Packs map[int]int `json:"packs"`
+	Total int         `json:"total"`
+	// Approximate is set when the server returned its best answer before
+	// proving it optimal.
+	Approximate bool `json:"approximate"`
+	// Cached is set when the server reused a stored allocation instead of
+	// computing the result for this request.
+	Cached bool `json:"cached"`
+	// ComputedAt is when the packs were computed; for a cached result, when
+	// the stored allocation was. The server only reuses allocations computed
+	// with the current pack sizes.
+	ComputedAt time.Time `json:"computed_at"`
+	// Shortfall is how much of the quantity the available stock could not
+	// cover.
+	Shortfall int `json:"shortfall,omitempty"`
+	// Source is "manual" when the quantity is pinned, and empty when the
+	// result was computed.
+	Source     string `json:"source,omitempty"`
+	OrderID    string `json:"order_id,omitempty"`
+	CustomerID string `json:"customer_id,omitempty"`
+}
+
+// Allocation is a stored allocation as returned by Recent.
+type Allocation struct {
+	ID             int64
+	OrderQuantity  int
+	Packs          map[int]int
+	Total          int
+	OrderID        string                 `json:",omitempty"`
+	CustomerID     string                 `json:",omitempty"`
+	Metadata       map[string]interface{} `json:",omitempty"`
+	Profile        string                 `json:",omitempty"`
+	ProfileVersion int                    `json:",omitempty"`
+	Source         string                 `json:",omitempty"`
+	// Algorithm names what produced the allocation, e.g. "dp", "cache",
+	// "manual", or "greedy" with Approximate after a timeout fallback.
+	Algorithm   string `json:",omitempty"`
+	Approximate bool   `json:",omitempty"`
+	CreatedAt   time.Time
+}
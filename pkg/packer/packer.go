@@ -0,0 +1,132 @@
+// Package packer computes pack allocations in-process, without the HTTP API
+// or its storage: for an ordered quantity and a set of pack sizes, it finds
+// the whole packs that cover the quantity with the least waste, then the
+// fewest packs.
+//
+//	a, err := packer.New([]int{250, 500, 1000, 2000, 5000}).Allocate(12001)
+//	// a.Packs is map[5000:2 2000:1 250:1], a.Total 12250
+//
+// It depends on the standard library only. The API server calculates with
+// the same package, so allocations match its exact strategies, ties
+// included: of the allocations as wasteful and with as many packs, the one
+// with more packs of the largest size where they differ wins.
+package packer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+var (
+	ErrInvalidQuantity = errors.New("quantity must be greater than 0")
+	ErrNoPackSizes     = errors.New("no pack sizes configured")
+	ErrInvalidPackSize = errors.New("pack sizes must be positive")
+	ErrNoCombination   = errors.New("no valid pack combination found")
+	// ErrOverflow reports a quantity so close to the int limit that some
+	// total considered would not fit in an int.
+	ErrOverflow = errors.New("quantity too large for exact calculation")
+)
+
+// Allocation is the packs covering a quantity.
+type Allocation struct {
+	Quantity int
+	// Packs maps each pack size used to its number of packs.
+	Packs map[int]int
+	// Total is the number of items the packs hold.
+	Total int
+}
+
+// Waste is how many items the packs hold beyond the quantity.
+func (a Allocation) Waste() int {
+	return a.Total - a.Quantity
+}
+
+// PackCount is the number of packs.
+func (a Allocation) PackCount() int {
+	n := 0
+	for _, count := range a.Packs {
+		n += count
+	}
+	return n
+}
+
+// Packer allocates packs of a fixed set of sizes. It is safe for concurrent
+// use.
+type Packer struct {
+	sizes []int
+	err   error
+}
+
+// New returns a Packer for sizes, in any order; duplicates are ignored. An
+// empty set or one with a size that is not positive makes every Allocate
+// fail with ErrNoPackSizes or ErrInvalidPackSize.
+func New(sizes []int) *Packer {
+	p := &Packer{}
+	if len(sizes) == 0 {
+		p.err = ErrNoPackSizes
+		return p
+	}
+	seen := make(map[int]bool, len(sizes))
+	for _, size := range sizes {
+		if size <= 0 {
+			p.err = fmt.Errorf("%w: %d", ErrInvalidPackSize, size)
+			return p
+		}
+		if !seen[size] {
+			seen[size] = true
+			p.sizes = append(p.sizes, size)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(p.sizes)))
+	return p
+}
+
+// Sizes returns the pack sizes, largest first.
+func (p *Packer) Sizes() []int {
+	return append([]int(nil), p.sizes...)
+}
+
+// Allocate returns the allocation of quantity with the least waste, then
+// the fewest packs. It takes time and memory in proportion to quantity; use
+// AllocateContext to bound the time.
+func (p *Packer) Allocate(quantity int) (Allocation, error) {
+	return p.AllocateContext(context.Background(), quantity)
+}
+
+// AllocateContext is Allocate, returning ctx's error if ctx is done before
+// the allocation is found.
+func (p *Packer) AllocateContext(ctx context.Context, quantity int) (Allocation, error) {
+	if p.err != nil {
+		return Allocation{}, p.err
+	}
+	if quantity <= 0 {
+		return Allocation{}, ErrInvalidQuantity
+	}
+	if err := CheckOverflow(quantity, p.sizes); err != nil {
+		return Allocation{}, err
+	}
+	table, err := NewTable(ctx, quantity+p.sizes[len(p.sizes)-1]-1, p.sizes, nil)
+	if err != nil {
+		return Allocation{}, err
+	}
+	a, ok := table.Solve(quantity)
+	if !ok {
+		return Allocation{}, ErrNoCombination
+	}
+	return a, nil
+}
+
+// CheckOverflow returns ErrOverflow unless every total an allocation of
+// quantity may consider fits in an int. No allocation goes past the quantity
+// plus the smallest size before correcting with one pack of another, so
+// totals stay below quantity + 2*largest. sizes must be sorted largest
+// first.
+func CheckOverflow(quantity int, sizes []int) error {
+	if sizes[0] > (math.MaxInt-quantity)/2 {
+		return ErrOverflow
+	}
+	return nil
+}
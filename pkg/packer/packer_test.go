@@ -0,0 +1,89 @@
+package packer
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllocate(t *testing.T) {
+	p := New([]int{250, 500, 1000, 2000, 5000})
+	tests := []struct {
+		quantity int
+		packs    map[int]int
+		total    int
+	}{
+		{1, map[int]int{250: 1}, 250},
+		{250, map[int]int{250: 1}, 250},
+		{251, map[int]int{500: 1}, 500},
+		{501, map[int]int{500: 1, 250: 1}, 750},
+		{12001, map[int]int{5000: 2, 2000: 1, 250: 1}, 12250},
+	}
+	for _, tt := range tests {
+		a, err := p.Allocate(tt.quantity)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.packs, a.Packs, "quantity %d", tt.quantity)
+		assert.Equal(t, tt.total, a.Total)
+		assert.Equal(t, tt.quantity, a.Quantity)
+	}
+}
+
+func TestAllocateCanonical(t *testing.T) {
+	a, err := New([]int{3, 4, 5}).Allocate(8)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{5: 1, 3: 1}, a.Packs)
+	assert.Equal(t, 0, a.Waste())
+	assert.Equal(t, 2, a.PackCount())
+}
+
+func TestNewNormalizesSizes(t *testing.T) {
+	p := New([]int{23, 53, 31, 53})
+	assert.Equal(t, []int{53, 31, 23}, p.Sizes())
+
+	a, err := p.Allocate(500000)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{53: 9429, 31: 7, 23: 2}, a.Packs)
+	assert.Equal(t, 500000, a.Total)
+}
+
+func TestAllocateErrors(t *testing.T) {
+	_, err := New(nil).Allocate(1)
+	assert.ErrorIs(t, err, ErrNoPackSizes)
+
+	_, err = New([]int{250, 0}).Allocate(1)
+	assert.ErrorIs(t, err, ErrInvalidPackSize)
+
+	_, err = New([]int{250}).Allocate(0)
+	assert.ErrorIs(t, err, ErrInvalidQuantity)
+
+	_, err = New([]int{250}).Allocate(math.MaxInt - 100)
+	assert.ErrorIs(t, err, ErrOverflow)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = New([]int{3, 5}).AllocateContext(ctx, 1<<20)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestTable(t *testing.T) {
+	sizes := []int{5, 4, 3}
+	table, err := NewTable(context.Background(), 20, sizes, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, table.Packs(2))
+	assert.Nil(t, table.Packs(21))
+	assert.Equal(t, map[int]int{5: 1, 3: 1}, table.Packs(8))
+
+	a, ok := table.Solve(2)
+	assert.True(t, ok)
+	assert.Equal(t, map[int]int{3: 1}, a.Packs)
+	_, ok = table.Solve(19)
+	assert.True(t, ok)
+
+	// Avoiding 5 trades it for more packs of the other sizes.
+	table, err = NewTable(context.Background(), 20, sizes, []int{5})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{4: 2}, table.Packs(8))
+	assert.Equal(t, map[int]int{4: 1, 3: 2}, table.Packs(10))
+}
@@ -0,0 +1,105 @@
+package packer
+
+import "context"
+
+// ctxCheckInterval controls how often filling a table polls ctx.
+const ctxCheckInterval = 1 << 14
+
+// Table records the fewest packs that sum exactly to every total up to a
+// limit, an unbounded-knapsack style table. One table answers every quantity
+// up to the limit - smallest + 1, so allocating many quantities of the same
+// sizes costs one table.
+type Table struct {
+	// count[t] is the fewest packs summing exactly to t, or -1 if unreachable;
+	// last[t] is the pack size added last on that path.
+	count []int
+	last  []int
+	// avoided[t] is the fewest packs of avoided sizes summing with others to
+	// t, minimised before count. It is nil without avoided sizes.
+	avoided []int
+	// smallest is the smallest pack size.
+	smallest int
+}
+
+// NewTable fills the table for totals up to limit. sizes must be positive
+// and sorted largest first. For each total, the combinations with the
+// fewest packs of the avoid sizes, e.g. sizes being phased out, are
+// preferred before those with the fewest packs.
+func NewTable(ctx context.Context, limit int, sizes, avoid []int) (*Table, error) {
+	d := &Table{
+		count:    make([]int, limit+1),
+		last:     make([]int, limit+1),
+		smallest: sizes[len(sizes)-1],
+	}
+	for t := 1; t <= limit; t++ {
+		d.count[t] = -1
+	}
+	// avoided[i] is set when sizes[i] is avoided.
+	avoided := make([]bool, len(sizes))
+	for i, size := range sizes {
+		for _, a := range avoid {
+			avoided[i] = avoided[i] || a == size
+		}
+	}
+	if len(avoid) > 0 {
+		d.avoided = make([]int, limit+1)
+	}
+
+	for t := 1; t <= limit; t++ {
+		if t%ctxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		// Sizes are descending, so ties on pack count favour larger packs:
+		// each step of the walk back in Packs takes the largest size it can,
+		// which yields the canonical combination.
+		for i, size := range sizes {
+			if size > t || d.count[t-size] < 0 {
+				continue
+			}
+			c := d.count[t-size] + 1
+			if d.avoided != nil {
+				n := d.avoided[t-size]
+				if avoided[i] {
+					n++
+				}
+				if d.count[t] < 0 || n < d.avoided[t] || n == d.avoided[t] && c < d.count[t] {
+					d.count[t], d.last[t], d.avoided[t] = c, size, n
+				}
+				continue
+			}
+			if d.count[t] < 0 || c < d.count[t] {
+				d.count[t] = c
+				d.last[t] = size
+			}
+		}
+	}
+	return d, nil
+}
+
+// Packs returns the combination the table records for the exact total, or
+// nil if no combination sums to it or it is past the limit.
+func (d *Table) Packs(total int) map[int]int {
+	if total <= 0 || total >= len(d.count) || d.count[total] < 0 {
+		return nil
+	}
+	packs := make(map[int]int)
+	for rem := total; rem > 0; rem -= d.last[rem] {
+		packs[d.last[rem]]++
+	}
+	return packs
+}
+
+// Solve returns the allocation of quantity with the least waste, then the
+// fewest packs, or false if no total the table covers reaches it.
+func (d *Table) Solve(quantity int) (Allocation, bool) {
+	limit := min(quantity+d.smallest-1, len(d.count)-1)
+	for t := quantity; t <= limit; t++ {
+		if d.count[t] < 0 {
+			continue
+		}
+		return Allocation{Quantity: quantity, Packs: d.Packs(t), Total: t}, true
+	}
+	return Allocation{}, false
+}